@@ -1,6 +1,7 @@
 "use client"
 
-import { useState } from "react"
+import { useEffect, useState } from "react"
+import Link from "next/link"
 import { useRouter } from "next/navigation"
 import { useForm } from "react-hook-form"
 import { zodResolver } from "@hookform/resolvers/zod"
@@ -8,16 +9,18 @@ import * as z from "zod"
 import { useCart } from "@/context/cart-context"
 import { useAuth } from "@/context/auth-context"
 import { orderService } from "@/services/order-service"
+import { addressService, formatAddress, type Address } from "@/services/address-service"
 import CheckoutSummary from "@/components/checkout/checkout-summary"
 import PaymentMethodSelector from "@/components/checkout/payment-method-selector"
 import { Button } from "@/components/ui/button"
 import { Form, FormControl, FormField, FormItem, FormLabel, FormMessage } from "@/components/ui/form"
 import { Input } from "@/components/ui/input"
-import { Textarea } from "@/components/ui/textarea"
+import { Label } from "@/components/ui/label"
+import { RadioGroup, RadioGroupItem } from "@/components/ui/radio-group"
 import { useToast } from "@/hooks/use-toast"
 
 const checkoutSchema = z.object({
-  shipping_address: z.string().min(5, { message: "Địa chỉ giao hàng là bắt buộc" }),
+  address_id: z.string().min(1, { message: "Vui lòng chọn địa chỉ giao hàng" }),
   payment_method: z.enum(["card"], {
     required_error: "Vui lòng chọn phương thức thanh toán",
   }),
@@ -56,11 +59,13 @@ export default function CheckoutPage() {
   const router = useRouter()
   const { toast } = useToast()
   const [isLoading, setIsLoading] = useState(false)
+  const [addresses, setAddresses] = useState<Address[]>([])
+  const [addressesLoading, setAddressesLoading] = useState(true)
 
   const form = useForm<CheckoutFormValues>({
     resolver: zodResolver(checkoutSchema),
     defaultValues: {
-      shipping_address: user?.address || "",
+      address_id: "",
       payment_method: "card",
       card_number: "",
       expiry_month: "",
@@ -71,6 +76,25 @@ export default function CheckoutPage() {
 
   const paymentMethod = form.watch("payment_method")
 
+  // Tải sổ địa chỉ và chọn sẵn địa chỉ giao hàng mặc định
+  useEffect(() => {
+    if (!user) return
+
+    addressService
+      .getAddresses(user.id)
+      .then((data) => {
+        setAddresses(data)
+        const defaultAddress = data.find((address) => address.default_shipping) || data[0]
+        if (defaultAddress && !form.getValues("address_id")) {
+          form.setValue("address_id", defaultAddress.id)
+        }
+      })
+      .catch((error) => {
+        console.error("Failed to load addresses:", error)
+      })
+      .finally(() => setAddressesLoading(false))
+  }, [user, form])
+
   async function onSubmit(data: CheckoutFormValues) {
     if (!cart || !user) return
 
@@ -82,7 +106,7 @@ export default function CheckoutPage() {
 
       const orderData = {
         customer_id: user.id,
-        shipping_address: data.shipping_address,
+        address_id: data.address_id,
         items: cart.items.map((item) => ({
           product_id: item.product_id,
           quantity: item.quantity,
@@ -236,13 +260,34 @@ export default function CheckoutPage() {
 
                 <FormField
                   control={form.control}
-                  name="shipping_address"
+                  name="address_id"
                   render={({ field }) => (
                     <FormItem>
                       <FormLabel>Địa chỉ giao hàng</FormLabel>
-                      <FormControl>
-                        <Textarea placeholder="Nhập địa chỉ giao hàng đầy đủ của bạn" className="min-h-[100px]" {...field} />
-                      </FormControl>
+                      {addressesLoading ? (
+                        <p className="text-sm text-muted-foreground">Đang tải địa chỉ...</p>
+                      ) : addresses.length === 0 ? (
+                        <p className="text-sm text-muted-foreground">
+                          Bạn chưa có địa chỉ nào.{" "}
+                          <Link href="/profile" className="font-medium text-primary hover:underline">
+                            Thêm địa chỉ giao hàng
+                          </Link>
+                        </p>
+                      ) : (
+                        <FormControl>
+                          <RadioGroup value={field.value} onValueChange={field.onChange} className="space-y-2">
+                            {addresses.map((address) => (
+                              <div key={address.id} className="flex items-start space-x-3 rounded-md border p-3">
+                                <RadioGroupItem value={address.id} id={`address-${address.id}`} className="mt-1" />
+                                <Label htmlFor={`address-${address.id}`} className="cursor-pointer font-normal">
+                                  <span className="font-medium">{address.recipient}</span> · {address.phone}
+                                  <span className="block text-muted-foreground">{formatAddress(address)}</span>
+                                </Label>
+                              </div>
+                            ))}
+                          </RadioGroup>
+                        </FormControl>
+                      )}
                       <FormMessage />
                     </FormItem>
                   )}
@@ -20,6 +20,7 @@ import { authService } from "@/services/auth-service"
 
 const loginSchema = z.object({
   email: z.string().email({ message: "Email không hợp lệ" }),
+  password: z.string().min(1, { message: "Mật khẩu là bắt buộc" }),
 })
 
 type LoginFormValues = z.infer<typeof loginSchema>
@@ -47,6 +48,7 @@ export default function LoginPage() {
     resolver: zodResolver(loginSchema),
     defaultValues: {
       email: "",
+      password: "",
     },
     mode: "onChange"
   })
@@ -65,7 +67,7 @@ export default function LoginPage() {
     } catch (error) {
       toast({
         title: "Đăng nhập thất bại",
-        description: "Vui lòng kiểm tra email của bạn",
+        description: "Email hoặc mật khẩu không đúng",
         variant: "destructive",
       })
     } finally {
@@ -129,7 +131,7 @@ export default function LoginPage() {
               </div>
               <CardTitle className="text-2xl font-bold text-center">Đăng nhập</CardTitle>
               <CardDescription className="text-center">
-                Nhập email của bạn để đăng nhập.
+                Nhập email và mật khẩu của bạn để đăng nhập.
               </CardDescription>
             </CardHeader>
             <CardContent>
@@ -156,6 +158,27 @@ export default function LoginPage() {
                     )}
                   />
 
+                  <FormField
+                    control={form.control}
+                    name="password"
+                    render={({ field }) => (
+                      <FormItem>
+                        <FormLabel>Mật khẩu</FormLabel>
+                        <FormControl>
+                          <div className="relative">
+                            <Lock className="absolute left-3 top-3 h-5 w-5 text-gray-400" />
+                            <Input
+                              type="password"
+                              placeholder="••••••••••"
+                              className="pl-10 bg-gray-50 dark:bg-gray-700 border-gray-200 dark:border-gray-600"
+                              {...field}
+                            />
+                          </div>
+                        </FormControl>
+                        <FormMessage />
+                      </FormItem>
+                    )}
+                  />
 
                   <Button
                     type="submit"
@@ -5,6 +5,7 @@ import { useParams } from "next/navigation"
 import Image from "next/image"
 import Link from "next/link"
 import { orderService } from "@/services/order-service"
+import { formatAddress, type AddressSnapshot } from "@/services/address-service"
 import { paymentService } from "@/services/payment-service"
 import { shippingService } from "@/services/shipping-service"
 import OrderStatusStepper from "@/components/checkout/order-status-stepper"
@@ -24,7 +25,7 @@ type OrderDetail = {
   customer_id: string
   status: "CREATED" | "INVENTORY_CHECKED" | "PAYMENT_PROCESSED" | "CONFIRMED" | "SHIPPING_SCHEDULED" | "SHIPPED" | "DELIVERED" | "CANCELLED" | "FAILED"
   total_amount: number
-  shipping_address: string
+  shipping_address: AddressSnapshot
   items: {
     id: string
     order_id: string
@@ -59,7 +60,7 @@ type Shipment = {
   carrier: string
   tracking_number: string
   status: "PENDING" | "IN_TRANSIT" | "DELIVERED"
-  shipping_address: string
+  shipping_address: AddressSnapshot
   customer_id: string
   estimated_delivery: string
   created_at: string
@@ -324,7 +325,14 @@ export default function OrderDetailPage() {
               <div className="space-y-4">
                 <div>
                   <h3 className="text-sm font-medium text-muted-foreground">Shipping Address</h3>
-                  <p className="mt-1">{order.shipping_address}</p>
+                  <p className="mt-1">
+                    {order.shipping_address.recipient && (
+                      <span className="block font-medium">
+                        {order.shipping_address.recipient} · {order.shipping_address.phone}
+                      </span>
+                    )}
+                    {formatAddress(order.shipping_address)}
+                  </p>
                 </div>
 
                 {shipment && (
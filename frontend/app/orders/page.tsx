@@ -4,6 +4,7 @@ import { useEffect, useState } from "react"
 import Link from "next/link"
 import { useAuth } from "@/context/auth-context"
 import { orderService } from "@/services/order-service"
+import { formatAddress, type AddressSnapshot } from "@/services/address-service"
 import { Button } from "@/components/ui/button"
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
@@ -17,7 +18,7 @@ type Order = {
   customer_id: string
   status: "CREATED" | "CONFIRMED" | "DELIVERED" | "CANCELLED" | "FAILED"
   total_amount: number
-  shipping_address: string
+  shipping_address: AddressSnapshot
   created_at: string
   updated_at: string
 }
@@ -141,7 +142,7 @@ export default function OrdersPage() {
                       <span className="font-medium text-foreground">${order.total_amount.toFixed(2)}</span>
                     </p>
                     <p className="text-sm text-muted-foreground">
-                      Shipping Address: <span className="font-medium text-foreground">{order.shipping_address}</span>
+                      Shipping Address: <span className="font-medium text-foreground">{formatAddress(order.shipping_address)}</span>
                     </p>
                   </div>
                   <Button asChild>
@@ -11,6 +11,8 @@ import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/com
 import { Form, FormControl, FormField, FormItem, FormLabel, FormMessage } from "@/components/ui/form"
 import { Input } from "@/components/ui/input"
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs"
+import AddressBook from "@/components/profile/address-book"
+import PrivacySettings from "@/components/profile/privacy-settings"
 import { useToast } from "@/hooks/use-toast"
 
 const profileSchema = z.object({
@@ -79,6 +81,7 @@ export default function ProfilePage() {
           <TabsTrigger value="personal">Personal Information</TabsTrigger>
           <TabsTrigger value="shipping">Shipping Addresses</TabsTrigger>
           <TabsTrigger value="payment">Payment Methods</TabsTrigger>
+          <TabsTrigger value="privacy">Privacy</TabsTrigger>
         </TabsList>
 
         <TabsContent value="personal">
@@ -163,29 +166,7 @@ export default function ProfilePage() {
               <CardDescription>Manage your shipping addresses</CardDescription>
             </CardHeader>
             <CardContent>
-              <p className="text-muted-foreground">You currently have 1 shipping address.</p>
-
-              <div className="mt-4 rounded-lg border p-4">
-                <div className="flex items-center justify-between">
-                  <div>
-                    <p className="font-medium">
-                      {user.first_name} {user.last_name}
-                    </p>
-                    <p className="text-sm text-muted-foreground">{user.address}</p>
-                    <p className="text-sm text-muted-foreground">{user.phone}</p>
-                  </div>
-                  <div className="flex gap-2">
-                    <Button variant="outline" size="sm">
-                      Edit
-                    </Button>
-                    <Button variant="outline" size="sm">
-                      Delete
-                    </Button>
-                  </div>
-                </div>
-              </div>
-
-              <Button className="mt-4">Add New Address</Button>
+              <AddressBook userId={user.id} />
             </CardContent>
           </Card>
         </TabsContent>
@@ -203,6 +184,18 @@ export default function ProfilePage() {
             </CardContent>
           </Card>
         </TabsContent>
+
+        <TabsContent value="privacy">
+          <Card>
+            <CardHeader>
+              <CardTitle>Privacy</CardTitle>
+              <CardDescription>Download or delete your personal data</CardDescription>
+            </CardHeader>
+            <CardContent>
+              <PrivacySettings userId={user.id} />
+            </CardContent>
+          </Card>
+        </TabsContent>
       </Tabs>
     </div>
   )
@@ -15,11 +15,19 @@ import { Input } from "@/components/ui/input"
 import { Card, CardContent, CardHeader, CardTitle, CardDescription, CardFooter } from "@/components/ui/card"
 import { useToast } from "@/hooks/use-toast"
 import { ThemeToggle } from "@/components/theme-toggle"
-import { ArrowRight, Mail, User, Phone, Home, CheckCircle } from "lucide-react"
+import { ArrowRight, Mail, Lock, User, Phone, Home, CheckCircle } from "lucide-react"
+
+// Mật khẩu theo chính sách của user-service
+const passwordSchema = z
+  .string()
+  .min(10, { message: "Mật khẩu phải có ít nhất 10 ký tự" })
+  .regex(/[A-Za-z]/, { message: "Mật khẩu phải có chữ cái" })
+  .regex(/[0-9]/, { message: "Mật khẩu phải có chữ số" })
 
 // Schema cho bước 1 (thông tin cơ bản)
 const step1Schema = z.object({
   email: z.string().email({ message: "Email không hợp lệ" }),
+  password: passwordSchema,
   first_name: z.string().min(2, { message: "Họ là bắt buộc" }),
   last_name: z.string().min(2, { message: "Tên là bắt buộc" }),
   phone: z.string().optional(),
@@ -29,6 +37,7 @@ const step1Schema = z.object({
 // Schema cho bước 2 (thông tin liên hệ)
 const step2Schema = z.object({
   email: z.string().email({ message: "Email không hợp lệ" }),
+  password: passwordSchema,
   first_name: z.string().min(2, { message: "Họ là bắt buộc" }),
   last_name: z.string().min(2, { message: "Tên là bắt buộc" }),
   phone: z.string().min(10, { message: "Số điện thoại hợp lệ là bắt buộc" }),
@@ -56,6 +65,7 @@ export default function RegisterPage() {
     resolver: zodResolver(step === 1 ? step1Schema : step2Schema),
     defaultValues: {
       email: "",
+      password: "",
       first_name: "",
       last_name: "",
       phone: "",
@@ -66,10 +76,10 @@ export default function RegisterPage() {
 
   // Xử lý khi chuyển từ bước 1 sang bước 2
   const handleContinue = () => {
-    const { email, first_name, last_name } = form.getValues();
+    const { email, password, first_name, last_name } = form.getValues();
 
     // Kiểm tra các trường ở bước 1
-    if (!email || !first_name || !last_name) {
+    if (!email || !password || !first_name || !last_name) {
       toast({
         title: "Thiếu thông tin",
         description: "Vui lòng điền đầy đủ thông tin trước khi tiếp tục",
@@ -88,6 +98,17 @@ export default function RegisterPage() {
       return;
     }
 
+    // Kiểm tra mật khẩu theo chính sách
+    const passwordCheck = passwordSchema.safeParse(password);
+    if (!passwordCheck.success) {
+      toast({
+        title: "Mật khẩu chưa đủ mạnh",
+        description: passwordCheck.error.issues[0].message,
+        variant: "destructive",
+      });
+      return;
+    }
+
     // Nếu thông tin hợp lệ, chuyển sang bước 2
     setStep(2);
   };
@@ -300,6 +321,27 @@ export default function RegisterPage() {
                           )}
                         />
                       </div>
+                      <FormField
+                        control={form.control}
+                        name="password"
+                        render={({ field }) => (
+                          <FormItem>
+                            <FormLabel>Mật khẩu</FormLabel>
+                            <FormControl>
+                              <div className="relative">
+                                <Lock className="absolute left-3 top-3 h-5 w-5 text-gray-400" />
+                                <Input
+                                  type="password"
+                                  placeholder="Ít nhất 10 ký tự, gồm chữ và số"
+                                  className="pl-10 bg-gray-50 dark:bg-gray-700 border-gray-200 dark:border-gray-600"
+                                  {...field}
+                                />
+                              </div>
+                            </FormControl>
+                            <FormMessage />
+                          </FormItem>
+                        )}
+                      />
                     </>
                   ) : (
                     <>
@@ -8,6 +8,7 @@ import { Separator } from "@/components/ui/separator"
 import { formatDate } from "@/lib/utils"
 import { MapPin, Package, Truck, CheckCircle, AlertCircle } from "lucide-react"
 import { useToast } from "@/hooks/use-toast"
+import type { AddressSnapshot } from "@/services/address-service"
 
 type OrderTrackingProps = {
   orderId: string
@@ -17,7 +18,7 @@ type OrderTrackingProps = {
     carrier: string
     tracking_number: string
     status: "PENDING" | "IN_TRANSIT" | "DELIVERED"
-    shipping_address: string
+    shipping_address: AddressSnapshot
     customer_id: string
     estimated_delivery: string
     created_at: string
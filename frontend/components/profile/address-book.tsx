@@ -0,0 +1,220 @@
+"use client"
+
+import { useEffect, useState } from "react"
+import { useForm } from "react-hook-form"
+import { zodResolver } from "@hookform/resolvers/zod"
+import * as z from "zod"
+import { addressService, formatAddress, type Address } from "@/services/address-service"
+import { Badge } from "@/components/ui/badge"
+import { Button } from "@/components/ui/button"
+import { Form, FormControl, FormField, FormItem, FormLabel, FormMessage } from "@/components/ui/form"
+import { Input } from "@/components/ui/input"
+import { useToast } from "@/hooks/use-toast"
+
+// Country specific rules are checked by user-service
+const addressSchema = z.object({
+  recipient: z.string().min(1, { message: "Recipient is required" }),
+  phone: z.string().min(7, { message: "Phone number is required" }),
+  line1: z.string().min(1, { message: "Street address is required" }),
+  line2: z.string().optional(),
+  ward: z.string().optional(),
+  district: z.string().optional(),
+  city: z.string().optional(),
+  province: z.string().optional(),
+  postal_code: z.string().optional(),
+  country: z.string().regex(/^[A-Za-z]{2}$/, { message: "Use a two-letter country code, e.g. VN" }),
+})
+
+type AddressFormValues = z.infer<typeof addressSchema>
+
+const emptyAddress: AddressFormValues = {
+  recipient: "",
+  phone: "",
+  line1: "",
+  line2: "",
+  ward: "",
+  district: "",
+  city: "",
+  province: "",
+  postal_code: "",
+  country: "VN",
+}
+
+const addressFields: { name: keyof AddressFormValues; label: string }[] = [
+  { name: "recipient", label: "Recipient" },
+  { name: "phone", label: "Phone" },
+  { name: "line1", label: "Street address" },
+  { name: "line2", label: "Apartment, suite, etc." },
+  { name: "ward", label: "Ward" },
+  { name: "district", label: "District" },
+  { name: "city", label: "City" },
+  { name: "province", label: "Province / State" },
+  { name: "postal_code", label: "Postal code" },
+  { name: "country", label: "Country" },
+]
+
+export default function AddressBook({ userId }: { userId: string }) {
+  const { toast } = useToast()
+  const [addresses, setAddresses] = useState<Address[]>([])
+  const [loading, setLoading] = useState(true)
+  const [saving, setSaving] = useState(false)
+  // undefined hides the form, null adds a new address
+  const [editing, setEditing] = useState<Address | null | undefined>(undefined)
+
+  const form = useForm<AddressFormValues>({
+    resolver: zodResolver(addressSchema),
+    defaultValues: emptyAddress,
+  })
+
+  async function loadAddresses() {
+    try {
+      setAddresses(await addressService.getAddresses(userId))
+    } catch (error) {
+      console.error("Failed to load addresses:", error)
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  useEffect(() => {
+    loadAddresses()
+  }, [userId])
+
+  function showError(title: string, error: any) {
+    toast({
+      title,
+      description: error.response?.data?.error || "Please try again",
+      variant: "destructive",
+    })
+  }
+
+  function openForm(address: Address | null) {
+    form.reset(address ? { ...emptyAddress, ...address } : emptyAddress)
+    setEditing(address)
+  }
+
+  async function onSubmit(data: AddressFormValues) {
+    setSaving(true)
+    try {
+      if (editing) {
+        await addressService.updateAddress(userId, editing.id, data)
+      } else {
+        await addressService.createAddress(userId, data)
+      }
+      setEditing(undefined)
+      await loadAddresses()
+      toast({ title: "Address saved" })
+    } catch (error: any) {
+      showError("Failed to save address", error)
+    } finally {
+      setSaving(false)
+    }
+  }
+
+  async function makeDefault(address: Address, flag: "default_shipping" | "default_billing") {
+    try {
+      await addressService.updateAddress(userId, address.id, { ...address, [flag]: true })
+      await loadAddresses()
+    } catch (error: any) {
+      showError("Failed to update address", error)
+    }
+  }
+
+  async function deleteAddress(address: Address) {
+    try {
+      await addressService.deleteAddress(userId, address.id)
+      await loadAddresses()
+      toast({ title: "Address deleted" })
+    } catch (error: any) {
+      showError("Failed to delete address", error)
+    }
+  }
+
+  if (loading) {
+    return <p className="text-muted-foreground">Loading addresses...</p>
+  }
+
+  return (
+    <div>
+      <p className="text-muted-foreground">
+        You currently have {addresses.length} shipping address{addresses.length === 1 ? "" : "es"}.
+      </p>
+
+      {addresses.map((address) => (
+        <div key={address.id} className="mt-4 rounded-lg border p-4">
+          <div className="flex items-start justify-between gap-4">
+            <div>
+              <p className="font-medium">
+                {address.recipient}
+                {address.default_shipping && <Badge className="ml-2">Default shipping</Badge>}
+                {address.default_billing && (
+                  <Badge variant="secondary" className="ml-2">
+                    Default billing
+                  </Badge>
+                )}
+              </p>
+              <p className="text-sm text-muted-foreground">{formatAddress(address)}</p>
+              <p className="text-sm text-muted-foreground">{address.phone}</p>
+            </div>
+            <div className="flex flex-wrap justify-end gap-2">
+              {!address.default_shipping && (
+                <Button variant="outline" size="sm" onClick={() => makeDefault(address, "default_shipping")}>
+                  Set as shipping
+                </Button>
+              )}
+              {!address.default_billing && (
+                <Button variant="outline" size="sm" onClick={() => makeDefault(address, "default_billing")}>
+                  Set as billing
+                </Button>
+              )}
+              <Button variant="outline" size="sm" onClick={() => openForm(address)}>
+                Edit
+              </Button>
+              <Button variant="outline" size="sm" onClick={() => deleteAddress(address)}>
+                Delete
+              </Button>
+            </div>
+          </div>
+        </div>
+      ))}
+
+      {editing === undefined ? (
+        <Button className="mt-4" onClick={() => openForm(null)}>
+          Add New Address
+        </Button>
+      ) : (
+        <Form {...form}>
+          <form onSubmit={form.handleSubmit(onSubmit)} className="mt-6 space-y-4 rounded-lg border p-4">
+            <h3 className="font-medium">{editing ? "Edit Address" : "New Address"}</h3>
+            <div className="grid grid-cols-1 gap-4 md:grid-cols-2">
+              {addressFields.map(({ name, label }) => (
+                <FormField
+                  key={name}
+                  control={form.control}
+                  name={name}
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>{label}</FormLabel>
+                      <FormControl>
+                        <Input {...field} value={field.value || ""} />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+              ))}
+            </div>
+            <div className="flex gap-2">
+              <Button type="submit" disabled={saving}>
+                {saving ? "Saving..." : "Save Address"}
+              </Button>
+              <Button type="button" variant="outline" onClick={() => setEditing(undefined)}>
+                Cancel
+              </Button>
+            </div>
+          </form>
+        </Form>
+      )}
+    </div>
+  )
+}
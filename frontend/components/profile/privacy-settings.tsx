@@ -0,0 +1,94 @@
+"use client"
+
+import { useState } from "react"
+import { useRouter } from "next/navigation"
+import { useAuth } from "@/context/auth-context"
+import { authService } from "@/services/auth-service"
+import { Button } from "@/components/ui/button"
+import { useToast } from "@/hooks/use-toast"
+
+export default function PrivacySettings({ userId }: { userId: string }) {
+  const { logout } = useAuth()
+  const router = useRouter()
+  const { toast } = useToast()
+  const [exporting, setExporting] = useState(false)
+  const [confirming, setConfirming] = useState(false)
+  const [erasing, setErasing] = useState(false)
+
+  async function downloadData() {
+    setExporting(true)
+    try {
+      const data = await authService.exportData(userId)
+      const url = URL.createObjectURL(data)
+      const link = document.createElement("a")
+      link.href = url
+      link.download = `user-data-${userId}.zip`
+      link.click()
+      URL.revokeObjectURL(url)
+    } catch (error) {
+      toast({
+        title: "Export failed",
+        description: "Some of your data could not be gathered, please try again later",
+        variant: "destructive",
+      })
+    } finally {
+      setExporting(false)
+    }
+  }
+
+  async function deleteAccount() {
+    setErasing(true)
+    try {
+      await authService.requestErasure(userId)
+      toast({
+        title: "Account deleted",
+        description: "Your personal data is being erased from all our services",
+      })
+      logout()
+      router.push("/")
+    } catch (error: any) {
+      toast({
+        title: "Failed to delete account",
+        description: error.response?.data?.error || "Please try again",
+        variant: "destructive",
+      })
+      setErasing(false)
+    }
+  }
+
+  return (
+    <div className="space-y-6">
+      <div>
+        <h3 className="font-medium">Download your data</h3>
+        <p className="text-sm text-muted-foreground">
+          Get a copy of your profile, addresses, orders, payments, shipments, notifications and cart.
+        </p>
+        <Button className="mt-2" variant="outline" onClick={downloadData} disabled={exporting}>
+          {exporting ? "Preparing..." : "Download My Data"}
+        </Button>
+      </div>
+
+      <div>
+        <h3 className="font-medium">Delete your account</h3>
+        <p className="text-sm text-muted-foreground">
+          Your account and personal data are erased. Orders, payments and shipments are kept as financial and
+          delivery records without your contact and card details. This cannot be undone.
+        </p>
+        {confirming ? (
+          <div className="mt-2 flex gap-2">
+            <Button variant="destructive" onClick={deleteAccount} disabled={erasing}>
+              {erasing ? "Deleting..." : "Yes, Delete My Account"}
+            </Button>
+            <Button variant="outline" onClick={() => setConfirming(false)} disabled={erasing}>
+              Cancel
+            </Button>
+          </div>
+        ) : (
+          <Button className="mt-2" variant="destructive" onClick={() => setConfirming(true)}>
+            Delete Account
+          </Button>
+        )}
+      </div>
+    </div>
+  )
+}
@@ -12,14 +12,15 @@ type User = {
   last_name: string
   phone: string
   address: string
+  role: "customer" | "support" | "warehouse" | "admin"
+  status: "pending_verification" | "active" | "locked"
   created_at: string
   updated_at: string
 }
 
 type LoginCredentials = {
-  id?: string
   email: string
-  address?: string
+  password: string
 }
 
 type AuthContextType = {
@@ -81,7 +82,9 @@ export function AuthProvider({ children }: { children: ReactNode }) {
   }
 
   const logout = () => {
+    authService.logout()
     localStorage.removeItem("token")
+    localStorage.removeItem("refreshToken")
     localStorage.removeItem("userId")
     localStorage.removeItem("cartId")
     setUser(null)
@@ -0,0 +1,72 @@
+import api from "./api"
+
+export type Address = {
+  id: string
+  customer_id: string
+  recipient: string
+  phone: string
+  line1: string
+  line2?: string
+  ward?: string
+  district?: string
+  city?: string
+  province?: string
+  postal_code?: string
+  country: string
+  default_shipping: boolean
+  default_billing: boolean
+}
+
+// Fields of an address as sent to user-service. Setting a default flag moves
+// that default away from the other addresses.
+export type AddressInput = Omit<Address, "id" | "customer_id" | "default_shipping" | "default_billing"> & {
+  default_shipping?: boolean
+  default_billing?: boolean
+}
+
+// The copy of an address kept by orders and shipments
+export type AddressSnapshot = Omit<Address, "id" | "customer_id" | "default_shipping" | "default_billing">
+
+// Format an address on one line. Orders placed before addresses were
+// structured only have the first line.
+export function formatAddress(address?: Partial<AddressSnapshot> | null) {
+  if (!address) return ""
+  return [
+    address.line1,
+    address.line2,
+    address.ward,
+    address.district,
+    address.city,
+    address.province,
+    address.postal_code,
+    address.country,
+  ]
+    .filter(Boolean)
+    .join(", ")
+}
+
+export const addressService = {
+  // Get the address book of a user
+  async getAddresses(userId: string): Promise<Address[]> {
+    const response = await api.get(`/users/${userId}/addresses`)
+    return response.data
+  },
+
+  // Add an address to the address book of a user
+  async createAddress(userId: string, address: AddressInput): Promise<Address> {
+    const response = await api.post(`/users/${userId}/addresses`, address)
+    return response.data
+  },
+
+  // Change an address of a user
+  async updateAddress(userId: string, addressId: string, address: AddressInput): Promise<Address> {
+    const response = await api.put(`/users/${userId}/addresses/${addressId}`, address)
+    return response.data
+  },
+
+  // Remove an address from the address book of a user
+  async deleteAddress(userId: string, addressId: string) {
+    const response = await api.delete(`/users/${userId}/addresses/${addressId}`)
+    return response.data
+  },
+}
@@ -65,9 +65,24 @@ api.interceptors.response.use(
       } : 'No config',
     });
 
-    // Handle token expiration
+    // Handle token expiration: try once to refresh the access token, then
+    // send the user to the login page
+    const original = error.config
+    const refreshToken = localStorage.getItem("refreshToken")
+    if (error.response && error.response.status === 401 && original && !original._retry &&
+        refreshToken && original.url !== "/auth/refresh") {
+      original._retry = true
+      return api.post("/auth/refresh", { refresh_token: refreshToken }).then((response) => {
+        localStorage.setItem("token", response.data.token)
+        localStorage.setItem("refreshToken", response.data.refresh_token)
+        original.headers.Authorization = `Bearer ${response.data.token}`
+        return api(original)
+      })
+    }
+
     if (error.response && error.response.status === 401) {
       localStorage.removeItem("token")
+      localStorage.removeItem("refreshToken")
       window.location.href = "/login"
     }
 
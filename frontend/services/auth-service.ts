@@ -8,28 +8,39 @@ export const authService = {
     last_name: string
     phone: string
     address: string
+    password: string
   }) {
-    const response = await api.post("/users", userData)
+    const response = await api.post("/auth/register", userData)
 
     // Store the user ID in localStorage for convenience
-    if (response.data && response.data.id) {
-      localStorage.setItem("registeredUserId", response.data.id)
+    if (response.data && response.data.user) {
+      localStorage.setItem("registeredUserId", response.data.user.id)
     }
 
-    return response.data
+    return response.data.user
   },
 
-  // Login user with email only
+  // Login user with email and password
   async login(credentials: {
     email: string
+    password: string
   }) {
     try {
       // Use the new login endpoint
       const response = await api.post("/auth/login", credentials);
 
+      // Accounts with MFA complete the login with verifyMfa
+      if (response.data.mfa_required || response.data.mfa_enrollment_required) {
+        const error = new Error("MFA required") as Error & { mfaToken?: string, mfaEnrollmentRequired?: boolean };
+        error.mfaToken = response.data.mfa_token;
+        error.mfaEnrollmentRequired = !!response.data.mfa_enrollment_required;
+        throw error;
+      }
+
       if (response.data.token && response.data.user) {
-        // Store token and user ID in localStorage
+        // Store tokens and user ID in localStorage
         localStorage.setItem("token", response.data.token);
+        localStorage.setItem("refreshToken", response.data.refresh_token);
         localStorage.setItem("userId", response.data.user.id);
 
         return {
@@ -45,6 +56,42 @@ export const authService = {
     }
   },
 
+  // Complete a login with a TOTP code or a recovery code
+  async verifyMfa(mfaToken: string, code: { code?: string, recovery_code?: string }) {
+    const response = await api.post("/auth/mfa/verify", { mfa_token: mfaToken, ...code });
+    localStorage.setItem("token", response.data.token);
+    localStorage.setItem("refreshToken", response.data.refresh_token);
+    localStorage.setItem("userId", response.data.user.id);
+
+    return {
+      token: response.data.token,
+      user: response.data.user
+    };
+  },
+
+  // Exchange the refresh token for a new pair of tokens
+  async refresh() {
+    const refreshToken = localStorage.getItem("refreshToken");
+    if (!refreshToken) {
+      throw new Error("No refresh token found");
+    }
+
+    const response = await api.post("/auth/refresh", { refresh_token: refreshToken });
+    localStorage.setItem("token", response.data.token);
+    localStorage.setItem("refreshToken", response.data.refresh_token);
+
+    return response.data.token;
+  },
+
+  // Revoke the current session on the server
+  async logout() {
+    try {
+      await api.post("/auth/logout");
+    } catch (error) {
+      console.error("Logout error:", error);
+    }
+  },
+
   // Legacy login method using verify endpoint
   async verifyUser(credentials: {
     id?: string
@@ -55,11 +102,7 @@ export const authService = {
       const response = await api.post("/users/verify", credentials);
 
       if (response.data.verified) {
-        // Store token and user ID in localStorage
-        if (response.data.token) {
-          localStorage.setItem("token", response.data.token);
-        }
-
+        // Store user ID in localStorage
         if (response.data.user && response.data.user.id) {
           localStorage.setItem("userId", response.data.user.id);
         }
@@ -93,6 +136,39 @@ export const authService = {
     return response.data;
   },
 
+  // Change the password of the logged in user
+  async changePassword(passwords: {
+    current_password: string
+    new_password: string
+  }) {
+    const response = await api.put("/auth/password", passwords)
+    return response.data
+  },
+
+  // Verify the email address with the token from the verification email
+  async verifyEmail(token: string) {
+    const response = await api.post("/auth/verify-email", { token })
+    return response.data
+  },
+
+  // Ask for another verification email
+  async resendVerification(email: string) {
+    const response = await api.post("/auth/resend-verification", { email })
+    return response.data
+  },
+
+  // Ask for a password reset email
+  async forgotPassword(email: string) {
+    const response = await api.post("/auth/forgot-password", { email })
+    return response.data
+  },
+
+  // Set a new password with the token from the password reset email
+  async resetPassword(token: string, newPassword: string) {
+    const response = await api.post("/auth/reset-password", { token, new_password: newPassword })
+    return response.data
+  },
+
   // Update user profile
   async updateProfile(
     userId: string,
@@ -106,4 +182,16 @@ export const authService = {
     const response = await api.put(`/users/${userId}`, userData)
     return response.data
   },
+
+  // Download all personal data the services hold about a user as a zip file
+  async exportData(userId: string): Promise<Blob> {
+    const response = await api.post(`/users/${userId}/data-export`, null, { responseType: "blob" })
+    return response.data
+  },
+
+  // Erase the account of a user and ask every service to erase its data
+  async requestErasure(userId: string) {
+    const response = await api.post(`/users/${userId}/erasure`)
+    return response.data
+  },
 }
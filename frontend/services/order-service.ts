@@ -4,7 +4,8 @@ export const orderService = {
   // Create a new order
   async createOrder(orderData: {
     customer_id: string
-    shipping_address: string
+    // Saved address to ship to; the default shipping address if left out
+    address_id?: string
     items: {
       product_id: string
       quantity: number
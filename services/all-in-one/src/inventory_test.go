@@ -0,0 +1,427 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	inventoryapp "github.com/online-order-system/inventory-service/app"
+	inventoryconfig "github.com/online-order-system/inventory-service/config"
+	"github.com/online-order-system/inventory-service/eventbus"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// newInventoryTest starts inventory-service on an in-memory bus and returns
+// it with its database
+func newInventoryTest(t *testing.T) (*inventoryapp.App, *sql.DB) {
+	bus := eventbus.NewMemoryBus()
+
+	cfg := inventoryconfig.LoadConfig()
+	cfg.DBDriver, cfg.DBDSN = sqliteDriver, sqliteDSN(t.TempDir(), "inventory")
+	cfg.EventBus = eventbus.DriverMemory
+
+	app, err := inventoryapp.New(cfg, bus)
+	if err != nil {
+		t.Fatalf("failed to create inventory-service: %v", err)
+	}
+	db, err := sql.Open(sqliteDriver, cfg.DBDSN)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		bus.Close()
+		app.Close()
+	})
+	return app, db
+}
+
+// createCategory creates a top-level category and returns its ID
+func createCategory(t *testing.T, app *inventoryapp.App, name string) string {
+	category, err := app.Service.CreateCategory(models.CreateCategoryRequest{Name: name})
+	if err != nil {
+		t.Fatalf("failed to create category %s: %v", name, err)
+	}
+	return category.ID
+}
+
+// createProduct creates a product with stock in the default warehouse
+func createProduct(t *testing.T, app *inventoryapp.App, categoryID, name string, quantity int) models.Product {
+	product, err := app.Service.CreateProduct(models.CreateProductRequest{
+		Name:        name,
+		Description: name,
+		CategoryID:  categoryID,
+		Price:       7,
+		Quantity:    quantity,
+		Caller:      "admin-1",
+	})
+	if err != nil {
+		t.Fatalf("failed to create product %s: %v", name, err)
+	}
+	return product
+}
+
+// reserve reserves stock of a product for an order
+func reserve(t *testing.T, app *inventoryapp.App, orderID, productID string, quantity int) models.Reservation {
+	var req models.ReserveStockRequest
+	body, _ := json.Marshal(map[string]interface{}{
+		"order_id": orderID,
+		"items":    []map[string]interface{}{{"product_id": productID, "quantity": quantity}},
+	})
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("failed to build reservation: %v", err)
+	}
+
+	reservation, err := app.Service.ReserveStock(req)
+	if err != nil || !reservation.Available {
+		t.Fatalf("failed to reserve %d of %s for %s: %+v, %v", quantity, productID, orderID, reservation, err)
+	}
+	return reservation
+}
+
+func TestStockLedgerReconciles(t *testing.T) {
+	app, db := newInventoryTest(t)
+	product := createProduct(t, app, createCategory(t, app, "Kitchen"), "Pan", 10)
+
+	hanoi, err := app.Service.CreateWarehouse(models.CreateWarehouseRequest{Code: "hn", Name: "Ha Noi", Country: "vn"})
+	if err != nil {
+		t.Fatalf("failed to create warehouse: %v", err)
+	}
+	stock, err := app.Service.GetProductStock(product.ID)
+	if err != nil || len(stock.Locations) != 1 {
+		t.Fatalf("stock of new product: %+v, %v", stock, err)
+	}
+	mainWarehouse := stock.Locations[0].WarehouseID
+
+	// Every way stock moves goes into the ledger
+	four := 4
+	if _, err := app.Service.SetStock(hanoi.ID, product.ID, models.SetStockRequest{Quantity: &four, Caller: "admin-1"}); err != nil {
+		t.Fatalf("failed to set stock: %v", err)
+	}
+	_, err = app.Service.TransferStock(models.TransferStockRequest{
+		ProductID: product.ID, FromWarehouseID: mainWarehouse, ToWarehouseID: hanoi.ID, Quantity: 3, Caller: "admin-1",
+	})
+	if err != nil {
+		t.Fatalf("failed to transfer stock: %v", err)
+	}
+	reserve(t, app, "order-1", product.ID, 5)
+	if err := app.Service.CommitStock("order-1"); err != nil {
+		t.Fatalf("failed to commit stock: %v", err)
+	}
+	reserve(t, app, "order-2", product.ID, 2)
+	if err := app.Service.RestoreStock(models.RestoreStockRequest{OrderID: "order-2", ProductID: product.ID, Quantity: 2}); err != nil {
+		t.Fatalf("failed to restore stock: %v", err)
+	}
+
+	movements, err := app.Service.GetMovements(models.MovementFilter{ProductID: product.ID})
+	if err != nil {
+		t.Fatalf("failed to get movements: %v", err)
+	}
+	counts := make(map[string]int)
+	balance := 0
+	for _, movement := range movements {
+		counts[movement.Type]++
+		balance += movement.Quantity
+	}
+	want := map[string]int{
+		models.MovementReceipt:     1,
+		models.MovementAdjustment:  1,
+		models.MovementTransfer:    2,
+		models.MovementReservation: 2,
+		models.MovementCommit:      1,
+		models.MovementRelease:     1,
+	}
+	for movementType, count := range want {
+		if counts[movementType] != count {
+			t.Errorf("%d %s movements, want %d (movements %+v)", counts[movementType], movementType, count, movements)
+		}
+	}
+	if balance != 9 {
+		t.Errorf("movements add up to %d, want 9", balance)
+	}
+
+	reconciliation, err := app.Service.ReconcileStock(product.ID)
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if !reconciliation.Balanced || reconciliation.Stored != 9 || reconciliation.Ledger != 9 {
+		t.Errorf("reconciliation %+v, want 9 stored and in the ledger", reconciliation)
+	}
+
+	// Stock changed behind the ledger's back shows up in its warehouse
+	_, err = db.Exec(`UPDATE stock_levels SET quantity = quantity + 1 WHERE product_id = $1 AND warehouse_id = $2`, product.ID, hanoi.ID)
+	if err != nil {
+		t.Fatalf("failed to change stock: %v", err)
+	}
+	reconciliations, err := app.Service.ReconcileAllStock()
+	if err != nil || len(reconciliations) != 1 {
+		t.Fatalf("reconcile all: %+v, %v", reconciliations, err)
+	}
+	if reconciliations[0].Balanced {
+		t.Errorf("reconciliation %+v is balanced after the stock changed", reconciliations[0])
+	}
+	for _, location := range reconciliations[0].Locations {
+		wantDifference := 0
+		if location.WarehouseID == hanoi.ID {
+			wantDifference = 1
+		}
+		if location.Difference != wantDifference {
+			t.Errorf("warehouse %s differs by %d, want %d", location.WarehouseID, location.Difference, wantDifference)
+		}
+	}
+}
+
+func TestBackordersFillFirstInFirstOut(t *testing.T) {
+	app, _ := newInventoryTest(t)
+	product, err := app.Service.CreateProduct(models.CreateProductRequest{
+		Name:         "Wok",
+		Description:  "Wok",
+		CategoryID:   createCategory(t, app, "Kitchen"),
+		Price:        7,
+		Availability: models.AvailabilityBackorder,
+	})
+	if err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	// Both orders wait for stock, the first one first
+	for _, order := range []struct {
+		id       string
+		quantity int
+	}{{"order-1", 3}, {"order-2", 2}} {
+		reservation := reserve(t, app, order.id, product.ID, order.quantity)
+		if len(reservation.Allocations) != 0 || len(reservation.Waiting) != 1 || reservation.Waiting[0].Quantity != order.quantity {
+			t.Errorf("reservation of %s = %+v, want %d units waiting", order.id, reservation, order.quantity)
+		}
+	}
+
+	// receive sets the stock of the product and returns the units each order
+	// still waits for
+	receive := func(quantity int) map[string]int {
+		t.Helper()
+		if _, err := app.Service.UpdateProduct(product.ID, models.UpdateProductRequest{Quantity: &quantity, Caller: "admin-1"}); err != nil {
+			t.Fatalf("failed to receive stock: %v", err)
+		}
+		backorders, err := app.Service.GetBackorders(product.ID)
+		if err != nil {
+			t.Fatalf("failed to get backorders: %v", err)
+		}
+		waiting := make(map[string]int)
+		for _, backorder := range backorders {
+			waiting[backorder.OrderID] = backorder.Quantity
+		}
+		return waiting
+	}
+
+	// The older backorder takes all it needs before the newer one gets any
+	if waiting := receive(4); len(waiting) != 1 || waiting["order-2"] != 1 {
+		t.Errorf("after receiving 4, waiting %v, want 1 unit of order-2", waiting)
+	}
+	if waiting := receive(3); len(waiting) != 0 {
+		t.Errorf("after receiving 3 more, waiting %v, want nothing", waiting)
+	}
+
+	stock, err := app.Service.GetProductStock(product.ID)
+	if err != nil || stock.Total != 2 {
+		t.Errorf("stock after filling the backorders = %+v, %v, want 2", stock, err)
+	}
+}
+
+// importCatalogue imports a catalogue file and waits for the import to finish
+func TestReserveStockOnce(t *testing.T) {
+	app, _ := newInventoryTest(t)
+	product := createProduct(t, app, createCategory(t, app, "Kitchen"), "Pan", 10)
+
+	var req models.ReserveStockRequest
+	body, _ := json.Marshal(map[string]interface{}{
+		"order_id": "order-1",
+		"items":    []map[string]interface{}{{"product_id": product.ID, "quantity": 3}},
+	})
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("failed to build reservation: %v", err)
+	}
+
+	// Redelivered order events race to reserve the same order
+	var wg sync.WaitGroup
+	reservations := make([]models.Reservation, 5)
+	errs := make([]error, len(reservations))
+	for i := range reservations {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reservations[i], errs[i] = app.Service.ReserveStock(req)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, reservation := range reservations {
+		if errs[i] != nil || !reservation.Available || len(reservation.Allocations) != 1 || reservation.Allocations[0].Quantity != 3 {
+			t.Errorf("reservation %d = %+v, %v, want 3 units reserved", i, reservation, errs[i])
+		}
+	}
+	stock, err := app.Service.GetProductStock(product.ID)
+	if err != nil || stock.Total != 7 {
+		t.Errorf("stock after reserving = %+v, %v, want 7", stock, err)
+	}
+}
+
+func importCatalogue(t *testing.T, app *inventoryapp.App, format, data string, dryRun bool) models.ImportJob {
+	job, err := app.Service.ImportProducts(format, []byte(data), dryRun, "admin-1")
+	if err != nil {
+		t.Fatalf("failed to start import: %v", err)
+	}
+	waitFor(t, "import to finish", func() bool {
+		job, err = app.Service.GetImportJob(job.ID)
+		return err == nil && job.Status != models.ImportStatusRunning
+	})
+	return job
+}
+
+func TestImportValidatesRows(t *testing.T) {
+	app, _ := newInventoryTest(t)
+	kitchen := createCategory(t, app, "Kitchen")
+
+	data := fmt.Sprintf(`sku,parent_sku,name,description,category_id,price,quantity,warehouse,options,option_values
+PAN,,Pan,Non-stick pan,%[1]s,7,5,,,
+PAN,,Pan again,Non-stick pan,%[1]s,7,,,,
+POT,,Pot,,%[1]s,9,,,,
+WOK,,Wok,Carbon steel wok,unknown,9,,,,
+LID,,Lid,Glass lid,%[1]s,3,-1,,,
+KNIFE,,Knife,Chef's knife,%[1]s,12,1,XX,,
+TS,,T-shirt,Cotton,%[1]s,10,,,Size=S|M,
+TS-M,TS,,,,,2,,,Size=M
+TS-XL,TS,,,,,2,,,Size=XL
+CUP-S,CUP,,,,,1,,,Size=S
+,,Nameless,,,,,,,
+`, kitchen)
+	want := map[int]string{
+		3:  "invalid sku: PAN is listed more than once",
+		4:  "invalid row: new products need a name, description, category_id and price",
+		5:  "invalid category",
+		6:  "invalid quantity",
+		7:  "invalid warehouse",
+		10: "XL is not a value of option Size",
+		11: "invalid parent_sku",
+		12: "invalid sku: it is required",
+	}
+
+	job := importCatalogue(t, app, "csv", data, true)
+	if job.Status != models.ImportStatusCompleted || job.TotalRows != 11 || job.Created != 3 || job.Failed != len(want) {
+		t.Errorf("dry run: %s, %d rows, %d created, %d failed, want completed, 11 rows, 3 created, %d failed",
+			job.Status, job.TotalRows, job.Created, job.Failed, len(want))
+	}
+	for _, rowErr := range job.Errors {
+		if !strings.Contains(rowErr.Error, want[rowErr.Line]) || want[rowErr.Line] == "" {
+			t.Errorf("line %d: got %q, want %q", rowErr.Line, rowErr.Error, want[rowErr.Line])
+		}
+	}
+
+	// A dry run writes nothing
+	products, err := app.Service.GetProducts()
+	if err != nil || len(products) != 0 {
+		t.Errorf("products after a dry run: %+v, %v", products, err)
+	}
+
+	// The rows that are valid are imported, the others reported again
+	job = importCatalogue(t, app, "csv", data, false)
+	if job.Created != 3 || job.Failed != len(want) {
+		t.Errorf("import: %d created, %d failed, want 3 created, %d failed", job.Created, job.Failed, len(want))
+	}
+	products, err = app.Service.GetProducts()
+	if err != nil {
+		t.Fatalf("failed to get products: %v", err)
+	}
+	imported := make(map[string]int)
+	for _, product := range products {
+		imported[product.Code] = product.Quantity
+	}
+	if len(imported) != 2 || imported["PAN"] != 5 || imported["TS"] != 2 {
+		t.Errorf("imported products with their stock %v, want PAN with 5 and TS with 2 in its SKU", imported)
+	}
+}
+
+func TestApplyPriceSchedules(t *testing.T) {
+	app, _ := newInventoryTest(t)
+	product := createProduct(t, app, createCategory(t, app, "Kitchen"), "Pan", 1)
+
+	// price returns the price of the product with its regular price on sale
+	price := func() (float64, *float64) {
+		t.Helper()
+		history, err := app.Service.GetPriceHistory(product.ID)
+		if err != nil {
+			t.Fatalf("failed to get price history: %v", err)
+		}
+		return history.Price, history.WasPrice
+	}
+	schedule := func(salePrice float64, startsIn, endsIn time.Duration) models.PriceSchedule {
+		t.Helper()
+		endsAt := time.Now().Add(endsIn)
+		schedule, err := app.Service.SchedulePrice(product.ID, models.CreatePriceScheduleRequest{
+			Price: salePrice, StartsAt: time.Now().Add(startsIn), EndsAt: &endsAt, Caller: "admin-1",
+		})
+		if err != nil {
+			t.Fatalf("failed to schedule price: %v", err)
+		}
+		return schedule
+	}
+
+	sale := schedule(5, 100*time.Millisecond, 300*time.Millisecond)
+	if _, err := app.Service.SchedulePrice(product.ID, models.CreatePriceScheduleRequest{
+		Price: 4, StartsAt: time.Now().Add(200 * time.Millisecond),
+	}); err == nil {
+		t.Error("a price change during the sale was scheduled")
+	}
+
+	// Nothing is due before the sale starts
+	if applied, err := app.Service.ApplyPriceSchedules(); err != nil || applied != 0 {
+		t.Errorf("before the sale: applied %d, %v, want none", applied, err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if applied, err := app.Service.ApplyPriceSchedules(); err != nil || applied != 1 {
+		t.Errorf("sale starting: applied %d, %v, want 1", applied, err)
+	}
+	if got, was := price(); got != 5 || was == nil || *was != 7 {
+		t.Errorf("on sale the price is %v, was %v, want 5 down from 7", got, was)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if applied, err := app.Service.ApplyPriceSchedules(); err != nil || applied != 1 {
+		t.Errorf("sale ending: applied %d, %v, want 1", applied, err)
+	}
+	if got, was := price(); got != 7 || was != nil {
+		t.Errorf("after the sale the price is %v, was %v, want 7", got, was)
+	}
+
+	// A sale that ended before it could start leaves the price alone
+	missed := schedule(3, 10*time.Millisecond, 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if applied, err := app.Service.ApplyPriceSchedules(); err != nil || applied != 1 {
+		t.Errorf("missed sale: applied %d, %v, want 1", applied, err)
+	}
+	if got, _ := price(); got != 7 {
+		t.Errorf("after a missed sale the price is %v, want 7", got)
+	}
+
+	history, err := app.Service.GetPriceHistory(product.ID)
+	if err != nil {
+		t.Fatalf("failed to get price history: %v", err)
+	}
+	var reasons []string
+	for _, change := range history.Changes {
+		reasons = append(reasons, change.Reason)
+	}
+	want := []string{models.PriceChangeCreated, models.PriceChangeScheduled, models.PriceChangeReverted}
+	if strings.Join(reasons, " ") != strings.Join(want, " ") || len(history.Schedules) != 0 {
+		t.Errorf("price changes %v with schedules %+v, want %v and none left", reasons, history.Schedules, want)
+	}
+	for _, id := range []string{sale.ID, missed.ID} {
+		if _, err := app.Service.CancelPriceSchedule(product.ID, id, "admin-1"); err == nil {
+			t.Errorf("schedule %s was cancelled after it completed", id)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite"
+
+	cartapp "github.com/online-order-system/cart-service/app"
+	cartconfig "github.com/online-order-system/cart-service/config"
+	inventoryapp "github.com/online-order-system/inventory-service/app"
+	inventoryconfig "github.com/online-order-system/inventory-service/config"
+	notificationapp "github.com/online-order-system/notification-service/app"
+	notificationconfig "github.com/online-order-system/notification-service/config"
+	orderapp "github.com/online-order-system/order-service/app"
+	orderconfig "github.com/online-order-system/order-service/config"
+	"github.com/online-order-system/order-service/eventbus"
+	paymentapp "github.com/online-order-system/payment-service/app"
+	paymentconfig "github.com/online-order-system/payment-service/config"
+	shippingapp "github.com/online-order-system/shipping-service/app"
+	shippingconfig "github.com/online-order-system/shipping-service/config"
+	userapp "github.com/online-order-system/user-service/app"
+	userconfig "github.com/online-order-system/user-service/config"
+)
+
+// Default ports, matching the ones used by docker-compose
+const (
+	orderPort        = "8081"
+	inventoryPort    = "8082"
+	paymentPort      = "8083"
+	shippingPort     = "8084"
+	notificationPort = "8085"
+	userPort         = "8086"
+	cartPort         = "8087"
+)
+
+// sqliteDriver is the database/sql driver name registered by modernc.org/sqlite
+const sqliteDriver = "sqlite"
+
+// component is the part of a service application the launcher drives
+type component interface {
+	Start(ctx context.Context)
+	Close() error
+}
+
+// server is a running service together with its HTTP listener
+type server struct {
+	name string
+	app  component
+	srv  *http.Server
+}
+
+func main() {
+	dataDir := getEnv("DATA_DIR", "./data")
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+
+	// All services share one in-process event bus
+	bus := eventbus.NewMemoryBus()
+	defer bus.Close()
+
+	servers, err := buildServers(dataDir, bus)
+	for _, s := range servers {
+		defer s.app.Close()
+	}
+	if err != nil {
+		log.Fatalf("Failed to create application: %v", err)
+	}
+
+	// Start consumers and HTTP servers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, s := range servers {
+		s.app.Start(ctx)
+
+		go func(s *server) {
+			log.Printf("Starting %s on %s", s.name, s.srv.Addr)
+			if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start %s: %v", s.name, err)
+			}
+		}(s)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown the servers
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down servers...")
+
+	// Stop consumers before the servers so no new events are processed
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	for _, s := range servers {
+		if err := s.srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down %s: %v", s.name, err)
+		}
+	}
+
+	log.Println("Servers exited")
+}
+
+// buildServers creates every service on top of bus with its own SQLite
+// database in dataDir. On error it returns the services created so far so the
+// caller can close them.
+func buildServers(dataDir string, bus eventbus.Bus) ([]*server, error) {
+	var servers []*server
+	add := func(name, port string, app component, router *gin.Engine) {
+		servers = append(servers, &server{
+			name: name,
+			app:  app,
+			srv:  &http.Server{Addr: ":" + port, Handler: router},
+		})
+	}
+
+	// Every other service verifies tokens with the keys of user-service
+	jwksURL := localURL(userPort) + "/.well-known/jwks.json"
+
+	// Services calling others get service tokens from user-service with a
+	// secret generated for this process
+	serviceTokenURL := localURL(userPort) + "/auth/service-token"
+	serviceSecrets := map[string]string{}
+	for _, name := range []string{"order-service", "payment-service", "shipping-service", "notification-service"} {
+		secret, err := newServiceSecret()
+		if err != nil {
+			return servers, fmt.Errorf("%s: %v", name, err)
+		}
+		serviceSecrets[name] = secret
+	}
+
+	// User service
+	userCfg := userconfig.LoadConfig()
+	userCfg.ServerPort = userPort
+	userCfg.DBDriver, userCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "user")
+	userCfg.EventBus = eventbus.DriverMemory
+	userCfg.OrderServiceURL = localURL(orderPort)
+	userCfg.PaymentServiceURL = localURL(paymentPort)
+	userCfg.ShippingServiceURL = localURL(shippingPort)
+	userCfg.NotificationServiceURL = localURL(notificationPort)
+	userCfg.CartServiceURL = localURL(cartPort)
+	userCfg.InventoryServiceURL = localURL(inventoryPort)
+	userCfg.ServiceCredentials = serviceSecrets
+	user, err := userapp.New(userCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("user-service: %v", err)
+	}
+	add("user-service", userPort, user, user.Router)
+
+	// Cart service
+	cartCfg := cartconfig.LoadConfig()
+	cartCfg.ServerPort = cartPort
+	cartCfg.DBDriver, cartCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "cart")
+	cartCfg.EventBus = eventbus.DriverMemory
+	cartCfg.JWKSURL = jwksURL
+	cart, err := cartapp.New(cartCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("cart-service: %v", err)
+	}
+	add("cart-service", cartPort, cart, cart.Router)
+
+	// Inventory service
+	inventoryCfg := inventoryconfig.LoadConfig()
+	inventoryCfg.ServerPort = inventoryPort
+	inventoryCfg.DBDriver, inventoryCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "inventory")
+	inventoryCfg.EventBus = eventbus.DriverMemory
+	inventoryCfg.JWKSURL = jwksURL
+	inventory, err := inventoryapp.New(inventoryCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("inventory-service: %v", err)
+	}
+	add("inventory-service", inventoryPort, inventory, inventory.Router)
+
+	// Payment service
+	paymentCfg := paymentconfig.LoadConfig()
+	paymentCfg.ServerPort = paymentPort
+	paymentCfg.DBDriver, paymentCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "payment")
+	paymentCfg.EventBus = eventbus.DriverMemory
+	paymentCfg.JWKSURL = jwksURL
+	paymentCfg.UserServiceURL = localURL(userPort)
+	paymentCfg.OrderServiceURL = localURL(orderPort)
+	paymentCfg.ServiceSecret = serviceSecrets[paymentCfg.ServiceName]
+	paymentCfg.ServiceTokenURL = serviceTokenURL
+	payment, err := paymentapp.New(paymentCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("payment-service: %v", err)
+	}
+	add("payment-service", paymentPort, payment, payment.Router)
+
+	// Shipping service
+	shippingCfg := shippingconfig.LoadConfig()
+	shippingCfg.ServerPort = shippingPort
+	shippingCfg.DBDriver, shippingCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "shipping")
+	shippingCfg.EventBus = eventbus.DriverMemory
+	shippingCfg.JWKSURL = jwksURL
+	shippingCfg.OrderServiceURL = localURL(orderPort)
+	shippingCfg.ServiceSecret = serviceSecrets[shippingCfg.ServiceName]
+	shippingCfg.ServiceTokenURL = serviceTokenURL
+	shipping, err := shippingapp.New(shippingCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("shipping-service: %v", err)
+	}
+	add("shipping-service", shippingPort, shipping, shipping.Router)
+
+	// Notification service
+	notificationCfg := notificationconfig.LoadConfig()
+	notificationCfg.ServerPort = notificationPort
+	notificationCfg.DBDriver, notificationCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "notification")
+	notificationCfg.EventBus = eventbus.DriverMemory
+	notificationCfg.JWKSURL = jwksURL
+	notificationCfg.OrderServiceURL = localURL(orderPort)
+	notificationCfg.UserServiceURL = localURL(userPort)
+	notificationCfg.ServiceSecret = serviceSecrets[notificationCfg.ServiceName]
+	notificationCfg.ServiceTokenURL = serviceTokenURL
+	notification, err := notificationapp.New(notificationCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("notification-service: %v", err)
+	}
+	add("notification-service", notificationPort, notification, notification.Router)
+
+	// Order service
+	orderCfg := orderconfig.LoadConfig()
+	orderCfg.ServerPort = orderPort
+	orderCfg.DBDriver, orderCfg.DBDSN = sqliteDriver, sqliteDSN(dataDir, "order")
+	orderCfg.EventBus = eventbus.DriverMemory
+	orderCfg.JWKSURL = jwksURL
+	orderCfg.InventoryServiceURL = localURL(inventoryPort)
+	orderCfg.RecommendationServiceURL = localURL(inventoryPort)
+	orderCfg.PaymentServiceURL = localURL(paymentPort)
+	orderCfg.ShippingServiceURL = localURL(shippingPort)
+	orderCfg.NotificationServiceURL = localURL(notificationPort)
+	orderCfg.UserServiceURL = localURL(userPort)
+	orderCfg.CartServiceURL = localURL(cartPort)
+	orderCfg.ServiceSecret = serviceSecrets[orderCfg.ServiceName]
+	orderCfg.ServiceTokenURL = serviceTokenURL
+	order, err := orderapp.New(orderCfg, bus)
+	if err != nil {
+		return servers, fmt.Errorf("order-service: %v", err)
+	}
+	add("order-service", orderPort, order, order.Router)
+
+	return servers, nil
+}
+
+// sqliteDSN returns the data source name of the SQLite database for a service.
+// WAL and a busy timeout let the connection pool write concurrently.
+func sqliteDSN(dataDir, name string) string {
+	path := filepath.Join(dataDir, name+".db")
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+}
+
+// newServiceSecret returns a random secret a service gets service tokens with
+func newServiceSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// localURL returns the URL of a service listening on port in this process
+func localURL(port string) string {
+	return "http://localhost:" + port
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	userapp "github.com/online-order-system/user-service/app"
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/models"
+)
+
+// totpCode returns the code of a secret for the step offset steps from now
+func totpCode(t *testing.T, secret string, offset int64) string {
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("failed to compute TOTP code: %v", err)
+	}
+	return code
+}
+
+// mfaLogin logs in with the password and returns the MFA token of the challenge
+func mfaLogin(t *testing.T, app *userapp.App, email string) string {
+	resp, err := app.Service.Login(models.LoginRequest{Email: email, Password: "Passw0rd!23"}, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	if !resp.MFARequired || resp.MFAToken == "" {
+		t.Fatalf("login didn't ask for MFA: %+v", resp)
+	}
+	return resp.MFAToken
+}
+
+func TestMFACodesWorkOnce(t *testing.T) {
+	app, _ := newUserTest(t)
+	userID := register(t, app, "cu@example.com")
+
+	enrollment, err := app.Service.EnrollMFA(userID)
+	if err != nil {
+		t.Fatalf("failed to enroll: %v", err)
+	}
+	confirmed := totpCode(t, enrollment.Secret, 0)
+	if _, err := app.Service.ConfirmMFA(userID, confirmed); err != nil {
+		t.Fatalf("failed to confirm MFA: %v", err)
+	}
+
+	verify := func(code string) error {
+		_, err := app.Service.VerifyMFA(models.MFAVerifyRequest{
+			MFAToken: mfaLogin(t, app, "cu@example.com"),
+			Code:     code,
+		}, models.ClientInfo{})
+		return err
+	}
+
+	// The code that confirmed enrollment can't log in, and neither can an
+	// older one that is still inside the window
+	if err := verify(confirmed); err != models.ErrInvalidMFACode {
+		t.Errorf("code used to confirm MFA: got %v, want ErrInvalidMFACode", err)
+	}
+	if err := verify(totpCode(t, enrollment.Secret, -1)); err != models.ErrInvalidMFACode {
+		t.Errorf("code older than the last one used: got %v, want ErrInvalidMFACode", err)
+	}
+
+	// The next code logs in once
+	next := totpCode(t, enrollment.Secret, 1)
+	if err := verify(next); err != nil {
+		t.Fatalf("next code: %v", err)
+	}
+	if err := verify(next); err != models.ErrInvalidMFACode {
+		t.Errorf("code used twice: got %v, want ErrInvalidMFACode", err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	orderapp "github.com/online-order-system/order-service/app"
+	orderconfig "github.com/online-order-system/order-service/config"
+	"github.com/online-order-system/order-service/eventbus"
+	"github.com/online-order-system/order-service/models"
+)
+
+// downstreams stands in for the services order-service calls and records
+// the requests it gets
+type downstreams struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// newDownstreams starts the stand-in services. Products cost 7.
+func newDownstreams(t *testing.T) *downstreams {
+	d := &downstreams{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.calls = append(d.calls, r.Method+" "+r.URL.Path)
+		d.mu.Unlock()
+
+		if r.URL.Path != "/auth/service-token" && r.Header.Get("Authorization") != "Bearer service-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var body interface{} = map[string]string{}
+		switch {
+		case r.URL.Path == "/auth/service-token":
+			body = map[string]interface{}{"token": "service-token", "token_type": "Bearer", "expires_in": 900}
+		case strings.HasSuffix(r.URL.Path, "/contact"):
+			body = models.CustomerContact{ID: "customer-1", Email: "cu@example.com", Name: "Cu St", Locale: "vi"}
+		case r.URL.Path == "/users/verify":
+			body = map[string]interface{}{
+				"verified": true,
+				"address":  models.Address{Recipient: "Cu St", Line1: "1 Le Loi", City: "HCM", Country: "VN"},
+			}
+		case strings.HasPrefix(r.URL.Path, "/products/"):
+			body = models.Product{ID: strings.TrimPrefix(r.URL.Path, "/products/"), Name: "Pan", Price: 7}
+		case r.URL.Path == "/inventory/reserve":
+			body = models.InventoryReservation{
+				Available:   true,
+				WarehouseID: "warehouse-1",
+				Allocations: []models.StockAllocation{{ProductID: "product-1", WarehouseID: "warehouse-1", Quantity: 3}},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+// called reports whether a request was made to the given method and path
+func (d *downstreams) called(call string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range d.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+// sagaTest is order-service running on an in-memory bus against stand-in
+// services
+type sagaTest struct {
+	app   *orderapp.App
+	bus   *eventbus.MemoryBus
+	stubs *downstreams
+
+	mu     sync.Mutex
+	events map[string][]string // Event types on the orders topic by order
+}
+
+// newSagaTest starts order-service with a payment-service that answers
+// order_created with the given payment event type
+func newSagaTest(t *testing.T, paymentEvent string) *sagaTest {
+	stubs := newDownstreams(t)
+	bus := eventbus.NewMemoryBus()
+
+	cfg := orderconfig.LoadConfig()
+	cfg.DBDriver, cfg.DBDSN = sqliteDriver, sqliteDSN(t.TempDir(), "order")
+	cfg.EventBus = eventbus.DriverMemory
+	cfg.JWKSURL = stubs.URL + "/.well-known/jwks.json"
+	cfg.ServiceSecret = "secret"
+	cfg.ServiceTokenURL = stubs.URL + "/auth/service-token"
+	cfg.InventoryServiceURL = stubs.URL
+	cfg.RecommendationServiceURL = stubs.URL
+	cfg.PaymentServiceURL = stubs.URL
+	cfg.ShippingServiceURL = stubs.URL
+	cfg.NotificationServiceURL = stubs.URL
+	cfg.UserServiceURL = stubs.URL
+	cfg.CartServiceURL = stubs.URL
+
+	app, err := orderapp.New(cfg, bus)
+	if err != nil {
+		t.Fatalf("failed to create order-service: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		bus.Close()
+		app.Close()
+	})
+	app.Start(ctx)
+
+	s := &sagaTest{app: app, bus: bus, stubs: stubs, events: make(map[string][]string)}
+
+	// Payment-service pays for every order created
+	bus.Subscribe(ctx, cfg.KafkaTopic, "payment-service", func(ctx context.Context, key, value []byte) error {
+		var event models.OrderEvent
+		if err := json.Unmarshal(value, &event); err != nil || event.EventType != "order_created" {
+			return err
+		}
+		payment, _ := json.Marshal(map[string]interface{}{
+			"event_type": paymentEvent,
+			"order_id":   event.OrderID,
+			"amount":     event.TotalAmount,
+		})
+		return bus.Publish(ctx, "payments", key, payment)
+	})
+
+	// Record the events order-service publishes
+	bus.Subscribe(ctx, cfg.KafkaTopic, "saga-test", func(ctx context.Context, key, value []byte) error {
+		var event models.OrderEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.events[event.OrderID] = append(s.events[event.OrderID], event.EventType)
+		s.mu.Unlock()
+		return nil
+	})
+
+	return s
+}
+
+// published reports whether an event of the given type was published for an
+// order
+func (s *sagaTest) published(orderID, eventType string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.events[orderID] {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// createOrder places an order for three pans the client claims cost 0.01
+func (s *sagaTest) createOrder(t *testing.T) models.Order {
+	order, err := s.app.Service.CreateOrder(models.CreateOrderRequest{
+		CustomerID: "customer-1",
+		Items:      []models.OrderItem{{ProductID: "product-1", Quantity: 3, Price: 0.01}},
+		Caller:     "customer-1",
+	})
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	return order
+}
+
+// waitFor fails the test when cond doesn't hold within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// status returns the status of an order
+func (s *sagaTest) status(t *testing.T, orderID string) models.OrderStatus {
+	order, err := s.app.Service.GetOrderByID(orderID)
+	if err != nil {
+		t.Fatalf("failed to get order %s: %v", orderID, err)
+	}
+	return order.Status
+}
+
+func TestOrderSagaCompletes(t *testing.T) {
+	s := newSagaTest(t, "payment_successful")
+
+	order := s.createOrder(t)
+	if order.TotalAmount != 21 || order.Items[0].Price != 7 {
+		t.Errorf("order priced at %.2f with unit price %.2f, want 21 and 7 from inventory-service", order.TotalAmount, order.Items[0].Price)
+	}
+	if !order.InventoryLocked || order.WarehouseID != "warehouse-1" {
+		t.Errorf("inventory locked %t in %q, want it reserved in warehouse-1", order.InventoryLocked, order.WarehouseID)
+	}
+	if !s.stubs.called("POST /carts/customer-1/clear") {
+		t.Error("cart was not cleared")
+	}
+
+	// Payment confirms the order, which ships
+	waitFor(t, "order to be confirmed", func() bool { return s.status(t, order.ID) == models.OrderStatusConfirmed })
+	waitFor(t, "order_confirmed", func() bool { return s.published(order.ID, "order_confirmed") })
+	if !s.stubs.called("POST /shipments") {
+		t.Error("shipping was not scheduled")
+	}
+
+	// Delivery completes the order
+	delivered, _ := json.Marshal(map[string]string{"event_type": "shipping_completed", "order_id": order.ID})
+	if err := s.bus.Publish(context.Background(), "shipments", []byte(order.ID), delivered); err != nil {
+		t.Fatalf("failed to publish shipping_completed: %v", err)
+	}
+	waitFor(t, "order to be delivered", func() bool { return s.status(t, order.ID) == models.OrderStatusDelivered })
+	waitFor(t, "order_completed", func() bool { return s.published(order.ID, "order_completed") })
+
+	if s.stubs.called("POST /inventory/restore") {
+		t.Error("inventory was restored for an order that completed")
+	}
+}
+
+func TestOrderSagaCompensatesFailedPayment(t *testing.T) {
+	s := newSagaTest(t, "payment_failed")
+
+	order := s.createOrder(t)
+
+	// Payment fails, so the order fails and gives its stock back
+	waitFor(t, "order_cancelled", func() bool { return s.published(order.ID, "order_cancelled") })
+	failed, err := s.app.Service.GetOrderByID(order.ID)
+	if err != nil {
+		t.Fatalf("failed to get order: %v", err)
+	}
+	if failed.Status != models.OrderStatusFailed || failed.FailureReason != "payment_failed" {
+		t.Errorf("order %s failed with %q, want %s failed with payment_failed", failed.Status, failed.FailureReason, models.OrderStatusFailed)
+	}
+	if !s.stubs.called("POST /inventory/restore") {
+		t.Error("inventory was not restored")
+	}
+	if !s.stubs.called("POST /notifications") {
+		t.Error("customer was not notified")
+	}
+	if s.stubs.called("POST /shipments") {
+		t.Error("shipping was scheduled for a failed order")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	userapp "github.com/online-order-system/user-service/app"
+	"github.com/online-order-system/user-service/auth"
+	userconfig "github.com/online-order-system/user-service/config"
+	"github.com/online-order-system/user-service/eventbus"
+	"github.com/online-order-system/user-service/models"
+)
+
+// oldPasswordHash is "Passw0rd!23" hashed with the argon2id parameters used
+// before they were raised (m=19456, t=2, p=1)
+const oldPasswordHash = "$argon2id$v=19$m=19456,t=2,p=1$MDEyMzQ1Njc4OWFiY2RlZg$xIx7yDKRdsqYHDOkjlQLPa89JTSbQImSx/nuOozSj+Y"
+
+// newUserTest starts user-service on an in-memory bus and returns it with the
+// path of its database
+func newUserTest(t *testing.T) (*userapp.App, string) {
+	dir := t.TempDir()
+	bus := eventbus.NewMemoryBus()
+
+	cfg := userconfig.LoadConfig()
+	cfg.DBDriver, cfg.DBDSN = sqliteDriver, sqliteDSN(dir, "user")
+	cfg.EventBus = eventbus.DriverMemory
+
+	app, err := userapp.New(cfg, bus)
+	if err != nil {
+		t.Fatalf("failed to create user-service: %v", err)
+	}
+	t.Cleanup(func() {
+		bus.Close()
+		app.Close()
+	})
+	return app, cfg.DBDSN
+}
+
+// register creates a customer account and returns its ID
+func register(t *testing.T, app *userapp.App, email string) string {
+	resp, err := app.Service.Register(models.RegisterRequest{
+		Email:     email,
+		Password:  "Passw0rd!23",
+		FirstName: "Cu",
+		LastName:  "St",
+	}, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	return resp.User.ID
+}
+
+func TestLoginUpgradesOldPasswordHash(t *testing.T) {
+	app, dsn := newUserTest(t)
+	userID := register(t, app, "cu@example.com")
+
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`UPDATE user_credentials SET password_hash = $1 WHERE user_id = $2`, oldPasswordHash, userID)
+	if err != nil {
+		t.Fatalf("failed to store old hash: %v", err)
+	}
+
+	resp, err := app.Service.Login(models.LoginRequest{Email: "cu@example.com", Password: "Passw0rd!23"}, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("login with old hash: %v", err)
+	}
+	if resp.Token == "" {
+		t.Errorf("login with old hash got no token: %+v", resp)
+	}
+
+	var hash string
+	err = db.QueryRow(`SELECT password_hash FROM user_credentials WHERE user_id = $1`, userID).Scan(&hash)
+	if err != nil {
+		t.Fatalf("failed to read hash: %v", err)
+	}
+	if hash == oldPasswordHash || auth.NeedsRehash(hash) || !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("hash after login is %s, want one with the current parameters", hash)
+	}
+	if ok, err := auth.VerifyPassword(hash, "Passw0rd!23"); err != nil || !ok {
+		t.Errorf("upgraded hash doesn't match the password: %t, %v", ok, err)
+	}
+
+	// A wrong password doesn't touch the hash
+	if _, err := app.Service.Login(models.LoginRequest{Email: "cu@example.com", Password: "Wrong-passw0rd"}, models.ClientInfo{}); err != models.ErrInvalidCredentials {
+		t.Errorf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -4,10 +4,14 @@ import (
 "net/http"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/cart-service/auth"
 "github.com/online-order-system/cart-service/interfaces"
 "github.com/online-order-system/cart-service/models"
 )
 
+// staffRoles may act on the carts of every customer
+var staffRoles = []string{auth.RoleSupport, auth.RoleAdmin}
+
 // Handlers handles HTTP requests
 type Handlers struct {
 service interfaces.CartService
@@ -28,6 +32,11 @@ c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 return
 }
 
+if !auth.CanAccess(c, req.CustomerID, staffRoles...) {
+c.JSON(http.StatusForbidden, gin.H{"error": "Cannot create a cart for another customer"})
+return
+}
+
 cart, err := h.service.CreateCart(req)
 if err != nil {
 c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -45,6 +54,10 @@ if err != nil {
 c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 return
 }
+if !auth.CanAccess(c, cart.CustomerID, staffRoles...) {
+c.JSON(http.StatusNotFound, gin.H{"error": "cart not found"})
+return
+}
 
 c.JSON(http.StatusOK, cart)
 }
@@ -52,6 +65,11 @@ c.JSON(http.StatusOK, cart)
 // GetCartByUserID handles cart retrieval by user ID requests
 func (h *Handlers) GetCartByUserID(c *gin.Context) {
 userID := c.Param("user_id")
+if !auth.CanAccess(c, userID, staffRoles...) {
+c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access the cart of another user"})
+return
+}
+
 cart, err := h.service.GetCartByUserID(userID)
 if err != nil {
 c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -64,6 +82,9 @@ c.JSON(http.StatusOK, cart)
 // AddCartItem handles adding an item to a cart
 func (h *Handlers) AddCartItem(c *gin.Context) {
 id := c.Param("id")
+if !h.ownsCart(c, id) {
+return
+}
 var req models.AddCartItemRequest
 if err := c.ShouldBindJSON(&req); err != nil {
 c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -82,6 +103,9 @@ c.JSON(http.StatusOK, cart)
 // UpdateCartItem handles updating an item in a cart
 func (h *Handlers) UpdateCartItem(c *gin.Context) {
 id := c.Param("id")
+if !h.ownsCart(c, id) {
+return
+}
 itemID := c.Param("item_id")
 var req models.UpdateCartItemRequest
 if err := c.ShouldBindJSON(&req); err != nil {
@@ -101,6 +125,9 @@ c.JSON(http.StatusOK, cart)
 // RemoveCartItem handles removing an item from a cart
 func (h *Handlers) RemoveCartItem(c *gin.Context) {
 id := c.Param("id")
+if !h.ownsCart(c, id) {
+return
+}
 itemID := c.Param("item_id")
 
 cart, err := h.service.RemoveCartItem(id, itemID)
@@ -115,6 +142,9 @@ c.JSON(http.StatusOK, cart)
 // DeleteCart handles cart deletion requests
 func (h *Handlers) DeleteCart(c *gin.Context) {
 id := c.Param("id")
+if !h.ownsCart(c, id) {
+return
+}
 
 err := h.service.DeleteCart(id)
 if err != nil {
@@ -124,3 +154,26 @@ return
 
 c.JSON(http.StatusOK, gin.H{"message": "Cart deleted successfully"})
 }
+
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export
+func (h *Handlers) GetCustomerData(c *gin.Context) {
+data, err := h.service.GetCustomerData(c.Param("id"))
+if err != nil {
+c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+return
+}
+
+c.JSON(http.StatusOK, data)
+}
+
+// ownsCart checks that the caller may change a cart, writing the error
+// response if not
+func (h *Handlers) ownsCart(c *gin.Context, id string) bool {
+cart, err := h.service.GetCartByID(id)
+if err != nil || !auth.CanAccess(c, cart.CustomerID, staffRoles...) {
+c.JSON(http.StatusNotFound, gin.H{"error": "cart not found"})
+return false
+}
+return true
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/cart-service/auth"
 )
 
 // SetupMiddleware sets up middleware for the router
@@ -34,14 +35,16 @@ func SetupMiddleware(router *gin.Engine) {
 		// Process request
 		c.Next()
 
-		// Log request
+		// Log request with the authenticated caller, so calls between services
+		// can be traced to the service that made them
 		latency := time.Since(start)
-		log.Printf("[%s] %s %s %d %s",
+		log.Printf("[%s] %s %s %d %s %s",
 			c.Request.Method,
 			c.Request.URL.Path,
 			c.ClientIP(),
 			c.Writer.Status(),
 			latency,
+			auth.Caller(c),
 		)
 	})
 }
@@ -2,16 +2,18 @@ package api
 
 import (
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/cart-service/auth"
 "github.com/online-order-system/cart-service/interfaces"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.CartService) *gin.Engine {
+func SetupRouter(service interfaces.CartService, verifier *auth.Verifier) *gin.Engine {
 // Create router
 router := gin.Default()
 
 // Add middleware
 SetupMiddleware(router)
+router.Use(auth.Authenticate(verifier))
 
 // Create handlers
 handlers := NewHandlers(service)
@@ -27,8 +29,11 @@ c.JSON(200, gin.H{
 router.StaticFile("/swagger", "./docs/swagger.html")
 router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+// Who may call the routes. The handlers check that the caller owns the cart.
+everyone := auth.RequireRole(auth.Everyone...)
+
 // Cart routes
-carts := router.Group("/carts")
+carts := router.Group("/carts", everyone)
 {
 carts.POST("", handlers.CreateCart)
 carts.GET("/:id", handlers.GetCart)
@@ -39,5 +44,8 @@ carts.DELETE("/:id/items/:item_id", handlers.RemoveCartItem)
 carts.DELETE("/:id", handlers.DeleteCart)
 }
 
+// Personal data of a customer, gathered by user-service for data exports
+router.GET("/customers/:id/data", auth.RequireRole(auth.Internal), handlers.GetCustomerData)
+
 return router
 }
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claimsKey is the gin context key the verified claims are stored under
+const claimsKey = "auth.claims"
+
+// Authenticate verifies the bearer token of a request if one is present and
+// stores its claims in the context. Requests without a token pass through
+// anonymously; requests with an invalid token are rejected.
+func Authenticate(v *Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		claims, err := verify(c, v, token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests that do not carry a valid bearer token
+func RequireAuth(v *Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := ClaimsFromContext(c); ok {
+			c.Next()
+			return
+		}
+
+		token, ok := bearerToken(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		claims, err := verify(c, v, token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// verify checks the token of a request. Service tokens are only accepted on
+// requests from inside the network.
+func verify(c *gin.Context, v *Verifier, token string) (*Claims, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.IsService() && c.GetHeader(GatewayHeader) != "" {
+		return nil, fmt.Errorf("%w: service token used from outside", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// ClaimsFromContext returns the claims of the authenticated caller, if any
+func ClaimsFromContext(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	return token, token != ""
+}
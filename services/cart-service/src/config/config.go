@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,8 +11,13 @@ import (
 type Config struct {
 	// Server configuration
 	ServerPort string
+	// Addresses of the proxies, the gateway, trusted to say which client a
+	// request came from. Clients are identified by their own address otherwise.
+	TrustedProxies []string
 
 	// Database configuration
+	DBDriver   string
+	DBDSN      string
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -21,6 +27,13 @@ type Config struct {
 	// Kafka configuration
 	KafkaBootstrapServers string
 	KafkaTopic            string
+	KafkaPrivacyTopic     string
+	EventBus              string
+
+	// JWT configuration
+	JWTIssuer   string
+	JWTAudience string
+	JWKSURL     string
 
 	// Redis configuration
 	RedisHost     string
@@ -34,9 +47,12 @@ type Config struct {
 func LoadConfig() *Config {
 	return &Config{
 		// Server configuration
-		ServerPort: getEnv("PORT", "8087"),
+		ServerPort:     getEnv("PORT", "8087"),
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 		// Database configuration
+		DBDriver:   getEnv("DB_DRIVER", "postgres"),
+		DBDSN:      getEnv("DB_DSN", ""),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "postgres"),
@@ -46,6 +62,13 @@ func LoadConfig() *Config {
 		// Kafka configuration
 		KafkaBootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:9092"),
 		KafkaTopic:            getEnv("KAFKA_TOPIC", "orders"),
+		KafkaPrivacyTopic:     getEnv("KAFKA_PRIVACY_TOPIC", "privacy"),
+		EventBus:              getEnv("EVENT_BUS", "kafka"),
+
+		// JWT configuration
+		JWTIssuer:   getEnv("JWT_ISSUER", "user-service"),
+		JWTAudience: getEnv("JWT_AUDIENCE", "online-order-system"),
+		JWKSURL:     getEnv("JWKS_URL", "http://user-service:8086/.well-known/jwks.json"),
 
 		// Redis configuration
 		RedisHost:     getEnv("REDIS_HOST", "localhost"),
@@ -73,3 +96,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsList gets a comma separated environment variable as a list
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
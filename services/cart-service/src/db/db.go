@@ -10,19 +10,29 @@ import (
 	"github.com/online-order-system/cart-service/config"
 )
 
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 	*sql.DB
+	Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 	// Create connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	connStr := cfg.DBDSN
+	if connStr == "" {
+		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open(cfg.DBDriver, connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -38,9 +48,9 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	log.Println("Connected to PostgreSQL database")
+	log.Printf("Connected to %s database", cfg.DBDriver)
 
-	return &Database{db}, nil
+	return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -64,6 +74,7 @@ func (db *Database) CreateTables() error {
 		id VARCHAR(36) PRIMARY KEY,
 		cart_id VARCHAR(36) NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
 		product_id VARCHAR(36) NOT NULL,
+		sku_id VARCHAR(36) NOT NULL DEFAULT '',
 		quantity INTEGER NOT NULL,
 		price DECIMAL(10, 2) NOT NULL,
 		created_at TIMESTAMP NOT NULL,
@@ -73,6 +84,19 @@ func (db *Database) CreateTables() error {
 	if err != nil {
 		return err
 	}
+	_, _ = db.Exec(`ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS sku_id VARCHAR(36) NOT NULL DEFAULT ''`)
+
+	// Create discontinued_products table, the products carts warn about
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS discontinued_products (
+		product_id VARCHAR(36) PRIMARY KEY,
+		status VARCHAR(20) NOT NULL,
+		discontinued_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
 
 	log.Println("Database tables created or already exist")
 	return nil
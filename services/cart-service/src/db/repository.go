@@ -3,6 +3,7 @@ package db
 import (
 "database/sql"
 "errors"
+"fmt"
 "time"
 
 "github.com/online-order-system/cart-service/models"
@@ -80,11 +81,50 @@ return models.Cart{}, err
 return cart, nil
 }
 
+// GetCartsByUserID retrieves the carts of a user with their items
+func (r *CartRepository) GetCartsByUserID(userID string) ([]models.Cart, error) {
+rows, err := r.db.Query(
+`SELECT id, user_id, created_at, updated_at
+FROM carts WHERE user_id = $1 ORDER BY created_at`,
+userID,
+)
+if err != nil {
+return nil, err
+}
+defer rows.Close()
+
+carts := []models.Cart{}
+for rows.Next() {
+var cart models.Cart
+err := rows.Scan(&cart.ID, &cart.CustomerID, &cart.CreatedAt, &cart.UpdatedAt)
+if err != nil {
+return nil, err
+}
+carts = append(carts, cart)
+}
+if err := rows.Err(); err != nil {
+return nil, err
+}
+
+// Get cart items once the rows are closed
+for i := range carts {
+carts[i].Items, err = r.GetCartItems(carts[i].ID)
+if err != nil {
+return nil, err
+}
+}
+
+return carts, nil
+}
+
 // GetCartItems retrieves all items for a cart
 func (r *CartRepository) GetCartItems(cartID string) ([]models.CartItem, error) {
 rows, err := r.db.Query(
-`SELECT id, cart_id, product_id, quantity, price, created_at, updated_at
-FROM cart_items WHERE cart_id = $1 ORDER BY created_at ASC`,
+`SELECT i.id, i.cart_id, i.product_id, i.sku_id, i.quantity, i.price, i.created_at, i.updated_at,
+COALESCE(d.status, '')
+FROM cart_items i
+LEFT JOIN discontinued_products d ON d.product_id = i.product_id
+WHERE i.cart_id = $1 ORDER BY i.created_at ASC`,
 cartID,
 )
 if err != nil {
@@ -95,13 +135,17 @@ defer rows.Close()
 var items []models.CartItem
 for rows.Next() {
 var item models.CartItem
+var status string
 err := rows.Scan(
-&item.ID, &item.CartID, &item.ProductID, &item.Quantity, &item.Price,
-&item.CreatedAt, &item.UpdatedAt,
+&item.ID, &item.CartID, &item.ProductID, &item.SKUID, &item.Quantity, &item.Price,
+&item.CreatedAt, &item.UpdatedAt, &status,
 )
 if err != nil {
 return nil, err
 }
+if status != "" {
+item.Warning = fmt.Sprintf("This product is %s and can no longer be ordered", status)
+}
 items = append(items, item)
 }
 return items, nil
@@ -109,11 +153,11 @@ return items, nil
 
 // AddCartItem adds an item to a cart
 func (r *CartRepository) AddCartItem(item models.CartItem) error {
-// Check if item already exists
+// Check if item already exists, each SKU of a product is an item of its own
 var existingItemID string
 err := r.db.QueryRow(
-`SELECT id FROM cart_items WHERE cart_id = $1 AND product_id = $2`,
-item.CartID, item.ProductID,
+`SELECT id FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND sku_id = $3`,
+item.CartID, item.ProductID, item.SKUID,
 ).Scan(&existingItemID)
 
 if err == nil {
@@ -131,9 +175,9 @@ return err
 
 // Item doesn't exist, insert new item
 _, err = r.db.Exec(
-`INSERT INTO cart_items (id, cart_id, product_id, quantity, price, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-item.ID, item.CartID, item.ProductID, item.Quantity, item.Price,
+`INSERT INTO cart_items (id, cart_id, product_id, sku_id, quantity, price, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+item.ID, item.CartID, item.ProductID, item.SKUID, item.Quantity, item.Price,
 item.CreatedAt, item.UpdatedAt,
 )
 return err
@@ -203,6 +247,81 @@ return err
 return r.DeleteCart(cartID)
 }
 
+// EraseCustomer deletes the carts of a user and all their items. It returns
+// the number of carts and items deleted.
+func (r *CartRepository) EraseCustomer(userID string) (int, error) {
+// Start a transaction
+tx, err := r.db.Begin()
+if err != nil {
+return 0, err
+}
+defer tx.Rollback()
+
+// Delete cart items
+result, err := tx.Exec(
+"DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id = $1)",
+userID,
+)
+if err != nil {
+return 0, err
+}
+items, err := result.RowsAffected()
+if err != nil {
+return 0, err
+}
+
+// Delete carts
+result, err = tx.Exec("DELETE FROM carts WHERE user_id = $1", userID)
+if err != nil {
+return 0, err
+}
+carts, err := result.RowsAffected()
+if err != nil {
+return 0, err
+}
+
+// Commit transaction
+return int(items + carts), tx.Commit()
+}
+
+// MarkProductDiscontinued records that a product is no longer sold, so the
+// carts holding it warn about it
+func (r *CartRepository) MarkProductDiscontinued(productID, status string, at time.Time) error {
+_, err := r.db.Exec(
+`INSERT INTO discontinued_products (product_id, status, discontinued_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (product_id) DO UPDATE SET status = excluded.status, discontinued_at = excluded.discontinued_at`,
+productID, status, at,
+)
+return err
+}
+
+// ClearProductDiscontinued records that a product is sold again
+func (r *CartRepository) ClearProductDiscontinued(productID string) error {
+_, err := r.db.Exec(
+`DELETE FROM discontinued_products WHERE product_id = $1`,
+productID,
+)
+return err
+}
+
+// RepriceCartItems moves the items of a product still at its previous price
+// to its new price. Items at another price, such as SKUs with their own
+// price, are left as they are. It returns the number of items repriced.
+func (r *CartRepository) RepriceCartItems(productID string, previousPrice, price float64) (int, error) {
+now := time.Now()
+result, err := r.db.Exec(
+`UPDATE cart_items SET price = $1, updated_at = $2
+WHERE product_id = $3 AND price = $4`,
+price, now, productID, previousPrice,
+)
+if err != nil {
+return 0, err
+}
+rows, err := result.RowsAffected()
+return int(rows), err
+}
+
 // UpdateCartUpdatedAt updates the updated_at field of a cart
 func (r *CartRepository) UpdateCartUpdatedAt(cartID string) error {
 now := time.Now()
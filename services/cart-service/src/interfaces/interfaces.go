@@ -14,12 +14,17 @@ type CartService interface {
 	RemoveCartItem(cartID string, itemID string) (models.Cart, error)
 	DeleteCart(id string) error
 	DeleteCartByUserID(userID string) error
+	GetCustomerData(customerID string) (models.CustomerData, error)
+	EraseCustomer(event models.ErasureEvent) error
+	ProductStatusChanged(event models.ProductEvent) error
+	ProductPriceChanged(event models.ProductEvent) error
 }
 
 // CartProducer defines the interface for cart producer
 type CartProducer interface {
 	PublishCartUpdated(cart models.Cart) error
 	PublishCartCleared(cartID string, customerID string) error
+	PublishErasureCompleted(event models.ErasureEvent) error
 	Close() error
 }
 
@@ -4,78 +4,92 @@ import (
 "context"
 "encoding/json"
 "log"
-"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/cart-service/config"
+"github.com/online-order-system/cart-service/eventbus"
 "github.com/online-order-system/cart-service/interfaces"
 "github.com/online-order-system/cart-service/models"
 )
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-reader  *kafka.Reader
-service interfaces.CartService
+bus          eventbus.Bus
+privacyTopic string
+service      interfaces.CartService
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.CartService) *Consumer {
-reader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:         []string{cfg.KafkaBootstrapServers},
-Topic:           "orders", // Listen to orders topic
-GroupID:         "cart-service",
-MinBytes:        10e3, // 10KB
-MaxBytes:        10e6, // 10MB
-MaxWait:         1 * time.Second,
-ReadLagInterval: -1,
-})
-
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.CartService) *Consumer {
 return &Consumer{
-reader:  reader,
-service: service,
+bus:          bus,
+privacyTopic: cfg.KafkaPrivacyTopic,
+service:      service,
 }
 }
 
 // StartConsuming starts consuming messages from Kafka
 func (c *Consumer) StartConsuming(ctx context.Context) {
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping Kafka consumer")
-c.reader.Close()
-return
-default:
-// Read message
-msg, err := c.reader.ReadMessage(ctx)
+// Listen to orders topic
+err := c.bus.Subscribe(ctx, "orders", "cart-service", c.processMessage)
 if err != nil {
-log.Printf("Error reading message: %v", err)
-continue
+log.Printf("Error subscribing to orders topic: %v", err)
+return
 }
 
-// Process message
-err = c.processMessage(msg)
+// Listen to privacy topic
+err = c.bus.Subscribe(ctx, c.privacyTopic, "cart-service", c.processPrivacyMessage)
 if err != nil {
-log.Printf("Error processing message: %v", err)
-continue
+log.Printf("Error subscribing to privacy topic: %v", err)
+return
 }
+
+log.Println("Kafka consumer started")
 }
+
+// processPrivacyMessage processes a message from the privacy topic
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+// Parse message
+var event models.ErasureEvent
+err := json.Unmarshal(value, &event)
+if err != nil {
+return err
 }
-}()
 
-log.Println("Kafka consumer started")
+// Erase the carts of the customer when asked to
+if event.EventType == "user_erasure_requested" {
+log.Printf("Erasing carts of user %s for erasure %s", event.CustomerID, event.RequestID)
+return c.service.EraseCustomer(event)
+}
+return nil
 }
 
 // processMessage processes a Kafka message
-func (c *Consumer) processMessage(msg kafka.Message) error {
+func (c *Consumer) processMessage(ctx context.Context, key, value []byte) error {
 // Parse message
 var event models.OrderEvent
-err := json.Unmarshal(msg.Value, &event)
+err := json.Unmarshal(value, &event)
 if err != nil {
 return err
 }
 
-log.Printf("Received message from Kafka orders topic: %s", string(msg.Value))
+log.Printf("Received message from Kafka orders topic: %s", string(value))
+
+// Inventory-service publishes product events on the topic too
+switch event.EventType {
+case "product_discontinued", "product_reactivated":
+var productEvent models.ProductEvent
+if err := json.Unmarshal(value, &productEvent); err != nil {
+return err
+}
+log.Printf("Product %s is %s, updating cart warnings", productEvent.ProductID, productEvent.Status)
+return c.service.ProductStatusChanged(productEvent)
+case "price_changed":
+var productEvent models.ProductEvent
+if err := json.Unmarshal(value, &productEvent); err != nil {
+return err
+}
+return c.service.ProductPriceChanged(productEvent)
+}
 
 // Process event
 return c.processOrderEvent(event)
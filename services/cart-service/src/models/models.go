@@ -18,8 +18,10 @@ type CartItem struct {
 ID        string    `json:"id"`
 CartID    string    `json:"cart_id"`
 ProductID string    `json:"product_id"`
+SKUID     string    `json:"sku_id,omitempty"` // Set for products sold in variants
 Quantity  int       `json:"quantity"`
 Price     float64   `json:"price"`
+Warning   string    `json:"warning,omitempty"` // Set when the product is no longer sold
 CreatedAt time.Time `json:"created_at"`
 UpdatedAt time.Time `json:"updated_at"`
 }
@@ -32,6 +34,7 @@ CustomerID string `json:"customer_id" binding:"required"`
 // AddCartItemRequest represents a request to add an item to a cart
 type AddCartItemRequest struct {
 ProductID string  `json:"product_id" binding:"required"`
+SKUID     string  `json:"sku_id"`
 Quantity  int     `json:"quantity" binding:"required,min=1"`
 Price     float64 `json:"price" binding:"required,min=0"`
 }
@@ -60,3 +63,47 @@ TotalAmount float64   `json:"total_amount"`
 Timestamp   int64     `json:"timestamp"`
 Items       []CartItem `json:"items,omitempty"`
 }
+
+// ProductEvent tells the services a product came off sale, discontinued or
+// archived (product_discontinued), went back on sale (product_reactivated),
+// or changed price (price_changed)
+type ProductEvent struct {
+EventType     string  `json:"event_type"`
+ProductID     string  `json:"product_id"`
+ProductName   string  `json:"product_name,omitempty"`
+Status        string  `json:"status"`
+Price         float64 `json:"price,omitempty"`
+PreviousPrice float64 `json:"previous_price,omitempty"`
+Timestamp     int64   `json:"timestamp"`
+}
+
+// CustomerData is the personal data cart-service holds about a customer, as
+// it appears in a data export
+type CustomerData struct {
+CustomerID string `json:"customer_id"`
+Carts      []Cart `json:"carts"`
+}
+
+// Statuses a service reports for an erasure
+const (
+ErasureCompleted = "completed"
+ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+EventType  string `json:"event_type"`
+RequestID  string `json:"request_id"`
+CustomerID string `json:"customer_id"`
+Email      string `json:"email,omitempty"`
+Timestamp  int64  `json:"timestamp"`
+
+// Set on user_erasure_completed events
+Service  string `json:"service,omitempty"`
+Status   string `json:"status,omitempty"`
+Erased   int    `json:"erased,omitempty"`
+Retained int    `json:"retained,omitempty"`
+Note     string `json:"note,omitempty"`
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/cart-service/models"
+)
+
+// cartServiceName names cart-service in erasure reports
+const cartServiceName = "cart-service"
+
+// GetCustomerData gathers the personal data cart-service holds about a
+// customer for a data export
+func (s *CartService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	carts, err := s.repository.GetCartsByUserID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+
+	return models.CustomerData{CustomerID: customerID, Carts: carts}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Carts are deleted with their items.
+func (s *CartService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    cartServiceName,
+		Status:     models.ErasureCompleted,
+	}
+
+	var err error
+	report.Erased, err = s.repository.EraseCustomer(event.CustomerID)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+
+	return s.producer.PublishErasureCompleted(report)
+}
@@ -82,6 +82,7 @@ item := models.CartItem{
 ID:        uuid.New().String(),
 CartID:    cartID,
 ProductID: req.ProductID,
+SKUID:     req.SKUID,
 Quantity:  req.Quantity,
 Price:     req.Price,
 CreatedAt: now,
@@ -212,6 +213,27 @@ log.Printf("Failed to publish cart cleared event: %v", err)
 return nil
 }
 
+// ProductStatusChanged records a product coming off sale or going back on
+// sale, so carts holding it warn about it until it does
+func (s *CartService) ProductStatusChanged(event models.ProductEvent) error {
+if event.EventType == "product_reactivated" {
+return s.repository.ClearProductDiscontinued(event.ProductID)
+}
+return s.repository.MarkProductDiscontinued(event.ProductID, event.Status, time.Unix(event.Timestamp, 0))
+}
+
+// ProductPriceChanged moves the items of a product in carts to its new price
+func (s *CartService) ProductPriceChanged(event models.ProductEvent) error {
+repriced, err := s.repository.RepriceCartItems(event.ProductID, event.PreviousPrice, event.Price)
+if err != nil {
+return err
+}
+if repriced > 0 {
+log.Printf("Repriced %d cart items of product %s from %.2f to %.2f", repriced, event.ProductID, event.PreviousPrice, event.Price)
+}
+return nil
+}
+
 // DeleteCartByUserID deletes a cart by user ID
 func (s *CartService) DeleteCartByUserID(userID string) error {
 // Check if cart exists
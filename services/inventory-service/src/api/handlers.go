@@ -1,12 +1,17 @@
 package api
 
 import (
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/inventory-service/auth"
+	"github.com/online-order-system/inventory-service/db"
 	"github.com/online-order-system/inventory-service/interfaces"
 	"github.com/online-order-system/inventory-service/models"
 )
@@ -31,8 +36,17 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	req.Caller = auth.Caller(c)
 	product, err := h.service.CreateProduct(req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if message := strings.ToLower(err.Error()); strings.Contains(message, "unique") || strings.Contains(message, "duplicate") {
+			c.JSON(http.StatusConflict, gin.H{"error": "a product or SKU with this SKU already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -63,12 +77,30 @@ func (h *Handler) GetProductByID(c *gin.Context) {
 		return
 	}
 
+	// Drafts and archived products are only seen by staff
+	if !product.Viewable() && !seesAllProducts(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product with ID " + id + " not found"})
+		return
+	}
+
 	c.JSON(http.StatusOK, product)
 }
 
+// seesAllProducts reports whether the caller sees products in any status:
+// other services and staff do, the storefront only sees products on sale
+func seesAllProducts(c *gin.Context) bool {
+	claims, ok := auth.ClaimsFromContext(c)
+	return ok && (claims.IsService() || claims.HasRole(auth.RoleWarehouse, auth.RoleAdmin))
+}
+
 // GetProducts handles retrieving all products
 func (h *Handler) GetProducts(c *gin.Context) {
-	products, err := h.service.GetProducts()
+	getProducts := h.service.GetListedProducts
+	if seesAllProducts(c) {
+		getProducts = h.service.GetProducts
+	}
+
+	products, err := getProducts()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
 		return
@@ -77,6 +109,88 @@ func (h *Handler) GetProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, products)
 }
 
+// searchQuery reads the search filters of a request. tags takes a comma
+// separated list; products must have all of them. Only staff may search
+// products in other statuses than active.
+func searchQuery(c *gin.Context) (models.ProductSearch, error) {
+	search := models.ProductSearch{
+		Query:      c.Query("q"),
+		CategoryID: c.Query("category_id"),
+		Status:     c.Query("status"),
+		Sort:       c.Query("sort"),
+		Cursor:     c.Query("cursor"),
+	}
+	if !seesAllProducts(c) {
+		search.Status = models.ProductStatusActive
+	}
+
+	switch search.Sort {
+	case "", models.SearchSortRelevance, models.SearchSortPriceAsc, models.SearchSortPriceDesc, models.SearchSortNewest:
+	default:
+		return search, errors.New("sort must be relevance, price_asc, price_desc or newest")
+	}
+
+	switch search.Status {
+	case "", models.ProductStatusDraft, models.ProductStatusActive, models.ProductStatusDiscontinued, models.ProductStatusArchived:
+	default:
+		return search, errors.New("status must be draft, active, discontinued or archived")
+	}
+
+	var err error
+	if search.MinPrice, err = parsePrice(c.Query("min_price")); err != nil {
+		return search, errors.New("Invalid min_price: " + err.Error())
+	}
+	if search.MaxPrice, err = parsePrice(c.Query("max_price")); err != nil {
+		return search, errors.New("Invalid max_price: " + err.Error())
+	}
+
+	if tags := c.Query("tags"); tags != "" {
+		search.Tags = strings.Split(tags, ",")
+	}
+
+	if descendants := c.Query("include_descendants"); descendants != "" {
+		if search.IncludeDescendants, err = strconv.ParseBool(descendants); err != nil {
+			return search, errors.New("Invalid include_descendants")
+		}
+	}
+
+	if inStock := c.Query("in_stock"); inStock != "" {
+		if search.InStock, err = strconv.ParseBool(inStock); err != nil {
+			return search, errors.New("Invalid in_stock")
+		}
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			search.Limit = parsedLimit
+		}
+	}
+
+	return search, nil
+}
+
+// SearchProducts handles searching the catalogue
+func (h *Handler) SearchProducts(c *gin.Context) {
+	search, err := searchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.SearchProducts(search)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid cursor") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error searching products: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // UpdateProduct handles updating a product
 func (h *Handler) UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
@@ -87,8 +201,17 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	req.Caller = auth.Caller(c)
 	product, err := h.service.UpdateProduct(id, req)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if message := strings.ToLower(err.Error()); strings.Contains(message, "unique") || strings.Contains(message, "duplicate") {
+			c.JSON(http.StatusConflict, gin.H{"error": "a product or SKU with this SKU already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -96,17 +219,24 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, product)
 }
 
-// DeleteProduct handles deleting a product
+// DeleteProduct handles deleting a product, which archives it
 func (h *Handler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 
 	err := h.service.DeleteProduct(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Product archived successfully"})
 }
 
 
@@ -144,6 +274,10 @@ func (h *Handler) CheckInventory(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if strings.HasPrefix(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		// Log the error
 		log.Printf("Error checking inventory: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -153,11 +287,22 @@ func (h *Handler) CheckInventory(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetProductRecommendations handles retrieving product recommendations by product ID
+// GetProductRecommendations handles retrieving product recommendations by product ID.
+// kind is bought_together for products frequently bought together with it,
+// also_bought for products customers who bought it also bought, or empty
+// for both.
 func (h *Handler) GetProductRecommendations(c *gin.Context) {
 	productID := c.Param("id")
 	limit := 10 // Default limit
 
+	kind := c.Query("kind")
+	switch kind {
+	case "", models.SimilarityBoughtTogether, models.SimilarityAlsoBought:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be bought_together or also_bought"})
+		return
+	}
+
 	// Parse limit from query parameter if provided
 	if limitParam := c.Query("limit"); limitParam != "" {
 		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
@@ -165,8 +310,12 @@ func (h *Handler) GetProductRecommendations(c *gin.Context) {
 		}
 	}
 
-	recommendations, err := h.service.GetProductRecommendations(productID, limit)
+	recommendations, err := h.service.GetProductRecommendations(productID, kind, limit)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -211,3 +360,674 @@ func (h *Handler) GetSimilarProducts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"products": recommendations})
 }
+
+// ComputeSimilarities handles computing product similarities from the order
+// history now, instead of waiting for the scheduled run
+func (h *Handler) ComputeSimilarities(c *gin.Context) {
+	count, err := h.service.ComputeSimilarities()
+	if err != nil {
+		log.Printf("Error computing product similarities: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute product similarities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"similarities": count})
+}
+
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export
+func (h *Handler) GetCustomerData(c *gin.Context) {
+	data, err := h.service.GetCustomerData(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// CreateWarehouse handles the creation of a new warehouse
+func (h *Handler) CreateWarehouse(c *gin.Context) {
+	var req models.CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := h.service.CreateWarehouse(req)
+	if err != nil {
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "unique") || strings.Contains(message, "duplicate") {
+			c.JSON(http.StatusConflict, gin.H{"error": "a warehouse with this code already exists"})
+			return
+		}
+		log.Printf("Error creating warehouse: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+// GetWarehouses handles retrieving all warehouses
+func (h *Handler) GetWarehouses(c *gin.Context) {
+	warehouses, err := h.service.GetWarehouses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get warehouses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// GetWarehouseByID handles retrieving a warehouse by ID
+func (h *Handler) GetWarehouseByID(c *gin.Context) {
+	warehouse, err := h.service.GetWarehouseByID(c.Param("id"))
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// UpdateWarehouse handles updating a warehouse
+func (h *Handler) UpdateWarehouse(c *gin.Context) {
+	var req models.UpdateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := h.service.UpdateWarehouse(c.Param("id"), req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// GetWarehouseStock handles retrieving the stock in a warehouse
+func (h *Handler) GetWarehouseStock(c *gin.Context) {
+	levels, err := h.service.GetWarehouseStock(c.Param("id"))
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, levels)
+}
+
+// SetStock handles setting the stock of a product in a warehouse
+func (h *Handler) SetStock(c *gin.Context) {
+	var req models.SetStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Caller = auth.Caller(c)
+	stock, err := h.service.SetStock(c.Param("id"), c.Param("product_id"), req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// GetProductStock handles retrieving the stock of a product per warehouse
+func (h *Handler) GetProductStock(c *gin.Context) {
+	stock, err := h.service.GetProductStock(c.Param("id"))
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// TransferStock handles moving stock between warehouses
+func (h *Handler) TransferStock(c *gin.Context) {
+	var req models.TransferStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Caller = auth.Caller(c)
+	transfer, err := h.service.TransferStock(req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// GetTransfers handles listing stock transfers, optionally of one product
+func (h *Handler) GetTransfers(c *gin.Context) {
+	transfers, err := h.service.GetTransfers(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// ReserveStock handles reserving the stock of an order
+func (h *Handler) ReserveStock(c *gin.Context) {
+	var req models.ReserveStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, item := range req.Items {
+		if item.ProductID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "product ID cannot be empty"})
+			return
+		}
+		if item.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be greater than 0"})
+			return
+		}
+	}
+
+	reservation, err := h.service.ReserveStock(req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// RestoreStock handles putting stock back, such as when an order failed
+func (h *Handler) RestoreStock(c *gin.Context) {
+	var req models.RestoreStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Caller = auth.Caller(c)
+	err := h.service.RestoreStock(req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock restored successfully"})
+}
+
+// GetMovements handles the request to get the stock movements of a product.
+// from and to take a date or an RFC 3339 time; to is exclusive.
+func (h *Handler) GetMovements(c *gin.Context) {
+	filter := models.MovementFilter{
+		ProductID:   c.Query("product_id"),
+		WarehouseID: c.Query("warehouse_id"),
+	}
+	if filter.ProductID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+
+	var err error
+	if filter.From, err = parseTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	if filter.To, err = parseTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+		return
+	}
+
+	movements, err := h.service.GetMovements(filter)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, movements)
+}
+
+// ReconcileStock handles the request to compare the stock of a product with
+// the ledger
+func (h *Handler) ReconcileStock(c *gin.Context) {
+	reconciliation, err := h.service.ReconcileStock(c.Param("id"))
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reconciliation)
+}
+
+// ReconcileAllStock handles the request to compare the stock of every product
+// with the ledger. Only the products that don't balance are returned unless
+// all=true is given.
+func (h *Handler) ReconcileAllStock(c *gin.Context) {
+	reconciliations, err := h.service.ReconcileAllStock()
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	unbalanced := []models.StockReconciliation{}
+	for _, reconciliation := range reconciliations {
+		if !reconciliation.Balanced {
+			unbalanced = append(unbalanced, reconciliation)
+		}
+	}
+	if c.Query("all") != "true" {
+		reconciliations = unbalanced
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balanced":        len(unbalanced) == 0,
+		"reconciliations": reconciliations,
+	})
+}
+
+// SetStockThresholds handles the request to set the stock thresholds of a
+// product
+func (h *Handler) SetStockThresholds(c *gin.Context) {
+	var req models.StockThresholds
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stock, err := h.service.SetStockThresholds(c.Param("id"), req)
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// GetStockAlerts handles the request to get the products low on stock
+func (h *Handler) GetStockAlerts(c *gin.Context) {
+	alerts, err := h.service.GetStockAlerts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stock alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetBackorders handles the request to get the open backorders, of one
+// product when product_id is given
+func (h *Handler) GetBackorders(c *gin.Context) {
+	backorders, err := h.service.GetBackorders(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get backorders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, backorders)
+}
+
+// CreateCategory handles creating a category
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var req models.CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.service.CreateCategory(req)
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// GetCategories handles retrieving the category tree
+func (h *Handler) GetCategories(c *gin.Context) {
+	categories, err := h.service.GetCategories()
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetCategoryByID handles retrieving a category with its breadcrumb
+func (h *Handler) GetCategoryByID(c *gin.Context) {
+	category, err := h.service.GetCategoryByID(c.Param("id"))
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory handles updating a category
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	var req models.UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.service.UpdateCategory(c.Param("id"), req)
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory handles deleting a category. Its products have to be
+// reassigned to another category with reassign_to.
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	err := h.service.DeleteCategory(c.Param("id"), c.Query("reassign_to"))
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// CreateSKU handles adding a SKU to a product with options
+func (h *Handler) CreateSKU(c *gin.Context) {
+	var req models.CreateSKURequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Caller = auth.Caller(c)
+	sku, err := h.service.CreateSKU(c.Param("id"), req)
+	if err != nil {
+		skuError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, sku)
+}
+
+// GetSKUs handles retrieving the variant matrix of a product
+func (h *Handler) GetSKUs(c *gin.Context) {
+	skus, err := h.service.GetSKUs(c.Param("id"))
+	if err != nil {
+		skuError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, skus)
+}
+
+// UpdateSKU handles updating a SKU
+func (h *Handler) UpdateSKU(c *gin.Context) {
+	var req models.UpdateSKURequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sku, err := h.service.UpdateSKU(c.Param("id"), c.Param("sku_id"), req)
+	if err != nil {
+		skuError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sku)
+}
+
+// DeleteSKU handles deleting a SKU
+func (h *Handler) DeleteSKU(c *gin.Context) {
+	err := h.service.DeleteSKU(c.Param("id"), c.Param("sku_id"))
+	if err != nil {
+		skuError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SKU deleted successfully"})
+}
+
+// GetPriceHistory handles retrieving the price history and scheduled prices
+// of a product
+func (h *Handler) GetPriceHistory(c *gin.Context) {
+	history, err := h.service.GetPriceHistory(c.Param("id"))
+	if err != nil {
+		priceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// SchedulePrice handles scheduling a price for a product
+func (h *Handler) SchedulePrice(c *gin.Context) {
+	var req models.CreatePriceScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Caller = auth.Caller(c)
+	schedule, err := h.service.SchedulePrice(c.Param("id"), req)
+	if err != nil {
+		priceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// CancelPriceSchedule handles cancelling a price schedule, which ends a
+// running sale
+func (h *Handler) CancelPriceSchedule(c *gin.Context) {
+	schedule, err := h.service.CancelPriceSchedule(c.Param("id"), c.Param("schedule_id"), auth.Caller(c))
+	if err != nil {
+		priceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// parseTime parses a date or an RFC 3339 time from a query parameter. An empty
+// value gives the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// parsePrice parses a price from a query parameter. An empty value gives nil.
+func parsePrice(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if price < 0 {
+		return nil, errors.New("price cannot be negative")
+	}
+	return &price, nil
+}
+
+// stockError responds with the status matching an error from the warehouse
+// and stock methods
+func stockError(c *gin.Context, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, db.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case strings.Contains(err.Error(), "cannot transfer") || strings.HasPrefix(err.Error(), "invalid"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error handling stock request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// priceError responds with the status matching an error from the price
+// schedule methods
+func priceError(c *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.Contains(err.Error(), "overlaps"):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error handling price request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// categoryError responds with the status matching an error from the category
+// methods
+func categoryError(c *gin.Context, err error) {
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.HasPrefix(message, "invalid"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.Contains(message, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, db.ErrCategoryNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + ", reassign them with reassign_to"})
+	case strings.Contains(message, "unique") || strings.Contains(message, "duplicate"):
+		c.JSON(http.StatusConflict, gin.H{"error": "a category with this slug already exists"})
+	default:
+		log.Printf("Error handling category request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// skuError responds with the status matching an error from the SKU methods
+func skuError(c *gin.Context, err error) {
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.HasPrefix(message, "invalid"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.Contains(message, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.Contains(message, "unique") || strings.Contains(message, "duplicate"):
+		c.JSON(http.StatusConflict, gin.H{"error": "a SKU with this code or barcode already exists"})
+	default:
+		log.Printf("Error handling SKU request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// maxImportSize is the largest catalogue file that can be imported
+const maxImportSize = 32 << 20
+
+// catalogueFormat returns the format of a catalogue file, from the format
+// query parameter or else the content type
+func catalogueFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	switch c.ContentType() {
+	case "text/csv":
+		return models.CatalogueFormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return models.CatalogueFormatJSONL
+	}
+	return ""
+}
+
+// ImportProducts handles importing a catalogue file sent as the request body.
+// The import runs in the background; the job it returns tells its progress.
+func (h *Handler) ImportProducts(c *gin.Context) {
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run"})
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "catalogue files can be up to 32MB"})
+		return
+	}
+
+	job, err := h.service.ImportProducts(catalogueFormat(c), data, dryRun, auth.Caller(c))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJob handles retrieving the progress of an import
+func (h *Handler) GetImportJob(c *gin.Context) {
+	job, err := h.service.GetImportJob(c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportProducts handles exporting the products matching a search as a
+// catalogue file. warehouse takes the code of a warehouse to export its
+// stock instead of the stock in all warehouses.
+func (h *Handler) ExportProducts(c *gin.Context) {
+	search, err := searchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", models.CatalogueFormatCSV)
+
+	data, err := h.service.ExportProducts(search, format, c.Query("warehouse"))
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error exporting products: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export products"})
+		}
+		return
+	}
+
+	contentType := "text/csv"
+	if format == models.CatalogueFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Disposition", "attachment; filename=products."+format)
+	c.Data(http.StatusOK, contentType, data)
+}
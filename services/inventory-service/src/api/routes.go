@@ -2,17 +2,19 @@ package api
 
 import (
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/inventory-service/auth"
 "github.com/online-order-system/inventory-service/interfaces"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.InventoryService) *gin.Engine {
+func SetupRouter(service interfaces.InventoryService, verifier *auth.Verifier) *gin.Engine {
 // Create router
 router := gin.Default()
 
 // Add middleware
 router.Use(Logger())
 router.Use(CORS())
+router.Use(auth.Authenticate(verifier))
 
 // Create handler
 handler := NewHandler(service)
@@ -28,33 +30,108 @@ c.JSON(200, gin.H{
 router.StaticFile("/swagger", "./docs/swagger.html")
 router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+// Who may change the catalogue. Reading it is open to everyone.
+warehouse := auth.RequireRole(auth.RoleWarehouse, auth.RoleAdmin)
+admin := auth.RequireRole(auth.RoleAdmin)
+internal := auth.RequireRole(auth.Internal)
+
 // Product routes
 products := router.Group("/products")
 {
 // Create a new product
-products.POST("", handler.CreateProduct)
+products.POST("", warehouse, handler.CreateProduct)
 
 // Get all products
 products.GET("", handler.GetProducts)
 
+// Search products by words, category, price, tags and stock
+products.GET("/search", handler.SearchProducts)
+
+// Export the products a search finds as a CSV or JSON Lines file
+products.GET("/export", warehouse, handler.ExportProducts)
+
 // Get a specific product by ID
 products.GET("/:id", handler.GetProductByID)
 
 // Update a product (full update)
-products.PUT("/:id", handler.UpdateProduct)
+products.PUT("/:id", warehouse, handler.UpdateProduct)
 
 // Update a product (partial update)
-products.PATCH("/:id", handler.UpdateProduct)
+products.PATCH("/:id", warehouse, handler.UpdateProduct)
 
 // Delete a product
-products.DELETE("/:id", handler.DeleteProduct)
+products.DELETE("/:id", admin, handler.DeleteProduct)
+
+// Variant matrix of a product and its SKUs
+products.GET("/:id/skus", handler.GetSKUs)
+products.POST("/:id/skus", warehouse, handler.CreateSKU)
+products.PUT("/:id/skus/:sku_id", warehouse, handler.UpdateSKU)
+products.DELETE("/:id/skus/:sku_id", admin, handler.DeleteSKU)
+
+// Price history and scheduled prices of a product
+products.GET("/:id/prices", warehouse, handler.GetPriceHistory)
+products.POST("/:id/prices/schedules", warehouse, handler.SchedulePrice)
+products.DELETE("/:id/prices/schedules/:schedule_id", warehouse, handler.CancelPriceSchedule)
+}
+
+// Category routes. The tree is open to everyone like the catalogue.
+categories := router.Group("/categories")
+{
+categories.GET("", handler.GetCategories)
+categories.POST("", warehouse, handler.CreateCategory)
+categories.GET("/:id", handler.GetCategoryByID)
+categories.PUT("/:id", warehouse, handler.UpdateCategory)
+categories.DELETE("/:id", admin, handler.DeleteCategory)
+}
+
+// Catalogue imports run in the background and report their progress
+imports := router.Group("/imports")
+{
+imports.POST("", warehouse, handler.ImportProducts)
+imports.GET("/:id", warehouse, handler.GetImportJob)
 }
 
 // Inventory routes
 inventory := router.Group("/inventory")
 {
-// Check inventory
-inventory.POST("/check", handler.CheckInventory)
+// Check inventory, for order-service and staff
+inventory.POST("/check", auth.RequireRole(auth.Internal, auth.RoleWarehouse, auth.RoleAdmin), handler.CheckInventory)
+
+// Stock of a product per warehouse
+inventory.GET("/products/:id", warehouse, handler.GetProductStock)
+
+// Low stock thresholds and the products below them
+inventory.PUT("/products/:id/thresholds", warehouse, handler.SetStockThresholds)
+inventory.GET("/alerts", warehouse, handler.GetStockAlerts)
+
+// Order lines waiting for stock of backorder and pre-order products
+inventory.GET("/backorders", warehouse, handler.GetBackorders)
+
+// Move stock between warehouses
+inventory.POST("/transfers", warehouse, handler.TransferStock)
+inventory.GET("/transfers", warehouse, handler.GetTransfers)
+
+// Ledger of stock movements and its reconciliation with the stored stock
+inventory.GET("/movements", warehouse, handler.GetMovements)
+inventory.GET("/products/:id/reconcile", warehouse, handler.ReconcileStock)
+inventory.GET("/reconcile", admin, handler.ReconcileAllStock)
+
+// Reserve the stock of an order and put it back, for order-service
+inventory.POST("/reserve", internal, handler.ReserveStock)
+inventory.POST("/restore", internal, handler.RestoreStock)
+}
+
+// Warehouse routes
+warehouses := router.Group("/inventory/warehouses")
+{
+warehouses.GET("", warehouse, handler.GetWarehouses)
+warehouses.POST("", admin, handler.CreateWarehouse)
+warehouses.GET("/:id", warehouse, handler.GetWarehouseByID)
+warehouses.PUT("/:id", admin, handler.UpdateWarehouse)
+
+// Stock in a warehouse
+warehouses.GET("/:id/stock", warehouse, handler.GetWarehouseStock)
+warehouses.PUT("/:id/stock/:product_id", warehouse, handler.SetStock)
 }
 
 // Recommendation routes
@@ -68,7 +145,13 @@ recommendations.GET("/category/:id", handler.GetCategoryRecommendations)
 
 // Get similar products
 recommendations.POST("/similar", handler.GetSimilarProducts)
+
+// Compute similarities from the order history now
+recommendations.POST("/compute", admin, handler.ComputeSimilarities)
 }
 
+// Personal data of a customer, gathered by user-service for data exports
+router.GET("/customers/:id/data", internal, handler.GetCustomerData)
+
 return router
 }
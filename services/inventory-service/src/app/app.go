@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/inventory-service/api"
+	"github.com/online-order-system/inventory-service/auth"
+	"github.com/online-order-system/inventory-service/cache"
+	"github.com/online-order-system/inventory-service/config"
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/eventbus"
+	"github.com/online-order-system/inventory-service/kafka"
+	"github.com/online-order-system/inventory-service/models"
+	"github.com/online-order-system/inventory-service/service"
+)
+
+// App wires together the components of the inventory service
+type App struct {
+	Config   *config.Config
+	Service  *service.InventoryService
+	Router   *gin.Engine
+	database *db.Database
+	cache    *cache.Cache
+	consumer *kafka.Consumer
+}
+
+// New connects to the database and Redis and builds the inventory service on
+// top of the given event bus. Redis is optional: while it is unreachable the
+// service caches in memory.
+func New(cfg *config.Config, bus eventbus.Bus) (*App, error) {
+	// Connect to database
+	database, err := db.NewDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	// Create tables
+	err = database.CreateTables()
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+
+	// Create repository
+	repository := db.NewInventoryRepository(database)
+
+	// Stock is kept per warehouse, so there has to be one to put it in
+	now := time.Now()
+	err = repository.EnsureDefaultWarehouse(models.Warehouse{
+		ID:        db.GenerateID(),
+		Code:      "MAIN",
+		Name:      "Main warehouse",
+		Country:   cfg.DefaultWarehouseCountry,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create default warehouse: %v", err)
+	}
+
+	// Create cache
+	productCache := cache.New(cfg)
+
+	// Create Kafka producer
+	producer := kafka.NewProducer(cfg, bus)
+
+	// Create service
+	inventoryService := service.NewInventoryService(cfg, repository, producer, productCache)
+
+	// Products are filed under categories, so the category IDs products had
+	// before categories were kept become categories
+	err = inventoryService.EnsureCategories()
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create categories: %v", err)
+	}
+
+	// Verify access tokens with the keys published by user-service
+	verifier := auth.NewVerifier(auth.NewRemoteKeySet(cfg.JWKSURL), cfg.JWTIssuer, cfg.JWTAudience)
+
+	// Only the gateway is trusted to say which client a request came from
+	router := api.SetupRouter(inventoryService, verifier)
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+
+	return &App{
+		Config:   cfg,
+		Service:  inventoryService,
+		Router:   router,
+		database: database,
+		cache:    productCache,
+		consumer: kafka.NewConsumer(cfg, bus, inventoryService),
+	}, nil
+}
+
+// Start starts the Kafka consumers, the similarity job, the price scheduler
+// and the sweep of interrupted imports. They stop when ctx is cancelled.
+func (a *App) Start(ctx context.Context) {
+	a.consumer.StartConsuming(ctx)
+
+	// Compute product similarities from the order history now and then on
+	// schedule
+	interval := a.Config.RecommendationInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := a.Service.ComputeSimilarities(); err != nil {
+				log.Printf("Failed to compute product similarities: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Started product similarity job (every %s)", interval)
+
+	// Start and end scheduled prices on time
+	priceInterval := a.Config.PriceScheduleInterval
+	if priceInterval <= 0 {
+		priceInterval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(priceInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := a.Service.ApplyPriceSchedules(); err != nil {
+				log.Printf("Failed to apply price schedules: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Started price scheduler (every %s)", priceInterval)
+
+	// Imports run in the background of a replica, so those running when a
+	// replica stops never finish
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			if _, err := a.Service.FailStaleImports(); err != nil {
+				log.Printf("Failed to fail interrupted imports: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close closes the Redis and database connections
+func (a *App) Close() error {
+	a.cache.Close()
+	return a.database.Close()
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+"context"
+"encoding/json"
+"fmt"
+"log"
+"math/rand"
+"sync"
+"sync/atomic"
+"time"
+
+"github.com/go-redis/redis/v8"
+"github.com/online-order-system/inventory-service/config"
+)
+
+// probeInterval is how often Redis is tried again while it is unavailable
+const probeInterval = 5 * time.Second
+
+// Cache caches values in Redis, shared by the replicas of the service, or in
+// an in-process LRU while Redis is unavailable. Values are cached under the
+// version of a name, such as a product, and bumping the version invalidates
+// all of them at once: values loaded before the bump are stored under the
+// old version and never read.
+type Cache struct {
+redis *RedisCache
+local *LRU
+ttl   time.Duration
+
+// Whether Redis answers. While it doesn't, values are cached in local and
+// versions that couldn't be bumped in Redis are missed.
+available atomic.Bool
+missed    atomic.Bool
+
+// Guards the loads in flight and the versions in local
+mu      sync.Mutex
+flights map[string]*flight
+done    chan struct{}
+}
+
+// flight is a load of a key that concurrent misses wait for
+type flight struct {
+wg   sync.WaitGroup
+data []byte
+err  error
+}
+
+// New creates a cache using Redis if it can be reached, and retrying it in
+// the background otherwise
+func New(cfg *config.Config) *Cache {
+c := &Cache{
+redis:   NewRedisCache(cfg),
+local:   NewLRU(cfg.CacheLRUSize),
+ttl:     cfg.RedisCacheTTL,
+flights: make(map[string]*flight),
+done:    make(chan struct{}),
+}
+
+if err := c.redis.Ping(context.Background()); err != nil {
+log.Printf("Failed to connect to Redis: %v", err)
+log.Println("Caching in memory until Redis is available...")
+go c.probe()
+} else {
+c.available.Store(true)
+log.Println("Connected to Redis cache")
+}
+return c
+}
+
+// Close stops retrying Redis and closes the Redis client
+func (c *Cache) Close() error {
+close(c.done)
+return c.redis.Close()
+}
+
+// Load reads the value cached under key and the version of name into value.
+// On a miss it calls load and caches what it returns. Concurrent misses of a
+// key share one call of load.
+func (c *Cache) Load(ctx context.Context, name, key string, value interface{}, load func() (interface{}, error)) error {
+inRedis := c.available.Load()
+var version int64
+if inRedis {
+var err error
+version, err = c.redis.Version(ctx, name, time.Now().UnixNano())
+if err != nil {
+c.unavailable(err)
+inRedis = false
+}
+}
+if !inRedis {
+version = c.localVersion(name)
+}
+key = fmt.Sprintf("%s:v%d", key, version)
+
+// A key read with a Redis version can only be cached in Redis
+cacheable := true
+if inRedis {
+data, err := c.redis.Get(ctx, key)
+if err == nil {
+return json.Unmarshal(data, value)
+}
+if err != redis.Nil {
+c.unavailable(err)
+cacheable = false
+}
+} else if data, ok := c.local.Get(key); ok {
+return json.Unmarshal(data.([]byte), value)
+}
+
+data, err := c.loadOnce(key, func() ([]byte, error) {
+loaded, err := load()
+if err != nil {
+return nil, err
+}
+data, err := json.Marshal(loaded)
+if err != nil {
+return nil, err
+}
+
+if !cacheable {
+return data, nil
+}
+if inRedis {
+if err := c.redis.Set(ctx, key, data, c.expiry()); err != nil {
+c.unavailable(err)
+}
+} else {
+c.local.Set(key, data, c.expiry())
+}
+return data, nil
+})
+if err != nil {
+return err
+}
+return json.Unmarshal(data, value)
+}
+
+// Invalidate bumps the versions of names, so nothing cached under them before
+// is read again by this replica. Other replicas have to Forget them.
+func (c *Cache) Invalidate(ctx context.Context, names ...string) {
+c.Forget(names...)
+
+if !c.available.Load() {
+c.missed.Store(true)
+return
+}
+for _, name := range names {
+if err := c.redis.Bump(ctx, name, time.Now().UnixNano()); err != nil {
+c.missed.Store(true)
+c.unavailable(err)
+return
+}
+}
+}
+
+// Forget bumps the versions of names in memory only, for names invalidated by
+// another replica, which bumped them in Redis
+func (c *Cache) Forget(names ...string) {
+c.mu.Lock()
+defer c.mu.Unlock()
+
+for _, name := range names {
+version := time.Now().UnixNano()
+if current, ok := c.local.Get(versionPrefix + name); ok {
+version = max(version, current.(int64)+1)
+}
+c.local.Set(versionPrefix+name, version, 0)
+}
+}
+
+// localVersion returns the version of a name in memory. Names without one
+// start at the current time, newer than any version they had.
+func (c *Cache) localVersion(name string) int64 {
+c.mu.Lock()
+defer c.mu.Unlock()
+
+if version, ok := c.local.Get(versionPrefix + name); ok {
+return version.(int64)
+}
+version := time.Now().UnixNano()
+c.local.Set(versionPrefix+name, version, 0)
+return version
+}
+
+// loadOnce calls load for a key unless it is already being loaded, in which
+// case it waits for that load and returns what it returned
+func (c *Cache) loadOnce(key string, load func() ([]byte, error)) ([]byte, error) {
+c.mu.Lock()
+if f, ok := c.flights[key]; ok {
+c.mu.Unlock()
+f.wg.Wait()
+return f.data, f.err
+}
+f := &flight{}
+f.wg.Add(1)
+c.flights[key] = f
+c.mu.Unlock()
+
+defer func() {
+c.mu.Lock()
+delete(c.flights, key)
+c.mu.Unlock()
+f.wg.Done()
+}()
+
+f.data, f.err = load()
+return f.data, f.err
+}
+
+// expiry returns the TTL of a value, spread by up to 10% either way so values
+// cached together don't expire together
+func (c *Cache) expiry() time.Duration {
+spread := int64(c.ttl) / 5
+if spread <= 0 {
+return c.ttl
+}
+return c.ttl - time.Duration(spread/2) + time.Duration(rand.Int63n(spread))
+}
+
+// unavailable switches to caching in memory after Redis failed, until it
+// answers again
+func (c *Cache) unavailable(err error) {
+if !c.available.CompareAndSwap(true, false) {
+return
+}
+log.Printf("Redis cache unavailable, caching in memory: %v", err)
+go c.probe()
+}
+
+// probe tries Redis until it answers and switches back to it. Versions that
+// couldn't be bumped meanwhile are reset first, since values cached in Redis
+// under them may be stale.
+func (c *Cache) probe() {
+ticker := time.NewTicker(probeInterval)
+defer ticker.Stop()
+
+ctx := context.Background()
+for {
+select {
+case <-c.done:
+return
+case <-ticker.C:
+}
+
+if err := c.redis.Ping(ctx); err != nil {
+continue
+}
+if err := c.resetMissed(ctx); err != nil {
+continue
+}
+c.available.Store(true)
+
+// Versions may have been missed while switching back
+if err := c.resetMissed(ctx); err != nil {
+c.unavailable(err)
+return
+}
+log.Println("Redis cache available again")
+return
+}
+}
+
+// resetMissed resets the versions in Redis if any were missed
+func (c *Cache) resetMissed(ctx context.Context) error {
+if !c.missed.Swap(false) {
+return nil
+}
+if err := c.redis.ResetVersions(ctx); err != nil {
+c.missed.Store(true)
+return err
+}
+log.Println("Reset Redis cache versions missed while it was unavailable")
+return nil
+}
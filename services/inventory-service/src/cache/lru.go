@@ -0,0 +1,77 @@
+package cache
+
+import (
+"container/list"
+"sync"
+"time"
+)
+
+// LRU is an in-process cache holding at most size values, dropping the least
+// recently used first. It stands in for Redis while Redis is unavailable.
+type LRU struct {
+mu      sync.Mutex
+size    int
+entries map[string]*list.Element
+order   *list.List
+}
+
+// lruEntry is a value of the LRU. Values with a zero expiry don't expire.
+type lruEntry struct {
+key     string
+value   interface{}
+expires time.Time
+}
+
+// NewLRU creates an LRU holding at most size values
+func NewLRU(size int) *LRU {
+if size <= 0 {
+size = 1
+}
+return &LRU{
+size:    size,
+entries: make(map[string]*list.Element),
+order:   list.New(),
+}
+}
+
+// Get retrieves a value, reporting whether it was cached
+func (l *LRU) Get(key string) (interface{}, bool) {
+l.mu.Lock()
+defer l.mu.Unlock()
+
+element, ok := l.entries[key]
+if !ok {
+return nil, false
+}
+entry := element.Value.(*lruEntry)
+if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+l.order.Remove(element)
+delete(l.entries, key)
+return nil, false
+}
+l.order.MoveToFront(element)
+return entry.value, true
+}
+
+// Set stores a value for ttl, or until evicted when ttl is 0
+func (l *LRU) Set(key string, value interface{}, ttl time.Duration) {
+l.mu.Lock()
+defer l.mu.Unlock()
+
+var expires time.Time
+if ttl > 0 {
+expires = time.Now().Add(ttl)
+}
+if element, ok := l.entries[key]; ok {
+element.Value = &lruEntry{key: key, value: value, expires: expires}
+l.order.MoveToFront(element)
+return
+}
+
+l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+for l.order.Len() > l.size {
+oldest := l.order.Back()
+l.order.Remove(oldest)
+delete(l.entries, oldest.Value.(*lruEntry).key)
+}
+}
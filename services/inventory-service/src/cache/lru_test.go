@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUDropsLeastRecentlyUsed(t *testing.T) {
+	lru := NewLRU(2)
+	lru.Set("a", 1, 0)
+	lru.Set("b", 2, 0)
+	lru.Get("a")
+	lru.Set("c", 3, 0)
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("b is cached, want it dropped as the least recently used")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if value, ok := lru.Get(key); !ok || value != want {
+			t.Errorf("Get(%s) = %v, %t, want %d", key, value, ok, want)
+		}
+	}
+
+	// Setting a cached key replaces it without dropping another
+	lru.Set("a", 4, 0)
+	if value, _ := lru.Get("a"); value != 4 {
+		t.Errorf("Get(a) = %v after replacing it, want 4", value)
+	}
+	if _, ok := lru.Get("c"); !ok {
+		t.Error("c was dropped when a was replaced")
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	lru := NewLRU(10)
+	lru.Set("short", 1, time.Millisecond)
+	lru.Set("forever", 2, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := lru.Get("short"); ok {
+		t.Error("short is cached after it expired")
+	}
+	if _, ok := lru.Get("forever"); !ok {
+		t.Error("forever expired")
+	}
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 "context"
-"encoding/json"
 "fmt"
 "time"
 
@@ -10,31 +9,36 @@ import (
 "github.com/online-order-system/inventory-service/config"
 )
 
-// RedisCache represents a Redis cache client
+// versionPrefix prefixes the keys of the versions of cached values in Redis
+const versionPrefix = "version:"
+
+// RedisCache represents a Redis cache client. Values are stored as they are
+// given; Cache decides what goes in and for how long.
 type RedisCache struct {
 client *redis.Client
-ttl    time.Duration
 }
 
-// NewRedisCache creates a new Redis cache client
-func NewRedisCache(cfg *config.Config) (*RedisCache, error) {
+// NewRedisCache creates a new Redis cache client. It doesn't connect until
+// first used, so the service can start before Redis does.
+func NewRedisCache(cfg *config.Config) *RedisCache {
 client := redis.NewClient(&redis.Options{
 Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
 Password: cfg.RedisPassword,
 DB:       cfg.RedisDB,
+// Fail fast, the cache falls back on memory rather than wait for Redis
+DialTimeout:  time.Second,
+ReadTimeout:  500 * time.Millisecond,
+WriteTimeout: 500 * time.Millisecond,
 })
 
-// Test connection
-ctx := context.Background()
-_, err := client.Ping(ctx).Result()
-if err != nil {
-return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-}
-
 return &RedisCache{
 client: client,
-ttl:    time.Duration(cfg.RedisCacheTTL) * time.Second,
-}, nil
+}
+}
+
+// Ping checks that Redis can be reached
+func (c *RedisCache) Ping(ctx context.Context) error {
+return c.client.Ping(ctx).Err()
 }
 
 // Close closes the Redis client
@@ -42,32 +46,64 @@ func (c *RedisCache) Close() error {
 return c.client.Close()
 }
 
-// Get retrieves a value from the cache
-func (c *RedisCache) Get(ctx context.Context, key string, value interface{}) error {
-data, err := c.client.Get(ctx, key).Result()
-if err != nil {
-return err
+// Get retrieves a value from the cache. It returns redis.Nil when the key
+// isn't cached.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+return c.client.Get(ctx, key).Bytes()
 }
 
-return json.Unmarshal([]byte(data), value)
+// Set stores a value in the cache for ttl
+func (c *RedisCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+return c.client.Set(ctx, key, data, ttl).Err()
 }
 
-// Set stores a value in the cache
-func (c *RedisCache) Set(ctx context.Context, key string, value interface{}) error {
-data, err := json.Marshal(value)
-if err != nil {
-return err
+// Version returns the version of a name. A name without a version, never
+// versioned or evicted, starts at initial, which must be newer than any
+// version it could have had.
+func (c *RedisCache) Version(ctx context.Context, name string, initial int64) (int64, error) {
+key := versionPrefix + name
+version, err := c.client.Get(ctx, key).Int64()
+if err != redis.Nil {
+return version, err
 }
 
-return c.client.Set(ctx, key, data, c.ttl).Err()
+// Another replica may set it first, in which case its version is used
+if err := c.client.SetNX(ctx, key, initial, 0).Err(); err != nil {
+return 0, err
+}
+return c.client.Get(ctx, key).Int64()
 }
 
-// Delete removes a value from the cache
-func (c *RedisCache) Delete(ctx context.Context, key string) error {
-return c.client.Del(ctx, key).Err()
+// Bump moves a name to a new version, so the values cached under the old one
+// are no longer read. A name without a version starts at initial, as in
+// Version.
+func (c *RedisCache) Bump(ctx context.Context, name string, initial int64) error {
+key := versionPrefix + name
+if err := c.client.SetNX(ctx, key, initial, 0).Err(); err != nil {
+return err
+}
+return c.client.Incr(ctx, key).Err()
 }
 
-// FlushAll removes all values from the cache
-func (c *RedisCache) FlushAll(ctx context.Context) error {
-return c.client.FlushAll(ctx).Err()
+// ResetVersions drops the versions of all names. Names start again at new
+// versions, so nothing cached before is read again.
+func (c *RedisCache) ResetVersions(ctx context.Context) error {
+iter := c.client.Scan(ctx, 0, versionPrefix+"*", 1000).Iterator()
+var keys []string
+for iter.Next(ctx) {
+keys = append(keys, iter.Val())
+if len(keys) == 1000 {
+if err := c.client.Del(ctx, keys...).Err(); err != nil {
+return err
+}
+keys = keys[:0]
+}
+}
+if err := iter.Err(); err != nil {
+return err
+}
+if len(keys) > 0 {
+return c.client.Del(ctx, keys...).Err()
+}
+return nil
 }
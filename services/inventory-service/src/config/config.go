@@ -3,15 +3,21 @@ package config
 import (
 "os"
 "strconv"
+"strings"
 "time"
 )
 
 // Config holds all configuration for the service
 type Config struct {
 // Server configuration
-ServerPort string
+ServerPort     string
+// Addresses of the proxies, the gateway, trusted to say which client a
+// request came from. Clients are identified by their own address otherwise.
+TrustedProxies []string
 
 // Database configuration
+DBDriver   string
+DBDSN      string
 DBHost     string
 DBPort     string
 DBUser     string
@@ -21,6 +27,13 @@ DBName     string
 // Kafka configuration
 KafkaBootstrapServers string
 KafkaTopic            string
+KafkaPrivacyTopic     string
+EventBus              string
+
+// JWT configuration
+JWTIssuer   string
+JWTAudience string
+JWKSURL     string
 
 // Redis configuration
 RedisHost     string
@@ -28,15 +41,46 @@ RedisPort     string
 RedisPassword string
 RedisDB       int
 RedisCacheTTL time.Duration
+
+// Cache configuration. Values are cached in an LRU of CacheLRUSize entries
+// while Redis is unavailable, and replicas tell each other what to
+// invalidate on CacheTopic.
+CacheLRUSize int
+CacheTopic   string
+
+// Fulfilment configuration
+FulfilmentStrategy      string
+DefaultWarehouseCountry string
+
+// Stock alert configuration. Products without their own thresholds use
+// these, and days of cover are estimated from the sales of the last
+// SalesWindowDays days.
+LowStockThreshold int
+ReorderPoint      int
+SalesWindowDays   int
+
+// Recommendation configuration. Similarities are computed every
+// RecommendationInterval from the orders of the last
+// RecommendationWindowDays days; products need RecommendationMinSupport
+// orders or customers in common to be recommended for each other.
+RecommendationInterval   time.Duration
+RecommendationWindowDays int
+RecommendationMinSupport int
+
+// Price schedules are started and ended every PriceScheduleInterval
+PriceScheduleInterval time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 return &Config{
 // Server configuration
-ServerPort: getEnv("PORT", "8082"),
+ServerPort:     getEnv("PORT", "8082"),
+TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 // Database configuration
+DBDriver:   getEnv("DB_DRIVER", "postgres"),
+DBDSN:      getEnv("DB_DSN", ""),
 DBHost:     getEnv("DB_HOST", "localhost"),
 DBPort:     getEnv("DB_PORT", "5432"),
 DBUser:     getEnv("DB_USER", "postgres"),
@@ -46,6 +90,13 @@ DBName:     getEnv("DB_NAME", "inventorydb"),
 // Kafka configuration
 KafkaBootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:9092"),
 KafkaTopic:            getEnv("KAFKA_TOPIC", "orders"),
+KafkaPrivacyTopic:     getEnv("KAFKA_PRIVACY_TOPIC", "privacy"),
+EventBus:              getEnv("EVENT_BUS", "kafka"),
+
+// JWT configuration
+JWTIssuer:   getEnv("JWT_ISSUER", "user-service"),
+JWTAudience: getEnv("JWT_AUDIENCE", "online-order-system"),
+JWKSURL:     getEnv("JWKS_URL", "http://user-service:8086/.well-known/jwks.json"),
 
 // Redis configuration
 RedisHost:     getEnv("REDIS_HOST", "localhost"),
@@ -53,6 +104,27 @@ RedisPort:     getEnv("REDIS_PORT", "6379"),
 RedisPassword: getEnv("REDIS_PASSWORD", ""),
 RedisDB:       getEnvAsInt("REDIS_DB", 1),
 RedisCacheTTL: time.Duration(getEnvAsInt("REDIS_CACHE_TTL", 3600)) * time.Second,
+
+// Cache configuration
+CacheLRUSize: getEnvAsInt("CACHE_LRU_SIZE", 10000),
+CacheTopic:   getEnv("CACHE_TOPIC", "inventory-cache"),
+
+// Fulfilment configuration: nearest, most_stock or fewest_splits
+FulfilmentStrategy:      getEnv("FULFILMENT_STRATEGY", "fewest_splits"),
+DefaultWarehouseCountry: getEnv("DEFAULT_WAREHOUSE_COUNTRY", "VN"),
+
+// Stock alert configuration
+LowStockThreshold: getEnvAsInt("LOW_STOCK_THRESHOLD", 5),
+ReorderPoint:      getEnvAsInt("REORDER_POINT", 10),
+SalesWindowDays:   getEnvAsInt("SALES_WINDOW_DAYS", 30),
+
+// Recommendation configuration
+RecommendationInterval:   time.Duration(getEnvAsInt("RECOMMENDATION_INTERVAL_MINUTES", 60)) * time.Minute,
+RecommendationWindowDays: getEnvAsInt("RECOMMENDATION_WINDOW_DAYS", 180),
+RecommendationMinSupport: getEnvAsInt("RECOMMENDATION_MIN_SUPPORT", 2),
+
+// Price schedule configuration
+PriceScheduleInterval: time.Duration(getEnvAsInt("PRICE_SCHEDULE_INTERVAL_SECONDS", 60)) * time.Second,
 }
 }
 
@@ -81,3 +153,14 @@ return value
 }
 return defaultValue
 }
+
+// Helper to read a comma separated environment variable into a list
+func getEnvAsList(key string) []string {
+var values []string
+for _, value := range strings.Split(getEnv(key, ""), ",") {
+if value = strings.TrimSpace(value); value != "" {
+values = append(values, value)
+}
+}
+return values
+}
@@ -10,19 +10,29 @@ import (
 _ "github.com/lib/pq"
 )
 
+// Supported database drivers
+const (
+DriverPostgres = "postgres"
+DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 *sql.DB
+Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 // Create connection string
-connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+connStr := cfg.DBDSN
+if connStr == "" {
+connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+}
 
 // Connect to database
-db, err := sql.Open("postgres", connStr)
+db, err := sql.Open(cfg.DBDriver, connStr)
 if err != nil {
 return nil, err
 }
@@ -38,9 +48,19 @@ db.SetMaxOpenConns(25)
 db.SetMaxIdleConns(25)
 db.SetConnMaxLifetime(5 * time.Minute)
 
-log.Println("Connected to PostgreSQL database")
+log.Printf("Connected to %s database", cfg.DBDriver)
 
-return &Database{db}, nil
+return &Database{DB: db, Driver: cfg.DBDriver}, nil
+}
+
+// ForUpdate returns the clause locking the rows a query reads until the end
+// of the transaction. SQLite has no row locks; a transaction there holds the
+// write lock of the whole database from its first write.
+func (db *Database) ForUpdate() string {
+if db.Driver == DriverSQLite {
+return ""
+}
+return " FOR UPDATE"
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -53,6 +73,14 @@ name VARCHAR(100) NOT NULL,
 description TEXT NOT NULL,
 category_id VARCHAR(36),
 price DECIMAL(10, 2) NOT NULL,
+availability VARCHAR(20) NOT NULL DEFAULT 'stock',
+available_at TIMESTAMP,
+parent_id VARCHAR(36) REFERENCES products(id),
+sku VARCHAR(64) NOT NULL DEFAULT '',
+barcode VARCHAR(64) NOT NULL DEFAULT '',
+status VARCHAR(20) NOT NULL DEFAULT 'active',
+was_price DECIMAL(10, 2),
+sale_ends_at TIMESTAMP,
 created_at TIMESTAMP NOT NULL,
 updated_at TIMESTAMP NOT NULL
 )
@@ -61,11 +89,74 @@ if err != nil {
 return err
 }
 
+// Backorder and pre-order columns
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS availability VARCHAR(20) NOT NULL DEFAULT 'stock'`)
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS available_at TIMESTAMP`)
+
+// Variant columns. A SKU is kept as a product under its parent product, so
+// its stock is kept like the stock of any product. Its price is the price
+// override, 0 when it sells at the price of its product.
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS parent_id VARCHAR(36) REFERENCES products(id)`)
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64) NOT NULL DEFAULT ''`)
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS barcode VARCHAR(64) NOT NULL DEFAULT ''`)
+
+// Lifecycle column. Products created before it was kept are on sale.
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'`)
+
+// Sale columns. While a sale price is applied, was_price is the price the
+// product goes back to at sale_ends_at.
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS was_price DECIMAL(10, 2)`)
+_, _ = db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS sale_ends_at TIMESTAMP`)
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_products_parent_id ON products(parent_id)`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products(sku) WHERE sku <> ''`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_barcode ON products(barcode) WHERE barcode <> ''`)
+if err != nil {
+return err
+}
+
+// Create product_options table holding the options a product is sold in,
+// such as size and colour, one row per value
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS product_options (
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+name VARCHAR(50) NOT NULL,
+value VARCHAR(50) NOT NULL,
+option_position INTEGER NOT NULL,
+value_position INTEGER NOT NULL,
+PRIMARY KEY (product_id, name, value)
+)
+`)
+if err != nil {
+return err
+}
+
+// Create sku_options table holding the option values of each SKU
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS sku_options (
+sku_id VARCHAR(36) NOT NULL REFERENCES products(id),
+name VARCHAR(50) NOT NULL,
+value VARCHAR(50) NOT NULL,
+PRIMARY KEY (sku_id, name)
+)
+`)
+if err != nil {
+return err
+}
+
 // Create inventory table
 _, err = db.Exec(`
 CREATE TABLE IF NOT EXISTS inventory (
 product_id VARCHAR(36) PRIMARY KEY REFERENCES products(id),
 quantity INTEGER NOT NULL,
+low_stock_threshold INTEGER,
+reorder_point INTEGER,
+stock_alert VARCHAR(20) NOT NULL DEFAULT '',
 updated_at TIMESTAMP NOT NULL
 )
 `)
@@ -73,6 +164,12 @@ if err != nil {
 return err
 }
 
+// Stock alert columns. stock_alert is the last alert raised, so an alert is
+// only raised when stock crosses a threshold.
+_, _ = db.Exec(`ALTER TABLE inventory ADD COLUMN IF NOT EXISTS low_stock_threshold INTEGER`)
+_, _ = db.Exec(`ALTER TABLE inventory ADD COLUMN IF NOT EXISTS reorder_point INTEGER`)
+_, _ = db.Exec(`ALTER TABLE inventory ADD COLUMN IF NOT EXISTS stock_alert VARCHAR(20) NOT NULL DEFAULT ''`)
+
 // Create product_tags table
 _, err = db.Exec(`
 CREATE TABLE IF NOT EXISTS product_tags (
@@ -86,13 +183,87 @@ if err != nil {
 return err
 }
 
-// Create product_similarities table
+// Create product_similarities table. kind is bought_together for products
+// bought in the same order and also_bought for products bought by the same
+// customers; support is how many orders or customers bought both.
 _, err = db.Exec(`
 CREATE TABLE IF NOT EXISTS product_similarities (
 id VARCHAR(36) PRIMARY KEY,
 product_id VARCHAR(36) REFERENCES products(id),
 similar_product_id VARCHAR(36) REFERENCES products(id),
 similarity_score DECIMAL(5, 4) NOT NULL,
+kind VARCHAR(20) NOT NULL DEFAULT 'bought_together',
+support INTEGER NOT NULL DEFAULT 0,
+created_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+_, _ = db.Exec(`ALTER TABLE product_similarities ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'bought_together'`)
+_, _ = db.Exec(`ALTER TABLE product_similarities ADD COLUMN IF NOT EXISTS support INTEGER NOT NULL DEFAULT 0`)
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_product_similarities_product ON product_similarities(product_id, kind)`)
+if err != nil {
+return err
+}
+
+// Create order_purchases table, the products bought in each order, from
+// the order events. Similarities are computed from it.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS order_purchases (
+order_id VARCHAR(36) NOT NULL,
+product_id VARCHAR(36) NOT NULL,
+customer_id VARCHAR(36) NOT NULL,
+completed BOOLEAN NOT NULL DEFAULT FALSE,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (order_id, product_id)
+)
+`)
+if err != nil {
+return err
+}
+
+// Create warehouses table
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS warehouses (
+id VARCHAR(36) PRIMARY KEY,
+code VARCHAR(20) NOT NULL UNIQUE,
+name VARCHAR(100) NOT NULL,
+country VARCHAR(2) NOT NULL,
+province VARCHAR(100) NOT NULL DEFAULT '',
+city VARCHAR(100) NOT NULL DEFAULT '',
+is_default BOOLEAN NOT NULL DEFAULT FALSE,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+
+// Create stock_levels table holding the stock of each product per
+// warehouse. inventory.quantity is kept as the total across warehouses.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS stock_levels (
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+quantity INTEGER NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+PRIMARY KEY (product_id, warehouse_id)
+)
+`)
+if err != nil {
+return err
+}
+
+// Create stock_transfers table
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS stock_transfers (
+id VARCHAR(36) PRIMARY KEY,
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+from_warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+to_warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+quantity INTEGER NOT NULL,
 created_at TIMESTAMP NOT NULL
 )
 `)
@@ -100,6 +271,197 @@ if err != nil {
 return err
 }
 
+// Create stock_reservations table recording which warehouses the stock of
+// an order was taken from, so it can be put back there
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS stock_reservations (
+id VARCHAR(36) PRIMARY KEY,
+order_id VARCHAR(36) NOT NULL,
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+warehouse_id VARCHAR(36) NOT NULL REFERENCES warehouses(id),
+quantity INTEGER NOT NULL,
+created_at TIMESTAMP NOT NULL,
+committed_at TIMESTAMP,
+released_at TIMESTAMP
+)
+`)
+if err != nil {
+return err
+}
+_, _ = db.Exec(`ALTER TABLE stock_reservations ADD COLUMN IF NOT EXISTS committed_at TIMESTAMP`)
+
+// Create order_reservations table with a row per order stock was reserved
+// for, so an order is reserved once however many requests race for it
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS order_reservations (
+order_id VARCHAR(36) PRIMARY KEY,
+created_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+
+// Create stock_movements table, the append-only ledger of every change to
+// stock_levels. It has no foreign keys so the history outlives products.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS stock_movements (
+id VARCHAR(36) PRIMARY KEY,
+product_id VARCHAR(36) NOT NULL,
+warehouse_id VARCHAR(36) NOT NULL,
+type VARCHAR(20) NOT NULL,
+quantity INTEGER NOT NULL,
+reference VARCHAR(100) NOT NULL DEFAULT '',
+created_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id, created_at)`)
+if err != nil {
+return err
+}
+
+// Create backorders table, the queue of order lines waiting for stock of
+// backorder and pre-order products. closed_at is set once a backorder is
+// fulfilled or cancelled.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS backorders (
+id VARCHAR(36) PRIMARY KEY,
+order_id VARCHAR(36) NOT NULL,
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+quantity INTEGER NOT NULL,
+requested INTEGER NOT NULL,
+availability VARCHAR(20) NOT NULL,
+country VARCHAR(2) NOT NULL DEFAULT '',
+province VARCHAR(100) NOT NULL DEFAULT '',
+city VARCHAR(100) NOT NULL DEFAULT '',
+created_at TIMESTAMP NOT NULL,
+committed_at TIMESTAMP,
+closed_at TIMESTAMP
+)
+`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_backorders_product_id ON backorders(product_id, created_at)`)
+if err != nil {
+return err
+}
+
+// Orders reserved before order_reservations existed
+_, err = db.Exec(`
+INSERT INTO order_reservations (order_id, created_at)
+SELECT order_id, MIN(created_at) FROM (
+SELECT order_id, created_at FROM stock_reservations
+UNION ALL
+SELECT order_id, created_at FROM backorders
+) reserved WHERE order_id <> '' GROUP BY order_id
+ON CONFLICT (order_id) DO NOTHING
+`)
+if err != nil {
+return err
+}
+
+// Create categories table, the category tree products are filed in.
+// Top-level categories have no parent.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS categories (
+id VARCHAR(36) PRIMARY KEY,
+parent_id VARCHAR(36) REFERENCES categories(id),
+name VARCHAR(100) NOT NULL,
+slug VARCHAR(100) NOT NULL UNIQUE,
+position INTEGER NOT NULL DEFAULT 0,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id)`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id)`)
+if err != nil {
+return err
+}
+
+// Create import_jobs table, the progress of catalogue imports. errors holds
+// the failed rows as JSON.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS import_jobs (
+id VARCHAR(36) PRIMARY KEY,
+format VARCHAR(10) NOT NULL,
+dry_run BOOLEAN NOT NULL,
+status VARCHAR(20) NOT NULL,
+total_rows INTEGER NOT NULL,
+processed_rows INTEGER NOT NULL DEFAULT 0,
+created_count INTEGER NOT NULL DEFAULT 0,
+updated_count INTEGER NOT NULL DEFAULT 0,
+failed_count INTEGER NOT NULL DEFAULT 0,
+errors TEXT NOT NULL DEFAULT '[]',
+reason TEXT NOT NULL DEFAULT '',
+caller VARCHAR(100) NOT NULL DEFAULT '',
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+finished_at TIMESTAMP
+)
+`)
+if err != nil {
+return err
+}
+
+// Create price_schedules table, the prices products are to sell at. The
+// scheduler starts schedules at starts_at and ends sales at ends_at.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS price_schedules (
+id VARCHAR(36) PRIMARY KEY,
+product_id VARCHAR(36) NOT NULL REFERENCES products(id),
+price DECIMAL(10, 2) NOT NULL,
+starts_at TIMESTAMP NOT NULL,
+ends_at TIMESTAMP,
+status VARCHAR(20) NOT NULL,
+caller VARCHAR(100) NOT NULL DEFAULT '',
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_price_schedules_product_id ON price_schedules(product_id, starts_at)`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_price_schedules_status ON price_schedules(status, starts_at)`)
+if err != nil {
+return err
+}
+
+// Create price_history table, the append-only history of the prices
+// products sold at. Like the stock ledger, it has no foreign keys.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS price_history (
+id VARCHAR(36) PRIMARY KEY,
+product_id VARCHAR(36) NOT NULL,
+price DECIMAL(10, 2) NOT NULL,
+previous_price DECIMAL(10, 2) NOT NULL,
+reason VARCHAR(20) NOT NULL,
+reference VARCHAR(100) NOT NULL DEFAULT '',
+changed_at TIMESTAMP NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_price_history_product_id ON price_history(product_id, changed_at)`)
+if err != nil {
+return err
+}
+
 log.Println("Database tables created or already exist")
 return nil
 }
@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,6 +13,17 @@ import (
 	"github.com/online-order-system/inventory-service/models"
 )
 
+// ErrInsufficientStock is returned when a warehouse doesn't have the stock
+// taken from it
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrAlreadyReserved is returned when stock was already reserved for an order
+var ErrAlreadyReserved = errors.New("stock already reserved for order")
+
+// ErrCategoryNotEmpty is returned when deleting a category that still has
+// products
+var ErrCategoryNotEmpty = errors.New("category has products")
+
 // InventoryRepository handles database operations for inventory
 type InventoryRepository struct {
 	db *Database
@@ -21,8 +34,10 @@ func NewInventoryRepository(db *Database) *InventoryRepository {
 	return &InventoryRepository{db: db}
 }
 
-// CreateProduct creates a new product in the database with initial inventory
-func (r *InventoryRepository) CreateProduct(product models.Product, initialQuantity int) error {
+// CreateProduct creates a new product in the database with initial inventory.
+// The initial quantity is recorded as a receipt and the price as the start of
+// the price history by reference.
+func (r *InventoryRepository) CreateProduct(product models.Product, initialQuantity int, reference string) error {
 	// Begin transaction
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -32,13 +47,26 @@ func (r *InventoryRepository) CreateProduct(product models.Product, initialQuant
 
 	// Insert product
 	_, err = tx.Exec(
-		"INSERT INTO products (id, name, description, category_id, price, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-		product.ID, product.Name, product.Description, product.CategoryID, product.Price, product.CreatedAt, product.UpdatedAt,
+		"INSERT INTO products (id, sku, name, description, category_id, price, availability, available_at, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		product.ID, product.Code, product.Name, product.Description, product.CategoryID, product.Price, product.Availability, product.AvailableAt,
+		product.Status, product.CreatedAt, product.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
+	// Insert variant options
+	err = saveOptions(tx, product.ID, product.Options)
+	if err != nil {
+		return err
+	}
+
+	// Start the price history
+	err = recordPriceChange(tx, product.ID, product.Price, 0, models.PriceChangeCreated, reference, product.CreatedAt)
+	if err != nil {
+		return err
+	}
+
 	// Insert inventory with specified initial quantity
 	_, err = tx.Exec(
 		"INSERT INTO inventory (product_id, quantity, updated_at) VALUES ($1, $2, $3)",
@@ -48,6 +76,18 @@ func (r *InventoryRepository) CreateProduct(product models.Product, initialQuant
 		return err
 	}
 
+	// The initial stock goes to the default warehouse
+	if initialQuantity > 0 {
+		warehouseID, err := defaultWarehouseID(tx)
+		if err != nil {
+			return err
+		}
+		err = adjustStock(tx, product.ID, warehouseID, initialQuantity, models.MovementReceipt, reference, product.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Commit transaction
 	return tx.Commit()
 }
@@ -61,21 +101,28 @@ func (r *InventoryRepository) CreateProductTag(tag models.ProductTag) error {
 	return err
 }
 
-// GetProductByID retrieves a product by ID with its inventory information
+// GetProductByID retrieves a product by ID with its inventory information.
+// SKUs are found by their ID too; they sell like the product they belong to.
 func (r *InventoryRepository) GetProductByID(id string) (models.Product, error) {
 	var product models.Product
 	var createdAt, updatedAt time.Time
+	var availableAt, saleEndsAt sql.NullTime
+	var wasPrice sql.NullFloat64
 
 	// Get product with inventory information using JOIN
 	err := r.db.QueryRow(
-		`SELECT p.id, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
-		COALESCE(i.quantity, 0) as quantity
+		`SELECT p.id, p.sku, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
+		COALESCE(parent.availability, p.availability), COALESCE(parent.available_at, p.available_at),
+		COALESCE(parent.status, p.status), p.was_price, p.sale_ends_at,
+		COALESCE(i.quantity, 0) as quantity, COALESCE(p.parent_id, '')
 		FROM products p
+		LEFT JOIN products parent ON parent.id = p.parent_id
 		LEFT JOIN inventory i ON p.id = i.product_id
 		WHERE p.id = $1`,
 		id,
-	).Scan(&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Price,
-		&createdAt, &updatedAt, &product.Quantity)
+	).Scan(&product.ID, &product.Code, &product.Name, &product.Description, &product.CategoryID, &product.Price,
+		&createdAt, &updatedAt, &product.Availability, &availableAt, &product.Status, &wasPrice, &saleEndsAt,
+		&product.Quantity, &product.ParentID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return product, fmt.Errorf("product with ID %s not found", id)
@@ -85,6 +132,9 @@ func (r *InventoryRepository) GetProductByID(id string) (models.Product, error)
 
 	product.CreatedAt = createdAt
 	product.UpdatedAt = updatedAt
+	product.AvailableAt = timePtr(availableAt)
+	product.WasPrice = floatPtr(wasPrice)
+	product.SaleEndsAt = timePtr(saleEndsAt)
 
 	// Get product tags
 	rows, err := r.db.Query("SELECT tag FROM product_tags WHERE product_id = $1", id)
@@ -103,6 +153,11 @@ func (r *InventoryRepository) GetProductByID(id string) (models.Product, error)
 		product.Tags = tags
 	}
 
+	product.Options, err = r.getOptions(id)
+	if err != nil {
+		return product, err
+	}
+
 	return product, nil
 }
 
@@ -110,10 +165,11 @@ func (r *InventoryRepository) GetProductByID(id string) (models.Product, error)
 func (r *InventoryRepository) GetProducts() ([]models.Product, error) {
 	// Get all products with inventory information using JOIN
 	rows, err := r.db.Query(
-		`SELECT p.id, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
-		COALESCE(i.quantity, 0) as quantity
+		`SELECT p.id, p.sku, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
+		p.availability, p.available_at, p.status, p.was_price, p.sale_ends_at, COALESCE(i.quantity, 0) as quantity
 		FROM products p
-		LEFT JOIN inventory i ON p.id = i.product_id`,
+		LEFT JOIN inventory i ON p.id = i.product_id
+		WHERE p.parent_id IS NULL`,
 	)
 	if err != nil {
 		return nil, err
@@ -124,15 +180,20 @@ func (r *InventoryRepository) GetProducts() ([]models.Product, error) {
 	for rows.Next() {
 		var product models.Product
 		var createdAt, updatedAt time.Time
+		var availableAt, saleEndsAt sql.NullTime
+		var wasPrice sql.NullFloat64
 
-		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Price,
-			&createdAt, &updatedAt, &product.Quantity)
+		err := rows.Scan(&product.ID, &product.Code, &product.Name, &product.Description, &product.CategoryID, &product.Price,
+			&createdAt, &updatedAt, &product.Availability, &availableAt, &product.Status, &wasPrice, &saleEndsAt, &product.Quantity)
 		if err != nil {
 			return nil, err
 		}
 
 		product.CreatedAt = createdAt
 		product.UpdatedAt = updatedAt
+		product.AvailableAt = timePtr(availableAt)
+		product.WasPrice = floatPtr(wasPrice)
+		product.SaleEndsAt = timePtr(saleEndsAt)
 
 		// Get product tags
 		tagRows, err := r.db.Query("SELECT tag FROM product_tags WHERE product_id = $1", product.ID)
@@ -148,46 +209,98 @@ func (r *InventoryRepository) GetProducts() ([]models.Product, error) {
 			product.Tags = tags
 		}
 
+		product.Options, err = r.getOptions(product.ID)
+		if err != nil {
+			return nil, err
+		}
+
 		products = append(products, product)
 	}
 
 	return products, nil
 }
 
-// UpdateProduct updates a product and its inventory in the database
-func (r *InventoryRepository) UpdateProduct(id string, product models.Product) error {
+// UpdateProduct updates a product in the database. A price other than 0 is
+// the new regular price: the selling price, or the was price while the
+// product is on sale. The product row is locked while the price is set, so
+// price schedules starting and ending meanwhile aren't overwritten. When
+// quantity is set, the stock is adjusted to it and the change recorded as an
+// adjustment. Changes to the selling price are recorded in the price history
+// and returned; both name reference.
+func (r *InventoryRepository) UpdateProduct(id string, product models.Product, price float64, quantity *int, reference string) (models.PriceChange, error) {
 	// Begin transaction
 	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return models.PriceChange{}, err
 	}
 	defer tx.Rollback()
 
+	change := models.PriceChange{
+		ProductID: id,
+		Reason:    models.PriceChangeUpdated,
+		Reference: reference,
+		ChangedAt: product.UpdatedAt,
+	}
+	var wasPrice sql.NullFloat64
+	err = tx.QueryRow("SELECT price, was_price FROM products WHERE id = $1"+r.db.ForUpdate(), id).Scan(&change.PreviousPrice, &wasPrice)
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	change.Price = change.PreviousPrice
+	if price != 0 {
+		if wasPrice.Valid {
+			// On sale, the price goes back to the new price when the sale ends
+			wasPrice.Float64 = price
+		} else {
+			change.Price = price
+		}
+	}
+
 	// Update product
 	_, err = tx.Exec(
-		"UPDATE products SET name = $1, description = $2, category_id = $3, price = $4, updated_at = $5 WHERE id = $6",
-		product.Name, product.Description, product.CategoryID, product.Price, product.UpdatedAt, id,
+		"UPDATE products SET sku = $1, name = $2, description = $3, category_id = $4, price = $5, was_price = $6, availability = $7, available_at = $8, status = $9, updated_at = $10 WHERE id = $11",
+		product.Code, product.Name, product.Description, product.CategoryID, change.Price, wasPrice, product.Availability, product.AvailableAt,
+		product.Status, product.UpdatedAt, id,
 	)
 	if err != nil {
-		return err
+		return models.PriceChange{}, err
+	}
+	if change.Price != change.PreviousPrice {
+		err = recordPriceChange(tx, id, change.Price, change.PreviousPrice, change.Reason, change.Reference, change.ChangedAt)
+		if err != nil {
+			return models.PriceChange{}, err
+		}
 	}
 
-	// Update inventory - use upsert to handle both insert and update cases
+	// Update inventory. The quantity is the total across warehouses, so a
+	// change to it is made in the default warehouse.
 	_, err = tx.Exec(
 		`INSERT INTO inventory (product_id, quantity, updated_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (product_id)
-		DO UPDATE SET quantity = $2, updated_at = $3`,
-		id, product.Quantity, product.UpdatedAt,
+		VALUES ($1, 0, $2)
+		ON CONFLICT (product_id) DO NOTHING`,
+		id, product.UpdatedAt,
 	)
 	if err != nil {
-		return err
+		return models.PriceChange{}, err
+	}
+
+	if quantity != nil {
+		err = r.setQuantity(tx, id, *quantity, reference, product.UpdatedAt)
+		if err != nil {
+			return models.PriceChange{}, err
+		}
+	}
+
+	// Replace variant options
+	err = saveOptions(tx, id, product.Options)
+	if err != nil {
+		return models.PriceChange{}, err
 	}
 
 	// Delete existing tags
 	_, err = tx.Exec("DELETE FROM product_tags WHERE product_id = $1", id)
 	if err != nil {
-		return err
+		return models.PriceChange{}, err
 	}
 
 	// Insert new tags
@@ -198,13 +311,13 @@ func (r *InventoryRepository) UpdateProduct(id string, product models.Product) e
 				GenerateID(), id, tag, time.Now(),
 			)
 			if err != nil {
-				return err
+				return models.PriceChange{}, err
 			}
 		}
 	}
 
 	// Commit transaction
-	return tx.Commit()
+	return change, tx.Commit()
 }
 
 // DeleteProduct deletes a product from the database
@@ -216,6 +329,14 @@ func (r *InventoryRepository) DeleteProduct(id string) error {
 	}
 	defer tx.Rollback()
 
+	// Delete stock in every warehouse, with its history
+	for _, table := range []string{"stock_reservations", "stock_transfers", "stock_levels", "backorders"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE product_id = $1", id)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Delete inventory
 	_, err = tx.Exec("DELETE FROM inventory WHERE product_id = $1", id)
 	if err != nil {
@@ -228,6 +349,22 @@ func (r *InventoryRepository) DeleteProduct(id string) error {
 		return err
 	}
 
+	// Delete similarities, the product can't be recommended any more
+	_, err = tx.Exec("DELETE FROM product_similarities WHERE product_id = $1 OR similar_product_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Delete variant options of the product, or option values of the SKU
+	_, err = tx.Exec("DELETE FROM product_options WHERE product_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM sku_options WHERE sku_id = $1", id)
+	if err != nil {
+		return err
+	}
+
 	// Delete product
 	_, err = tx.Exec("DELETE FROM products WHERE id = $1", id)
 	if err != nil {
@@ -243,22 +380,26 @@ func (r *InventoryRepository) DeleteProduct(id string) error {
 // CheckInventory checks if all items are available in inventory
 func (r *InventoryRepository) CheckInventory(items []struct {
 	ProductID string `json:"product_id"`
+	SKUID     string `json:"sku_id,omitempty"`
 	Quantity  int    `json:"quantity"`
 }) (bool, []struct {
 	ProductID   string `json:"product_id"`
+	SKUID       string `json:"sku_id,omitempty"`
 	ProductName string `json:"product_name"`
 	Requested   int    `json:"requested"`
 	Available   int    `json:"available"`
 }, error) {
 	var unavailableItems []struct {
 		ProductID   string `json:"product_id"`
+		SKUID       string `json:"sku_id,omitempty"`
 		ProductName string `json:"product_name"`
 		Requested   int    `json:"requested"`
 		Available   int    `json:"available"`
 	}
 
 	for _, item := range items {
-		// Get product with inventory information using JOIN
+		// Get product with inventory information using JOIN. The stock of
+		// a SKU is kept under the SKU.
 		var productName string
 		var quantity int
 		err := r.db.QueryRow(
@@ -266,11 +407,11 @@ func (r *InventoryRepository) CheckInventory(items []struct {
 			FROM products p
 			LEFT JOIN inventory i ON p.id = i.product_id
 			WHERE p.id = $1`,
-			item.ProductID,
+			models.StockID(item.ProductID, item.SKUID),
 		).Scan(&productName, &quantity)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return false, nil, fmt.Errorf("product with ID %s not found", item.ProductID)
+				return false, nil, fmt.Errorf("product with ID %s not found", models.StockID(item.ProductID, item.SKUID))
 			}
 			return false, nil, fmt.Errorf("error getting product: %w", err)
 		}
@@ -279,11 +420,13 @@ func (r *InventoryRepository) CheckInventory(items []struct {
 		if quantity < item.Quantity {
 			unavailableItems = append(unavailableItems, struct {
 				ProductID   string `json:"product_id"`
+				SKUID       string `json:"sku_id,omitempty"`
 				ProductName string `json:"product_name"`
 				Requested   int    `json:"requested"`
 				Available   int    `json:"available"`
 			}{
 				ProductID:   item.ProductID,
+				SKUID:       item.SKUID,
 				ProductName: productName,
 				Requested:   item.Quantity,
 				Available:   quantity,
@@ -294,17 +437,32 @@ func (r *InventoryRepository) CheckInventory(items []struct {
 	return len(unavailableItems) == 0, unavailableItems, nil
 }
 
-// GetProductsByCategory retrieves products by category ID with their inventory information
-func (r *InventoryRepository) GetProductsByCategory(categoryID string, limit int) ([]models.Product, error) {
+// GetProductsByCategory retrieves the active products in any of the
+// categories with their inventory information
+func (r *InventoryRepository) GetProductsByCategory(categoryIDs []string, limit int) ([]models.Product, error) {
+	if len(categoryIDs) == 0 {
+		return nil, nil
+	}
+
+	// Create placeholders for categories
+	var placeholders []string
+	var args []any
+	for i, categoryID := range categoryIDs {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		args = append(args, categoryID)
+	}
+	args = append(args, limit)
+
 	// Get products by category with inventory information using JOIN
 	rows, err := r.db.Query(
 		`SELECT p.id, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
-		COALESCE(i.quantity, 0) as quantity
+		p.availability, p.available_at, p.status, p.was_price, p.sale_ends_at, COALESCE(i.quantity, 0) as quantity
 		FROM products p
 		LEFT JOIN inventory i ON p.id = i.product_id
-		WHERE p.category_id = $1
-		LIMIT $2`,
-		categoryID, limit,
+		WHERE p.parent_id IS NULL AND p.status = '`+models.ProductStatusActive+`'
+		AND p.category_id IN (`+strings.Join(placeholders, ",")+`)
+		LIMIT $`+strconv.Itoa(len(categoryIDs)+1),
+		args...,
 	)
 	if err != nil {
 		return nil, err
@@ -315,15 +473,20 @@ func (r *InventoryRepository) GetProductsByCategory(categoryID string, limit int
 	for rows.Next() {
 		var product models.Product
 		var createdAt, updatedAt time.Time
+		var availableAt, saleEndsAt sql.NullTime
+		var wasPrice sql.NullFloat64
 
 		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Price,
-			&createdAt, &updatedAt, &product.Quantity)
+			&createdAt, &updatedAt, &product.Availability, &availableAt, &product.Status, &wasPrice, &saleEndsAt, &product.Quantity)
 		if err != nil {
 			return nil, err
 		}
 
 		product.CreatedAt = createdAt
 		product.UpdatedAt = updatedAt
+		product.AvailableAt = timePtr(availableAt)
+		product.WasPrice = floatPtr(wasPrice)
+		product.SaleEndsAt = timePtr(saleEndsAt)
 
 		// Get product tags
 		tagRows, err := r.db.Query("SELECT tag FROM product_tags WHERE product_id = $1", product.ID)
@@ -350,11 +513,11 @@ func (r *InventoryRepository) GetProductsByTags(tags []string, limit int) ([]mod
 	// Get products by tags with inventory information using JOIN
 	query := `
 		SELECT p.id, p.name, p.description, p.category_id, p.price, p.created_at, p.updated_at,
-		COALESCE(i.quantity, 0) as quantity
+		p.availability, p.available_at, COALESCE(i.quantity, 0) as quantity
 		FROM products p
 		JOIN product_tags t ON p.id = t.product_id
 		LEFT JOIN inventory i ON p.id = i.product_id
-		WHERE t.tag IN (`
+		WHERE p.parent_id IS NULL AND t.tag IN (`
 
 	// Create placeholders for tags
 	var placeholders []string
@@ -377,15 +540,17 @@ func (r *InventoryRepository) GetProductsByTags(tags []string, limit int) ([]mod
 	for rows.Next() {
 		var product models.Product
 		var createdAt, updatedAt time.Time
+		var availableAt sql.NullTime
 
 		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Price,
-			&createdAt, &updatedAt, &product.Quantity)
+			&createdAt, &updatedAt, &product.Availability, &availableAt, &product.Quantity)
 		if err != nil {
 			return nil, err
 		}
 
 		product.CreatedAt = createdAt
 		product.UpdatedAt = updatedAt
+		product.AvailableAt = timePtr(availableAt)
 
 		// Get product tags
 		tagRows, err := r.db.Query("SELECT tag FROM product_tags WHERE product_id = $1", product.ID)
@@ -407,6 +572,2000 @@ func (r *InventoryRepository) GetProductsByTags(tags []string, limit int) ([]mod
 	return products, nil
 }
 
+// EnsureDefaultWarehouse creates the default warehouse when there are no
+// warehouses yet, and moves stock recorded before there were warehouses
+// into it
+func (r *InventoryRepository) EnsureDefaultWarehouse(warehouse models.Warehouse) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM warehouses").Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		warehouse.IsDefault = true
+		err = insertWarehouse(tx, warehouse)
+		if err != nil {
+			return err
+		}
+	}
+
+	warehouseID, err := defaultWarehouseID(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO stock_levels (product_id, warehouse_id, quantity, updated_at)
+		SELECT product_id, $1, quantity, updated_at FROM inventory
+		WHERE quantity > 0 AND product_id NOT IN (SELECT product_id FROM stock_levels)`,
+		warehouseID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Stock recorded before there was a ledger gets an opening balance, so
+	// the ledger adds up to it
+	rows, err := tx.Query(
+		`SELECT s.product_id, s.warehouse_id, s.quantity FROM stock_levels s
+		WHERE s.quantity <> 0 AND NOT EXISTS (
+		SELECT 1 FROM stock_movements m WHERE m.product_id = s.product_id AND m.warehouse_id = s.warehouse_id)`,
+	)
+	if err != nil {
+		return err
+	}
+	var openings []models.StockMovement
+	for rows.Next() {
+		var movement models.StockMovement
+		if err := rows.Scan(&movement.ProductID, &movement.WarehouseID, &movement.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		openings = append(openings, movement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, movement := range openings {
+		err = recordMovement(tx, movement.ProductID, movement.WarehouseID, models.MovementAdjustment, movement.Quantity, "opening balance", warehouse.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateWarehouse creates a new warehouse in the database
+func (r *InventoryRepository) CreateWarehouse(warehouse models.Warehouse) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertWarehouse(tx, warehouse)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWarehouses retrieves all warehouses, the default one first
+func (r *InventoryRepository) GetWarehouses() ([]models.Warehouse, error) {
+	rows, err := r.db.Query(
+		"SELECT id, code, name, country, province, city, is_default, created_at, updated_at FROM warehouses ORDER BY is_default DESC, code",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+	for rows.Next() {
+		var warehouse models.Warehouse
+		err := rows.Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &warehouse.Country, &warehouse.Province,
+			&warehouse.City, &warehouse.IsDefault, &warehouse.CreatedAt, &warehouse.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+
+	return warehouses, rows.Err()
+}
+
+// GetWarehouseByID retrieves a warehouse by ID
+func (r *InventoryRepository) GetWarehouseByID(id string) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	err := r.db.QueryRow(
+		"SELECT id, code, name, country, province, city, is_default, created_at, updated_at FROM warehouses WHERE id = $1",
+		id,
+	).Scan(&warehouse.ID, &warehouse.Code, &warehouse.Name, &warehouse.Country, &warehouse.Province,
+		&warehouse.City, &warehouse.IsDefault, &warehouse.CreatedAt, &warehouse.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return warehouse, fmt.Errorf("warehouse with ID %s not found", id)
+		}
+		return warehouse, fmt.Errorf("error getting warehouse: %w", err)
+	}
+
+	return warehouse, nil
+}
+
+// UpdateWarehouse updates a warehouse in the database. Making it the default
+// takes the flag from the previous default warehouse.
+func (r *InventoryRepository) UpdateWarehouse(warehouse models.Warehouse) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if warehouse.IsDefault {
+		_, err = tx.Exec("UPDATE warehouses SET is_default = FALSE WHERE id <> $1", warehouse.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE warehouses SET name = $1, country = $2, province = $3, city = $4, is_default = $5, updated_at = $6 WHERE id = $7",
+		warehouse.Name, warehouse.Country, warehouse.Province, warehouse.City, warehouse.IsDefault, warehouse.UpdatedAt, warehouse.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetStockByProduct retrieves the stock of a product in every warehouse
+// holding it
+func (r *InventoryRepository) GetStockByProduct(productID string) ([]models.StockLevel, error) {
+	return r.getStockLevels(
+		`SELECT s.product_id, s.warehouse_id, w.code, s.quantity, s.updated_at
+		FROM stock_levels s
+		JOIN warehouses w ON w.id = s.warehouse_id
+		WHERE s.product_id = $1
+		ORDER BY w.code`,
+		productID,
+	)
+}
+
+// GetStockByWarehouse retrieves the stock of every product in a warehouse
+func (r *InventoryRepository) GetStockByWarehouse(warehouseID string) ([]models.StockLevel, error) {
+	return r.getStockLevels(
+		`SELECT s.product_id, s.warehouse_id, w.code, s.quantity, s.updated_at
+		FROM stock_levels s
+		JOIN warehouses w ON w.id = s.warehouse_id
+		WHERE s.warehouse_id = $1
+		ORDER BY s.product_id`,
+		warehouseID,
+	)
+}
+
+// getStockLevels retrieves the stock levels a query selects
+func (r *InventoryRepository) getStockLevels(query string, args ...any) ([]models.StockLevel, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []models.StockLevel
+	for rows.Next() {
+		var level models.StockLevel
+		err := rows.Scan(&level.ProductID, &level.WarehouseID, &level.WarehouseCode, &level.Quantity, &level.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// SetStock sets the stock of a product in a warehouse. The difference to the
+// current stock is recorded as an adjustment by reference.
+func (r *InventoryRepository) SetStock(productID, warehouseID string, quantity int, reference string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the stock level, creating it empty when the product has none in
+	// the warehouse yet, so a concurrent change can't slip in between
+	// reading it and adjusting it
+	_, err = tx.Exec(
+		`INSERT INTO stock_levels (product_id, warehouse_id, quantity, updated_at)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (product_id, warehouse_id) DO NOTHING`,
+		productID, warehouseID, now,
+	)
+	if err != nil {
+		return err
+	}
+	var current int
+	err = tx.QueryRow(
+		"SELECT quantity FROM stock_levels WHERE product_id = $1 AND warehouse_id = $2"+r.db.ForUpdate(),
+		productID, warehouseID,
+	).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if quantity == current {
+		return nil
+	}
+
+	err = adjustStock(tx, productID, warehouseID, quantity-current, models.MovementAdjustment, reference, now)
+	if err != nil {
+		return err
+	}
+
+	err = syncTotal(tx, productID, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TransferStock moves stock from one warehouse to another, recorded as a
+// transfer by reference. It fails with ErrInsufficientStock when the source
+// warehouse doesn't have the stock.
+func (r *InventoryRepository) TransferStock(transfer models.StockTransfer, reference string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = adjustStock(tx, transfer.ProductID, transfer.FromWarehouseID, -transfer.Quantity, models.MovementTransfer, reference, transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+	err = adjustStock(tx, transfer.ProductID, transfer.ToWarehouseID, transfer.Quantity, models.MovementTransfer, reference, transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO stock_transfers (id, product_id, from_warehouse_id, to_warehouse_id, quantity, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		transfer.ID, transfer.ProductID, transfer.FromWarehouseID, transfer.ToWarehouseID, transfer.Quantity, transfer.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTransfers retrieves the transfers of a product, or of every product
+// when productID is empty, newest first
+func (r *InventoryRepository) GetTransfers(productID string) ([]models.StockTransfer, error) {
+	query := "SELECT id, product_id, from_warehouse_id, to_warehouse_id, quantity, created_at FROM stock_transfers"
+	var args []any
+	if productID != "" {
+		query += " WHERE product_id = $1"
+		args = append(args, productID)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.StockTransfer
+	for rows.Next() {
+		var transfer models.StockTransfer
+		err := rows.Scan(&transfer.ID, &transfer.ProductID, &transfer.FromWarehouseID, &transfer.ToWarehouseID,
+			&transfer.Quantity, &transfer.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// GetStockForProducts retrieves the stock of products per warehouse, keyed by
+// product ID and then warehouse ID
+func (r *InventoryRepository) GetStockForProducts(productIDs []string) (map[string]map[string]int, error) {
+	stock := make(map[string]map[string]int)
+	if len(productIDs) == 0 {
+		return stock, nil
+	}
+
+	var placeholders []string
+	var args []any
+	for i, id := range productIDs {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		args = append(args, id)
+	}
+
+	rows, err := r.db.Query(
+		"SELECT product_id, warehouse_id, quantity FROM stock_levels WHERE quantity > 0 AND product_id IN ("+strings.Join(placeholders, ",")+")",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, warehouseID string
+		var quantity int
+		if err := rows.Scan(&productID, &warehouseID, &quantity); err != nil {
+			return nil, err
+		}
+		if stock[productID] == nil {
+			stock[productID] = make(map[string]int)
+		}
+		stock[productID][warehouseID] = quantity
+	}
+
+	return stock, rows.Err()
+}
+
+// GetReservations retrieves the stock reserved for an order that hasn't been
+// released
+func (r *InventoryRepository) GetReservations(orderID string) ([]models.StockAllocation, error) {
+	rows, err := r.db.Query(
+		`SELECT r.product_id, p.parent_id, r.warehouse_id, r.quantity
+		FROM stock_reservations r
+		LEFT JOIN products p ON p.id = r.product_id
+		WHERE r.order_id = $1 AND r.released_at IS NULL
+		ORDER BY r.created_at, r.id`,
+		orderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []models.StockAllocation
+	for rows.Next() {
+		var allocation models.StockAllocation
+		var stockID string
+		var parentID sql.NullString
+		if err := rows.Scan(&stockID, &parentID, &allocation.WarehouseID, &allocation.Quantity); err != nil {
+			return nil, err
+		}
+		allocation.ProductID, allocation.SKUID = splitStockID(stockID, parentID)
+		allocations = append(allocations, allocation)
+	}
+
+	return allocations, rows.Err()
+}
+
+// HasReservations reports whether stock was ever reserved or backordered for
+// an order, including stock released and backorders cancelled since
+func (r *InventoryRepository) HasReservations(orderID string) (bool, error) {
+	var reservations, backorders int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM stock_reservations WHERE order_id = $1", orderID).Scan(&reservations)
+	if err != nil {
+		return false, err
+	}
+	err = r.db.QueryRow("SELECT COUNT(*) FROM backorders WHERE order_id = $1", orderID).Scan(&backorders)
+	return reservations+backorders > 0, err
+}
+
+// ReserveStock takes the stock of an order from the warehouses it was
+// allocated to and queues the backorders of the order. Nothing is taken when
+// one of the warehouses no longer has the stock, in which case it fails with
+// ErrInsufficientStock, and when the order was reserved before, in which case
+// it fails with ErrAlreadyReserved.
+func (r *InventoryRepository) ReserveStock(orderID string, allocations []models.StockAllocation, backorders []models.Backorder, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Claim the order first. A concurrent reservation of it waits here until
+	// this one is done, then finds it claimed.
+	result, err := tx.Exec(
+		"INSERT INTO order_reservations (order_id, created_at) VALUES ($1, $2) ON CONFLICT (order_id) DO NOTHING",
+		orderID, now,
+	)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return ErrAlreadyReserved
+	}
+
+	err = reserveAllocations(tx, orderID, allocations, false, now)
+	if err != nil {
+		return err
+	}
+
+	for _, backorder := range backorders {
+		_, err = tx.Exec(
+			`INSERT INTO backorders (id, order_id, product_id, quantity, requested, availability, country, province, city, created_at)
+			VALUES ($1, $2, $3, $4, $4, $5, $6, $7, $8, $9)`,
+			backorder.ID, backorder.OrderID, models.StockID(backorder.ProductID, backorder.SKUID), backorder.Quantity, backorder.Availability,
+			backorder.ShippingAddress.Country, backorder.ShippingAddress.Province, backorder.ShippingAddress.City, backorder.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetWaitingItems retrieves the open backorders of an order with the
+// availability of their products
+func (r *InventoryRepository) GetWaitingItems(orderID string) ([]models.WaitingItem, error) {
+	rows, err := r.db.Query(
+		`SELECT b.product_id, p.parent_id, b.quantity, b.availability, COALESCE(parent.available_at, p.available_at)
+		FROM backorders b
+		JOIN products p ON p.id = b.product_id
+		LEFT JOIN products parent ON parent.id = p.parent_id
+		WHERE b.order_id = $1 AND b.closed_at IS NULL
+		ORDER BY b.created_at, b.id`,
+		orderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.WaitingItem
+	for rows.Next() {
+		var item models.WaitingItem
+		var stockID string
+		var parentID sql.NullString
+		var expectedAt sql.NullTime
+		if err := rows.Scan(&stockID, &parentID, &item.Quantity, &item.Availability, &expectedAt); err != nil {
+			return nil, err
+		}
+		item.ProductID, item.SKUID = splitStockID(stockID, parentID)
+		item.ExpectedAt = timePtr(expectedAt)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetBackorders retrieves the open backorders of a product or SKU in the
+// order they are served, or of every product when productID is empty
+func (r *InventoryRepository) GetBackorders(productID string) ([]models.Backorder, error) {
+	query := `SELECT b.id, b.order_id, b.product_id, p.parent_id, b.quantity, b.requested, b.availability,
+		b.country, b.province, b.city, b.created_at, b.committed_at IS NOT NULL, b.closed_at
+		FROM backorders b
+		LEFT JOIN products p ON p.id = b.product_id
+		WHERE b.closed_at IS NULL`
+	var args []any
+	if productID != "" {
+		query += " AND b.product_id = $1"
+		args = append(args, productID)
+	}
+	query += " ORDER BY b.created_at, b.id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backorders := []models.Backorder{}
+	for rows.Next() {
+		var backorder models.Backorder
+		var stockID string
+		var parentID sql.NullString
+		var closedAt sql.NullTime
+		err := rows.Scan(&backorder.ID, &backorder.OrderID, &stockID, &parentID, &backorder.Quantity,
+			&backorder.Requested, &backorder.Availability, &backorder.ShippingAddress.Country,
+			&backorder.ShippingAddress.Province, &backorder.ShippingAddress.City, &backorder.CreatedAt,
+			&backorder.Committed, &closedAt)
+		if err != nil {
+			return nil, err
+		}
+		backorder.ProductID, backorder.SKUID = splitStockID(stockID, parentID)
+		backorder.ClosedAt = timePtr(closedAt)
+		backorders = append(backorders, backorder)
+	}
+
+	return backorders, rows.Err()
+}
+
+// AllocateBackorder reserves stock for a backorder and takes the units off
+// the queue. The reservation is committed right away when the order was
+// confirmed already. It fails with ErrInsufficientStock when the stock or
+// the backorder changed since they were read.
+func (r *InventoryRepository) AllocateBackorder(backorder models.Backorder, allocations []models.StockAllocation, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	units := 0
+	for _, allocation := range allocations {
+		units += allocation.Quantity
+	}
+	result, err := tx.Exec(
+		`UPDATE backorders SET quantity = quantity - $2, closed_at = CASE WHEN quantity = $2 THEN $3 END
+		WHERE id = $1 AND closed_at IS NULL AND quantity >= $2`,
+		backorder.ID, units, now,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return fmt.Errorf("backorder %s changed: %w", backorder.ID, ErrInsufficientStock)
+	}
+
+	err = reserveAllocations(tx, backorder.OrderID, allocations, backorder.Committed, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelBackorders takes the backorders for a product of an order off the
+// queue and returns how many units were waiting
+func (r *InventoryRepository) CancelBackorders(orderID, productID string, now time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var units int
+	err = tx.QueryRow(
+		"SELECT COALESCE(SUM(quantity), 0) FROM backorders WHERE order_id = $1 AND product_id = $2 AND closed_at IS NULL",
+		orderID, productID,
+	).Scan(&units)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE backorders SET closed_at = $3 WHERE order_id = $1 AND product_id = $2 AND closed_at IS NULL",
+		orderID, productID, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return units, tx.Commit()
+}
+
+// CountWaiting returns the number of units of an order waiting for stock
+func (r *InventoryRepository) CountWaiting(orderID string) (int, error) {
+	var units int
+	err := r.db.QueryRow(
+		"SELECT COALESCE(SUM(quantity), 0) FROM backorders WHERE order_id = $1 AND closed_at IS NULL",
+		orderID,
+	).Scan(&units)
+	return units, err
+}
+
+// ReleaseReservations puts the stock reserved for a product of an order back
+// into the warehouses it was taken from, and returns how much was put back.
+// Stock of committed reservations was sold, so it comes back as a return.
+// Released reservations are kept, so releasing again puts nothing back.
+func (r *InventoryRepository) ReleaseReservations(orderID, productID string, now time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"SELECT id, warehouse_id, quantity, committed_at IS NOT NULL FROM stock_reservations WHERE order_id = $1 AND product_id = $2 AND released_at IS NULL",
+		orderID, productID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type reservation struct {
+		id          string
+		warehouseID string
+		quantity    int
+		committed   bool
+	}
+	var reservations []reservation
+	for rows.Next() {
+		var res reservation
+		if err := rows.Scan(&res.id, &res.warehouseID, &res.quantity, &res.committed); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reservations = append(reservations, res)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, res := range reservations {
+		movementType := models.MovementRelease
+		if res.committed {
+			movementType = models.MovementReturn
+		}
+		err = adjustStock(tx, productID, res.warehouseID, res.quantity, movementType, orderID, now)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec("UPDATE stock_reservations SET released_at = $1 WHERE id = $2", now, res.id)
+		if err != nil {
+			return 0, err
+		}
+		released += res.quantity
+	}
+
+	if released > 0 {
+		if err := syncTotal(tx, productID, now); err != nil {
+			return 0, err
+		}
+	}
+
+	return released, tx.Commit()
+}
+
+// CommitReservations marks the stock reserved for an order as sold and
+// returns how many reservations were committed. Committing again commits
+// nothing.
+func (r *InventoryRepository) CommitReservations(orderID string, now time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	reservations, err := tx.Query(
+		"SELECT id, product_id, warehouse_id FROM stock_reservations WHERE order_id = $1 AND committed_at IS NULL AND released_at IS NULL",
+		orderID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	var allocations []models.StockAllocation
+	for reservations.Next() {
+		var id string
+		var allocation models.StockAllocation
+		if err := reservations.Scan(&id, &allocation.ProductID, &allocation.WarehouseID); err != nil {
+			reservations.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		allocations = append(allocations, allocation)
+	}
+	reservations.Close()
+	if err := reservations.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		_, err = tx.Exec("UPDATE stock_reservations SET committed_at = $1 WHERE id = $2", now, id)
+		if err != nil {
+			return 0, err
+		}
+		err = recordMovement(tx, allocations[i].ProductID, allocations[i].WarehouseID, models.MovementCommit, 0, orderID, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Stock reserved for the backorders of the order later is sold as well
+	_, err = tx.Exec("UPDATE backorders SET committed_at = $1 WHERE order_id = $2 AND committed_at IS NULL", now, orderID)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// GetMovements retrieves the stock movements a filter selects, oldest first
+func (r *InventoryRepository) GetMovements(filter models.MovementFilter) ([]models.StockMovement, error) {
+	query := "SELECT id, product_id, warehouse_id, type, quantity, reference, created_at FROM stock_movements WHERE product_id = $1"
+	args := []any{filter.ProductID}
+	if filter.WarehouseID != "" {
+		args = append(args, filter.WarehouseID)
+		query += " AND warehouse_id = $" + strconv.Itoa(len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += " AND created_at >= $" + strconv.Itoa(len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += " AND created_at < $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		err := rows.Scan(&movement.ID, &movement.ProductID, &movement.WarehouseID, &movement.Type,
+			&movement.Quantity, &movement.Reference, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, rows.Err()
+}
+
+// GetLedgerBalances sums the movements of a product per warehouse
+func (r *InventoryRepository) GetLedgerBalances(productID string) (map[string]int, error) {
+	rows, err := r.db.Query(
+		"SELECT warehouse_id, SUM(quantity) FROM stock_movements WHERE product_id = $1 GROUP BY warehouse_id",
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[string]int)
+	for rows.Next() {
+		var warehouseID string
+		var quantity int
+		if err := rows.Scan(&warehouseID, &quantity); err != nil {
+			return nil, err
+		}
+		balances[warehouseID] = quantity
+	}
+
+	return balances, rows.Err()
+}
+
+// GetStockThresholds retrieves the stock thresholds of a product and the last
+// stock alert raised for it
+func (r *InventoryRepository) GetStockThresholds(productID string) (models.StockThresholds, string, error) {
+	var lowStockThreshold, reorderPoint sql.NullInt64
+	var alert string
+	err := r.db.QueryRow(
+		"SELECT low_stock_threshold, reorder_point, stock_alert FROM inventory WHERE product_id = $1",
+		productID,
+	).Scan(&lowStockThreshold, &reorderPoint, &alert)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.StockThresholds{}, "", fmt.Errorf("inventory of product %s not found", productID)
+		}
+		return models.StockThresholds{}, "", err
+	}
+
+	var thresholds models.StockThresholds
+	if lowStockThreshold.Valid {
+		value := int(lowStockThreshold.Int64)
+		thresholds.LowStockThreshold = &value
+	}
+	if reorderPoint.Valid {
+		value := int(reorderPoint.Int64)
+		thresholds.ReorderPoint = &value
+	}
+	return thresholds, alert, nil
+}
+
+// SetStockThresholds sets the stock thresholds of a product
+func (r *InventoryRepository) SetStockThresholds(productID string, thresholds models.StockThresholds, now time.Time) error {
+	result, err := r.db.Exec(
+		"UPDATE inventory SET low_stock_threshold = $2, reorder_point = $3, updated_at = $4 WHERE product_id = $1",
+		productID, thresholds.LowStockThreshold, thresholds.ReorderPoint, now,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("inventory of product %s not found", productID)
+	}
+	return nil
+}
+
+// SwapStockAlert replaces the last stock alert of a product if it is still
+// from. It reports whether it did, so only one of several concurrent changes
+// raises an alert.
+func (r *InventoryRepository) SwapStockAlert(productID, from, to string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE inventory SET stock_alert = $3 WHERE product_id = $1 AND stock_alert = $2",
+		productID, from, to,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// GetLowStockProducts retrieves the products at or below their low stock
+// threshold, using the given defaults for products without thresholds.
+// Discontinued and archived products aren't restocked, so they are left out.
+func (r *InventoryRepository) GetLowStockProducts(lowStockThreshold, reorderPoint int) ([]models.StockAlert, error) {
+	rows, err := r.db.Query(
+		`SELECT p.id, p.name, i.quantity, COALESCE(i.low_stock_threshold, $1), COALESCE(i.reorder_point, $2)
+		FROM products p
+		JOIN inventory i ON p.id = i.product_id
+		LEFT JOIN products parent ON parent.id = p.parent_id
+		WHERE i.quantity <= COALESCE(i.low_stock_threshold, $1)
+		AND COALESCE(parent.status, p.status) IN ('`+models.ProductStatusDraft+`', '`+models.ProductStatusActive+`')
+		ORDER BY i.quantity, p.name`,
+		lowStockThreshold, reorderPoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []models.StockAlert{}
+	for rows.Next() {
+		var alert models.StockAlert
+		err := rows.Scan(&alert.ProductID, &alert.ProductName, &alert.Quantity,
+			&alert.LowStockThreshold, &alert.ReorderPoint)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// GetSales sums the units sold per product since a time, from the stock
+// reserved for orders less the stock put back. productID limits it to one
+// product when it is set.
+func (r *InventoryRepository) GetSales(since time.Time, productID string) (map[string]int, error) {
+	query := "SELECT product_id, -SUM(quantity) FROM stock_movements WHERE type IN ($1, $2, $3) AND created_at >= $4"
+	args := []any{models.MovementReservation, models.MovementRelease, models.MovementReturn, since}
+	if productID != "" {
+		query += " AND product_id = $5"
+		args = append(args, productID)
+	}
+	query += " GROUP BY product_id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var quantity int
+		if err := rows.Scan(&id, &quantity); err != nil {
+			return nil, err
+		}
+		sales[id] = quantity
+	}
+
+	return sales, rows.Err()
+}
+
+// AddStock adds stock of a product coming back to the default warehouse,
+// recorded as a return by reference
+func (r *InventoryRepository) AddStock(productID string, quantity int, reference string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	warehouseID, err := defaultWarehouseID(tx)
+	if err != nil {
+		return err
+	}
+	err = adjustStock(tx, productID, warehouseID, quantity, models.MovementReturn, reference, now)
+	if err != nil {
+		return err
+	}
+	err = syncTotal(tx, productID, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateCategory creates a new category in the database
+func (r *InventoryRepository) CreateCategory(category models.Category) error {
+	_, err := r.db.Exec(
+		"INSERT INTO categories (id, parent_id, name, slug, position, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		category.ID, nullString(category.ParentID), category.Name, category.Slug, category.Position, category.CreatedAt, category.UpdatedAt,
+	)
+	return err
+}
+
+// GetCategories retrieves all categories by position, then name
+func (r *InventoryRepository) GetCategories() ([]models.Category, error) {
+	rows, err := r.db.Query(
+		"SELECT id, parent_id, name, slug, position, created_at, updated_at FROM categories ORDER BY position, name",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var category models.Category
+		var parentID sql.NullString
+		err := rows.Scan(&category.ID, &parentID, &category.Name, &category.Slug, &category.Position,
+			&category.CreatedAt, &category.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		category.ParentID = parentID.String
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryByID retrieves a category by ID
+func (r *InventoryRepository) GetCategoryByID(id string) (models.Category, error) {
+	var category models.Category
+	var parentID sql.NullString
+	err := r.db.QueryRow(
+		"SELECT id, parent_id, name, slug, position, created_at, updated_at FROM categories WHERE id = $1",
+		id,
+	).Scan(&category.ID, &parentID, &category.Name, &category.Slug, &category.Position,
+		&category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return category, fmt.Errorf("category with ID %s not found", id)
+		}
+		return category, fmt.Errorf("error getting category: %w", err)
+	}
+	category.ParentID = parentID.String
+
+	return category, nil
+}
+
+// UpdateCategory updates a category in the database
+func (r *InventoryRepository) UpdateCategory(category models.Category) error {
+	_, err := r.db.Exec(
+		"UPDATE categories SET parent_id = $1, name = $2, slug = $3, position = $4, updated_at = $5 WHERE id = $6",
+		nullString(category.ParentID), category.Name, category.Slug, category.Position, category.UpdatedAt, category.ID,
+	)
+	return err
+}
+
+// DeleteCategory deletes a category. Its children move up to its parent.
+// With reassignTo its products move to that category, otherwise deleting a
+// category with products fails with ErrCategoryNotEmpty. It returns the IDs
+// of the products moved.
+func (r *InventoryRepository) DeleteCategory(category models.Category, reassignTo string, now time.Time) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM products WHERE category_id = $1", category.ID)
+	if err != nil {
+		return nil, err
+	}
+	var productIDs []string
+	for rows.Next() {
+		var productID string
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(productIDs) > 0 {
+		if reassignTo == "" {
+			return nil, fmt.Errorf("%w: %d products are in category %s", ErrCategoryNotEmpty, len(productIDs), category.ID)
+		}
+		_, err = tx.Exec(
+			"UPDATE products SET category_id = $1, updated_at = $2 WHERE category_id = $3",
+			reassignTo, now, category.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE categories SET parent_id = $1, updated_at = $2 WHERE parent_id = $3",
+		nullString(category.ParentID), now, category.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM categories WHERE id = $1", category.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return productIDs, tx.Commit()
+}
+
+// CountProductsByCategory returns the number of products filed directly in
+// each category
+func (r *InventoryRepository) CountProductsByCategory() (map[string]int, error) {
+	rows, err := r.db.Query("SELECT category_id, COUNT(*) FROM products WHERE category_id IS NOT NULL AND parent_id IS NULL GROUP BY category_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var categoryID string
+		var count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return nil, err
+		}
+		counts[categoryID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetUnknownCategoryIDs returns the category IDs of products that have no
+// category, from before categories were kept
+func (r *InventoryRepository) GetUnknownCategoryIDs() ([]string, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT category_id FROM products
+		WHERE category_id IS NOT NULL AND category_id <> ''
+		AND category_id NOT IN (SELECT id FROM categories)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// getOptions retrieves the variant options of a product in order
+func (r *InventoryRepository) getOptions(productID string) ([]models.VariantOption, error) {
+	rows, err := r.db.Query(
+		`SELECT name, value FROM product_options WHERE product_id = $1
+		ORDER BY option_position, value_position`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []models.VariantOption
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if len(options) == 0 || options[len(options)-1].Name != name {
+			options = append(options, models.VariantOption{Name: name})
+		}
+		options[len(options)-1].Values = append(options[len(options)-1].Values, value)
+	}
+
+	return options, rows.Err()
+}
+
+// CreateSKU creates a SKU of a product with its option values and initial
+// stock. The initial stock is recorded as a receipt by reference.
+func (r *InventoryRepository) CreateSKU(sku models.SKU, price float64, initialQuantity int, reference string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// SKUs are products with a parent, so their stock is kept like any other
+	_, err = tx.Exec(
+		`INSERT INTO products (id, name, description, category_id, price, parent_id, sku, barcode, created_at, updated_at)
+		VALUES ($1, $2, '', '', $3, $4, $5, $6, $7, $8)`,
+		sku.ID, sku.Name, price, sku.ProductID, sku.Code, sku.Barcode, sku.CreatedAt, sku.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range sku.Options {
+		_, err = tx.Exec(
+			"INSERT INTO sku_options (sku_id, name, value) VALUES ($1, $2, $3)",
+			sku.ID, name, value,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO inventory (product_id, quantity, updated_at) VALUES ($1, $2, $3)",
+		sku.ID, initialQuantity, sku.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The initial stock goes to the default warehouse
+	if initialQuantity > 0 {
+		warehouseID, err := defaultWarehouseID(tx)
+		if err != nil {
+			return err
+		}
+		err = adjustStock(tx, sku.ID, warehouseID, initialQuantity, models.MovementReceipt, reference, sku.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSKUs retrieves the SKUs of products with their option values and stock,
+// keyed by product ID, oldest first. Price holds the stored price override,
+// which is 0 when the SKU sells at the price of its product.
+func (r *InventoryRepository) GetSKUs(productIDs []string) (map[string][]models.SKU, error) {
+	skus := make(map[string][]models.SKU)
+	if len(productIDs) == 0 {
+		return skus, nil
+	}
+
+	var placeholders []string
+	var args []any
+	for i, id := range productIDs {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		args = append(args, id)
+	}
+
+	rows, err := r.db.Query(
+		`SELECT p.id, p.parent_id, p.sku, p.barcode, p.name, p.price, COALESCE(i.quantity, 0), p.created_at, p.updated_at
+		FROM products p
+		LEFT JOIN inventory i ON p.id = i.product_id
+		WHERE p.parent_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY p.created_at, p.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var order []models.SKU
+	for rows.Next() {
+		var sku models.SKU
+		err := rows.Scan(&sku.ID, &sku.ProductID, &sku.Code, &sku.Barcode, &sku.Name, &sku.Price,
+			&sku.Quantity, &sku.CreatedAt, &sku.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sku.Options = make(map[string]string)
+		order = append(order, sku)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(order) == 0 {
+		return skus, nil
+	}
+	index := make(map[string]*models.SKU, len(order))
+	for i := range order {
+		index[order[i].ID] = &order[i]
+	}
+
+	// Get the option values of the SKUs
+	optionRows, err := r.db.Query(
+		`SELECT o.sku_id, o.name, o.value
+		FROM sku_options o
+		JOIN products p ON p.id = o.sku_id
+		WHERE p.parent_id IN (`+strings.Join(placeholders, ",")+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		var skuID, name, value string
+		if err := optionRows.Scan(&skuID, &name, &value); err != nil {
+			return nil, err
+		}
+		if sku, ok := index[skuID]; ok {
+			sku.Options[name] = value
+		}
+	}
+	if err := optionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, sku := range order {
+		skus[sku.ProductID] = append(skus[sku.ProductID], sku)
+	}
+	return skus, nil
+}
+
+// HasSKUs reports whether a product is sold in variants
+func (r *InventoryRepository) HasSKUs(productID string) (bool, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM products WHERE parent_id = $1", productID).Scan(&count)
+	return count > 0, err
+}
+
+// UpdateSKU updates the code, barcode, name and price override of a SKU. A
+// price of 0 sells it at the price of its product.
+func (r *InventoryRepository) UpdateSKU(sku models.SKU, price float64) error {
+	_, err := r.db.Exec(
+		"UPDATE products SET sku = $1, barcode = $2, name = $3, price = $4, updated_at = $5 WHERE id = $6 AND parent_id = $7",
+		sku.Code, sku.Barcode, sku.Name, price, sku.UpdatedAt, sku.ID, sku.ProductID,
+	)
+	return err
+}
+
+// GetProductIDBySKU returns the ID of the product or SKU with an external
+// SKU, "" when there is none
+func (r *InventoryRepository) GetProductIDBySKU(code string) (string, error) {
+	var id string
+	err := r.db.QueryRow("SELECT id FROM products WHERE sku = $1", code).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// CreateImportJob creates an import job
+func (r *InventoryRepository) CreateImportJob(job models.ImportJob) error {
+	_, err := r.db.Exec(
+		`INSERT INTO import_jobs (id, format, dry_run, status, total_rows, caller, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		job.ID, job.Format, job.DryRun, job.Status, job.TotalRows, job.Caller, job.CreatedAt, job.UpdatedAt,
+	)
+	return err
+}
+
+// UpdateImportJob saves the progress of an import job
+func (r *InventoryRepository) UpdateImportJob(job models.ImportJob) error {
+	errs, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`UPDATE import_jobs SET status = $1, processed_rows = $2, created_count = $3, updated_count = $4,
+		failed_count = $5, errors = $6, reason = $7, updated_at = $8, finished_at = $9
+		WHERE id = $10`,
+		job.Status, job.ProcessedRows, job.Created, job.Updated, job.Failed, string(errs), job.Reason,
+		job.UpdatedAt, job.FinishedAt, job.ID,
+	)
+	return err
+}
+
+// GetImportJob retrieves an import job with its failed rows
+func (r *InventoryRepository) GetImportJob(id string) (models.ImportJob, error) {
+	var job models.ImportJob
+	var errs string
+	var finishedAt sql.NullTime
+
+	err := r.db.QueryRow(
+		`SELECT id, format, dry_run, status, total_rows, processed_rows, created_count, updated_count,
+		failed_count, errors, reason, caller, created_at, updated_at, finished_at
+		FROM import_jobs WHERE id = $1`,
+		id,
+	).Scan(&job.ID, &job.Format, &job.DryRun, &job.Status, &job.TotalRows, &job.ProcessedRows, &job.Created,
+		&job.Updated, &job.Failed, &errs, &job.Reason, &job.Caller, &job.CreatedAt, &job.UpdatedAt, &finishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return job, fmt.Errorf("import with ID %s not found", id)
+		}
+		return job, fmt.Errorf("error getting import: %w", err)
+	}
+
+	job.FinishedAt = timePtr(finishedAt)
+	if err := json.Unmarshal([]byte(errs), &job.Errors); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// TouchImportJob records that a running import job is still alive
+func (r *InventoryRepository) TouchImportJob(id string, now time.Time) error {
+	_, err := r.db.Exec(
+		"UPDATE import_jobs SET updated_at = $1 WHERE id = $2 AND status = $3",
+		now, id, models.ImportStatusRunning,
+	)
+	return err
+}
+
+// FailStaleImports marks the running imports that were last touched before
+// the given time as failed. Imports run in the background of a replica, so
+// they stop with it.
+func (r *InventoryRepository) FailStaleImports(reason string, before, now time.Time) (int, error) {
+	result, err := r.db.Exec(
+		"UPDATE import_jobs SET status = $1, reason = $2, updated_at = $3, finished_at = $3 WHERE status = $4 AND updated_at < $5",
+		models.ImportStatusFailed, reason, now, models.ImportStatusRunning, before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	failed, err := result.RowsAffected()
+	return int(failed), err
+}
+
+// RecordPurchases records the products bought in an order. Orders are
+// recorded when they are created and again when they are completed, and
+// events can be delivered more than once, so recording is idempotent.
+func (r *InventoryRepository) RecordPurchases(orderID, customerID string, productIDs []string, completed bool, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, productID := range productIDs {
+		_, err = tx.Exec(
+			`INSERT INTO order_purchases (order_id, product_id, customer_id, completed, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (order_id, product_id) DO UPDATE SET completed = order_purchases.completed OR excluded.completed`,
+			orderID, productID, customerID, completed, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeletePurchases forgets the products of an order that was cancelled
+func (r *InventoryRepository) DeletePurchases(orderID string) error {
+	_, err := r.db.Exec("DELETE FROM order_purchases WHERE order_id = $1", orderID)
+	return err
+}
+
+// GetPurchases retrieves the products bought in orders since a time
+func (r *InventoryRepository) GetPurchases(since time.Time) ([]models.Purchase, error) {
+	rows, err := r.db.Query(
+		"SELECT order_id, product_id, customer_id, completed, created_at FROM order_purchases WHERE created_at >= $1",
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purchases []models.Purchase
+	for rows.Next() {
+		var purchase models.Purchase
+		err := rows.Scan(&purchase.OrderID, &purchase.ProductID, &purchase.CustomerID, &purchase.Completed, &purchase.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// GetPurchasesByCustomer retrieves the products a customer bought
+func (r *InventoryRepository) GetPurchasesByCustomer(customerID string) ([]models.Purchase, error) {
+	rows, err := r.db.Query(
+		"SELECT order_id, product_id, customer_id, completed, created_at FROM order_purchases WHERE customer_id = $1 ORDER BY created_at",
+		customerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []models.Purchase{}
+	for rows.Next() {
+		var purchase models.Purchase
+		err := rows.Scan(&purchase.OrderID, &purchase.ProductID, &purchase.CustomerID, &purchase.Completed, &purchase.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// AnonymizePurchases replaces the customer of their purchases with an opaque
+// ID, so they still count towards recommendations without naming the
+// customer. It returns the number of purchases anonymized.
+func (r *InventoryRepository) AnonymizePurchases(customerID string) (int, error) {
+	result, err := r.db.Exec(
+		"UPDATE order_purchases SET customer_id = $1 WHERE customer_id = $2",
+		GenerateID(), customerID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// ReplaceSimilarities replaces the similarities of a kind with newly
+// computed ones
+func (r *InventoryRepository) ReplaceSimilarities(kind string, similarities []models.ProductSimilarity) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM product_similarities WHERE kind = $1", kind)
+	if err != nil {
+		return err
+	}
+	for _, similarity := range similarities {
+		_, err = tx.Exec(
+			`INSERT INTO product_similarities (id, product_id, similar_product_id, similarity_score, kind, support, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			similarity.ID, similarity.ProductID, similarity.SimilarProductID, similarity.SimilarityScore,
+			kind, similarity.Support, similarity.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSimilarities retrieves the products most similar to a product, most
+// similar first
+func (r *InventoryRepository) GetSimilarities(productID, kind string, limit int) ([]models.ProductSimilarity, error) {
+	rows, err := r.db.Query(
+		`SELECT id, product_id, similar_product_id, similarity_score, kind, support, created_at
+		FROM product_similarities WHERE product_id = $1 AND kind = $2
+		ORDER BY similarity_score DESC, support DESC, similar_product_id LIMIT $3`,
+		productID, kind, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similarities []models.ProductSimilarity
+	for rows.Next() {
+		var similarity models.ProductSimilarity
+		err := rows.Scan(&similarity.ID, &similarity.ProductID, &similarity.SimilarProductID,
+			&similarity.SimilarityScore, &similarity.Kind, &similarity.Support, &similarity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		similarities = append(similarities, similarity)
+	}
+	return similarities, rows.Err()
+}
+
+// CreatePriceSchedule saves a price schedule unless check refuses it. check
+// is given the scheduled and active price schedules of the product. The
+// product row is locked meanwhile, so schedules created at the same time are
+// checked against each other.
+func (r *InventoryRepository) CreatePriceSchedule(schedule models.PriceSchedule, check func(open []models.PriceSchedule) error) error {
+	// Begin transaction
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var productID string
+	err = tx.QueryRow("SELECT id FROM products WHERE id = $1"+r.db.ForUpdate(), schedule.ProductID).Scan(&productID)
+	if err != nil {
+		return err
+	}
+
+	open, err := queryPriceSchedules(tx, "WHERE product_id = $1 AND status IN ($2, $3)",
+		schedule.ProductID, models.PriceScheduleScheduled, models.PriceScheduleActive)
+	if err != nil {
+		return err
+	}
+	if err := check(open); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO price_schedules (id, product_id, price, starts_at, ends_at, status, caller, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		schedule.ID, schedule.ProductID, schedule.Price, schedule.StartsAt, schedule.EndsAt, schedule.Status,
+		schedule.Caller, schedule.CreatedAt, schedule.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// GetPriceSchedule retrieves a price schedule by ID
+func (r *InventoryRepository) GetPriceSchedule(id string) (models.PriceSchedule, error) {
+	schedules, err := r.getPriceSchedules("WHERE id = $1", id)
+	if err != nil {
+		return models.PriceSchedule{}, err
+	}
+	if len(schedules) == 0 {
+		return models.PriceSchedule{}, fmt.Errorf("price schedule with ID %s not found", id)
+	}
+	return schedules[0], nil
+}
+
+// GetPriceSchedules retrieves the price schedules of a product in any of
+// statuses, or in any status when none are given, by start
+func (r *InventoryRepository) GetPriceSchedules(productID string, statuses ...string) ([]models.PriceSchedule, error) {
+	where := "WHERE product_id = $1"
+	args := []any{productID}
+	if len(statuses) > 0 {
+		var placeholders []string
+		for _, status := range statuses {
+			args = append(args, status)
+			placeholders = append(placeholders, "$"+strconv.Itoa(len(args)))
+		}
+		where += " AND status IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	return r.getPriceSchedules(where, args...)
+}
+
+// GetDuePriceSchedules retrieves the price schedules to start or end by now,
+// by start
+func (r *InventoryRepository) GetDuePriceSchedules(now time.Time) ([]models.PriceSchedule, error) {
+	return r.getPriceSchedules(
+		"WHERE (status = $1 AND starts_at <= $2) OR (status = $3 AND ends_at <= $2)",
+		models.PriceScheduleScheduled, now, models.PriceScheduleActive,
+	)
+}
+
+// getPriceSchedules retrieves the price schedules a WHERE clause selects
+func (r *InventoryRepository) getPriceSchedules(where string, args ...any) ([]models.PriceSchedule, error) {
+	return queryPriceSchedules(r.db, where, args...)
+}
+
+// queryPriceSchedules retrieves the price schedules a WHERE clause selects,
+// by start
+func queryPriceSchedules(db interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, where string, args ...any) ([]models.PriceSchedule, error) {
+	rows, err := db.Query(
+		`SELECT id, product_id, price, starts_at, ends_at, status, caller, created_at, updated_at
+		FROM price_schedules `+where+` ORDER BY starts_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.PriceSchedule{}
+	for rows.Next() {
+		var schedule models.PriceSchedule
+		var endsAt sql.NullTime
+		err := rows.Scan(&schedule.ID, &schedule.ProductID, &schedule.Price, &schedule.StartsAt, &endsAt,
+			&schedule.Status, &schedule.Caller, &schedule.CreatedAt, &schedule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		schedule.EndsAt = timePtr(endsAt)
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// SetPriceScheduleStatus moves a price schedule from one status to another
+// without changing any price. It reports false when the schedule wasn't in
+// the status, such as when another replica moved it first.
+func (r *InventoryRepository) SetPriceScheduleStatus(id, from, to string, now time.Time) (bool, error) {
+	return moveSchedule(r.db, id, from, to, now)
+}
+
+// StartPriceSchedule sets the price of a product to the price of a schedule.
+// A sale keeps the price the product had as its was price, to go back to at
+// the end of the sale. It reports false when the schedule was already
+// started, such as by another replica.
+func (r *InventoryRepository) StartPriceSchedule(schedule models.PriceSchedule, now time.Time) (models.PriceChange, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+	defer tx.Rollback()
+
+	status := models.PriceScheduleCompleted
+	if schedule.EndsAt != nil {
+		status = models.PriceScheduleActive
+	}
+	moved, err := moveSchedule(tx, schedule.ID, models.PriceScheduleScheduled, status, now)
+	if err != nil || !moved {
+		return models.PriceChange{}, false, err
+	}
+
+	var previousPrice float64
+	var wasPrice sql.NullFloat64
+	err = tx.QueryRow("SELECT price, was_price FROM products WHERE id = $1"+r.db.ForUpdate(), schedule.ProductID).Scan(&previousPrice, &wasPrice)
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+
+	if schedule.EndsAt != nil {
+		regular := previousPrice
+		if wasPrice.Valid {
+			regular = wasPrice.Float64
+		}
+		_, err = tx.Exec(
+			"UPDATE products SET price = $1, was_price = $2, sale_ends_at = $3, updated_at = $4 WHERE id = $5",
+			schedule.Price, regular, schedule.EndsAt, now, schedule.ProductID,
+		)
+	} else {
+		_, err = tx.Exec(
+			"UPDATE products SET price = $1, updated_at = $2 WHERE id = $3",
+			schedule.Price, now, schedule.ProductID,
+		)
+	}
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+
+	change := models.PriceChange{
+		ProductID:     schedule.ProductID,
+		Price:         schedule.Price,
+		PreviousPrice: previousPrice,
+		Reason:        models.PriceChangeScheduled,
+		Reference:     "schedule:" + schedule.ID,
+		ChangedAt:     now,
+	}
+	err = recordPriceChange(tx, change.ProductID, change.Price, change.PreviousPrice, change.Reason, change.Reference, now)
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+	return change, true, tx.Commit()
+}
+
+// EndPriceSchedule ends a running sale, moving it to status, completed or
+// cancelled, and sets the price of its product back to its was price. The
+// change is recorded by reference. It reports false when the sale had
+// already ended, such as by another replica.
+func (r *InventoryRepository) EndPriceSchedule(schedule models.PriceSchedule, status, reference string, now time.Time) (models.PriceChange, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+	defer tx.Rollback()
+
+	moved, err := moveSchedule(tx, schedule.ID, models.PriceScheduleActive, status, now)
+	if err != nil || !moved {
+		return models.PriceChange{}, false, err
+	}
+
+	var previousPrice float64
+	var wasPrice sql.NullFloat64
+	err = tx.QueryRow("SELECT price, was_price FROM products WHERE id = $1"+r.db.ForUpdate(), schedule.ProductID).Scan(&previousPrice, &wasPrice)
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+
+	change := models.PriceChange{
+		ProductID:     schedule.ProductID,
+		Price:         previousPrice,
+		PreviousPrice: previousPrice,
+		Reason:        models.PriceChangeReverted,
+		Reference:     reference,
+		ChangedAt:     now,
+	}
+	if wasPrice.Valid {
+		change.Price = wasPrice.Float64
+	}
+	_, err = tx.Exec(
+		"UPDATE products SET price = $1, was_price = NULL, sale_ends_at = NULL, updated_at = $2 WHERE id = $3",
+		change.Price, now, schedule.ProductID,
+	)
+	if err != nil {
+		return models.PriceChange{}, false, err
+	}
+	if change.Price != change.PreviousPrice {
+		err = recordPriceChange(tx, change.ProductID, change.Price, change.PreviousPrice, change.Reason, change.Reference, now)
+		if err != nil {
+			return models.PriceChange{}, false, err
+		}
+	}
+	return change, true, tx.Commit()
+}
+
+// GetPriceChanges retrieves the price history of a product, oldest first
+func (r *InventoryRepository) GetPriceChanges(productID string) ([]models.PriceChange, error) {
+	rows, err := r.db.Query(
+		`SELECT id, product_id, price, previous_price, reason, reference, changed_at
+		FROM price_history WHERE product_id = $1 ORDER BY changed_at, id`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.PriceChange{}
+	for rows.Next() {
+		var change models.PriceChange
+		err := rows.Scan(&change.ID, &change.ProductID, &change.Price, &change.PreviousPrice,
+			&change.Reason, &change.Reference, &change.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// reserveAllocations takes the stock of allocations for an order and records
+// the reservations, committed already when committed is set
+func reserveAllocations(tx *sql.Tx, orderID string, allocations []models.StockAllocation, committed bool, now time.Time) error {
+	products := make(map[string]bool)
+	for _, allocation := range allocations {
+		stockID := allocation.StockID()
+		err := adjustStock(tx, stockID, allocation.WarehouseID, -allocation.Quantity, models.MovementReservation, orderID, now)
+		if err != nil {
+			return err
+		}
+
+		var committedAt *time.Time
+		if committed {
+			committedAt = &now
+			err = recordMovement(tx, stockID, allocation.WarehouseID, models.MovementCommit, 0, orderID, now)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(
+			"INSERT INTO stock_reservations (id, order_id, product_id, warehouse_id, quantity, created_at, committed_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			GenerateID(), orderID, stockID, allocation.WarehouseID, allocation.Quantity, now, committedAt,
+		)
+		if err != nil {
+			return err
+		}
+		products[stockID] = true
+	}
+
+	for productID := range products {
+		if err := syncTotal(tx, productID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveOptions replaces the variant options of a product
+func saveOptions(tx *sql.Tx, productID string, options []models.VariantOption) error {
+	_, err := tx.Exec("DELETE FROM product_options WHERE product_id = $1", productID)
+	if err != nil {
+		return err
+	}
+
+	for i, option := range options {
+		for j, value := range option.Values {
+			_, err = tx.Exec(
+				"INSERT INTO product_options (product_id, name, value, option_position, value_position) VALUES ($1, $2, $3, $4, $5)",
+				productID, option.Name, value, i, j,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// timePtr returns the time of a nullable column, nil when it is NULL
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// floatPtr returns the number of a nullable column, nil when it is NULL
+func floatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// splitStockID returns the product and SKU stock is kept under by stockID,
+// given the parent of the product it names. Only SKUs have a parent.
+func splitStockID(stockID string, parentID sql.NullString) (string, string) {
+	if parentID.Valid {
+		return parentID.String, stockID
+	}
+	return stockID, ""
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// insertWarehouse inserts a warehouse. A new default warehouse takes the flag
+// from the previous one.
+func insertWarehouse(tx *sql.Tx, warehouse models.Warehouse) error {
+	if warehouse.IsDefault {
+		_, err := tx.Exec("UPDATE warehouses SET is_default = FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO warehouses (id, code, name, country, province, city, is_default, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		warehouse.ID, warehouse.Code, warehouse.Name, warehouse.Country, warehouse.Province, warehouse.City,
+		warehouse.IsDefault, warehouse.CreatedAt, warehouse.UpdatedAt,
+	)
+	return err
+}
+
+// defaultWarehouseID returns the ID of the default warehouse
+func defaultWarehouseID(tx *sql.Tx) (string, error) {
+	var id string
+	err := tx.QueryRow("SELECT id FROM warehouses WHERE is_default = TRUE").Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", errors.New("no default warehouse")
+	}
+	return id, err
+}
+
+// adjustStock adds delta to the stock of a product in a warehouse and records
+// the movement in the ledger. Taking more than the warehouse has fails with
+// ErrInsufficientStock. The total in inventory is left to syncTotal.
+func adjustStock(tx *sql.Tx, productID, warehouseID string, delta int, movementType, reference string, now time.Time) error {
+	if delta >= 0 {
+		_, err := tx.Exec(
+			`INSERT INTO stock_levels (product_id, warehouse_id, quantity, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (product_id, warehouse_id)
+			DO UPDATE SET quantity = stock_levels.quantity + $3, updated_at = $4`,
+			productID, warehouseID, delta, now,
+		)
+		if err != nil {
+			return err
+		}
+		return recordMovement(tx, productID, warehouseID, movementType, delta, reference, now)
+	}
+
+	result, err := tx.Exec(
+		"UPDATE stock_levels SET quantity = quantity - $1, updated_at = $2 WHERE product_id = $3 AND warehouse_id = $4 AND quantity >= $1",
+		-delta, now, productID, warehouseID,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrInsufficientStock
+	}
+	return recordMovement(tx, productID, warehouseID, movementType, delta, reference, now)
+}
+
+// recordMovement appends a movement to the stock ledger
+func recordMovement(tx *sql.Tx, productID, warehouseID, movementType string, quantity int, reference string, now time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO stock_movements (id, product_id, warehouse_id, type, quantity, reference, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		GenerateID(), productID, warehouseID, movementType, quantity, reference, now,
+	)
+	return err
+}
+
+// recordPriceChange appends a change to the price history of a product
+func recordPriceChange(tx *sql.Tx, productID string, price, previousPrice float64, reason, reference string, now time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO price_history (id, product_id, price, previous_price, reason, reference, changed_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		GenerateID(), productID, price, previousPrice, reason, reference, now,
+	)
+	return err
+}
+
+// moveSchedule moves a price schedule from one status to another, reporting
+// false when it wasn't in the status
+func moveSchedule(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, id, from, to string, now time.Time) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE price_schedules SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4",
+		to, now, id, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// setQuantity adjusts the stock of a product in the default warehouse so its
+// stock across all warehouses is quantity. The stock rows are locked first, so
+// a reservation committed meanwhile isn't undone by a stale total.
+func (r *InventoryRepository) setQuantity(tx *sql.Tx, productID string, quantity int, reference string, now time.Time) error {
+	rows, err := tx.Query("SELECT quantity FROM stock_levels WHERE product_id = $1"+r.db.ForUpdate(), productID)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for rows.Next() {
+		var stock int
+		if err := rows.Scan(&stock); err != nil {
+			rows.Close()
+			return err
+		}
+		total += stock
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	delta := quantity - total
+	if delta == 0 {
+		return nil
+	}
+	warehouseID, err := defaultWarehouseID(tx)
+	if err != nil {
+		return err
+	}
+	err = adjustStock(tx, productID, warehouseID, delta, models.MovementAdjustment, reference, now)
+	if errors.Is(err, ErrInsufficientStock) {
+		return fmt.Errorf("quantity %d would leave the default warehouse with negative stock, set the stock per warehouse instead", quantity)
+	}
+	if err != nil {
+		return err
+	}
+	return syncTotal(tx, productID, now)
+}
+
+// syncTotal sets the quantity in inventory to the stock of a product across
+// all warehouses
+func syncTotal(tx *sql.Tx, productID string, now time.Time) error {
+	_, err := tx.Exec(
+		"UPDATE inventory SET quantity = (SELECT COALESCE(SUM(quantity), 0) FROM stock_levels WHERE product_id = $1), updated_at = $2 WHERE product_id = $1",
+		productID, now,
+	)
+	return err
+}
+
 // GenerateID generates a new UUID
 func GenerateID() string {
 	return uuid.New().String()
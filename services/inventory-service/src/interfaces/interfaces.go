@@ -10,20 +10,91 @@ type InventoryService interface {
 	CreateProduct(req models.CreateProductRequest) (models.Product, error)
 	GetProductByID(id string) (models.Product, error)
 	GetProducts() ([]models.Product, error)
+	GetListedProducts() ([]models.Product, error)
+	SearchProducts(search models.ProductSearch) (models.ProductSearchResult, error)
 	UpdateProduct(id string, req models.UpdateProductRequest) (models.Product, error)
 	DeleteProduct(id string) error
 
+	// Catalogue import and export methods
+	ImportProducts(format string, data []byte, dryRun bool, caller string) (models.ImportJob, error)
+	GetImportJob(id string) (models.ImportJob, error)
+	FailStaleImports() (int, error)
+	ExportProducts(search models.ProductSearch, format, warehouse string) ([]byte, error)
+
+	// SKU methods
+	CreateSKU(productID string, req models.CreateSKURequest) (models.SKU, error)
+	GetSKUs(productID string) ([]models.SKU, error)
+	UpdateSKU(productID, skuID string, req models.UpdateSKURequest) (models.SKU, error)
+	DeleteSKU(productID, skuID string) error
+
+	// Price schedule and history methods
+	SchedulePrice(productID string, req models.CreatePriceScheduleRequest) (models.PriceSchedule, error)
+	CancelPriceSchedule(productID, scheduleID, caller string) (models.PriceSchedule, error)
+	GetPriceHistory(productID string) (models.PriceHistory, error)
+	ApplyPriceSchedules() (int, error)
+
+	// Category methods
+	CreateCategory(req models.CreateCategoryRequest) (models.Category, error)
+	GetCategories() ([]models.Category, error)
+	GetCategoryByID(id string) (models.Category, error)
+	UpdateCategory(id string, req models.UpdateCategoryRequest) (models.Category, error)
+	DeleteCategory(id string, reassignTo string) error
+
 	// Inventory check method
 	CheckInventory(req models.InventoryCheckRequest) (models.InventoryCheckResponse, error)
 
+	// Warehouse and per-location stock methods
+	CreateWarehouse(req models.CreateWarehouseRequest) (models.Warehouse, error)
+	GetWarehouses() ([]models.Warehouse, error)
+	GetWarehouseByID(id string) (models.Warehouse, error)
+	UpdateWarehouse(id string, req models.UpdateWarehouseRequest) (models.Warehouse, error)
+	GetWarehouseStock(warehouseID string) ([]models.StockLevel, error)
+	GetProductStock(productID string) (models.ProductStock, error)
+	SetStock(warehouseID, productID string, req models.SetStockRequest) (models.ProductStock, error)
+	TransferStock(req models.TransferStockRequest) (models.StockTransfer, error)
+	GetTransfers(productID string) ([]models.StockTransfer, error)
+
+	// Reservation methods
+	ReserveStock(req models.ReserveStockRequest) (models.Reservation, error)
+	RestoreStock(req models.RestoreStockRequest) error
+	CommitStock(orderID string) error
+
+	// Stock ledger methods
+	GetMovements(filter models.MovementFilter) ([]models.StockMovement, error)
+	ReconcileStock(productID string) (models.StockReconciliation, error)
+	ReconcileAllStock() ([]models.StockReconciliation, error)
+
+	// Stock alert methods
+	SetStockThresholds(productID string, req models.StockThresholds) (models.ProductStock, error)
+	GetStockAlerts() ([]models.StockAlert, error)
+
+	// Backorder methods
+	GetBackorders(productID string) ([]models.Backorder, error)
+
 	// Recommendation methods
-	GetProductRecommendations(productID string, limit int) ([]models.Product, error)
+	GetProductRecommendations(productID, kind string, limit int) ([]models.Product, error)
 	GetCategoryRecommendations(categoryID string, limit int) ([]models.Product, error)
 	GetSimilarProducts(req models.RecommendationRequest) ([]models.Product, error)
+	RecordPurchase(orderID, customerID string, productIDs []string, completed bool) error
+	CancelPurchase(orderID string) error
+	ComputeSimilarities() (int, error)
+
+	// Cache methods
+	ForgetCached(names []string)
+
+	// Privacy methods
+	GetCustomerData(customerID string) (models.CustomerData, error)
+	EraseCustomer(event models.ErasureEvent) error
 }
 
 // InventoryProducer defines the interface for inventory producer
 type InventoryProducer interface {
 	PublishInventoryUpdated(productID string, quantity int) error
+	PublishStockAlert(alert models.StockAlert) error
+	PublishBackorderAllocated(event models.BackorderEvent) error
+	PublishCacheInvalidated(names []string) error
+	PublishProductStatusChanged(product models.Product) error
+	PublishPriceChanged(change models.PriceChange) error
+	PublishErasureCompleted(event models.ErasureEvent) error
 	Close() error
 }
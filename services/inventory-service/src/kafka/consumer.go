@@ -4,168 +4,238 @@ import (
 "context"
 "encoding/json"
 "log"
-"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/inventory-service/config"
+"github.com/online-order-system/inventory-service/eventbus"
 "github.com/online-order-system/inventory-service/interfaces"
 "github.com/online-order-system/inventory-service/models"
 )
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-reader  *kafka.Reader
-service interfaces.InventoryService
+bus          eventbus.Bus
+service      interfaces.InventoryService
+cacheTopic   string
+privacyTopic string
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.InventoryService) *Consumer {
-// Create a reader for the orders topic
-ordersReader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    "orders", // Listen to orders topic
-GroupID:  "inventory-service-orders",
-MinBytes: 10e3, // 10KB
-MaxBytes: 10e6, // 10MB
-})
-
-// Create a reader for the payments topic
-paymentsReader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    "payments", // Listen to payments topic
-GroupID:  "inventory-service-payments",
-MinBytes: 10e3, // 10KB
-MaxBytes: 10e6, // 10MB
-})
-
-log.Printf("Inventory Service Kafka consumer created and subscribed to topics: orders, payments")
-
-// Start consuming from both topics
-consumer := &Consumer{
-reader:  ordersReader, // We'll use this as the primary reader
-service: service,
-}
-
-// Start a separate goroutine to consume from the payments topic
-go func() {
-ctx := context.Background()
-for {
-m, err := paymentsReader.ReadMessage(ctx)
-if err != nil {
-log.Printf("Error reading message from Kafka payments topic: %v", err)
-time.Sleep(1 * time.Second)
-continue
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.InventoryService) *Consumer {
+return &Consumer{
+bus:          bus,
+service:      service,
+cacheTopic:   cfg.CacheTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
+}
 }
 
-log.Printf("Received message from Kafka payments topic: %s", string(m.Value))
+// StartConsuming starts consuming messages from Kafka
+func (c *Consumer) StartConsuming(ctx context.Context) {
+// Listen to orders topic
+err := c.bus.Subscribe(ctx, "orders", "inventory-service-orders", c.processOrderMessage)
+if err != nil {
+log.Printf("Error subscribing to orders topic: %v", err)
+}
 
-// Process the message
-var event struct {
-EventType string `json:"event_type"`
+// Listen to payments topic
+err = c.bus.Subscribe(ctx, "payments", "inventory-service-payments", c.processPaymentMessage)
+if err != nil {
+log.Printf("Error subscribing to payments topic: %v", err)
 }
-if err := json.Unmarshal(m.Value, &event); err != nil {
-log.Printf("Error unmarshaling message from payments topic: %v", err)
-continue
+
+// Listen to privacy topic
+err = c.bus.Subscribe(ctx, c.privacyTopic, "inventory-service-privacy", c.processPrivacyMessage)
+if err != nil {
+log.Printf("Error subscribing to privacy topic: %v", err)
 }
 
-// Process the event based on its type
-if event.EventType == "payment_failed" {
-log.Printf("Processing payment failed event from payments topic")
-var paymentEvent struct {
-OrderID string `json:"order_id"`
+// Every replica caches values of its own, so each listens to cache
+// invalidations outside any group, from the time it starts
+err = c.bus.Subscribe(ctx, c.cacheTopic, "", c.processCacheMessage)
+if err != nil {
+log.Printf("Error subscribing to %s topic: %v", c.cacheTopic, err)
 }
-if err := json.Unmarshal(m.Value, &paymentEvent); err != nil {
-log.Printf("Error unmarshaling payment failed event: %v", err)
-continue
+
+log.Printf("Inventory Service Kafka consumer subscribed to topics: orders, payments, %s, %s", c.privacyTopic, c.cacheTopic)
 }
 
-// Get order details to restore inventory
-// We need to make a request to the order service to get the order details
-// For now, we'll just log that we received the event
-log.Printf("Payment failed for order %s, should restore inventory", paymentEvent.OrderID)
+// processPrivacyMessage processes a message from the privacy topic
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+var event models.ErasureEvent
+if err := json.Unmarshal(value, &event); err != nil {
+log.Printf("Error unmarshaling message: %v", err)
+return nil
+}
 
-// TODO: Implement inventory restoration logic
-// This would require getting the order details from the order service
-// and then updating the inventory for each item in the order
+if event.EventType == "user_erasure_requested" {
+log.Printf("Processing erasure %s for customer %s", event.RequestID, event.CustomerID)
+if err := c.service.EraseCustomer(event); err != nil {
+log.Printf("Error reporting erasure %s: %v", event.RequestID, err)
+}
 }
+return nil
 }
-}()
 
-return consumer
+// processCacheMessage processes a message from the cache topic
+func (c *Consumer) processCacheMessage(ctx context.Context, key, value []byte) error {
+var event models.CacheEvent
+if err := json.Unmarshal(value, &event); err != nil {
+log.Printf("Error unmarshaling message from %s topic: %v", c.cacheTopic, err)
+return nil
 }
 
-// StartConsuming starts consuming messages from Kafka
-func (c *Consumer) StartConsuming(ctx context.Context) {
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping Kafka consumer")
-c.reader.Close()
-return
-default:
-m, err := c.reader.ReadMessage(ctx)
-if err != nil {
-log.Printf("Error reading message from Kafka: %v", err)
-time.Sleep(1 * time.Second)
-continue
+if event.EventType == "cache_invalidated" {
+c.service.ForgetCached(event.Names)
+}
+return nil
 }
 
-log.Printf("Received message from Kafka: %s", string(m.Value))
+// processOrderMessage processes a message from the orders topic
+func (c *Consumer) processOrderMessage(ctx context.Context, key, value []byte) error {
+log.Printf("Received message from Kafka: %s", string(value))
 
 // Process the message
 var event struct {
 EventType string `json:"event_type"`
 }
-if err := json.Unmarshal(m.Value, &event); err != nil {
+if err := json.Unmarshal(value, &event); err != nil {
 log.Printf("Error unmarshaling message: %v", err)
-continue
+return nil
 }
 
 // Process the event based on its type
 if event.EventType == "order_created" {
 log.Printf("Processing order created event")
 var orderEvent struct {
-OrderID string `json:"order_id"`
-Items   []struct {
+OrderID    string `json:"order_id"`
+CustomerID string `json:"customer_id"`
+Items      []struct {
 ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
 Quantity  int    `json:"quantity"`
 } `json:"items"`
+ShippingAddress *models.Location `json:"shipping_address"`
 }
-if err := json.Unmarshal(m.Value, &orderEvent); err != nil {
+if err := json.Unmarshal(value, &orderEvent); err != nil {
 log.Printf("Error unmarshaling order created event: %v", err)
-continue
+return nil
 }
 
-// Update inventory for each item
+// Record what was bought together, for recommendations
+var productIDs []string
 for _, item := range orderEvent.Items {
-// Get current product with inventory
-product, err := c.service.GetProductByID(item.ProductID)
+productIDs = append(productIDs, item.ProductID)
+}
+if err := c.service.RecordPurchase(orderEvent.OrderID, orderEvent.CustomerID, productIDs, false); err != nil {
+// Log error but continue
+log.Printf("Error recording purchase of order %s: %v", orderEvent.OrderID, err)
+}
+
+// Reserve the stock of the order. Order-service normally reserved it
+// already, in which case this returns the existing reservation.
+req := models.ReserveStockRequest{
+OrderID: orderEvent.OrderID,
+Items:   orderEvent.Items,
+}
+if orderEvent.ShippingAddress != nil {
+req.ShippingAddress = *orderEvent.ShippingAddress
+}
+reservation, err := c.service.ReserveStock(req)
 if err != nil {
-log.Printf("Error getting product %s: %v", item.ProductID, err)
-continue
+log.Printf("Error reserving stock for order %s: %v", orderEvent.OrderID, err)
+return nil
+}
+if !reservation.Available {
+log.Printf("Not enough stock to reserve for order %s", orderEvent.OrderID)
+}
+} else if event.EventType == "order_confirmed" {
+var orderEvent struct {
+OrderID string `json:"order_id"`
+}
+if err := json.Unmarshal(value, &orderEvent); err != nil {
+log.Printf("Error unmarshaling order confirmed event: %v", err)
+return nil
 }
 
-// Update inventory
-newQuantity := product.Quantity - item.Quantity
-if newQuantity < 0 {
-newQuantity = 0
+// The reserved stock of a confirmed order is sold
+if err := c.service.CommitStock(orderEvent.OrderID); err != nil {
+log.Printf("Error committing stock for order %s: %v", orderEvent.OrderID, err)
+}
+} else if event.EventType == "order_completed" {
+var orderEvent struct {
+OrderID    string `json:"order_id"`
+CustomerID string `json:"customer_id"`
+Items      []struct {
+ProductID string `json:"product_id"`
+} `json:"items"`
+}
+if err := json.Unmarshal(value, &orderEvent); err != nil {
+log.Printf("Error unmarshaling order completed event: %v", err)
+return nil
 }
 
-// Create update request with only quantity
-quantityPtr := new(int)
-*quantityPtr = newQuantity
+// Delivered orders count more towards recommendations
+var productIDs []string
+for _, item := range orderEvent.Items {
+productIDs = append(productIDs, item.ProductID)
+}
+if err := c.service.RecordPurchase(orderEvent.OrderID, orderEvent.CustomerID, productIDs, true); err != nil {
+log.Printf("Error recording completed purchase of order %s: %v", orderEvent.OrderID, err)
+}
+} else if event.EventType == "order_cancelled" {
+var orderEvent struct {
+OrderID string `json:"order_id"`
+}
+if err := json.Unmarshal(value, &orderEvent); err != nil {
+log.Printf("Error unmarshaling order cancelled event: %v", err)
+return nil
+}
 
-// UpdateProduct returns (models.Product, error)
-_, err = c.service.UpdateProduct(item.ProductID, models.UpdateProductRequest{
-Quantity: quantityPtr,
-})
-if err != nil {
-log.Printf("Error updating inventory for product %s: %v", item.ProductID, err)
+// Cancelled orders weren't bought
+if err := c.service.CancelPurchase(orderEvent.OrderID); err != nil {
+log.Printf("Error forgetting purchase of order %s: %v", orderEvent.OrderID, err)
 }
 }
+return nil
 }
+
+// processPaymentMessage processes a message from the payments topic
+func (c *Consumer) processPaymentMessage(ctx context.Context, key, value []byte) error {
+log.Printf("Received message from Kafka payments topic: %s", string(value))
+
+// Process the message
+var event struct {
+EventType string `json:"event_type"`
 }
+if err := json.Unmarshal(value, &event); err != nil {
+log.Printf("Error unmarshaling message from payments topic: %v", err)
+return nil
+}
+
+// Process the event based on its type
+if event.EventType == "payment_failed" {
+log.Printf("Processing payment failed event from payments topic")
+var paymentEvent struct {
+OrderID string `json:"order_id"`
+}
+if err := json.Unmarshal(value, &paymentEvent); err != nil {
+log.Printf("Error unmarshaling payment failed event: %v", err)
+return nil
+}
+
+// Get order details to restore inventory
+// We need to make a request to the order service to get the order details
+// For now, we'll just log that we received the event
+log.Printf("Payment failed for order %s, should restore inventory", paymentEvent.OrderID)
+
+// Unpaid orders weren't bought
+if err := c.service.CancelPurchase(paymentEvent.OrderID); err != nil {
+log.Printf("Error forgetting purchase of order %s: %v", paymentEvent.OrderID, err)
+}
+
+// TODO: Implement inventory restoration logic
+// This would require getting the order details from the order service
+// and then updating the inventory for each item in the order
 }
-}()
+return nil
 }
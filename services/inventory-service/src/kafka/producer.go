@@ -6,36 +6,36 @@ import (
 "log"
 "time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/inventory-service/config"
+"github.com/online-order-system/inventory-service/eventbus"
 "github.com/online-order-system/inventory-service/interfaces"
 "github.com/online-order-system/inventory-service/models"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-writer *kafka.Writer
+bus          eventbus.Bus
+topic        string
+cacheTopic   string
+privacyTopic string
 }
 
 // Ensure Producer implements InventoryProducer interface
 var _ interfaces.InventoryProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-writer := &kafka.Writer{
-Addr:     kafka.TCP(cfg.KafkaBootstrapServers),
-Topic:    cfg.KafkaTopic,
-Balancer: &kafka.LeastBytes{},
-}
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 return &Producer{
-writer: writer,
+bus:          bus,
+topic:        cfg.KafkaTopic,
+cacheTopic:   cfg.CacheTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-return p.writer.Close()
+return nil
 }
 
 // PublishInventoryUpdated publishes an inventory updated event
@@ -50,6 +50,121 @@ Timestamp:  time.Now().Unix(),
 return p.publishEvent(event)
 }
 
+// PublishStockAlert publishes an inventory_low or out_of_stock event when the
+// stock of a product crosses a threshold
+func (p *Producer) PublishStockAlert(alert models.StockAlert) error {
+eventType := "inventory_low"
+if alert.Status == models.StockStatusOutOfStock {
+eventType = "out_of_stock"
+}
+
+event := models.InventoryEvent{
+EventType:         eventType,
+ProductID:         alert.ProductID,
+Quantity:          alert.Quantity,
+Timestamp:         time.Now().Unix(),
+ProductName:       alert.ProductName,
+LowStockThreshold: alert.LowStockThreshold,
+ReorderPoint:      alert.ReorderPoint,
+DaysOfCover:       alert.DaysOfCover,
+}
+
+return p.publishEvent(event)
+}
+
+// PublishProductStatusChanged publishes a product_discontinued event when a
+// product comes off sale, discontinued or archived, and a product_reactivated
+// event when it goes back on sale
+func (p *Producer) PublishProductStatusChanged(product models.Product) error {
+eventType := "product_discontinued"
+if product.Listed() {
+eventType = "product_reactivated"
+}
+
+event := models.InventoryEvent{
+EventType:   eventType,
+ProductID:   product.ID,
+Quantity:    product.Quantity,
+Timestamp:   time.Now().Unix(),
+ProductName: product.Name,
+Status:      product.Status,
+}
+
+return p.publishEvent(event)
+}
+
+// PublishPriceChanged publishes a price_changed event when the price of a
+// product changes
+func (p *Producer) PublishPriceChanged(change models.PriceChange) error {
+event := models.InventoryEvent{
+EventType:     "price_changed",
+ProductID:     change.ProductID,
+Timestamp:     time.Now().Unix(),
+Price:         change.Price,
+PreviousPrice: change.PreviousPrice,
+Reason:        change.Reason,
+}
+
+return p.publishEvent(event)
+}
+
+// PublishBackorderAllocated publishes a backorder allocated event
+func (p *Producer) PublishBackorderAllocated(event models.BackorderEvent) error {
+event.EventType = "backorder_allocated"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+// Keyed by order, so the events of an order stay in order
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.OrderID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for order %s", event.EventType, event.OrderID)
+return nil
+}
+
+// PublishCacheInvalidated tells the other replicas which cached values are
+// stale
+func (p *Producer) PublishCacheInvalidated(names []string) error {
+event := models.CacheEvent{
+EventType: "cache_invalidated",
+Names:     names,
+Timestamp: time.Now().Unix(),
+}
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+return p.bus.Publish(context.Background(), p.cacheTopic, nil, eventJSON)
+}
+
+// PublishErasureCompleted reports to user-service what was erased for an
+// erasure request
+func (p *Producer) PublishErasureCompleted(event models.ErasureEvent) error {
+event.EventType = "user_erasure_completed"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+err = p.bus.Publish(context.Background(), p.privacyTopic, []byte(event.CustomerID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for erasure %s", event.EventType, event.RequestID)
+return nil
+}
+
 // publishEvent publishes an event to Kafka
 func (p *Producer) publishEvent(event models.InventoryEvent) error {
 // Convert event to JSON
@@ -58,14 +173,8 @@ if err != nil {
 return err
 }
 
-// Create message
-message := kafka.Message{
-Key:   []byte(event.ProductID),
-Value: eventJSON,
-}
-
 // Write message
-err = p.writer.WriteMessages(context.Background(), message)
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.ProductID), eventJSON)
 if err != nil {
 return err
 }
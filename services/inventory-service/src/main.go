@@ -8,88 +8,36 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/online-order-system/inventory-service/api"
-	"github.com/online-order-system/inventory-service/cache"
+	"github.com/online-order-system/inventory-service/app"
 	"github.com/online-order-system/inventory-service/config"
-	"github.com/online-order-system/inventory-service/db"
-	"github.com/online-order-system/inventory-service/kafka"
-	"github.com/online-order-system/inventory-service/service"
+	"github.com/online-order-system/inventory-service/eventbus"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
-	// Connect to database
-	database, err := db.NewDatabase(cfg)
+	// Create event bus
+	bus, err := eventbus.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to create event bus: %v", err)
 	}
-	defer database.Close()
+	defer bus.Close()
 
-	// Create tables
-	err = database.CreateTables()
+	// Create application
+	application, err := app.New(cfg, bus)
 	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+		log.Fatalf("Failed to create application: %v", err)
 	}
-
-	// Create repository
-	repository := db.NewInventoryRepository(database)
-
-	// Create Redis cache
-	redisCache, err := cache.NewRedisCache(cfg)
-	if err != nil {
-		log.Printf("Failed to connect to Redis: %v", err)
-		log.Println("Continuing without Redis cache...")
-		redisCache = nil
-	} else {
-		log.Println("Connected to Redis cache")
-		defer redisCache.Close()
-	}
-
-	// Create Kafka producer
-	producer := kafka.NewProducer(cfg)
-	defer producer.Close()
-
-	// Create service
-	inventoryService := service.NewInventoryService(cfg, repository, producer, redisCache)
-
-	// Create Kafka consumer
-	consumer := kafka.NewConsumer(cfg, inventoryService)
+	defer application.Close()
 
 	// Start Kafka consumer
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	consumer.StartConsuming(ctx)
+	application.Start(ctx)
 
 	// Set up router
-	router := api.SetupRouter(inventoryService)
-
-	// Start cache refresh goroutine
-	if redisCache != nil {
-		go func() {
-			// Refresh cache every 5 minutes
-			ticker := time.NewTicker(5 * time.Minute)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					log.Println("Performing scheduled cache refresh...")
-					// Delete products:all cache to force refresh on next query
-					err := redisCache.Delete(ctx, "products:all")
-					if err != nil {
-						log.Printf("Failed to refresh products cache: %v", err)
-					} else {
-						log.Println("Successfully refreshed products cache")
-					}
-				}
-			}
-		}()
-		log.Println("Started automatic cache refresh (every 5 minutes)")
-	}
+	router := application.Router
 
 	// Start server
 	go func() {
@@ -6,18 +6,108 @@ import (
 
 // Product represents a product in the system with its inventory information
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CategoryID  string    `json:"category_id"`
-	Price       float64   `json:"price"`
-	Quantity    int       `json:"quantity"`    // Integrated inventory quantity
-	Tags        []string  `json:"tags,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string     `json:"id"`
+	Code         string     `json:"sku,omitempty"` // External SKU, unique across products and SKUs
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	CategoryID   string     `json:"category_id"`
+	Price        float64    `json:"price"`
+	Quantity     int        `json:"quantity"` // Integrated inventory quantity
+	Tags         []string   `json:"tags,omitempty"`
+	Availability string     `json:"availability"`           // How the product sells when out of stock
+	AvailableAt  *time.Time `json:"available_at,omitempty"` // When backordered or pre-ordered stock is expected
+	Status       string     `json:"status"`                 // Where the product is in its lifecycle
+	WasPrice     *float64   `json:"was_price,omitempty"`    // Regular price while on sale, Price being the sale price
+	SaleEndsAt   *time.Time `json:"sale_ends_at,omitempty"` // When the price goes back to WasPrice
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Products sold in variants list their options and the SKUs sold, with
+	// the stock of each. Their quantity is the stock of all of their SKUs.
+	Options  []VariantOption `json:"options,omitempty"`
+	SKUs     []SKU           `json:"skus,omitempty"`
+	ParentID string          `json:"-"` // Product a SKU is a variant of, SKUs are kept as products
+}
+
+// VariantOption is an option a product is sold in, such as size or colour,
+// with the values its SKUs can take
+type VariantOption struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Values []string `json:"values" binding:"required,min=1,dive,required,max=50"`
+}
+
+// SKU is a variant of a product, with one value of each option of the
+// product, its own stock and barcode. Price is its price override or, without
+// one, the price of the product. Available tells whether it can be ordered:
+// it is in stock or its product sells without stock.
+type SKU struct {
+	ID            string            `json:"id"`
+	ProductID     string            `json:"product_id"`
+	Code          string            `json:"sku"`
+	Barcode       string            `json:"barcode,omitempty"`
+	Name          string            `json:"name"`
+	Options       map[string]string `json:"options"`
+	Price         float64           `json:"price"`
+	PriceOverride *float64          `json:"price_override,omitempty"`
+	WasPrice      *float64          `json:"was_price,omitempty"` // Of SKUs sold at the sale price of their product
+	Quantity      int               `json:"quantity"`
+	Available     bool              `json:"available"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// CreateSKURequest represents a request to add a SKU to a product
+type CreateSKURequest struct {
+	Code          string            `json:"sku" binding:"required,max=64"`
+	Barcode       string            `json:"barcode" binding:"max=64"`
+	Options       map[string]string `json:"options" binding:"required"`
+	PriceOverride *float64          `json:"price_override" binding:"omitempty,gt=0"`
+	Quantity      int               `json:"quantity" binding:"min=0"` // Initial stock, in the default warehouse
+	Caller        string            `json:"-"`                        // Set by the handler for the stock ledger
 }
 
-// Inventory represents the inventory of a product
+// UpdateSKURequest represents a request to update a SKU. A price override of
+// 0 sells the SKU at the price of its product again. Stock is set per
+// warehouse like the stock of products.
+type UpdateSKURequest struct {
+	Code          string   `json:"sku" binding:"max=64"`
+	Barcode       *string  `json:"barcode" binding:"omitempty,max=64"`
+	PriceOverride *float64 `json:"price_override" binding:"omitempty,min=0"`
+}
+
+// How a product sells when it is out of stock. Orders for backorder and
+// pre-order products wait for stock instead of failing.
+const (
+	AvailabilityStock     = "stock"
+	AvailabilityBackorder = "backorder"
+	AvailabilityPreorder  = "preorder"
+)
+
+// Statuses of a product in its lifecycle. Only active products are listed on
+// the storefront and can be ordered. Discontinued products can still be
+// viewed, for the carts and orders that hold them; draft and archived
+// products are only seen by staff. Deleting a product archives it, so orders
+// and recommendations referencing it keep working.
+const (
+	ProductStatusDraft        = "draft"
+	ProductStatusActive       = "active"
+	ProductStatusDiscontinued = "discontinued"
+	ProductStatusArchived     = "archived"
+)
+
+// Listed reports whether the storefront lists the product
+func (p Product) Listed() bool {
+	return p.Status == ProductStatusActive
+}
+
+// Viewable reports whether the storefront shows the product to whoever has
+// its ID
+func (p Product) Viewable() bool {
+	return p.Status == ProductStatusActive || p.Status == ProductStatusDiscontinued
+}
+
+// Inventory represents the inventory of a product. Quantity is the total
+// across all warehouses.
 type Inventory struct {
 	ProductID string    `json:"product_id"`
 	Quantity  int       `json:"quantity"`
@@ -38,27 +128,90 @@ type ProductSimilarity struct {
 	ProductID        string    `json:"product_id"`
 	SimilarProductID string    `json:"similar_product_id"`
 	SimilarityScore  float64   `json:"similarity_score"`
+	Kind             string    `json:"kind"`
+	Support          int       `json:"support"` // Orders or customers that bought both products
 	CreatedAt        time.Time `json:"created_at"`
 }
 
+// Kinds of product similarities, learnt from the orders customers placed.
+// Products are frequently bought together when they are in the same orders,
+// and customers who bought one also bought the other when the same
+// customers bought them, in any of their orders.
+const (
+	SimilarityBoughtTogether = "bought_together"
+	SimilarityAlsoBought     = "also_bought"
+)
+
+// Purchase is a product bought in an order. Completed orders were delivered.
+type Purchase struct {
+	OrderID    string    `json:"order_id"`
+	ProductID  string    `json:"product_id"`
+	CustomerID string    `json:"customer_id"`
+	Completed  bool      `json:"completed"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CustomerData is the personal data inventory-service holds about a
+// customer, as it appears in a data export
+type CustomerData struct {
+	CustomerID string     `json:"customer_id"`
+	Purchases  []Purchase `json:"purchases"`
+}
+
+// Statuses a service reports for an erasure
+const (
+	ErasureCompleted = "completed"
+	ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+	EventType  string `json:"event_type"`
+	RequestID  string `json:"request_id"`
+	CustomerID string `json:"customer_id"`
+	Email      string `json:"email,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+
+	// Set on user_erasure_completed events
+	Service  string `json:"service,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Erased   int    `json:"erased,omitempty"`
+	Retained int    `json:"retained,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
 // CreateProductRequest represents a request to create a new product
 type CreateProductRequest struct {
-	Name        string   `json:"name" binding:"required"`
-	Description string   `json:"description" binding:"required"`
-	CategoryID  string   `json:"category_id" binding:"required"`
-	Price       float64  `json:"price" binding:"required"`
-	Tags        []string `json:"tags,omitempty"`
-	Quantity    int      `json:"quantity"`  // Initial inventory quantity, defaults to 0 if not provided
+	Code         string          `json:"sku" binding:"max=64"`
+	Name         string          `json:"name" binding:"required"`
+	Description  string          `json:"description" binding:"required"`
+	CategoryID   string          `json:"category_id" binding:"required"`
+	Price        float64         `json:"price" binding:"required"`
+	Tags         []string        `json:"tags,omitempty"`
+	Quantity     int             `json:"quantity"` // Initial inventory quantity, defaults to 0 if not provided
+	Availability string          `json:"availability" binding:"omitempty,oneof=stock backorder preorder"`
+	AvailableAt  *time.Time      `json:"available_at,omitempty"`
+	Status       string          `json:"status" binding:"omitempty,oneof=draft active"`
+	Options      []VariantOption `json:"options,omitempty" binding:"dive"` // Options the SKUs of the product are picked from
+	Caller       string          `json:"-"`                                // Set by the handler for the stock ledger
 }
 
 // UpdateProductRequest represents a request to update a product
 type UpdateProductRequest struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	CategoryID  string   `json:"category_id"`
-	Price       float64  `json:"price"`
-	Tags        []string `json:"tags,omitempty"`
-	Quantity    *int     `json:"quantity,omitempty"`  // Optional inventory quantity update
+	Code         string          `json:"sku" binding:"max=64"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	CategoryID   string          `json:"category_id"`
+	Price        float64         `json:"price"`
+	Tags         []string        `json:"tags,omitempty"`
+	Quantity     *int            `json:"quantity,omitempty"` // Optional inventory quantity update
+	Availability string          `json:"availability" binding:"omitempty,oneof=stock backorder preorder"`
+	AvailableAt  *time.Time      `json:"available_at,omitempty"`
+	Status       string          `json:"status" binding:"omitempty,oneof=draft active discontinued archived"`
+	Options      []VariantOption `json:"options,omitempty" binding:"dive"` // Replaces the options when set
+	Caller       string          `json:"-"`                                // Set by the handler for the stock ledger
 }
 
 
@@ -67,6 +220,7 @@ type UpdateProductRequest struct {
 type InventoryCheckRequest struct {
 	Items []struct {
 		ProductID string `json:"product_id"`
+		SKUID     string `json:"sku_id,omitempty"` // Required for products sold in variants
 		Quantity  int    `json:"quantity"`
 	} `json:"items"`
 }
@@ -76,23 +230,253 @@ type InventoryCheckResponse struct {
 	Available        bool `json:"available"`
 	UnavailableItems []struct {
 		ProductID   string `json:"product_id"`
+		SKUID       string `json:"sku_id,omitempty"`
+		ProductName string `json:"product_name"`
+		Requested   int    `json:"requested"`
+		Available   int    `json:"available"`
+	} `json:"unavailable_items,omitempty"`
+}
+
+// Warehouse is a location stock is kept in and shipped from. The default
+// warehouse receives the stock set on products directly.
+type Warehouse struct {
+	ID        string    `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country"`
+	Province  string    `json:"province,omitempty"`
+	City      string    `json:"city,omitempty"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateWarehouseRequest represents a request to create a warehouse
+type CreateWarehouseRequest struct {
+	Code      string `json:"code" binding:"required,max=20"`
+	Name      string `json:"name" binding:"required,max=100"`
+	Country   string `json:"country" binding:"required,len=2"`
+	Province  string `json:"province" binding:"max=100"`
+	City      string `json:"city" binding:"max=100"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// UpdateWarehouseRequest represents a request to update a warehouse
+type UpdateWarehouseRequest struct {
+	Name      string `json:"name" binding:"max=100"`
+	Country   string `json:"country" binding:"omitempty,len=2"`
+	Province  string `json:"province" binding:"max=100"`
+	City      string `json:"city" binding:"max=100"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// StockLevel is the stock of a product in one warehouse
+type StockLevel struct {
+	ProductID     string    `json:"product_id"`
+	WarehouseID   string    `json:"warehouse_id"`
+	WarehouseCode string    `json:"warehouse_code,omitempty"`
+	Quantity      int       `json:"quantity"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ProductStock is the stock of a product across warehouses
+type ProductStock struct {
+	ProductID         string       `json:"product_id"`
+	Total             int          `json:"total"`
+	LowStockThreshold int          `json:"low_stock_threshold"`
+	ReorderPoint      int          `json:"reorder_point"`
+	Locations         []StockLevel `json:"locations"`
+}
+
+// SetStockRequest represents a request to set the stock of a product in a
+// warehouse
+type SetStockRequest struct {
+	Quantity *int   `json:"quantity" binding:"required,min=0"`
+	Caller   string `json:"-"` // Set by the handler for the stock ledger
+}
+
+// StockTransfer is stock moved from one warehouse to another
+type StockTransfer struct {
+	ID              string    `json:"id"`
+	ProductID       string    `json:"product_id"`
+	FromWarehouseID string    `json:"from_warehouse_id"`
+	ToWarehouseID   string    `json:"to_warehouse_id"`
+	Quantity        int       `json:"quantity"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TransferStockRequest represents a request to move stock between warehouses
+type TransferStockRequest struct {
+	ProductID       string `json:"product_id" binding:"required"`
+	FromWarehouseID string `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   string `json:"to_warehouse_id" binding:"required"`
+	Quantity        int    `json:"quantity" binding:"required,min=1"`
+	Caller          string `json:"-"` // Set by the handler for the stock ledger
+}
+
+// Location is the part of a shipping address used to find the nearest
+// warehouse
+type Location struct {
+	Country  string `json:"country"`
+	Province string `json:"province,omitempty"`
+	City     string `json:"city,omitempty"`
+}
+
+// ReserveStockRequest represents a request to reserve the stock of an order
+type ReserveStockRequest struct {
+	OrderID string `json:"order_id" binding:"required"`
+	Items   []struct {
+		ProductID string `json:"product_id"`
+		SKUID     string `json:"sku_id,omitempty"` // Required for products sold in variants
+		Quantity  int    `json:"quantity"`
+	} `json:"items" binding:"required"`
+	ShippingAddress Location `json:"shipping_address"`
+}
+
+// StockAllocation is the part of an order item taken from one warehouse
+type StockAllocation struct {
+	ProductID   string `json:"product_id"`
+	SKUID       string `json:"sku_id,omitempty"`
+	WarehouseID string `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+}
+
+// StockID is the ID the stock of an allocation is kept under: its SKU or,
+// for products without variants, its product
+func (a StockAllocation) StockID() string {
+	return StockID(a.ProductID, a.SKUID)
+}
+
+// StockID is the ID the stock of a product is kept under, or of one of its
+// SKUs when skuID is set
+func StockID(productID, skuID string) string {
+	if skuID != "" {
+		return skuID
+	}
+	return productID
+}
+
+// Reservation is the stock reserved for an order. WarehouseID is the
+// fulfilment location; when the order is split it is the warehouse sending
+// the most units.
+type Reservation struct {
+	OrderID          string            `json:"order_id"`
+	Available        bool              `json:"available"`
+	Strategy         string            `json:"strategy,omitempty"`
+	WarehouseID      string            `json:"warehouse_id,omitempty"`
+	Allocations      []StockAllocation `json:"allocations,omitempty"`
+	Waiting          []WaitingItem     `json:"waiting,omitempty"`
+	UnavailableItems []struct {
+		ProductID   string `json:"product_id"`
+		SKUID       string `json:"sku_id,omitempty"`
 		ProductName string `json:"product_name"`
 		Requested   int    `json:"requested"`
 		Available   int    `json:"available"`
 	} `json:"unavailable_items,omitempty"`
 }
 
-// InventoryEvent represents an event related to inventory
+// RestoreStockRequest represents a request to put stock back. With an order
+// ID the stock returns to the warehouses it was reserved from, otherwise it
+// goes to the default warehouse.
+type RestoreStockRequest struct {
+	OrderID   string `json:"order_id"`
+	ProductID string `json:"product_id" binding:"required"`
+	SKUID     string `json:"sku_id"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	Caller    string `json:"-"` // Set by the handler for the stock ledger
+}
+
+// Types of stock movements
+const (
+	// MovementReceipt is stock received into a warehouse
+	MovementReceipt = "receipt"
+	// MovementReservation is stock taken for an order
+	MovementReservation = "reservation"
+	// MovementCommit marks stock taken for an order as sold. The stock
+	// already left with the reservation, so its quantity is 0.
+	MovementCommit = "commit"
+	// MovementRelease is stock put back from an order that wasn't sold
+	MovementRelease = "release"
+	// MovementAdjustment is stock counted or set by hand
+	MovementAdjustment = "adjustment"
+	// MovementReturn is stock coming back after it was sold
+	MovementReturn = "return"
+	// MovementTransfer is stock leaving or arriving at a warehouse in a
+	// transfer
+	MovementTransfer = "transfer"
+)
+
+// StockMovement is an entry in the stock ledger. The ledger is append-only:
+// the stock of a product in a warehouse is the sum of its movements.
+// Reference is the order ID or the caller that moved the stock.
+type StockMovement struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	WarehouseID string    `json:"warehouse_id"`
+	Type        string    `json:"type"`
+	Quantity    int       `json:"quantity"`
+	Reference   string    `json:"reference"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MovementFilter selects stock movements. Zero times leave the range open.
+type MovementFilter struct {
+	ProductID   string
+	WarehouseID string
+	From        time.Time
+	To          time.Time
+}
+
+// LocationReconciliation compares the stored stock of a product in a
+// warehouse with the sum of its movements
+type LocationReconciliation struct {
+	WarehouseID string `json:"warehouse_id"`
+	Stored      int    `json:"stored"`
+	Ledger      int    `json:"ledger"`
+	Difference  int    `json:"difference"`
+}
+
+// StockReconciliation compares the stored stock of a product with the
+// ledger. Stored is the total kept in inventory; Balanced is false when it or
+// any warehouse differs from the ledger.
+type StockReconciliation struct {
+	ProductID string                   `json:"product_id"`
+	Stored    int                      `json:"stored"`
+	Ledger    int                      `json:"ledger"`
+	Balanced  bool                     `json:"balanced"`
+	Locations []LocationReconciliation `json:"locations"`
+}
+
+// InventoryEvent represents an event related to inventory. Stock alerts also
+// carry the product name, its thresholds and days of cover.
 type InventoryEvent struct {
-	EventType string `json:"event_type"`
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
-	Timestamp int64  `json:"timestamp"`
+	EventType         string   `json:"event_type"`
+	ProductID         string   `json:"product_id"`
+	Quantity          int      `json:"quantity"`
+	Timestamp         int64    `json:"timestamp"`
+	ProductName       string   `json:"product_name,omitempty"`
+	LowStockThreshold int      `json:"low_stock_threshold,omitempty"`
+	ReorderPoint      int      `json:"reorder_point,omitempty"`
+	DaysOfCover       *float64 `json:"days_of_cover,omitempty"`
+	Status            string   `json:"status,omitempty"` // Of product_discontinued and product_reactivated events
+	Price             float64  `json:"price,omitempty"`  // Of price_changed events
+	PreviousPrice     float64  `json:"previous_price,omitempty"`
+	Reason            string   `json:"reason,omitempty"`
+}
+
+// CacheEvent tells the replicas of the service which cached values are stale
+// (cache_invalidated). Names are the names the values are cached under, such
+// as product:<id>.
+type CacheEvent struct {
+	EventType string   `json:"event_type"`
+	Names     []string `json:"names"`
+	Timestamp int64    `json:"timestamp"`
 }
 
 // RecommendationRequest represents a request for product recommendations
 type RecommendationRequest struct {
 	ProductID  string   `json:"product_id,omitempty"`
+	Kind       string   `json:"kind,omitempty" binding:"omitempty,oneof=bought_together also_bought"` // Of the recommendations for a product
 	CategoryID string   `json:"category_id,omitempty"`
 	Tags       []string `json:"tags,omitempty"`
 	PriceMin   float64  `json:"price_min,omitempty"`
@@ -104,3 +488,291 @@ type RecommendationRequest struct {
 type RecommendationResponse struct {
 	Products []Product `json:"products"`
 }
+
+// Stock alert statuses. A product is low on stock at or below its low stock
+// threshold and out of stock at zero.
+const (
+	StockStatusLow        = "low_stock"
+	StockStatusOutOfStock = "out_of_stock"
+)
+
+// StockThresholds are the stock levels at which a product is low on stock and
+// should be reordered. Levels that are not set use the service defaults.
+type StockThresholds struct {
+	LowStockThreshold *int `json:"low_stock_threshold" binding:"omitempty,min=0"`
+	ReorderPoint      *int `json:"reorder_point" binding:"omitempty,min=0"`
+}
+
+// StockAlert is a product that is low on or out of stock. DaysOfCover is how
+// long the stock lasts at the recent rate of sales, nil when nothing sold.
+type StockAlert struct {
+	ProductID         string   `json:"product_id"`
+	ProductName       string   `json:"product_name"`
+	Status            string   `json:"status"`
+	Quantity          int      `json:"quantity"`
+	LowStockThreshold int      `json:"low_stock_threshold"`
+	ReorderPoint      int      `json:"reorder_point"`
+	Reorder           bool     `json:"reorder"` // At or below the reorder point
+	DailySales        float64  `json:"daily_sales"`
+	DaysOfCover       *float64 `json:"days_of_cover"`
+}
+
+// WaitingItem is the part of an order line waiting for stock of a backorder
+// or pre-order product
+type WaitingItem struct {
+	ProductID    string     `json:"product_id"`
+	SKUID        string     `json:"sku_id,omitempty"`
+	Quantity     int        `json:"quantity"`
+	Availability string     `json:"availability"`
+	ExpectedAt   *time.Time `json:"expected_at,omitempty"`
+}
+
+// Backorder is the part of an order line waiting for stock. Stock received
+// for a product goes to its backorders first in, first out.
+type Backorder struct {
+	ID              string     `json:"id"`
+	OrderID         string     `json:"order_id"`
+	ProductID       string     `json:"product_id"`
+	SKUID           string     `json:"sku_id,omitempty"`
+	Quantity        int        `json:"quantity"`  // Units still waiting
+	Requested       int        `json:"requested"` // Units that went on the queue
+	Availability    string     `json:"availability"`
+	ShippingAddress Location   `json:"-"`
+	Committed       bool       `json:"-"` // The order was confirmed
+	CreatedAt       time.Time  `json:"created_at"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+}
+
+// BackorderEvent reports stock reserved for a backorder (backorder_allocated).
+// Remaining is the number of units of the order still waiting.
+type BackorderEvent struct {
+	EventType   string            `json:"event_type"`
+	OrderID     string            `json:"order_id"`
+	ProductID   string            `json:"product_id"`
+	SKUID       string            `json:"sku_id,omitempty"`
+	Allocations []StockAllocation `json:"allocations"`
+	Remaining   int               `json:"remaining"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+// Orders of product search results
+const (
+	SearchSortRelevance = "relevance"
+	SearchSortPriceAsc  = "price_asc"
+	SearchSortPriceDesc = "price_desc"
+	SearchSortNewest    = "newest"
+)
+
+// ProductSearch selects products matching a query and filters. Products match
+// when every word of the query is in their name, description or tags. Cursor
+// continues from the page it was returned with.
+type ProductSearch struct {
+	Query      string
+	CategoryID string
+	MinPrice   *float64
+	MaxPrice   *float64
+	Tags       []string
+	InStock    bool
+	Status     string // Only products with this status, any when empty
+	Sort       string
+	Cursor     string
+	Limit      int
+
+	// Also match products in the descendants of the category
+	IncludeDescendants bool
+}
+
+// FacetCount is the number of matching products with a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets counts the matching products per category and tag. Category
+// counts ignore the category filter, so other categories can be offered.
+type SearchFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Tags       []FacetCount `json:"tags"`
+}
+
+// ProductSearchResult is a page of search results. Total counts every
+// matching product; NextCursor is empty on the last page.
+type ProductSearchResult struct {
+	Products   []Product    `json:"products"`
+	Total      int          `json:"total"`
+	Facets     SearchFacets `json:"facets"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// Category is a node of the category tree. Children are listed by position,
+// then name. ProductCount counts the products in the category and all of its
+// descendants.
+type Category struct {
+	ID           string        `json:"id"`
+	ParentID     string        `json:"parent_id,omitempty"` // Empty for top-level categories
+	Name         string        `json:"name"`
+	Slug         string        `json:"slug"`
+	Position     int           `json:"position"`
+	Path         []CategoryRef `json:"path,omitempty"` // Breadcrumb from the top-level category down to this one
+	ProductCount int           `json:"product_count"`
+	Children     []Category    `json:"children,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// CategoryRef is a category in a breadcrumb
+type CategoryRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CreateCategoryRequest represents a request to create a category. The slug
+// is made from the name when it is not given.
+type CreateCategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Slug     string `json:"slug" binding:"max=100"`
+	ParentID string `json:"parent_id"`
+	Position int    `json:"position"`
+}
+
+// UpdateCategoryRequest represents a request to update a category. An empty
+// parent_id moves the category to the top level.
+type UpdateCategoryRequest struct {
+	Name     string  `json:"name" binding:"max=100"`
+	Slug     string  `json:"slug" binding:"max=100"`
+	ParentID *string `json:"parent_id"`
+	Position *int    `json:"position"`
+}
+
+// Formats of catalogue imports and exports. JSON Lines has one JSON object per
+// line.
+const (
+	CatalogueFormatCSV   = "csv"
+	CatalogueFormatJSONL = "jsonl"
+)
+
+// CatalogueRow is a product or SKU in a catalogue import or export. Rows are
+// matched with products by SKU: a matching product is updated, otherwise one
+// is created. Rows with a parent SKU are SKUs of that product. Empty fields
+// leave a product as it is.
+type CatalogueRow struct {
+	SKU          string            `json:"sku"`
+	ParentSKU    string            `json:"parent_sku,omitempty"`
+	Name         string            `json:"name,omitempty"` // Of products; SKUs are named after their product
+	Description  string            `json:"description,omitempty"`
+	CategoryID   string            `json:"category_id,omitempty"`
+	Price        *float64          `json:"price,omitempty"` // Price override of SKUs
+	Tags         []string          `json:"tags,omitempty"`
+	Availability string            `json:"availability,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	Options      []VariantOption   `json:"options,omitempty"`       // Of products sold in variants
+	OptionValues map[string]string `json:"option_values,omitempty"` // Of SKUs
+	Barcode      string            `json:"barcode,omitempty"`       // Of SKUs
+	Quantity     *int              `json:"quantity,omitempty"`      // Stock in the warehouse
+	Warehouse    string            `json:"warehouse,omitempty"`     // Code of the warehouse, the default one when empty
+}
+
+// Statuses of import jobs. A completed import may still have failed rows;
+// failed imports stopped before reading every row.
+const (
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// ImportJob is a catalogue import running in the background. Rows are
+// imported one at a time, so rows before a failed one stay imported. A dry
+// run only validates the rows, counting the products it would create and
+// update.
+type ImportJob struct {
+	ID            string        `json:"id"`
+	Format        string        `json:"format"`
+	DryRun        bool          `json:"dry_run"`
+	Status        string        `json:"status"`
+	TotalRows     int           `json:"total_rows"`
+	ProcessedRows int           `json:"processed_rows"`
+	Created       int           `json:"created"`
+	Updated       int           `json:"updated"`
+	Failed        int           `json:"failed"`
+	Errors        []ImportError `json:"errors"`           // Failed rows, the first thousand
+	Reason        string        `json:"reason,omitempty"` // Why a failed import stopped
+	Caller        string        `json:"-"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+}
+
+// ImportError is a row that couldn't be imported. Line is where the row is in
+// the file, counting the CSV header.
+type ImportError struct {
+	Line  int    `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// Statuses of price schedules. A schedule is active while its price is the
+// price of the product; schedules without an end complete once applied.
+const (
+	PriceScheduleScheduled = "scheduled"
+	PriceScheduleActive    = "active"
+	PriceScheduleCompleted = "completed"
+	PriceScheduleCancelled = "cancelled"
+)
+
+// PriceSchedule is a price a product sells at from StartsAt. A sale price has
+// an end, when the product goes back to the price it had; a schedule without
+// one changes the price for good.
+type PriceSchedule struct {
+	ID        string     `json:"id"`
+	ProductID string     `json:"product_id"`
+	Price     float64    `json:"price"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Status    string     `json:"status"`
+	Caller    string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CreatePriceScheduleRequest represents a request to schedule a price. Prices
+// starting in the past start now.
+type CreatePriceScheduleRequest struct {
+	Price    float64    `json:"price" binding:"required,gt=0"`
+	StartsAt time.Time  `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Caller   string     `json:"-"` // Set by the handler for the price history
+}
+
+// Why the price of a product changed
+const (
+	PriceChangeCreated   = "created"   // The product was created at the price
+	PriceChangeUpdated   = "updated"   // The product was updated or imported
+	PriceChangeScheduled = "scheduled" // A price schedule started
+	PriceChangeReverted  = "reverted"  // A sale ended or was cancelled
+)
+
+// PriceChange is an entry in the price history of a product, a change to the
+// price it sells at. Reference is the caller or the price schedule that
+// changed it.
+type PriceChange struct {
+	ID            string    `json:"id"`
+	ProductID     string    `json:"product_id"`
+	Price         float64   `json:"price"`
+	PreviousPrice float64   `json:"previous_price"`
+	Reason        string    `json:"reason"`
+	Reference     string    `json:"reference"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// PriceHistory is the price of a product, the regular price while on sale,
+// its price history, oldest first, and its upcoming and running price
+// schedules
+type PriceHistory struct {
+	ProductID  string          `json:"product_id"`
+	Price      float64         `json:"price"`
+	WasPrice   *float64        `json:"was_price,omitempty"`
+	SaleEndsAt *time.Time      `json:"sale_ends_at,omitempty"`
+	Changes    []PriceChange   `json:"changes"`
+	Schedules  []PriceSchedule `json:"schedules"`
+}
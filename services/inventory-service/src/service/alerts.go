@@ -0,0 +1,190 @@
+package service
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// SetStockThresholds sets the stock thresholds of a product. Thresholds left
+// out keep their current value.
+func (s *InventoryService) SetStockThresholds(productID string, req models.StockThresholds) (models.ProductStock, error) {
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+
+	thresholds, _, err := s.repository.GetStockThresholds(productID)
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+	if req.LowStockThreshold != nil {
+		thresholds.LowStockThreshold = req.LowStockThreshold
+	}
+	if req.ReorderPoint != nil {
+		thresholds.ReorderPoint = req.ReorderPoint
+	}
+
+	err = s.repository.SetStockThresholds(productID, thresholds, time.Now())
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+
+	// A new threshold can put the product below it
+	s.checkStockAlert(product)
+
+	return s.GetProductStock(productID)
+}
+
+// GetStockAlerts retrieves the products at or below their low stock threshold
+// with their days of cover, most urgent first
+func (s *InventoryService) GetStockAlerts() ([]models.StockAlert, error) {
+	alerts, err := s.repository.GetLowStockProducts(s.config.LowStockThreshold, s.config.ReorderPoint)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return alerts, nil
+	}
+
+	sales, err := s.repository.GetSales(s.salesSince(), "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range alerts {
+		s.fillStockAlert(&alerts[i], sales[alerts[i].ProductID])
+	}
+
+	sortStockAlerts(alerts)
+	return alerts, nil
+}
+
+// checkStockAlert raises an alert when the stock of a product falls below its
+// low stock threshold or runs out. The last alert is kept, so an alert is only
+// raised when stock crosses a threshold and not on every change.
+func (s *InventoryService) checkStockAlert(product models.Product) {
+	thresholds, last, err := s.repository.GetStockThresholds(product.ID)
+	if err != nil {
+		log.Printf("Failed to get stock thresholds of product %s: %v", product.ID, err)
+		return
+	}
+	lowStockThreshold, reorderPoint := s.thresholds(thresholds)
+
+	status := stockStatus(product.Quantity, lowStockThreshold)
+	if status == last {
+		return
+	}
+	swapped, err := s.repository.SwapStockAlert(product.ID, last, status)
+	if err != nil {
+		log.Printf("Failed to record stock alert of product %s: %v", product.ID, err)
+		return
+	}
+	// Another change got there first, or stock went back up
+	if !swapped || alertRank(status) < alertRank(last) {
+		return
+	}
+
+	alert := models.StockAlert{
+		ProductID:         product.ID,
+		ProductName:       product.Name,
+		Status:            status,
+		Quantity:          product.Quantity,
+		LowStockThreshold: lowStockThreshold,
+		ReorderPoint:      reorderPoint,
+	}
+	sales, err := s.repository.GetSales(s.salesSince(), product.ID)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to get sales of product %s: %v", product.ID, err)
+	}
+	s.fillStockAlert(&alert, sales[product.ID])
+
+	err = s.producer.PublishStockAlert(alert)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to publish stock alert event: %v", err)
+	}
+}
+
+// thresholds returns the low stock threshold and reorder point of a product,
+// using the defaults for the ones it doesn't set
+func (s *InventoryService) thresholds(thresholds models.StockThresholds) (int, int) {
+	lowStockThreshold, reorderPoint := s.config.LowStockThreshold, s.config.ReorderPoint
+	if thresholds.LowStockThreshold != nil {
+		lowStockThreshold = *thresholds.LowStockThreshold
+	}
+	if thresholds.ReorderPoint != nil {
+		reorderPoint = *thresholds.ReorderPoint
+	}
+	return lowStockThreshold, reorderPoint
+}
+
+// salesSince returns the start of the window sales are averaged over
+func (s *InventoryService) salesSince() time.Time {
+	return time.Now().AddDate(0, 0, -s.salesWindowDays())
+}
+
+// salesWindowDays returns the number of days sales are averaged over
+func (s *InventoryService) salesWindowDays() int {
+	if s.config.SalesWindowDays <= 0 {
+		return 30
+	}
+	return s.config.SalesWindowDays
+}
+
+// fillStockAlert sets the status, daily sales and days of cover of an alert
+// from the units sold in the sales window
+func (s *InventoryService) fillStockAlert(alert *models.StockAlert, sold int) {
+	alert.Status = stockStatus(alert.Quantity, alert.LowStockThreshold)
+	alert.Reorder = alert.Quantity <= alert.ReorderPoint
+	alert.DailySales = 0
+	alert.DaysOfCover = nil
+	if sold <= 0 {
+		return
+	}
+
+	alert.DailySales = float64(sold) / float64(s.salesWindowDays())
+	cover := float64(max(alert.Quantity, 0)) / alert.DailySales
+	alert.DaysOfCover = &cover
+}
+
+// stockStatus returns the stock alert status of a quantity, empty when there
+// is enough stock
+func stockStatus(quantity, lowStockThreshold int) string {
+	switch {
+	case quantity <= 0:
+		return models.StockStatusOutOfStock
+	case quantity <= lowStockThreshold:
+		return models.StockStatusLow
+	}
+	return ""
+}
+
+// alertRank orders stock alert statuses from enough stock to out of stock
+func alertRank(status string) int {
+	switch status {
+	case models.StockStatusLow:
+		return 1
+	case models.StockStatusOutOfStock:
+		return 2
+	}
+	return 0
+}
+
+// sortStockAlerts sorts alerts out of stock first, then by days of cover with
+// the products that sold nothing last
+func sortStockAlerts(alerts []models.StockAlert) {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		ri, rj := alertRank(alerts[i].Status), alertRank(alerts[j].Status)
+		if ri != rj {
+			return ri > rj
+		}
+		ci, cj := alerts[i].DaysOfCover, alerts[j].DaysOfCover
+		if ci == nil || cj == nil {
+			return ci != nil && cj == nil
+		}
+		return *ci < *cj
+	})
+}
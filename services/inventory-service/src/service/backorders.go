@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// GetBackorders retrieves the open backorders of a product in the order they
+// are served, or of every product when productID is empty
+func (s *InventoryService) GetBackorders(productID string) ([]models.Backorder, error) {
+	return s.repository.GetBackorders(productID)
+}
+
+// fillBackorders reserves the stock of a product for its backorders, first in
+// first out, and returns the number of units reserved. An older backorder
+// takes all the stock it needs before a newer one gets any. Order-service
+// learns of each reservation from a backorder_allocated event.
+func (s *InventoryService) fillBackorders(productID string) int {
+	s.backorderMu.Lock()
+	defer s.backorderMu.Unlock()
+
+	backorders, err := s.repository.GetBackorders(productID)
+	if err != nil {
+		log.Printf("Failed to get backorders of product %s: %v", productID, err)
+		return 0
+	}
+	if len(backorders) == 0 {
+		return 0
+	}
+
+	warehouses, err := s.repository.GetWarehouses()
+	if err != nil {
+		log.Printf("Failed to get warehouses: %v", err)
+		return 0
+	}
+	stock, err := s.repository.GetStockForProducts([]string{productID})
+	if err != nil {
+		log.Printf("Failed to get stock of product %s: %v", productID, err)
+		return 0
+	}
+
+	filled := 0
+	for _, backorder := range backorders {
+		inStock := 0
+		for _, quantity := range stock[productID] {
+			inStock += quantity
+		}
+		units := min(backorder.Quantity, inStock)
+		if units == 0 {
+			break
+		}
+
+		needs := map[string]int{productID: units}
+		allocations, ok := allocate(s.strategy, warehouses, stock, []string{productID}, needs, backorder.ShippingAddress)
+		if !ok {
+			break
+		}
+		for i := range allocations {
+			allocations[i].ProductID, allocations[i].SKUID = backorder.ProductID, backorder.SKUID
+		}
+		err := s.repository.AllocateBackorder(backorder, allocations, time.Now())
+		if errors.Is(err, db.ErrInsufficientStock) {
+			// Stock moved underneath, the next change fills it
+			log.Printf("Stopped filling backorders of product %s: %v", productID, err)
+			break
+		}
+		if err != nil {
+			log.Printf("Failed to fill backorder %s: %v", backorder.ID, err)
+			break
+		}
+		for _, allocation := range allocations {
+			stock[productID][allocation.WarehouseID] -= allocation.Quantity
+		}
+		filled += units
+		log.Printf("Reserved %d units of product %s for backorder of order %s", units, productID, backorder.OrderID)
+
+		remaining, err := s.repository.CountWaiting(backorder.OrderID)
+		if err != nil {
+			// Log error but continue
+			log.Printf("Failed to count waiting units of order %s: %v", backorder.OrderID, err)
+		}
+		err = s.producer.PublishBackorderAllocated(models.BackorderEvent{
+			OrderID:     backorder.OrderID,
+			ProductID:   backorder.ProductID,
+			SKUID:       backorder.SKUID,
+			Allocations: allocations,
+			Remaining:   remaining,
+		})
+		if err != nil {
+			// Log error but continue
+			log.Printf("Failed to publish backorder allocated event: %v", err)
+		}
+	}
+
+	return filled
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+)
+
+// catalogueCache is the name lists and recommendations are cached under. Any
+// product can change them, so they are invalidated with every product.
+const catalogueCache = "catalogue"
+
+// productCache returns the name a product is cached under
+func productCache(id string) string {
+	return "product:" + id
+}
+
+// invalidate makes the cached copies of products and of everything cached
+// under the catalogue stale, on this replica and, through the cache topic,
+// on the others
+func (s *InventoryService) invalidate(productIDs ...string) {
+	names := []string{catalogueCache}
+	for _, id := range productIDs {
+		if id != "" {
+			names = append(names, productCache(id))
+		}
+	}
+
+	s.cache.Invalidate(context.Background(), names...)
+	s.search.invalidate()
+
+	err := s.producer.PublishCacheInvalidated(names)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to publish cache invalidation: %v", err)
+	}
+}
+
+// ForgetCached forgets the values cached under names by this replica, after
+// another replica invalidated them
+func (s *InventoryService) ForgetCached(names []string) {
+	s.cache.Forget(names...)
+	s.search.invalidate()
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// catalogueColumns are the columns of catalogue CSV files in the order they
+// are exported. Imports match columns by name, in any order. In CSV, tags
+// and option values are separated by "|" and options by ";", such as
+// "Size=S|M;Color=Red".
+var catalogueColumns = []string{
+	"sku", "parent_sku", "name", "description", "category_id", "price", "tags",
+	"availability", "status", "options", "option_values", "barcode", "quantity",
+	"warehouse",
+}
+
+// catalogueLine is a row read from a catalogue file, or why it couldn't be
+// read. Line is where it starts in the file.
+type catalogueLine struct {
+	line int
+	row  models.CatalogueRow
+	err  error
+}
+
+// readCatalogue reads the rows of a catalogue file. Rows that can't be read
+// are returned with their error, so they are reported with the others.
+func readCatalogue(format string, data []byte) ([]catalogueLine, error) {
+	switch format {
+	case models.CatalogueFormatCSV:
+		return readCatalogueCSV(data)
+	case models.CatalogueFormatJSONL:
+		return readCatalogueJSONL(data), nil
+	}
+	return nil, fmt.Errorf("invalid format %q, use csv or jsonl", format)
+}
+
+// readCatalogueCSV reads a CSV catalogue with a header row
+func readCatalogueCSV(data []byte) ([]catalogueLine, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(catalogueColumns, name) {
+			return nil, fmt.Errorf("invalid CSV: unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["sku"]; !ok {
+		return nil, errors.New("invalid CSV: the sku column is required")
+	}
+
+	var lines []catalogueLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The reader has no field positions after an error
+			line := 0
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			lines = append(lines, catalogueLine{line: line, err: err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row, err := parseCSVRow(value)
+		lines = append(lines, catalogueLine{line: line, row: row, err: err})
+	}
+	return lines, nil
+}
+
+// parseCSVRow makes a catalogue row of the values of a CSV record by column
+func parseCSVRow(value func(column string) string) (models.CatalogueRow, error) {
+	row := models.CatalogueRow{
+		SKU:          value("sku"),
+		ParentSKU:    value("parent_sku"),
+		Name:         value("name"),
+		Description:  value("description"),
+		CategoryID:   value("category_id"),
+		Availability: value("availability"),
+		Status:       value("status"),
+		Barcode:      value("barcode"),
+		Warehouse:    value("warehouse"),
+	}
+
+	if price := value("price"); price != "" {
+		parsed, err := strconv.ParseFloat(price, 64)
+		if err != nil {
+			return row, fmt.Errorf("invalid price %q", price)
+		}
+		row.Price = &parsed
+	}
+	if quantity := value("quantity"); quantity != "" {
+		parsed, err := strconv.Atoi(quantity)
+		if err != nil {
+			return row, fmt.Errorf("invalid quantity %q", quantity)
+		}
+		row.Quantity = &parsed
+	}
+	if tags := value("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				row.Tags = append(row.Tags, tag)
+			}
+		}
+	}
+	if options := value("options"); options != "" {
+		for _, option := range strings.Split(options, ";") {
+			name, values, ok := strings.Cut(option, "=")
+			if !ok {
+				return row, fmt.Errorf("invalid options %q, use Size=S|M;Color=Red|Blue", options)
+			}
+			row.Options = append(row.Options, models.VariantOption{Name: name, Values: strings.Split(values, "|")})
+		}
+	}
+	if values := value("option_values"); values != "" {
+		row.OptionValues = make(map[string]string)
+		for _, pair := range strings.Split(values, ";") {
+			name, optionValue, ok := strings.Cut(pair, "=")
+			if !ok {
+				return row, fmt.Errorf("invalid option_values %q, use Size=M;Color=Red", values)
+			}
+			row.OptionValues[strings.TrimSpace(name)] = strings.TrimSpace(optionValue)
+		}
+	}
+	return row, nil
+}
+
+// readCatalogueJSONL reads a JSON Lines catalogue, skipping blank lines
+func readCatalogueJSONL(data []byte) []catalogueLine {
+	var lines []catalogueLine
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var row models.CatalogueRow
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&row)
+		if err != nil {
+			err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		lines = append(lines, catalogueLine{line: n + 1, row: row, err: err})
+	}
+	return lines
+}
+
+// writeCatalogue writes catalogue rows in a format
+func writeCatalogue(format string, rows []models.CatalogueRow) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case models.CatalogueFormatJSONL:
+		encoder := json.NewEncoder(&buf)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return nil, err
+			}
+		}
+	case models.CatalogueFormatCSV:
+		writer := csv.NewWriter(&buf)
+		if err := writer.Write(catalogueColumns); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if err := writer.Write(csvRecord(row)); err != nil {
+				return nil, err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %q, use csv or jsonl", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// csvRecord returns the values of a catalogue row in the order of the columns
+func csvRecord(row models.CatalogueRow) []string {
+	var price, quantity string
+	if row.Price != nil {
+		price = strconv.FormatFloat(*row.Price, 'f', -1, 64)
+	}
+	if row.Quantity != nil {
+		quantity = strconv.Itoa(*row.Quantity)
+	}
+
+	var options []string
+	for _, option := range row.Options {
+		options = append(options, option.Name+"="+strings.Join(option.Values, "|"))
+	}
+	var values []string
+	for name, value := range row.OptionValues {
+		values = append(values, name+"="+value)
+	}
+	sort.Strings(values)
+
+	return []string{
+		row.SKU, row.ParentSKU, row.Name, row.Description, row.CategoryID, price,
+		strings.Join(row.Tags, "|"), row.Availability, row.Status,
+		strings.Join(options, ";"), strings.Join(values, ";"), row.Barcode, quantity,
+		row.Warehouse,
+	}
+}
+
+// ExportProducts exports the products matching a search, each followed by
+// its SKUs, as a catalogue file that can be imported again. Quantities are
+// the stock in all warehouses or, given its code, in one warehouse. Products
+// sold in variants have no quantity, their SKUs do.
+func (s *InventoryService) ExportProducts(search models.ProductSearch, format, warehouse string) ([]byte, error) {
+	if format != models.CatalogueFormatCSV && format != models.CatalogueFormatJSONL {
+		return nil, fmt.Errorf("invalid format %q, use csv or jsonl", format)
+	}
+
+	var warehouseID string
+	if warehouse != "" {
+		warehouses, err := s.repository.GetWarehouses()
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warehouses {
+			if strings.EqualFold(w.Code, warehouse) {
+				warehouseID = w.ID
+			}
+		}
+		if warehouseID == "" {
+			return nil, fmt.Errorf("warehouse with code %s not found", warehouse)
+		}
+	}
+
+	// Page through the search, so the export has what a search would show
+	var products []models.Product
+	search.Limit = maxSearchLimit
+	for {
+		result, err := s.SearchProducts(search)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, result.Products...)
+		if result.NextCursor == "" {
+			break
+		}
+		search.Cursor = result.NextCursor
+	}
+
+	var stock map[string]map[string]int
+	if warehouseID != "" {
+		var stockIDs []string
+		for _, product := range products {
+			stockIDs = append(stockIDs, product.ID)
+			for _, sku := range product.SKUs {
+				stockIDs = append(stockIDs, sku.ID)
+			}
+		}
+		var err error
+		stock, err = s.repository.GetStockForProducts(stockIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	quantity := func(stockID string, total int) *int {
+		if warehouseID != "" {
+			total = stock[stockID][warehouseID]
+		}
+		return &total
+	}
+
+	rows := make([]models.CatalogueRow, 0, len(products))
+	for _, product := range products {
+		// The regular price, a sale is scheduled rather than imported
+		price := product.Price
+		if product.WasPrice != nil {
+			price = *product.WasPrice
+		}
+		row := models.CatalogueRow{
+			SKU:          product.Code,
+			Name:         product.Name,
+			Description:  product.Description,
+			CategoryID:   product.CategoryID,
+			Price:        &price,
+			Tags:         product.Tags,
+			Availability: product.Availability,
+			Status:       product.Status,
+			Options:      product.Options,
+			Warehouse:    strings.ToUpper(warehouse),
+		}
+		if len(product.SKUs) == 0 {
+			row.Quantity = quantity(product.ID, product.Quantity)
+		}
+		rows = append(rows, row)
+
+		for _, sku := range product.SKUs {
+			rows = append(rows, models.CatalogueRow{
+				SKU:          sku.Code,
+				ParentSKU:    product.Code,
+				Price:        sku.PriceOverride,
+				OptionValues: sku.Options,
+				Barcode:      sku.Barcode,
+				Quantity:     quantity(sku.ID, sku.Quantity),
+				Warehouse:    strings.ToUpper(warehouse),
+			})
+		}
+	}
+
+	return writeCatalogue(format, rows)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+func TestReadCatalogueCSV(t *testing.T) {
+	data := "\ufeffSKU,Name,price,quantity,tags,options,option_values,parent_sku\n" +
+		"TS,T-shirt,10.5,,cotton| summer |,Size=S|M;Color=Red,,\n" +
+		"TS-M-RED,,,3,,,Size=M; Color=Red,TS\n" +
+		"BAD-PRICE,Pan,cheap,,,,,\n" +
+		"BAD-QTY,Pan,7,many,,,,\n" +
+		"BAD-OPTIONS,Pan,7,,,Size,,\n" +
+		"\"unterminated,Pan\n"
+
+	lines, err := readCatalogue(models.CatalogueFormatCSV, []byte(data))
+	if err != nil {
+		t.Fatalf("readCatalogue: %v", err)
+	}
+	if len(lines) != 6 {
+		t.Fatalf("read %d lines, want 6: %+v", len(lines), lines)
+	}
+
+	price, quantity := 10.5, 3
+	want := []models.CatalogueRow{
+		{
+			SKU:     "TS",
+			Name:    "T-shirt",
+			Price:   &price,
+			Tags:    []string{"cotton", "summer"},
+			Options: []models.VariantOption{{Name: "Size", Values: []string{"S", "M"}}, {Name: "Color", Values: []string{"Red"}}},
+		},
+		{SKU: "TS-M-RED", ParentSKU: "TS", Quantity: &quantity, OptionValues: map[string]string{"Size": "M", "Color": "Red"}},
+	}
+	for i, row := range want {
+		if lines[i].err != nil || lines[i].line != i+2 || !reflect.DeepEqual(lines[i].row, row) {
+			t.Errorf("line %d: got %+v, %v, want %+v", i+2, lines[i].row, lines[i].err, row)
+		}
+	}
+
+	// Rows that can't be read are kept with their line and error
+	for i, want := range []string{"invalid price", "invalid quantity", "invalid options", "extraneous or missing \" in quoted-field"} {
+		line := lines[i+2]
+		if line.line != i+4 || line.err == nil || !strings.Contains(line.err.Error(), want) {
+			t.Errorf("line %d: got line %d, %v, want %s", i+4, line.line, line.err, want)
+		}
+	}
+}
+
+func TestReadCatalogueInvalidFiles(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		want   string
+	}{
+		{models.CatalogueFormatCSV, "", "invalid CSV"},
+		{models.CatalogueFormatCSV, "sku,colour\n", "unknown column \"colour\""},
+		{models.CatalogueFormatCSV, "name,price\nPan,7\n", "the sku column is required"},
+		{"xlsx", "sku\n", "invalid format"},
+	}
+	for _, tt := range tests {
+		if _, err := readCatalogue(tt.format, []byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: got %v, want %s", tt.format, tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestReadCatalogueJSONL(t *testing.T) {
+	data := `{"sku":"PAN","name":"Pan","price":7}
+
+{"sku":"POT","colour":"red"}
+not json
+`
+	lines, err := readCatalogue(models.CatalogueFormatJSONL, []byte(data))
+	if err != nil {
+		t.Fatalf("readCatalogue: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("read %d lines, want 3 (blank lines are skipped): %+v", len(lines), lines)
+	}
+	if lines[0].err != nil || lines[0].line != 1 || lines[0].row.SKU != "PAN" || *lines[0].row.Price != 7 {
+		t.Errorf("line 1: got %+v", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if line.err == nil || !strings.HasPrefix(line.err.Error(), "invalid JSON") {
+			t.Errorf("line %d: got %v, want invalid JSON", line.line, line.err)
+		}
+	}
+	if lines[1].line != 3 || lines[2].line != 4 {
+		t.Errorf("invalid rows on lines %d and %d, want 3 and 4", lines[1].line, lines[2].line)
+	}
+}
+
+func TestWriteCatalogueReadsBack(t *testing.T) {
+	price, quantity := 12.0, 4
+	rows := []models.CatalogueRow{
+		{
+			SKU:          "TS",
+			Name:         "T-shirt, cotton",
+			Description:  "Soft \"organic\" cotton",
+			CategoryID:   "clothes",
+			Price:        &price,
+			Tags:         []string{"cotton", "summer"},
+			Availability: models.AvailabilityBackorder,
+			Options:      []models.VariantOption{{Name: "Size", Values: []string{"S", "M"}}},
+		},
+		{SKU: "TS-M", ParentSKU: "TS", OptionValues: map[string]string{"Size": "M"}, Barcode: "8930000000017", Quantity: &quantity, Warehouse: "HN"},
+	}
+
+	for _, format := range []string{models.CatalogueFormatCSV, models.CatalogueFormatJSONL} {
+		data, err := writeCatalogue(format, rows)
+		if err != nil {
+			t.Fatalf("%s: writeCatalogue: %v", format, err)
+		}
+		lines, err := readCatalogue(format, data)
+		if err != nil {
+			t.Fatalf("%s: readCatalogue: %v", format, err)
+		}
+		var got []models.CatalogueRow
+		for _, line := range lines {
+			if line.err != nil {
+				t.Errorf("%s line %d: %v", format, line.line, line.err)
+			}
+			got = append(got, line.row)
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("%s: read back %+v, want %+v", format, got, rows)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// slugify makes a slug of words, folding Vietnamese diacritics
+func slugify(text string) string {
+	return strings.Join(tokenize(text), "-")
+}
+
+// categoryTree is every category by ID, with the IDs of the children of each
+// category in order. Top-level categories are the children of "".
+type categoryTree struct {
+	categories map[string]models.Category
+	children   map[string][]string
+	counts     map[string]int // Products filed directly in each category
+}
+
+// loadCategoryTree reads the category tree and the number of products in
+// each category
+func (s *InventoryService) loadCategoryTree() (*categoryTree, error) {
+	categories, err := s.repository.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repository.CountProductsByCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &categoryTree{
+		categories: make(map[string]models.Category, len(categories)),
+		children:   make(map[string][]string),
+		counts:     counts,
+	}
+	for _, category := range categories {
+		tree.categories[category.ID] = category
+	}
+	for _, category := range categories {
+		parentID := category.ParentID
+		if _, ok := tree.categories[parentID]; !ok {
+			parentID = ""
+		}
+		tree.children[parentID] = append(tree.children[parentID], category.ID)
+	}
+	return tree, nil
+}
+
+// subtree returns the ID of a category and of all of its descendants
+func (t *categoryTree) subtree(id string) []string {
+	ids := []string{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+// path returns the breadcrumb from the top-level category down to a category
+func (t *categoryTree) path(id string) []models.CategoryRef {
+	var path []models.CategoryRef
+	for len(path) < len(t.categories) {
+		category, ok := t.categories[id]
+		if !ok {
+			break
+		}
+		path = append([]models.CategoryRef{{ID: category.ID, Name: category.Name, Slug: category.Slug}}, path...)
+		id = category.ParentID
+	}
+	return path
+}
+
+// node returns a category with its product count and, down to depth levels,
+// its children
+func (t *categoryTree) node(id string, depth int) models.Category {
+	category := t.categories[id]
+	for _, descendant := range t.subtree(id) {
+		category.ProductCount += t.counts[descendant]
+	}
+	if depth > 0 {
+		for _, childID := range t.children[id] {
+			category.Children = append(category.Children, t.node(childID, depth-1))
+		}
+	}
+	return category
+}
+
+// CreateCategory creates a category, at the top level or under a parent
+func (s *InventoryService) CreateCategory(req models.CreateCategoryRequest) (models.Category, error) {
+	now := time.Now()
+	category := models.Category{
+		ID:        db.GenerateID(),
+		ParentID:  req.ParentID,
+		Name:      strings.TrimSpace(req.Name),
+		Slug:      slugify(req.Slug),
+		Position:  req.Position,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if category.Slug == "" {
+		category.Slug = slugify(category.Name)
+	}
+	if category.Slug == "" {
+		return models.Category{}, errors.New("invalid slug: it needs letters or digits")
+	}
+
+	if category.ParentID != "" {
+		if _, err := s.repository.GetCategoryByID(category.ParentID); err != nil {
+			return models.Category{}, fmt.Errorf("invalid parent: %w", err)
+		}
+	}
+
+	err := s.repository.CreateCategory(category)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return s.GetCategoryByID(category.ID)
+}
+
+// GetCategories retrieves the category tree, each category with the number
+// of products in it and its descendants
+func (s *InventoryService) GetCategories() ([]models.Category, error) {
+	tree, err := s.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+
+	categories := []models.Category{}
+	for _, id := range tree.children[""] {
+		categories = append(categories, tree.node(id, len(tree.categories)))
+	}
+	return categories, nil
+}
+
+// GetCategoryByID retrieves a category with its breadcrumb and children
+func (s *InventoryService) GetCategoryByID(id string) (models.Category, error) {
+	tree, err := s.loadCategoryTree()
+	if err != nil {
+		return models.Category{}, err
+	}
+	if _, ok := tree.categories[id]; !ok {
+		return models.Category{}, fmt.Errorf("category with ID %s not found", id)
+	}
+
+	category := tree.node(id, 1)
+	category.Path = tree.path(id)
+	return category, nil
+}
+
+// UpdateCategory updates a category. A category can't be moved under itself
+// or one of its descendants.
+func (s *InventoryService) UpdateCategory(id string, req models.UpdateCategoryRequest) (models.Category, error) {
+	tree, err := s.loadCategoryTree()
+	if err != nil {
+		return models.Category{}, err
+	}
+	category, ok := tree.categories[id]
+	if !ok {
+		return models.Category{}, fmt.Errorf("category with ID %s not found", id)
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		category.Name = name
+	}
+	if req.Slug != "" {
+		if category.Slug = slugify(req.Slug); category.Slug == "" {
+			return models.Category{}, errors.New("invalid slug: it needs letters or digits")
+		}
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+	if req.ParentID != nil {
+		parentID := *req.ParentID
+		if parentID != "" {
+			if _, ok := tree.categories[parentID]; !ok {
+				return models.Category{}, fmt.Errorf("invalid parent: category with ID %s not found", parentID)
+			}
+			for _, descendant := range tree.subtree(id) {
+				if descendant == parentID {
+					return models.Category{}, errors.New("invalid parent: a category can't be moved under itself or its descendants")
+				}
+			}
+		}
+		category.ParentID = parentID
+	}
+	category.UpdatedAt = time.Now()
+
+	err = s.repository.UpdateCategory(category)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return s.GetCategoryByID(id)
+}
+
+// DeleteCategory deletes a category. Its children move up to its parent.
+// A category with products can only be deleted when they are reassigned to
+// another category.
+func (s *InventoryService) DeleteCategory(id string, reassignTo string) error {
+	category, err := s.repository.GetCategoryByID(id)
+	if err != nil {
+		return err
+	}
+	if reassignTo != "" {
+		if reassignTo == id {
+			return errors.New("invalid reassign_to: products can't be reassigned to the deleted category")
+		}
+		if _, err := s.repository.GetCategoryByID(reassignTo); err != nil {
+			return fmt.Errorf("invalid reassign_to: %w", err)
+		}
+	}
+
+	productIDs, err := s.repository.DeleteCategory(category, reassignTo, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Products that moved are cached with their old category
+	s.invalidate(productIDs...)
+
+	log.Printf("Deleted category %s, reassigned %d products to %q", id, len(productIDs), reassignTo)
+	return nil
+}
+
+// EnsureCategories creates a category for every category ID products were
+// filed under before categories were kept. The ID doubles as the name.
+func (s *InventoryService) EnsureCategories() error {
+	ids, err := s.repository.GetUnknownCategoryIDs()
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	categories, err := s.repository.GetCategories()
+	if err != nil {
+		return err
+	}
+	slugs := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		slugs[category.Slug] = true
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		base := slugify(id)
+		if base == "" {
+			base = "category"
+		}
+		slug := base
+		for n := 2; slugs[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		slugs[slug] = true
+
+		err := s.repository.CreateCategory(models.Category{
+			ID:        id,
+			Name:      id,
+			Slug:      slug,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Created category %s for existing products", id)
+	}
+	return nil
+}
+
+// categorySubtree returns the ID of a category and of its descendants. An
+// unknown category is returned alone.
+func (s *InventoryService) categorySubtree(id string) ([]string, error) {
+	tree, err := s.loadCategoryTree()
+	if err != nil {
+		return nil, err
+	}
+	return tree.subtree(id), nil
+}
+
+// checkCategory makes sure products are filed under a category that exists
+func (s *InventoryService) checkCategory(id string) error {
+	if _, err := s.repository.GetCategoryByID(id); err != nil {
+		return fmt.Errorf("invalid category: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// testCategoryTree is a kitchen category with pans and pots under it, and
+// woks under pans
+func testCategoryTree() *categoryTree {
+	return &categoryTree{
+		categories: map[string]models.Category{
+			"kitchen": {ID: "kitchen", Name: "Nhà bếp", Slug: "nha-bep"},
+			"pans":    {ID: "pans", Name: "Chảo", Slug: "chao", ParentID: "kitchen"},
+			"pots":    {ID: "pots", Name: "Nồi", Slug: "noi", ParentID: "kitchen"},
+			"woks":    {ID: "woks", Name: "Chảo wok", Slug: "chao-wok", ParentID: "pans"},
+			"garden":  {ID: "garden", Name: "Vườn", Slug: "vuon"},
+		},
+		children: map[string][]string{
+			"":        {"kitchen", "garden"},
+			"kitchen": {"pans", "pots"},
+			"pans":    {"woks"},
+		},
+		counts: map[string]int{"kitchen": 1, "pans": 2, "woks": 3, "garden": 4},
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Nhà bếp":         "nha-bep",
+		"  Chảo & Nồi  ":  "chao-noi",
+		"Đồ dùng 2 người": "do-dung-2-nguoi",
+		"!!!":             "",
+	}
+	for text, want := range tests {
+		if got := slugify(text); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestCategorySubtree(t *testing.T) {
+	tree := testCategoryTree()
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"kitchen", []string{"kitchen", "pans", "pots", "woks"}},
+		{"pans", []string{"pans", "woks"}},
+		{"pots", []string{"pots"}},
+		{"unknown", []string{"unknown"}},
+	}
+	for _, tt := range tests {
+		if got := tree.subtree(tt.id); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("subtree(%s) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestCategoryPath(t *testing.T) {
+	tree := testCategoryTree()
+	want := []models.CategoryRef{
+		{ID: "kitchen", Name: "Nhà bếp", Slug: "nha-bep"},
+		{ID: "pans", Name: "Chảo", Slug: "chao"},
+		{ID: "woks", Name: "Chảo wok", Slug: "chao-wok"},
+	}
+	if got := tree.path("woks"); !reflect.DeepEqual(got, want) {
+		t.Errorf("path(woks) = %+v, want %+v", got, want)
+	}
+	if got := tree.path("unknown"); len(got) != 0 {
+		t.Errorf("path(unknown) = %+v, want none", got)
+	}
+
+	// A cycle doesn't loop forever
+	tree.categories["kitchen"] = models.Category{ID: "kitchen", ParentID: "woks"}
+	if got := tree.path("woks"); len(got) > len(tree.categories) {
+		t.Errorf("path through a cycle has %d categories", len(got))
+	}
+}
+
+func TestCategoryNodeCountsDescendants(t *testing.T) {
+	node := testCategoryTree().node("kitchen", 1)
+	if node.ProductCount != 6 {
+		t.Errorf("kitchen counts %d products, want 6", node.ProductCount)
+	}
+	if len(node.Children) != 2 || node.Children[0].ProductCount != 5 || len(node.Children[0].Children) != 0 {
+		t.Errorf("children of kitchen down to depth 1: %+v", node.Children)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// Strategies for picking the warehouses an order is fulfilled from
+const (
+	// StrategyNearest takes stock from the warehouses nearest to the
+	// shipping address first
+	StrategyNearest = "nearest"
+	// StrategyMostStock takes stock from the warehouses holding the most of
+	// the ordered products first
+	StrategyMostStock = "most_stock"
+	// StrategyFewestSplits takes stock from as few warehouses as possible,
+	// preferring the nearest when several would do
+	StrategyFewestSplits = "fewest_splits"
+)
+
+// validStrategy reports whether strategy is a known fulfilment strategy
+func validStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyNearest, StrategyMostStock, StrategyFewestSplits:
+		return true
+	}
+	return false
+}
+
+// distance ranks how far a warehouse is from a shipping address. Addresses
+// carry no coordinates, so warehouses in the same city are nearest, then the
+// same province, then the same country.
+func distance(warehouse models.Warehouse, location models.Location) int {
+	if !sameArea(warehouse.Country, location.Country) {
+		return 3
+	}
+	if !sameArea(warehouse.Province, location.Province) {
+		return 2
+	}
+	if !sameArea(warehouse.City, location.City) {
+		return 1
+	}
+	return 0
+}
+
+// sameArea reports whether two area names are set and name the same area
+func sameArea(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	return a != "" && strings.EqualFold(a, b)
+}
+
+// allocate picks the warehouses the ordered quantities are taken from.
+// needs holds the quantity per product and products the order to allocate
+// them in; stock holds the stock per product and warehouse. It returns false
+// when the warehouses together don't have enough stock.
+func allocate(strategy string, warehouses []models.Warehouse, stock map[string]map[string]int,
+	products []string, needs map[string]int, location models.Location) ([]models.StockAllocation, bool) {
+	// Nearest first, by code when equally near, so every strategy breaks ties
+	// the same way
+	ranked := make([]models.Warehouse, len(warehouses))
+	copy(ranked, warehouses)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		di, dj := distance(ranked[i], location), distance(ranked[j], location)
+		if di != dj {
+			return di < dj
+		}
+		return ranked[i].Code < ranked[j].Code
+	})
+
+	remaining := make(map[string]int, len(needs))
+	for productID, quantity := range needs {
+		remaining[productID] = quantity
+	}
+
+	var allocations []models.StockAllocation
+	take := func(warehouseID string) {
+		for _, productID := range products {
+			quantity := min(remaining[productID], stock[productID][warehouseID])
+			if quantity <= 0 {
+				continue
+			}
+			allocations = append(allocations, models.StockAllocation{
+				ProductID:   productID,
+				WarehouseID: warehouseID,
+				Quantity:    quantity,
+			})
+			remaining[productID] -= quantity
+		}
+	}
+	// covered returns how many of the remaining units a warehouse can send
+	covered := func(warehouseID string) int {
+		units := 0
+		for productID, quantity := range remaining {
+			units += min(quantity, stock[productID][warehouseID])
+		}
+		return units
+	}
+
+	switch strategy {
+	case StrategyNearest:
+		for _, warehouse := range ranked {
+			take(warehouse.ID)
+		}
+	case StrategyMostStock:
+		held := make(map[string]int, len(ranked))
+		for _, warehouse := range ranked {
+			for productID := range needs {
+				held[warehouse.ID] += stock[productID][warehouse.ID]
+			}
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return held[ranked[i].ID] > held[ranked[j].ID]
+		})
+		for _, warehouse := range ranked {
+			take(warehouse.ID)
+		}
+	default:
+		// Greedily take from the warehouse that can send the most of what is
+		// left, which is a single warehouse whenever one has everything. A
+		// warehouse taken from has sent all it can, so it isn't counted again.
+		taken := make(map[string]bool, len(ranked))
+		for {
+			best, bestUnits := "", 0
+			for _, warehouse := range ranked {
+				if taken[warehouse.ID] {
+					continue
+				}
+				if units := covered(warehouse.ID); units > bestUnits {
+					best, bestUnits = warehouse.ID, units
+				}
+			}
+			if bestUnits == 0 {
+				break
+			}
+			take(best)
+			taken[best] = true
+		}
+	}
+
+	for _, quantity := range remaining {
+		if quantity > 0 {
+			return nil, false
+		}
+	}
+	return allocations, true
+}
+
+// fulfilmentWarehouse returns the warehouse sending the most units
+func fulfilmentWarehouse(allocations []models.StockAllocation) string {
+	units := make(map[string]int)
+	best := ""
+	for _, allocation := range allocations {
+		units[allocation.WarehouseID] += allocation.Quantity
+		if best == "" || units[allocation.WarehouseID] > units[best] {
+			best = allocation.WarehouseID
+		}
+	}
+	return best
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// testWarehouses are a warehouse in Ho Chi Minh City and two further away
+var testWarehouses = []models.Warehouse{
+	{ID: "hn", Code: "HN", Country: "VN", Province: "Ha Noi", City: "Ha Noi"},
+	{ID: "hcm", Code: "HCM", Country: "VN", Province: "Ho Chi Minh", City: "Ho Chi Minh"},
+	{ID: "dn", Code: "DN", Country: "VN", Province: "Da Nang", City: "Da Nang"},
+}
+
+func TestDistance(t *testing.T) {
+	warehouse := testWarehouses[1]
+	tests := []struct {
+		location models.Location
+		want     int
+	}{
+		{models.Location{Country: "VN", Province: "Ho Chi Minh", City: "Ho Chi Minh"}, 0},
+		{models.Location{Country: "vn", Province: " ho chi minh ", City: "HO CHI MINH"}, 0},
+		{models.Location{Country: "VN", Province: "Ho Chi Minh", City: "Thu Duc"}, 1},
+		{models.Location{Country: "VN", Province: "Ho Chi Minh"}, 1},
+		{models.Location{Country: "VN", Province: "Ha Noi", City: "Ho Chi Minh"}, 2},
+		{models.Location{Country: "TH", Province: "Ho Chi Minh", City: "Ho Chi Minh"}, 3},
+		{models.Location{}, 3},
+	}
+
+	for _, tt := range tests {
+		if got := distance(warehouse, tt.location); got != tt.want {
+			t.Errorf("distance to %+v = %d, want %d", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	hcm := models.Location{Country: "VN", Province: "Ho Chi Minh", City: "Ho Chi Minh"}
+	products := []string{"pan", "pot"}
+
+	// The nearest warehouse has some pans, Ha Noi has everything and Da Nang
+	// a little of both
+	spread := map[string]map[string]int{
+		"pan": {"hcm": 2, "hn": 5, "dn": 3},
+		"pot": {"hn": 4, "dn": 1},
+	}
+	// Ha Noi holds the most, but only Da Nang has pots
+	lopsided := map[string]map[string]int{
+		"pan": {"hn": 10, "dn": 3},
+		"pot": {"dn": 1},
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		stock    map[string]map[string]int
+		needs    map[string]int
+		want     []models.StockAllocation
+		wantOK   bool
+	}{
+		{
+			name:     "nearest takes from the nearest first, then by code",
+			strategy: StrategyNearest,
+			stock:    spread,
+			needs:    map[string]int{"pan": 4, "pot": 1},
+			want: []models.StockAllocation{
+				{ProductID: "pan", WarehouseID: "hcm", Quantity: 2},
+				{ProductID: "pan", WarehouseID: "dn", Quantity: 2},
+				{ProductID: "pot", WarehouseID: "dn", Quantity: 1},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "most stock takes from the fullest warehouse first",
+			strategy: StrategyMostStock,
+			stock:    spread,
+			needs:    map[string]int{"pan": 4, "pot": 1},
+			want: []models.StockAllocation{
+				{ProductID: "pan", WarehouseID: "hn", Quantity: 4},
+				{ProductID: "pot", WarehouseID: "hn", Quantity: 1},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "most stock splits when the fullest lacks a product",
+			strategy: StrategyMostStock,
+			stock:    lopsided,
+			needs:    map[string]int{"pan": 3, "pot": 1},
+			want: []models.StockAllocation{
+				{ProductID: "pan", WarehouseID: "hn", Quantity: 3},
+				{ProductID: "pot", WarehouseID: "dn", Quantity: 1},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "fewest splits sends from one warehouse when one has everything",
+			strategy: StrategyFewestSplits,
+			stock:    lopsided,
+			needs:    map[string]int{"pan": 3, "pot": 1},
+			want: []models.StockAllocation{
+				{ProductID: "pan", WarehouseID: "dn", Quantity: 3},
+				{ProductID: "pot", WarehouseID: "dn", Quantity: 1},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "fewest splits prefers the nearest of equal warehouses",
+			strategy: StrategyFewestSplits,
+			stock:    map[string]map[string]int{"pan": {"hn": 2, "hcm": 2, "dn": 2}},
+			needs:    map[string]int{"pan": 2},
+			want:     []models.StockAllocation{{ProductID: "pan", WarehouseID: "hcm", Quantity: 2}},
+			wantOK:   true,
+		},
+		{
+			name:     "fewest splits splits when no warehouse has everything",
+			strategy: StrategyFewestSplits,
+			stock:    spread,
+			needs:    map[string]int{"pan": 9},
+			want: []models.StockAllocation{
+				{ProductID: "pan", WarehouseID: "hn", Quantity: 5},
+				{ProductID: "pan", WarehouseID: "dn", Quantity: 3},
+				{ProductID: "pan", WarehouseID: "hcm", Quantity: 1},
+			},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := allocate(tt.strategy, testWarehouses, tt.stock, products, tt.needs, hcm)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocate = %+v, %t, want %+v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAllocateWithoutEnoughStock(t *testing.T) {
+	stock := map[string]map[string]int{"pan": {"hcm": 2, "hn": 5}}
+	for _, strategy := range []string{StrategyNearest, StrategyMostStock, StrategyFewestSplits} {
+		got, ok := allocate(strategy, testWarehouses, stock, []string{"pan"}, map[string]int{"pan": 8}, models.Location{})
+		if ok || got != nil {
+			t.Errorf("%s: allocate = %+v, %t, want nothing", strategy, got, ok)
+		}
+	}
+}
+
+func TestFulfilmentWarehouse(t *testing.T) {
+	allocations := []models.StockAllocation{
+		{ProductID: "pan", WarehouseID: "hcm", Quantity: 2},
+		{ProductID: "pan", WarehouseID: "dn", Quantity: 2},
+		{ProductID: "pot", WarehouseID: "dn", Quantity: 1},
+	}
+	if got := fulfilmentWarehouse(allocations); got != "dn" {
+		t.Errorf("fulfilmentWarehouse = %q, want dn", got)
+	}
+	if got := fulfilmentWarehouse(nil); got != "" {
+		t.Errorf("fulfilmentWarehouse of nothing = %q, want none", got)
+	}
+}
@@ -0,0 +1,477 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+const (
+	// maxImportErrors is how many failed rows an import job keeps
+	maxImportErrors = 1000
+	// importProgressRows is how often an import job saves its progress
+	importProgressRows = 100
+	// importHeartbeat is how often a running import job is touched, so that
+	// other replicas can tell it is alive
+	importHeartbeat = 30 * time.Second
+	// importStaleAfter is how long a running import job can go untouched
+	// before it is taken for dead
+	importStaleAfter = 5 * time.Minute
+)
+
+// importState is what an import has learnt from the rows before the current
+// one. Dry runs write nothing, so the products they would create are only
+// known from here.
+type importState struct {
+	seen       map[string]bool                   // SKUs of the rows so far
+	options    map[string][]models.VariantOption // Options of products in the file, by SKU
+	values     map[string][]map[string]string    // Option values of SKUs in the file, by parent SKU
+	warehouses map[string]string                 // Warehouse IDs by upper-case code
+	defaultID  string                            // Default warehouse, stock goes there when no code is given
+}
+
+// newImportState loads the warehouses rows can name
+func (s *InventoryService) newImportState() (*importState, error) {
+	warehouses, err := s.repository.GetWarehouses()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &importState{
+		seen:       make(map[string]bool),
+		options:    make(map[string][]models.VariantOption),
+		values:     make(map[string][]map[string]string),
+		warehouses: make(map[string]string, len(warehouses)),
+	}
+	for _, warehouse := range warehouses {
+		state.warehouses[strings.ToUpper(warehouse.Code)] = warehouse.ID
+		if warehouse.IsDefault {
+			state.defaultID = warehouse.ID
+		}
+	}
+	return state, nil
+}
+
+// ImportProducts reads a catalogue file and imports its rows in the
+// background. Files that can't be read at all are rejected; rows that can't
+// be imported are reported by the job.
+func (s *InventoryService) ImportProducts(format string, data []byte, dryRun bool, caller string) (models.ImportJob, error) {
+	lines, err := readCatalogue(format, data)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	if len(lines) == 0 {
+		return models.ImportJob{}, errors.New("invalid file: it has no rows")
+	}
+
+	now := time.Now()
+	job := models.ImportJob{
+		ID:        db.GenerateID(),
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    models.ImportStatusRunning,
+		TotalRows: len(lines),
+		Errors:    []models.ImportError{},
+		Caller:    caller,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.repository.CreateImportJob(job)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	go s.runImport(job, lines)
+
+	return job, nil
+}
+
+// GetImportJob retrieves an import job with its progress
+func (s *InventoryService) GetImportJob(id string) (models.ImportJob, error) {
+	return s.repository.GetImportJob(id)
+}
+
+// runImport imports the rows of a file one at a time, saving the progress of
+// the job as it goes
+func (s *InventoryService) runImport(job models.ImportJob, lines []catalogueLine) {
+	// Waiting for another import counts as running
+	stop := s.keepImportAlive(job.ID)
+	defer stop()
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+
+	state, err := s.newImportState()
+	if err != nil {
+		job.Status = models.ImportStatusFailed
+		job.Reason = err.Error()
+		s.finishImport(job)
+		return
+	}
+
+	for n, line := range lines {
+		created := false
+		err := line.err
+		if err == nil {
+			created, err = s.importRow(state, line.row, job.DryRun, job.Caller)
+		}
+
+		switch {
+		case err != nil:
+			job.Failed++
+			if len(job.Errors) < maxImportErrors {
+				job.Errors = append(job.Errors, models.ImportError{
+					Line:  line.line,
+					SKU:   strings.TrimSpace(line.row.SKU),
+					Error: err.Error(),
+				})
+			}
+		case created:
+			job.Created++
+		default:
+			job.Updated++
+		}
+		job.ProcessedRows = n + 1
+
+		if job.ProcessedRows%importProgressRows == 0 {
+			job.UpdatedAt = time.Now()
+			if err := s.repository.UpdateImportJob(job); err != nil {
+				// Continue anyway, the job is saved when it finishes
+				log.Printf("Failed to save progress of import %s: %v", job.ID, err)
+			}
+		}
+	}
+
+	job.Status = models.ImportStatusCompleted
+	s.finishImport(job)
+	log.Printf("Import %s completed: %d created, %d updated, %d failed (dry run: %t)",
+		job.ID, job.Created, job.Updated, job.Failed, job.DryRun)
+}
+
+// keepImportAlive touches a running import job until the returned function
+// is called
+func (s *InventoryService) keepImportAlive(id string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(importHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := s.repository.TouchImportJob(id, now); err != nil {
+					// Log error but continue
+					log.Printf("Failed to touch import %s: %v", id, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// FailStaleImports marks the running import jobs that were not touched for a
+// while as failed. Their replica stopped or crashed, so they never finish.
+func (s *InventoryService) FailStaleImports() (int, error) {
+	now := time.Now()
+	failed, err := s.repository.FailStaleImports("interrupted: the import stopped with its replica", now.Add(-importStaleAfter), now)
+	if err != nil {
+		return 0, err
+	}
+	if failed > 0 {
+		log.Printf("Marked %d interrupted imports as failed", failed)
+	}
+	return failed, nil
+}
+
+// finishImport saves an import job that stopped
+func (s *InventoryService) finishImport(job models.ImportJob) {
+	now := time.Now()
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if err := s.repository.UpdateImportJob(job); err != nil {
+		log.Printf("Failed to save import %s: %v", job.ID, err)
+	}
+}
+
+// importRow creates or updates the product or SKU of a row. It reports
+// whether the row was created.
+func (s *InventoryService) importRow(state *importState, row models.CatalogueRow, dryRun bool, caller string) (bool, error) {
+	row.SKU = strings.TrimSpace(row.SKU)
+	row.ParentSKU = strings.TrimSpace(row.ParentSKU)
+	if row.SKU == "" {
+		return false, errors.New("invalid sku: it is required")
+	}
+	if len(row.SKU) > 64 {
+		return false, errors.New("invalid sku: it is longer than 64 characters")
+	}
+	if state.seen[row.SKU] {
+		return false, fmt.Errorf("invalid sku: %s is listed more than once", row.SKU)
+	}
+	state.seen[row.SKU] = true
+
+	if row.Quantity != nil && *row.Quantity < 0 {
+		return false, errors.New("invalid quantity: it can't be negative")
+	}
+	warehouseID := state.defaultID
+	if row.Warehouse != "" {
+		var ok bool
+		if warehouseID, ok = state.warehouses[strings.ToUpper(strings.TrimSpace(row.Warehouse))]; !ok {
+			return false, fmt.Errorf("invalid warehouse: warehouse with code %s not found", row.Warehouse)
+		}
+	}
+	if row.Quantity != nil && warehouseID == "" {
+		return false, errors.New("invalid warehouse: there is no default warehouse, name one")
+	}
+
+	id, err := s.repository.GetProductIDBySKU(row.SKU)
+	if err != nil {
+		return false, err
+	}
+	if row.ParentSKU != "" {
+		return s.importSKU(state, row, id, warehouseID, dryRun, caller)
+	}
+	return s.importProduct(state, row, id, warehouseID, dryRun, caller)
+}
+
+// importProduct creates or updates a product from a row
+func (s *InventoryService) importProduct(state *importState, row models.CatalogueRow, id, warehouseID string, dryRun bool, caller string) (bool, error) {
+	if row.Barcode != "" || row.OptionValues != nil {
+		return false, errors.New("invalid row: barcode and option_values are for SKUs, which need a parent_sku")
+	}
+	switch row.Availability {
+	case "", models.AvailabilityStock, models.AvailabilityBackorder, models.AvailabilityPreorder:
+	default:
+		return false, fmt.Errorf("invalid availability %q, use stock, backorder or preorder", row.Availability)
+	}
+	switch row.Status {
+	case "", models.ProductStatusDraft, models.ProductStatusActive, models.ProductStatusDiscontinued, models.ProductStatusArchived:
+	default:
+		return false, fmt.Errorf("invalid status %q, use draft, active, discontinued or archived", row.Status)
+	}
+	if row.Price != nil && *row.Price <= 0 {
+		return false, errors.New("invalid price: it must be greater than 0")
+	}
+	var options []models.VariantOption
+	if row.Options != nil {
+		var err error
+		if options, err = checkOptions(row.Options); err != nil {
+			return false, err
+		}
+	}
+
+	if id == "" {
+		if row.Name == "" || row.Description == "" || row.CategoryID == "" || row.Price == nil {
+			return false, errors.New("invalid row: new products need a name, description, category_id and price")
+		}
+		if row.Status != "" && row.Status != models.ProductStatusDraft && row.Status != models.ProductStatusActive {
+			return false, fmt.Errorf("invalid status %q: new products are draft or active", row.Status)
+		}
+		if err := s.checkCategory(row.CategoryID); err != nil {
+			return false, err
+		}
+		state.options[row.SKU] = options
+		if dryRun {
+			return true, nil
+		}
+
+		product, err := s.CreateProduct(models.CreateProductRequest{
+			Code:         row.SKU,
+			Name:         row.Name,
+			Description:  row.Description,
+			CategoryID:   row.CategoryID,
+			Price:        *row.Price,
+			Tags:         row.Tags,
+			Availability: row.Availability,
+			Status:       row.Status,
+			Options:      options,
+			Caller:       caller,
+		})
+		if err != nil {
+			return false, err
+		}
+		if row.Quantity != nil {
+			_, err := s.SetStock(warehouseID, product.ID, models.SetStockRequest{Quantity: row.Quantity, Caller: caller})
+			if err != nil {
+				return true, fmt.Errorf("product created, but its stock wasn't set: %w", err)
+			}
+		}
+		return true, nil
+	}
+
+	product, err := s.repository.GetProductByID(id)
+	if err != nil {
+		return false, err
+	}
+	if product.ParentID != "" {
+		return false, fmt.Errorf("invalid row: %s is a SKU, set its parent_sku", row.SKU)
+	}
+	if row.CategoryID != "" && row.CategoryID != product.CategoryID {
+		if err := s.checkCategory(row.CategoryID); err != nil {
+			return false, err
+		}
+	}
+	if options != nil {
+		if err := s.checkSKUOptions(id, options); err != nil {
+			return false, err
+		}
+		state.options[row.SKU] = options
+	} else {
+		state.options[row.SKU] = product.Options
+	}
+	if row.Quantity != nil {
+		if err := s.checkStockItem(id); err != nil {
+			return false, err
+		}
+	}
+	if err := setStatus(&product, row.Status); err != nil {
+		return false, err
+	}
+	if dryRun {
+		return false, nil
+	}
+
+	req := models.UpdateProductRequest{
+		Name:         row.Name,
+		Description:  row.Description,
+		CategoryID:   row.CategoryID,
+		Tags:         row.Tags,
+		Availability: row.Availability,
+		Status:       row.Status,
+		Options:      options,
+		Caller:       caller,
+	}
+	if row.Price != nil {
+		req.Price = *row.Price
+	}
+	_, err = s.UpdateProduct(id, req)
+	if err != nil {
+		return false, err
+	}
+	if row.Quantity != nil {
+		_, err := s.SetStock(warehouseID, id, models.SetStockRequest{Quantity: row.Quantity, Caller: caller})
+		if err != nil {
+			return false, fmt.Errorf("product updated, but its stock wasn't set: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// importSKU creates or updates a SKU from a row. Its product is either in the
+// catalogue or on an earlier row of the file.
+func (s *InventoryService) importSKU(state *importState, row models.CatalogueRow, id, warehouseID string, dryRun bool, caller string) (bool, error) {
+	if row.Name != "" || row.Description != "" || row.CategoryID != "" || row.Tags != nil || row.Availability != "" || row.Status != "" || row.Options != nil {
+		return false, errors.New("invalid row: SKUs take their name, description, category, tags, availability, status and options from their product")
+	}
+	if row.Price != nil && *row.Price < 0 {
+		return false, errors.New("invalid price: it can't be negative")
+	}
+	if len(row.Barcode) > 64 {
+		return false, errors.New("invalid barcode: it is longer than 64 characters")
+	}
+
+	parentID, err := s.repository.GetProductIDBySKU(row.ParentSKU)
+	if err != nil {
+		return false, err
+	}
+	options, listed := state.options[row.ParentSKU]
+	var parent models.Product
+	if parentID != "" {
+		if parent, err = s.repository.GetProductByID(parentID); err != nil {
+			return false, err
+		}
+		if parent.ParentID != "" {
+			return false, fmt.Errorf("invalid parent_sku: %s is a SKU", row.ParentSKU)
+		}
+		if !listed {
+			options = parent.Options
+		}
+	} else if !listed || !dryRun {
+		// Outside dry runs, products listed earlier are created by now
+		return false, fmt.Errorf("invalid parent_sku: product with SKU %s not found, list it before its SKUs", row.ParentSKU)
+	}
+
+	if id != "" {
+		if parentID == "" {
+			return false, fmt.Errorf("invalid parent_sku: SKU %s belongs to another product", row.SKU)
+		}
+		_, sku, err := s.getSKU(parentID, id)
+		if err != nil {
+			return false, fmt.Errorf("invalid parent_sku: SKU %s is not a SKU of %s", row.SKU, row.ParentSKU)
+		}
+		if row.OptionValues != nil {
+			values, err := skuOptions(options, row.OptionValues)
+			if err != nil {
+				return false, err
+			}
+			if !sameOptions(values, sku.Options) {
+				return false, errors.New("invalid option_values: the options of a SKU can't be changed")
+			}
+		}
+		if dryRun {
+			return false, nil
+		}
+
+		req := models.UpdateSKURequest{PriceOverride: row.Price}
+		if row.Barcode != "" {
+			req.Barcode = &row.Barcode
+		}
+		_, err = s.UpdateSKU(parentID, id, req)
+		if err != nil {
+			return false, err
+		}
+		if row.Quantity != nil {
+			_, err := s.SetStock(warehouseID, id, models.SetStockRequest{Quantity: row.Quantity, Caller: caller})
+			if err != nil {
+				return false, fmt.Errorf("SKU updated, but its stock wasn't set: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	values, err := skuOptions(options, row.OptionValues)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range state.values[row.ParentSKU] {
+		if sameOptions(other, values) {
+			return false, errors.New("invalid option_values: a SKU earlier in the file has the same options")
+		}
+	}
+	if parentID != "" {
+		if err := s.checkNewSKU(parent, values); err != nil {
+			return false, err
+		}
+	}
+	state.values[row.ParentSKU] = append(state.values[row.ParentSKU], values)
+	if dryRun {
+		return true, nil
+	}
+
+	req := models.CreateSKURequest{
+		Code:    row.SKU,
+		Barcode: row.Barcode,
+		Options: values,
+		Caller:  caller,
+	}
+	if row.Price != nil && *row.Price > 0 {
+		req.PriceOverride = row.Price
+	}
+	sku, err := s.CreateSKU(parentID, req)
+	if err != nil {
+		return false, err
+	}
+	if row.Quantity != nil {
+		_, err := s.SetStock(warehouseID, sku.ID, models.SetStockRequest{Quantity: row.Quantity, Caller: caller})
+		if err != nil {
+			return true, fmt.Errorf("SKU created, but its stock wasn't set: %w", err)
+		}
+	}
+	return true, nil
+}
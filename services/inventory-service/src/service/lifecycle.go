@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// statusChanges are the statuses a product can move to from each status.
+// Products go on sale from draft and come off it discontinued or archived;
+// archived products come back as drafts.
+var statusChanges = map[string][]string{
+	models.ProductStatusDraft:        {models.ProductStatusActive, models.ProductStatusArchived},
+	models.ProductStatusActive:       {models.ProductStatusDiscontinued, models.ProductStatusArchived},
+	models.ProductStatusDiscontinued: {models.ProductStatusActive, models.ProductStatusArchived},
+	models.ProductStatusArchived:     {models.ProductStatusDraft},
+}
+
+// setStatus moves a product to a status, if it can move there from its
+// current one. New products and products from before statuses were kept are
+// active.
+func setStatus(product *models.Product, status string) error {
+	if product.Status == "" {
+		product.Status = models.ProductStatusActive
+	}
+	if status == "" || status == product.Status {
+		return nil
+	}
+
+	for _, next := range statusChanges[product.Status] {
+		if next == status {
+			product.Status = status
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid status change from %s to %s", product.Status, status)
+}
+
+// statusChanged tells carts and wishlists a product came off sale
+// (product_discontinued) or went back on sale (product_reactivated)
+func (s *InventoryService) statusChanged(product models.Product, previous string) {
+	wasSold := previous == models.ProductStatusActive
+	if product.Listed() == wasSold {
+		return
+	}
+
+	err := s.producer.PublishProductStatusChanged(product)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to publish status change of product %s: %v", product.ID, err)
+	}
+}
+
+// GetListedProducts retrieves the products listed on the storefront, the
+// active ones
+func (s *InventoryService) GetListedProducts() ([]models.Product, error) {
+	products, err := s.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	return listed(products), nil
+}
+
+// listed returns the products listed on the storefront
+func listed(products []models.Product) []models.Product {
+	result := make([]models.Product, 0, len(products))
+	for _, product := range products {
+		if product.Listed() {
+			result = append(result, product)
+		}
+	}
+	return result
+}
+
+// checkOrderable makes sure a product with a status can be ordered: it is on
+// sale
+func checkOrderable(id, status string) error {
+	if status == "" || status == models.ProductStatusActive {
+		return nil
+	}
+	return fmt.Errorf("invalid item: product with ID %s is %s", id, status)
+}
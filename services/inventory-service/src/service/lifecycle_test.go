@@ -0,0 +1,73 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+func TestSetStatus(t *testing.T) {
+	const (
+		draft        = models.ProductStatusDraft
+		active       = models.ProductStatusActive
+		discontinued = models.ProductStatusDiscontinued
+		archived     = models.ProductStatusArchived
+	)
+	tests := []struct {
+		from, to string
+		want     string // Status after the change, empty when it is refused
+	}{
+		{draft, active, active},
+		{draft, archived, archived},
+		{draft, discontinued, ""},
+		{active, discontinued, discontinued},
+		{active, archived, archived},
+		{active, draft, ""},
+		{discontinued, active, active},
+		{discontinued, archived, archived},
+		{discontinued, draft, ""},
+		{archived, draft, draft},
+		{archived, active, ""},
+		{archived, discontinued, ""},
+		{"", discontinued, discontinued}, // Products from before statuses are active
+		{"", draft, ""},
+		{"", "", active},
+		{draft, "", draft},
+		{draft, draft, draft},
+		{active, "deleted", ""},
+	}
+
+	for _, tt := range tests {
+		product := models.Product{Status: tt.from}
+		err := setStatus(&product, tt.to)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%q to %q: moved to %s, want it refused", tt.from, tt.to, product.Status)
+		case tt.want != "" && (err != nil || product.Status != tt.want):
+			t.Errorf("%q to %q: got %s, %v, want %s", tt.from, tt.to, product.Status, err, tt.want)
+		}
+	}
+}
+
+func TestListedAndOrderable(t *testing.T) {
+	products := []models.Product{
+		{ID: "draft", Status: models.ProductStatusDraft},
+		{ID: "active", Status: models.ProductStatusActive},
+		{ID: "discontinued", Status: models.ProductStatusDiscontinued},
+		{ID: "archived", Status: models.ProductStatusArchived},
+	}
+	if got := ids(listed(products)); !reflect.DeepEqual(got, []string{"active"}) {
+		t.Errorf("listed = %v, want only the active product", got)
+	}
+
+	for _, product := range products {
+		err := checkOrderable(product.ID, product.Status)
+		if (err == nil) != (product.Status == models.ProductStatusActive) {
+			t.Errorf("checkOrderable(%s) = %v", product.Status, err)
+		}
+	}
+	if err := checkOrderable("old", ""); err != nil {
+		t.Errorf("product from before statuses can't be ordered: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// SchedulePrice schedules a price for a product: a sale price until EndsAt,
+// or a new price for good without one. Schedules of a product can't overlap.
+// Prices starting now or in the past are applied at once, the others by the
+// scheduler.
+func (s *InventoryService) SchedulePrice(productID string, req models.CreatePriceScheduleRequest) (models.PriceSchedule, error) {
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.PriceSchedule{}, err
+	}
+	if product.ParentID != "" {
+		return models.PriceSchedule{}, fmt.Errorf("product with ID %s not found", productID)
+	}
+
+	// Times are kept in UTC, so the scheduler compares them with now
+	now := time.Now().UTC()
+	schedule := models.PriceSchedule{
+		ID:        db.GenerateID(),
+		ProductID: productID,
+		Price:     req.Price,
+		StartsAt:  req.StartsAt.UTC(),
+		Status:    models.PriceScheduleScheduled,
+		Caller:    req.Caller,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if schedule.StartsAt.Before(now) {
+		schedule.StartsAt = now
+	}
+	if req.EndsAt != nil {
+		endsAt := req.EndsAt.UTC()
+		if !endsAt.After(schedule.StartsAt) {
+			return models.PriceSchedule{}, fmt.Errorf("invalid ends_at: it must be after starts_at and in the future")
+		}
+		schedule.EndsAt = &endsAt
+	}
+
+	err = s.repository.CreatePriceSchedule(schedule, func(open []models.PriceSchedule) error {
+		for _, other := range open {
+			if overlaps(schedule, other) {
+				return fmt.Errorf("price schedule overlaps price schedule %s of the product", other.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PriceSchedule{}, err
+	}
+	if schedule.StartsAt.Equal(now) {
+		if err := s.startSchedule(schedule, now); err != nil {
+			return models.PriceSchedule{}, err
+		}
+		return s.repository.GetPriceSchedule(schedule.ID)
+	}
+
+	return schedule, nil
+}
+
+// overlaps reports whether two price schedules of a product overlap. A sale
+// runs from its start to its end; a price without an end changes the price at
+// its start, which can't be during a sale.
+func overlaps(a, b models.PriceSchedule) bool {
+	if a.EndsAt == nil && b.EndsAt == nil {
+		return a.StartsAt.Equal(b.StartsAt)
+	}
+	if a.EndsAt == nil {
+		a, b = b, a
+	}
+	if b.EndsAt == nil {
+		return !b.StartsAt.Before(a.StartsAt) && !b.StartsAt.After(*a.EndsAt)
+	}
+	return a.StartsAt.Before(*b.EndsAt) && b.StartsAt.Before(*a.EndsAt)
+}
+
+// CancelPriceSchedule cancels a price schedule of a product. A running sale
+// ends at once, the product going back to its regular price.
+func (s *InventoryService) CancelPriceSchedule(productID, scheduleID, caller string) (models.PriceSchedule, error) {
+	schedule, err := s.repository.GetPriceSchedule(scheduleID)
+	if err != nil {
+		return models.PriceSchedule{}, err
+	}
+	if schedule.ProductID != productID {
+		return models.PriceSchedule{}, fmt.Errorf("price schedule with ID %s not found", scheduleID)
+	}
+
+	now := time.Now().UTC()
+	if schedule.Status == models.PriceScheduleScheduled {
+		cancelled, err := s.repository.SetPriceScheduleStatus(scheduleID, models.PriceScheduleScheduled, models.PriceScheduleCancelled, now)
+		if err != nil {
+			return models.PriceSchedule{}, err
+		}
+		if !cancelled {
+			// It started meanwhile
+			if schedule, err = s.repository.GetPriceSchedule(scheduleID); err != nil {
+				return models.PriceSchedule{}, err
+			}
+		}
+	}
+	if schedule.Status == models.PriceScheduleActive {
+		change, ended, err := s.repository.EndPriceSchedule(schedule, models.PriceScheduleCancelled, caller, now)
+		if err != nil {
+			return models.PriceSchedule{}, err
+		}
+		if ended {
+			s.invalidate(productID)
+			s.priceChanged(change)
+		}
+	}
+
+	schedule, err = s.repository.GetPriceSchedule(scheduleID)
+	if err != nil {
+		return models.PriceSchedule{}, err
+	}
+	if schedule.Status != models.PriceScheduleCancelled {
+		return models.PriceSchedule{}, fmt.Errorf("invalid cancellation: price schedule %s is %s", scheduleID, schedule.Status)
+	}
+	return schedule, nil
+}
+
+// GetPriceHistory retrieves the price of a product with its price history and
+// the price schedules still to start or end
+func (s *InventoryService) GetPriceHistory(productID string) (models.PriceHistory, error) {
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	if product.ParentID != "" {
+		return models.PriceHistory{}, fmt.Errorf("product with ID %s not found", productID)
+	}
+
+	changes, err := s.repository.GetPriceChanges(productID)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	schedules, err := s.repository.GetPriceSchedules(productID, models.PriceScheduleScheduled, models.PriceScheduleActive)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+
+	return models.PriceHistory{
+		ProductID:  productID,
+		Price:      product.Price,
+		WasPrice:   product.WasPrice,
+		SaleEndsAt: product.SaleEndsAt,
+		Changes:    changes,
+		Schedules:  schedules,
+	}, nil
+}
+
+// ApplyPriceSchedules starts the price schedules due to start and ends the
+// sales due to end. Schedules whose whole sale passed before they could be
+// started, while the service was down, are completed without changing the
+// price. It returns the number of schedules started or ended.
+func (s *InventoryService) ApplyPriceSchedules() (int, error) {
+	now := time.Now().UTC()
+	due, err := s.repository.GetDuePriceSchedules(now)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, schedule := range due {
+		switch {
+		case schedule.Status == models.PriceScheduleScheduled && schedule.EndsAt != nil && !schedule.EndsAt.After(now):
+			_, err = s.repository.SetPriceScheduleStatus(schedule.ID, models.PriceScheduleScheduled, models.PriceScheduleCompleted, now)
+			if err == nil {
+				log.Printf("Skipped price schedule %s of product %s, its sale ended before it started", schedule.ID, schedule.ProductID)
+			}
+		case schedule.Status == models.PriceScheduleScheduled:
+			err = s.startSchedule(schedule, now)
+		default:
+			var change models.PriceChange
+			var ended bool
+			change, ended, err = s.repository.EndPriceSchedule(schedule, models.PriceScheduleCompleted, "schedule:"+schedule.ID, now)
+			if err == nil && ended {
+				s.invalidate(schedule.ProductID)
+				s.priceChanged(change)
+			}
+		}
+		if err != nil {
+			// Log error but continue
+			log.Printf("Failed to apply price schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// startSchedule sets the price of a product to the price of a schedule
+func (s *InventoryService) startSchedule(schedule models.PriceSchedule, now time.Time) error {
+	change, started, err := s.repository.StartPriceSchedule(schedule, now)
+	if err != nil || !started {
+		return err
+	}
+
+	s.invalidate(schedule.ProductID)
+	s.priceChanged(change)
+	return nil
+}
+
+// priceChanged tells carts and wishlists the price of a product changed
+func (s *InventoryService) priceChanged(change models.PriceChange) {
+	if change.Price == change.PreviousPrice {
+		return
+	}
+
+	err := s.producer.PublishPriceChanged(change)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to publish price change of product %s: %v", change.ProductID, err)
+	}
+}
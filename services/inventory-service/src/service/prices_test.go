@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+func TestOverlaps(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 11, n, 0, 0, 0, 0, time.UTC) }
+	sale := func(from, to int) models.PriceSchedule {
+		end := day(to)
+		return models.PriceSchedule{StartsAt: day(from), EndsAt: &end}
+	}
+	change := func(at int) models.PriceSchedule {
+		return models.PriceSchedule{StartsAt: day(at)}
+	}
+
+	tests := []struct {
+		name string
+		a, b models.PriceSchedule
+		want bool
+	}{
+		{"sales apart", sale(1, 5), sale(10, 12), false},
+		{"sale ending as the next starts", sale(1, 5), sale(5, 8), false},
+		{"sales overlapping", sale(1, 5), sale(4, 8), true},
+		{"sale within a sale", sale(1, 10), sale(3, 4), true},
+		{"price change during a sale", sale(1, 5), change(3), true},
+		{"price change as a sale starts", change(1), sale(1, 5), true},
+		{"price change as a sale ends", sale(1, 5), change(5), true},
+		{"price change before a sale", change(1), sale(2, 5), false},
+		{"price change after a sale", sale(1, 5), change(6), false},
+		{"price changes at once", change(3), change(3), true},
+		{"price changes apart", change(3), change(4), false},
+	}
+	for _, tt := range tests {
+		if got := overlaps(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: overlaps = %t, want %t", tt.name, got, tt.want)
+		}
+		if got := overlaps(tt.b, tt.a); got != tt.want {
+			t.Errorf("%s, swapped: overlaps = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
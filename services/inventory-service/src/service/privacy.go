@@ -0,0 +1,43 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// inventoryServiceName names inventory-service in erasure reports
+const inventoryServiceName = "inventory-service"
+
+// GetCustomerData gathers the personal data inventory-service holds about a
+// customer for a data export: the products bought in their orders
+func (s *InventoryService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	purchases, err := s.repository.GetPurchasesByCustomer(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+
+	return models.CustomerData{CustomerID: customerID, Purchases: purchases}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Purchases are kept for recommendations
+// under an opaque ID that no longer leads to the customer.
+func (s *InventoryService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    inventoryServiceName,
+		Status:     models.ErasureCompleted,
+		Note:       "purchases kept for recommendations under an anonymous ID",
+	}
+
+	count, err := s.repository.AnonymizePurchases(event.CustomerID)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+	report.Erased, report.Retained = count, count
+
+	return s.producer.PublishErasureCompleted(report)
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+const (
+	// maxSimilarities is how many similar products are kept per product and
+	// kind
+	maxSimilarities = 20
+	// maxBasketProducts is the most products an order or customer may have
+	// bought to count. Bulk buyers tell little about what goes together.
+	maxBasketProducts = 100
+	// completedWeight is how much more delivered orders count than orders
+	// that were only placed
+	completedWeight = 2.0
+)
+
+// RecordPurchase records the products bought in an order, for computing
+// which products are bought together. SKUs count as their product.
+func (s *InventoryService) RecordPurchase(orderID, customerID string, productIDs []string, completed bool) error {
+	seen := make(map[string]bool, len(productIDs))
+	var unique []string
+	for _, productID := range productIDs {
+		if productID != "" && !seen[productID] {
+			seen[productID] = true
+			unique = append(unique, productID)
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	return s.repository.RecordPurchases(orderID, customerID, unique, completed, time.Now())
+}
+
+// CancelPurchase forgets the products of an order that was cancelled or
+// never paid, so they don't count as bought together
+func (s *InventoryService) CancelPurchase(orderID string) error {
+	return s.repository.DeletePurchases(orderID)
+}
+
+// ComputeSimilarities computes which products are frequently bought together
+// and which are bought by the same customers from the orders of the
+// recommendation window. It returns how many similarities it found.
+func (s *InventoryService) ComputeSimilarities() (int, error) {
+	now := time.Now()
+	purchases, err := s.repository.GetPurchases(now.AddDate(0, 0, -s.config.RecommendationWindowDays))
+	if err != nil {
+		return 0, err
+	}
+
+	// Products deleted since they were bought can't be recommended
+	products, err := s.repository.GetProducts()
+	if err != nil {
+		return 0, err
+	}
+	exists := make(map[string]bool, len(products))
+	for _, product := range products {
+		exists[product.ID] = true
+	}
+
+	orders := make(map[string]map[string]float64)
+	customers := make(map[string]map[string]float64)
+	for _, purchase := range purchases {
+		if !exists[purchase.ProductID] {
+			continue
+		}
+		weight := 1.0
+		if purchase.Completed {
+			weight = completedWeight
+		}
+		addToBasket(orders, purchase.OrderID, purchase.ProductID, weight)
+		if purchase.CustomerID != "" {
+			addToBasket(customers, purchase.CustomerID, purchase.ProductID, weight)
+		}
+	}
+
+	total := 0
+	for kind, baskets := range map[string]map[string]map[string]float64{
+		models.SimilarityBoughtTogether: orders,
+		models.SimilarityAlsoBought:     customers,
+	} {
+		similarities := similarities(baskets, s.config.RecommendationMinSupport)
+		for i := range similarities {
+			similarities[i].ID = db.GenerateID()
+			similarities[i].Kind = kind
+			similarities[i].CreatedAt = now
+		}
+		if err := s.repository.ReplaceSimilarities(kind, similarities); err != nil {
+			return total, err
+		}
+		total += len(similarities)
+	}
+
+	// Recommendations cached before are stale
+	s.invalidate()
+
+	log.Printf("Computed %d product similarities from %d purchases", total, len(purchases))
+	return total, nil
+}
+
+// addToBasket adds a product to the basket of an order or customer, keeping
+// its largest weight
+func addToBasket(baskets map[string]map[string]float64, key, productID string, weight float64) {
+	if baskets[key] == nil {
+		baskets[key] = make(map[string]float64)
+	}
+	baskets[key][productID] = max(baskets[key][productID], weight)
+}
+
+// similarities scores every pair of products by the cosine of their
+// weighted baskets: 1 when they are always bought together, 0 when never.
+// Only pairs in at least minSupport baskets are kept, the best of each
+// product first.
+func similarities(baskets map[string]map[string]float64, minSupport int) []models.ProductSimilarity {
+	type pair struct{ a, b string }
+	norms := make(map[string]float64)
+	products := make(map[pair]float64)
+	support := make(map[pair]int)
+	for _, basket := range baskets {
+		if len(basket) > maxBasketProducts {
+			continue
+		}
+		for a, weightA := range basket {
+			norms[a] += weightA * weightA
+			for b, weightB := range basket {
+				if a != b {
+					products[pair{a, b}] += weightA * weightB
+					support[pair{a, b}]++
+				}
+			}
+		}
+	}
+
+	similar := make(map[string][]models.ProductSimilarity)
+	for p, product := range products {
+		if support[p] < minSupport {
+			continue
+		}
+		score := product / math.Sqrt(norms[p.a]*norms[p.b])
+		similar[p.a] = append(similar[p.a], models.ProductSimilarity{
+			ProductID:        p.a,
+			SimilarProductID: p.b,
+			SimilarityScore:  math.Round(min(score, 1)*10000) / 10000,
+			Support:          support[p],
+		})
+	}
+
+	var result []models.ProductSimilarity
+	for _, list := range similar {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].SimilarityScore != list[j].SimilarityScore {
+				return list[i].SimilarityScore > list[j].SimilarityScore
+			}
+			if list[i].Support != list[j].Support {
+				return list[i].Support > list[j].Support
+			}
+			return list[i].SimilarProductID < list[j].SimilarProductID
+		})
+		result = append(result, list[:min(len(list), maxSimilarities)]...)
+	}
+	return result
+}
+
+// learntRecommendations returns the IDs of the products learnt to go with a
+// product, best first. Without a kind, products bought together come before
+// products bought by the same customers.
+func (s *InventoryService) learntRecommendations(productID, kind string, limit int) ([]string, error) {
+	kinds := []string{models.SimilarityBoughtTogether, models.SimilarityAlsoBought}
+	if kind != "" {
+		kinds = []string{kind}
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, kind := range kinds {
+		similarities, err := s.repository.GetSimilarities(productID, kind, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, similarity := range similarities {
+			if !seen[similarity.SimilarProductID] {
+				seen[similarity.SimilarProductID] = true
+				ids = append(ids, similarity.SimilarProductID)
+			}
+		}
+	}
+	return ids[:min(len(ids), limit)], nil
+}
+
+// fallbackRecommendations ranks the catalogue for products with too little
+// order history: products of the same category first, then products sharing
+// the most tags
+func fallbackRecommendations(product models.Product, catalogue []models.Product) []models.Product {
+	type candidate struct {
+		product models.Product
+		score   int
+	}
+	var candidates []candidate
+	for _, other := range catalogue {
+		if other.ID == product.ID {
+			continue
+		}
+		score := 0
+		// Uncategorised products aren't alike for lacking a category
+		if product.CategoryID != "" && other.CategoryID == product.CategoryID {
+			score += len(product.Tags) + 1
+		}
+		for _, tag := range other.Tags {
+			if containsAnyTag(product.Tags, []string{tag}) {
+				score++
+			}
+		}
+		candidates = append(candidates, candidate{other, score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	products := make([]models.Product, 0, len(candidates))
+	for _, candidate := range candidates {
+		products = append(products, candidate.product)
+	}
+	return products
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+func TestSimilarities(t *testing.T) {
+	baskets := make(map[string]map[string]float64)
+	// Delivered orders weigh more, and a product counts once per basket at
+	// its largest weight
+	addToBasket(baskets, "order-1", "pan", completedWeight)
+	addToBasket(baskets, "order-1", "lid", completedWeight)
+	addToBasket(baskets, "order-2", "pan", 1)
+	addToBasket(baskets, "order-2", "pan", completedWeight)
+	addToBasket(baskets, "order-2", "lid", completedWeight)
+	addToBasket(baskets, "order-2", "oil", 1)
+	addToBasket(baskets, "order-3", "pan", 1)
+	addToBasket(baskets, "order-3", "oil", 1)
+	// Bulk orders are left out
+	for i := 0; i <= maxBasketProducts; i++ {
+		addToBasket(baskets, "bulk", fmt.Sprintf("product-%d", i), 1)
+	}
+	addToBasket(baskets, "bulk", "lid", 1)
+	addToBasket(baskets, "bulk", "oil", 1)
+
+	tests := []struct {
+		minSupport int
+		want       map[string][]string
+	}{
+		{
+			minSupport: 2,
+			want: map[string][]string{
+				"pan": {"lid 0.9428 (2)", "oil 0.7071 (2)"},
+				"lid": {"pan 0.9428 (2)"},
+				"oil": {"pan 0.7071 (2)"},
+			},
+		},
+		{
+			minSupport: 1,
+			want: map[string][]string{
+				"pan": {"lid 0.9428 (2)", "oil 0.7071 (2)"},
+				"lid": {"pan 0.9428 (2)", "oil 0.5 (1)"},
+				"oil": {"pan 0.7071 (2)", "lid 0.5 (1)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := make(map[string][]string)
+		for _, similarity := range similarities(baskets, tt.minSupport) {
+			got[similarity.ProductID] = append(got[similarity.ProductID],
+				fmt.Sprintf("%s %v (%d)", similarity.SimilarProductID, similarity.SimilarityScore, similarity.Support))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("similarities with support %d = %v, want %v", tt.minSupport, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackRecommendations(t *testing.T) {
+	product := models.Product{ID: "pan", CategoryID: "kitchen", Tags: []string{"chảo", "bếp"}}
+	catalogue := []models.Product{
+		{ID: "garden-hose", CategoryID: "garden"},
+		{ID: "knife", CategoryID: "tools", Tags: []string{"bếp"}},
+		product,
+		{ID: "wok", CategoryID: "tools", Tags: []string{"chảo", "bếp"}},
+		{ID: "pot", CategoryID: "kitchen"},
+	}
+
+	// The same category outweighs every shared tag
+	var got []string
+	for _, recommended := range fallbackRecommendations(product, catalogue) {
+		got = append(got, recommended.ID)
+	}
+	want := []string{"pot", "wok", "knife", "garden-hose"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fallbackRecommendations = %v, want %v", got, want)
+	}
+
+	// Products without a category are only alike by their tags
+	product = models.Product{ID: "lamp", Tags: []string{"đèn"}}
+	catalogue = []models.Product{
+		{ID: "chair"},
+		{ID: "bulb", CategoryID: "lighting", Tags: []string{"đèn"}},
+		product,
+	}
+	got = nil
+	for _, recommended := range fallbackRecommendations(product, catalogue) {
+		got = append(got, recommended.ID)
+	}
+	want = []string{"bulb", "chair"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fallbackRecommendations without a category = %v, want %v", got, want)
+	}
+}
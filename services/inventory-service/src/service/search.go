@@ -0,0 +1,345 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// Default and largest number of products on a page of search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Weights of words by where they are found in a product
+const (
+	nameWeight        = 3.0
+	tagWeight         = 2.0
+	descriptionWeight = 1.0
+)
+
+// ErrInvalidCursor is returned for cursors that weren't returned by a search
+// with the same order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// foldVietnamese replaces Vietnamese letters with diacritics by the plain
+// letter, so searches match with or without them
+var foldVietnamese = func() *strings.Replacer {
+	groups := map[string]string{
+		"a": "àáạảãâầấậẩẫăằắặẳẵ",
+		"e": "èéẹẻẽêềếệểễ",
+		"i": "ìíịỉĩ",
+		"o": "òóọỏõôồốộổỗơờớợởỡ",
+		"u": "ùúụủũưừứựửữ",
+		"y": "ỳýỵỷỹ",
+		"d": "đ",
+	}
+	var pairs []string
+	for plain, letters := range groups {
+		for _, letter := range letters {
+			pairs = append(pairs, string(letter), plain)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// normalize lower-cases text and folds diacritics
+func normalize(text string) string {
+	return foldVietnamese.Replace(strings.ToLower(text))
+}
+
+// tokenize splits text into normalized words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchIndex keeps the words of every product in memory. It is dropped
+// whenever a product or its stock changes and rebuilt by the next search.
+type searchIndex struct {
+	mu       sync.Mutex
+	snapshot *searchSnapshot
+}
+
+// searchSnapshot is the catalogue at the time the index was built. It is
+// never changed, so searches can share it.
+type searchSnapshot struct {
+	products []models.Product
+	names    []string             // Normalized names, for matching whole queries
+	terms    []map[string]float64 // Words of each product with their weights
+}
+
+// invalidate drops the index, so the next search rebuilds it
+func (i *searchIndex) invalidate() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.snapshot = nil
+}
+
+// get returns the index, building it with load when it was dropped
+func (i *searchIndex) get(load func() ([]models.Product, error)) (*searchSnapshot, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.snapshot != nil {
+		return i.snapshot, nil
+	}
+
+	products, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &searchSnapshot{
+		products: products,
+		names:    make([]string, len(products)),
+		terms:    make([]map[string]float64, len(products)),
+	}
+	for n, product := range products {
+		terms := make(map[string]float64)
+		add := func(text string, weight float64) {
+			for _, term := range tokenize(text) {
+				terms[term] = max(terms[term], weight)
+			}
+		}
+		add(product.Name, nameWeight)
+		for _, tag := range product.Tags {
+			add(tag, tagWeight)
+		}
+		add(product.Description, descriptionWeight)
+
+		snapshot.names[n] = strings.Join(tokenize(product.Name), " ")
+		snapshot.terms[n] = terms
+	}
+	i.snapshot = snapshot
+	return snapshot, nil
+}
+
+// score returns how well product n matches the words of a query, 0 when it
+// doesn't. A word matches words of the product it is equal to, or half as
+// well words it starts.
+func (s *searchSnapshot) score(n int, words []string) float64 {
+	total := 0.0
+	for _, word := range words {
+		best := 0.0
+		for term, weight := range s.terms[n] {
+			if term == word {
+				best = max(best, weight)
+			} else if strings.HasPrefix(term, word) {
+				best = max(best, weight/2)
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	// Names containing the query as typed rank first
+	if len(words) > 1 && strings.Contains(s.names[n], strings.Join(words, " ")) {
+		total += nameWeight
+	}
+	return total
+}
+
+// searchCursor is the position of the last product of a page. Pages continue
+// after it in the order of the search.
+type searchCursor struct {
+	Sort string     `json:"s"`
+	Key  [2]float64 `json:"k"`
+	ID   string     `json:"id"`
+}
+
+// searchHit is a matching product with the key it is ordered by
+type searchHit struct {
+	product models.Product
+	key     [2]float64
+}
+
+// before reports whether a hit comes before a position in the order
+func (h searchHit) before(key [2]float64, id string) bool {
+	if h.key[0] != key[0] {
+		return h.key[0] < key[0]
+	}
+	if h.key[1] != key[1] {
+		return h.key[1] < key[1]
+	}
+	return h.product.ID < id
+}
+
+// sortKey orders products ascending, newest first when equal
+func sortKey(order string, product models.Product, score float64) [2]float64 {
+	newest := -float64(product.CreatedAt.UnixMicro())
+	switch order {
+	case models.SearchSortPriceAsc:
+		return [2]float64{product.Price, newest}
+	case models.SearchSortPriceDesc:
+		return [2]float64{-product.Price, newest}
+	case models.SearchSortNewest:
+		return [2]float64{newest, 0}
+	}
+	return [2]float64{-score, newest}
+}
+
+// SearchProducts searches the catalogue by words, category, price, tags and
+// stock. The category filter can take in its subcategories. Results are
+// sorted by relevance when there is a query and newest first otherwise.
+func (s *InventoryService) SearchProducts(search models.ProductSearch) (models.ProductSearchResult, error) {
+	if search.Sort == "" {
+		search.Sort = models.SearchSortNewest
+		if strings.TrimSpace(search.Query) != "" {
+			search.Sort = models.SearchSortRelevance
+		}
+	}
+	if search.Limit <= 0 {
+		search.Limit = defaultSearchLimit
+	}
+	search.Limit = min(search.Limit, maxSearchLimit)
+
+	var after *searchCursor
+	if search.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(search.Cursor)
+		if err != nil {
+			return models.ProductSearchResult{}, ErrInvalidCursor
+		}
+		after = &searchCursor{}
+		if err := json.Unmarshal(data, after); err != nil || after.Sort != search.Sort {
+			return models.ProductSearchResult{}, ErrInvalidCursor
+		}
+	}
+
+	snapshot, err := s.search.get(s.catalogue)
+	if err != nil {
+		return models.ProductSearchResult{}, err
+	}
+
+	var categories map[string]bool
+	if search.CategoryID != "" {
+		categoryIDs := []string{search.CategoryID}
+		if search.IncludeDescendants {
+			if categoryIDs, err = s.categorySubtree(search.CategoryID); err != nil {
+				return models.ProductSearchResult{}, err
+			}
+		}
+		categories = make(map[string]bool, len(categoryIDs))
+		for _, categoryID := range categoryIDs {
+			categories[categoryID] = true
+		}
+	}
+
+	words := tokenize(search.Query)
+	tags := make([]string, 0, len(search.Tags))
+	for _, tag := range search.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, normalize(tag))
+		}
+	}
+
+	categoryCounts := make(map[string]int)
+	tagCounts := make(map[string]int)
+	var hits []searchHit
+	for n, product := range snapshot.products {
+		score := 1.0
+		if len(words) > 0 {
+			if score = snapshot.score(n, words); score == 0 {
+				continue
+			}
+		}
+		if search.MinPrice != nil && product.Price < *search.MinPrice {
+			continue
+		}
+		if search.MaxPrice != nil && product.Price > *search.MaxPrice {
+			continue
+		}
+		if search.InStock && product.Quantity <= 0 {
+			continue
+		}
+		if search.Status != "" && product.Status != search.Status {
+			continue
+		}
+		if !hasTags(product, tags) {
+			continue
+		}
+
+		// Category counts ignore the category filter
+		categoryCounts[product.CategoryID]++
+		if categories != nil && !categories[product.CategoryID] {
+			continue
+		}
+		for _, tag := range product.Tags {
+			tagCounts[tag]++
+		}
+
+		hits = append(hits, searchHit{product: product, key: sortKey(search.Sort, product, score)})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].before(hits[j].key, hits[j].product.ID)
+	})
+
+	result := models.ProductSearchResult{
+		Products: []models.Product{},
+		Total:    len(hits),
+		Facets: models.SearchFacets{
+			Categories: facetCounts(categoryCounts),
+			Tags:       facetCounts(tagCounts),
+		},
+	}
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(hits), func(i int) bool {
+			return !hits[i].before(after.Key, after.ID) && hits[i].product.ID != after.ID
+		})
+	}
+	end := min(start+search.Limit, len(hits))
+	for _, hit := range hits[start:end] {
+		result.Products = append(result.Products, hit.product)
+	}
+
+	if end < len(hits) {
+		last := hits[end-1]
+		data, _ := json.Marshal(searchCursor{Sort: search.Sort, Key: last.key, ID: last.product.ID})
+		result.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	return result, nil
+}
+
+// hasTags reports whether a product has every one of the normalized tags
+func hasTags(product models.Product, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, productTag := range product.Tags {
+			if normalize(productTag) == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// facetCounts returns the counts of facet values, most common first
+func facetCounts(counts map[string]int) []models.FacetCount {
+	facets := make([]models.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, models.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// newSearchService returns a service whose search index holds products
+func newSearchService(t *testing.T, products []models.Product) *InventoryService {
+	s := &InventoryService{}
+	_, err := s.search.get(func() ([]models.Product, error) { return products, nil })
+	if err != nil {
+		t.Fatalf("failed to build search index: %v", err)
+	}
+	return s
+}
+
+// testCatalogue is a small kitchen shop, each product newer than the last
+func testCatalogue() []models.Product {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	products := []models.Product{
+		{ID: "p1", Name: "Chảo chống dính", CategoryID: "kitchen", Price: 300, Quantity: 5, Tags: []string{"chảo", "bếp"}},
+		{ID: "p2", Name: "Nồi inox", CategoryID: "kitchen", Price: 500, Tags: []string{"nồi", "bếp"}},
+		{ID: "p3", Name: "Chảo gang", CategoryID: "kitchen", Price: 700, Quantity: 2, Tags: []string{"chảo"}},
+		{ID: "p4", Name: "Dao bếp", Description: "Dùng cho chảo", CategoryID: "tools", Price: 150, Quantity: 9, Tags: []string{"dao", "bếp"}},
+		{ID: "p5", Name: "Thớt gỗ", CategoryID: "tools", Price: 90, Quantity: 1},
+	}
+	for i := range products {
+		products[i].CreatedAt = created.Add(time.Duration(i) * time.Hour)
+	}
+	return products
+}
+
+// ids returns the IDs of products
+func ids(products []models.Product) []string {
+	var ids []string
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Chảo CHỐNG-dính 24cm, Đỏ!")
+	want := []string{"chao", "chong", "dinh", "24cm", "do"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	price := func(p float64) *float64 { return &p }
+	tests := []struct {
+		name   string
+		search models.ProductSearch
+		want   []string
+	}{
+		{"names outrank descriptions, newest first when equal", models.ProductSearch{Query: "chao"}, []string{"p3", "p1", "p4"}},
+		{"diacritics and case are ignored", models.ProductSearch{Query: "CHẢO"}, []string{"p3", "p1", "p4"}},
+		{"every word has to match", models.ProductSearch{Query: "chao chong"}, []string{"p1"}},
+		{"words match the start of words", models.ProductSearch{Query: "cha"}, []string{"p3", "p1", "p4"}},
+		{"no match", models.ProductSearch{Query: "ấm"}, nil},
+		{"newest first without a query", models.ProductSearch{InStock: true}, []string{"p5", "p4", "p3", "p1"}},
+		{"category", models.ProductSearch{CategoryID: "tools"}, []string{"p5", "p4"}},
+		{
+			"price range by price",
+			models.ProductSearch{MinPrice: price(100), MaxPrice: price(500), Sort: models.SearchSortPriceAsc},
+			[]string{"p4", "p1", "p2"},
+		},
+		{"tags by price, highest first", models.ProductSearch{Tags: []string{" Bếp "}, Sort: models.SearchSortPriceDesc}, []string{"p2", "p1", "p4"}},
+	}
+
+	s := newSearchService(t, testCatalogue())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.SearchProducts(tt.search)
+			if err != nil {
+				t.Fatalf("SearchProducts: %v", err)
+			}
+			if got := ids(result.Products); !reflect.DeepEqual(got, tt.want) || result.Total != len(tt.want) {
+				t.Errorf("found %v (total %d), want %v", got, result.Total, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	s := newSearchService(t, testCatalogue())
+	result, err := s.SearchProducts(models.ProductSearch{Query: "bep", CategoryID: "kitchen"})
+	if err != nil {
+		t.Fatalf("SearchProducts: %v", err)
+	}
+
+	// Categories are counted before the category filter, tags after it
+	want := models.SearchFacets{
+		Categories: []models.FacetCount{{Value: "kitchen", Count: 2}, {Value: "tools", Count: 1}},
+		Tags:       []models.FacetCount{{Value: "bếp", Count: 2}, {Value: "chảo", Count: 1}, {Value: "nồi", Count: 1}},
+	}
+	if !reflect.DeepEqual(result.Facets, want) {
+		t.Errorf("facets %+v, want %+v", result.Facets, want)
+	}
+}
+
+func TestSearchCursorPages(t *testing.T) {
+	s := newSearchService(t, testCatalogue())
+
+	var pages [][]string
+	search := models.ProductSearch{Limit: 2}
+	for {
+		result, err := s.SearchProducts(search)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		pages = append(pages, ids(result.Products))
+		if result.NextCursor == "" {
+			break
+		}
+		search.Cursor = result.NextCursor
+		if len(pages) > 5 {
+			t.Fatal("pages don't end")
+		}
+	}
+	want := [][]string{{"p5", "p4"}, {"p3", "p2"}, {"p1"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages %v, want %v", pages, want)
+	}
+
+	// Cursors only continue the order they came from
+	first, _ := s.SearchProducts(models.ProductSearch{Limit: 2})
+	for _, search := range []models.ProductSearch{
+		{Cursor: first.NextCursor, Sort: models.SearchSortPriceAsc},
+		{Cursor: "not a cursor"},
+	} {
+		if _, err := s.SearchProducts(search); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q with sort %q: got %v, want ErrInvalidCursor", search.Cursor, search.Sort, err)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/online-order-system/inventory-service/cache"
@@ -20,40 +22,73 @@ type InventoryService struct {
 	config     *config.Config
 	repository *db.InventoryRepository
 	producer   interfaces.InventoryProducer
-	cache      *cache.RedisCache
+	cache      *cache.Cache
+	strategy   string
+
+	// Serialises filling backorders, so stock goes to them in order
+	backorderMu sync.Mutex
+
+	// Runs catalogue imports one at a time, so two files don't race for the
+	// same SKUs
+	importMu sync.Mutex
+
+	// Words of the catalogue for product search
+	search searchIndex
 }
 
 // Ensure InventoryService implements InventoryService interface
 var _ interfaces.InventoryService = (*InventoryService)(nil)
 
 // NewInventoryService creates a new inventory service
-func NewInventoryService(cfg *config.Config, repo *db.InventoryRepository, producer interfaces.InventoryProducer, redisCache *cache.RedisCache) *InventoryService {
+func NewInventoryService(cfg *config.Config, repo *db.InventoryRepository, producer interfaces.InventoryProducer, productCache *cache.Cache) *InventoryService {
+	strategy := cfg.FulfilmentStrategy
+	if !validStrategy(strategy) {
+		log.Printf("Unknown fulfilment strategy %q, using %s", strategy, StrategyFewestSplits)
+		strategy = StrategyFewestSplits
+	}
+
 	return &InventoryService{
 		config:     cfg,
 		repository: repo,
 		producer:   producer,
-		cache:      redisCache,
+		cache:      productCache,
+		strategy:   strategy,
 	}
 }
 
 // CreateProduct creates a new product with initial inventory
 func (s *InventoryService) CreateProduct(req models.CreateProductRequest) (models.Product, error) {
+	if err := s.checkCategory(req.CategoryID); err != nil {
+		return models.Product{}, err
+	}
+	options, err := checkOptions(req.Options)
+	if err != nil {
+		return models.Product{}, err
+	}
+
 	// Create product
 	now := time.Now()
 	product := models.Product{
 		ID:          db.GenerateID(),
+		Code:        strings.TrimSpace(req.Code),
 		Name:        req.Name,
 		Description: req.Description,
 		CategoryID:  req.CategoryID,
 		Price:       req.Price,
 		Quantity:    req.Quantity, // Set initial quantity
 		Tags:        req.Tags,
+		Options:     options,
+		Status:      models.ProductStatusActive,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
+	setAvailability(&product, req.Availability, req.AvailableAt)
+	if req.Status != "" {
+		product.Status = req.Status
+	}
 
 	// Save product to database with initial inventory
-	err := s.repository.CreateProduct(product, product.Quantity)
+	err = s.repository.CreateProduct(product, product.Quantity, req.Caller)
 	if err != nil {
 		return models.Product{}, err
 	}
@@ -75,35 +110,8 @@ func (s *InventoryService) CreateProduct(req models.CreateProductRequest) (model
 		}
 	}
 
-	// Cache the new product
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%s", product.ID)
-	err = s.cache.Set(ctx, cacheKey, product)
-	if err != nil {
-		log.Printf("Failed to cache new product: %v", err)
-	}
-
-	// Invalidate products cache to ensure new product is included in list
-	err = s.cache.Delete(ctx, "products:all")
-	if err != nil {
-		log.Printf("Failed to invalidate products cache: %v", err)
-	} else {
-		log.Printf("Successfully invalidated products cache after creating new product: %s", product.ID)
-	}
-
-	// Invalidate category cache
-	categoryKey := fmt.Sprintf("category:%s", product.CategoryID)
-	err = s.cache.Delete(ctx, categoryKey)
-	if err != nil {
-		log.Printf("Failed to invalidate category cache: %v", err)
-	}
-
-	// Invalidate inventory cache
-	inventoryKey := fmt.Sprintf("inventory:%s", product.ID)
-	err = s.cache.Delete(ctx, inventoryKey)
-	if err != nil {
-		log.Printf("Failed to invalidate inventory cache: %v", err)
-	}
+	// Invalidate cached lists to ensure new product is included
+	s.invalidate(product.ID)
 
 	// Publish inventory event if initial quantity > 0
 	if product.Quantity > 0 {
@@ -119,57 +127,48 @@ func (s *InventoryService) CreateProduct(req models.CreateProductRequest) (model
 
 // GetProductByID retrieves a product by ID
 func (s *InventoryService) GetProductByID(id string) (models.Product, error) {
-	// Try to get from cache first
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%s", id)
+	// Get from cache, or from database if not cached
 	var product models.Product
-
-	err := s.cache.Get(ctx, cacheKey, &product)
-	if err == nil {
-		log.Printf("Cache hit for product: %s", id)
-		return product, nil
-	}
-
-	// If not in cache, get from database
-	product, err = s.repository.GetProductByID(id)
+	err := s.cache.Load(context.Background(), productCache(id), "product:"+id, &product, func() (interface{}, error) {
+		// SKUs are only found through their product
+		product, err := s.repository.GetProductByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if product.ParentID != "" {
+			return nil, fmt.Errorf("product with ID %s not found", id)
+		}
+		products := []models.Product{product}
+		if err := s.withSKUs(products); err != nil {
+			return nil, err
+		}
+		return products[0], nil
+	})
 	if err != nil {
 		return models.Product{}, err
 	}
 
-	// Store in cache for future requests
-	err = s.cache.Set(ctx, cacheKey, product)
-	if err != nil {
-		log.Printf("Failed to cache product: %v", err)
-	}
-
 	return product, nil
 }
 
 // GetProducts retrieves all products
 func (s *InventoryService) GetProducts() ([]models.Product, error) {
-	// Try to get from cache first
-	ctx := context.Background()
-	cacheKey := "products:all"
+	// Get from cache, or from database if not cached
 	var products []models.Product
-
-	err := s.cache.Get(ctx, cacheKey, &products)
-	if err == nil {
-		log.Printf("Cache hit for all products")
+	err := s.cache.Load(context.Background(), catalogueCache, "products:all", &products, func() (interface{}, error) {
+		products, err := s.repository.GetProducts()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.withSKUs(products); err != nil {
+			return nil, err
+		}
 		return products, nil
-	}
-
-	// If not in cache, get from database
-	products, err = s.repository.GetProducts()
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Store in cache for future requests
-	err = s.cache.Set(ctx, cacheKey, products)
-	if err != nil {
-		log.Printf("Failed to cache products: %v", err)
-	}
-
 	return products, nil
 }
 
@@ -180,26 +179,53 @@ func (s *InventoryService) UpdateProduct(id string, req models.UpdateProductRequ
 	if err != nil {
 		return models.Product{}, err
 	}
+	if product.ParentID != "" {
+		return models.Product{}, fmt.Errorf("product with ID %s not found", id)
+	}
+	renamed := req.Name != "" && req.Name != product.Name
+	status := product.Status
+	if err := setStatus(&product, req.Status); err != nil {
+		return models.Product{}, err
+	}
 
 	// Update product fields
+	if code := strings.TrimSpace(req.Code); code != "" {
+		product.Code = code
+	}
 	if req.Name != "" {
 		product.Name = req.Name
 	}
 	if req.Description != "" {
 		product.Description = req.Description
 	}
-	if req.CategoryID != "" {
+	if req.CategoryID != "" && req.CategoryID != product.CategoryID {
+		if err := s.checkCategory(req.CategoryID); err != nil {
+			return models.Product{}, err
+		}
 		product.CategoryID = req.CategoryID
 	}
-	if req.Price != 0 {
-		product.Price = req.Price
-	}
 	if len(req.Tags) > 0 {
 		product.Tags = req.Tags
 	}
+	setAvailability(&product, req.Availability, req.AvailableAt)
+
+	// Options can't be changed from under the SKUs taking their values
+	if req.Options != nil {
+		options, err := checkOptions(req.Options)
+		if err != nil {
+			return models.Product{}, err
+		}
+		if err := s.checkSKUOptions(id, options); err != nil {
+			return models.Product{}, err
+		}
+		product.Options = options
+	}
 
 	// Update quantity if provided
 	if req.Quantity != nil {
+		if err := s.checkStockItem(id); err != nil {
+			return models.Product{}, err
+		}
 		product.Quantity = *req.Quantity
 
 		// Publish inventory updated event
@@ -212,79 +238,48 @@ func (s *InventoryService) UpdateProduct(id string, req models.UpdateProductRequ
 
 	product.UpdatedAt = time.Now()
 
-	// Save product and inventory to database in a single transaction
-	err = s.repository.UpdateProduct(id, product)
+	// Save product, price and inventory to database in a single transaction
+	change, err := s.repository.UpdateProduct(id, product, req.Price, req.Quantity, req.Caller)
 	if err != nil {
 		return models.Product{}, err
 	}
-
-	// Update cache
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%s", id)
-	err = s.cache.Set(ctx, cacheKey, product)
-	if err != nil {
-		log.Printf("Failed to update product in cache: %v", err)
+	if req.Quantity != nil {
+		// Received stock goes to the backorders of the product first
+		s.fillBackorders(id)
 	}
-
-	// Invalidate products cache
-	err = s.cache.Delete(ctx, "products:all")
-	if err != nil {
-		log.Printf("Failed to invalidate products cache: %v", err)
+	if saved, err := s.repository.GetProductByID(id); err == nil {
+		product.Price, product.WasPrice, product.SaleEndsAt = saved.Price, saved.WasPrice, saved.SaleEndsAt
+		product.Quantity = saved.Quantity
 	}
-
-	// Invalidate category cache
-	categoryKey := fmt.Sprintf("category:%s", product.CategoryID)
-	err = s.cache.Delete(ctx, categoryKey)
-	if err != nil {
-		log.Printf("Failed to invalidate category cache: %v", err)
+	if req.Quantity != nil {
+		s.checkStockAlert(product)
 	}
-
-	// Invalidate inventory cache
-	inventoryKey := fmt.Sprintf("inventory:%s", id)
-	err = s.cache.Delete(ctx, inventoryKey)
-	if err != nil {
-		log.Printf("Failed to invalidate inventory cache: %v", err)
+	if renamed {
+		if err := s.renameSKUs(product); err != nil {
+			// Log error but continue
+			log.Printf("Failed to rename SKUs of product %s: %v", id, err)
+		}
 	}
+	products := []models.Product{product}
+	if err := s.withSKUs(products); err != nil {
+		// Log error but continue
+		log.Printf("Failed to get SKUs of product %s: %v", id, err)
+	}
+	product = products[0]
+
+	// Invalidate the cached product and lists
+	s.invalidate(id)
+	s.statusChanged(product, status)
+	s.priceChanged(change)
 
 	return product, nil
 }
 
-// DeleteProduct deletes a product
+// DeleteProduct archives a product. It is kept, with its SKUs, stock and
+// history, for the orders referencing it, but taken off the storefront.
 func (s *InventoryService) DeleteProduct(id string) error {
-	// Get product to get category ID before deletion
-	product, err := s.repository.GetProductByID(id)
-	if err != nil {
-		return err
-	}
-
-	// Delete from database
-	err = s.repository.DeleteProduct(id)
-	if err != nil {
-		return err
-	}
-
-	// Delete from cache
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%s", id)
-	err = s.cache.Delete(ctx, cacheKey)
-	if err != nil {
-		log.Printf("Failed to delete product from cache: %v", err)
-	}
-
-	// Invalidate products cache
-	err = s.cache.Delete(ctx, "products:all")
-	if err != nil {
-		log.Printf("Failed to invalidate products cache: %v", err)
-	}
-
-	// Invalidate category cache
-	categoryKey := fmt.Sprintf("category:%s", product.CategoryID)
-	err = s.cache.Delete(ctx, categoryKey)
-	if err != nil {
-		log.Printf("Failed to invalidate category cache: %v", err)
-	}
-
-	return nil
+	_, err := s.UpdateProduct(id, models.UpdateProductRequest{Status: models.ProductStatusArchived})
+	return err
 }
 
 
@@ -294,6 +289,9 @@ func (s *InventoryService) CheckInventory(req models.InventoryCheckRequest) (mod
 	if len(req.Items) == 0 {
 		return models.InventoryCheckResponse{}, errors.New("no items to check")
 	}
+	if err := s.checkSKUs(req.Items); err != nil {
+		return models.InventoryCheckResponse{}, err
+	}
 
 	// Check inventory
 	available, unavailableItems, err := s.repository.CheckInventory(req.Items)
@@ -313,59 +311,68 @@ func (s *InventoryService) CheckInventory(req models.InventoryCheckRequest) (mod
 	return response, nil
 }
 
-// GetProductRecommendations retrieves product recommendations based on product ID
-func (s *InventoryService) GetProductRecommendations(productID string, limit int) ([]models.Product, error) {
+// GetProductRecommendations retrieves product recommendations based on product ID.
+// A SKU is recommended for like its product. Products learnt from orders to
+// go with the product come first, of one kind or, without a kind, of both;
+// products with too little order history are topped up with products of the
+// same category and tags.
+func (s *InventoryService) GetProductRecommendations(productID, kind string, limit int) ([]models.Product, error) {
 	// Set default limit if not provided
 	if limit <= 0 {
 		limit = 10
 	}
+	if sku, err := s.repository.GetProductByID(productID); err == nil && sku.ParentID != "" {
+		productID = sku.ParentID
+	}
 
-	// Try to get from cache first
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%s:recommendations:limit:%d", productID, limit)
+	// Get from cache, or compute if not cached. They are cached under the
+	// catalogue, which is invalidated whenever similarities are computed
+	// again.
 	var recommendations []models.Product
-
-	err := s.cache.Get(ctx, cacheKey, &recommendations)
-	if err == nil {
-		log.Printf("Cache hit for product recommendations: %s", productID)
-		return recommendations, nil
+	cacheKey := fmt.Sprintf("product:%s:recommendations:%s:limit:%d", productID, kind, limit)
+	err := s.cache.Load(context.Background(), catalogueCache, cacheKey, &recommendations, func() (interface{}, error) {
+		return s.productRecommendations(productID, kind, limit)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return recommendations, nil
+}
+
+// productRecommendations computes the recommendations for a product
+func (s *InventoryService) productRecommendations(productID, kind string, limit int) ([]models.Product, error) {
 	// Get product to check if it exists
 	product, err := s.repository.GetProductByID(productID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get similar products based on category
-	similarProducts, err := s.repository.GetProductsByCategory(product.CategoryID, limit)
+	learnt, err := s.learntRecommendations(productID, kind, limit)
 	if err != nil {
 		return nil, err
 	}
+	catalogue, err := s.GetListedProducts()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Product, len(catalogue))
+	for _, p := range catalogue {
+		byID[p.ID] = p
+	}
 
-	// Filter out the original product
-	recommendations = []models.Product{}
-	for _, p := range similarProducts {
-		if p.ID != productID {
+	recommendations := []models.Product{}
+	for _, id := range learnt {
+		if p, ok := byID[id]; ok {
 			recommendations = append(recommendations, p)
 		}
 	}
 
-	// If we don't have enough recommendations, get more products
+	// If we don't have enough recommendations, fall back on the category
+	// and tags of the product
 	if len(recommendations) < limit {
-		moreProducts, err := s.repository.GetProducts()
-		if err != nil {
-			// Store in cache for future requests
-			err = s.cache.Set(ctx, cacheKey, recommendations)
-			if err != nil {
-				log.Printf("Failed to cache product recommendations: %v", err)
-			}
-			return recommendations, nil // Return what we have so far
-		}
-
-		// Add more products until we reach the limit
-		for _, p := range moreProducts {
-			if p.ID != productID && !containsProduct(recommendations, p.ID) {
+		for _, p := range fallbackRecommendations(product, catalogue) {
+			if !containsProduct(recommendations, p.ID) {
 				recommendations = append(recommendations, p)
 				if len(recommendations) >= limit {
 					break
@@ -374,12 +381,6 @@ func (s *InventoryService) GetProductRecommendations(productID string, limit int
 		}
 	}
 
-	// Store in cache for future requests
-	err = s.cache.Set(ctx, cacheKey, recommendations)
-	if err != nil {
-		log.Printf("Failed to cache product recommendations: %v", err)
-	}
-
 	return recommendations, nil
 }
 
@@ -390,29 +391,28 @@ func (s *InventoryService) GetCategoryRecommendations(categoryID string, limit i
 		limit = 10
 	}
 
-	// Try to get from cache first
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("category:%s:limit:%d", categoryID, limit)
+	// Get from cache, or from database if not cached
 	var products []models.Product
-
-	err := s.cache.Get(ctx, cacheKey, &products)
-	if err == nil {
-		log.Printf("Cache hit for category recommendations: %s", categoryID)
+	cacheKey := fmt.Sprintf("category:%s:limit:%d", categoryID, limit)
+	err := s.cache.Load(context.Background(), catalogueCache, cacheKey, &products, func() (interface{}, error) {
+		// Products in subcategories are in the category too
+		categoryIDs, err := s.categorySubtree(categoryID)
+		if err != nil {
+			return nil, err
+		}
+		products, err := s.repository.GetProductsByCategory(categoryIDs, limit)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.withSKUs(products); err != nil {
+			return nil, err
+		}
 		return products, nil
-	}
-
-	// If not in cache, get from database
-	products, err = s.repository.GetProductsByCategory(categoryID, limit)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Store in cache for future requests
-	err = s.cache.Set(ctx, cacheKey, products)
-	if err != nil {
-		log.Printf("Failed to cache category recommendations: %v", err)
-	}
-
 	return products, nil
 }
 
@@ -423,27 +423,32 @@ func (s *InventoryService) GetSimilarProducts(req models.RecommendationRequest)
 		req.Limit = 10
 	}
 
-	// Try to get from cache first
-	ctx := context.Background()
+	// Get from cache, or compute if not cached
 	reqJSON, err := json.Marshal(req)
 	if err != nil {
 		log.Printf("Failed to marshal recommendation request: %v", err)
-	} else {
-		cacheKey := fmt.Sprintf("similar:%s", string(reqJSON))
-		var products []models.Product
-
-		err := s.cache.Get(ctx, cacheKey, &products)
-		if err == nil {
-			log.Printf("Cache hit for similar products")
-			return products, nil
-		}
+		return s.similarProducts(req)
 	}
+	var products []models.Product
+	cacheKey := fmt.Sprintf("similar:%s", string(reqJSON))
+	err = s.cache.Load(context.Background(), catalogueCache, cacheKey, &products, func() (interface{}, error) {
+		return s.similarProducts(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
 
+// similarProducts computes the products similar to a request
+func (s *InventoryService) similarProducts(req models.RecommendationRequest) ([]models.Product, error) {
 	var products []models.Product
+	var err error
 
 	// If product ID is provided, get recommendations based on product
 	if req.ProductID != "" {
-		products, err = s.GetProductRecommendations(req.ProductID, req.Limit)
+		products, err = s.GetProductRecommendations(req.ProductID, req.Kind, req.Limit)
 		if err != nil {
 			return nil, err
 		}
@@ -455,7 +460,7 @@ func (s *InventoryService) GetSimilarProducts(req models.RecommendationRequest)
 		}
 	} else {
 		// Otherwise, get products based on other criteria
-		products, err = s.repository.GetProducts()
+		products, err = s.GetListedProducts()
 		if err != nil {
 			return nil, err
 		}
@@ -489,18 +494,6 @@ func (s *InventoryService) GetSimilarProducts(req models.RecommendationRequest)
 		products = products[:req.Limit]
 	}
 
-	// Store in cache for future requests
-	reqJSON, err = json.Marshal(req)
-	if err != nil {
-		log.Printf("Failed to marshal recommendation request: %v", err)
-	} else {
-		cacheKey := fmt.Sprintf("similar:%s", string(reqJSON))
-		err = s.cache.Set(ctx, cacheKey, products)
-		if err != nil {
-			log.Printf("Failed to cache similar products: %v", err)
-		}
-	}
-
 	return products, nil
 }
 
@@ -525,3 +518,20 @@ func containsAnyTag(productTags []string, tags []string) bool {
 	}
 	return false
 }
+
+// setAvailability sets how a product sells when it is out of stock. Products
+// sold from stock only have no expected availability date.
+func setAvailability(product *models.Product, availability string, availableAt *time.Time) {
+	if availability != "" {
+		product.Availability = availability
+	}
+	if product.Availability == "" {
+		product.Availability = models.AvailabilityStock
+	}
+	if availableAt != nil {
+		product.AvailableAt = availableAt
+	}
+	if product.Availability == models.AvailabilityStock {
+		product.AvailableAt = nil
+	}
+}
@@ -0,0 +1,414 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// checkOptions trims the variant options of a product and makes sure their
+// names and values are set and listed once
+func checkOptions(options []models.VariantOption) ([]models.VariantOption, error) {
+	checked := make([]models.VariantOption, 0, len(options))
+	names := make(map[string]bool, len(options))
+	for _, option := range options {
+		name := strings.TrimSpace(option.Name)
+		if name == "" {
+			return nil, errors.New("invalid options: every option needs a name")
+		}
+		if names[strings.ToLower(name)] {
+			return nil, fmt.Errorf("invalid options: option %s is listed twice", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		values := make([]string, 0, len(option.Values))
+		seen := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return nil, fmt.Errorf("invalid options: option %s has an empty value", name)
+			}
+			if seen[strings.ToLower(value)] {
+				return nil, fmt.Errorf("invalid options: value %s of option %s is listed twice", value, name)
+			}
+			seen[strings.ToLower(value)] = true
+			values = append(values, value)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("invalid options: option %s has no values", name)
+		}
+
+		checked = append(checked, models.VariantOption{Name: name, Values: values})
+	}
+	return checked, nil
+}
+
+// skuOptions matches the option values of a SKU with the options of its
+// product. A SKU takes one value of every option, spelt as the product
+// spells it.
+func skuOptions(options []models.VariantOption, values map[string]string) (map[string]string, error) {
+	if len(options) == 0 {
+		return nil, errors.New("invalid options: the product has no variant options")
+	}
+
+	matched := make(map[string]string, len(options))
+	for name, value := range values {
+		var option *models.VariantOption
+		for i := range options {
+			if strings.EqualFold(options[i].Name, strings.TrimSpace(name)) {
+				option = &options[i]
+			}
+		}
+		if option == nil {
+			return nil, fmt.Errorf("invalid options: the product has no option %s", name)
+		}
+		for _, optionValue := range option.Values {
+			if strings.EqualFold(optionValue, strings.TrimSpace(value)) {
+				matched[option.Name] = optionValue
+			}
+		}
+		if _, ok := matched[option.Name]; !ok {
+			return nil, fmt.Errorf("invalid options: %s is not a value of option %s", value, option.Name)
+		}
+	}
+	for _, option := range options {
+		if _, ok := matched[option.Name]; !ok {
+			return nil, fmt.Errorf("invalid options: a value of option %s is required", option.Name)
+		}
+	}
+	return matched, nil
+}
+
+// sameOptions reports whether two SKUs take the same option values
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if b[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// skuName names a SKU after its product and option values, such as
+// "T-shirt (M, Red)"
+func skuName(product models.Product, options map[string]string) string {
+	var values []string
+	for _, option := range product.Options {
+		if value, ok := options[option.Name]; ok {
+			values = append(values, value)
+		}
+	}
+	return fmt.Sprintf("%s (%s)", product.Name, strings.Join(values, ", "))
+}
+
+// priceSKU sets the price of a SKU from its price override, read as the
+// stored price, or else the price and sale of its product, and whether it can
+// be ordered
+func priceSKU(product models.Product, sku *models.SKU) {
+	if sku.Price > 0 {
+		override := sku.Price
+		sku.PriceOverride = &override
+	} else {
+		sku.Price = product.Price
+		sku.WasPrice = product.WasPrice
+	}
+	sku.Available = sku.Quantity > 0 || product.Availability != models.AvailabilityStock
+}
+
+// withSKUs adds the variant matrix to products sold in variants. Their
+// quantity is the stock of all of their SKUs.
+func (s *InventoryService) withSKUs(products []models.Product) error {
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	skus, err := s.repository.GetSKUs(ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		product := &products[i]
+		if len(skus[product.ID]) == 0 {
+			continue
+		}
+		product.SKUs = skus[product.ID]
+		product.Quantity = 0
+		for j := range product.SKUs {
+			priceSKU(*product, &product.SKUs[j])
+			product.Quantity += product.SKUs[j].Quantity
+		}
+	}
+	return nil
+}
+
+// catalogue loads every product with its SKUs, for the search index
+func (s *InventoryService) catalogue() ([]models.Product, error) {
+	products, err := s.repository.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+	return products, s.withSKUs(products)
+}
+
+// getSKU retrieves a product sold in variants and one of its SKUs, with the
+// stored price override as its price
+func (s *InventoryService) getSKU(productID, skuID string) (models.Product, models.SKU, error) {
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.Product{}, models.SKU{}, err
+	}
+	if product.ParentID != "" {
+		return models.Product{}, models.SKU{}, fmt.Errorf("product with ID %s not found", productID)
+	}
+
+	skus, err := s.repository.GetSKUs([]string{productID})
+	if err != nil {
+		return models.Product{}, models.SKU{}, err
+	}
+	for _, sku := range skus[productID] {
+		if sku.ID == skuID {
+			return product, sku, nil
+		}
+	}
+	return models.Product{}, models.SKU{}, fmt.Errorf("SKU with ID %s not found", skuID)
+}
+
+// CreateSKU adds a SKU to a product with options. The SKU takes one value
+// of each option, and no other SKU of the product may take the same values.
+func (s *InventoryService) CreateSKU(productID string, req models.CreateSKURequest) (models.SKU, error) {
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.SKU{}, err
+	}
+	if product.ParentID != "" {
+		return models.SKU{}, fmt.Errorf("product with ID %s not found", productID)
+	}
+
+	options, err := skuOptions(product.Options, req.Options)
+	if err != nil {
+		return models.SKU{}, err
+	}
+	if err := s.checkNewSKU(product, options); err != nil {
+		return models.SKU{}, err
+	}
+
+	now := time.Now()
+	sku := models.SKU{
+		ID:        db.GenerateID(),
+		ProductID: productID,
+		Code:      strings.TrimSpace(req.Code),
+		Barcode:   strings.TrimSpace(req.Barcode),
+		Name:      skuName(product, options),
+		Options:   options,
+		Quantity:  req.Quantity,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.PriceOverride != nil {
+		sku.Price = *req.PriceOverride
+	}
+
+	err = s.repository.CreateSKU(sku, sku.Price, req.Quantity, req.Caller)
+	if err != nil {
+		return models.SKU{}, err
+	}
+	priceSKU(product, &sku)
+
+	if sku.Quantity > 0 {
+		s.stockChanged(sku.ID)
+	} else {
+		s.skusChanged(product)
+	}
+
+	return sku, nil
+}
+
+// checkNewSKU makes sure a SKU with option values can be added to a product:
+// no other SKU of the product takes the same values
+func (s *InventoryService) checkNewSKU(product models.Product, options map[string]string) error {
+	existing, err := s.repository.GetSKUs([]string{product.ID})
+	if err != nil {
+		return err
+	}
+	for _, sku := range existing[product.ID] {
+		if sameOptions(sku.Options, options) {
+			return fmt.Errorf("invalid options: SKU %s has the same options", sku.Code)
+		}
+	}
+	// Once a product has SKUs it is ordered by SKU, so stock of its own
+	// could never be sold
+	if len(existing[product.ID]) == 0 && product.Quantity > 0 {
+		return fmt.Errorf("invalid product: product %s has stock of its own, set it to 0 before adding SKUs", product.ID)
+	}
+	return nil
+}
+
+// GetSKUs retrieves the variant matrix of a product: its SKUs with their
+// option values, price and availability
+func (s *InventoryService) GetSKUs(productID string) ([]models.SKU, error) {
+	product, err := s.GetProductByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.SKUs == nil {
+		return []models.SKU{}, nil
+	}
+	return product.SKUs, nil
+}
+
+// UpdateSKU updates the code, barcode and price override of a SKU
+func (s *InventoryService) UpdateSKU(productID, skuID string, req models.UpdateSKURequest) (models.SKU, error) {
+	product, sku, err := s.getSKU(productID, skuID)
+	if err != nil {
+		return models.SKU{}, err
+	}
+
+	if code := strings.TrimSpace(req.Code); code != "" {
+		sku.Code = code
+	}
+	if req.Barcode != nil {
+		sku.Barcode = strings.TrimSpace(*req.Barcode)
+	}
+	if req.PriceOverride != nil {
+		sku.Price = *req.PriceOverride
+	}
+	sku.UpdatedAt = time.Now()
+
+	err = s.repository.UpdateSKU(sku, sku.Price)
+	if err != nil {
+		return models.SKU{}, err
+	}
+	priceSKU(product, &sku)
+	s.skusChanged(product)
+
+	return sku, nil
+}
+
+// DeleteSKU deletes a SKU of a product with its stock
+func (s *InventoryService) DeleteSKU(productID, skuID string) error {
+	product, sku, err := s.getSKU(productID, skuID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DeleteProduct(sku.ID)
+	if err != nil {
+		return err
+	}
+	s.skusChanged(product)
+
+	log.Printf("Deleted SKU %s of product %s", sku.Code, productID)
+	return nil
+}
+
+// renameSKUs names the SKUs of a product after its new name
+func (s *InventoryService) renameSKUs(product models.Product) error {
+	skus, err := s.repository.GetSKUs([]string{product.ID})
+	if err != nil {
+		return err
+	}
+	for _, sku := range skus[product.ID] {
+		sku.Name = skuName(product, sku.Options)
+		sku.UpdatedAt = product.UpdatedAt
+		if err := s.repository.UpdateSKU(sku, sku.Price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSKUOptions makes sure the SKUs of a product still take values of its
+// options once they are changed
+func (s *InventoryService) checkSKUOptions(productID string, options []models.VariantOption) error {
+	skus, err := s.repository.GetSKUs([]string{productID})
+	if err != nil {
+		return err
+	}
+	for _, sku := range skus[productID] {
+		if _, err := skuOptions(options, sku.Options); err != nil {
+			return fmt.Errorf("%w, as SKU %s does", err, sku.Code)
+		}
+	}
+	return nil
+}
+
+// skusChanged drops the cached copies of a product whose SKUs changed
+func (s *InventoryService) skusChanged(product models.Product) {
+	s.invalidate(product.ID)
+}
+
+// checkSKUs makes sure order lines are of products on sale, and that lines of
+// products sold in variants name one of their SKUs and lines of other
+// products name none
+func (s *InventoryService) checkSKUs(items []struct {
+	ProductID string `json:"product_id"`
+	SKUID     string `json:"sku_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}) error {
+	for _, item := range items {
+		if item.SKUID != "" {
+			sku, err := s.repository.GetProductByID(item.SKUID)
+			if err != nil || sku.ParentID != item.ProductID {
+				return fmt.Errorf("SKU with ID %s of product %s not found", item.SKUID, item.ProductID)
+			}
+			if err := checkOrderable(item.ProductID, sku.Status); err != nil {
+				return err
+			}
+			continue
+		}
+
+		product, err := s.repository.GetProductByID(item.ProductID)
+		if err != nil {
+			return err
+		}
+		if product.ParentID != "" {
+			return fmt.Errorf("product with ID %s not found", item.ProductID)
+		}
+		if err := checkOrderable(item.ProductID, product.Status); err != nil {
+			return err
+		}
+		if err := s.checkStockItem(item.ProductID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStockItem makes sure stock can be kept under an ID: it is a SKU, or a
+// product that isn't sold in variants
+func (s *InventoryService) checkStockItem(id string) error {
+	product, err := s.repository.GetProductByID(id)
+	if err != nil {
+		return err
+	}
+	if product.ParentID != "" {
+		return nil
+	}
+
+	variants, err := s.repository.HasSKUs(id)
+	if err != nil {
+		return err
+	}
+	if variants {
+		return fmt.Errorf("invalid item: product %s is sold in variants, pick one of its SKUs", id)
+	}
+	return nil
+}
+
+// splitStockID returns the product and SKU of a stock ID, given the product
+// of each SKU
+func splitStockID(stockID string, parents map[string]string) (string, string) {
+	if productID, ok := parents[stockID]; ok {
+		return productID, stockID
+	}
+	return stockID, ""
+}
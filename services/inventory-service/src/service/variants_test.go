@@ -0,0 +1,105 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/online-order-system/inventory-service/models"
+)
+
+func TestCheckOptions(t *testing.T) {
+	got, err := checkOptions([]models.VariantOption{
+		{Name: " Size ", Values: []string{"S", " M "}},
+		{Name: "Color", Values: []string{"Red"}},
+	})
+	want := []models.VariantOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Red"}},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("checkOptions = %+v, %v, want %+v", got, err, want)
+	}
+
+	invalid := map[string][]models.VariantOption{
+		"without a name":    {{Name: " ", Values: []string{"S"}}},
+		"listed twice":      {{Name: "Size", Values: []string{"S"}}, {Name: "size", Values: []string{"M"}}},
+		"an empty value":    {{Name: "Size", Values: []string{"S", " "}}},
+		"a value twice":     {{Name: "Size", Values: []string{"S", "s"}}},
+		"without any value": {{Name: "Size"}},
+	}
+	for name, options := range invalid {
+		if _, err := checkOptions(options); err == nil || !strings.HasPrefix(err.Error(), "invalid options") {
+			t.Errorf("option %s: got %v, want invalid options", name, err)
+		}
+	}
+}
+
+func TestSKUOptions(t *testing.T) {
+	options := []models.VariantOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Red", "Blue"}},
+	}
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"spelt as the product spells it", map[string]string{" size": "m ", "COLOR": "red"}, map[string]string{"Size": "M", "Color": "Red"}, false},
+		{"missing option", map[string]string{"Size": "M"}, nil, true},
+		{"unknown option", map[string]string{"Size": "M", "Color": "Red", "Fabric": "Cotton"}, nil, true},
+		{"unknown value", map[string]string{"Size": "XL", "Color": "Red"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := skuOptions(options, tt.values)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: skuOptions = %v, %v, want %v (error %t)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := skuOptions(nil, map[string]string{"Size": "M"}); err == nil {
+		t.Error("product without options: got no error")
+	}
+}
+
+func TestSameOptions(t *testing.T) {
+	a := map[string]string{"Size": "M", "Color": "Red"}
+	if !sameOptions(a, map[string]string{"Color": "Red", "Size": "M"}) {
+		t.Error("same values in another order differ")
+	}
+	if sameOptions(a, map[string]string{"Size": "M", "Color": "Blue"}) || sameOptions(a, map[string]string{"Size": "M"}) {
+		t.Error("different values are the same")
+	}
+}
+
+func TestSKUNameAndPrice(t *testing.T) {
+	product := models.Product{
+		Name:         "T-shirt",
+		Price:        10,
+		Availability: models.AvailabilityStock,
+		Options:      []models.VariantOption{{Name: "Size"}, {Name: "Color"}},
+	}
+	if got := skuName(product, map[string]string{"Color": "Red", "Size": "M"}); got != "T-shirt (M, Red)" {
+		t.Errorf("skuName = %q, want T-shirt (M, Red)", got)
+	}
+
+	inherited := models.SKU{Quantity: 0}
+	priceSKU(product, &inherited)
+	if inherited.Price != 10 || inherited.PriceOverride != nil || inherited.Available {
+		t.Errorf("SKU without a price override: %+v", inherited)
+	}
+
+	overridden := models.SKU{Price: 12, Quantity: 1}
+	priceSKU(product, &overridden)
+	if overridden.Price != 12 || overridden.PriceOverride == nil || *overridden.PriceOverride != 12 || !overridden.Available {
+		t.Errorf("SKU with a price override: %+v", overridden)
+	}
+
+	product.Availability = models.AvailabilityBackorder
+	backordered := models.SKU{}
+	priceSKU(product, &backordered)
+	if !backordered.Available {
+		t.Error("SKU of a backorder product out of stock can't be ordered")
+	}
+}
@@ -0,0 +1,495 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/inventory-service/db"
+	"github.com/online-order-system/inventory-service/models"
+)
+
+// CreateWarehouse creates a new warehouse
+func (s *InventoryService) CreateWarehouse(req models.CreateWarehouseRequest) (models.Warehouse, error) {
+	now := time.Now()
+	warehouse := models.Warehouse{
+		ID:        db.GenerateID(),
+		Code:      strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:      strings.TrimSpace(req.Name),
+		Country:   strings.ToUpper(req.Country),
+		Province:  strings.TrimSpace(req.Province),
+		City:      strings.TrimSpace(req.City),
+		IsDefault: req.IsDefault,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := s.repository.CreateWarehouse(warehouse)
+	if err != nil {
+		return models.Warehouse{}, err
+	}
+
+	return warehouse, nil
+}
+
+// GetWarehouses retrieves all warehouses
+func (s *InventoryService) GetWarehouses() ([]models.Warehouse, error) {
+	return s.repository.GetWarehouses()
+}
+
+// GetWarehouseByID retrieves a warehouse by ID
+func (s *InventoryService) GetWarehouseByID(id string) (models.Warehouse, error) {
+	return s.repository.GetWarehouseByID(id)
+}
+
+// UpdateWarehouse updates a warehouse. The default warehouse stays the
+// default until another one is made the default.
+func (s *InventoryService) UpdateWarehouse(id string, req models.UpdateWarehouseRequest) (models.Warehouse, error) {
+	warehouse, err := s.repository.GetWarehouseByID(id)
+	if err != nil {
+		return models.Warehouse{}, err
+	}
+
+	if req.Name != "" {
+		warehouse.Name = strings.TrimSpace(req.Name)
+	}
+	if req.Country != "" {
+		warehouse.Country = strings.ToUpper(req.Country)
+	}
+	if req.Province != "" {
+		warehouse.Province = strings.TrimSpace(req.Province)
+	}
+	if req.City != "" {
+		warehouse.City = strings.TrimSpace(req.City)
+	}
+	warehouse.IsDefault = warehouse.IsDefault || req.IsDefault
+	warehouse.UpdatedAt = time.Now()
+
+	err = s.repository.UpdateWarehouse(warehouse)
+	if err != nil {
+		return models.Warehouse{}, err
+	}
+
+	return warehouse, nil
+}
+
+// GetWarehouseStock retrieves the stock of every product in a warehouse
+func (s *InventoryService) GetWarehouseStock(warehouseID string) ([]models.StockLevel, error) {
+	if _, err := s.repository.GetWarehouseByID(warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repository.GetStockByWarehouse(warehouseID)
+}
+
+// GetProductStock retrieves the stock of a product in every warehouse
+func (s *InventoryService) GetProductStock(productID string) (models.ProductStock, error) {
+	if _, err := s.repository.GetProductByID(productID); err != nil {
+		return models.ProductStock{}, err
+	}
+
+	levels, err := s.repository.GetStockByProduct(productID)
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+
+	thresholds, _, err := s.repository.GetStockThresholds(productID)
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+
+	stock := models.ProductStock{ProductID: productID, Locations: levels}
+	stock.LowStockThreshold, stock.ReorderPoint = s.thresholds(thresholds)
+	for _, level := range levels {
+		stock.Total += level.Quantity
+	}
+	return stock, nil
+}
+
+// SetStock sets the stock of a product or SKU in a warehouse
+func (s *InventoryService) SetStock(warehouseID, productID string, req models.SetStockRequest) (models.ProductStock, error) {
+	if _, err := s.repository.GetWarehouseByID(warehouseID); err != nil {
+		return models.ProductStock{}, err
+	}
+	if err := s.checkStockItem(productID); err != nil {
+		return models.ProductStock{}, err
+	}
+
+	err := s.repository.SetStock(productID, warehouseID, *req.Quantity, req.Caller, time.Now())
+	if err != nil {
+		return models.ProductStock{}, err
+	}
+	s.stockChanged(productID)
+
+	return s.GetProductStock(productID)
+}
+
+// TransferStock moves stock of a product or SKU from one warehouse to another
+func (s *InventoryService) TransferStock(req models.TransferStockRequest) (models.StockTransfer, error) {
+	if req.FromWarehouseID == req.ToWarehouseID {
+		return models.StockTransfer{}, errors.New("cannot transfer stock to the warehouse it is in")
+	}
+	for _, id := range []string{req.FromWarehouseID, req.ToWarehouseID} {
+		if _, err := s.repository.GetWarehouseByID(id); err != nil {
+			return models.StockTransfer{}, err
+		}
+	}
+	if err := s.checkStockItem(req.ProductID); err != nil {
+		return models.StockTransfer{}, err
+	}
+
+	transfer := models.StockTransfer{
+		ID:              db.GenerateID(),
+		ProductID:       req.ProductID,
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Quantity:        req.Quantity,
+		CreatedAt:       time.Now(),
+	}
+
+	err := s.repository.TransferStock(transfer, req.Caller)
+	if err != nil {
+		return models.StockTransfer{}, err
+	}
+
+	// The total doesn't change, so cached products stay valid
+	return transfer, nil
+}
+
+// GetTransfers retrieves the transfers of a product, or of every product when
+// productID is empty
+func (s *InventoryService) GetTransfers(productID string) ([]models.StockTransfer, error) {
+	return s.repository.GetTransfers(productID)
+}
+
+// ReserveStock takes the stock of an order from the warehouses the
+// fulfilment strategy picks. Reserving an order again returns the stock
+// already reserved for it.
+func (s *InventoryService) ReserveStock(req models.ReserveStockRequest) (models.Reservation, error) {
+	if len(req.Items) == 0 {
+		return models.Reservation{}, errors.New("no items to reserve")
+	}
+
+	existing, reserved, err := s.existingReservation(req.OrderID)
+	if err != nil || reserved {
+		return existing, err
+	}
+
+	if err := s.checkSKUs(req.Items); err != nil {
+		return models.Reservation{}, err
+	}
+
+	// Check the stock across all warehouses first. Backorder and pre-order
+	// products are available whatever their stock.
+	available, unavailableItems, err := s.repository.CheckInventory(req.Items)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	sellable := make(map[string]models.Product)
+	if !available {
+		stockOnly := unavailableItems[:0]
+		for _, item := range unavailableItems {
+			stockID := models.StockID(item.ProductID, item.SKUID)
+			product, err := s.repository.GetProductByID(stockID)
+			if err != nil || product.Availability == models.AvailabilityStock {
+				stockOnly = append(stockOnly, item)
+				continue
+			}
+			sellable[stockID] = product
+		}
+		if len(stockOnly) > 0 {
+			return models.Reservation{
+				OrderID:          req.OrderID,
+				UnavailableItems: stockOnly,
+			}, nil
+		}
+	}
+
+	// Stock is allocated by the ID it is kept under, which is the SKU for
+	// products sold in variants. parents maps SKUs back to their product.
+	var products []string
+	needs := make(map[string]int)
+	parents := make(map[string]string)
+	for _, item := range req.Items {
+		stockID := models.StockID(item.ProductID, item.SKUID)
+		if _, ok := needs[stockID]; !ok {
+			products = append(products, stockID)
+		}
+		needs[stockID] += item.Quantity
+		if item.SKUID != "" {
+			parents[item.SKUID] = item.ProductID
+		}
+	}
+
+	warehouses, err := s.repository.GetWarehouses()
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	stock, err := s.repository.GetStockForProducts(products)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	// Reserve what is in stock of backorder and pre-order products, the rest
+	// of their lines waits for stock
+	now := time.Now()
+	var backorders []models.Backorder
+	var waitingItems []models.WaitingItem
+	for stockID, product := range sellable {
+		inStock := 0
+		for _, quantity := range stock[stockID] {
+			inStock += quantity
+		}
+		if inStock >= needs[stockID] {
+			continue
+		}
+		short := needs[stockID] - inStock
+		needs[stockID] = inStock
+		productID, skuID := splitStockID(stockID, parents)
+		backorders = append(backorders, models.Backorder{
+			ID:              db.GenerateID(),
+			OrderID:         req.OrderID,
+			ProductID:       productID,
+			SKUID:           skuID,
+			Quantity:        short,
+			Availability:    product.Availability,
+			ShippingAddress: req.ShippingAddress,
+			CreatedAt:       now,
+		})
+		waitingItems = append(waitingItems, models.WaitingItem{
+			ProductID:    productID,
+			SKUID:        skuID,
+			Quantity:     short,
+			Availability: product.Availability,
+			ExpectedAt:   product.AvailableAt,
+		})
+	}
+
+	allocations, ok := allocate(s.strategy, warehouses, stock, products, needs, req.ShippingAddress)
+	if !ok {
+		return models.Reservation{}, fmt.Errorf("failed to allocate stock for order %s: %w", req.OrderID, db.ErrInsufficientStock)
+	}
+	for i, allocation := range allocations {
+		allocations[i].ProductID, allocations[i].SKUID = splitStockID(allocation.ProductID, parents)
+	}
+
+	err = s.repository.ReserveStock(req.OrderID, allocations, backorders, now)
+	if errors.Is(err, db.ErrAlreadyReserved) {
+		// A concurrent request reserved the order first
+		existing, _, err := s.existingReservation(req.OrderID)
+		return existing, err
+	}
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	for _, stockID := range products {
+		if needs[stockID] > 0 {
+			s.stockChanged(stockID)
+		}
+	}
+
+	reservation := models.Reservation{
+		OrderID:     req.OrderID,
+		Available:   true,
+		Strategy:    s.strategy,
+		WarehouseID: fulfilmentWarehouse(allocations),
+		Allocations: allocations,
+		Waiting:     waitingItems,
+	}
+	log.Printf("Reserved stock for order %s from warehouse %s (%d allocations, %d backorders, strategy %s)",
+		req.OrderID, reservation.WarehouseID, len(allocations), len(backorders), s.strategy)
+
+	return reservation, nil
+}
+
+// existingReservation returns the stock already reserved for an order, and
+// whether there is any. Requests for an order are answered with the same
+// reservation, but an order whose stock was put back doesn't get it again,
+// such as when its order_created event arrives after it failed.
+func (s *InventoryService) existingReservation(orderID string) (models.Reservation, bool, error) {
+	reserved, err := s.repository.GetReservations(orderID)
+	if err != nil {
+		return models.Reservation{}, false, err
+	}
+	waiting, err := s.repository.GetWaitingItems(orderID)
+	if err != nil {
+		return models.Reservation{}, false, err
+	}
+	if len(reserved) > 0 || len(waiting) > 0 {
+		return models.Reservation{
+			OrderID:     orderID,
+			Available:   true,
+			WarehouseID: fulfilmentWarehouse(reserved),
+			Allocations: reserved,
+			Waiting:     waiting,
+		}, true, nil
+	}
+
+	released, err := s.repository.HasReservations(orderID)
+	if err != nil {
+		return models.Reservation{}, false, err
+	}
+	if released {
+		return models.Reservation{}, true, fmt.Errorf("stock reserved for order %s was already released", orderID)
+	}
+	return models.Reservation{}, false, nil
+}
+
+// RestoreStock puts stock back. Stock reserved for an order goes back to the
+// warehouses it was taken from, any other stock to the default warehouse.
+func (s *InventoryService) RestoreStock(req models.RestoreStockRequest) error {
+	now := time.Now()
+	stockID := models.StockID(req.ProductID, req.SKUID)
+	if req.OrderID != "" {
+		// Units still waiting for stock have nothing to put back
+		cancelled, err := s.repository.CancelBackorders(req.OrderID, stockID, now)
+		if err != nil {
+			return err
+		}
+		if cancelled > 0 {
+			log.Printf("Cancelled backorder of %d units of product %s for order %s", cancelled, stockID, req.OrderID)
+		}
+
+		released, err := s.repository.ReleaseReservations(req.OrderID, stockID, now)
+		if err != nil {
+			return err
+		}
+		if released > 0 {
+			s.stockChanged(stockID)
+		}
+		return nil
+	}
+
+	if err := s.checkStockItem(stockID); err != nil {
+		return err
+	}
+	err := s.repository.AddStock(stockID, req.Quantity, req.Caller, now)
+	if err != nil {
+		return err
+	}
+	s.stockChanged(stockID)
+	return nil
+}
+
+// stockChanged drops the cached copies of a product or SKU whose total stock
+// changed and publishes the new total. Stock that came in goes to the
+// backorders of the product first. The stock of a SKU is part of its product.
+func (s *InventoryService) stockChanged(productID string) {
+	s.fillBackorders(productID)
+
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		log.Printf("Failed to get product %s: %v", productID, err)
+	}
+
+	s.invalidate(productID, product.ParentID)
+
+	if err != nil {
+		return
+	}
+	err = s.producer.PublishInventoryUpdated(productID, product.Quantity)
+	if err != nil {
+		// Log error but continue
+		log.Printf("Failed to publish inventory updated event: %v", err)
+	}
+
+	s.checkStockAlert(product)
+}
+
+// CommitStock marks the stock reserved for an order as sold, once the order
+// is confirmed
+func (s *InventoryService) CommitStock(orderID string) error {
+	committed, err := s.repository.CommitReservations(orderID, time.Now())
+	if err != nil {
+		return err
+	}
+	if committed > 0 {
+		log.Printf("Committed %d reservations of order %s", committed, orderID)
+	}
+	return nil
+}
+
+// GetMovements retrieves the stock movements of a product
+func (s *InventoryService) GetMovements(filter models.MovementFilter) ([]models.StockMovement, error) {
+	if filter.ProductID == "" {
+		return nil, errors.New("product ID is required")
+	}
+	return s.repository.GetMovements(filter)
+}
+
+// ReconcileStock compares the stored stock of a product with its ledger
+func (s *InventoryService) ReconcileStock(productID string) (models.StockReconciliation, error) {
+	// Read the database, a cached product may be stale
+	product, err := s.repository.GetProductByID(productID)
+	if err != nil {
+		return models.StockReconciliation{}, err
+	}
+
+	levels, err := s.repository.GetStockByProduct(productID)
+	if err != nil {
+		return models.StockReconciliation{}, err
+	}
+	balances, err := s.repository.GetLedgerBalances(productID)
+	if err != nil {
+		return models.StockReconciliation{}, err
+	}
+
+	reconciliation := models.StockReconciliation{
+		ProductID: productID,
+		Stored:    product.Quantity,
+		Balanced:  true,
+		Locations: []models.LocationReconciliation{},
+	}
+	compare := func(warehouseID string, stored int) {
+		location := models.LocationReconciliation{
+			WarehouseID: warehouseID,
+			Stored:      stored,
+			Ledger:      balances[warehouseID],
+			Difference:  stored - balances[warehouseID],
+		}
+		if location.Difference != 0 {
+			reconciliation.Balanced = false
+		}
+		reconciliation.Ledger += location.Ledger
+		reconciliation.Locations = append(reconciliation.Locations, location)
+		delete(balances, warehouseID)
+	}
+	for _, level := range levels {
+		compare(level.WarehouseID, level.Quantity)
+	}
+	// Movements in warehouses without stock levels
+	for warehouseID := range balances {
+		compare(warehouseID, 0)
+	}
+	if reconciliation.Stored != reconciliation.Ledger {
+		reconciliation.Balanced = false
+	}
+
+	return reconciliation, nil
+}
+
+// ReconcileAllStock compares the stored stock of every product and SKU with
+// the ledger
+func (s *InventoryService) ReconcileAllStock() ([]models.StockReconciliation, error) {
+	products, err := s.catalogue()
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliations := []models.StockReconciliation{}
+	for _, product := range products {
+		stockIDs := []string{product.ID}
+		for _, sku := range product.SKUs {
+			stockIDs = append(stockIDs, sku.ID)
+		}
+		for _, stockID := range stockIDs {
+			reconciliation, err := s.ReconcileStock(stockID)
+			if err != nil {
+				return nil, err
+			}
+			reconciliations = append(reconciliations, reconciliation)
+		}
+	}
+	return reconciliations, nil
+}
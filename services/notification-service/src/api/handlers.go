@@ -4,10 +4,14 @@ import (
 "net/http"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/notification-service/auth"
 "github.com/online-order-system/notification-service/interfaces"
 "github.com/online-order-system/notification-service/models"
 )
 
+// staffRoles may see the notifications of every customer
+var staffRoles = []string{auth.RoleSupport, auth.RoleAdmin}
+
 // Handler handles HTTP requests
 type Handler struct {
 service interfaces.NotificationService
@@ -42,7 +46,7 @@ func (h *Handler) GetNotificationByID(c *gin.Context) {
 id := c.Param("id")
 
 notification, err := h.service.GetNotificationByID(id)
-if err != nil {
+if err != nil || !auth.CanAccess(c, notification.CustomerID, staffRoles...) {
 c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
 return
 }
@@ -53,6 +57,10 @@ c.JSON(http.StatusOK, notification)
 // GetNotificationsByUserID handles retrieving notifications by user ID
 func (h *Handler) GetNotificationsByUserID(c *gin.Context) {
 userID := c.Param("user_id")
+if !auth.CanAccess(c, userID, staffRoles...) {
+c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access notifications of another user"})
+return
+}
 
 notifications, err := h.service.GetNotificationsByCustomerID(userID)
 if err != nil {
@@ -84,7 +92,13 @@ c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 return
 }
 
-notification, err := h.service.UpdateNotificationStatus(id, req.Status)
+notification, err := h.service.GetNotificationByID(id)
+if err != nil || !auth.CanAccess(c, notification.CustomerID, staffRoles...) {
+c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+return
+}
+
+notification, err = h.service.UpdateNotificationStatus(id, req.Status)
 if err != nil {
 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification status"})
 return
@@ -92,3 +106,15 @@ return
 
 c.JSON(http.StatusOK, notification)
 }
+
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export
+func (h *Handler) GetCustomerData(c *gin.Context) {
+data, err := h.service.GetCustomerData(c.Param("id"))
+if err != nil {
+c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+return
+}
+
+c.JSON(http.StatusOK, data)
+}
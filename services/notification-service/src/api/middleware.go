@@ -5,6 +5,7 @@ import (
 "time"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/notification-service/auth"
 )
 
 // Logger is a middleware function that logs the request
@@ -19,15 +20,17 @@ c.Next()
 // Calculate latency
 latency := time.Since(start)
 
-// Log request
+// Log request with the authenticated caller, so calls between services can
+// be traced to the service that made them
 log.Printf(
-"[%s] %s %s %s %d %s",
+"[%s] %s %s %s %d %s %s",
 c.Request.Method,
 c.Request.URL.Path,
 c.Request.Proto,
 latency,
 c.Writer.Status(),
 c.ClientIP(),
+auth.Caller(c),
 )
 }
 }
@@ -2,17 +2,19 @@ package api
 
 import (
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/notification-service/auth"
 "github.com/online-order-system/notification-service/interfaces"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.NotificationService) *gin.Engine {
+func SetupRouter(service interfaces.NotificationService, verifier *auth.Verifier) *gin.Engine {
 // Create router
 router := gin.Default()
 
 // Add middleware
 router.Use(Logger())
 router.Use(CORS())
+router.Use(auth.Authenticate(verifier))
 
 // Create handler
 handler := NewHandler(service)
@@ -28,24 +30,32 @@ c.JSON(200, gin.H{
 router.StaticFile("/swagger", "./docs/swagger.html")
 router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+// Who may call the routes. Routes open to everyone check in the handler
+// that the caller owns the notification.
+everyone := auth.RequireRole(auth.Everyone...)
+staff := auth.RequireRole(auth.Internal, auth.RoleSupport, auth.RoleAdmin)
+
 // Notification routes
 notifications := router.Group("/notifications")
 {
 // Create a new notification
-notifications.POST("", handler.CreateNotification)
+notifications.POST("", staff, handler.CreateNotification)
 
 // Get all notifications
-notifications.GET("", handler.GetNotifications)
+notifications.GET("", staff, handler.GetNotifications)
 
 // Get a specific notification by ID
-notifications.GET("/:id", handler.GetNotificationByID)
+notifications.GET("/:id", everyone, handler.GetNotificationByID)
 
 // Get notifications by user ID
-notifications.GET("/user/:user_id", handler.GetNotificationsByUserID)
+notifications.GET("/user/:user_id", everyone, handler.GetNotificationsByUserID)
 
 // Update a notification's status
-notifications.PUT("/:id/status", handler.UpdateNotificationStatus)
+notifications.PUT("/:id/status", everyone, handler.UpdateNotificationStatus)
 }
 
+// Personal data of a customer, gathered by user-service for data exports
+router.GET("/customers/:id/data", auth.RequireRole(auth.Internal), handler.GetCustomerData)
+
 return router
 }
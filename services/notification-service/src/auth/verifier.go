@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when a token fails verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// RevocationList reports whether the session a token belongs to was revoked
+type RevocationList interface {
+	IsRevoked(sessionID string) (bool, error)
+}
+
+// Verifier checks the signature and standard claims of access tokens
+type Verifier struct {
+	keys     KeySource
+	issuer   string
+	audience string
+	revoked  RevocationList
+}
+
+// NewVerifier creates a verifier that accepts tokens signed by keys from the
+// given source for the given issuer and audience
+func NewVerifier(keys KeySource, issuer, audience string) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// WithRevocationList makes the verifier reject tokens of revoked sessions
+func (v *Verifier) WithRevocationList(list RevocationList) *Verifier {
+	v.revoked = list
+	return v
+}
+
+// Verify parses a token and returns its claims if it is valid
+func (v *Verifier) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, v.keyFunc,
+		jwt.WithValidMethods([]string{SigningMethod.Alg()}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	if v.revoked != nil && claims.SessionID != "" {
+		revoked, err := v.revoked.IsRevoked(claims.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check session: %v", err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("%w: session revoked", ErrInvalidToken)
+		}
+	}
+
+	return claims, nil
+}
+
+// keyFunc looks up the key named by the kid header of a token
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errors.New("missing key ID")
+	}
+
+	return v.keys.PublicKey(kid)
+}
@@ -10,19 +10,29 @@ import (
 _ "github.com/lib/pq"
 )
 
+// Supported database drivers
+const (
+DriverPostgres = "postgres"
+DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 *sql.DB
+Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 // Create connection string
-connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+connStr := cfg.DBDSN
+if connStr == "" {
+connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+}
 
 // Connect to database
-db, err := sql.Open("postgres", connStr)
+db, err := sql.Open(cfg.DBDriver, connStr)
 if err != nil {
 return nil, err
 }
@@ -38,9 +48,9 @@ db.SetMaxOpenConns(25)
 db.SetMaxIdleConns(25)
 db.SetConnMaxLifetime(5 * time.Minute)
 
-log.Println("Connected to PostgreSQL database")
+log.Printf("Connected to %s database", cfg.DBDriver)
 
-return &Database{db}, nil
+return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -52,7 +62,9 @@ id VARCHAR(36) PRIMARY KEY,
 user_id VARCHAR(36) NOT NULL,
 type VARCHAR(20) NOT NULL,
 status VARCHAR(20) NOT NULL,
+subject VARCHAR(255) NOT NULL DEFAULT '',
 content TEXT NOT NULL,
+recipient VARCHAR(255) NOT NULL DEFAULT '',
 created_at TIMESTAMP NOT NULL,
 updated_at TIMESTAMP NOT NULL,
 sent_at TIMESTAMP
@@ -133,6 +133,22 @@ notifications = append(notifications, notification)
 return notifications, nil
 }
 
+// EraseCustomer deletes the notifications of a customer, including those
+// sent to the email address of the customer without a user ID. It returns the
+// number of notifications deleted.
+func (r *NotificationRepository) EraseCustomer(customerID string, email string) (int, error) {
+result, err := r.db.Exec(
+"DELETE FROM notifications WHERE user_id = $1 OR ($2 <> '' AND recipient = $2)",
+customerID, email,
+)
+if err != nil {
+return 0, err
+}
+
+erased, err := result.RowsAffected()
+return int(erased), err
+}
+
 // UpdateNotificationStatus updates the status of a notification
 func (r *NotificationRepository) UpdateNotificationStatus(id string, status models.NotificationStatus) error {
 now := time.Now()
@@ -0,0 +1,168 @@
+package eventbus
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaBus is a Bus backed by a Kafka cluster
+type KafkaBus struct {
+	brokers []string
+
+	mu      sync.Mutex
+	writers map[string]*kafka.Writer
+	readers []*kafka.Reader
+}
+
+// Ensure KafkaBus implements Bus interface
+var _ Bus = (*KafkaBus)(nil)
+
+// NewKafkaBus creates a new Kafka backed event bus
+func NewKafkaBus(brokers string) *KafkaBus {
+	return &KafkaBus{
+		brokers: []string{brokers},
+		writers: make(map[string]*kafka.Writer),
+	}
+}
+
+// Publish writes a message to the given topic
+func (b *KafkaBus) Publish(ctx context.Context, topic string, key, value []byte) error {
+	return b.writer(topic).WriteMessages(ctx, kafka.Message{
+		Key:   key,
+		Value: value,
+		Time:  time.Now(),
+	})
+}
+
+// Subscribe starts a reader for the topic in the given consumer group and
+// passes every message to handler until ctx is cancelled. Without a group,
+// a reader per partition starts at the latest offset and commits nothing.
+func (b *KafkaBus) Subscribe(ctx context.Context, topic, groupID string, handler Handler) error {
+	if groupID == "" {
+		go b.broadcast(ctx, topic, handler)
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  b.brokers,
+		Topic:    topic,
+		GroupID:  groupID,
+		MinBytes: 10e3, // 10KB
+		MaxBytes: 10e6, // 10MB
+		MaxWait:  1 * time.Second,
+	})
+	b.consume(ctx, reader, topic, groupID, handler)
+
+	log.Printf("Kafka consumer subscribed to topic %s (group %s)", topic, groupID)
+	return nil
+}
+
+// broadcast starts a reader outside any consumer group on every partition of
+// the topic, retrying until the partitions can be read or ctx is cancelled
+func (b *KafkaBus) broadcast(ctx context.Context, topic string, handler Handler) {
+	for {
+		partitions, err := b.partitions(ctx, topic)
+		if err == nil {
+			for _, partition := range partitions {
+				reader := kafka.NewReader(kafka.ReaderConfig{
+					Brokers:   b.brokers,
+					Topic:     topic,
+					Partition: partition.ID,
+					MinBytes:  1,
+					MaxBytes:  10e6, // 10MB
+					MaxWait:   1 * time.Second,
+				})
+				if err := reader.SetOffset(kafka.LastOffset); err != nil {
+					log.Printf("Error seeking to the end of Kafka topic %s: %v", topic, err)
+				}
+				b.consume(ctx, reader, topic, "", handler)
+			}
+			log.Printf("Kafka consumer subscribed to topic %s (%d partitions, no group)", topic, len(partitions))
+			return
+		}
+
+		log.Printf("Error reading partitions of Kafka topic %s: %v", topic, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// partitions returns the partitions of a topic
+func (b *KafkaBus) partitions(ctx context.Context, topic string) ([]kafka.Partition, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", b.brokers[0])
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ReadPartitions(topic)
+}
+
+// consume passes the messages reader reads to handler until ctx is cancelled
+func (b *KafkaBus) consume(ctx context.Context, reader *kafka.Reader, topic, groupID string, handler Handler) {
+	b.mu.Lock()
+	b.readers = append(b.readers, reader)
+	b.mu.Unlock()
+
+	go func() {
+		defer reader.Close()
+		for {
+			msg, err := reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					log.Printf("Stopping Kafka consumer for topic %s (group %s)", topic, groupID)
+					return
+				}
+				log.Printf("Error reading message from Kafka topic %s: %v", topic, err)
+				time.Sleep(1 * time.Second)
+				continue
+			}
+
+			if err := handler(ctx, msg.Key, msg.Value); err != nil {
+				log.Printf("Error handling message from Kafka topic %s: %v", topic, err)
+			}
+		}
+	}()
+}
+
+// Close closes all writers and readers
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	for _, w := range b.writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, r := range b.readers {
+		r.Close()
+	}
+	b.writers = make(map[string]*kafka.Writer)
+	b.readers = nil
+	return firstErr
+}
+
+// writer returns the writer for a topic, creating it on first use
+func (b *KafkaBus) writer(topic string) *kafka.Writer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.writers[topic]
+	if !ok {
+		w = &kafka.Writer{
+			Addr:     kafka.TCP(b.brokers...),
+			Topic:    topic,
+			Balancer: &kafka.LeastBytes{},
+		}
+		b.writers[topic] = w
+	}
+	return w
+}
@@ -15,6 +15,10 @@ SendNotification(notification models.Notification) error
 ProcessOrderEvent(event models.OrderEvent) error
 ProcessPaymentEvent(event models.PaymentEvent) error
 ProcessShipmentEvent(event models.ShipmentEvent) error
+ProcessAccountEmailEvent(event models.AccountEmailEvent) error
+ProcessInventoryEvent(event models.InventoryEvent) error
+GetCustomerData(customerID string) (models.CustomerData, error)
+EraseCustomer(event models.ErasureEvent) error
 }
 
 // NotificationProducer defines the interface for notification producer
@@ -22,6 +26,7 @@ type NotificationProducer interface {
 PublishNotificationCreated(notification models.Notification) error
 PublishNotificationSent(notification models.Notification) error
 PublishNotificationFailed(notification models.Notification) error
+PublishErasureCompleted(event models.ErasureEvent) error
 Close() error
 }
 
@@ -30,6 +35,8 @@ type NotificationConsumer interface {
 ProcessOrderEvent(event models.OrderEvent) error
 ProcessPaymentEvent(event models.PaymentEvent) error
 ProcessShipmentEvent(event models.ShipmentEvent) error
+ProcessAccountEmailEvent(event models.AccountEmailEvent) error
+ProcessInventoryEvent(event models.InventoryEvent) error
 }
 
 // EmailSender defines the interface for email sender
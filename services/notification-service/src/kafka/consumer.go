@@ -4,186 +4,147 @@ import (
 "context"
 "encoding/json"
 "log"
-"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/notification-service/config"
+"github.com/online-order-system/notification-service/eventbus"
 "github.com/online-order-system/notification-service/interfaces"
 "github.com/online-order-system/notification-service/models"
 )
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-orderReader    *kafka.Reader
-paymentReader  *kafka.Reader
-shipmentReader *kafka.Reader
-service        interfaces.NotificationService
+bus          eventbus.Bus
+service      interfaces.NotificationService
+userTopic    string
+privacyTopic string
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.NotificationService) *Consumer {
-orderReader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:         []string{cfg.KafkaBootstrapServers},
-Topic:           "orders",
-GroupID:         "notification-service",
-MinBytes:        10e3, // 10KB
-MaxBytes:        10e6, // 10MB
-MaxWait:         1 * time.Second,
-ReadLagInterval: -1,
-})
-
-paymentReader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:         []string{cfg.KafkaBootstrapServers},
-Topic:           "payments",
-GroupID:         "notification-service",
-MinBytes:        10e3, // 10KB
-MaxBytes:        10e6, // 10MB
-MaxWait:         1 * time.Second,
-ReadLagInterval: -1,
-})
-
-shipmentReader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:         []string{cfg.KafkaBootstrapServers},
-Topic:           "shipments",
-GroupID:         "notification-service",
-MinBytes:        10e3, // 10KB
-MaxBytes:        10e6, // 10MB
-MaxWait:         1 * time.Second,
-ReadLagInterval: -1,
-})
-
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.NotificationService) *Consumer {
 return &Consumer{
-orderReader:    orderReader,
-paymentReader:  paymentReader,
-shipmentReader: shipmentReader,
-service:        service,
+bus:          bus,
+service:      service,
+userTopic:    cfg.KafkaUserTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
 // StartConsuming starts consuming messages from Kafka
 func (c *Consumer) StartConsuming(ctx context.Context) {
 // Start consuming order events
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping order Kafka consumer")
-c.orderReader.Close()
-return
-default:
-// Read message
-msg, err := c.orderReader.ReadMessage(ctx)
-if err != nil {
-log.Printf("Error reading order message: %v", err)
-continue
-}
-
-// Process message
-err = c.processOrderMessage(msg)
-if err != nil {
-log.Printf("Error processing order message: %v", err)
-continue
+if err := c.bus.Subscribe(ctx, "orders", "notification-service", c.processOrderMessage); err != nil {
+log.Printf("Error subscribing to orders topic: %v", err)
 }
-}
-}
-}()
 
 // Start consuming payment events
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping payment Kafka consumer")
-c.paymentReader.Close()
-return
-default:
-// Read message
-msg, err := c.paymentReader.ReadMessage(ctx)
-if err != nil {
-log.Printf("Error reading payment message: %v", err)
-continue
-}
-
-// Process message
-err = c.processPaymentMessage(msg)
-if err != nil {
-log.Printf("Error processing payment message: %v", err)
-continue
-}
-}
+if err := c.bus.Subscribe(ctx, "payments", "notification-service", c.processPaymentMessage); err != nil {
+log.Printf("Error subscribing to payments topic: %v", err)
 }
-}()
 
 // Start consuming shipment events
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping shipment Kafka consumer")
-c.shipmentReader.Close()
-return
-default:
-// Read message
-msg, err := c.shipmentReader.ReadMessage(ctx)
-if err != nil {
-log.Printf("Error reading shipment message: %v", err)
-continue
+if err := c.bus.Subscribe(ctx, "shipments", "notification-service", c.processShipmentMessage); err != nil {
+log.Printf("Error subscribing to shipments topic: %v", err)
 }
 
-// Process message
-err = c.processShipmentMessage(msg)
-if err != nil {
-log.Printf("Error processing shipment message: %v", err)
-continue
-}
+// Start consuming user account events
+if err := c.bus.Subscribe(ctx, c.userTopic, "notification-service", c.processUserMessage); err != nil {
+log.Printf("Error subscribing to %s topic: %v", c.userTopic, err)
 }
+
+// Start consuming erasure requests
+if err := c.bus.Subscribe(ctx, c.privacyTopic, "notification-service", c.processPrivacyMessage); err != nil {
+log.Printf("Error subscribing to %s topic: %v", c.privacyTopic, err)
 }
-}()
 
 log.Println("Kafka consumers started")
 }
 
 // processOrderMessage processes an order message
-func (c *Consumer) processOrderMessage(msg kafka.Message) error {
+func (c *Consumer) processOrderMessage(ctx context.Context, key, value []byte) error {
 // Parse message
 var event models.OrderEvent
-err := json.Unmarshal(msg.Value, &event)
+err := json.Unmarshal(value, &event)
 if err != nil {
 return err
 }
 
-log.Printf("Received message from Kafka orders topic: %s", string(msg.Value))
+log.Printf("Received message from Kafka orders topic: %s", string(value))
+
+// Inventory-service publishes its stock alerts on the orders topic
+if event.EventType == "inventory_low" || event.EventType == "out_of_stock" {
+var inventoryEvent models.InventoryEvent
+if err := json.Unmarshal(value, &inventoryEvent); err != nil {
+return err
+}
+return c.service.ProcessInventoryEvent(inventoryEvent)
+}
 
 // Process event
 return c.service.ProcessOrderEvent(event)
 }
 
 // processPaymentMessage processes a payment message
-func (c *Consumer) processPaymentMessage(msg kafka.Message) error {
+func (c *Consumer) processPaymentMessage(ctx context.Context, key, value []byte) error {
 // Parse message
 var event models.PaymentEvent
-err := json.Unmarshal(msg.Value, &event)
+err := json.Unmarshal(value, &event)
 if err != nil {
 return err
 }
 
-log.Printf("Received message from Kafka payments topic: %s", string(msg.Value))
+log.Printf("Received message from Kafka payments topic: %s", string(value))
 
 // Process event
 return c.service.ProcessPaymentEvent(event)
 }
 
 // processShipmentMessage processes a shipment message
-func (c *Consumer) processShipmentMessage(msg kafka.Message) error {
+func (c *Consumer) processShipmentMessage(ctx context.Context, key, value []byte) error {
 // Parse message
 var event models.ShipmentEvent
-err := json.Unmarshal(msg.Value, &event)
+err := json.Unmarshal(value, &event)
 if err != nil {
 return err
 }
 
-log.Printf("Received message from Kafka shipments topic: %s", string(msg.Value))
+log.Printf("Received message from Kafka shipments topic: %s", string(value))
 
 // Process event
 return c.service.ProcessShipmentEvent(event)
 }
+
+// processUserMessage processes a user account message
+func (c *Consumer) processUserMessage(ctx context.Context, key, value []byte) error {
+// Parse message
+var event models.AccountEmailEvent
+err := json.Unmarshal(value, &event)
+if err != nil {
+return err
+}
+
+// The message carries a link that grants access to the account, so only
+// the event type is logged
+log.Printf("Received %s message from Kafka %s topic for user %s", event.EventType, c.userTopic, event.CustomerID)
+
+// Process event
+return c.service.ProcessAccountEmailEvent(event)
+}
+
+// processPrivacyMessage processes a privacy message
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+// Parse message
+var event models.ErasureEvent
+err := json.Unmarshal(value, &event)
+if err != nil {
+return err
+}
+
+// Only erasure requests are handled, reports come from the other services
+if event.EventType != "user_erasure_requested" {
+return nil
+}
+log.Printf("Received %s message from Kafka %s topic for user %s", event.EventType, c.privacyTopic, event.CustomerID)
+
+// Process event
+return c.service.EraseCustomer(event)
+}
@@ -6,36 +6,34 @@ import (
 "log"
 "time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/notification-service/config"
+"github.com/online-order-system/notification-service/eventbus"
 "github.com/online-order-system/notification-service/interfaces"
 "github.com/online-order-system/notification-service/models"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-writer *kafka.Writer
+bus          eventbus.Bus
+topic        string
+privacyTopic string
 }
 
 // Ensure Producer implements NotificationProducer interface
 var _ interfaces.NotificationProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-writer := kafka.NewWriter(kafka.WriterConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    cfg.KafkaTopic,
-Balancer: &kafka.LeastBytes{},
-})
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 return &Producer{
-writer: writer,
+bus:          bus,
+topic:        cfg.KafkaTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-return p.writer.Close()
+return nil
 }
 
 // PublishNotificationCreated publishes a notification created event
@@ -86,6 +84,26 @@ Timestamp:  time.Now().Unix(),
 return p.publishEvent(event)
 }
 
+// PublishErasureCompleted reports to user-service what was erased for an
+// erasure request
+func (p *Producer) PublishErasureCompleted(event models.ErasureEvent) error {
+event.EventType = "user_erasure_completed"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+err = p.bus.Publish(context.Background(), p.privacyTopic, []byte(event.CustomerID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for erasure %s", event.EventType, event.RequestID)
+return nil
+}
+
 // publishEvent publishes an event to Kafka
 func (p *Producer) publishEvent(event models.NotificationEvent) error {
 // Marshal event to JSON
@@ -94,15 +112,8 @@ if err != nil {
 return err
 }
 
-// Create message
-msg := kafka.Message{
-Key:   []byte(event.CustomerID),
-Value: eventJSON,
-Time:  time.Now(),
-}
-
 // Write message to Kafka
-err = p.writer.WriteMessages(context.Background(), msg)
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.CustomerID), eventJSON)
 if err != nil {
 return err
 }
@@ -19,6 +19,15 @@ const (
 	NotificationTypeOrderCancelled NotificationType = "ORDER_CANCELLED"
 	NotificationTypePaymentFailed  NotificationType = "PAYMENT_FAILED"
 
+	// Account emails sent for user-service
+	NotificationTypeEmailVerification NotificationType = "EMAIL_VERIFICATION"
+	NotificationTypePasswordReset     NotificationType = "PASSWORD_RESET"
+	NotificationTypeAccountLocked     NotificationType = "ACCOUNT_LOCKED"
+
+	// Stock alerts sent to staff for inventory-service
+	NotificationTypeInventoryLow NotificationType = "INVENTORY_LOW"
+	NotificationTypeOutOfStock   NotificationType = "OUT_OF_STOCK"
+
 	// Channel types for internal use
 	NotificationTypeEmail   NotificationType = "EMAIL"
 	NotificationTypeSMS     NotificationType = "SMS"
@@ -46,9 +55,9 @@ ID          string             `json:"id"`
 CustomerID  string             `json:"customer_id"` // Kept as CustomerID for code consistency, maps to user_id in DB
 Type        NotificationType   `json:"type"`
 Status      NotificationStatus `json:"status"`
-Subject     string             `json:"subject,omitempty"` // Not in DB schema as per design
+Subject     string             `json:"subject,omitempty"`
 Content     string             `json:"content"`
-Recipient   string             `json:"recipient,omitempty"` // Not in DB schema as per design
+Recipient   string             `json:"recipient,omitempty"`
 CreatedAt   time.Time          `json:"created_at"`
 UpdatedAt   time.Time          `json:"updated_at"`
 SentAt      *time.Time         `json:"sent_at,omitempty"`
@@ -85,6 +94,7 @@ type OrderItem struct {
 	ID          string  `json:"id"`
 	OrderID     string  `json:"order_id"`
 	ProductID   string  `json:"product_id"`
+	SKUID       string  `json:"sku_id,omitempty"`
 	Quantity    int     `json:"quantity"`
 	Price       float64 `json:"price"`
 }
@@ -99,7 +109,32 @@ type OrderEvent struct {
 	Timestamp       int64       `json:"timestamp"`
 	FailureReason   string      `json:"failure_reason,omitempty"`
 	Items           []OrderItem `json:"items,omitempty"`
-	ShippingAddress string      `json:"shipping_address,omitempty"`
+	ShippingAddress *Address    `json:"shipping_address,omitempty"`
+	Customer        *CustomerContact `json:"customer,omitempty"`
+}
+
+// CustomerContact is what services need to contact a customer. Events carry
+// it, so notifications don't have to look it up in user-service.
+type CustomerContact struct {
+ID     string `json:"id"`
+Email  string `json:"email"`
+Name   string `json:"name"`
+Phone  string `json:"phone,omitempty"`
+Locale string `json:"locale"`
+}
+
+// Address is the address an order ships to
+type Address struct {
+Recipient  string `json:"recipient"`
+Phone      string `json:"phone"`
+Line1      string `json:"line1"`
+Line2      string `json:"line2,omitempty"`
+Ward       string `json:"ward,omitempty"`
+District   string `json:"district,omitempty"`
+City       string `json:"city,omitempty"`
+Province   string `json:"province,omitempty"`
+PostalCode string `json:"postal_code,omitempty"`
+Country    string `json:"country"`
 }
 
 // PaymentEvent represents a payment event from Kafka
@@ -112,6 +147,36 @@ Amount        float64 `json:"amount"`
 Status        string  `json:"status"`
 PaymentMethod string  `json:"payment_method"`
 Timestamp     int64   `json:"timestamp"`
+Customer      *CustomerContact `json:"customer,omitempty"`
+}
+
+// AccountEmailEvent represents a request from user-service to email a user
+// about their account, such as an email verification or password reset link
+// or an alert that the account was locked
+type AccountEmailEvent struct {
+EventType   string `json:"event_type"`
+CustomerID  string `json:"customer_id"`
+Email       string `json:"email"`
+FirstName   string `json:"first_name"`
+Link        string `json:"link,omitempty"`
+ExpiresAt   int64  `json:"expires_at,omitempty"`
+Reason      string `json:"reason,omitempty"`
+IPAddress   string `json:"ip_address,omitempty"`
+LockedUntil int64  `json:"locked_until,omitempty"`
+Timestamp   int64  `json:"timestamp"`
+}
+
+// InventoryEvent represents a stock alert from inventory-service. It arrives
+// on the orders topic with the other inventory events.
+type InventoryEvent struct {
+EventType         string   `json:"event_type"`
+ProductID         string   `json:"product_id"`
+ProductName       string   `json:"product_name"`
+Quantity          int      `json:"quantity"`
+LowStockThreshold int      `json:"low_stock_threshold"`
+ReorderPoint      int      `json:"reorder_point"`
+DaysOfCover       *float64 `json:"days_of_cover,omitempty"`
+Timestamp         int64    `json:"timestamp"`
 }
 
 // ShipmentEvent represents a shipment event from Kafka
@@ -123,4 +188,36 @@ Status         string `json:"status"`
 TrackingNumber string `json:"tracking_number,omitempty"`
 Timestamp      int64  `json:"timestamp"`
 CustomerID     string `json:"customer_id,omitempty"` // Added for notification purposes
+Customer       *CustomerContact `json:"customer,omitempty"`
+}
+
+// CustomerData is the personal data notification-service holds about a
+// customer, as it appears in a data export
+type CustomerData struct {
+CustomerID    string         `json:"customer_id"`
+Notifications []Notification `json:"notifications"`
+}
+
+// Statuses a service reports for an erasure
+const (
+ErasureCompleted = "completed"
+ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+EventType  string `json:"event_type"`
+RequestID  string `json:"request_id"`
+CustomerID string `json:"customer_id"`
+Email      string `json:"email,omitempty"`
+Timestamp  int64  `json:"timestamp"`
+
+// Set on user_erasure_completed events
+Service  string `json:"service,omitempty"`
+Status   string `json:"status,omitempty"`
+Erased   int    `json:"erased,omitempty"`
+Retained int    `json:"retained,omitempty"`
+Note     string `json:"note,omitempty"`
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/online-order-system/notification-service/auth"
 	"github.com/online-order-system/notification-service/config"
 )
 
@@ -15,13 +16,11 @@ type OrderClient struct {
 	client *http.Client
 }
 
-// NewOrderClient creates a new order client
-func NewOrderClient(cfg *config.Config) *OrderClient {
+// NewOrderClient creates a new order client calling with tokens from tokens
+func NewOrderClient(cfg *config.Config, tokens auth.TokenSource) *OrderClient {
 	return &OrderClient{
 		config: cfg,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		client: auth.NewClient(tokens, 5*time.Second),
 	}
 }
 
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/notification-service/models"
+)
+
+// notificationServiceName names notification-service in erasure reports
+const notificationServiceName = "notification-service"
+
+// GetCustomerData gathers the personal data notification-service holds about
+// a customer for a data export
+func (s *NotificationService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	notifications, err := s.repository.GetNotificationsByCustomerID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+	if notifications == nil {
+		notifications = []models.Notification{}
+	}
+
+	return models.CustomerData{CustomerID: customerID, Notifications: notifications}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Notifications are only kept for the
+// customer to read, so all of them are deleted.
+func (s *NotificationService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    notificationServiceName,
+		Status:     models.ErasureCompleted,
+	}
+
+	// Don't keep contact details of the customer around in the cache either
+	s.userClient.Forget(event.CustomerID)
+
+	var err error
+	report.Erased, err = s.repository.EraseCustomer(event.CustomerID, event.Email)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+
+	return s.producer.PublishErasureCompleted(report)
+}
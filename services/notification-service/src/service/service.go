@@ -1,9 +1,11 @@
 package service
 
 import (
+"fmt"
 "log"
 "time"
 
+"github.com/online-order-system/notification-service/auth"
 "github.com/online-order-system/notification-service/config"
 "github.com/online-order-system/notification-service/db"
 "github.com/online-order-system/notification-service/interfaces"
@@ -16,6 +18,8 @@ config     *config.Config
 repository *db.NotificationRepository
 producer   interfaces.NotificationProducer
 orderClient *OrderClient
+userClient  *UserClient
+emailSender *EmailSender
 }
 
 // Ensure NotificationService implements NotificationService interface
@@ -23,11 +27,18 @@ var _ interfaces.NotificationService = (*NotificationService)(nil)
 
 // NewNotificationService creates a new notification service
 func NewNotificationService(cfg *config.Config, repo *db.NotificationRepository, producer interfaces.NotificationProducer) *NotificationService {
+if cfg.ServiceSecret == "" {
+log.Printf("SERVICE_SECRET is not set, calls to order-service and user-service will fail")
+}
+
+tokens := auth.NewServiceTokens(cfg.ServiceTokenURL, cfg.ServiceName, cfg.ServiceSecret)
 return &NotificationService{
 config:     cfg,
 repository: repo,
 producer:   producer,
-orderClient: NewOrderClient(cfg),
+orderClient: NewOrderClient(cfg, tokens),
+userClient:  NewUserClient(cfg.UserServiceURL, tokens, cfg.CustomerCacheTTL),
+emailSender: NewEmailSender(cfg),
 }
 }
 
@@ -182,17 +193,23 @@ func (s *NotificationService) ProcessOrderEvent(event models.OrderEvent) error {
         return nil // Ignore unknown event types
     }
 
+customer, err := s.customerFor(event.Customer, event.CustomerID, event.OrderID)
+if err != nil {
+log.Printf("Skipping %s notification for order %s: %v", event.EventType, event.OrderID, err)
+return nil
+}
+
 // Create notification request
 req := models.CreateNotificationRequest{
-CustomerID: event.CustomerID,
+CustomerID: customer.ID,
 Type:      notificationType,
 Subject:   subject,
 Content:   content,
-Recipient: event.CustomerID + "@example.com", // In a real app, get from customer service
+Recipient: customer.Email,
 }
 
 // Create notification
-_, err := s.CreateNotification(req)
+_, err = s.CreateNotification(req)
 return err
 }
 
@@ -222,17 +239,23 @@ func (s *NotificationService) ProcessPaymentEvent(event models.PaymentEvent) err
         return nil // Ignore unknown event types
     }
 
+customer, err := s.customerFor(event.Customer, event.CustomerID, event.OrderID)
+if err != nil {
+log.Printf("Skipping %s notification for order %s: %v", event.EventType, event.OrderID, err)
+return nil
+}
+
 // Create notification request
 req := models.CreateNotificationRequest{
-CustomerID: event.CustomerID,
+CustomerID: customer.ID,
 Type:      notificationType,
 Subject:   subject,
 Content:   content,
-Recipient: event.CustomerID + "@example.com", // In a real app, get from customer service
+Recipient: customer.Email,
 }
 
 // Create notification
-_, err := s.CreateNotification(req)
+_, err = s.CreateNotification(req)
 return err
 }
 
@@ -269,30 +292,174 @@ func (s *NotificationService) ProcessShipmentEvent(event models.ShipmentEvent) e
         return nil // Ignore unknown event types
     }
 
-// Check if we have customer ID in the event
-if event.CustomerID == "" {
-    // If not, try to get it from order service
-    order, err := s.orderClient.GetOrderByID(event.OrderID)
-    if err != nil {
-        log.Printf("Failed to get order details: %v", err)
-        // Continue with a fallback approach instead of returning error
-        // We'll use the order ID as a placeholder for customer ID
-        event.CustomerID = event.OrderID
-    } else {
-        event.CustomerID = order.CustomerID
-    }
+customer, err := s.customerFor(event.Customer, event.CustomerID, event.OrderID)
+if err != nil {
+    log.Printf("Skipping %s notification for order %s: %v", event.EventType, event.OrderID, err)
+    return nil
 }
 
-// Create notification request with customer ID
+// Create notification request
 req := models.CreateNotificationRequest{
-    CustomerID: event.CustomerID,
+    CustomerID: customer.ID,
     Type:      notificationType,
     Subject:   subject,
     Content:   content,
-    Recipient: event.CustomerID + "@example.com", // Use customer ID for email
+    Recipient: customer.Email,
 }
 
 // Create notification
-_, err := s.CreateNotification(req)
+_, err = s.CreateNotification(req)
+return err
+}
+
+// customerFor returns the contact details of the customer an event is about.
+// Events carry them; the customer of an event published before they did is
+// looked up in user-service, by way of order-service when the event doesn't
+// name the customer either.
+func (s *NotificationService) customerFor(customer *models.CustomerContact, customerID string, orderID string) (models.CustomerContact, error) {
+if customer != nil && customer.Email != "" {
+    contact := *customer
+    if contact.ID == "" {
+        contact.ID = customerID
+    }
+    return contact, nil
+}
+
+if customerID == "" {
+    order, err := s.orderClient.GetOrderByID(orderID)
+    if err != nil {
+        return models.CustomerContact{}, err
+    }
+    customerID = order.CustomerID
+}
+
+return s.userClient.GetCustomer(customerID)
+}
+
+// ProcessAccountEmailEvent processes an account email event from user-service.
+// A link in the email grants access to the account, so it is handed to the
+// email sender only and never stored with the notification.
+func (s *NotificationService) ProcessAccountEmailEvent(event models.AccountEmailEvent) error {
+var notificationType models.NotificationType
+var subject, body, content string
+
+expiresAt := time.Unix(event.ExpiresAt, 0).UTC().Format(time.RFC1123)
+
+switch event.EventType {
+case "email_verification_requested":
+notificationType = models.NotificationTypeEmailVerification
+subject = "Verify your email address"
+body = "Hi " + event.FirstName + ", please confirm your email address by opening " + event.Link + " before " + expiresAt + "."
+content = "We sent you an email to verify your email address."
+log.Printf("Sending email verification to user %s", event.CustomerID)
+
+case "password_reset_requested":
+notificationType = models.NotificationTypePasswordReset
+subject = "Reset your password"
+body = "Hi " + event.FirstName + ", you can choose a new password by opening " + event.Link + " before " + expiresAt + ". If you didn't ask for this, you can ignore this email."
+content = "We sent you an email with a link to reset your password."
+log.Printf("Sending password reset to user %s", event.CustomerID)
+
+case "user_locked":
+notificationType = models.NotificationTypeAccountLocked
+subject = "Your account was locked"
+body = "Hi " + event.FirstName + ", "
+if event.LockedUntil > 0 {
+lockedUntil := time.Unix(event.LockedUntil, 0).UTC().Format(time.RFC1123)
+body += "your account was locked until " + lockedUntil + " after too many failed sign-in attempts"
+if event.IPAddress != "" {
+body += " from " + event.IPAddress
+}
+body += ". If this wasn't you, reset your password once the account is unlocked."
+content = "Your account was locked until " + lockedUntil + " after too many failed sign-in attempts."
+} else {
+body += "your account was locked by an administrator and stays locked until an administrator unlocks it. Please contact support if you have questions."
+content = "Your account was locked by an administrator."
+}
+log.Printf("Sending account locked alert to user %s", event.CustomerID)
+
+default:
+log.Printf("Ignoring user event type: %s", event.EventType)
+return nil // Ignore unknown event types
+}
+
+// Send the email, with the link if there is one
+err := s.emailSender.SendEmail(event.Email, subject, body)
+if err != nil {
 return err
 }
+
+// Record that the email was sent, without the link
+req := models.CreateNotificationRequest{
+CustomerID: event.CustomerID,
+Type:       notificationType,
+Subject:    subject,
+Content:    content,
+Recipient:  event.Email,
+}
+
+_, err = s.CreateNotification(req)
+return err
+}
+
+// ProcessInventoryEvent processes a stock alert from inventory-service. Stock
+// alerts go to the staff in STAFF_EMAILS rather than to a customer.
+func (s *NotificationService) ProcessInventoryEvent(event models.InventoryEvent) error {
+var notificationType models.NotificationType
+var subject, content string
+
+name := event.ProductName
+if name == "" {
+name = event.ProductID
+}
+
+switch event.EventType {
+case "inventory_low":
+notificationType = models.NotificationTypeInventoryLow
+subject = "Low stock: " + name
+content = fmt.Sprintf("%s (%s) is low on stock: %d left, the low stock threshold is %d.",
+name, event.ProductID, event.Quantity, event.LowStockThreshold)
+if event.DaysOfCover != nil {
+content += fmt.Sprintf(" At recent sales this lasts about %.1f days.", *event.DaysOfCover)
+}
+if event.Quantity <= event.ReorderPoint {
+content += fmt.Sprintf(" It is at or below its reorder point of %d, please reorder.", event.ReorderPoint)
+}
+
+case "out_of_stock":
+notificationType = models.NotificationTypeOutOfStock
+subject = "Out of stock: " + name
+content = fmt.Sprintf("%s (%s) is out of stock, please reorder.", name, event.ProductID)
+
+default:
+log.Printf("Ignoring inventory event type: %s", event.EventType)
+return nil // Ignore unknown event types
+}
+
+if len(s.config.StaffEmails) == 0 {
+log.Printf("STAFF_EMAILS is not set, dropping %s alert for product %s", event.EventType, event.ProductID)
+return nil
+}
+log.Printf("Sending %s alert for product %s to %d staff", event.EventType, event.ProductID, len(s.config.StaffEmails))
+
+for _, email := range s.config.StaffEmails {
+err := s.emailSender.SendEmail(email, subject, content)
+if err != nil {
+log.Printf("Failed to send %s alert to %s: %v", event.EventType, email, err)
+// Continue anyway
+}
+
+// Staff alerts belong to no customer
+req := models.CreateNotificationRequest{
+Type:      notificationType,
+Subject:   subject,
+Content:   content,
+Recipient: email,
+}
+if _, err := s.CreateNotification(req); err != nil {
+log.Printf("Failed to record %s alert for %s: %v", event.EventType, email, err)
+}
+}
+
+return nil
+}
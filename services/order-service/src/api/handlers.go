@@ -5,10 +5,14 @@ import (
 "net/http"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/order-service/auth"
 "github.com/online-order-system/order-service/interfaces"
 "github.com/online-order-system/order-service/models"
 )
 
+// staffRoles may act on the orders of every customer
+var staffRoles = []string{auth.RoleSupport, auth.RoleWarehouse, auth.RoleAdmin}
+
 // Handler handles HTTP requests
 type Handler struct {
 service interfaces.OrderService
@@ -39,6 +43,13 @@ c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 return
 }
 
+// Customers can only order for themselves
+if !auth.CanAccess(c, req.CustomerID, auth.RoleSupport, auth.RoleAdmin) {
+c.JSON(http.StatusForbidden, gin.H{"error": "Cannot create orders for another customer"})
+return
+}
+
+req.Caller = auth.Caller(c)
 order, err := h.service.CreateOrder(req)
 if err != nil {
 c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -67,12 +78,18 @@ c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 return
 }
 
+// Orders of other customers look the same as missing ones
+if !auth.CanAccess(c, order.CustomerID, staffRoles...) {
+c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+return
+}
+
 c.JSON(http.StatusOK, order)
 }
 
 // GetOrders handles retrieving all orders
 // @Summary Get all orders
-// @Description Get all orders in the system
+// @Description Get all orders in the system, or only their own for customers
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -80,7 +97,13 @@ c.JSON(http.StatusOK, order)
 // @Failure 500 {object} map[string]string "Internal Server Error"
 // @Router /orders [get]
 func (h *Handler) GetOrders(c *gin.Context) {
-orders, err := h.service.GetOrders()
+var orders []models.Order
+var err error
+if claims, ok := auth.ClaimsFromContext(c); ok && !claims.HasRole(staffRoles...) {
+orders, err = h.service.GetOrdersByCustomerID(claims.UserID())
+} else {
+orders, err = h.service.GetOrders()
+}
 if err != nil {
 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get orders"})
 return
@@ -141,6 +164,12 @@ func (h *Handler) RetryPayment(c *gin.Context) {
 id := c.Param("id")
 log.Printf("RetryPayment handler called for order ID: %s", id)
 
+order, err := h.service.GetOrderByID(id)
+if err != nil || !auth.CanAccess(c, order.CustomerID, auth.RoleSupport, auth.RoleAdmin) {
+c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+return
+}
+
 var req models.RetryPaymentRequest
 if err := c.ShouldBindJSON(&req); err != nil {
 log.Printf("Error binding JSON: %v", err)
@@ -149,7 +178,8 @@ return
 }
 
 log.Printf("Retrying payment for order %s with payment method: %s", id, req.PaymentMethod)
-order, err := h.service.RetryPayment(id, req)
+req.Caller = auth.Caller(c)
+order, err = h.service.RetryPayment(id, req)
 if err != nil {
 log.Printf("Error retrying payment: %v", err)
 c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -159,3 +189,23 @@ return
 log.Printf("Payment retried successfully for order %s", id)
 c.JSON(http.StatusOK, order)
 }
+
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export
+// @Summary Get customer data
+// @Description Get the personal data order-service holds about a customer. Only other services may call it.
+// @Tags privacy
+// @Produce json
+// @Param id path string true "Customer ID"
+// @Success 200 {object} models.CustomerData
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /customers/{id}/data [get]
+func (h *Handler) GetCustomerData(c *gin.Context) {
+data, err := h.service.GetCustomerData(c.Param("id"))
+if err != nil {
+c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+return
+}
+
+c.JSON(http.StatusOK, data)
+}
@@ -2,18 +2,20 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/order-service/auth"
 	"github.com/online-order-system/order-service/interfaces"
 	"log"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.OrderService) *gin.Engine {
+func SetupRouter(service interfaces.OrderService, verifier *auth.Verifier) *gin.Engine {
 	// Create router
 	router := gin.Default()
 
 	// Add middleware
 	router.Use(Logger())
 	router.Use(CORS())
+	router.Use(auth.Authenticate(verifier))
 
 	// Create handler
 	handler := NewHandler(service)
@@ -29,25 +31,33 @@ func SetupRouter(service interfaces.OrderService) *gin.Engine {
 	router.StaticFile("/swagger", "./docs/swagger.html")
 	router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+	// Who may call the routes. Routes open to everyone check in the handler
+	// that the caller owns the order.
+	everyone := auth.RequireRole(auth.Everyone...)
+	staff := auth.RequireRole(auth.Internal, auth.RoleSupport, auth.RoleWarehouse, auth.RoleAdmin)
+
 	// Order routes
 	orders := router.Group("/orders")
 	{
 		// Create a new order
-		orders.POST("", handler.CreateOrder)
+		orders.POST("", everyone, handler.CreateOrder)
 
 		// Get all orders
-		orders.GET("", handler.GetOrders)
+		orders.GET("", everyone, handler.GetOrders)
 
 		// Get a specific order by ID
-		orders.GET("/:id", handler.GetOrderByID)
+		orders.GET("/:id", everyone, handler.GetOrderByID)
 
 		// Update the status of an order
-		orders.PUT("/:id/status", handler.UpdateOrderStatus)
+		orders.PUT("/:id/status", staff, handler.UpdateOrderStatus)
 
 		// Retry payment for a failed order
-		orders.POST("/:id/retry-payment", handler.RetryPayment)
+		orders.POST("/:id/retry-payment", everyone, handler.RetryPayment)
 	}
 
+	// Personal data of a customer, gathered by user-service for data exports
+	router.GET("/customers/:id/data", auth.RequireRole(auth.Internal), handler.GetCustomerData)
+
 	log.Printf("Route registered: GET /orders")
 	log.Printf("Route registered: GET /orders/:id")
 	log.Printf("Route registered: GET /health")
@@ -3,14 +3,21 @@ package config
 import (
 "os"
 "strconv"
+"strings"
+"time"
 )
 
 // Config holds all configuration for the service
 type Config struct {
 // Server configuration
-ServerPort string
+ServerPort     string
+// Addresses of the proxies, the gateway, trusted to say which client a
+// request came from. Clients are identified by their own address otherwise.
+TrustedProxies []string
 
 // Database configuration
+DBDriver   string
+DBDSN      string
 DBHost     string
 DBPort     string
 DBUser     string
@@ -20,6 +27,19 @@ DBName     string
 // Kafka configuration
 KafkaBootstrapServers string
 KafkaTopic            string
+KafkaPrivacyTopic     string
+EventBus              string
+
+// JWT configuration
+JWTIssuer   string
+JWTAudience string
+JWKSURL     string
+
+// Service token configuration. Calls to other services carry a token
+// user-service issues for the name and secret of this service.
+ServiceName     string
+ServiceSecret   string
+ServiceTokenURL string
 
 // External services
 InventoryServiceURL      string
@@ -29,15 +49,25 @@ RecommendationServiceURL string
 NotificationServiceURL   string
 UserServiceURL           string
 CartServiceURL           string
+
+// How long customers looked up in user-service are cached
+CustomerCacheTTL time.Duration
+
+// Percentage of the ordered units that must be allocated before an order
+// with backordered items ships. 100 waits for every item.
+PartialShipmentPercent int
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 return &Config{
 // Server configuration
-ServerPort: getEnv("PORT", "8081"),
+ServerPort:     getEnv("PORT", "8081"),
+TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 // Database configuration
+DBDriver:   getEnv("DB_DRIVER", "postgres"),
+DBDSN:      getEnv("DB_DSN", ""),
 DBHost:     getEnv("DB_HOST", "localhost"),
 DBPort:     getEnv("DB_PORT", "5432"),
 DBUser:     getEnv("DB_USER", "postgres"),
@@ -47,15 +77,31 @@ DBName:     getEnv("DB_NAME", "orderdb"),
 // Kafka configuration
 KafkaBootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:9092"),
 KafkaTopic:            getEnv("KAFKA_TOPIC", "orders"),
+KafkaPrivacyTopic:     getEnv("KAFKA_PRIVACY_TOPIC", "privacy"),
+EventBus:              getEnv("EVENT_BUS", "kafka"),
+
+// JWT configuration
+JWTIssuer:   getEnv("JWT_ISSUER", "user-service"),
+JWTAudience: getEnv("JWT_AUDIENCE", "online-order-system"),
+JWKSURL:     getEnv("JWKS_URL", "http://user-service:8086/.well-known/jwks.json"),
+
+// Service token configuration
+ServiceName:     getEnv("SERVICE_NAME", "order-service"),
+ServiceSecret:   getEnv("SERVICE_SECRET", ""),
+ServiceTokenURL: getEnv("SERVICE_TOKEN_URL", "http://user-service:8086/auth/service-token"),
 
 // External services
 InventoryServiceURL:      getEnv("INVENTORY_SERVICE_URL", "http://inventory-service:8082"),
 PaymentServiceURL:        getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8083"),
 ShippingServiceURL:       getEnv("SHIPPING_SERVICE_URL", "http://shipping-service:8084"),
-RecommendationServiceURL: getEnv("RECOMMENDATION_SERVICE_URL", "http://recommendation-service:8088"),
+RecommendationServiceURL: getEnv("RECOMMENDATION_SERVICE_URL", "http://inventory-service:8082"), // Recommendations are served by inventory-service
 NotificationServiceURL:   getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8085"),
 UserServiceURL:           getEnv("USER_SERVICE_URL", "http://user-service:8086"),
 CartServiceURL:           getEnv("CART_SERVICE_URL", "http://cart-service:8087"),
+
+CustomerCacheTTL: time.Duration(getEnvAsInt("CUSTOMER_CACHE_TTL", 300)) * time.Second,
+
+PartialShipmentPercent: getEnvAsInt("PARTIAL_SHIPMENT_PERCENT", 100),
 }
 }
 
@@ -84,3 +130,14 @@ return value
 }
 return defaultValue
 }
+
+// Helper to read a comma separated environment variable into a list
+func getEnvAsList(key string) []string {
+var values []string
+for _, value := range strings.Split(getEnv(key, ""), ",") {
+if value = strings.TrimSpace(value); value != "" {
+values = append(values, value)
+}
+}
+return values
+}
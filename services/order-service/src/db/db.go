@@ -10,19 +10,29 @@ import (
 	"github.com/online-order-system/order-service/config"
 )
 
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 	*sql.DB
+	Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 	// Create connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	connStr := cfg.DBDSN
+	if connStr == "" {
+		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open(cfg.DBDriver, connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -38,9 +48,9 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	log.Println("Connected to PostgreSQL database")
+	log.Printf("Connected to %s database", cfg.DBDriver)
 
-	return &Database{db}, nil
+	return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -59,7 +69,9 @@ inventory_locked BOOLEAN DEFAULT FALSE,
 payment_processed BOOLEAN DEFAULT FALSE,
 shipping_scheduled BOOLEAN DEFAULT FALSE,
 failure_reason TEXT,
-tracking_number VARCHAR(100)
+tracking_number VARCHAR(100),
+warehouse_id VARCHAR(36) NOT NULL DEFAULT '',
+backordered BOOLEAN DEFAULT FALSE
 )
 `)
 	if err != nil {
@@ -71,6 +83,8 @@ tracking_number VARCHAR(100)
 	_, _ = db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_processed BOOLEAN DEFAULT FALSE`)
 	_, _ = db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_scheduled BOOLEAN DEFAULT FALSE`)
 	_, _ = db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS failure_reason TEXT`)
+	_, _ = db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS warehouse_id VARCHAR(36) NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS backordered BOOLEAN DEFAULT FALSE`)
 
 	// Create order_items table
 	_, err = db.Exec(`
@@ -78,6 +92,7 @@ CREATE TABLE IF NOT EXISTS order_items (
 id VARCHAR(36) PRIMARY KEY,
 order_id VARCHAR(36) NOT NULL REFERENCES orders(id),
 product_id VARCHAR(36) NOT NULL,
+sku_id VARCHAR(36) NOT NULL DEFAULT '',
 quantity INTEGER NOT NULL,
 price DECIMAL(10, 2) NOT NULL
 )
@@ -85,12 +100,59 @@ price DECIMAL(10, 2) NOT NULL
 	if err != nil {
 		return err
 	}
+	_, _ = db.Exec(`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS sku_id VARCHAR(36) NOT NULL DEFAULT ''`)
+
+	// Create order_addresses table holding the copy of the address each order
+	// ships to. orders.shipping_address keeps the address on one line, which is
+	// all orders placed before addresses were structured have.
+	_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS order_addresses (
+order_id VARCHAR(36) PRIMARY KEY REFERENCES orders(id),
+recipient VARCHAR(100) NOT NULL,
+phone VARCHAR(20) NOT NULL,
+line1 VARCHAR(200) NOT NULL,
+line2 VARCHAR(200) NOT NULL DEFAULT '',
+ward VARCHAR(100) NOT NULL DEFAULT '',
+district VARCHAR(100) NOT NULL DEFAULT '',
+city VARCHAR(100) NOT NULL DEFAULT '',
+province VARCHAR(100) NOT NULL DEFAULT '',
+postal_code VARCHAR(20) NOT NULL DEFAULT '',
+country VARCHAR(2) NOT NULL
+)
+`)
+	if err != nil {
+		return err
+	}
+
+	// Create order_allocations table holding the warehouses the stock of each
+	// order was reserved in
+	_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS order_allocations (
+id VARCHAR(36) NOT NULL DEFAULT '',
+order_id VARCHAR(36) NOT NULL REFERENCES orders(id),
+product_id VARCHAR(36) NOT NULL,
+sku_id VARCHAR(36) NOT NULL DEFAULT '',
+warehouse_id VARCHAR(36) NOT NULL,
+quantity INTEGER NOT NULL,
+shipped BOOLEAN NOT NULL DEFAULT FALSE
+)
+`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.Exec(`ALTER TABLE order_allocations ADD COLUMN IF NOT EXISTS sku_id VARCHAR(36) NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE order_allocations ADD COLUMN IF NOT EXISTS id VARCHAR(36) NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE order_allocations ADD COLUMN IF NOT EXISTS shipped BOOLEAN NOT NULL DEFAULT FALSE`)
+	// Allocations recorded before shipments were tracked per allocation went
+	// out with the whole order
+	_, _ = db.Exec(`UPDATE order_allocations SET shipped = TRUE WHERE id = '' AND order_id IN (SELECT id FROM orders WHERE shipping_scheduled)`)
 
 	// Create audit_logs table
 	_, err = db.Exec(`
 CREATE TABLE IF NOT EXISTS audit_logs (
 id VARCHAR(36) PRIMARY KEY,
 service_name VARCHAR(50) NOT NULL,
+caller VARCHAR(100) NOT NULL DEFAULT '',
 action VARCHAR(50) NOT NULL,
 user_id VARCHAR(36) NOT NULL,
 timestamp TIMESTAMP NOT NULL,
@@ -100,6 +162,7 @@ details TEXT
 	if err != nil {
 		return err
 	}
+	_, _ = db.Exec(`ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS caller VARCHAR(100) NOT NULL DEFAULT ''`)
 
 	log.Println("Database tables created or already exist")
 	return nil
@@ -1,6 +1,7 @@
 package db
 
 import (
+"database/sql"
 "time"
 
 "github.com/online-order-system/order-service/models"
@@ -19,8 +20,8 @@ return &OrderRepository{db: db}
 // CreateAuditLog creates a new audit log entry in the database
 func (r *OrderRepository) CreateAuditLog(log models.AuditLog) error {
 	_, err := r.db.Exec(
-		"INSERT INTO audit_logs (id, service_name, action, user_id, timestamp, details) VALUES ($1, $2, $3, $4, $5, $6)",
-		log.ID, log.ServiceName, log.Action, log.CustomerID, log.Timestamp, log.Details,
+		"INSERT INTO audit_logs (id, service_name, caller, action, user_id, timestamp, details) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		log.ID, log.ServiceName, log.Caller, log.Action, log.CustomerID, log.Timestamp, log.Details,
 	)
 	return err
 }
@@ -37,7 +38,17 @@ defer tx.Rollback()
 // Insert order
 _, err = tx.Exec(
 "INSERT INTO orders (id, user_id, status, total_amount, shipping_address, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-order.ID, order.CustomerID, order.Status, order.TotalAmount, order.ShippingAddress, order.CreatedAt, order.UpdatedAt,
+order.ID, order.CustomerID, order.Status, order.TotalAmount, order.ShippingAddress.String(), order.CreatedAt, order.UpdatedAt,
+)
+if err != nil {
+return err
+}
+
+// Insert the copy of the shipping address
+address := order.ShippingAddress
+_, err = tx.Exec(
+"INSERT INTO order_addresses (order_id, recipient, phone, line1, line2, ward, district, city, province, postal_code, country) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+order.ID, address.Recipient, address.Phone, address.Line1, address.Line2, address.Ward, address.District, address.City, address.Province, address.PostalCode, address.Country,
 )
 if err != nil {
 return err
@@ -46,8 +57,8 @@ return err
 // Insert order items
 for _, item := range order.Items {
 _, err = tx.Exec(
-"INSERT INTO order_items (id, order_id, product_id, quantity, price) VALUES ($1, $2, $3, $4, $5)",
-item.ID, order.ID, item.ProductID, item.Quantity, item.Price,
+"INSERT INTO order_items (id, order_id, product_id, sku_id, quantity, price) VALUES ($1, $2, $3, $4, $5, $6)",
+item.ID, order.ID, item.ProductID, item.SKUID, item.Quantity, item.Price,
 )
 if err != nil {
 return err
@@ -62,14 +73,19 @@ return tx.Commit()
 func (r *OrderRepository) GetOrderByID(id string) (models.Order, error) {
 var order models.Order
 var createdAt, updatedAt time.Time
-var status string
+var status, shippingAddress string
 
 
 // Get order
 err := r.db.QueryRow(
-"SELECT id, user_id, status, total_amount, shipping_address, created_at, updated_at, inventory_locked, payment_processed, shipping_scheduled, failure_reason FROM orders WHERE id = $1",
+"SELECT id, user_id, status, total_amount, shipping_address, created_at, updated_at, inventory_locked, payment_processed, shipping_scheduled, failure_reason, warehouse_id, backordered FROM orders WHERE id = $1",
 id,
-).Scan(&order.ID, &order.CustomerID, &status, &order.TotalAmount, &order.ShippingAddress, &createdAt, &updatedAt, &order.InventoryLocked, &order.PaymentProcessed, &order.ShippingScheduled, &order.FailureReason)
+).Scan(&order.ID, &order.CustomerID, &status, &order.TotalAmount, &shippingAddress, &createdAt, &updatedAt, &order.InventoryLocked, &order.PaymentProcessed, &order.ShippingScheduled, &order.FailureReason, &order.WarehouseID, &order.Backordered)
+if err != nil {
+return order, err
+}
+
+order.ShippingAddress, err = r.getShippingAddress(id, shippingAddress)
 if err != nil {
 return order, err
 }
@@ -80,7 +96,7 @@ order.UpdatedAt = updatedAt
 
 // Get order items
 rows, err := r.db.Query(
-"SELECT id, product_id, quantity, price FROM order_items WHERE order_id = $1",
+"SELECT id, product_id, sku_id, quantity, price FROM order_items WHERE order_id = $1",
 id,
 )
 if err != nil {
@@ -91,7 +107,7 @@ defer rows.Close()
 var items []models.OrderItem
 for rows.Next() {
 var item models.OrderItem
-err := rows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.Price)
+err := rows.Scan(&item.ID, &item.ProductID, &item.SKUID, &item.Quantity, &item.Price)
 if err != nil {
 return order, err
 }
@@ -100,15 +116,90 @@ items = append(items, item)
 }
 
 order.Items = items
+
+order.Allocations, err = r.getAllocations(id)
+if err != nil {
+return order, err
+}
+
 return order, nil
 }
 
+// getAllocations retrieves the warehouses the stock of an order was reserved in
+func (r *OrderRepository) getAllocations(orderID string) ([]models.StockAllocation, error) {
+rows, err := r.db.Query(
+"SELECT id, product_id, sku_id, warehouse_id, quantity, shipped FROM order_allocations WHERE order_id = $1",
+orderID,
+)
+if err != nil {
+return nil, err
+}
+defer rows.Close()
+
+var allocations []models.StockAllocation
+for rows.Next() {
+var allocation models.StockAllocation
+if err := rows.Scan(&allocation.ID, &allocation.ProductID, &allocation.SKUID, &allocation.WarehouseID, &allocation.Quantity, &allocation.Shipped); err != nil {
+return nil, err
+}
+allocations = append(allocations, allocation)
+}
+
+return allocations, rows.Err()
+}
+
+// SaveAllocations stores the fulfilment location of an order and the
+// warehouses its stock was reserved in
+func (r *OrderRepository) SaveAllocations(order models.Order) error {
+// Begin transaction
+tx, err := r.db.Begin()
+if err != nil {
+return err
+}
+defer tx.Rollback()
+
+_, err = tx.Exec("UPDATE orders SET warehouse_id = $1 WHERE id = $2", order.WarehouseID, order.ID)
+if err != nil {
+return err
+}
+
+_, err = tx.Exec("DELETE FROM order_allocations WHERE order_id = $1", order.ID)
+if err != nil {
+return err
+}
+
+for _, allocation := range order.Allocations {
+_, err = tx.Exec(
+"INSERT INTO order_allocations (id, order_id, product_id, sku_id, warehouse_id, quantity, shipped) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+allocation.ID, order.ID, allocation.ProductID, allocation.SKUID, allocation.WarehouseID, allocation.Quantity, allocation.Shipped,
+)
+if err != nil {
+return err
+}
+}
+
+// Commit transaction
+return tx.Commit()
+}
+
 // GetOrders retrieves all orders
 func (r *OrderRepository) GetOrders() ([]models.Order, error) {
-// Get all orders
-rows, err := r.db.Query(
+return r.getOrders(
 "SELECT id, user_id, status, total_amount, shipping_address, created_at, updated_at FROM orders",
 )
+}
+
+// GetOrdersByCustomerID retrieves the orders of a customer
+func (r *OrderRepository) GetOrdersByCustomerID(customerID string) ([]models.Order, error) {
+return r.getOrders(
+"SELECT id, user_id, status, total_amount, shipping_address, created_at, updated_at FROM orders WHERE user_id = $1",
+customerID,
+)
+}
+
+// getOrders retrieves the orders selected by a query, together with their items
+func (r *OrderRepository) getOrders(query string, args ...interface{}) ([]models.Order, error) {
+rows, err := r.db.Query(query, args...)
 if err != nil {
 return nil, err
 }
@@ -117,10 +208,15 @@ defer rows.Close()
 var orders []models.Order
 for rows.Next() {
 var order models.Order
-var status string
+var status, shippingAddress string
 var createdAt, updatedAt time.Time
 
-err := rows.Scan(&order.ID, &order.CustomerID, &status, &order.TotalAmount, &order.ShippingAddress, &createdAt, &updatedAt)
+err := rows.Scan(&order.ID, &order.CustomerID, &status, &order.TotalAmount, &shippingAddress, &createdAt, &updatedAt)
+if err != nil {
+return nil, err
+}
+
+order.ShippingAddress, err = r.getShippingAddress(order.ID, shippingAddress)
 if err != nil {
 return nil, err
 }
@@ -131,7 +227,7 @@ order.UpdatedAt = updatedAt
 
 // Get order items for this order
 itemRows, err := r.db.Query(
-"SELECT id, product_id, quantity, price FROM order_items WHERE order_id = $1",
+"SELECT id, product_id, sku_id, quantity, price FROM order_items WHERE order_id = $1",
 order.ID,
 )
 if err != nil {
@@ -142,7 +238,7 @@ defer itemRows.Close()
 var items []models.OrderItem
 for itemRows.Next() {
 var item models.OrderItem
-err := itemRows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.Price)
+err := itemRows.Scan(&item.ID, &item.ProductID, &item.SKUID, &item.Quantity, &item.Price)
 if err != nil {
 return nil, err
 }
@@ -157,6 +253,21 @@ orders = append(orders, order)
 return orders, nil
 }
 
+// getShippingAddress retrieves the copy of the address an order ships to.
+// Orders placed before addresses were structured only have the address on
+// one line, which is returned as the first line.
+func (r *OrderRepository) getShippingAddress(orderID string, legacy string) (models.Address, error) {
+var address models.Address
+err := r.db.QueryRow(
+"SELECT recipient, phone, line1, line2, ward, district, city, province, postal_code, country FROM order_addresses WHERE order_id = $1",
+orderID,
+).Scan(&address.Recipient, &address.Phone, &address.Line1, &address.Line2, &address.Ward, &address.District, &address.City, &address.Province, &address.PostalCode, &address.Country)
+if err == sql.ErrNoRows {
+return models.Address{Line1: legacy}, nil
+}
+return address, err
+}
+
 // UpdateOrderStatus updates the status of an order
 func (r *OrderRepository) UpdateOrderStatus(id string, status models.OrderStatus) error {
 _, err := r.db.Exec(
@@ -166,6 +277,39 @@ status, time.Now(), id,
 return err
 }
 
+// MarkShippingScheduled records that a shipment was scheduled for an order
+func (r *OrderRepository) MarkShippingScheduled(id string) error {
+_, err := r.db.Exec(
+"UPDATE orders SET shipping_scheduled = $1, updated_at = $2 WHERE id = $3",
+true, time.Now(), id,
+)
+return err
+}
+
+// MarkAllocationsShipped records that a shipment carrying the given
+// allocations of an order was created
+func (r *OrderRepository) MarkAllocationsShipped(orderID string, ids []string) error {
+// Begin transaction
+tx, err := r.db.Begin()
+if err != nil {
+return err
+}
+defer tx.Rollback()
+
+for _, id := range ids {
+_, err = tx.Exec(
+"UPDATE order_allocations SET shipped = $1 WHERE order_id = $2 AND id = $3",
+true, orderID, id,
+)
+if err != nil {
+return err
+}
+}
+
+// Commit transaction
+return tx.Commit()
+}
+
 // UpdateOrder updates an order in the database
 func (r *OrderRepository) UpdateOrder(order models.Order) error {
 // Begin transaction
@@ -177,9 +321,9 @@ defer tx.Rollback()
 
 // Update order
 _, err = tx.Exec(
-"UPDATE orders SET user_id = $1, status = $2, total_amount = $3, shipping_address = $4, updated_at = $5, inventory_locked = $6, payment_processed = $7, shipping_scheduled = $8, failure_reason = $9 WHERE id = $10",
-order.CustomerID, order.Status, order.TotalAmount, order.ShippingAddress, order.UpdatedAt,
-order.InventoryLocked, order.PaymentProcessed, order.ShippingScheduled, order.FailureReason, order.ID,
+"UPDATE orders SET user_id = $1, status = $2, total_amount = $3, shipping_address = $4, updated_at = $5, inventory_locked = $6, payment_processed = $7, shipping_scheduled = $8, failure_reason = $9, warehouse_id = $10, backordered = $11 WHERE id = $12",
+order.CustomerID, order.Status, order.TotalAmount, order.ShippingAddress.String(), order.UpdatedAt,
+order.InventoryLocked, order.PaymentProcessed, order.ShippingScheduled, order.FailureReason, order.WarehouseID, order.Backordered, order.ID,
 )
 if err != nil {
 return err
@@ -188,3 +332,63 @@ return err
 // Commit transaction
 return tx.Commit()
 }
+
+// GetAuditLogsByCustomerID retrieves the audit log entries about a customer
+func (r *OrderRepository) GetAuditLogsByCustomerID(customerID string) ([]models.AuditLog, error) {
+rows, err := r.db.Query(
+"SELECT id, service_name, caller, action, user_id, timestamp, details FROM audit_logs WHERE user_id = $1 ORDER BY timestamp",
+customerID,
+)
+if err != nil {
+return nil, err
+}
+defer rows.Close()
+
+logs := []models.AuditLog{}
+for rows.Next() {
+var entry models.AuditLog
+var details sql.NullString
+err := rows.Scan(&entry.ID, &entry.ServiceName, &entry.Caller, &entry.Action, &entry.CustomerID, &entry.Timestamp, &details)
+if err != nil {
+return nil, err
+}
+entry.Details = details.String
+logs = append(logs, entry)
+}
+
+return logs, rows.Err()
+}
+
+// EraseCustomer removes the shipping addresses from the orders of a customer.
+// The orders themselves are kept, as accounting records have to be. It returns
+// the number of addresses erased and of orders kept.
+func (r *OrderRepository) EraseCustomer(customerID string) (int, int, error) {
+tx, err := r.db.Begin()
+if err != nil {
+return 0, 0, err
+}
+defer tx.Rollback()
+
+result, err := tx.Exec(
+"DELETE FROM order_addresses WHERE order_id IN (SELECT id FROM orders WHERE user_id = $1)",
+customerID,
+)
+if err != nil {
+return 0, 0, err
+}
+erased, err := result.RowsAffected()
+if err != nil {
+return 0, 0, err
+}
+
+result, err = tx.Exec("UPDATE orders SET shipping_address = '' WHERE user_id = $1", customerID)
+if err != nil {
+return 0, 0, err
+}
+retained, err := result.RowsAffected()
+if err != nil {
+return 0, 0, err
+}
+
+return int(erased), int(retained), tx.Commit()
+}
@@ -0,0 +1,253 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect returns a handler that sends the values it gets to a channel
+func collect() (Handler, chan string) {
+	ch := make(chan string, 100)
+	return func(ctx context.Context, key, value []byte) error {
+		ch <- string(value)
+		return nil
+	}, ch
+}
+
+// receive waits for n values from ch
+func receive(t *testing.T, ch chan string, n int) []string {
+	t.Helper()
+	var values []string
+	for len(values) < n {
+		select {
+		case v := <-ch:
+			values = append(values, v)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %v, want %d messages", values, n)
+		}
+	}
+	return values
+}
+
+// nothing checks that ch gets no more values
+func nothing(t *testing.T, ch chan string) {
+	t.Helper()
+	select {
+	case v := <-ch:
+		t.Fatalf("got unexpected message %q", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// publish publishes the values to topic
+func publish(t *testing.T, bus Bus, topic string, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		if err := bus.Publish(context.Background(), topic, nil, []byte(v)); err != nil {
+			t.Fatalf("failed to publish %q: %v", v, err)
+		}
+	}
+}
+
+// equal reports whether two lists of values are the same
+func equal(got, want []string) bool {
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
+
+func TestMemoryBusFansOutToEveryGroup(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	payments, paymentsCh := collect()
+	shipping, shippingCh := collect()
+	bus.Subscribe(ctx, "orders", "payment-service", payments)
+	bus.Subscribe(ctx, "orders", "shipping-service", shipping)
+
+	publish(t, bus, "orders", "a", "b", "c")
+
+	for name, ch := range map[string]chan string{"payment-service": paymentsCh, "shipping-service": shippingCh} {
+		if got := receive(t, ch, 3); !equal(got, []string{"a", "b", "c"}) {
+			t.Errorf("%s got %v, want [a b c] in order", name, got)
+		}
+		nothing(t, ch)
+	}
+}
+
+func TestMemoryBusSplitsMessagesWithinGroup(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, ch := collect()
+	bus.Subscribe(ctx, "orders", "order-service", handler)
+	bus.Subscribe(ctx, "orders", "order-service", handler)
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprint(i))
+	}
+	publish(t, bus, "orders", want...)
+
+	seen := make(map[string]int)
+	for _, v := range receive(t, ch, len(want)) {
+		seen[v]++
+	}
+	nothing(t, ch)
+	for _, v := range want {
+		if seen[v] != 1 {
+			t.Errorf("message %s was handled %d times by the group, want once", v, seen[v])
+		}
+	}
+}
+
+func TestMemoryBusLateGroupReadsFromStart(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	publish(t, bus, "orders", "a", "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, ch := collect()
+	bus.Subscribe(ctx, "orders", "late", handler)
+	publish(t, bus, "orders", "c")
+
+	if got := receive(t, ch, 3); !equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("got %v, want [a b c]", got)
+	}
+}
+
+func TestMemoryBusGroupResumesAtItsOffset(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler, ch := collect()
+	bus.Subscribe(ctx, "orders", "order-service", handler)
+	publish(t, bus, "orders", "a", "b")
+	receive(t, ch, 2)
+
+	// The consumer stops, and messages are published while it is down
+	cancel()
+	publish(t, bus, "orders", "c", "d")
+	nothing(t, ch)
+
+	// Its replacement gets what was published meanwhile, and only that
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	bus.Subscribe(ctx, "orders", "order-service", handler)
+	if got := receive(t, ch, 2); !equal(got, []string{"c", "d"}) {
+		t.Errorf("got %v, want [c d]", got)
+	}
+	nothing(t, ch)
+}
+
+func TestMemoryBusKeepsDeliveringAfterHandlerError(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan string, 10)
+	bus.Subscribe(ctx, "orders", "order-service", func(ctx context.Context, key, value []byte) error {
+		ch <- string(value)
+		if string(value) == "bad" {
+			return errors.New("cannot handle")
+		}
+		return nil
+	})
+
+	publish(t, bus, "orders", "bad", "good")
+
+	if got := receive(t, ch, 2); !equal(got, []string{"bad", "good"}) {
+		t.Errorf("got %v, want [bad good]", got)
+	}
+	nothing(t, ch)
+}
+
+func TestMemoryBusWithoutGroupReadsNewMessages(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	publish(t, bus, "cache", "old")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, firstCh := collect()
+	second, secondCh := collect()
+	bus.Subscribe(ctx, "cache", "", first)
+	bus.Subscribe(ctx, "cache", "", second)
+
+	publish(t, bus, "cache", "new")
+
+	// Every replica sees each message published after it subscribed
+	for _, ch := range []chan string{firstCh, secondCh} {
+		if got := receive(t, ch, 1); !equal(got, []string{"new"}) {
+			t.Errorf("got %v, want [new]", got)
+		}
+		nothing(t, ch)
+	}
+}
+
+func TestMemoryBusKeysDoNotShareMemory(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	key, value := []byte("k1"), []byte("v1")
+	if err := bus.Publish(context.Background(), "orders", key, value); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	key[1], value[1] = '2', '2'
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	done := make(chan struct{})
+	var got string
+	bus.Subscribe(ctx, "orders", "order-service", func(ctx context.Context, key, value []byte) error {
+		mu.Lock()
+		got = string(key) + "=" + string(value)
+		mu.Unlock()
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got != "k1=v1" {
+		t.Errorf("got %s, want k1=v1 as published", got)
+	}
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	bus := NewMemoryBus()
+
+	handler, ch := collect()
+	bus.Subscribe(context.Background(), "orders", "order-service", handler)
+	if err := bus.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	if err := bus.Publish(context.Background(), "orders", nil, []byte("a")); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("publish after close returned %v, want ErrBusClosed", err)
+	}
+	if err := bus.Subscribe(context.Background(), "orders", "other", handler); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("subscribe after close returned %v, want ErrBusClosed", err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Errorf("second close returned %v", err)
+	}
+	nothing(t, ch)
+}
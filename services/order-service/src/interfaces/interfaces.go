@@ -9,9 +9,13 @@ type OrderService interface {
 CreateOrder(req models.CreateOrderRequest) (models.Order, error)
 GetOrderByID(id string) (models.Order, error)
 GetOrders() ([]models.Order, error)
+GetOrdersByCustomerID(customerID string) ([]models.Order, error)
 UpdateOrderStatus(id string, status models.OrderStatus) error
 Compensate(order models.Order, failureReason string) error
 RetryPayment(orderID string, req models.RetryPaymentRequest) (models.Order, error)
+GetCustomerData(customerID string) (models.CustomerData, error)
+EraseCustomer(event models.ErasureEvent) error
+AllocateBackorder(event models.BackorderEvent) error
 }
 
 // OrderProducer defines the interface for order producer
@@ -21,5 +25,6 @@ PublishOrderConfirmed(order models.Order) error
 PublishOrderCancelled(order models.Order) error
 PublishOrderCompleted(order models.Order, trackingNumber string) error
 PublishOrderEvent(event models.OrderEvent) error
+PublishErasureCompleted(event models.ErasureEvent) error
 Close() error
 }
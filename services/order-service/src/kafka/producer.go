@@ -6,36 +6,34 @@ import (
 "log"
 "time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/order-service/config"
+"github.com/online-order-system/order-service/eventbus"
 "github.com/online-order-system/order-service/interfaces"
 "github.com/online-order-system/order-service/models"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-writer *kafka.Writer
+bus          eventbus.Bus
+topic        string
+privacyTopic string
 }
 
 // Ensure Producer implements OrderProducer interface
 var _ interfaces.OrderProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-writer := kafka.NewWriter(kafka.WriterConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    cfg.KafkaTopic,
-Balancer: &kafka.LeastBytes{},
-})
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 return &Producer{
-writer: writer,
+bus:          bus,
+topic:        cfg.KafkaTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-return p.writer.Close()
+return nil
 }
 
 // PublishOrderCreated publishes an order created event
@@ -49,7 +47,8 @@ func (p *Producer) PublishOrderCreated(order models.Order) error {
 		TotalAmount:     order.TotalAmount,
 		Timestamp:       order.CreatedAt.Unix(),
 		Items:           order.Items,
-		ShippingAddress: order.ShippingAddress,
+		ShippingAddress: &order.ShippingAddress,
+		Customer:        order.Customer,
 	}
 
 	return p.publishEvent(event)
@@ -65,7 +64,10 @@ func (p *Producer) PublishOrderConfirmed(order models.Order) error {
 		Status:      order.Status,
 		TotalAmount: order.TotalAmount,
 		Timestamp:   order.UpdatedAt.Unix(),
-		ShippingAddress: order.ShippingAddress, // Add shipping address for notification service
+		ShippingAddress: &order.ShippingAddress, // Add shipping address for notification service
+		Customer:        order.Customer,
+		WarehouseID:     order.WarehouseID,
+		AwaitingStock:   order.AwaitingStock,
 	}
 
 	return p.publishEvent(event)
@@ -80,6 +82,7 @@ CustomerID:  order.CustomerID,
 Status:      order.Status,
 TotalAmount: order.TotalAmount,
 Timestamp:   order.UpdatedAt.Unix(),
+Customer:    order.Customer,
 }
 
 return p.publishEvent(event)
@@ -94,6 +97,8 @@ CustomerID:     order.CustomerID,
 Status:         order.Status,
 TotalAmount:    order.TotalAmount,
 Timestamp:      order.UpdatedAt.Unix(),
+Items:          order.Items, // Inventory-service learns what is bought together from delivered orders
+Customer:       order.Customer,
 }
 
 return p.publishEvent(event)
@@ -104,6 +109,26 @@ func (p *Producer) PublishOrderEvent(event models.OrderEvent) error {
 return p.publishEvent(event)
 }
 
+// PublishErasureCompleted reports to user-service what was erased for an
+// erasure request
+func (p *Producer) PublishErasureCompleted(event models.ErasureEvent) error {
+event.EventType = "user_erasure_completed"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+err = p.bus.Publish(context.Background(), p.privacyTopic, []byte(event.CustomerID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for erasure %s", event.EventType, event.RequestID)
+return nil
+}
+
 // publishEvent publishes an event to Kafka
 func (p *Producer) publishEvent(event models.OrderEvent) error {
 // Marshal event to JSON
@@ -112,15 +137,8 @@ if err != nil {
 return err
 }
 
-// Create message
-msg := kafka.Message{
-Key:   []byte(event.OrderID),
-Value: eventJSON,
-Time:  time.Now(),
-}
-
 // Write message to Kafka
-err = p.writer.WriteMessages(context.Background(), msg)
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.OrderID), eventJSON)
 if err != nil {
 return err
 }
@@ -9,55 +9,36 @@ import (
 "syscall"
 "time"
 
-"github.com/online-order-system/order-service/api"
+"github.com/online-order-system/order-service/app"
 "github.com/online-order-system/order-service/config"
-"github.com/online-order-system/order-service/db"
-"github.com/online-order-system/order-service/kafka"
-"github.com/online-order-system/order-service/service"
+"github.com/online-order-system/order-service/eventbus"
 )
 
 func main() {
 // Load configuration
 cfg := config.LoadConfig()
 
-// Connect to database
-database, err := db.NewDatabase(cfg)
+// Create event bus
+bus, err := eventbus.New(cfg)
 if err != nil {
-log.Fatalf("Failed to connect to database: %v", err)
+log.Fatalf("Failed to create event bus: %v", err)
 }
+defer bus.Close()
 
-// Create tables
-err = database.CreateTables()
+// Create application
+application, err := app.New(cfg, bus)
 if err != nil {
-log.Fatalf("Failed to create tables: %v", err)
+log.Fatalf("Failed to create application: %v", err)
 }
-
-// Create repository
-repository := db.NewOrderRepository(database)
-
-// Create Kafka producer
-producer := kafka.NewProducer(cfg)
-defer producer.Close()
-
-// Create service
-orderService := service.NewOrderService(cfg, repository, producer)
-
-// Set order service instance for direct compensation
-service.SetOrderServiceInstance(orderService)
-
-// Create Kafka consumer
-consumer := kafka.NewConsumer(cfg, orderService)
-paymentConsumer := kafka.NewPaymentConsumer(cfg, orderService)
+defer application.Close()
 
 // Start Kafka consumers
 ctx, cancel := context.WithCancel(context.Background())
 defer cancel()
-consumer.StartConsuming(ctx)
-paymentConsumer.StartConsuming(ctx)
+application.Start(ctx)
 
 // Setup router
-// Use the new router setup
-router := api.SetupRouter(orderService)
+router := application.Router
 
 // Start server
 srv := &http.Server{
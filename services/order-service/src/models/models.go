@@ -1,6 +1,7 @@
 package models
 
 import (
+"strings"
 "time"
 )
 
@@ -23,7 +24,7 @@ ID                string       `json:"id"`
 CustomerID        string       `json:"customer_id"`
 Status            OrderStatus  `json:"status"`
 TotalAmount       float64      `json:"total_amount"`
-ShippingAddress   string       `json:"shipping_address"`
+ShippingAddress   Address      `json:"shipping_address"`
 Items             []OrderItem  `json:"items,omitempty"`
 CreatedAt         time.Time    `json:"created_at"`
 UpdatedAt         time.Time    `json:"updated_at"`
@@ -31,22 +32,106 @@ InventoryLocked   bool         `json:"inventory_locked,omitempty"`
 PaymentProcessed  bool         `json:"payment_processed,omitempty"`
 ShippingScheduled bool         `json:"shipping_scheduled,omitempty"`
 FailureReason     string       `json:"failure_reason,omitempty"`
+WarehouseID       string       `json:"warehouse_id,omitempty"` // Fulfilment location picked by inventory-service
+Allocations       []StockAllocation `json:"allocations,omitempty"`
+Backordered       bool              `json:"backordered,omitempty"` // Some items were accepted without stock
+Waiting           []WaitingItem     `json:"waiting,omitempty"` // Backordered and pre-ordered units not allocated yet
+AwaitingStock     bool              `json:"-"` // Shipping waits until enough waiting units are allocated
+Customer          *CustomerContact `json:"-"` // Looked up in user-service, not stored with the order
 }
 
-// OrderItem represents an item in an order
+// WaitingItem is the part of an order item waiting for stock. Backorderable
+// and pre-order products are accepted without stock and allocated first come,
+// first served when stock is received.
+type WaitingItem struct {
+ProductID    string     `json:"product_id"`
+SKUID        string     `json:"sku_id,omitempty"`
+Quantity     int        `json:"quantity"`
+Availability string     `json:"availability,omitempty"`
+ExpectedAt   *time.Time `json:"expected_at,omitempty"` // When a pre-order product is expected
+}
+
+// BackorderEvent is published by inventory-service when waiting units of an
+// order are allocated
+type BackorderEvent struct {
+EventType   string            `json:"event_type"`
+OrderID     string            `json:"order_id"`
+ProductID   string            `json:"product_id"`
+SKUID       string            `json:"sku_id,omitempty"`
+Allocations []StockAllocation `json:"allocations"`
+Remaining   int               `json:"remaining"` // Units of the order still waiting
+Timestamp   int64             `json:"timestamp"`
+}
+
+// StockAllocation is the part of an order item reserved in one warehouse.
+// Orders are split across warehouses when no single one has everything.
+type StockAllocation struct {
+ID          string `json:"id,omitempty"` // Given by order-service when it records the allocation
+ProductID   string `json:"product_id"`
+SKUID       string `json:"sku_id,omitempty"`
+WarehouseID string `json:"warehouse_id"`
+Quantity    int    `json:"quantity"`
+Shipped     bool   `json:"shipped,omitempty"` // A shipment carrying the units was created
+}
+
+// CustomerContact is what services need to contact a customer. It is looked
+// up in user-service and carried in events, so the services consuming them
+// don't have to look it up again.
+type CustomerContact struct {
+ID     string `json:"id"`
+Email  string `json:"email"`
+Name   string `json:"name"`
+Phone  string `json:"phone,omitempty"`
+Locale string `json:"locale"`
+}
+
+// Address is the copy of a customer address an order ships to. It is taken
+// from the address book in user-service when the order is placed, so later
+// changes to the address book don't change the order.
+type Address struct {
+Recipient  string `json:"recipient"`
+Phone      string `json:"phone"`
+Line1      string `json:"line1"`
+Line2      string `json:"line2,omitempty"`
+Ward       string `json:"ward,omitempty"`
+District   string `json:"district,omitempty"`
+City       string `json:"city,omitempty"`
+Province   string `json:"province,omitempty"`
+PostalCode string `json:"postal_code,omitempty"`
+Country    string `json:"country"`
+}
+
+// String formats an address on one line
+func (a Address) String() string {
+var parts []string
+for _, part := range []string{a.Line1, a.Line2, a.Ward, a.District, a.City, a.Province, a.PostalCode, a.Country} {
+if part != "" {
+parts = append(parts, part)
+}
+}
+return strings.Join(parts, ", ")
+}
+
+// OrderItem represents an item in an order. Products sold in variants are
+// ordered by SKU. Price is set from inventory-service when the order is
+// created, the price in the request is ignored.
 type OrderItem struct {
 ID        string  `json:"id"`
 OrderID   string  `json:"order_id"`
 ProductID string  `json:"product_id"`
+SKUID     string  `json:"sku_id,omitempty"`
 Quantity  int     `json:"quantity"`
 Price     float64 `json:"price"`
 }
 
-// CreateOrderRequest represents a request to create a new order
+// CreateOrderRequest represents a request to create a new order. AddressID
+// picks the address from the address book of the customer; without it the
+// order ships to the default shipping address.
 type CreateOrderRequest struct {
-CustomerID      string      `json:"customer_id" binding:"required"`
-Items           []OrderItem `json:"items" binding:"required"`
-ShippingAddress string      `json:"shipping_address" binding:"required"`
+CustomerID string      `json:"customer_id" binding:"required"`
+Items      []OrderItem `json:"items" binding:"required"`
+AddressID  string      `json:"address_id"`
+Caller     string      `json:"-"` // Set by the handler for audit logs
 }
 
 // UpdateOrderStatusRequest represents a request to update an order's status
@@ -64,13 +149,17 @@ type OrderEvent struct {
 	Timestamp       int64       `json:"timestamp"`
 	Items           []OrderItem `json:"items,omitempty"`
 	FailureReason   string      `json:"failure_reason,omitempty"`
-	ShippingAddress string      `json:"shipping_address,omitempty"`
+	ShippingAddress *Address    `json:"shipping_address,omitempty"`
+	Customer        *CustomerContact `json:"customer,omitempty"`
+	WarehouseID     string      `json:"warehouse_id,omitempty"` // Fulfilment location, on order_confirmed
+	AwaitingStock   bool        `json:"awaiting_stock,omitempty"` // Shipping waits for backordered stock, on order_confirmed
 }
 
 // InventoryCheckRequest represents a request to check inventory
 type InventoryCheckRequest struct {
 Items []struct {
 ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
 Quantity  int    `json:"quantity"`
 } `json:"items"`
 }
@@ -80,21 +169,55 @@ type InventoryCheckResponse struct {
 Available       bool `json:"available"`
 UnavailableItems []struct {
 ProductID   string `json:"product_id"`
+SKUID       string `json:"sku_id,omitempty"`
+ProductName string `json:"product_name"`
+Requested   int    `json:"requested"`
+Available   int    `json:"available"`
+} `json:"unavailable_items,omitempty"`
+}
+
+// InventoryReserveRequest represents a request to reserve the stock of an
+// order. Inventory-service uses the shipping address to pick the warehouse
+// nearest to it.
+type InventoryReserveRequest struct {
+OrderID string `json:"order_id"`
+Items   []struct {
+ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
+Quantity  int    `json:"quantity"`
+} `json:"items"`
+ShippingAddress Address `json:"shipping_address"`
+}
+
+// InventoryReservation represents the stock inventory-service reserved for an
+// order. WarehouseID is the fulfilment location.
+type InventoryReservation struct {
+Available        bool              `json:"available"`
+WarehouseID      string            `json:"warehouse_id"`
+Allocations      []StockAllocation `json:"allocations"`
+Waiting          []WaitingItem     `json:"waiting,omitempty"`
+UnavailableItems []struct {
+ProductID   string `json:"product_id"`
+SKUID       string `json:"sku_id,omitempty"`
 ProductName string `json:"product_name"`
 Requested   int    `json:"requested"`
 Available   int    `json:"available"`
 } `json:"unavailable_items,omitempty"`
 }
 
-// InventoryRestoreRequest represents a request to restore inventory
+// InventoryRestoreRequest represents a request to restore inventory. With an
+// order ID the stock goes back to the warehouses it was reserved in.
 type InventoryRestoreRequest struct {
+OrderID   string `json:"order_id,omitempty"`
 ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
 Quantity  int    `json:"quantity"`
 }
 
 // CreatePaymentRequest represents a request to create a payment
 type CreatePaymentRequest struct {
 OrderID       string  `json:"order_id"`
+CustomerID    string  `json:"customer_id,omitempty"`
 Amount        float64 `json:"amount"`
 PaymentMethod string  `json:"payment_method"`
 CardNumber    string  `json:"card_number,omitempty"`
@@ -118,9 +241,21 @@ Amount  float64 `json:"amount"`
 
 // CreateShipmentRequest represents a request to create a shipment
 type CreateShipmentRequest struct {
-OrderID         string `json:"order_id"`
-ShippingAddress string `json:"shipping_address"`
-Carrier         string `json:"carrier,omitempty"`
+OrderID         string  `json:"order_id"`
+ShippingAddress Address `json:"shipping_address"`
+Carrier         string  `json:"carrier,omitempty"`
+CustomerID      string  `json:"customer_id,omitempty"`
+Customer        *CustomerContact `json:"customer,omitempty"`
+WarehouseID     string  `json:"warehouse_id,omitempty"` // Warehouse the shipment leaves from
+Items           []ShipmentItem `json:"items,omitempty"` // What the shipment carries, the whole order when empty
+Reference       string  `json:"reference,omitempty"` // Retrying a request with the same reference creates no second shipment
+}
+
+// ShipmentItem is the part of an order item a shipment carries
+type ShipmentItem struct {
+ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
+Quantity  int    `json:"quantity"`
 }
 
 // RecommendationResponse represents a response from recommendation service
@@ -136,10 +271,19 @@ Description string    `json:"description"`
 CategoryID  string    `json:"category_id"`
 Price       float64   `json:"price"`
 Tags        []string  `json:"tags,omitempty"`
+SKUs        []SKU     `json:"skus,omitempty"` // Variants of products sold in variants
 CreatedAt   time.Time `json:"created_at"`
 UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// SKU is a variant of a product, such as one size and colour
+type SKU struct {
+ID        string  `json:"id"`
+Name      string  `json:"name"`
+Price     float64 `json:"price"`
+Available bool    `json:"available"`
+}
+
 // CreateNotificationRequest represents a request to create a notification
 type CreateNotificationRequest struct {
 CustomerID string `json:"customer_id"`
@@ -157,6 +301,7 @@ CardNumber    string `json:"card_number,omitempty"`
 ExpiryMonth   string `json:"expiry_month,omitempty"`
 ExpiryYear    string `json:"expiry_year,omitempty"`
 CVV           string `json:"cvv,omitempty"`
+Caller        string `json:"-"` // Set by the handler for audit logs
 }
 
 // AuditLogAction represents the action type for audit logs
@@ -174,8 +319,41 @@ AuditLogActionShipmentUpdate AuditLogAction = "shipment_updated"
 type AuditLog struct {
 ID          string        `json:"id"`
 ServiceName string        `json:"service_name"`
+Caller      string        `json:"caller"` // Authenticated caller of the request that led to the entry
 Action      AuditLogAction `json:"action"`
 CustomerID  string        `json:"customer_id"`
 Timestamp   time.Time     `json:"timestamp"`
 Details     string        `json:"details"`
 }
+
+// CustomerData is the personal data order-service holds about a customer, as
+// it appears in a data export
+type CustomerData struct {
+CustomerID string     `json:"customer_id"`
+Orders     []Order    `json:"orders"`
+AuditLogs  []AuditLog `json:"audit_logs"`
+}
+
+// Statuses a service reports for an erasure
+const (
+ErasureCompleted = "completed"
+ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+EventType  string `json:"event_type"`
+RequestID  string `json:"request_id"`
+CustomerID string `json:"customer_id"`
+Email      string `json:"email,omitempty"`
+Timestamp  int64  `json:"timestamp"`
+
+// Set on user_erasure_completed events
+Service  string `json:"service,omitempty"`
+Status   string `json:"status,omitempty"`
+Erased   int    `json:"erased,omitempty"`
+Retained int    `json:"retained,omitempty"`
+Note     string `json:"note,omitempty"`
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-order-system/order-service/models"
+)
+
+// waitingItems returns the ordered units not allocated to a warehouse yet
+func waitingItems(order models.Order) []models.WaitingItem {
+	type stockItem struct{ productID, skuID string }
+	allocated := make(map[stockItem]int)
+	for _, allocation := range order.Allocations {
+		allocated[stockItem{allocation.ProductID, allocation.SKUID}] += allocation.Quantity
+	}
+
+	var waiting []models.WaitingItem
+	for _, item := range order.Items {
+		key := stockItem{item.ProductID, item.SKUID}
+		units := min(item.Quantity, allocated[key])
+		allocated[key] -= units
+		if item.Quantity > units {
+			waiting = append(waiting, models.WaitingItem{
+				ProductID: item.ProductID,
+				SKUID:     item.SKUID,
+				Quantity:  item.Quantity - units,
+			})
+		}
+	}
+	return waiting
+}
+
+// readyToShip reports whether enough of an order is allocated to ship it.
+// Orders without backordered items always are; backordered orders are once
+// every unit is allocated, or PARTIAL_SHIPMENT_PERCENT of them.
+func (s *OrderService) readyToShip(order models.Order) bool {
+	if !order.Backordered {
+		return true
+	}
+
+	ordered, waiting := 0, 0
+	for _, item := range order.Items {
+		ordered += item.Quantity
+	}
+	for _, item := range waitingItems(order) {
+		waiting += item.Quantity
+	}
+	if waiting == 0 {
+		return true
+	}
+
+	allocated := ordered - waiting
+	return allocated > 0 && s.config.PartialShipmentPercent < 100 &&
+		allocated*100 >= s.config.PartialShipmentPercent*ordered
+}
+
+// AllocateBackorder records waiting units of an order inventory-service has
+// allocated. A confirmed order is shipped once enough of it is allocated, and
+// the rest of a partially shipped order once everything is.
+func (s *OrderService) AllocateBackorder(event models.BackorderEvent) error {
+	order, err := s.repository.GetOrderByID(event.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status == models.OrderStatusFailed || order.Status == models.OrderStatusCancelled {
+		log.Printf("Ignoring allocation of backordered stock for %s order %s", order.Status, order.ID)
+		return nil
+	}
+
+	waiting, units := 0, 0
+	for _, item := range waitingItems(order) {
+		if item.ProductID == event.ProductID && item.SKUID == event.SKUID {
+			waiting += item.Quantity
+		}
+	}
+	for _, allocation := range event.Allocations {
+		units += allocation.Quantity
+	}
+	if units == 0 || units > waiting {
+		// Already recorded, events can be delivered more than once
+		log.Printf("Allocation of %d units of product %s already recorded for order %s", units, event.ProductID, order.ID)
+		return nil
+	}
+
+	wasComplete := len(waitingItems(order)) == 0
+	order.Allocations = append(order.Allocations, event.Allocations...)
+	withAllocationIDs(order.Allocations)
+	if order.WarehouseID == "" {
+		order.WarehouseID = event.Allocations[0].WarehouseID
+	}
+	err = s.repository.SaveAllocations(order)
+	if err != nil {
+		return fmt.Errorf("failed to save allocations: %w", err)
+	}
+	log.Printf("Allocated %d backordered units of product %s to order %s, %d units still waiting",
+		units, event.ProductID, order.ID, event.Remaining)
+
+	// Unpaid orders are shipped when they are confirmed
+	if order.Status != models.OrderStatusConfirmed {
+		return nil
+	}
+
+	complete := len(waitingItems(order)) == 0
+	var details string
+	switch {
+	case !order.ShippingScheduled && s.readyToShip(order):
+		details = fmt.Sprintf("Shipping scheduled for backordered order ID: %s", order.ID)
+	case order.ShippingScheduled && !wasComplete && complete:
+		details = fmt.Sprintf("Shipping scheduled for the rest of backordered order ID: %s", order.ID)
+	default:
+		return nil
+	}
+
+	s.withCustomer(&order)
+	err = s.scheduleShipping(order)
+	if err != nil {
+		details = fmt.Sprintf("Failed to schedule shipping for backordered order ID: %s, Error: %v", order.ID, err)
+	}
+
+	auditLog := models.AuditLog{
+		ID:          uuid.New().String(),
+		ServiceName: "order-service",
+		Action:      models.AuditLogActionShipmentUpdate,
+		CustomerID:  order.CustomerID,
+		Timestamp:   time.Now(),
+		Details:     details,
+	}
+
+	s.repository.CreateAuditLog(auditLog)
+
+	// The allocations are recorded either way, so retrying wouldn't help
+	return nil
+}
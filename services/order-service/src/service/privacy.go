@@ -0,0 +1,54 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/order-service/models"
+)
+
+// orderServiceName names order-service in erasure reports
+const orderServiceName = "order-service"
+
+// GetCustomerData gathers the personal data order-service holds about a
+// customer for a data export
+func (s *OrderService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	orders, err := s.repository.GetOrdersByCustomerID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+
+	auditLogs, err := s.repository.GetAuditLogsByCustomerID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+
+	return models.CustomerData{CustomerID: customerID, Orders: orders, AuditLogs: auditLogs}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Orders are kept for accounting with their
+// shipping addresses removed; audit logs only hold IDs and amounts.
+func (s *OrderService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    orderServiceName,
+		Status:     models.ErasureCompleted,
+		Note:       "orders kept for accounting without shipping addresses",
+	}
+
+	// Don't keep contact details of the customer around in the cache either
+	s.users.Forget(event.CustomerID)
+
+	var err error
+	report.Erased, report.Retained, err = s.repository.EraseCustomer(event.CustomerID)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+
+	return s.producer.PublishErasureCompleted(report)
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/online-order-system/order-service/auth"
 	"github.com/online-order-system/order-service/config"
 	"github.com/online-order-system/order-service/db"
 	"github.com/online-order-system/order-service/interfaces"
@@ -20,6 +22,8 @@ type OrderService struct {
 	repository *db.OrderRepository
 	producer   interfaces.OrderProducer
 	httpClient *utils.HTTPClient
+	tokens     *auth.ServiceTokens
+	users      *UserClient
 }
 
 // Ensure OrderService implements OrderService interface
@@ -27,11 +31,18 @@ var _ interfaces.OrderService = (*OrderService)(nil)
 
 // NewOrderService creates a new order service
 func NewOrderService(cfg *config.Config, repo *db.OrderRepository, producer interfaces.OrderProducer) *OrderService {
+	if cfg.ServiceSecret == "" {
+		log.Printf("SERVICE_SECRET is not set, calls to other services will fail")
+	}
+
+	tokens := auth.NewServiceTokens(cfg.ServiceTokenURL, cfg.ServiceName, cfg.ServiceSecret)
 	return &OrderService{
 		config:     cfg,
 		repository: repo,
 		producer:   producer,
-		httpClient: utils.NewHTTPClient(),
+		httpClient: utils.NewHTTPClient(tokens),
+		tokens:     tokens,
+		users:      NewUserClient(cfg.UserServiceURL, tokens, cfg.CustomerCacheTTL),
 	}
 }
 
@@ -43,24 +54,50 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 		ID:              uuid.New().String(),
 		CustomerID:      req.CustomerID,
 		Status:          models.OrderStatusCreated,
-		TotalAmount:     calculateTotalAmount(req.Items),
-		ShippingAddress: req.ShippingAddress,
 		Items:           req.Items,
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
 
-	// Verify customer (Step 2 in design)
-	err := s.verifyCustomer(req.CustomerID)
+	// Verify customer (Step 2 in design) and take a copy of the address the
+	// order ships to
+	customer, address, err := s.verifyCustomer(req.CustomerID, req.AddressID)
 	if err != nil {
 		log.Printf("Failed to verify customer: %v", err)
 		return models.Order{}, fmt.Errorf("failed to verify customer: %v", err)
 	}
+	order.ShippingAddress = address
+	order.Customer = &customer
+
+	// Get cart items (Step 1-2 in design)
+	if len(req.Items) == 0 {
+		cartItems, err := s.getCartItems(req.CustomerID)
+		if err != nil {
+			log.Printf("Failed to get cart items: %v", err)
+			return models.Order{}, fmt.Errorf("failed to get cart items: %v", err)
+		}
+
+		if len(cartItems) == 0 {
+			return models.Order{}, errors.New("cart is empty")
+		}
+
+		order.Items = cartItems
+	}
+
+	// Items are sold at the prices inventory-service has now, whatever the
+	// request or the cart says
+	err = s.priceItems(order.Items)
+	if err != nil {
+		log.Printf("Failed to price items: %v", err)
+		return models.Order{}, err
+	}
+	order.TotalAmount = calculateTotalAmount(order.Items)
 
 	// Create audit log for order creation
 	auditLog := models.AuditLog{
 		ID:          uuid.New().String(),
 		ServiceName: "order-service",
+		Caller:      req.Caller,
 		Action:      models.AuditLogActionCreateOrder,
 		CustomerID:  req.CustomerID,
 		Timestamp:   now,
@@ -73,22 +110,6 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 		// Continue anyway
 	}
 
-	// Get cart items (Step 1-2 in design)
-	if len(req.Items) == 0 {
-		cartItems, err := s.getCartItems(req.CustomerID)
-		if err != nil {
-			log.Printf("Failed to get cart items: %v", err)
-			return models.Order{}, fmt.Errorf("failed to get cart items: %v", err)
-		}
-
-		if len(cartItems) == 0 {
-			return models.Order{}, errors.New("cart is empty")
-		}
-
-		order.Items = cartItems
-		order.TotalAmount = calculateTotalAmount(cartItems)
-	}
-
 	// Set order ID for each item
 	for i := range order.Items {
 		order.Items[i].ID = uuid.New().String()
@@ -101,30 +122,32 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 		return models.Order{}, err
 	}
 
-	// Check inventory
-	available, err := s.checkInventory(req.Items)
+	// Reserve inventory in the warehouses inventory-service picks
+	reservation, err := s.reserveInventory(order)
 	if err != nil {
-		log.Printf("Failed to check inventory: %v", err)
+		log.Printf("Failed to reserve inventory: %v", err)
 
 		// Create audit log for inventory check failure
 		auditLog := models.AuditLog{
 			ID:          uuid.New().String(),
 			ServiceName: "order-service",
+			Caller:      req.Caller,
 			Action:      models.AuditLogActionInventoryError,
 			CustomerID:  order.CustomerID,
 			Timestamp:   time.Now(),
-			Details:     fmt.Sprintf("Failed to check inventory for order ID: %s, Error: %v", order.ID, err),
+			Details:     fmt.Sprintf("Failed to reserve inventory for order ID: %s, Error: %v", order.ID, err),
 		}
 
 		s.repository.CreateAuditLog(auditLog)
 
-		return order, fmt.Errorf("failed to check inventory: %v", err)
+		return order, fmt.Errorf("failed to reserve inventory: %v", err)
 	}
-	if !available {
+	if !reservation.Available {
 		// Create audit log for inventory unavailable
 		auditLog := models.AuditLog{
 			ID:          uuid.New().String(),
 			ServiceName: "order-service",
+			Caller:      req.Caller,
 			Action:      models.AuditLogActionInventoryError,
 			CustomerID:  order.CustomerID,
 			Timestamp:   time.Now(),
@@ -138,12 +161,23 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 		return order, errors.New("some items are not available in inventory")
 	}
 
-	// Mark inventory as locked
+	// Mark inventory as locked and keep where it was reserved
 	order.InventoryLocked = true
+	order.WarehouseID = reservation.WarehouseID
+	order.Allocations = reservation.Allocations
+	withAllocationIDs(order.Allocations)
+	// Backorderable and pre-order items inventory can't reserve yet wait for
+	// stock instead of failing the order
+	order.Waiting = reservation.Waiting
+	order.Backordered = len(reservation.Waiting) > 0
 	err = s.repository.UpdateOrder(order)
 	if err != nil {
 		log.Printf("Failed to update order: %v", err)
 	}
+	err = s.repository.SaveAllocations(order)
+	if err != nil {
+		log.Printf("Failed to save allocations of order %s: %v", order.ID, err)
+	}
 
 	// Process payment
 	log.Printf("Processing payment for order %s", order.ID)
@@ -152,6 +186,7 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 	auditLog2 := models.AuditLog{
 		ID:          uuid.New().String(),
 		ServiceName: "order-service",
+		Caller:      req.Caller,
 		Action:      models.AuditLogActionProcessPayment,
 		CustomerID:  order.CustomerID,
 		Timestamp:   time.Now(),
@@ -172,6 +207,7 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 	auditLog3 := models.AuditLog{
 		ID:          uuid.New().String(),
 		ServiceName: "order-service",
+		Caller:      req.Caller,
 		Action:      models.AuditLogActionProcessPayment,
 		CustomerID:  order.CustomerID,
 		Timestamp:   time.Now(),
@@ -211,7 +247,14 @@ func (s *OrderService) CreateOrder(req models.CreateOrderRequest) (models.Order,
 
 // GetOrderByID retrieves an order by ID
 func (s *OrderService) GetOrderByID(id string) (models.Order, error) {
-	return s.repository.GetOrderByID(id)
+	order, err := s.repository.GetOrderByID(id)
+	if err != nil {
+		return order, err
+	}
+	if order.Backordered {
+		order.Waiting = waitingItems(order)
+	}
+	return order, nil
 }
 
 // GetOrders retrieves all orders
@@ -219,6 +262,11 @@ func (s *OrderService) GetOrders() ([]models.Order, error) {
 	return s.repository.GetOrders()
 }
 
+// GetOrdersByCustomerID retrieves the orders of a customer
+func (s *OrderService) GetOrdersByCustomerID(customerID string) ([]models.Order, error) {
+	return s.repository.GetOrdersByCustomerID(customerID)
+}
+
 // UpdateOrderStatus updates the status of an order
 func (s *OrderService) UpdateOrderStatus(id string, status models.OrderStatus) error {
 	// Get order
@@ -230,6 +278,7 @@ func (s *OrderService) UpdateOrderStatus(id string, status models.OrderStatus) e
 	// Update status
 	order.Status = status
 	order.UpdatedAt = time.Now()
+	s.withCustomer(&order)
 
 	// Save to database
 	err = s.repository.UpdateOrderStatus(id, status)
@@ -237,8 +286,12 @@ func (s *OrderService) UpdateOrderStatus(id string, status models.OrderStatus) e
 		return err
 	}
 
-	// If order is confirmed, schedule shipping
-	if status == models.OrderStatusConfirmed {
+	// If order is confirmed, schedule shipping, unless it waits for stock.
+	// Shipping is scheduled when enough of the waiting units are allocated.
+	if status == models.OrderStatusConfirmed && !s.readyToShip(order) {
+		log.Printf("Order %s is confirmed, shipping waits for backordered stock", order.ID)
+		order.AwaitingStock = true
+	} else if status == models.OrderStatusConfirmed {
 		err = s.scheduleShipping(order)
 		if err != nil {
 			log.Printf("Failed to schedule shipping: %v", err)
@@ -290,53 +343,64 @@ func (s *OrderService) UpdateOrderStatus(id string, status models.OrderStatus) e
 	return nil
 }
 
-// checkInventory checks if all items are available in inventory
-func (s *OrderService) checkInventory(items []models.OrderItem) (bool, error) {
+// reserveInventory reserves the items of an order in inventory. Inventory
+// picks the warehouses by its fulfilment strategy and reports which items
+// are unavailable when it can't reserve them all.
+func (s *OrderService) reserveInventory(order models.Order) (models.InventoryReservation, error) {
 	// Prepare request
 	var checkItems []struct {
 		ProductID string `json:"product_id"`
+		SKUID     string `json:"sku_id,omitempty"`
 		Quantity  int    `json:"quantity"`
 	}
-	for _, item := range items {
+	for _, item := range order.Items {
 		checkItems = append(checkItems, struct {
 			ProductID string `json:"product_id"`
+			SKUID     string `json:"sku_id,omitempty"`
 			Quantity  int    `json:"quantity"`
 		}{
 			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
 			Quantity:  item.Quantity,
 		})
 	}
-	checkRequest := models.InventoryCheckRequest{
-		Items: checkItems,
+	reserveRequest := models.InventoryReserveRequest{
+		OrderID:         order.ID,
+		Items:           checkItems,
+		ShippingAddress: order.ShippingAddress,
 	}
 
-	// Send request to inventory service with timeout and retry (2 retries as per design)
-	var checkResponse models.InventoryCheckResponse
-	inventoryClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	// Send request to inventory service with timeout and retry (2 retries as per design).
+	// Reserving is idempotent, so a retry doesn't reserve twice.
+	var checkResponse models.InventoryReservation
+	inventoryClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 	err := inventoryClient.Post(
-		fmt.Sprintf("%s/inventory/check", s.config.InventoryServiceURL),
-		checkRequest,
+		fmt.Sprintf("%s/inventory/reserve", s.config.InventoryServiceURL),
+		reserveRequest,
 		&checkResponse,
 	)
 	if err != nil {
-		log.Printf("Error checking inventory: %v", err)
+		log.Printf("Error reserving inventory: %v", err)
 		// Check if error is a client error (4xx)
 		if err.Error() == "client error: 404" {
-			return false, fmt.Errorf("one or more products not found")
+			return models.InventoryReservation{}, fmt.Errorf("one or more products not found")
+		}
+		if err.Error() == "client error: 400" {
+			return models.InventoryReservation{}, fmt.Errorf("one or more items are invalid, products sold in variants are ordered by SKU")
 		}
-		return false, err
+		return models.InventoryReservation{}, err
 	}
 
 	// If items are not available, get recommendations and send notification
 	if !checkResponse.Available && len(checkResponse.UnavailableItems) > 0 {
-		// Get customer information
-		// For demo purposes, we'll use a placeholder email
-		customerEmail := "customer@example.com"
-
 		// For each unavailable item, get recommendations and send notification
 		for _, item := range checkResponse.UnavailableItems {
-			// Get recommendations
-			recommendations, err := s.getRecommendations(item.ProductID)
+			// Get recommendations, for the SKU when one was ordered
+			productID := item.ProductID
+			if item.SKUID != "" {
+				productID = item.SKUID
+			}
+			recommendations, err := s.getRecommendations(productID)
 			if err != nil {
 				log.Printf("Failed to get recommendations for product %s: %v", item.ProductID, err)
 				continue
@@ -352,25 +416,31 @@ func (s *OrderService) checkInventory(items []models.OrderItem) (bool, error) {
 					break
 				}
 				content += fmt.Sprintf("- %s: $%.2f\n", product.Name, product.Price)
+				for _, sku := range product.SKUs {
+					if sku.Available {
+						content += fmt.Sprintf("  - %s: $%.2f\n", sku.Name, sku.Price)
+					}
+				}
 			}
 
 			// Send notification
-			err = s.sendNotification(item.ProductID, customerEmail, content)
+			err = s.sendNotification(order, "LOW_INVENTORY", "Product Out of Stock - Alternatives Available", content)
 			if err != nil {
 				log.Printf("Failed to send notification for product %s: %v", item.ProductID, err)
 			}
 		}
 	}
 
-	return checkResponse.Available, nil
+	return checkResponse, nil
 }
 
-// getRecommendations gets product recommendations from the recommendation service
+// getRecommendations gets product recommendations from the recommendation service,
+// the products customers who bought the product also bought
 func (s *OrderService) getRecommendations(productID string) (models.RecommendationResponse, error) {
 	// Send request to recommendation service with timeout and retry
 	var recommendationResponse models.RecommendationResponse
 	err := s.httpClient.Get(
-		fmt.Sprintf("%s/recommendations/products/%s", s.config.RecommendationServiceURL, productID),
+		fmt.Sprintf("%s/recommendations/product/%s?kind=also_bought&limit=3", s.config.RecommendationServiceURL, productID),
 		&recommendationResponse,
 	)
 	if err != nil {
@@ -381,16 +451,20 @@ func (s *OrderService) getRecommendations(productID string) (models.Recommendati
 	return recommendationResponse, nil
 }
 
-// sendNotification sends a notification to the customer
-func (s *OrderService) sendNotification(productID string, customerEmail string, content string) error {
+// sendNotification sends a notification to the customer of an order
+func (s *OrderService) sendNotification(order models.Order, notificationType string, subject string, content string) error {
+	if order.Customer == nil {
+		return fmt.Errorf("no contact details for customer %s", order.CustomerID)
+	}
+
 	// Prepare request
 	notificationRequest := models.CreateNotificationRequest{
-		CustomerID: productID, // Using productID as CustomerID for demo purposes
-		Type:       "LOW_INVENTORY",
+		CustomerID: order.CustomerID,
+		Type:       notificationType,
 		Channel:    "EMAIL",
-		Subject:    "Product Out of Stock - Alternatives Available",
+		Subject:    subject,
 		Content:    content,
-		Recipient:  customerEmail,
+		Recipient:  order.Customer.Email,
 	}
 
 	// Send request to notification service with timeout and retry
@@ -409,14 +483,12 @@ func (s *OrderService) sendNotification(productID string, customerEmail string,
 
 // processPayment processes payment for an order
 func (s *OrderService) processPayment(order models.Order) error {
-	// Get customer information (in a real app, this would come from the user service)
-	// For now, we'll use placeholder data
-	customerEmail := "customer@example.com"
-	customerName := "Test Customer"
+	customerEmail, customerName := customerDetails(order)
 
 	// Prepare request with more detailed information for Stripe
 	paymentRequest := models.CreatePaymentRequest{
 		OrderID:       order.ID,
+		CustomerID:    order.CustomerID,
 		Amount:        order.TotalAmount,
 		PaymentMethod: "card", // Use "card" as the standard payment method
 		Currency:      "vnd",  // Vietnamese Dong
@@ -436,7 +508,7 @@ func (s *OrderService) processPayment(order models.Order) error {
 		StripeClientSecret string `json:"stripe_client_secret,omitempty"`
 	}
 
-	paymentClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	paymentClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 	err := paymentClient.Post(
 		fmt.Sprintf("%s/payments", s.config.PaymentServiceURL),
 		paymentRequest,
@@ -456,43 +528,63 @@ func (s *OrderService) processPayment(order models.Order) error {
 	return nil
 }
 
-// verifyCustomer verifies that a customer exists and is valid
-func (s *OrderService) verifyCustomer(customerID string) error {
+// verifyCustomer verifies that a customer exists and is valid, and returns
+// their contact details and the address from their address book the order
+// ships to
+func (s *OrderService) verifyCustomer(customerID string, addressID string) (models.CustomerContact, models.Address, error) {
+	customer, err := s.users.GetCustomer(customerID)
+	if errors.Is(err, ErrCustomerNotFound) {
+		return models.CustomerContact{}, models.Address{}, errors.New("customer does not exist")
+	}
+	if err != nil {
+		log.Printf("Error getting customer: %v", err)
+		return models.CustomerContact{}, models.Address{}, err
+	}
+
 	// Send request to user service with timeout and retry
 	var customerResponse struct {
-		Verified bool   `json:"verified"`
-		Message  string `json:"message"`
+		Verified bool            `json:"verified"`
+		Message  string          `json:"message"`
+		Address  *models.Address `json:"address"`
 	}
 
 	// Create a client with 2 retries as per design
-	customerClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	customerClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 
 	// Prepare request body for user verification
 	verifyRequest := struct {
-		ID      string `json:"id"`
-		Email   string `json:"email"`
-		Address string `json:"address,omitempty"`
+		ID        string `json:"id"`
+		Email     string `json:"email"`
+		AddressID string `json:"address_id,omitempty"`
 	}{
-		ID:    customerID,
-		Email: "testuser@example.com", // Sử dụng email của người dùng test
+		ID:        customerID,
+		Email:     customer.Email,
+		AddressID: addressID,
 	}
 
 	// Gọi đúng endpoint /users/verify với phương thức POST
-	err := customerClient.Post(
+	err = customerClient.Post(
 		fmt.Sprintf("%s/users/verify", s.config.UserServiceURL),
 		verifyRequest,
 		&customerResponse,
 	)
 	if err != nil {
 		log.Printf("Error verifying customer: %v", err)
-		return err
+		return models.CustomerContact{}, models.Address{}, err
 	}
 
 	if !customerResponse.Verified {
-		return errors.New("customer does not exist")
+		if customerResponse.Message == "Address not found" {
+			return models.CustomerContact{}, models.Address{}, errors.New("shipping address not found")
+		}
+		return models.CustomerContact{}, models.Address{}, errors.New("customer does not exist")
 	}
 
-	return nil
+	if customerResponse.Address == nil {
+		return models.CustomerContact{}, models.Address{}, errors.New("customer has no shipping address")
+	}
+
+	return customer, *customerResponse.Address, nil
 }
 
 // getCartItems gets the items from a customer's cart
@@ -501,13 +593,14 @@ func (s *OrderService) getCartItems(customerID string) ([]models.OrderItem, erro
 	var cartResponse struct {
 		Items []struct {
 			ProductID string  `json:"product_id"`
+			SKUID     string  `json:"sku_id"`
 			Quantity  int     `json:"quantity"`
 			Price     float64 `json:"price"`
 		} `json:"items"`
 	}
 
 	// Create a client with 2 retries as per design
-	cartClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	cartClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 
 	err := cartClient.Get(
 		fmt.Sprintf("%s/carts/%s", s.config.CartServiceURL, customerID),
@@ -523,6 +616,7 @@ func (s *OrderService) getCartItems(customerID string) ([]models.OrderItem, erro
 	for _, item := range cartResponse.Items {
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
 			Quantity:  item.Quantity,
 			Price:     item.Price,
 		})
@@ -531,10 +625,57 @@ func (s *OrderService) getCartItems(customerID string) ([]models.OrderItem, erro
 	return orderItems, nil
 }
 
+// priceItems sets the price of each item to the price inventory-service sells
+// it at: the sale price of products on sale, and the price of the SKU for
+// items ordered by SKU
+func (s *OrderService) priceItems(items []models.OrderItem) error {
+	products := make(map[string]models.Product)
+	for i := range items {
+		item := &items[i]
+		if item.Quantity <= 0 {
+			return fmt.Errorf("invalid quantity of product %s: it must be at least 1", item.ProductID)
+		}
+
+		product, ok := products[item.ProductID]
+		if !ok {
+			err := s.httpClient.Get(
+				fmt.Sprintf("%s/products/%s", s.config.InventoryServiceURL, item.ProductID),
+				&product,
+			)
+			if err != nil {
+				log.Printf("Error getting product %s: %v", item.ProductID, err)
+				if err.Error() == "client error: 404" {
+					return fmt.Errorf("product %s not found", item.ProductID)
+				}
+				return fmt.Errorf("failed to get price of product %s: %v", item.ProductID, err)
+			}
+			products[item.ProductID] = product
+		}
+
+		if item.SKUID == "" {
+			item.Price = product.Price
+			continue
+		}
+		found := false
+		for _, sku := range product.SKUs {
+			if sku.ID == item.SKUID {
+				item.Price = sku.Price
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("SKU %s of product %s not found", item.SKUID, item.ProductID)
+		}
+	}
+
+	return nil
+}
+
 // clearCart clears a customer's cart after order is processed or compensated
 func (s *OrderService) clearCart(customerID string) error {
 	// Create a client with 2 retries as per design
-	cartClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	cartClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 
 	err := cartClient.Post(
 		fmt.Sprintf("%s/carts/%s/clear", s.config.CartServiceURL, customerID),
@@ -549,31 +690,120 @@ func (s *OrderService) clearCart(customerID string) error {
 	return nil
 }
 
-// scheduleShipping schedules shipping for an order
+// scheduleShipping schedules shipping for the allocated units of an order
+// that haven't been shipped yet, one shipment per warehouse. Each shipment is
+// keyed by the order and one of its allocations, so shipping-service creates
+// it once however often the request is retried.
 func (s *OrderService) scheduleShipping(order models.Order) error {
-	// Prepare request
-	shipmentRequest := models.CreateShipmentRequest{
-		OrderID:         order.ID,
-		ShippingAddress: order.ShippingAddress,
+	// Allocations recorded before they had IDs get one, so the shipments
+	// carrying them can be keyed and recorded
+	if withAllocationIDs(order.Allocations) {
+		err := s.repository.SaveAllocations(order)
+		if err != nil {
+			return fmt.Errorf("failed to save allocations: %w", err)
+		}
 	}
 
 	// Create a special HTTP client with 3 retries for shipments as per design
-	shipmentClient := utils.NewHTTPClientWithOptions(3, 1*time.Second, 5*time.Second)
+	shipmentClient := utils.NewHTTPClientWithOptions(s.tokens, 3, 1*time.Second, 5*time.Second)
 
-	// Send request to shipping service with timeout and retry
-	err := shipmentClient.Post(
-		fmt.Sprintf("%s/shipments", s.config.ShippingServiceURL),
-		shipmentRequest,
-		nil,
-	)
+	for _, shipment := range pendingShipments(order) {
+		// Send request to shipping service with timeout and retry
+		err := shipmentClient.Post(
+			fmt.Sprintf("%s/shipments", s.config.ShippingServiceURL),
+			shipment.request,
+			nil,
+		)
+		if err != nil {
+			log.Printf("Error scheduling shipping: %v", err)
+			return err
+		}
+
+		err = s.repository.MarkAllocationsShipped(order.ID, shipment.allocationIDs)
+		if err != nil {
+			log.Printf("Failed to mark allocations of order %s shipped: %v", order.ID, err)
+			// Continue anyway, scheduling them again returns the same shipment
+		}
+	}
+
+	err := s.repository.MarkShippingScheduled(order.ID)
 	if err != nil {
-		log.Printf("Error scheduling shipping: %v", err)
-		return err
+		log.Printf("Failed to mark shipping scheduled for order %s: %v", order.ID, err)
+		// Continue anyway
 	}
 
 	return nil
 }
 
+// pendingShipment is a shipment to create for an order, with the
+// allocations it carries
+type pendingShipment struct {
+	request       models.CreateShipmentRequest
+	allocationIDs []string
+}
+
+// pendingShipments groups the allocations of an order not shipped yet by
+// warehouse. Orders without allocations are shipped whole from their
+// fulfilment location.
+func pendingShipments(order models.Order) []pendingShipment {
+	newRequest := func(warehouseID string) models.CreateShipmentRequest {
+		return models.CreateShipmentRequest{
+			OrderID:         order.ID,
+			ShippingAddress: order.ShippingAddress,
+			CustomerID:      order.CustomerID,
+			Customer:        order.Customer,
+			WarehouseID:     warehouseID,
+		}
+	}
+
+	if len(order.Allocations) == 0 {
+		request := newRequest(order.WarehouseID)
+		request.Reference = order.ID
+		return []pendingShipment{{request: request}}
+	}
+
+	var shipments []pendingShipment
+	byWarehouse := make(map[string]int)
+	for _, allocation := range order.Allocations {
+		if allocation.Shipped {
+			continue
+		}
+		i, ok := byWarehouse[allocation.WarehouseID]
+		if !ok {
+			i = len(shipments)
+			byWarehouse[allocation.WarehouseID] = i
+			shipments = append(shipments, pendingShipment{request: newRequest(allocation.WarehouseID)})
+		}
+		shipments[i].request.Items = append(shipments[i].request.Items, models.ShipmentItem{
+			ProductID: allocation.ProductID,
+			SKUID:     allocation.SKUID,
+			Quantity:  allocation.Quantity,
+		})
+		shipments[i].allocationIDs = append(shipments[i].allocationIDs, allocation.ID)
+	}
+
+	// The first of the allocations keys the shipment, whatever order they
+	// were loaded in
+	for i := range shipments {
+		first := slices.Min(shipments[i].allocationIDs)
+		shipments[i].request.Reference = order.ID + ":" + first
+	}
+	return shipments
+}
+
+// withAllocationIDs gives the allocations that have none an ID, and reports
+// whether any had none
+func withAllocationIDs(allocations []models.StockAllocation) bool {
+	assigned := false
+	for i := range allocations {
+		if allocations[i].ID == "" {
+			allocations[i].ID = uuid.New().String()
+			assigned = true
+		}
+	}
+	return assigned
+}
+
 // Compensate performs compensation actions when an order fails
 func (s *OrderService) Compensate(order models.Order, failureReason string) error {
 	log.Printf("Compensating for order %s with reason: %s", order.ID, failureReason)
@@ -595,11 +825,13 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 		for _, item := range order.Items {
 			// Call Inventory Service to restore inventory
 			restoreRequest := models.InventoryRestoreRequest{
+				OrderID:   order.ID,
 				ProductID: item.ProductID,
+				SKUID:     item.SKUID,
 				Quantity:  item.Quantity,
 			}
 
-			inventoryClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+			inventoryClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 			err := inventoryClient.Post(
 				fmt.Sprintf("%s/inventory/restore", s.config.InventoryServiceURL),
 				restoreRequest,
@@ -622,7 +854,7 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 			Amount:  order.TotalAmount,
 		}
 
-		paymentClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+		paymentClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 		err := paymentClient.Post(
 			fmt.Sprintf("%s/payments/refund", s.config.PaymentServiceURL),
 			refundRequest,
@@ -635,7 +867,7 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 	}
 
 	// 4. Send notification to customer
-	customerEmail := "customer@example.com" // In a real app, get from Customer Service
+	s.withCustomer(&order)
 
 	var notificationContent string
 	if failureReason == "payment_failed" {
@@ -649,7 +881,7 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 			order.ID, getFailureMessage(failureReason))
 	}
 
-	err = s.sendNotification(order.CustomerID, customerEmail, notificationContent)
+	err = s.sendNotification(order, "ORDER_FAILED", "Order Failed", notificationContent)
 	if err != nil {
 		log.Printf("Failed to send notification for order %s: %v",
 			order.ID, err)
@@ -672,6 +904,7 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 		TotalAmount:   order.TotalAmount,
 		Timestamp:     time.Now().Unix(),
 		FailureReason: failureReason,
+		Customer:      order.Customer,
 	}
 
 	err = s.producer.PublishOrderEvent(orderEvent)
@@ -682,6 +915,31 @@ func (s *OrderService) Compensate(order models.Order, failureReason string) erro
 	return nil
 }
 
+// withCustomer looks up the contact details of the customer of an order in
+// user-service, unless the order already has them. The order goes on without
+// them when user-service can't be reached.
+func (s *OrderService) withCustomer(order *models.Order) {
+	if order.Customer != nil {
+		return
+	}
+
+	customer, err := s.users.GetCustomer(order.CustomerID)
+	if err != nil {
+		log.Printf("Failed to get contact details of customer %s: %v", order.CustomerID, err)
+		return
+	}
+	order.Customer = &customer
+}
+
+// customerDetails returns the email and name of the customer of an order
+// for the payment provider
+func customerDetails(order models.Order) (string, string) {
+	if order.Customer == nil {
+		return "", ""
+	}
+	return order.Customer.Email, order.Customer.Name
+}
+
 // getFailureMessage returns a user-friendly message for a failure reason
 func getFailureMessage(reason string) string {
 	switch reason {
@@ -722,14 +980,13 @@ func (s *OrderService) RetryPayment(orderID string, req models.RetryPaymentReque
 		return models.Order{}, errors.New("order did not fail due to payment issues")
 	}
 
-	// Get customer information (in a real app, this would come from the user service)
-	// For now, we'll use placeholder data
-	customerEmail := "customer@example.com"
-	customerName := "Test Customer"
+	s.withCustomer(&order)
+	customerEmail, customerName := customerDetails(order)
 
 	// Prepare payment request with more detailed information for Stripe
 	paymentRequest := models.CreatePaymentRequest{
 		OrderID:       order.ID,
+		CustomerID:    order.CustomerID,
 		Amount:        order.TotalAmount,
 		PaymentMethod: req.PaymentMethod,
 		CardNumber:    req.CardNumber,
@@ -753,7 +1010,7 @@ func (s *OrderService) RetryPayment(orderID string, req models.RetryPaymentReque
 		StripeClientSecret string `json:"stripe_client_secret,omitempty"`
 	}
 
-	paymentClient := utils.NewHTTPClientWithOptions(2, 100*time.Millisecond, 5*time.Second)
+	paymentClient := utils.NewHTTPClientWithOptions(s.tokens, 2, 100*time.Millisecond, 5*time.Second)
 	err = paymentClient.Post(
 		fmt.Sprintf("%s/payments", s.config.PaymentServiceURL),
 		paymentRequest,
@@ -779,15 +1036,18 @@ func (s *OrderService) RetryPayment(orderID string, req models.RetryPaymentReque
 		return order, fmt.Errorf("failed to update order: %v", err)
 	}
 
-	// Continue with order processing (schedule shipping)
-	err = s.scheduleShipping(order)
-	if err != nil {
+	// Continue with order processing (schedule shipping), unless the order
+	// waits for backordered stock
+	if !s.readyToShip(order) {
+		log.Printf("Order %s is confirmed, shipping waits for backordered stock", order.ID)
+	} else if err = s.scheduleShipping(order); err != nil {
 		log.Printf("Failed to schedule shipping: %v", err)
 
 		// Create audit log for shipping failure
 		auditLog := models.AuditLog{
 			ID:          uuid.New().String(),
 			ServiceName: "order-service",
+			Caller:      req.Caller,
 			Action:      models.AuditLogActionShipmentUpdate,
 			CustomerID:  order.CustomerID,
 			Timestamp:   time.Now(),
@@ -802,6 +1062,7 @@ func (s *OrderService) RetryPayment(orderID string, req models.RetryPaymentReque
 		auditLog := models.AuditLog{
 			ID:          uuid.New().String(),
 			ServiceName: "order-service",
+			Caller:      req.Caller,
 			Action:      models.AuditLogActionShipmentUpdate,
 			CustomerID:  order.CustomerID,
 			Timestamp:   time.Now(),
@@ -812,10 +1073,9 @@ func (s *OrderService) RetryPayment(orderID string, req models.RetryPaymentReque
 	}
 
 	// Send notification to customer
-	// Use the same customerEmail that was used for payment
 	notificationContent := fmt.Sprintf("Your payment for order %s has been successfully processed. Your order is now confirmed.", order.ID)
 
-	err = s.sendNotification(order.CustomerID, customerEmail, notificationContent)
+	err = s.sendNotification(order, "ORDER_CONFIRMED", "Payment Successful", notificationContent)
 	if err != nil {
 		log.Printf("Failed to send notification for order %s: %v", order.ID, err)
 		// Continue anyway
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/online-order-system/order-service/models"
+)
+
+func TestPendingShipments(t *testing.T) {
+	order := models.Order{
+		ID:          "order-1",
+		WarehouseID: "hn",
+		Allocations: []models.StockAllocation{
+			{ID: "c", ProductID: "pan", WarehouseID: "hn", Quantity: 2, Shipped: true},
+			{ID: "e", ProductID: "pot", WarehouseID: "hcm", Quantity: 1},
+			{ID: "b", ProductID: "pan", WarehouseID: "hn", Quantity: 1},
+			{ID: "d", ProductID: "lid", SKUID: "lid-red", WarehouseID: "hn", Quantity: 3},
+		},
+	}
+
+	// Shipped allocations are left out, the rest go out from their warehouse
+	// keyed by their first allocation
+	type shipment struct {
+		warehouse, reference string
+		items                []models.ShipmentItem
+		allocations          []string
+	}
+	var got []shipment
+	for _, pending := range pendingShipments(order) {
+		got = append(got, shipment{pending.request.WarehouseID, pending.request.Reference, pending.request.Items, pending.allocationIDs})
+	}
+	want := []shipment{
+		{"hcm", "order-1:e", []models.ShipmentItem{{ProductID: "pot", Quantity: 1}}, []string{"e"}},
+		{"hn", "order-1:b", []models.ShipmentItem{{ProductID: "pan", Quantity: 1}, {ProductID: "lid", SKUID: "lid-red", Quantity: 3}}, []string{"b", "d"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingShipments = %+v, want %+v", got, want)
+	}
+
+	// Once everything is shipped there is nothing left to ship
+	for i := range order.Allocations {
+		order.Allocations[i].Shipped = true
+	}
+	if pending := pendingShipments(order); len(pending) != 0 {
+		t.Errorf("pendingShipments of a shipped order = %+v, want none", pending)
+	}
+
+	// Orders without allocations are shipped whole from their warehouse
+	order.Allocations = nil
+	pending := pendingShipments(order)
+	if len(pending) != 1 || pending[0].request.WarehouseID != "hn" || pending[0].request.Reference != "order-1" || pending[0].request.Items != nil {
+		t.Errorf("pendingShipments without allocations = %+v, want the whole order from hn", pending)
+	}
+}
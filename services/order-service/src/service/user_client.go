@@ -0,0 +1,107 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/online-order-system/order-service/auth"
+	"github.com/online-order-system/order-service/models"
+)
+
+// ErrCustomerNotFound is returned when user-service doesn't know a customer
+var ErrCustomerNotFound = errors.New("customer not found")
+
+// maxCachedCustomers bounds the number of customers a UserClient keeps
+const maxCachedCustomers = 10000
+
+// UserClient looks up customers in user-service. Contact details rarely
+// change, so they are cached for a while.
+type UserClient struct {
+	baseURL string
+	client  *http.Client
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	customers map[string]cachedCustomer
+}
+
+// cachedCustomer is a customer in the cache of a UserClient
+type cachedCustomer struct {
+	contact   models.CustomerContact
+	expiresAt time.Time
+}
+
+// NewUserClient creates a client for the user-service at baseURL that keeps
+// customers for ttl
+func NewUserClient(baseURL string, tokens auth.TokenSource, ttl time.Duration) *UserClient {
+	return &UserClient{
+		baseURL:   baseURL,
+		client:    auth.NewClient(tokens, 5*time.Second),
+		ttl:       ttl,
+		customers: make(map[string]cachedCustomer),
+	}
+}
+
+// GetCustomer returns the contact details of a customer
+func (c *UserClient) GetCustomer(customerID string) (models.CustomerContact, error) {
+	c.mu.Lock()
+	cached, ok := c.customers[customerID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.contact, nil
+	}
+
+	resp, err := c.client.Get(fmt.Sprintf("%s/users/%s/contact", c.baseURL, url.PathEscape(customerID)))
+	if err != nil {
+		return models.CustomerContact{}, fmt.Errorf("failed to get customer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return models.CustomerContact{}, ErrCustomerNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return models.CustomerContact{}, fmt.Errorf("failed to get customer, status code: %d", resp.StatusCode)
+	}
+
+	var contact models.CustomerContact
+	if err := json.NewDecoder(resp.Body).Decode(&contact); err != nil {
+		return models.CustomerContact{}, fmt.Errorf("failed to decode customer response: %w", err)
+	}
+
+	c.store(customerID, contact)
+	return contact, nil
+}
+
+// Forget drops a customer from the cache, such as when they were erased
+func (c *UserClient) Forget(customerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.customers, customerID)
+}
+
+// store caches a customer, dropping expired customers when the cache is full
+func (c *UserClient) store(customerID string, contact models.CustomerContact) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.customers) >= maxCachedCustomers {
+		for id, cached := range c.customers {
+			if now.After(cached.expiresAt) {
+				delete(c.customers, id)
+			}
+		}
+		// Start over if every customer is still fresh
+		if len(c.customers) >= maxCachedCustomers {
+			c.customers = make(map[string]cachedCustomer)
+		}
+	}
+
+	c.customers[customerID] = cachedCustomer{contact: contact, expiresAt: now.Add(c.ttl)}
+}
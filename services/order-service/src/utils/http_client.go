@@ -11,9 +11,13 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/online-order-system/order-service/auth"
 )
 
-// HTTPClient is a wrapper around http.Client with retry and timeout
+// HTTPClient is a wrapper around http.Client with retry and timeout. Every
+// request carries a service token, as only other services may call the
+// internal routes.
 type HTTPClient struct {
 	client     *http.Client
 	maxRetries int
@@ -22,9 +26,9 @@ type HTTPClient struct {
 }
 
 // NewHTTPClient creates a new HTTPClient with default settings
-func NewHTTPClient() *HTTPClient {
+func NewHTTPClient(tokens auth.TokenSource) *HTTPClient {
 	return &HTTPClient{
-		client:     &http.Client{},
+		client:     auth.NewClient(tokens, 0),
 		maxRetries: 2,
 		retryDelay: 100 * time.Millisecond,
 		timeout:    3 * time.Second,
@@ -32,9 +36,9 @@ func NewHTTPClient() *HTTPClient {
 }
 
 // NewHTTPClientWithOptions creates a new HTTPClient with custom settings
-func NewHTTPClientWithOptions(maxRetries int, retryDelay time.Duration, timeout time.Duration) *HTTPClient {
+func NewHTTPClientWithOptions(tokens auth.TokenSource, maxRetries int, retryDelay time.Duration, timeout time.Duration) *HTTPClient {
 	return &HTTPClient{
-		client:     &http.Client{},
+		client:     auth.NewClient(tokens, 0),
 		maxRetries: maxRetries,
 		retryDelay: retryDelay,
 		timeout:    timeout,
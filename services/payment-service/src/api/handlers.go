@@ -1,14 +1,21 @@
 package api
 
 import (
+"errors"
+"fmt"
 "log"
+"math"
 "net/http"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/payment-service/auth"
 "github.com/online-order-system/payment-service/interfaces"
 "github.com/online-order-system/payment-service/models"
 )
 
+// staffRoles may see the payments of every customer
+var staffRoles = []string{auth.RoleSupport, auth.RoleAdmin}
+
 // Handler handles HTTP requests
 type Handler struct {
 service interfaces.PaymentService
@@ -31,6 +38,31 @@ c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 return
 }
 
+// Payments made by users are checked against the order in order-service:
+// customers pay for their own orders only, and always the order total.
+// Services are trusted with the orders they pay for.
+if claims, ok := auth.ClaimsFromContext(c); ok && !claims.IsService() {
+order, err := h.service.GetOrder(req.OrderID)
+if errors.Is(err, models.ErrOrderNotFound) {
+c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+return
+}
+if err != nil {
+log.Printf("Error getting order %s: %v", req.OrderID, err)
+c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to check the order"})
+return
+}
+if !claims.HasRole(staffRoles...) && order.CustomerID != claims.UserID() {
+c.JSON(http.StatusForbidden, gin.H{"error": "Cannot pay for the order of another customer"})
+return
+}
+if math.Abs(order.TotalAmount-req.Amount) >= 0.005 {
+c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Amount must be the order total %.2f", order.TotalAmount)})
+return
+}
+req.CustomerID = order.CustomerID
+}
+
 log.Printf("Creating payment with request: %+v", req)
 log.Printf("Payment method (raw): '%s'", req.PaymentMethod)
 log.Printf("Payment method length: %d", len(req.PaymentMethod))
@@ -59,7 +91,7 @@ func (h *Handler) GetPaymentByID(c *gin.Context) {
 id := c.Param("id")
 
 payment, err := h.service.GetPaymentByID(id)
-if err != nil {
+if err != nil || !auth.CanAccess(c, payment.CustomerID, staffRoles...) {
 c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 return
 }
@@ -76,7 +108,7 @@ func (h *Handler) GetPaymentByOrderID(c *gin.Context) {
 orderID := c.Param("order_id")
 
 payment, err := h.service.GetPaymentByOrderID(orderID)
-if err != nil {
+if err != nil || !auth.CanAccess(c, payment.CustomerID, staffRoles...) {
 c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 return
 }
@@ -164,7 +196,7 @@ func (h *Handler) ConfirmPayment(c *gin.Context) {
 
 	// Get the payment first
 	payment, err := h.service.GetPaymentByID(id)
-	if err != nil {
+	if err != nil || !auth.CanAccess(c, payment.CustomerID, staffRoles...) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 		return
 	}
@@ -227,6 +259,23 @@ func (h *Handler) TestSuccessfulPayment(c *gin.Context) {
 	c.JSON(http.StatusOK, payment)
 }
 
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export. Card numbers are masked and CVVs left out.
+func (h *Handler) GetCustomerData(c *gin.Context) {
+	data, err := h.service.GetCustomerData(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+		return
+	}
+
+	for i := range data.Payments {
+		data.Payments[i].CardNumber = maskCardNumber(data.Payments[i].CardNumber)
+		data.Payments[i].CVV = ""
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
 // maskCardNumber masks a credit card number, showing only the last 4 digits
 func maskCardNumber(cardNumber string) string {
 	if len(cardNumber) <= 4 {
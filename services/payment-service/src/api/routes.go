@@ -2,17 +2,19 @@ package api
 
 import (
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/payment-service/auth"
 "github.com/online-order-system/payment-service/interfaces"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.PaymentService) *gin.Engine {
+func SetupRouter(service interfaces.PaymentService, verifier *auth.Verifier) *gin.Engine {
 // Create router
 router := gin.Default()
 
 // Add middleware
 router.Use(Logger())
 router.Use(CORS())
+router.Use(auth.Authenticate(verifier))
 
 // Create handler
 handler := NewHandler(service)
@@ -28,31 +30,40 @@ c.JSON(200, gin.H{
 router.StaticFile("/swagger", "./docs/swagger.html")
 router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+// Who may call the routes. Routes open to everyone check in the handler
+// that the caller owns the payment.
+everyone := auth.RequireRole(auth.Everyone...)
+staff := auth.RequireRole(auth.Internal, auth.RoleSupport, auth.RoleAdmin)
+admin := auth.RequireRole(auth.RoleAdmin)
+
 // Payment routes
 payments := router.Group("/payments")
 {
 // Create a new payment
-payments.POST("", handler.CreatePayment)
+payments.POST("", everyone, handler.CreatePayment)
 
 // Get all payments
-payments.GET("", handler.GetPayments)
+payments.GET("", staff, handler.GetPayments)
 
 // Get a specific payment by ID
-payments.GET("/:id", handler.GetPaymentByID)
+payments.GET("/:id", everyone, handler.GetPaymentByID)
 
 // Get a payment by order ID
-payments.GET("/order/:order_id", handler.GetPaymentByOrderID)
+payments.GET("/order/:order_id", everyone, handler.GetPaymentByOrderID)
 
 // Update a payment's status
-payments.PUT("/:id/status", handler.UpdatePaymentStatus)
+payments.PUT("/:id/status", staff, handler.UpdatePaymentStatus)
 
 // Confirm a payment
-payments.POST("/:id/confirm", handler.ConfirmPayment)
+payments.POST("/:id/confirm", everyone, handler.ConfirmPayment)
 
 // Test successful payment with Stripe
-payments.POST("/:id/test-success", handler.TestSuccessfulPayment)
+payments.POST("/:id/test-success", admin, handler.TestSuccessfulPayment)
 }
 
+// Personal data of a customer, gathered by user-service for data exports
+router.GET("/customers/:id/data", auth.RequireRole(auth.Internal), handler.GetCustomerData)
+
 // Stripe webhook route
 // This endpoint receives webhook events from Stripe
 router.POST("/webhooks/stripe", handler.HandleStripeWebhook)
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/payment-service/api"
+	"github.com/online-order-system/payment-service/auth"
+	"github.com/online-order-system/payment-service/config"
+	"github.com/online-order-system/payment-service/db"
+	"github.com/online-order-system/payment-service/eventbus"
+	"github.com/online-order-system/payment-service/kafka"
+	"github.com/online-order-system/payment-service/service"
+)
+
+// App wires together the components of the payment service
+type App struct {
+	Config   *config.Config
+	Service  *service.PaymentService
+	Router   *gin.Engine
+	database *db.Database
+	consumer *kafka.Consumer
+}
+
+// New connects to the database and builds the payment service on top of the given event bus
+func New(cfg *config.Config, bus eventbus.Bus) (*App, error) {
+	// Connect to database
+	database, err := db.NewDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	// Create tables
+	err = database.CreateTables()
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+
+	// Create repository
+	repository := db.NewPaymentRepository(database)
+
+	// Create Kafka producer
+	producer := kafka.NewProducer(cfg, bus)
+
+	// Create service
+	paymentService := service.NewPaymentService(cfg, repository, producer)
+
+	// Verify access tokens with the keys published by user-service
+	verifier := auth.NewVerifier(auth.NewRemoteKeySet(cfg.JWKSURL), cfg.JWTIssuer, cfg.JWTAudience)
+
+	// Only the gateway is trusted to say which client a request came from
+	router := api.SetupRouter(paymentService, verifier)
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+
+	return &App{
+		Config:   cfg,
+		Service:  paymentService,
+		Router:   router,
+		database: database,
+		consumer: kafka.NewConsumer(cfg, bus, paymentService),
+	}, nil
+}
+
+// Start starts the Kafka consumers. They stop when ctx is cancelled.
+func (a *App) Start(ctx context.Context) {
+	a.consumer.StartConsuming(ctx)
+}
+
+// Close closes the database connection
+func (a *App) Close() error {
+	return a.database.Close()
+}
@@ -10,19 +10,29 @@ import (
 _ "github.com/lib/pq"
 )
 
+// Supported database drivers
+const (
+DriverPostgres = "postgres"
+DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 *sql.DB
+Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 // Create connection string
-connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+connStr := cfg.DBDSN
+if connStr == "" {
+connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+}
 
 // Connect to database
-db, err := sql.Open("postgres", connStr)
+db, err := sql.Open(cfg.DBDriver, connStr)
 if err != nil {
 return nil, err
 }
@@ -38,16 +48,19 @@ db.SetMaxOpenConns(25)
 db.SetMaxIdleConns(25)
 db.SetConnMaxLifetime(5 * time.Minute)
 
-log.Println("Connected to PostgreSQL database")
+log.Printf("Connected to %s database", cfg.DBDriver)
 
-return &Database{db}, nil
+return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
 func (db *Database) CreateTables() error {
-// Check if payments table exists
+// Check if payments table exists. The column migrations below rely on
+// information_schema, so SQLite always takes the create path.
 var exists bool
-err := db.QueryRow(`
+var err error
+if db.Driver != DriverSQLite {
+err = db.QueryRow(`
     SELECT EXISTS (
         SELECT FROM information_schema.tables
         WHERE table_schema = 'public'
@@ -58,6 +71,7 @@ if err != nil {
     log.Printf("Error checking if payments table exists: %v", err)
     return err
 }
+}
 
 // If table exists, check if columns need to be altered
 if exists {
@@ -200,6 +214,7 @@ if exists {
     CREATE TABLE IF NOT EXISTS payments (
     id VARCHAR(36) PRIMARY KEY,
     order_id VARCHAR(36) NOT NULL,
+    customer_id VARCHAR(36),
     amount DECIMAL(10, 2) NOT NULL,
     status VARCHAR(20) NOT NULL,
     payment_method VARCHAR(50) NOT NULL,
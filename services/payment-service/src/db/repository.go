@@ -54,15 +54,15 @@ func (r *PaymentRepository) CreatePayment(payment models.Payment) error {
 			card_number, expiry_month, expiry_year, cvv,
 			stripe_payment_id, stripe_client_secret, currency, description,
 			customer_email, customer_name, receipt_url, error_message,
-			created_at, updated_at
+			created_at, updated_at, customer_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
 		)`,
 		payment.ID, payment.OrderID, payment.Amount, payment.Status, payment.PaymentMethod,
 		encryptedCardNumber, payment.ExpiryMonth, payment.ExpiryYear, encryptedCVV,
 		payment.StripePaymentID, payment.StripeClientSecret, payment.Currency, payment.Description,
 		payment.CustomerEmail, payment.CustomerName, payment.ReceiptURL, payment.ErrorMessage,
-		payment.CreatedAt, payment.UpdatedAt,
+		payment.CreatedAt, payment.UpdatedAt, payment.CustomerID,
 	)
 
 	if err != nil {
@@ -79,6 +79,7 @@ func (r *PaymentRepository) GetPaymentByID(id string) (models.Payment, error) {
 	var encryptedCardNumber, encryptedCVV string
 	var stripePaymentID, stripeClientSecret, currency, description sql.NullString
 	var customerEmail, customerName, receiptURL, errorMessage sql.NullString
+	var customerID sql.NullString
 
 	// Get payment
 	err := r.db.QueryRow(
@@ -87,14 +88,14 @@ func (r *PaymentRepository) GetPaymentByID(id string) (models.Payment, error) {
 			card_number, expiry_month, expiry_year, cvv,
 			stripe_payment_id, stripe_client_secret, currency, description,
 			customer_email, customer_name, receipt_url, error_message,
-			created_at, updated_at
+			created_at, updated_at, customer_id
 		FROM payments WHERE id = $1`,
 		id,
 	).Scan(&payment.ID, &payment.OrderID, &payment.Amount, &status, &payment.PaymentMethod,
 		&encryptedCardNumber, &payment.ExpiryMonth, &payment.ExpiryYear, &encryptedCVV,
 		&stripePaymentID, &stripeClientSecret, &currency, &description,
 		&customerEmail, &customerName, &receiptURL, &errorMessage,
-		&createdAt, &updatedAt)
+		&createdAt, &updatedAt, &customerID)
 	if err != nil {
 		return payment, err
 	}
@@ -159,6 +160,9 @@ func (r *PaymentRepository) GetPaymentByID(id string) (models.Payment, error) {
 	if errorMessage.Valid {
 		payment.ErrorMessage = errorMessage.String
 	}
+	if customerID.Valid {
+		payment.CustomerID = customerID.String
+	}
 
 	payment.Status = models.PaymentStatus(status)
 	payment.CreatedAt = createdAt
@@ -175,6 +179,7 @@ func (r *PaymentRepository) GetPaymentByOrderID(orderID string) (models.Payment,
 	var encryptedCardNumber, encryptedCVV string
 	var stripePaymentID, stripeClientSecret, currency, description sql.NullString
 	var customerEmail, customerName, receiptURL, errorMessage sql.NullString
+	var customerID sql.NullString
 
 	// Get payment
 	err := r.db.QueryRow(
@@ -183,14 +188,14 @@ func (r *PaymentRepository) GetPaymentByOrderID(orderID string) (models.Payment,
 			card_number, expiry_month, expiry_year, cvv,
 			stripe_payment_id, stripe_client_secret, currency, description,
 			customer_email, customer_name, receipt_url, error_message,
-			created_at, updated_at
+			created_at, updated_at, customer_id
 		FROM payments WHERE order_id = $1`,
 		orderID,
 	).Scan(&payment.ID, &payment.OrderID, &payment.Amount, &status, &payment.PaymentMethod,
 		&encryptedCardNumber, &payment.ExpiryMonth, &payment.ExpiryYear, &encryptedCVV,
 		&stripePaymentID, &stripeClientSecret, &currency, &description,
 		&customerEmail, &customerName, &receiptURL, &errorMessage,
-		&createdAt, &updatedAt)
+		&createdAt, &updatedAt, &customerID)
 	if err != nil {
 		return payment, err
 	}
@@ -255,6 +260,9 @@ func (r *PaymentRepository) GetPaymentByOrderID(orderID string) (models.Payment,
 	if errorMessage.Valid {
 		payment.ErrorMessage = errorMessage.String
 	}
+	if customerID.Valid {
+		payment.CustomerID = customerID.String
+	}
 
 	payment.Status = models.PaymentStatus(status)
 	payment.CreatedAt = createdAt
@@ -265,10 +273,22 @@ func (r *PaymentRepository) GetPaymentByOrderID(orderID string) (models.Payment,
 
 // GetPayments retrieves all payments
 func (r *PaymentRepository) GetPayments() ([]models.Payment, error) {
-	// Get all payments
-	rows, err := r.db.Query(
-		"SELECT id, order_id, amount, status, payment_method, card_number, expiry_month, expiry_year, cvv, created_at, updated_at FROM payments",
+	return r.getPayments(
+		"SELECT id, order_id, amount, status, payment_method, card_number, expiry_month, expiry_year, cvv, created_at, updated_at, customer_id FROM payments",
 	)
+}
+
+// GetPaymentsByCustomerID retrieves the payments of a customer
+func (r *PaymentRepository) GetPaymentsByCustomerID(customerID string) ([]models.Payment, error) {
+	return r.getPayments(
+		"SELECT id, order_id, amount, status, payment_method, card_number, expiry_month, expiry_year, cvv, created_at, updated_at, customer_id FROM payments WHERE customer_id = $1",
+		customerID,
+	)
+}
+
+// getPayments retrieves the payments selected by a query
+func (r *PaymentRepository) getPayments(query string, args ...interface{}) ([]models.Payment, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -280,13 +300,15 @@ func (r *PaymentRepository) GetPayments() ([]models.Payment, error) {
 		var status string
 		var createdAt, updatedAt time.Time
 		var encryptedCardNumber, encryptedCVV string
+		var customerID sql.NullString
 
 		err := rows.Scan(&payment.ID, &payment.OrderID, &payment.Amount, &status, &payment.PaymentMethod,
 			&encryptedCardNumber, &payment.ExpiryMonth, &payment.ExpiryYear, &encryptedCVV,
-			&createdAt, &updatedAt)
+			&createdAt, &updatedAt, &customerID)
 		if err != nil {
 			return nil, err
 		}
+		payment.CustomerID = customerID.String
 
 		// Decrypt sensitive payment information
 		var cardNumber string
@@ -333,6 +355,25 @@ func (r *PaymentRepository) GetPayments() ([]models.Payment, error) {
 	return payments, nil
 }
 
+// EraseCustomer removes the card details and contact details from the
+// payments of a customer. Payments made before they carried the customer ID
+// are found by email. The payments themselves are kept, as financial records
+// have to be. It returns the number of payments kept.
+func (r *PaymentRepository) EraseCustomer(customerID string, email string) (int, error) {
+	result, err := r.db.Exec(
+		`UPDATE payments SET card_number = '', expiry_month = '', expiry_year = '', cvv = '',
+			customer_email = NULL, customer_name = NULL, stripe_client_secret = NULL
+		WHERE customer_id = $1 OR ($2 <> '' AND customer_email = $2)`,
+		customerID, email,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
 // UpdatePaymentStatus updates the status of a payment
 func (r *PaymentRepository) UpdatePaymentStatus(id string, status models.PaymentStatus) error {
 	_, err := r.db.Exec(
@@ -0,0 +1,44 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/online-order-system/payment-service/config"
+)
+
+// Handler processes a single message delivered by the bus. It is an alias
+// rather than a named type so Bus implementations stay interchangeable.
+type Handler = func(ctx context.Context, key, value []byte) error
+
+// Bus defines a publish/subscribe event bus with consumer groups.
+// Subscribers sharing a group ID split the messages of a topic between them,
+// while every distinct group receives its own copy of each message.
+// Subscribers with an empty group ID are in no group: each receives every
+// message published after it subscribed, and no offsets are kept for it.
+type Bus interface {
+	Publish(ctx context.Context, topic string, key, value []byte) error
+	Subscribe(ctx context.Context, topic, groupID string, handler Handler) error
+	Close() error
+}
+
+// Supported bus drivers
+const (
+	DriverKafka  = "kafka"
+	DriverMemory = "memory"
+)
+
+// New creates the event bus selected by the EVENT_BUS configuration
+func New(cfg *config.Config) (Bus, error) {
+	switch cfg.EventBus {
+	case "", DriverKafka:
+		log.Println("Using Kafka event bus")
+		return NewKafkaBus(cfg.KafkaBootstrapServers), nil
+	case DriverMemory:
+		log.Println("Using in-memory event bus")
+		return NewMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("unknown event bus driver: %s", cfg.EventBus)
+	}
+}
@@ -0,0 +1,170 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// ErrBusClosed is returned when publishing to a closed bus
+var ErrBusClosed = errors.New("event bus is closed")
+
+// MemoryBus is an in-process Bus for tests and local development.
+// Every topic keeps its full message log, and each consumer group tracks its
+// own offset into it, so a group that subscribes late still sees earlier
+// messages, the same as a new Kafka consumer group reading from the start.
+// Subscribers without a group only see the messages published after they
+// subscribed.
+type MemoryBus struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// memoryMessage is a message stored in a topic log
+type memoryMessage struct {
+	key   []byte
+	value []byte
+}
+
+// memoryTopic holds the message log and consumer groups of a topic.
+// Subscribers without a group each read at an offset of their own.
+type memoryTopic struct {
+	messages   []memoryMessage
+	groups     map[string]*memoryGroup
+	broadcasts []*memoryGroup
+}
+
+// memoryGroup tracks the read offset of a consumer group
+type memoryGroup struct {
+	offset int
+	notify chan struct{}
+}
+
+// Ensure MemoryBus implements Bus interface
+var _ Bus = (*MemoryBus)(nil)
+
+// NewMemoryBus creates a new in-memory event bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics: make(map[string]*memoryTopic),
+	}
+}
+
+// Publish appends a message to the topic log and wakes up its consumer groups
+func (b *MemoryBus) Publish(ctx context.Context, topic string, key, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	t := b.topic(topic)
+	t.messages = append(t.messages, memoryMessage{
+		key:   append([]byte(nil), key...),
+		value: append([]byte(nil), value...),
+	})
+	for _, g := range t.readers() {
+		select {
+		case g.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe starts delivering messages of the topic to handler until ctx is
+// cancelled. Subscribers of the same group compete for messages.
+func (b *MemoryBus) Subscribe(ctx context.Context, topic, groupID string, handler Handler) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBusClosed
+	}
+	t := b.topic(topic)
+	g, ok := t.groups[groupID]
+	if groupID == "" {
+		g = &memoryGroup{offset: len(t.messages), notify: make(chan struct{}, 1)}
+		t.broadcasts = append(t.broadcasts, g)
+	} else if !ok {
+		g = &memoryGroup{notify: make(chan struct{}, 1)}
+		t.groups[groupID] = g
+	}
+	b.wg.Add(1)
+	b.mu.Unlock()
+
+	go func() {
+		defer b.wg.Done()
+		for {
+			// A subscriber that was stopped must not take the messages of
+			// the one that replaces it in the group
+			b.mu.Lock()
+			if b.closed || ctx.Err() != nil {
+				b.mu.Unlock()
+				return
+			}
+			if g.offset < len(t.messages) {
+				msg := t.messages[g.offset]
+				g.offset++
+				b.mu.Unlock()
+
+				if err := handler(ctx, msg.key, msg.value); err != nil {
+					log.Printf("Error handling message from topic %s: %v", topic, err)
+				}
+				continue
+			}
+			b.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.notify:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops delivery to all subscribers
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for _, t := range b.topics {
+		for _, g := range t.readers() {
+			close(g.notify)
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
+
+// topic returns the topic with the given name, creating it if needed.
+// The caller must hold b.mu.
+func (b *MemoryBus) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string]*memoryGroup)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// readers returns the consumer groups and the subscribers without a group of
+// the topic. The caller must hold the lock of the bus.
+func (t *memoryTopic) readers() []*memoryGroup {
+	readers := append([]*memoryGroup(nil), t.broadcasts...)
+	for _, g := range t.groups {
+		readers = append(readers, g)
+	}
+	return readers
+}
@@ -13,6 +13,9 @@ GetPayments() ([]models.Payment, error)
 UpdatePaymentStatus(id string, status models.PaymentStatus) (models.Payment, error)
 HandleStripeWebhook(payload []byte, signature string) error
 ConfirmPayment(paymentID string) (models.Payment, error)
+GetCustomerData(customerID string) (models.CustomerData, error)
+GetOrder(orderID string) (models.OrderSummary, error)
+EraseCustomer(event models.ErasureEvent) error
 }
 
 // PaymentProducer defines the interface for payment producer
@@ -21,5 +24,6 @@ PublishPaymentCreated(payment models.Payment) error
 PublishPaymentSuccessful(payment models.Payment) error
 PublishPaymentFailed(payment models.Payment) error
 PublishPaymentRefunded(payment models.Payment) error
+PublishErasureCompleted(event models.ErasureEvent) error
 Close() error
 }
@@ -4,64 +4,78 @@ import (
 "context"
 "encoding/json"
 "log"
-"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/payment-service/config"
+"github.com/online-order-system/payment-service/eventbus"
 "github.com/online-order-system/payment-service/interfaces"
 "github.com/online-order-system/payment-service/models"
 )
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-reader  *kafka.Reader
-service interfaces.PaymentService
+bus          eventbus.Bus
+service      interfaces.PaymentService
+paymentMode  string
+privacyTopic string
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.PaymentService) *Consumer {
-reader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    "orders", // Listen to orders topic
-GroupID:  "payment-service",
-MinBytes: 10e3, // 10KB
-MaxBytes: 10e6, // 10MB
-})
-
-log.Printf("Payment Service Kafka consumer created and subscribed to topic: orders")
-
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.PaymentService) *Consumer {
 return &Consumer{
-reader:  reader,
-service: service,
+bus:          bus,
+service:      service,
+paymentMode:  cfg.PaymentMode,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
 // StartConsuming starts consuming messages from Kafka
 func (c *Consumer) StartConsuming(ctx context.Context) {
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping Kafka consumer")
-c.reader.Close()
+// Listen to orders topic
+err := c.bus.Subscribe(ctx, "orders", "payment-service", c.processMessage)
+if err != nil {
+log.Printf("Error subscribing to orders topic: %v", err)
 return
-default:
-m, err := c.reader.ReadMessage(ctx)
+}
+
+// Listen to privacy topic
+err = c.bus.Subscribe(ctx, c.privacyTopic, "payment-service", c.processPrivacyMessage)
 if err != nil {
-log.Printf("Error reading message from Kafka: %v", err)
-time.Sleep(1 * time.Second)
-continue
+log.Printf("Error subscribing to privacy topic: %v", err)
+return
+}
+
+log.Printf("Payment Service Kafka consumer subscribed to topics: orders, %s", c.privacyTopic)
+}
+
+// processPrivacyMessage processes a message from the privacy topic
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+var event models.ErasureEvent
+if err := json.Unmarshal(value, &event); err != nil {
+log.Printf("Error unmarshaling message: %v", err)
+return nil
+}
+
+if event.EventType == "user_erasure_requested" {
+log.Printf("Processing erasure %s for customer %s", event.RequestID, event.CustomerID)
+if err := c.service.EraseCustomer(event); err != nil {
+log.Printf("Error reporting erasure %s: %v", event.RequestID, err)
+}
+}
+return nil
 }
 
-log.Printf("Received message from Kafka: %s", string(m.Value))
+// processMessage processes a message from the orders topic
+func (c *Consumer) processMessage(ctx context.Context, key, value []byte) error {
+log.Printf("Received message from Kafka: %s", string(value))
 
 // Process the message
 var event struct {
 EventType string `json:"event_type"`
 }
-if err := json.Unmarshal(m.Value, &event); err != nil {
+if err := json.Unmarshal(value, &event); err != nil {
 log.Printf("Error unmarshaling message: %v", err)
-continue
+return nil
 }
 
 // Process the event based on its type
@@ -69,13 +83,14 @@ switch event.EventType {
 case "order_created":
 	log.Printf("Processing order created event")
 	var orderEvent struct {
-		OrderID     string  `json:"order_id"`
-		CustomerID  string  `json:"customer_id"`
-		TotalAmount float64 `json:"total_amount"`
+		OrderID     string                  `json:"order_id"`
+		CustomerID  string                  `json:"customer_id"`
+		TotalAmount float64                 `json:"total_amount"`
+		Customer    *models.CustomerContact `json:"customer"`
 	}
-	if err := json.Unmarshal(m.Value, &orderEvent); err != nil {
+	if err := json.Unmarshal(value, &orderEvent); err != nil {
 		log.Printf("Error unmarshaling order created event: %v", err)
-		continue
+		return nil
 	}
 
 	// Check if payment already exists for this order
@@ -83,7 +98,13 @@ case "order_created":
 	if err == nil {
 		// Payment already exists, skip
 		log.Printf("Payment already exists for order %s, skipping", orderEvent.OrderID)
-		continue
+		return nil
+	}
+
+	// Default to STRIPE, or to card when payments are mocked
+	paymentMethod := "STRIPE"
+	if c.paymentMode != "stripe" {
+		paymentMethod = "card"
 	}
 
 	// Create payment request
@@ -96,19 +117,24 @@ case "order_created":
 	}{
 		OrderID:       orderEvent.OrderID,
 		Amount:        orderEvent.TotalAmount,
-		PaymentMethod: "STRIPE", // Default to STRIPE
-		Currency:      "USD",    // Default to USD
+		PaymentMethod: paymentMethod,
+		Currency:      "USD", // Default to USD
 		Description:   "Payment for order " + orderEvent.OrderID,
 	}
 
 	// Convert to models.CreatePaymentRequest
 	createPaymentReq := models.CreatePaymentRequest{
 		OrderID:       paymentReq.OrderID,
+		CustomerID:    orderEvent.CustomerID,
 		Amount:        paymentReq.Amount,
 		PaymentMethod: paymentReq.PaymentMethod,
 		Currency:      paymentReq.Currency,
 		Description:   paymentReq.Description,
 	}
+	if orderEvent.Customer != nil {
+		createPaymentReq.CustomerEmail = orderEvent.Customer.Email
+		createPaymentReq.CustomerName = orderEvent.Customer.Name
+	}
 
 	// Create payment
 	_, err = c.service.CreatePayment(createPaymentReq)
@@ -121,16 +147,16 @@ case "order_cancelled":
 	var orderEvent struct {
 		OrderID string `json:"order_id"`
 	}
-	if err := json.Unmarshal(m.Value, &orderEvent); err != nil {
+	if err := json.Unmarshal(value, &orderEvent); err != nil {
 		log.Printf("Error unmarshaling order cancelled event: %v", err)
-		continue
+		return nil
 	}
 
 	// Get payment for order
 	payment, err := c.service.GetPaymentByOrderID(orderEvent.OrderID)
 	if err != nil {
 		log.Printf("Error getting payment for order %s: %v", orderEvent.OrderID, err)
-		continue
+		return nil
 	}
 
 	// Refund payment if it was successful
@@ -141,7 +167,5 @@ case "order_cancelled":
 		}
 	}
 }
-}
-}
-}()
+return nil
 }
@@ -4,37 +4,36 @@ import (
 "context"
 "encoding/json"
 "log"
+"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/payment-service/config"
+"github.com/online-order-system/payment-service/eventbus"
 "github.com/online-order-system/payment-service/interfaces"
 "github.com/online-order-system/payment-service/models"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-writer *kafka.Writer
+bus          eventbus.Bus
+topic        string
+privacyTopic string
 }
 
 // Ensure Producer implements PaymentProducer interface
 var _ interfaces.PaymentProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-writer := &kafka.Writer{
-Addr:     kafka.TCP(cfg.KafkaBootstrapServers),
-Topic:    cfg.KafkaTopic,
-Balancer: &kafka.LeastBytes{},
-}
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 return &Producer{
-writer: writer,
+bus:          bus,
+topic:        cfg.KafkaTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-return p.writer.Close()
+return nil
 }
 
 // PublishPaymentCreated publishes a payment created event
@@ -46,6 +45,8 @@ OrderID:       payment.OrderID,
 Amount:        payment.Amount,
 Status:        payment.Status,
 PaymentMethod: payment.PaymentMethod,
+CustomerID:    payment.CustomerID,
+Customer:      payment.Contact(),
 Timestamp:     payment.CreatedAt.Unix(),
 }
 
@@ -61,6 +62,8 @@ OrderID:       payment.OrderID,
 Amount:        payment.Amount,
 Status:        payment.Status,
 PaymentMethod: payment.PaymentMethod,
+CustomerID:    payment.CustomerID,
+Customer:      payment.Contact(),
 Timestamp:     payment.UpdatedAt.Unix(),
 }
 
@@ -76,6 +79,8 @@ OrderID:       payment.OrderID,
 Amount:        payment.Amount,
 Status:        payment.Status,
 PaymentMethod: payment.PaymentMethod,
+CustomerID:    payment.CustomerID,
+Customer:      payment.Contact(),
 Timestamp:     payment.UpdatedAt.Unix(),
 }
 
@@ -91,12 +96,34 @@ OrderID:       payment.OrderID,
 Amount:        payment.Amount,
 Status:        payment.Status,
 PaymentMethod: payment.PaymentMethod,
+CustomerID:    payment.CustomerID,
+Customer:      payment.Contact(),
 Timestamp:     payment.UpdatedAt.Unix(),
 }
 
 return p.publishEvent(event)
 }
 
+// PublishErasureCompleted reports to user-service what was erased for an
+// erasure request
+func (p *Producer) PublishErasureCompleted(event models.ErasureEvent) error {
+event.EventType = "user_erasure_completed"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+err = p.bus.Publish(context.Background(), p.privacyTopic, []byte(event.CustomerID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for erasure %s", event.EventType, event.RequestID)
+return nil
+}
+
 // publishEvent publishes an event to Kafka
 func (p *Producer) publishEvent(event models.PaymentEvent) error {
 // Convert event to JSON
@@ -105,14 +132,8 @@ if err != nil {
 return err
 }
 
-// Create message
-message := kafka.Message{
-Key:   []byte(event.PaymentID),
-Value: eventJSON,
-}
-
 // Write message
-err = p.writer.WriteMessages(context.Background(), message)
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.PaymentID), eventJSON)
 if err != nil {
 return err
 }
@@ -1,9 +1,13 @@
 package models
 
 import (
+"errors"
 "time"
 )
 
+// ErrOrderNotFound is returned when order-service doesn't know an order
+var ErrOrderNotFound = errors.New("order not found")
+
 // PaymentStatus represents the status of a payment
 type PaymentStatus string
 
@@ -19,6 +23,7 @@ PaymentStatusRefunded  PaymentStatus = "REFUNDED"
 type Payment struct {
 ID                string        `json:"id"`
 OrderID           string        `json:"order_id"`
+CustomerID        string        `json:"customer_id,omitempty"`
 Amount            float64       `json:"amount"`
 Status            PaymentStatus `json:"status"`
 PaymentMethod     string        `json:"payment_method"`
@@ -43,6 +48,7 @@ ErrorMessage      string        `json:"error_message,omitempty"`
 // CreatePaymentRequest represents a request to create a new payment
 type CreatePaymentRequest struct {
 OrderID       string  `json:"order_id" binding:"required"`
+CustomerID    string  `json:"customer_id,omitempty"`
 Amount        float64 `json:"amount" binding:"required"`
 PaymentMethod string  `json:"payment_method" binding:"required"`
 CardNumber    string  `json:"card_number,omitempty"`
@@ -58,6 +64,14 @@ CustomerName  string  `json:"customer_name,omitempty"`
 PaymentMethodID string `json:"payment_method_id,omitempty"`
 }
 
+// OrderSummary is what payment-service needs to know of an order to check a
+// payment for it. It is looked up in order-service.
+type OrderSummary struct {
+ID          string  `json:"id"`
+CustomerID  string  `json:"customer_id"`
+TotalAmount float64 `json:"total_amount"`
+}
+
 // UpdatePaymentStatusRequest represents a request to update a payment's status
 type UpdatePaymentStatusRequest struct {
 Status PaymentStatus `json:"status" binding:"required"`
@@ -72,4 +86,57 @@ Amount        float64       `json:"amount"`
 Status        PaymentStatus `json:"status"`
 PaymentMethod string        `json:"payment_method"`
 Timestamp     int64         `json:"timestamp"`
+CustomerID    string        `json:"customer_id,omitempty"`
+Customer      *CustomerContact `json:"customer,omitempty"`
+}
+
+// CustomerContact is what services need to contact a customer. It is looked
+// up in user-service and carried in events, so the services consuming them
+// don't have to look it up again.
+type CustomerContact struct {
+ID     string `json:"id"`
+Email  string `json:"email"`
+Name   string `json:"name"`
+Phone  string `json:"phone,omitempty"`
+Locale string `json:"locale"`
+}
+
+// Contact returns the contact details of the customer a payment keeps, or
+// nil when it has none
+func (p Payment) Contact() *CustomerContact {
+if p.CustomerEmail == "" {
+return nil
+}
+return &CustomerContact{ID: p.CustomerID, Email: p.CustomerEmail, Name: p.CustomerName}
+}
+
+// CustomerData is the personal data payment-service holds about a customer, as
+// it appears in a data export. Card numbers are masked and CVVs left out.
+type CustomerData struct {
+CustomerID string    `json:"customer_id"`
+Payments   []Payment `json:"payments"`
+}
+
+// Statuses a service reports for an erasure
+const (
+ErasureCompleted = "completed"
+ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+EventType  string `json:"event_type"`
+RequestID  string `json:"request_id"`
+CustomerID string `json:"customer_id"`
+Email      string `json:"email,omitempty"`
+Timestamp  int64  `json:"timestamp"`
+
+// Set on user_erasure_completed events
+Service  string `json:"service,omitempty"`
+Status   string `json:"status,omitempty"`
+Erased   int    `json:"erased,omitempty"`
+Retained int    `json:"retained,omitempty"`
+Note     string `json:"note,omitempty"`
 }
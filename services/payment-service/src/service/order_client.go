@@ -0,0 +1,50 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/online-order-system/payment-service/auth"
+	"github.com/online-order-system/payment-service/models"
+)
+
+// OrderClient looks up orders in order-service. Orders aren't cached, since
+// their totals have to be current when a payment is checked against them.
+type OrderClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOrderClient creates a client for the order-service at baseURL
+func NewOrderClient(baseURL string, tokens auth.TokenSource) *OrderClient {
+	return &OrderClient{
+		baseURL: baseURL,
+		client:  auth.NewClient(tokens, 5*time.Second),
+	}
+}
+
+// GetOrder returns the customer and total of an order
+func (c *OrderClient) GetOrder(orderID string) (models.OrderSummary, error) {
+	resp, err := c.client.Get(fmt.Sprintf("%s/orders/%s", c.baseURL, url.PathEscape(orderID)))
+	if err != nil {
+		return models.OrderSummary{}, fmt.Errorf("failed to get order: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return models.OrderSummary{}, models.ErrOrderNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return models.OrderSummary{}, fmt.Errorf("failed to get order, status code: %d", resp.StatusCode)
+	}
+
+	var order models.OrderSummary
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return models.OrderSummary{}, fmt.Errorf("failed to decode order response: %w", err)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/payment-service/models"
+)
+
+// paymentServiceName names payment-service in erasure reports
+const paymentServiceName = "payment-service"
+
+// GetCustomerData gathers the personal data payment-service holds about a
+// customer for a data export
+func (s *PaymentService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	payments, err := s.repository.GetPaymentsByCustomerID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+
+	return models.CustomerData{CustomerID: customerID, Payments: payments}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Payments are kept as financial records
+// with their card and contact details removed.
+func (s *PaymentService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    paymentServiceName,
+		Status:     models.ErasureCompleted,
+		Note:       "payments kept as financial records without card and contact details",
+	}
+
+	// Don't keep contact details of the customer around in the cache either
+	s.users.Forget(event.CustomerID)
+
+	count, err := s.repository.EraseCustomer(event.CustomerID, event.Email)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+	report.Erased, report.Retained = count, count
+
+	return s.producer.PublishErasureCompleted(report)
+}
@@ -7,6 +7,7 @@ import (
 "math/rand"
 "time"
 
+"github.com/online-order-system/payment-service/auth"
 "github.com/online-order-system/payment-service/config"
 "github.com/online-order-system/payment-service/db"
 "github.com/online-order-system/payment-service/interfaces"
@@ -19,6 +20,8 @@ config     *config.Config
 repository *db.PaymentRepository
 producer   interfaces.PaymentProducer
 stripeService *StripePaymentService
+users         *UserClient
+orders        *OrderClient
 }
 
 // Ensure PaymentService implements PaymentService interface
@@ -29,11 +32,18 @@ func NewPaymentService(cfg *config.Config, repo *db.PaymentRepository, producer
 	// Create Stripe service
 	stripeService := NewStripePaymentService(cfg, repo, producer)
 
+	if cfg.ServiceSecret == "" {
+		log.Printf("SERVICE_SECRET is not set, customers can't be looked up in user-service")
+	}
+	tokens := auth.NewServiceTokens(cfg.ServiceTokenURL, cfg.ServiceName, cfg.ServiceSecret)
+
 	return &PaymentService{
 		config:        cfg,
 		repository:    repo,
 		producer:      producer,
 		stripeService: stripeService,
+		users:         NewUserClient(cfg.UserServiceURL, tokens, cfg.CustomerCacheTTL),
+		orders:        NewOrderClient(cfg.OrderServiceURL, tokens),
 	}
 }
 
@@ -53,6 +63,7 @@ now := time.Now()
 payment := models.Payment{
     ID:            db.GenerateID(),
     OrderID:       req.OrderID,
+    CustomerID:    req.CustomerID,
     Amount:        req.Amount,
     Status:        models.PaymentStatusPending,
     PaymentMethod: paymentMethod,
@@ -71,6 +82,18 @@ payment := models.Payment{
     PaymentMethodID: req.PaymentMethodID,
 }
 
+// Look up the customer when the request doesn't say who to send the receipt to
+if payment.CustomerEmail == "" && payment.CustomerID != "" {
+    customer, err := s.users.GetCustomer(payment.CustomerID)
+    if err != nil {
+        log.Printf("Failed to get contact details of customer %s: %v", payment.CustomerID, err)
+        // Continue anyway, the payment doesn't need them
+    } else {
+        payment.CustomerEmail = customer.Email
+        payment.CustomerName = customer.Name
+    }
+}
+
 // Save payment to database
 err := s.repository.CreatePayment(payment)
 if err != nil {
@@ -164,6 +187,11 @@ func (s *PaymentService) GetPaymentByOrderID(orderID string) (models.Payment, er
 return s.repository.GetPaymentByOrderID(orderID)
 }
 
+// GetOrder looks up an order in order-service, to check a payment for it
+func (s *PaymentService) GetOrder(orderID string) (models.OrderSummary, error) {
+return s.orders.GetOrder(orderID)
+}
+
 // GetPayments retrieves all payments
 func (s *PaymentService) GetPayments() ([]models.Payment, error) {
 return s.repository.GetPayments()
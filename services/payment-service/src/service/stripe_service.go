@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/online-order-system/payment-service/config"
 	"github.com/online-order-system/payment-service/db"
@@ -12,6 +13,7 @@ import (
 	"github.com/online-order-system/payment-service/models"
 	"github.com/stripe/stripe-go/v82"
 	"github.com/stripe/stripe-go/v82/paymentintent"
+	"github.com/stripe/stripe-go/v82/paymentmethod"
 	"github.com/stripe/stripe-go/v82/webhook"
 )
 
@@ -318,11 +320,20 @@ func (s *StripePaymentService) ConfirmPayment(payment models.Payment) (bool, err
 	// Create a payment method based on the card details
 	if payment.CardNumber != "" {
 		// Use the provided card details
+		expMonth, err := strconv.ParseInt(payment.ExpiryMonth, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid expiry month: %v", err)
+		}
+		expYear, err := strconv.ParseInt(payment.ExpiryYear, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid expiry year: %v", err)
+		}
+
 		pmParams := &stripe.PaymentMethodParams{
 			Card: &stripe.PaymentMethodCardParams{
 				Number:   stripe.String(payment.CardNumber),
-				ExpMonth: stripe.String(payment.ExpiryMonth),
-				ExpYear:  stripe.String(payment.ExpiryYear),
+				ExpMonth: stripe.Int64(expMonth),
+				ExpYear:  stripe.Int64(expYear),
 				CVC:      stripe.String(payment.CVV),
 			},
 			Type: stripe.String("card"),
@@ -331,7 +342,7 @@ func (s *StripePaymentService) ConfirmPayment(payment models.Payment) (bool, err
 		pm, err := paymentmethod.New(pmParams)
 		if err != nil {
 			log.Printf("Error creating payment method: %v", err)
-			return payment, fmt.Errorf("failed to create payment method: %v", err)
+			return false, fmt.Errorf("failed to create payment method: %v", err)
 		}
 
 		params.PaymentMethod = stripe.String(pm.ID)
@@ -4,10 +4,14 @@ import (
 "net/http"
 
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/shipping-service/auth"
 "github.com/online-order-system/shipping-service/interfaces"
 "github.com/online-order-system/shipping-service/models"
 )
 
+// staffRoles may see the shipments of every customer
+var staffRoles = []string{auth.RoleSupport, auth.RoleWarehouse, auth.RoleAdmin}
+
 // Handler handles HTTP requests
 type Handler struct {
 service interfaces.ShippingService
@@ -42,7 +46,7 @@ func (h *Handler) GetShipmentByID(c *gin.Context) {
 id := c.Param("id")
 
 shipment, err := h.service.GetShipmentByID(id)
-if err != nil {
+if err != nil || !auth.CanAccess(c, shipment.CustomerID, staffRoles...) {
 c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
 return
 }
@@ -55,7 +59,7 @@ func (h *Handler) GetShipmentByOrderID(c *gin.Context) {
 orderID := c.Param("order_id")
 
 shipment, err := h.service.GetShipmentByOrderID(orderID)
-if err != nil {
+if err != nil || !auth.CanAccess(c, shipment.CustomerID, staffRoles...) {
 c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
 return
 }
@@ -110,4 +114,15 @@ return
 }
 
 c.JSON(http.StatusOK, shipment)
-}
\ No newline at end of file
+}
+// GetCustomerData handles user-service gathering the data of a customer for a
+// data export
+func (h *Handler) GetCustomerData(c *gin.Context) {
+data, err := h.service.GetCustomerData(c.Param("id"))
+if err != nil {
+c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer data"})
+return
+}
+
+c.JSON(http.StatusOK, data)
+}
@@ -2,17 +2,19 @@ package api
 
 import (
 "github.com/gin-gonic/gin"
+"github.com/online-order-system/shipping-service/auth"
 "github.com/online-order-system/shipping-service/interfaces"
 )
 
 // SetupRouter sets up the router with all the necessary routes and middleware
-func SetupRouter(service interfaces.ShippingService) *gin.Engine {
+func SetupRouter(service interfaces.ShippingService, verifier *auth.Verifier) *gin.Engine {
 // Create router
 router := gin.Default()
 
 // Add middleware
 router.Use(Logger())
 router.Use(CORS())
+router.Use(auth.Authenticate(verifier))
 
 // Create handler
 handler := NewHandler(service)
@@ -28,27 +30,36 @@ c.JSON(200, gin.H{
 router.StaticFile("/swagger", "./docs/swagger.html")
 router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
 
+// Who may call the routes. Routes open to everyone check in the handler
+// that the caller owns the shipment.
+everyone := auth.RequireRole(auth.Everyone...)
+staff := auth.RequireRole(auth.RoleSupport, auth.RoleWarehouse, auth.RoleAdmin)
+warehouse := auth.RequireRole(auth.Internal, auth.RoleWarehouse, auth.RoleAdmin)
+
 // Shipment routes
 shipments := router.Group("/shipments")
 {
 // Create a new shipment
-shipments.POST("", handler.CreateShipment)
+shipments.POST("", warehouse, handler.CreateShipment)
 
 // Get all shipments
-shipments.GET("", handler.GetShipments)
+shipments.GET("", staff, handler.GetShipments)
 
 // Get a specific shipment by ID
-shipments.GET("/:id", handler.GetShipmentByID)
+shipments.GET("/:id", everyone, handler.GetShipmentByID)
 
 // Get a shipment by order ID
-shipments.GET("/order/:order_id", handler.GetShipmentByOrderID)
+shipments.GET("/order/:order_id", everyone, handler.GetShipmentByOrderID)
 
 // Update a shipment's status
-shipments.PUT("/:id/status", handler.UpdateShipmentStatus)
+shipments.PUT("/:id/status", warehouse, handler.UpdateShipmentStatus)
 
 // Update a shipment's tracking number
-shipments.PUT("/:id/tracking", handler.UpdateTrackingNumber)
+shipments.PUT("/:id/tracking", warehouse, handler.UpdateTrackingNumber)
 }
 
+// Personal data of a customer, gathered by user-service for data exports
+router.GET("/customers/:id/data", auth.RequireRole(auth.Internal), handler.GetCustomerData)
+
 return router
 }
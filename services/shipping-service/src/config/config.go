@@ -3,15 +3,21 @@ package config
 import (
 "os"
 "strconv"
+"strings"
 "time"
 )
 
 // Config holds all configuration for the service
 type Config struct {
 // Server configuration
-ServerPort string
+ServerPort     string
+// Addresses of the proxies, the gateway, trusted to say which client a
+// request came from. Clients are identified by their own address otherwise.
+TrustedProxies []string
 
 // Database configuration
+DBDriver   string
+DBDSN      string
 DBHost     string
 DBPort     string
 DBUser     string
@@ -21,6 +27,19 @@ DBName     string
 // Kafka configuration
 KafkaBootstrapServers string
 KafkaTopic            string
+KafkaPrivacyTopic     string
+EventBus              string
+
+// JWT configuration
+JWTIssuer   string
+JWTAudience string
+JWKSURL     string
+
+// Service token configuration. Calls to other services carry a token
+// user-service issues for the name and secret of this service.
+ServiceName     string
+ServiceSecret   string
+ServiceTokenURL string
 
 // External services
 OrderServiceURL string
@@ -37,9 +56,12 @@ RedisCacheTTL time.Duration
 func LoadConfig() *Config {
 return &Config{
 // Server configuration
-ServerPort: getEnv("PORT", "8084"),
+ServerPort:     getEnv("PORT", "8084"),
+TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 // Database configuration
+DBDriver:   getEnv("DB_DRIVER", "postgres"),
+DBDSN:      getEnv("DB_DSN", ""),
 DBHost:     getEnv("DB_HOST", "localhost"),
 DBPort:     getEnv("DB_PORT", "5432"),
 DBUser:     getEnv("DB_USER", "postgres"),
@@ -49,6 +71,18 @@ DBName:     getEnv("DB_NAME", "shippingdb"),
 // Kafka configuration
 KafkaBootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:9092"),
 KafkaTopic:            getEnv("KAFKA_TOPIC", "shipments"),
+KafkaPrivacyTopic:     getEnv("KAFKA_PRIVACY_TOPIC", "privacy"),
+EventBus:              getEnv("EVENT_BUS", "kafka"),
+
+// JWT configuration
+JWTIssuer:   getEnv("JWT_ISSUER", "user-service"),
+JWTAudience: getEnv("JWT_AUDIENCE", "online-order-system"),
+JWKSURL:     getEnv("JWKS_URL", "http://user-service:8086/.well-known/jwks.json"),
+
+// Service token configuration
+ServiceName:     getEnv("SERVICE_NAME", "shipping-service"),
+ServiceSecret:   getEnv("SERVICE_SECRET", ""),
+ServiceTokenURL: getEnv("SERVICE_TOKEN_URL", "http://user-service:8086/auth/service-token"),
 
 // External services
 OrderServiceURL: getEnv("ORDER_SERVICE_URL", "http://order-service:8081"),
@@ -87,3 +121,14 @@ return value
 }
 return defaultValue
 }
+
+// Helper to read a comma separated environment variable into a list
+func getEnvAsList(key string) []string {
+var values []string
+for _, value := range strings.Split(getEnv(key, ""), ",") {
+if value = strings.TrimSpace(value); value != "" {
+values = append(values, value)
+}
+}
+return values
+}
@@ -10,19 +10,29 @@ import (
 _ "github.com/lib/pq"
 )
 
+// Supported database drivers
+const (
+DriverPostgres = "postgres"
+DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 *sql.DB
+Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 // Create connection string
-connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+connStr := cfg.DBDSN
+if connStr == "" {
+connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+}
 
 // Connect to database
-db, err := sql.Open("postgres", connStr)
+db, err := sql.Open(cfg.DBDriver, connStr)
 if err != nil {
 return nil, err
 }
@@ -38,9 +48,9 @@ db.SetMaxOpenConns(25)
 db.SetMaxIdleConns(25)
 db.SetConnMaxLifetime(5 * time.Minute)
 
-log.Println("Connected to PostgreSQL database")
+log.Printf("Connected to %s database", cfg.DBDriver)
 
-return &Database{db}, nil
+return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -57,7 +67,68 @@ carrier VARCHAR(100),
 estimated_delivery TIMESTAMP,
 created_at TIMESTAMP NOT NULL,
 updated_at TIMESTAMP NOT NULL,
-customer_id VARCHAR(36)
+customer_id VARCHAR(36),
+warehouse_id VARCHAR(36) NOT NULL DEFAULT '',
+reference VARCHAR(100) NOT NULL DEFAULT ''
+)
+`)
+if err != nil {
+return err
+}
+_, _ = db.Exec(`ALTER TABLE shipments ADD COLUMN IF NOT EXISTS warehouse_id VARCHAR(36) NOT NULL DEFAULT ''`)
+_, _ = db.Exec(`ALTER TABLE shipments ADD COLUMN IF NOT EXISTS reference VARCHAR(100) NOT NULL DEFAULT ''`)
+
+// A request is turned into one shipment, however often it is retried
+_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_reference ON shipments (reference) WHERE reference <> ''`)
+if err != nil {
+return err
+}
+
+// Create shipment_items table holding what each shipment carries
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS shipment_items (
+shipment_id VARCHAR(36) NOT NULL REFERENCES shipments(id),
+product_id VARCHAR(36) NOT NULL,
+sku_id VARCHAR(36) NOT NULL DEFAULT '',
+quantity INTEGER NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+
+// Create shipment_addresses table holding the copy of the address each
+// shipment goes to. shipments.shipping_address keeps the address on one
+// line, which is all shipments created before addresses were structured have.
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS shipment_addresses (
+shipment_id VARCHAR(36) PRIMARY KEY REFERENCES shipments(id),
+recipient VARCHAR(100) NOT NULL,
+phone VARCHAR(20) NOT NULL,
+line1 VARCHAR(200) NOT NULL,
+line2 VARCHAR(200) NOT NULL DEFAULT '',
+ward VARCHAR(100) NOT NULL DEFAULT '',
+district VARCHAR(100) NOT NULL DEFAULT '',
+city VARCHAR(100) NOT NULL DEFAULT '',
+province VARCHAR(100) NOT NULL DEFAULT '',
+postal_code VARCHAR(20) NOT NULL DEFAULT '',
+country VARCHAR(2) NOT NULL
+)
+`)
+if err != nil {
+return err
+}
+
+// Create shipment_customers table holding the contact details of the
+// customer of each shipment, taken from the order, so shipment events can
+// carry them
+_, err = db.Exec(`
+CREATE TABLE IF NOT EXISTS shipment_customers (
+shipment_id VARCHAR(36) PRIMARY KEY REFERENCES shipments(id),
+email VARCHAR(100) NOT NULL,
+name VARCHAR(100) NOT NULL DEFAULT '',
+phone VARCHAR(20) NOT NULL DEFAULT '',
+locale VARCHAR(10) NOT NULL DEFAULT ''
 )
 `)
 if err != nil {
@@ -20,25 +20,70 @@ return &ShippingRepository{db: db}
 
 // CreateShipment creates a new shipment in the database
 func (r *ShippingRepository) CreateShipment(shipment models.Shipment) error {
-_, err := r.db.Exec(
-"INSERT INTO shipments (id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
-shipment.ID, shipment.OrderID, shipment.Status, shipment.TrackingNumber, shipment.ShippingAddress, shipment.Carrier, shipment.EstimatedDelivery, shipment.CreatedAt, shipment.UpdatedAt, shipment.CustomerID,
+// Begin transaction
+tx, err := r.db.Begin()
+if err != nil {
+return err
+}
+defer tx.Rollback()
+
+_, err = tx.Exec(
+"INSERT INTO shipments (id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id, warehouse_id, reference) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+shipment.ID, shipment.OrderID, shipment.Status, shipment.TrackingNumber, shipment.ShippingAddress.String(), shipment.Carrier, shipment.EstimatedDelivery, shipment.CreatedAt, shipment.UpdatedAt, shipment.CustomerID, shipment.WarehouseID, shipment.Reference,
 )
+if err != nil {
 return err
 }
 
+// Insert what the shipment carries
+for _, item := range shipment.Items {
+_, err = tx.Exec(
+"INSERT INTO shipment_items (shipment_id, product_id, sku_id, quantity) VALUES ($1, $2, $3, $4)",
+shipment.ID, item.ProductID, item.SKUID, item.Quantity,
+)
+if err != nil {
+return err
+}
+}
+
+// Insert the copy of the shipping address
+address := shipment.ShippingAddress
+_, err = tx.Exec(
+"INSERT INTO shipment_addresses (shipment_id, recipient, phone, line1, line2, ward, district, city, province, postal_code, country) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+shipment.ID, address.Recipient, address.Phone, address.Line1, address.Line2, address.Ward, address.District, address.City, address.Province, address.PostalCode, address.Country,
+)
+if err != nil {
+return err
+}
+
+// Insert the contact details of the customer
+if customer := shipment.Customer; customer != nil {
+_, err = tx.Exec(
+"INSERT INTO shipment_customers (shipment_id, email, name, phone, locale) VALUES ($1, $2, $3, $4, $5)",
+shipment.ID, customer.Email, customer.Name, customer.Phone, customer.Locale,
+)
+if err != nil {
+return err
+}
+}
+
+// Commit transaction
+return tx.Commit()
+}
+
 // GetShipmentByID retrieves a shipment by ID
 func (r *ShippingRepository) GetShipmentByID(id string) (models.Shipment, error) {
 var shipment models.Shipment
 var status string
 var createdAt, updatedAt time.Time
 var estimatedDeliveryNull sql.NullTime
+var shippingAddress string
 
 // Get shipment
 err := r.db.QueryRow(
-"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id FROM shipments WHERE id = $1",
+"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id, warehouse_id, reference FROM shipments WHERE id = $1",
 id,
-).Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shipment.ShippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID)
+).Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID, &shipment.WarehouseID, &shipment.Reference)
 if err != nil {
 return shipment, err
 }
@@ -50,6 +95,21 @@ if estimatedDeliveryNull.Valid {
 shipment.EstimatedDelivery = estimatedDeliveryNull.Time
 }
 
+shipment.ShippingAddress, err = r.getShippingAddress(shipment.ID, shippingAddress)
+if err != nil {
+return shipment, err
+}
+
+shipment.Customer, err = r.getCustomer(shipment)
+if err != nil {
+return shipment, err
+}
+
+shipment.Items, err = r.getItems(shipment.ID)
+if err != nil {
+return shipment, err
+}
+
 return shipment, nil
 }
 
@@ -59,12 +119,13 @@ var shipment models.Shipment
 var status string
 var createdAt, updatedAt time.Time
 var estimatedDeliveryNull sql.NullTime
+var shippingAddress string
 
 // Get shipment
 err := r.db.QueryRow(
-"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id FROM shipments WHERE order_id = $1",
+"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id, warehouse_id, reference FROM shipments WHERE order_id = $1",
 orderID,
-).Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shipment.ShippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID)
+).Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID, &shipment.WarehouseID, &shipment.Reference)
 if err != nil {
 return shipment, err
 }
@@ -76,15 +137,51 @@ if estimatedDeliveryNull.Valid {
 shipment.EstimatedDelivery = estimatedDeliveryNull.Time
 }
 
+shipment.ShippingAddress, err = r.getShippingAddress(shipment.ID, shippingAddress)
+if err != nil {
+return shipment, err
+}
+
+shipment.Customer, err = r.getCustomer(shipment)
+if err != nil {
+return shipment, err
+}
+
+shipment.Items, err = r.getItems(shipment.ID)
+if err != nil {
+return shipment, err
+}
+
 return shipment, nil
 }
 
+// GetShipmentByReference retrieves the shipment created by the request with
+// the given reference
+func (r *ShippingRepository) GetShipmentByReference(reference string) (models.Shipment, error) {
+var id string
+err := r.db.QueryRow("SELECT id FROM shipments WHERE reference = $1", reference).Scan(&id)
+if err != nil {
+return models.Shipment{}, err
+}
+return r.GetShipmentByID(id)
+}
+
 // GetShipments retrieves all shipments
 func (r *ShippingRepository) GetShipments() ([]models.Shipment, error) {
-// Get all shipments
-rows, err := r.db.Query(
-"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id FROM shipments",
+return r.getShipments("SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id, warehouse_id, reference FROM shipments")
+}
+
+// GetShipmentsByCustomerID retrieves the shipments of a customer
+func (r *ShippingRepository) GetShipmentsByCustomerID(customerID string) ([]models.Shipment, error) {
+return r.getShipments(
+"SELECT id, order_id, status, tracking_number, shipping_address, carrier, estimated_delivery, created_at, updated_at, customer_id, warehouse_id, reference FROM shipments WHERE customer_id = $1 ORDER BY created_at",
+customerID,
 )
+}
+
+// getShipments retrieves the shipments a query selects
+func (r *ShippingRepository) getShipments(query string, args ...interface{}) ([]models.Shipment, error) {
+rows, err := r.db.Query(query, args...)
 if err != nil {
 return nil, err
 }
@@ -96,8 +193,9 @@ var shipment models.Shipment
 var status string
 var createdAt, updatedAt time.Time
 var estimatedDeliveryNull sql.NullTime
+var shippingAddress string
 
-err := rows.Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shipment.ShippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID)
+err := rows.Scan(&shipment.ID, &shipment.OrderID, &status, &shipment.TrackingNumber, &shippingAddress, &shipment.Carrier, &estimatedDeliveryNull, &createdAt, &updatedAt, &shipment.CustomerID, &shipment.WarehouseID, &shipment.Reference)
 if err != nil {
 return nil, err
 }
@@ -109,12 +207,130 @@ if estimatedDeliveryNull.Valid {
 shipment.EstimatedDelivery = estimatedDeliveryNull.Time
 }
 
+shipment.ShippingAddress, err = r.getShippingAddress(shipment.ID, shippingAddress)
+if err != nil {
+return nil, err
+}
+
+shipment.Customer, err = r.getCustomer(shipment)
+if err != nil {
+return nil, err
+}
+
+shipment.Items, err = r.getItems(shipment.ID)
+if err != nil {
+return nil, err
+}
+
 shipments = append(shipments, shipment)
 }
 
 return shipments, nil
 }
 
+// getShippingAddress retrieves the copy of the address a shipment goes to.
+// Shipments created before addresses were structured only have the address
+// on one line, which is returned as the first line.
+func (r *ShippingRepository) getShippingAddress(shipmentID string, legacy string) (models.Address, error) {
+var address models.Address
+err := r.db.QueryRow(
+"SELECT recipient, phone, line1, line2, ward, district, city, province, postal_code, country FROM shipment_addresses WHERE shipment_id = $1",
+shipmentID,
+).Scan(&address.Recipient, &address.Phone, &address.Line1, &address.Line2, &address.Ward, &address.District, &address.City, &address.Province, &address.PostalCode, &address.Country)
+if err == sql.ErrNoRows {
+return models.Address{Line1: legacy}, nil
+}
+return address, err
+}
+
+// getCustomer retrieves the contact details of the customer of a shipment,
+// or nil for shipments created without them
+func (r *ShippingRepository) getCustomer(shipment models.Shipment) (*models.CustomerContact, error) {
+customer := models.CustomerContact{ID: shipment.CustomerID}
+err := r.db.QueryRow(
+"SELECT email, name, phone, locale FROM shipment_customers WHERE shipment_id = $1",
+shipment.ID,
+).Scan(&customer.Email, &customer.Name, &customer.Phone, &customer.Locale)
+if err == sql.ErrNoRows {
+return nil, nil
+}
+if err != nil {
+return nil, err
+}
+return &customer, nil
+}
+
+// getItems retrieves what a shipment carries. Shipments created before items
+// were recorded carry their whole order and have none.
+func (r *ShippingRepository) getItems(shipmentID string) ([]models.ShipmentItem, error) {
+rows, err := r.db.Query(
+"SELECT product_id, sku_id, quantity FROM shipment_items WHERE shipment_id = $1",
+shipmentID,
+)
+if err != nil {
+return nil, err
+}
+defer rows.Close()
+
+var items []models.ShipmentItem
+for rows.Next() {
+var item models.ShipmentItem
+if err := rows.Scan(&item.ProductID, &item.SKUID, &item.Quantity); err != nil {
+return nil, err
+}
+items = append(items, item)
+}
+return items, rows.Err()
+}
+
+// EraseCustomer removes the addresses and contact details from the shipments
+// of a customer. The shipments themselves are kept as delivery records. It
+// returns the number of addresses and contact details erased and of
+// shipments kept.
+func (r *ShippingRepository) EraseCustomer(customerID string) (int, int, error) {
+tx, err := r.db.Begin()
+if err != nil {
+return 0, 0, err
+}
+defer tx.Rollback()
+
+result, err := tx.Exec(
+"DELETE FROM shipment_addresses WHERE shipment_id IN (SELECT id FROM shipments WHERE customer_id = $1)",
+customerID,
+)
+if err != nil {
+return 0, 0, err
+}
+erased, err := result.RowsAffected()
+if err != nil {
+return 0, 0, err
+}
+
+result, err = tx.Exec(
+"DELETE FROM shipment_customers WHERE shipment_id IN (SELECT id FROM shipments WHERE customer_id = $1)",
+customerID,
+)
+if err != nil {
+return 0, 0, err
+}
+contacts, err := result.RowsAffected()
+if err != nil {
+return 0, 0, err
+}
+erased += contacts
+
+result, err = tx.Exec("UPDATE shipments SET shipping_address = '' WHERE customer_id = $1", customerID)
+if err != nil {
+return 0, 0, err
+}
+retained, err := result.RowsAffected()
+if err != nil {
+return 0, 0, err
+}
+
+return int(erased), int(retained), tx.Commit()
+}
+
 // UpdateShipmentStatus updates the status of a shipment
 func (r *ShippingRepository) UpdateShipmentStatus(id string, status models.ShipmentStatus) error {
 _, err := r.db.Exec(
@@ -12,6 +12,8 @@ GetShipmentByOrderID(orderID string) (models.Shipment, error)
 GetShipments() ([]models.Shipment, error)
 UpdateShipmentStatus(id string, status models.ShipmentStatus) (models.Shipment, error)
 UpdateTrackingNumber(id string, trackingNumber string) (models.Shipment, error)
+GetCustomerData(customerID string) (models.CustomerData, error)
+EraseCustomer(event models.ErasureEvent) error
 }
 
 // ShippingProducer defines the interface for shipping producer
@@ -19,5 +21,6 @@ type ShippingProducer interface {
 PublishShipmentCreated(shipment models.Shipment) error
 PublishShipmentStatusUpdated(shipment models.Shipment) error
 PublishShipmentCompleted(shipment models.Shipment) error
+PublishErasureCompleted(event models.ErasureEvent) error
 Close() error
 }
@@ -4,64 +4,76 @@ import (
 "context"
 "encoding/json"
 "log"
-"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/shipping-service/config"
+"github.com/online-order-system/shipping-service/eventbus"
 "github.com/online-order-system/shipping-service/interfaces"
 "github.com/online-order-system/shipping-service/models"
 )
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-reader  *kafka.Reader
-service interfaces.ShippingService
+bus          eventbus.Bus
+privacyTopic string
+service      interfaces.ShippingService
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.ShippingService) *Consumer {
-reader := kafka.NewReader(kafka.ReaderConfig{
-Brokers:  []string{cfg.KafkaBootstrapServers},
-Topic:    "orders", // Listen to orders topic
-GroupID:  "shipping-service",
-MinBytes: 10e3, // 10KB
-MaxBytes: 10e6, // 10MB
-})
-
-log.Printf("Shipping Service Kafka consumer created and subscribed to topic: orders")
-
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.ShippingService) *Consumer {
 return &Consumer{
-reader:  reader,
-service: service,
+bus:          bus,
+privacyTopic: cfg.KafkaPrivacyTopic,
+service:      service,
 }
 }
 
 // StartConsuming starts consuming messages from Kafka
 func (c *Consumer) StartConsuming(ctx context.Context) {
-go func() {
-for {
-select {
-case <-ctx.Done():
-log.Println("Stopping Kafka consumer")
-c.reader.Close()
+// Listen to orders topic
+err := c.bus.Subscribe(ctx, "orders", "shipping-service", c.processMessage)
+if err != nil {
+log.Printf("Error subscribing to orders topic: %v", err)
 return
-default:
-m, err := c.reader.ReadMessage(ctx)
+}
+
+// Listen to privacy topic
+err = c.bus.Subscribe(ctx, c.privacyTopic, "shipping-service", c.processPrivacyMessage)
 if err != nil {
-log.Printf("Error reading message from Kafka: %v", err)
-time.Sleep(1 * time.Second)
-continue
+log.Printf("Error subscribing to privacy topic: %v", err)
+return
+}
+
+log.Printf("Shipping Service Kafka consumer subscribed to topics: orders, %s", c.privacyTopic)
+}
+
+// processPrivacyMessage processes a message from the privacy topic
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+var event models.ErasureEvent
+if err := json.Unmarshal(value, &event); err != nil {
+log.Printf("Error unmarshaling message: %v", err)
+return nil
+}
+
+if event.EventType == "user_erasure_requested" {
+log.Printf("Processing erasure %s for customer %s", event.RequestID, event.CustomerID)
+if err := c.service.EraseCustomer(event); err != nil {
+log.Printf("Error reporting erasure %s: %v", event.RequestID, err)
+}
+}
+return nil
 }
 
-log.Printf("Received message from Kafka: %s", string(m.Value))
+// processMessage processes a message from the orders topic
+func (c *Consumer) processMessage(ctx context.Context, key, value []byte) error {
+log.Printf("Received message from Kafka: %s", string(value))
 
 // Process the message
 var event struct {
 EventType string `json:"event_type"`
 }
-if err := json.Unmarshal(m.Value, &event); err != nil {
+if err := json.Unmarshal(value, &event); err != nil {
 log.Printf("Error unmarshaling message: %v", err)
-continue
+return nil
 }
 
 // Process the event based on its type
@@ -69,24 +81,32 @@ switch event.EventType {
 case "order_confirmed":
 log.Printf("Processing order confirmed event")
 var orderEvent struct {
-OrderID         string `json:"order_id"`
-ShippingAddress string `json:"shipping_address"`
+OrderID         string         `json:"order_id"`
+ShippingAddress models.Address `json:"shipping_address"`
+WarehouseID     string         `json:"warehouse_id"`
+AwaitingStock   bool           `json:"awaiting_stock"`
 }
-if err := json.Unmarshal(m.Value, &orderEvent); err != nil {
+if err := json.Unmarshal(value, &orderEvent); err != nil {
 log.Printf("Error unmarshaling order confirmed event: %v", err)
-continue
+return nil
+}
+
+// Orders waiting for backordered stock are shipped by order-service once
+// enough of it is allocated
+if orderEvent.AwaitingStock {
+log.Printf("Order %s waits for backordered stock, not creating a shipment yet", orderEvent.OrderID)
+return nil
 }
 
 // Create shipment
-_, err = c.service.CreateShipment(models.CreateShipmentRequest{
+_, err := c.service.CreateShipment(models.CreateShipmentRequest{
 OrderID:         orderEvent.OrderID,
 ShippingAddress: orderEvent.ShippingAddress,
+WarehouseID:     orderEvent.WarehouseID,
 })
 if err != nil {
 log.Printf("Error creating shipment for order %s: %v", orderEvent.OrderID, err)
 }
 }
-}
-}
-}()
+return nil
 }
@@ -4,37 +4,36 @@ import (
 "context"
 "encoding/json"
 "log"
+"time"
 
-"github.com/segmentio/kafka-go"
 "github.com/online-order-system/shipping-service/config"
+"github.com/online-order-system/shipping-service/eventbus"
 "github.com/online-order-system/shipping-service/interfaces"
 "github.com/online-order-system/shipping-service/models"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-writer *kafka.Writer
+bus          eventbus.Bus
+topic        string
+privacyTopic string
 }
 
 // Ensure Producer implements ShippingProducer interface
 var _ interfaces.ShippingProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-writer := &kafka.Writer{
-Addr:     kafka.TCP(cfg.KafkaBootstrapServers),
-Topic:    cfg.KafkaTopic,
-Balancer: &kafka.LeastBytes{},
-}
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 return &Producer{
-writer: writer,
+bus:          bus,
+topic:        cfg.KafkaTopic,
+privacyTopic: cfg.KafkaPrivacyTopic,
 }
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-return p.writer.Close()
+return nil
 }
 
 // PublishShipmentCreated publishes a shipment created event
@@ -47,6 +46,7 @@ Status:         shipment.Status,
 TrackingNumber: shipment.TrackingNumber,
 Timestamp:      shipment.CreatedAt.Unix(),
 CustomerID:     shipment.CustomerID, // Include customer ID
+Customer:       shipment.Customer,
 }
 
 return p.publishEvent(event)
@@ -62,6 +62,7 @@ Status:         shipment.Status,
 TrackingNumber: shipment.TrackingNumber,
 Timestamp:      shipment.UpdatedAt.Unix(),
 CustomerID:     shipment.CustomerID, // Include customer ID
+Customer:       shipment.Customer,
 }
 
 return p.publishEvent(event)
@@ -77,11 +78,32 @@ Status:         shipment.Status,
 TrackingNumber: shipment.TrackingNumber,
 Timestamp:      shipment.UpdatedAt.Unix(),
 CustomerID:     shipment.CustomerID, // Include customer ID
+Customer:       shipment.Customer,
 }
 
 return p.publishEvent(event)
 }
 
+// PublishErasureCompleted reports to user-service what was erased for an
+// erasure request
+func (p *Producer) PublishErasureCompleted(event models.ErasureEvent) error {
+event.EventType = "user_erasure_completed"
+event.Timestamp = time.Now().Unix()
+
+eventJSON, err := json.Marshal(event)
+if err != nil {
+return err
+}
+
+err = p.bus.Publish(context.Background(), p.privacyTopic, []byte(event.CustomerID), eventJSON)
+if err != nil {
+return err
+}
+
+log.Printf("Published event: %s for erasure %s", event.EventType, event.RequestID)
+return nil
+}
+
 // publishEvent publishes an event to Kafka
 func (p *Producer) publishEvent(event models.ShipmentEvent) error {
 // Convert event to JSON
@@ -90,14 +112,8 @@ if err != nil {
 return err
 }
 
-// Create message
-message := kafka.Message{
-Key:   []byte(event.ShipmentID),
-Value: eventJSON,
-}
-
 // Write message
-err = p.writer.WriteMessages(context.Background(), message)
+err = p.bus.Publish(context.Background(), p.topic, []byte(event.ShipmentID), eventJSON)
 if err != nil {
 return err
 }
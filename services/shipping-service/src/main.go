@@ -8,50 +8,36 @@ import (
 "syscall"
 "time"
 
-"github.com/online-order-system/shipping-service/api"
+"github.com/online-order-system/shipping-service/app"
 "github.com/online-order-system/shipping-service/config"
-"github.com/online-order-system/shipping-service/db"
-"github.com/online-order-system/shipping-service/kafka"
-"github.com/online-order-system/shipping-service/service"
+"github.com/online-order-system/shipping-service/eventbus"
 )
 
 func main() {
 // Load configuration
 cfg := config.LoadConfig()
 
-// Connect to database
-database, err := db.NewDatabase(cfg)
+// Create event bus
+bus, err := eventbus.New(cfg)
 if err != nil {
-log.Fatalf("Failed to connect to database: %v", err)
+log.Fatalf("Failed to create event bus: %v", err)
 }
-defer database.Close()
+defer bus.Close()
 
-// Create tables
-err = database.CreateTables()
+// Create application
+application, err := app.New(cfg, bus)
 if err != nil {
-log.Fatalf("Failed to create tables: %v", err)
+log.Fatalf("Failed to create application: %v", err)
 }
-
-// Create repository
-repository := db.NewShippingRepository(database)
-
-// Create Kafka producer
-producer := kafka.NewProducer(cfg)
-defer producer.Close()
-
-// Create service
-shippingService := service.NewShippingService(cfg, repository, producer)
-
-// Create Kafka consumer
-consumer := kafka.NewConsumer(cfg, shippingService)
+defer application.Close()
 
 // Start Kafka consumer
 ctx, cancel := context.WithCancel(context.Background())
 defer cancel()
-consumer.StartConsuming(ctx)
+application.Start(ctx)
 
 // Set up router
-router := api.SetupRouter(shippingService)
+router := application.Router
 
 // Start server
 go func() {
@@ -1,6 +1,7 @@
 package models
 
 import (
+"strings"
 "time"
 )
 
@@ -22,20 +23,74 @@ ID              string         `json:"id"`
 OrderID         string         `json:"order_id"`
 Status          ShipmentStatus `json:"status"`
 TrackingNumber  string         `json:"tracking_number"`
-ShippingAddress string         `json:"shipping_address"`
+ShippingAddress Address        `json:"shipping_address"`
 Carrier         string         `json:"carrier"`
 EstimatedDelivery time.Time    `json:"estimated_delivery"`
 CreatedAt       time.Time      `json:"created_at"`
 UpdatedAt       time.Time      `json:"updated_at"`
 CustomerID      string         `json:"customer_id,omitempty"` // Added for notification purposes
+Customer        *CustomerContact `json:"customer,omitempty"`
+WarehouseID     string         `json:"warehouse_id,omitempty"` // Warehouse the shipment leaves from
+Reference       string         `json:"reference,omitempty"` // Key of the request that created the shipment
+Items           []ShipmentItem `json:"items,omitempty"`
+}
+
+// ShipmentItem is the part of an order item a shipment carries. Orders split
+// across warehouses, or shipped before all their stock arrived, are carried by
+// more than one shipment.
+type ShipmentItem struct {
+ProductID string `json:"product_id"`
+SKUID     string `json:"sku_id,omitempty"`
+Quantity  int    `json:"quantity"`
+}
+
+// CustomerContact is what services need to contact a customer. Shipments
+// keep the copy order-service passes on, so shipment events can carry it.
+type CustomerContact struct {
+ID     string `json:"id"`
+Email  string `json:"email"`
+Name   string `json:"name"`
+Phone  string `json:"phone,omitempty"`
+Locale string `json:"locale"`
+}
+
+// Address is the copy of the address a shipment goes to, taken from the order
+type Address struct {
+Recipient  string `json:"recipient"`
+Phone      string `json:"phone"`
+Line1      string `json:"line1"`
+Line2      string `json:"line2,omitempty"`
+Ward       string `json:"ward,omitempty"`
+District   string `json:"district,omitempty"`
+City       string `json:"city,omitempty"`
+Province   string `json:"province,omitempty"`
+PostalCode string `json:"postal_code,omitempty"`
+Country    string `json:"country"`
+}
+
+// String formats an address on one line
+func (a Address) String() string {
+var parts []string
+for _, part := range []string{a.Line1, a.Line2, a.Ward, a.District, a.City, a.Province, a.PostalCode, a.Country} {
+if part != "" {
+parts = append(parts, part)
+}
+}
+return strings.Join(parts, ", ")
 }
 
 // CreateShipmentRequest represents a request to create a new shipment
 type CreateShipmentRequest struct {
-OrderID         string `json:"order_id" binding:"required"`
-ShippingAddress string `json:"shipping_address" binding:"required"`
-Carrier         string `json:"carrier,omitempty"`
-CustomerID      string `json:"customer_id,omitempty"` // Added for notification purposes
+OrderID         string  `json:"order_id" binding:"required"`
+ShippingAddress Address `json:"shipping_address"`
+Carrier         string  `json:"carrier,omitempty"`
+CustomerID      string  `json:"customer_id,omitempty"` // Added for notification purposes
+Customer        *CustomerContact `json:"customer,omitempty"`
+WarehouseID     string  `json:"warehouse_id,omitempty"` // Fulfilment location picked by inventory-service
+Items           []ShipmentItem `json:"items,omitempty"` // What the shipment carries, the whole order when empty
+// Reference makes creating the shipment idempotent: a request with the
+// reference of an existing shipment returns that shipment
+Reference string `json:"reference,omitempty"`
 }
 
 // UpdateShipmentStatusRequest represents a request to update a shipment's status
@@ -57,4 +112,36 @@ Status         ShipmentStatus `json:"status"`
 TrackingNumber string         `json:"tracking_number,omitempty"`
 Timestamp      int64          `json:"timestamp"`
 CustomerID     string         `json:"customer_id,omitempty"` // Added for notification purposes
+Customer       *CustomerContact `json:"customer,omitempty"`
+}
+
+// CustomerData is the personal data shipping-service holds about a customer,
+// as it appears in a data export
+type CustomerData struct {
+CustomerID string     `json:"customer_id"`
+Shipments  []Shipment `json:"shipments"`
+}
+
+// Statuses a service reports for an erasure
+const (
+ErasureCompleted = "completed"
+ErasureFailed    = "failed"
+)
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+EventType  string `json:"event_type"`
+RequestID  string `json:"request_id"`
+CustomerID string `json:"customer_id"`
+Email      string `json:"email,omitempty"`
+Timestamp  int64  `json:"timestamp"`
+
+// Set on user_erasure_completed events
+Service  string `json:"service,omitempty"`
+Status   string `json:"status,omitempty"`
+Erased   int    `json:"erased,omitempty"`
+Retained int    `json:"retained,omitempty"`
+Note     string `json:"note,omitempty"`
 }
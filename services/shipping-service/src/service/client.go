@@ -3,9 +3,11 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/online-order-system/shipping-service/auth"
 	"github.com/online-order-system/shipping-service/config"
 )
 
@@ -17,11 +19,14 @@ type OrderClient struct {
 
 // NewOrderClient creates a new order client
 func NewOrderClient(cfg *config.Config) *OrderClient {
+	if cfg.ServiceSecret == "" {
+		log.Printf("SERVICE_SECRET is not set, calls to order-service will fail")
+	}
+
+	tokens := auth.NewServiceTokens(cfg.ServiceTokenURL, cfg.ServiceName, cfg.ServiceSecret)
 	return &OrderClient{
 		config: cfg,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		client: auth.NewClient(tokens, 5*time.Second),
 	}
 }
 
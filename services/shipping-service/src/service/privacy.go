@@ -0,0 +1,46 @@
+package service
+
+import (
+	"log"
+
+	"github.com/online-order-system/shipping-service/models"
+)
+
+// shippingServiceName names shipping-service in erasure reports
+const shippingServiceName = "shipping-service"
+
+// GetCustomerData gathers the personal data shipping-service holds about a
+// customer for a data export
+func (s *ShippingService) GetCustomerData(customerID string) (models.CustomerData, error) {
+	shipments, err := s.repository.GetShipmentsByCustomerID(customerID)
+	if err != nil {
+		return models.CustomerData{}, err
+	}
+	if shipments == nil {
+		shipments = []models.Shipment{}
+	}
+
+	return models.CustomerData{CustomerID: customerID, Shipments: shipments}, nil
+}
+
+// EraseCustomer erases the personal data of a customer for an erasure request
+// and reports back to user-service. Shipments are kept as delivery records
+// with their addresses and contact details removed.
+func (s *ShippingService) EraseCustomer(event models.ErasureEvent) error {
+	report := models.ErasureEvent{
+		RequestID:  event.RequestID,
+		CustomerID: event.CustomerID,
+		Service:    shippingServiceName,
+		Status:     models.ErasureCompleted,
+		Note:       "shipments kept as delivery records without addresses or contact details",
+	}
+
+	var err error
+	report.Erased, report.Retained, err = s.repository.EraseCustomer(event.CustomerID)
+	if err != nil {
+		log.Printf("Failed to erase customer %s: %v", event.CustomerID, err)
+		report.Status, report.Note = models.ErasureFailed, err.Error()
+	}
+
+	return s.producer.PublishErasureCompleted(report)
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+"database/sql"
+"errors"
 "fmt"
 "log"
 "time"
@@ -34,6 +36,21 @@ orderClient: NewOrderClient(cfg),
 
 // CreateShipment creates a new shipment
 func (s *ShippingService) CreateShipment(req models.CreateShipmentRequest) (models.Shipment, error) {
+if req.ShippingAddress.Line1 == "" {
+return models.Shipment{}, errors.New("shipping address is required")
+}
+
+// A retried request gets the shipment it already created
+if req.Reference != "" {
+shipment, err := s.repository.GetShipmentByReference(req.Reference)
+if err == nil {
+return shipment, nil
+}
+if err != sql.ErrNoRows {
+return models.Shipment{}, err
+}
+}
+
 // Create shipment
 now := time.Now()
 estimatedDelivery := now.Add(3 * 24 * time.Hour) // 3 days from now
@@ -71,10 +88,20 @@ EstimatedDelivery: estimatedDelivery,
 CreatedAt:        now,
 UpdatedAt:        now,
 CustomerID:       customerID, // Save customer ID
+Customer:         req.Customer,
+WarehouseID:      req.WarehouseID,
+Reference:        req.Reference,
+Items:            req.Items,
 }
 
 // Save shipment to database
 err := s.repository.CreateShipment(shipment)
+if err != nil && req.Reference != "" {
+// A concurrent retry of the request may have created it first
+if existing, lookupErr := s.repository.GetShipmentByReference(req.Reference); lookupErr == nil {
+return existing, nil
+}
+}
 if err != nil {
 return models.Shipment{}, err
 }
@@ -1,13 +1,15 @@
 package api
 
 import (
-	"encoding/base64"
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/user-service/auth"
 	"github.com/online-order-system/user-service/interfaces"
 	"github.com/online-order-system/user-service/models"
 )
@@ -55,6 +57,11 @@ func (h *Handlers) CreateUser(c *gin.Context) {
 // GetUserByID handles user retrieval by ID requests
 func (h *Handlers) GetUserByID(c *gin.Context) {
 	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access another user"})
+		return
+	}
+
 	user, err := h.service.GetUserByID(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -64,6 +71,22 @@ func (h *Handlers) GetUserByID(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// GetUserContact handles requests of other services for the contact details
+// of a customer
+func (h *Handlers) GetUserContact(c *gin.Context) {
+	user, err := h.service.GetUserByID(c.Param("id"))
+	if errors.Is(err, models.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Contact())
+}
+
 // GetUsers handles user list retrieval requests
 func (h *Handlers) GetUsers(c *gin.Context) {
 	users, err := h.service.GetUsers()
@@ -75,27 +98,127 @@ func (h *Handlers) GetUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
-// UpdateUser handles user update requests
+// UpdateUser handles user update requests. Staff can't update accounts with
+// a higher role than their own, and only the owner or an admin can change the
+// email address, which password resets are sent to.
 func (h *Handlers) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update another user"})
+		return
+	}
+
 	var req models.UpdateCustomerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	existing, err := h.service.GetUserByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	if claims.UserID() != id && !claims.IsService() {
+		if auth.Outranks(existing.Role, claims.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update a user with a higher role"})
+			return
+		}
+	}
+	if req.Email != "" && req.Email != existing.Email {
+		if claims.UserID() != id && !claims.HasRole(auth.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the user or an admin can change the email address"})
+			return
+		}
+	}
+
 	user, err := h.service.UpdateUser(id, req)
 	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserRole handles requests to change the role of a user
+func (h *Handlers) UpdateUserRole(c *gin.Context) {
+	id := c.Param("id")
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.UpdateUserRole(id, req.Role)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[PUT] /users/%s/role - Role changed to %s", id, user.Role)
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserStatus handles requests to activate or lock an account
+func (h *Handlers) UpdateUserStatus(c *gin.Context) {
+	id := c.Param("id")
+	var req models.UpdateStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.UpdateUserStatus(id, req.Status)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidStatus) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	log.Printf("[PUT] /users/%s/status - Status changed to %s", id, user.Status)
+	c.JSON(http.StatusOK, user)
+}
+
+// UnlockUser handles requests from admins to lift the lockout after failed
+// logins, and the locked status, of an account
+func (h *Handlers) UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+
+	user, err := h.service.UnlockUser(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[POST] /users/%s/unlock - User unlocked", id)
 	c.JSON(http.StatusOK, user)
 }
 
 // DeleteUser handles user deletion requests
 func (h *Handlers) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete another user"})
+		return
+	}
+
 	err := h.service.DeleteUser(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -105,6 +228,81 @@ func (h *Handlers) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// ExportUserData handles requests for an archive of the personal data every
+// service holds about a user
+func (h *Handlers) ExportUserData(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot export data of another user"})
+		return
+	}
+
+	archive, err := h.service.ExportUserData(id)
+	if err != nil {
+		h.privacyError(c, "[POST] /users/"+id+"/data-export", err)
+		return
+	}
+
+	log.Printf("[POST] /users/%s/data-export - Data exported", id)
+	c.Header("Content-Disposition", `attachment; filename="user-data-`+id+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// RequestErasure handles requests to erase the personal data of a user across
+// every service. The account is gone when the response is sent; the other
+// services report back asynchronously.
+func (h *Handlers) RequestErasure(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot erase another user"})
+		return
+	}
+
+	requestedBy := auth.Internal
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		requestedBy = claims.UserID()
+	}
+
+	request, err := h.service.RequestErasure(id, requestedBy)
+	if err != nil {
+		h.privacyError(c, "[POST] /users/"+id+"/erasure", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+// GetErasureRequests handles requests for the progress of the erasures of a
+// user, reported per service
+func (h *Handlers) GetErasureRequests(c *gin.Context) {
+	id := c.Param("id")
+
+	requests, err := h.service.GetErasureRequests(id)
+	if err != nil {
+		h.privacyError(c, "[GET] /users/"+id+"/erasure", err)
+		return
+	}
+	if len(requests) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": models.ErrErasureNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// privacyError writes the response for an error of a data export or erasure
+func (h *Handlers) privacyError(c *gin.Context, route string, err error) {
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrDataExportFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s - Error: %v", route, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process privacy request"})
+	}
+}
+
 // VerifyUser handles user verification requests
 func (h *Handlers) VerifyUser(c *gin.Context) {
 	var req models.VerifyCustomerRequest
@@ -113,7 +311,10 @@ func (h *Handlers) VerifyUser(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.VerifyUser(req)
+	response, err := h.service.VerifyUser(req, clientInfo(c))
+	if throttled(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -125,6 +326,11 @@ func (h *Handlers) VerifyUser(c *gin.Context) {
 // GetUserOrders handles user orders retrieval requests
 func (h *Handlers) GetUserOrders(c *gin.Context) {
 	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access orders of another user"})
+		return
+	}
+
 	orders, err := h.service.GetUserOrders(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -134,6 +340,148 @@ func (h *Handlers) GetUserOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+// GetUserAddresses handles requests for the address book of a user
+func (h *Handlers) GetUserAddresses(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access addresses of another user"})
+		return
+	}
+
+	addresses, err := h.service.GetAddresses(id)
+	if err != nil {
+		h.addressError(c, "[GET] /users/"+id+"/addresses", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+// GetUserAddress handles requests for an address of a user
+func (h *Handlers) GetUserAddress(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access addresses of another user"})
+		return
+	}
+
+	address, err := h.service.GetAddress(id, c.Param("aid"))
+	if err != nil {
+		h.addressError(c, "[GET] /users/"+id+"/addresses/"+c.Param("aid"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// CreateUserAddress handles requests to add an address to the address book
+// of a user
+func (h *Handlers) CreateUserAddress(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change addresses of another user"})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address, err := h.service.CreateAddress(id, req)
+	if err != nil {
+		h.addressError(c, "[POST] /users/"+id+"/addresses", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+// UpdateUserAddress handles requests to change an address of a user
+func (h *Handlers) UpdateUserAddress(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change addresses of another user"})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address, err := h.service.UpdateAddress(id, c.Param("aid"), req)
+	if err != nil {
+		h.addressError(c, "[PUT] /users/"+id+"/addresses/"+c.Param("aid"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// DeleteUserAddress handles requests to remove an address from the address
+// book of a user
+func (h *Handlers) DeleteUserAddress(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleSupport, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change addresses of another user"})
+		return
+	}
+
+	err := h.service.DeleteAddress(id, c.Param("aid"))
+	if err != nil {
+		h.addressError(c, "[DELETE] /users/"+id+"/addresses/"+c.Param("aid"), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
+
+// addressError writes the response for an error of an address book request
+func (h *Handlers) addressError(c *gin.Context, route string, err error) {
+	switch {
+	case errors.Is(err, models.ErrAddressNotFound), errors.Is(err, models.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidAddress), errors.Is(err, models.ErrTooManyAddresses):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s - Error: %v", route, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process address request"})
+	}
+}
+
+// Register handles account registration requests
+func (h *Handlers) Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.Register(req, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[POST] /auth/register - Error registering user: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		}
+		return
+	}
+
+	if response.VerificationRequired {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
 // Login handles user login requests
 func (h *Handlers) Login(c *gin.Context) {
 	var req models.LoginRequest
@@ -142,29 +490,443 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
-	// Get user by email
-	user, err := h.service.GetUserByEmail(req.Email)
+	response, err := h.service.Login(req, clientInfo(c))
+	if throttled(c, err) {
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, models.ErrAccountLocked), errors.Is(err, models.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[POST] /auth/login - Error logging in: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		}
 		return
 	}
 
-	// Generate token
-	token := generateToken(user.ID, user.Email)
+	c.JSON(http.StatusOK, response)
+}
 
-	// Return response
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
-		User:  &user,
-	})
+// Refresh handles requests to exchange a refresh token for new tokens
+func (h *Handlers) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.service.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		log.Printf("[POST] /auth/refresh - Error refreshing token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout handles logout requests by revoking the session of the access token
+func (h *Handlers) Logout(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	err := h.service.Logout(claims.SessionID)
+	if err != nil {
+		log.Printf("[POST] /auth/logout - Error logging out: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetUserSessions handles requests to list the active sessions of a user
+func (h *Handlers) GetUserSessions(c *gin.Context) {
+	id := c.Param("id")
+	claims, _ := auth.ClaimsFromContext(c)
+	if !auth.CanAccess(c, id, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access sessions of another user"})
+		return
+	}
+
+	sessions, err := h.service.GetSessions(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSession handles requests to sign out a session of a user
+func (h *Handlers) RevokeUserSession(c *gin.Context) {
+	id := c.Param("id")
+	if !auth.CanAccess(c, id, auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot revoke sessions of another user"})
+		return
+	}
+
+	err := h.service.RevokeSession(id, c.Param("sid"))
+	if err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[DELETE] /users/%s/sessions/%s - Error revoking session: %v", id, c.Param("sid"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// ChangePassword handles password change requests from the authenticated user
+func (h *Handlers) ChangePassword(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.ChangePassword(claims.UserID(), claims.SessionID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		default:
+			log.Printf("[PUT] /auth/password - Error changing password: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// VerifyEmail handles requests to verify an email address with the token
+// from the verification email
+func (h *Handlers) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.VerifyEmail(req.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[POST] /auth/verify-email - Error verifying email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully", "user": user})
+}
+
+// ResendVerification handles requests for another verification email. The
+// response is the same whether or not the account exists.
+func (h *Handlers) ResendVerification(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.ResendVerification(req.Email)
+	if err != nil {
+		log.Printf("[POST] /auth/resend-verification - Error sending verification email: %v", err)
+		// Don't tell the caller, the response would reveal that the account exists
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account is waiting for verification, an email is on its way"})
 }
 
-// ValidateToken handles token validation requests
+// ForgotPassword handles requests for a password reset email. The response is
+// the same whether or not the account exists.
+func (h *Handlers) ForgotPassword(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.ForgotPassword(req.Email)
+	if err != nil {
+		log.Printf("[POST] /auth/forgot-password - Error sending password reset email: %v", err)
+		// Don't tell the caller, the response would reveal that the account exists
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this email, a password reset link is on its way"})
+}
+
+// ResetPassword handles requests to set a new password with the token from
+// the password reset email
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.ResetPassword(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidToken), errors.Is(err, auth.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[POST] /auth/reset-password - Error resetting password: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// VerifyMFA handles requests to complete a login with a TOTP code or a
+// recovery code
+func (h *Handlers) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	response, err := h.service.VerifyMFA(req, clientInfo(c))
+	if err != nil {
+		h.mfaError(c, "[POST] /auth/mfa/verify", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMFAStatus handles requests for the MFA status of the caller
+func (h *Handlers) GetMFAStatus(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	status, err := h.service.GetMFAStatus(claims.UserID())
+	if err != nil {
+		h.mfaError(c, "[GET] /auth/mfa", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollMFA handles requests to create a TOTP secret for the caller
+func (h *Handlers) EnrollMFA(c *gin.Context) {
+	var req models.MFAEnrollRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, ok := h.mfaUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.service.EnrollMFA(userID)
+	if err != nil {
+		h.mfaError(c, "[POST] /auth/mfa/enroll", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA handles requests to turn on MFA with a code from the new secret.
+// Logins that had to set up MFA first are completed in the same request.
+func (h *Handlers) ConfirmMFA(c *gin.Context) {
+	var req models.MFAConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MFAToken != "" {
+		response, err := h.service.CompleteMFAEnrollment(req.MFAToken, req.Code, clientInfo(c))
+		if err != nil {
+			h.mfaError(c, "[POST] /auth/mfa/confirm", err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	userID, ok := h.mfaUser(c, "")
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.ConfirmMFA(userID, req.Code)
+	if err != nil {
+		h.mfaError(c, "[POST] /auth/mfa/confirm", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAConfirmResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA handles requests from the caller to turn off MFA
+func (h *Handlers) DisableMFA(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.DisableMFA(claims.UserID(), req)
+	if err != nil {
+		h.mfaError(c, "[DELETE] /auth/mfa", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+}
+
+// RegenerateRecoveryCodes handles requests from the caller for new recovery codes
+func (h *Handlers) RegenerateRecoveryCodes(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c)
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(claims.UserID(), req.Code)
+	if err != nil {
+		h.mfaError(c, "[POST] /auth/mfa/recovery-codes", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAConfirmResponse{RecoveryCodes: codes})
+}
+
+// ResetUserMFA handles requests from admins to remove the MFA of a user who
+// lost access to it
+func (h *Handlers) ResetUserMFA(c *gin.Context) {
+	id := c.Param("id")
+
+	err := h.service.ResetMFA(id)
+	if err != nil {
+		h.mfaError(c, "[DELETE] /users/"+id+"/mfa", err)
+		return
+	}
+
+	log.Printf("[DELETE] /users/%s/mfa - MFA reset", id)
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset successfully"})
+}
+
+// GetMFAPolicy handles requests for the roles that have to use MFA
+func (h *Handlers) GetMFAPolicy(c *gin.Context) {
+	policy, err := h.service.GetMFAPolicy()
+	if err != nil {
+		h.mfaError(c, "[GET] /auth/mfa/policy", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateMFAPolicy handles requests to change the roles that have to use MFA
+func (h *Handlers) UpdateMFAPolicy(c *gin.Context) {
+	var req models.MFAPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.UpdateMFAPolicy(req)
+	if err != nil {
+		h.mfaError(c, "[PUT] /auth/mfa/policy", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// mfaUser returns the user an MFA request is for: the caller, or the user of
+// the MFA token of a login that has to set up MFA first
+func (h *Handlers) mfaUser(c *gin.Context, mfaToken string) (string, bool) {
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		return claims.UserID(), true
+	}
+
+	if mfaToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return "", false
+	}
+
+	userID, err := h.service.MFAEnrollmentUser(mfaToken)
+	if err != nil {
+		h.mfaError(c, "[POST] /auth/mfa", err)
+		return "", false
+	}
+	return userID, true
+}
+
+// mfaError writes the response for an error of an MFA request
+func (h *Handlers) mfaError(c *gin.Context, route string, err error) {
+	if throttled(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, models.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+	case errors.Is(err, models.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidRole), errors.Is(err, models.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrMFARequired), errors.Is(err, models.ErrAccountLocked), errors.Is(err, models.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s - Error: %v", route, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process MFA request"})
+	}
+}
+
+// ValidateToken handles token validation requests. The gateway asks it about
+// every request, so tokens of revoked sessions are refused before they reach
+// any service.
 func (h *Handlers) ValidateToken(c *gin.Context) {
-	// Get token from Authorization header
+	// Requests without a token pass the gateway anonymously; the services
+	// decide which routes need one
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No Authorization header provided"})
+		c.JSON(http.StatusOK, gin.H{"valid": false, "anonymous": true})
 		return
 	}
 
@@ -178,44 +940,81 @@ func (h *Handlers) ValidateToken(c *gin.Context) {
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 
 	// Validate token
-	claims, err := validateToken(token)
+	claims, err := h.service.ValidateToken(token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		log.Printf("[GET] /auth/validate - Error validating token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+		return
+	}
+
+	// Service tokens are only for calls between services, never from outside
+	if claims.IsService() {
+		log.Printf("[GET] /auth/validate - Refused service token of %s from outside", claims.ServiceName())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Service tokens are not accepted from outside"})
 		return
 	}
 
 	// Set user ID and role headers
-	c.Header("X-User-ID", claims.UserID)
+	c.Header("X-User-ID", claims.UserID())
 	c.Header("X-User-Role", claims.Role)
 
 	c.JSON(http.StatusOK, gin.H{"valid": true})
 }
 
-// generateToken creates a simple token for authentication
-func generateToken(userID, email string) string {
-	// In a real app, this would be a JWT with proper signing
-	// For demo purposes, we'll just create a base64 encoded string
-	tokenData := userID + ":" + email + ":" + time.Now().Format(time.RFC3339)
-	return base64.StdEncoding.EncodeToString([]byte(tokenData))
-}
+// IssueServiceToken handles requests of other services for a service token
+func (h *Handlers) IssueServiceToken(c *gin.Context) {
+	var req auth.ServiceTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-// validateToken validates a token and returns the claims
-func validateToken(token string) (*models.TokenClaims, error) {
-	// In a real app, this would verify the JWT signature
-	// For demo purposes, we'll just decode the base64 string
-	data, err := base64.StdEncoding.DecodeString(token)
+	response, err := h.service.IssueServiceToken(req)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, models.ErrInvalidServiceCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid service credentials"})
+			return
+		}
+		log.Printf("[POST] /auth/service-token - Error issuing service token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue service token"})
+		return
 	}
 
-	parts := strings.Split(string(data), ":")
-	if len(parts) < 2 {
-		return nil, err
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJWKS serves the public keys that verify access tokens
+func (h *Handlers) GetJWKS(c *gin.Context) {
+	// Verifiers refetch the keys when they see a new key ID, so caching is safe
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// throttled answers 429 with a Retry-After header if an attempt was refused
+// because of too many failed attempts
+func throttled(c *gin.Context, err error) bool {
+	var throttledErr *models.ThrottledError
+	if !errors.As(err, &throttledErr) {
+		return false
+	}
+
+	seconds := int(math.Ceil(throttledErr.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
 	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": throttledErr.Error(), "retry_after": seconds})
+	return true
+}
 
-	return &models.TokenClaims{
-		UserID: parts[0],
-		Email:  parts[1],
-		Role:   "user", // Default role
-	}, nil
+// clientInfo describes the device a request comes from
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
 }
@@ -2,15 +2,17 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/user-service/auth"
 	"github.com/online-order-system/user-service/interfaces"
 )
 
 // SetupRouter sets up the router
-func SetupRouter(service interfaces.UserService) *gin.Engine {
+func SetupRouter(service interfaces.UserService, verifier *auth.Verifier) *gin.Engine {
 	router := gin.Default()
 
 	// Set up middleware
 	SetupMiddleware(router)
+	router.Use(auth.Authenticate(verifier))
 
 	// Create handlers
 	handlers := NewHandlers(service)
@@ -22,23 +24,67 @@ func SetupRouter(service interfaces.UserService) *gin.Engine {
 	// Health check
 	router.GET("/health", handlers.HealthCheck)
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
+	// Who may call the routes. Routes open to everyone check in the handler
+	// that the caller owns the user.
+	everyone := auth.RequireRole(auth.Everyone...)
+	staff := auth.RequireRole(auth.RoleSupport, auth.RoleAdmin)
+	admin := auth.RequireRole(auth.RoleAdmin)
+
 	// Users
 	users := router.Group("/users")
 	{
-		users.POST("", handlers.CreateUser)
-		users.GET("", handlers.GetUsers)
-		users.GET("/:id", handlers.GetUserByID)
-		users.PUT("/:id", handlers.UpdateUser)
-		users.DELETE("/:id", handlers.DeleteUser)
-		users.GET("/:id/orders", handlers.GetUserOrders)
-		users.POST("/verify", handlers.VerifyUser)
+		users.POST("", admin, handlers.CreateUser)
+		users.GET("", staff, handlers.GetUsers)
+		users.GET("/:id", everyone, handlers.GetUserByID)
+		users.PUT("/:id", everyone, handlers.UpdateUser)
+		users.PUT("/:id/role", admin, handlers.UpdateUserRole)
+		users.PUT("/:id/status", admin, handlers.UpdateUserStatus)
+		users.POST("/:id/unlock", admin, handlers.UnlockUser)
+		users.DELETE("/:id", everyone, handlers.DeleteUser)
+		users.POST("/:id/data-export", everyone, handlers.ExportUserData)
+		users.POST("/:id/erasure", everyone, handlers.RequestErasure)
+		users.GET("/:id/erasure", staff, handlers.GetErasureRequests)
+		users.GET("/:id/orders", everyone, handlers.GetUserOrders)
+		users.GET("/:id/contact", auth.RequireRole(auth.Internal), handlers.GetUserContact)
+		users.GET("/:id/addresses", everyone, handlers.GetUserAddresses)
+		users.POST("/:id/addresses", everyone, handlers.CreateUserAddress)
+		users.GET("/:id/addresses/:aid", everyone, handlers.GetUserAddress)
+		users.PUT("/:id/addresses/:aid", everyone, handlers.UpdateUserAddress)
+		users.DELETE("/:id/addresses/:aid", everyone, handlers.DeleteUserAddress)
+		users.GET("/:id/sessions", auth.RequireAuth(verifier), handlers.GetUserSessions)
+		users.DELETE("/:id/sessions/:sid", auth.RequireAuth(verifier), handlers.RevokeUserSession)
+		users.DELETE("/:id/mfa", admin, handlers.ResetUserMFA)
+		users.POST("/verify", auth.RequireRole(auth.Internal, auth.RoleSupport, auth.RoleAdmin), handlers.VerifyUser)
 	}
 
 	// Auth
-	auth := router.Group("/auth")
+	authRoutes := router.Group("/auth")
 	{
-		auth.POST("/login", handlers.Login)
-		auth.GET("/validate", handlers.ValidateToken)
+		authRoutes.POST("/register", handlers.Register)
+		authRoutes.POST("/login", handlers.Login)
+		authRoutes.POST("/refresh", handlers.Refresh)
+		authRoutes.POST("/logout", auth.RequireAuth(verifier), handlers.Logout)
+		authRoutes.GET("/validate", handlers.ValidateToken)
+		authRoutes.POST("/service-token", handlers.IssueServiceToken)
+		authRoutes.PUT("/password", auth.RequireAuth(verifier), handlers.ChangePassword)
+		authRoutes.POST("/verify-email", handlers.VerifyEmail)
+		authRoutes.POST("/resend-verification", handlers.ResendVerification)
+		authRoutes.POST("/forgot-password", handlers.ForgotPassword)
+		authRoutes.POST("/reset-password", handlers.ResetPassword)
+
+		// MFA. Enrolment takes either an access token or the MFA token of a
+		// login that has to set up MFA first.
+		authRoutes.POST("/mfa/verify", handlers.VerifyMFA)
+		authRoutes.GET("/mfa", auth.RequireAuth(verifier), handlers.GetMFAStatus)
+		authRoutes.POST("/mfa/enroll", handlers.EnrollMFA)
+		authRoutes.POST("/mfa/confirm", handlers.ConfirmMFA)
+		authRoutes.DELETE("/mfa", auth.RequireAuth(verifier), handlers.DisableMFA)
+		authRoutes.POST("/mfa/recovery-codes", auth.RequireAuth(verifier), handlers.RegenerateRecoveryCodes)
+		authRoutes.GET("/mfa/policy", admin, handlers.GetMFAPolicy)
+		authRoutes.PUT("/mfa/policy", admin, handlers.UpdateMFAPolicy)
 	}
 
 	return router
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-order-system/user-service/api"
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/cache"
+	"github.com/online-order-system/user-service/config"
+	"github.com/online-order-system/user-service/db"
+	"github.com/online-order-system/user-service/eventbus"
+	"github.com/online-order-system/user-service/kafka"
+	"github.com/online-order-system/user-service/service"
+)
+
+// App wires together the components of the user service
+type App struct {
+	Config   *config.Config
+	Service  *service.UserService
+	Router   *gin.Engine
+	database *db.Database
+	consumer *kafka.Consumer
+	keyRing  *auth.KeyRing
+	cache    *cache.RedisCache
+}
+
+// New connects to the database and builds the user service on top of the given event bus
+func New(cfg *config.Config, bus eventbus.Bus) (*App, error) {
+	// Connect to database
+	database, err := db.NewDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	// Create tables
+	err = database.CreateTables()
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+
+	// Create repository
+	repository := db.NewUserRepository(database)
+
+	// Load token signing keys. Retired keys are kept as long as the tokens
+	// they signed can live.
+	keyRing, err := auth.NewKeyRing(cfg.JWTKeysDir, cfg.JWTExpiration)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to load signing keys: %v", err)
+	}
+
+	// Connect to Redis, which caches session revocations
+	redisCache, err := cache.NewRedisCache(cfg)
+	if err != nil {
+		log.Printf("Failed to connect to Redis: %v", err)
+		log.Println("Continuing without Redis cache...")
+		log.Println("WARNING: failed logins are not throttled and accounts are not locked out without Redis")
+		redisCache = nil
+	}
+
+	// Create Kafka producer
+	producer := kafka.NewProducer(cfg, bus)
+
+	// Create service
+	userService := service.NewUserService(cfg, repository, producer, keyRing, redisCache)
+	verifier := auth.NewVerifier(keyRing, cfg.JWTIssuer, cfg.JWTAudience).WithRevocationList(userService)
+
+	// Only the gateway is trusted to say which client a request came from
+	router := api.SetupRouter(userService, verifier)
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+
+	return &App{
+		Config:   cfg,
+		Service:  userService,
+		Router:   router,
+		database: database,
+		consumer: kafka.NewConsumer(cfg, bus, userService),
+		keyRing:  keyRing,
+		cache:    redisCache,
+	}, nil
+}
+
+// Start starts the Kafka consumers, the signing key rotation and the session
+// cleanup. They stop when ctx is cancelled.
+func (a *App) Start(ctx context.Context) {
+	a.consumer.StartConsuming(ctx)
+
+	// Rotate the signing key on schedule. Keys loaded from disk may already
+	// be due.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			if err := a.keyRing.RotateIfOlder(a.Config.JWTKeyRotation); err != nil {
+				log.Printf("Failed to rotate signing key: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Delete sessions that ended long enough ago that their refresh tokens
+	// are no longer needed to detect reuse, and expired account tokens
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			a.Service.CleanupSessions()
+			a.Service.CleanupTokens()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close closes the database and Redis connections
+func (a *App) Close() error {
+	a.cache.Close()
+	return a.database.Close()
+}
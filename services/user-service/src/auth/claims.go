@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningMethod is the JWT algorithm used for every token in the system
+var SigningMethod = jwt.SigningMethodEdDSA
+
+// Claims represents the claims carried by an access token
+type Claims struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewClaims creates the claims for a token issued to a user that expires after ttl
+func NewClaims(issuer, audience string, ttl time.Duration, userID, email, role string) *Claims {
+	now := time.Now()
+	return &Claims{
+		Email: email,
+		Role:  role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// NewServiceClaims creates the claims for a token issued to another service
+// that expires after ttl. The service name is the subject.
+func NewServiceClaims(issuer, audience string, ttl time.Duration, service string) *Claims {
+	return NewClaims(issuer, audience, ttl, service, "", Internal)
+}
+
+// UserID returns the ID of the user the token was issued to
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+// IsService reports whether the token was issued to another service
+func (c *Claims) IsService() bool {
+	return c.Role == Internal
+}
+
+// ServiceName returns the name of the service a service token was issued to
+func (c *Claims) ServiceName() string {
+	if !c.IsService() {
+		return ""
+	}
+	return c.Subject
+}
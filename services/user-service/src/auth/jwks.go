@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when no key matches the key ID of a token
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource resolves the public key a token was signed with
+type KeySource interface {
+	PublicKey(kid string) (ed25519.PublicKey, error)
+}
+
+// JWK is a single public key in JSON Web Key format (RFC 8037)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK converts an Ed25519 public key into its JWK representation
+func NewJWK(kid string, key ed25519.PublicKey) JWK {
+	return JWK{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(key),
+		KeyID:     kid,
+		Use:       "sig",
+		Algorithm: SigningMethod.Alg(),
+	}
+}
+
+// PublicKey decodes the Ed25519 public key of a JWK
+func (k JWK) PublicKey() (ed25519.PublicKey, error) {
+	if k.KeyType != "OKP" || k.Curve != "Ed25519" {
+		return nil, fmt.Errorf("unsupported key type %s/%s", k.KeyType, k.Curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s: %v", k.KeyID, err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid key %s: wrong size", k.KeyID)
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// RemoteKeySet fetches the public keys from a JWKS endpoint and caches them.
+// The keys are fetched again when a token names a key that is not cached, so
+// rotation on the issuing side is picked up without a restart.
+type RemoteKeySet struct {
+	url         string
+	client      *http.Client
+	minInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]ed25519.PublicKey
+	fetchedAt time.Time
+}
+
+// Ensure RemoteKeySet implements KeySource
+var _ KeySource = (*RemoteKeySet)(nil)
+
+// NewRemoteKeySet creates a key set backed by the JWKS document at url
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:         url,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		minInterval: 30 * time.Second,
+		keys:        make(map[string]ed25519.PublicKey),
+	}
+}
+
+// PublicKey returns the key with the given ID, refreshing the cache if needed
+func (s *RemoteKeySet) PublicKey(kid string) (ed25519.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Don't hammer the issuer with tokens signed by keys it never had
+	if time.Since(s.fetchedAt) < s.minInterval {
+		return nil, ErrUnknownKey
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh replaces the cached keys with the ones currently published
+func (s *RemoteKeySet) refresh() error {
+	s.fetchedAt = time.Now()
+
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %v", err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	s.keys = keys
+
+	return nil
+}
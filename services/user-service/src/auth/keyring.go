@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// kidTimeFormat is the creation time prefix of key IDs. Key IDs sort in the
+// order the keys were created.
+const kidTimeFormat = "20060102T150405Z"
+
+// signingKey is a key pair in the ring
+type signingKey struct {
+	id        string
+	createdAt time.Time
+	private   ed25519.PrivateKey
+}
+
+// KeyRing holds the Ed25519 keys used to sign access tokens. The newest key
+// signs; older keys are kept for verification until every token they signed
+// has expired.
+type KeyRing struct {
+	dir       string
+	retention time.Duration
+
+	mu   sync.RWMutex
+	keys []*signingKey // oldest first, the last one is current
+}
+
+// Ensure KeyRing implements KeySource
+var _ KeySource = (*KeyRing)(nil)
+
+// NewKeyRing loads the keys stored in dir, creating one if there are none. If
+// dir is empty the keys only live in memory and tokens do not survive a
+// restart. Retired keys are dropped once retention has passed since they were
+// replaced.
+func NewKeyRing(dir string, retention time.Duration) (*KeyRing, error) {
+	r := &KeyRing{
+		dir:       dir,
+		retention: retention,
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %v", err)
+		}
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.keys) == 0 {
+		if err := r.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Rotate creates a new signing key and retires the current one
+func (r *KeyRing) Rotate() error {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to generate key ID: %v", err)
+	}
+
+	now := time.Now().UTC()
+	key := &signingKey{
+		id:        now.Format(kidTimeFormat) + "-" + hex.EncodeToString(suffix),
+		createdAt: now,
+		private:   private,
+	}
+
+	if r.dir != "" {
+		if err := r.save(key); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.keys = append(r.keys, key)
+	r.prune()
+	r.mu.Unlock()
+
+	log.Printf("Rotated token signing key, current key ID: %s", key.id)
+	return nil
+}
+
+// RotateIfOlder rotates the signing key if it was created more than maxAge ago
+func (r *KeyRing) RotateIfOlder(maxAge time.Duration) error {
+	r.mu.RLock()
+	age := time.Since(r.current().createdAt)
+	r.mu.RUnlock()
+
+	if age < maxAge {
+		return nil
+	}
+	return r.Rotate()
+}
+
+// Sign signs the claims with the current key
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	key := r.current()
+	r.mu.RUnlock()
+
+	token := jwt.NewWithClaims(SigningMethod, claims)
+	token.Header["kid"] = key.id
+
+	return token.SignedString(key.private)
+}
+
+// PublicKey returns the public key with the given ID
+func (r *KeyRing) PublicKey(kid string) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.id == kid {
+			return key.private.Public().(ed25519.PublicKey), nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKS returns the public keys that are still valid for verification
+func (r *KeyRing) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(r.keys))}
+	for _, key := range r.keys {
+		jwks.Keys = append(jwks.Keys, NewJWK(key.id, key.private.Public().(ed25519.PublicKey)))
+	}
+	return jwks
+}
+
+// current returns the signing key. The caller must hold the lock.
+func (r *KeyRing) current() *signingKey {
+	return r.keys[len(r.keys)-1]
+}
+
+// prune drops the retired keys whose tokens have all expired. The caller must
+// hold the write lock.
+func (r *KeyRing) prune() {
+	now := time.Now()
+	kept := r.keys[:0]
+	for i, key := range r.keys {
+		// A key is retired when the next one is created
+		if i < len(r.keys)-1 && now.Sub(r.keys[i+1].createdAt) > r.retention {
+			if r.dir != "" {
+				if err := os.Remove(r.keyPath(key.id)); err != nil && !os.IsNotExist(err) {
+					log.Printf("Failed to remove retired signing key %s: %v", key.id, err)
+				}
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	r.keys = kept
+}
+
+// load reads the keys stored in the key directory
+func (r *KeyRing) load() error {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %v", err)
+	}
+
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		createdAt, err := time.Parse(kidTimeFormat, strings.SplitN(id, "-", 2)[0])
+		if err != nil {
+			log.Printf("Skipping signing key with unexpected name %s", path)
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key %s: %v", id, err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("failed to decode signing key %s", id)
+		}
+
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key %s: %v", id, err)
+		}
+
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("signing key %s is not an Ed25519 key", id)
+		}
+
+		r.keys = append(r.keys, &signingKey{id: id, createdAt: createdAt, private: private})
+	}
+
+	sort.Slice(r.keys, func(i, j int) bool {
+		return r.keys[i].id < r.keys[j].id
+	})
+	r.prune()
+
+	return nil
+}
+
+// save writes a key to the key directory
+func (r *KeyRing) save(key *signingKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %v", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(r.keyPath(key.id), data, 0o600); err != nil {
+		return fmt.Errorf("failed to save signing key: %v", err)
+	}
+
+	return nil
+}
+
+// keyPath returns the file a key is stored in
+func (r *KeyRing) keyPath(kid string) string {
+	return filepath.Join(r.dir, kid+".pem")
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// kids returns the IDs of the keys a ring publishes
+func kids(ring *KeyRing) map[string]bool {
+	ids := make(map[string]bool)
+	for _, key := range ring.JWKS().Keys {
+		ids[key.KeyID] = true
+	}
+	return ids
+}
+
+// currentKid returns the ID of the key a ring signs with
+func currentKid(ring *KeyRing) string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.current().id
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	ring := newTestRing(t)
+	verifier := NewVerifier(ring, testIssuer, testAudience)
+	claims := NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleCustomer)
+
+	before := sign(t, ring, claims)
+	first := currentKid(ring)
+
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	second := currentKid(ring)
+	if second == first {
+		t.Fatal("rotation kept the signing key")
+	}
+
+	// New tokens are signed with the new key, and tokens signed with the
+	// retired key stay valid
+	after := sign(t, ring, claims)
+	for name, token := range map[string]string{"before": before, "after": after} {
+		if _, err := verifier.Verify(token); err != nil {
+			t.Errorf("token signed %s rotation: %v", name, err)
+		}
+	}
+
+	published := kids(ring)
+	if len(published) != 2 || !published[first] || !published[second] {
+		t.Errorf("JWKS has keys %v, want %s and %s", published, first, second)
+	}
+}
+
+func TestKeyRingPrunesRetiredKeys(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := NewKeyRing(dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	verifier := NewVerifier(ring, testIssuer, testAudience)
+
+	first := currentKid(ring)
+	before := sign(t, ring, NewClaims(testIssuer, testAudience, time.Minute, "user-1", "", RoleCustomer))
+
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	// Still within retention of its retirement
+	if _, err := ring.PublicKey(first); err != nil {
+		t.Fatalf("key retired just now was dropped: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	if _, err := ring.PublicKey(first); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("pruned key: got %v, want ErrUnknownKey", err)
+	}
+	if kids(ring)[first] {
+		t.Errorf("JWKS still publishes pruned key %s", first)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first+".pem")); !os.IsNotExist(err) {
+		t.Errorf("file of pruned key is still there: %v", err)
+	}
+	if _, err := verifier.Verify(before); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with pruned key: got %v, want ErrInvalidToken", err)
+	}
+
+	// The key retired last is kept until its own retention passes
+	if len(kids(ring)) != 2 {
+		t.Errorf("JWKS has keys %v, want the current and the last retired key", kids(ring))
+	}
+}
+
+func TestKeyRingRotateIfOlder(t *testing.T) {
+	ring := newTestRing(t)
+	first := currentKid(ring)
+
+	if err := ring.RotateIfOlder(time.Hour); err != nil {
+		t.Fatalf("RotateIfOlder: %v", err)
+	}
+	if currentKid(ring) != first {
+		t.Error("key younger than an hour was rotated")
+	}
+
+	if err := ring.RotateIfOlder(0); err != nil {
+		t.Fatalf("RotateIfOlder: %v", err)
+	}
+	if currentKid(ring) == first {
+		t.Error("key older than the maximum age was not rotated")
+	}
+}
+
+func TestKeyRingKeepsKeysAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := NewKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	claims := NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleCustomer)
+	before := sign(t, ring, claims)
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	after := sign(t, ring, claims)
+
+	reloaded, err := NewKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeyRing after restart: %v", err)
+	}
+	if got, want := kids(reloaded), kids(ring); len(got) != len(want) {
+		t.Errorf("reloaded ring has keys %v, want %v", got, want)
+	}
+
+	verifier := NewVerifier(reloaded, testIssuer, testAudience)
+	for name, token := range map[string]string{"before": before, "after": after} {
+		if _, err := verifier.Verify(token); err != nil {
+			t.Errorf("token signed %s rotation, after restart: %v", name, err)
+		}
+	}
+}
+
+func TestKeyRingSkipsUnexpectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.pem"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := NewKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	if len(kids(ring)) != 1 {
+		t.Errorf("ring has keys %v, want one new key", kids(ring))
+	}
+}
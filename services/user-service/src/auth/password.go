@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters, following the OWASP recommendation for interactive logins
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// maxPasswordLength bounds the work a single login attempt can cause
+const maxPasswordLength = 128
+
+// ErrWeakPassword is returned when a password does not satisfy the policy
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// commonPasswords are rejected regardless of length
+var commonPasswords = map[string]bool{
+	"password123":  true,
+	"password1234": true,
+	"qwerty123456": true,
+	"1234567890":   true,
+	"123456789012": true,
+	"iloveyou123":  true,
+	"letmein12345": true,
+	"welcome12345": true,
+	"admin1234567": true,
+	"changeme1234": true,
+}
+
+// HashPassword hashes a password with argon2id. The result is a PHC string
+// that carries the parameters and salt, so they can change without
+// invalidating existing hashes.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// passwordHash is a decoded argon2id hash with its parameters
+type passwordHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// VerifyPassword reports whether password matches a hash created by
+// HashPassword, with the parameters of the time the hash was created
+func VerifyPassword(encoded, password string) (bool, error) {
+	hash, err := decodePasswordHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), hash.salt, hash.time, hash.memory, hash.threads, uint32(len(hash.key)))
+	return subtle.ConstantTimeCompare(hash.key, candidate) == 1, nil
+}
+
+// NeedsRehash reports whether a hash was created with other parameters than
+// HashPassword uses now. It should be replaced the next time the password is
+// checked.
+func NeedsRehash(encoded string) bool {
+	hash, err := decodePasswordHash(encoded)
+	if err != nil {
+		return true
+	}
+	return hash.memory != argon2Memory || hash.time != argon2Time || hash.threads != argon2Threads ||
+		len(hash.salt) != argon2SaltLen || len(hash.key) != argon2KeyLen
+}
+
+// decodePasswordHash reads the parameters, salt and key of a PHC string
+func decodePasswordHash(encoded string) (passwordHash, error) {
+	var hash passwordHash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return hash, errors.New("unsupported password hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return hash, errors.New("unsupported argon2 version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.memory, &hash.time, &hash.threads); err != nil {
+		return hash, fmt.Errorf("invalid password hash parameters: %v", err)
+	}
+
+	var err error
+	hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return hash, fmt.Errorf("invalid password hash salt: %v", err)
+	}
+
+	hash.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return hash, fmt.Errorf("invalid password hash key: %v", err)
+	}
+
+	return hash, nil
+}
+
+// PasswordPolicy describes what a new password must look like
+type PasswordPolicy struct {
+	MinLength int
+}
+
+// Validate checks a new password against the policy. The email of the account
+// is used to reject passwords built from it.
+func (p PasswordPolicy) Validate(password, email string) error {
+	if len(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrWeakPassword, maxPasswordLength)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: must contain both letters and digits", ErrWeakPassword)
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: too common", ErrWeakPassword)
+	}
+
+	local := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+	if len(local) >= 3 && strings.Contains(lower, local) {
+		return fmt.Errorf("%w: must not contain the email address", ErrWeakPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// hashWith hashes a password with the given argon2id parameters, the way an
+// earlier version of HashPassword would have
+func hashWith(password string, memory, time uint32, threads uint8) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("Passw0rd!23")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	prefix := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$", argon2.Version, argon2Memory, argon2Time, argon2Threads)
+	if !strings.HasPrefix(hash, prefix) {
+		t.Errorf("hash %s doesn't start with %s", hash, prefix)
+	}
+
+	ok, err := VerifyPassword(hash, "Passw0rd!23")
+	if err != nil || !ok {
+		t.Errorf("password doesn't match its hash: %t, %v", ok, err)
+	}
+	ok, err = VerifyPassword(hash, "Passw0rd!24")
+	if err != nil || ok {
+		t.Errorf("wrong password matched: %t, %v", ok, err)
+	}
+
+	if NeedsRehash(hash) {
+		t.Error("fresh hash needs rehashing")
+	}
+
+	// Every hash has its own salt
+	other, _ := HashPassword("Passw0rd!23")
+	if other == hash {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestVerifyPasswordWithOlderParameters(t *testing.T) {
+	// Hashes made before the parameters were raised still verify, and are
+	// marked for an upgrade
+	old := hashWith("Passw0rd!23", 19*1024, 2, 1)
+
+	ok, err := VerifyPassword(old, "Passw0rd!23")
+	if err != nil || !ok {
+		t.Errorf("password doesn't match its old hash: %t, %v", ok, err)
+	}
+	ok, _ = VerifyPassword(old, "wrong-password1")
+	if ok {
+		t.Error("wrong password matched an old hash")
+	}
+	if !NeedsRehash(old) {
+		t.Error("old hash doesn't need rehashing")
+	}
+
+	current := hashWith("Passw0rd!23", argon2Memory, argon2Time, argon2Threads)
+	if NeedsRehash(current) {
+		t.Error("hash with the current parameters needs rehashing")
+	}
+}
+
+func TestVerifyPasswordRejectsOtherFormats(t *testing.T) {
+	valid := hashWith("Passw0rd!23", argon2Memory, argon2Time, argon2Threads)
+	parts := strings.Split(valid, "$")
+
+	hashes := map[string]string{
+		"bcrypt":       "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"plain text":   "Passw0rd!23",
+		"argon2i":      strings.Replace(valid, "$argon2id$", "$argon2i$", 1),
+		"old version":  strings.Replace(valid, "$v=19$", "$v=16$", 1),
+		"no params":    strings.Join([]string{"", parts[1], parts[2], "m=x", parts[4], parts[5]}, "$"),
+		"bad salt":     strings.Join([]string{"", parts[1], parts[2], parts[3], "!!!", parts[5]}, "$"),
+		"bad key":      strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], "!!!"}, "$"),
+		"missing part": strings.Join(parts[:5], "$"),
+	}
+	for name, hash := range hashes {
+		if ok, err := VerifyPassword(hash, "Passw0rd!23"); err == nil || ok {
+			t.Errorf("%s hash verified: %t, %v", name, ok, err)
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("%s hash doesn't need rehashing", name)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10}
+
+	valid := []string{"Passw0rd!23", "correct horse 42", "mật khẩu dài 2024"}
+	for _, password := range valid {
+		if err := policy.Validate(password, "cu@example.com"); err != nil {
+			t.Errorf("%q was refused: %v", password, err)
+		}
+	}
+
+	invalid := []string{
+		"Sh0rt",
+		"onlyletterspassword",
+		"12345678901234",
+		"Password123",
+		"cuong12345678",
+		strings.Repeat("a1", maxPasswordLength),
+	}
+	for _, password := range invalid {
+		if err := policy.Validate(password, "cuong@example.com"); !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("%q was not refused as weak: %v", password, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles a user can have. Customers only reach their own data; staff roles
+// reach the data of every customer within their area.
+const (
+	RoleCustomer  = "customer"
+	RoleSupport   = "support"
+	RoleWarehouse = "warehouse"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role a user can have
+var Roles = []string{RoleCustomer, RoleSupport, RoleWarehouse, RoleAdmin}
+
+// Internal is the role of service tokens, which user-service issues to other
+// services for calling each other. It is granted like any other role.
+const Internal = "internal"
+
+// Everyone lists every role together with Internal, for routes open to any
+// authenticated caller whose handlers check ownership with CanAccess
+var Everyone = append([]string{Internal}, Roles...)
+
+// GatewayHeader is set by the API gateway on every request it forwards.
+// Service tokens are refused on requests that carry it, so a leaked service
+// token can't be used from outside the network.
+const GatewayHeader = "X-Gateway-Request"
+
+// roleRanks orders the roles by what they may do. Staff may only manage
+// accounts whose role doesn't rank above their own.
+var roleRanks = map[string]int{
+	RoleCustomer:  0,
+	RoleSupport:   1,
+	RoleWarehouse: 1,
+	RoleAdmin:     2,
+}
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	return contains(Roles, role)
+}
+
+// Outranks reports whether role ranks above other
+func Outranks(role, other string) bool {
+	return roleRanks[role] > roleRanks[other]
+}
+
+// HasRole reports whether the token carries one of the roles
+func (c *Claims) HasRole(roles ...string) bool {
+	return contains(roles, c.Role)
+}
+
+// IsInternal reports whether a request was made by another service with a
+// service token
+func IsInternal(c *gin.Context) bool {
+	claims, ok := ClaimsFromContext(c)
+	return ok && claims.IsService()
+}
+
+// Caller names the authenticated caller of a request for request and audit
+// logs: service:<name> for other services, user:<id> for users and anonymous
+// for requests without a token
+func Caller(c *gin.Context) string {
+	claims, ok := ClaimsFromContext(c)
+	if !ok {
+		return "anonymous"
+	}
+	if claims.IsService() {
+		return "service:" + claims.ServiceName()
+	}
+	return "user:" + claims.UserID()
+}
+
+// RequireRole rejects requests unless the caller has one of the roles. Include
+// Internal to let other services call the route as well.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		if !claims.HasRole(roles...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CanAccess reports whether the caller may access data owned by ownerID. The
+// owner, other services and the given staff roles may.
+func CanAccess(c *gin.Context, ownerID string, staff ...string) bool {
+	claims, ok := ClaimsFromContext(c)
+	if !ok {
+		return false
+	}
+	return claims.IsService() || claims.UserID() == ownerID || claims.HasRole(staff...)
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
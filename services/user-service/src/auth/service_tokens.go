@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrNoServiceCredentials is returned when a service has to call another
+// service but was given no credentials to get a service token with
+var ErrNoServiceCredentials = errors.New("no service credentials configured")
+
+// serviceTokenRenewal is how long before it expires a service token is
+// replaced, so a token never expires while a request is in flight
+const serviceTokenRenewal = 30 * time.Second
+
+// TokenSource provides the token a service calls other services with
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// ServiceTokenRequest is the body of a request for a service token
+type ServiceTokenRequest struct {
+	Service string `json:"service" binding:"required"`
+	Secret  string `json:"secret" binding:"required"`
+}
+
+// ServiceTokenResponse is the answer to a request for a service token
+type ServiceTokenResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"` // Seconds
+}
+
+// ServiceTokens gets short-lived service tokens from user-service with the
+// credentials of a service and caches them until shortly before they expire
+type ServiceTokens struct {
+	url     string
+	service string
+	secret  string
+	client  *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Ensure ServiceTokens implements TokenSource
+var _ TokenSource = (*ServiceTokens)(nil)
+
+// NewServiceTokens creates a token source for the named service that requests
+// tokens from the service token endpoint at url
+func NewServiceTokens(url, service, secret string) *ServiceTokens {
+	return &ServiceTokens{
+		url:     url,
+		service: service,
+		secret:  secret,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Token returns a valid service token, requesting a new one if needed
+func (s *ServiceTokens) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > serviceTokenRenewal {
+		return s.token, nil
+	}
+
+	if s.secret == "" {
+		return "", ErrNoServiceCredentials
+	}
+
+	body, err := json.Marshal(ServiceTokenRequest{Service: s.service, Secret: s.secret})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to get service token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get service token: status %d", resp.StatusCode)
+	}
+
+	var token ServiceTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode service token: %v", err)
+	}
+
+	s.token = token.Token
+	s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// NewClient creates an HTTP client that calls other services with a token
+// from tokens
+func NewClient(tokens TokenSource, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &serviceTransport{tokens: tokens, base: http.DefaultTransport},
+	}
+}
+
+// serviceTransport adds a service token to every request it sends
+type serviceTransport struct {
+	tokens TokenSource
+	base   http.RoundTripper
+}
+
+// RoundTrip sends a request with a service token
+func (t *serviceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not change the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the amount of randomness in an opaque token
+const tokenBytes = 32
+
+// NewToken creates a random opaque token, such as a refresh, email
+// verification or password reset token. Only its hash is meant to be stored,
+// so a leaked database can't be used to take over accounts.
+func NewToken() (token string, hash string, err error) {
+	data := make([]byte, tokenBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(data)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash an opaque token is stored under
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
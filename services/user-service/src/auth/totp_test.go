@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 secret of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, v := range vectors {
+		code, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode at %d: %v", v.unix, err)
+		}
+		if want := v.code[2:]; code != want {
+			t.Errorf("code at %d is %s, want %s", v.unix, code, want)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowercaseSecret(t *testing.T) {
+	code, err := TOTPCode(strings.ToLower(rfcSecret), TOTPStep(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("got %s, %v, want 287082", code, err)
+	}
+}
+
+func TestTOTPCodeRejectsInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("invalid secret was accepted")
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, err := TOTPCode(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+
+		step, ok := VerifyTOTP(rfcSecret, code, now)
+		inWindow := offset >= -totpSkew && offset <= totpSkew
+		if ok != inWindow {
+			t.Errorf("code %d steps away accepted %t, want %t", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code %d steps away verified for step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestVerifyTOTPReportsStepForReplayCheck(t *testing.T) {
+	// A code stays valid for the whole window, so it verifies again a
+	// little later. Callers refuse it by the step it belongs to, which is
+	// the same both times.
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfcSecret, TOTPStep(now))
+
+	first, ok := VerifyTOTP(rfcSecret, code, now)
+	if !ok {
+		t.Fatal("current code was refused")
+	}
+	again, ok := VerifyTOTP(rfcSecret, code, now.Add(totpPeriod))
+	if !ok {
+		t.Fatal("code of the previous step was refused")
+	}
+	if first != again {
+		t.Errorf("same code verified for steps %d and %d", first, again)
+	}
+}
+
+func TestVerifyTOTPRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870822", "94287082", "abcdef", "287083"} {
+		if _, ok := VerifyTOTP(rfcSecret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+	if _, ok := VerifyTOTP(rfcSecret, " 287082 ", now); !ok {
+		t.Error("code with spaces around it was refused")
+	}
+	if _, ok := VerifyTOTP("not base32!", "287082", now); ok {
+		t.Error("code was accepted for an invalid secret")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Errorf("secret %s decodes to %d bytes (%v), want %d", secret, len(key), err, totpSecretBytes)
+	}
+
+	other, _ := NewTOTPSecret()
+	if other == secret {
+		t.Error("two secrets are the same")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Online Order System", "cu@example.com", rfcSecret)
+	for _, part := range []string{
+		"otpauth://totp/Online%20Order%20System:cu@example.com?",
+		"secret=" + rfcSecret,
+		"issuer=Online+Order+System",
+		"algorithm=SHA1",
+		"digits=6",
+		"period=30",
+	} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("URI %s is missing %s", uri, part)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("got %d codes and %d hashes, want 10 of each", len(codes), len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("code %s was generated twice", code)
+		}
+		seen[code] = true
+
+		if hashes[i] != HashRecoveryCode(code) {
+			t.Errorf("hash of code %s doesn't match", code)
+		}
+		// Codes can be typed the way they read
+		typed := strings.ToUpper(strings.Replace(code, "-", " ", 1))
+		if HashRecoveryCode(typed) != hashes[i] {
+			t.Errorf("code %s typed as %s doesn't match", code, typed)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "user-service"
+	testAudience = "online-order-system"
+)
+
+// revocations is a RevocationList of revoked session IDs
+type revocations struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (r revocations) IsRevoked(sessionID string) (bool, error) {
+	return r.revoked[sessionID], r.err
+}
+
+// newTestRing creates a key ring that lives in memory
+func newTestRing(t *testing.T) *KeyRing {
+	t.Helper()
+	ring, err := NewKeyRing("", time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	return ring
+}
+
+// sign signs claims with ring, failing the test on error
+func sign(t *testing.T, ring *KeyRing, claims jwt.Claims) string {
+	t.Helper()
+	token, err := ring.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func TestVerifierAcceptsValidToken(t *testing.T) {
+	ring := newTestRing(t)
+	verifier := NewVerifier(ring, testIssuer, testAudience)
+
+	claims := NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleSupport)
+	claims.SessionID = "session-1"
+	got, err := verifier.Verify(sign(t, ring, claims))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.UserID() != "user-1" || got.Email != "cu@example.com" || got.Role != RoleSupport || got.SessionID != "session-1" {
+		t.Errorf("got claims %+v", got)
+	}
+	if got.IsService() {
+		t.Error("user token is a service token")
+	}
+}
+
+func TestVerifierAcceptsServiceToken(t *testing.T) {
+	ring := newTestRing(t)
+	verifier := NewVerifier(ring, testIssuer, testAudience)
+
+	got, err := verifier.Verify(sign(t, ring, NewServiceClaims(testIssuer, testAudience, time.Minute, "order-service")))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !got.IsService() || got.ServiceName() != "order-service" {
+		t.Errorf("got claims %+v, want a service token of order-service", got)
+	}
+}
+
+func TestVerifierRejectsInvalidTokens(t *testing.T) {
+	ring := newTestRing(t)
+	other := newTestRing(t)
+	valid := func() *Claims {
+		return NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleCustomer)
+	}
+
+	expired := valid()
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expired.NotBefore = expired.IssuedAt
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	noExpiry := valid()
+	noExpiry.ExpiresAt = nil
+
+	notYet := valid()
+	notYet.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	noSubject := valid()
+	noSubject.Subject = ""
+
+	noKid := jwt.NewWithClaims(SigningMethod, valid())
+	noKidToken, err := noKid.SignedString(ring.current().private)
+	if err != nil {
+		t.Fatalf("failed to sign token without kid: %v", err)
+	}
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	hmac.Header["kid"] = ring.current().id
+	hmacToken, err := hmac.SignedString([]byte("shared secret"))
+	if err != nil {
+		t.Fatalf("failed to sign HS256 token: %v", err)
+	}
+
+	tokens := map[string]string{
+		"expired":         sign(t, ring, expired),
+		"without expiry":  sign(t, ring, noExpiry),
+		"not yet valid":   sign(t, ring, notYet),
+		"wrong audience":  sign(t, ring, NewClaims(testIssuer, "another-system", time.Minute, "user-1", "", RoleCustomer)),
+		"wrong issuer":    sign(t, ring, NewClaims("someone-else", testAudience, time.Minute, "user-1", "", RoleCustomer)),
+		"unknown kid":     sign(t, other, valid()),
+		"without kid":     noKidToken,
+		"without subject": sign(t, ring, noSubject),
+		"signed by HS256": hmacToken,
+		"tampered":        sign(t, ring, valid()) + "x",
+		"not a token":     "not-a-token",
+	}
+
+	verifier := NewVerifier(ring, testIssuer, testAudience)
+	for name, token := range tokens {
+		if _, err := verifier.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s token: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestVerifierRejectsRevokedSessions(t *testing.T) {
+	ring := newTestRing(t)
+	list := revocations{revoked: map[string]bool{"revoked": true}}
+	verifier := NewVerifier(ring, testIssuer, testAudience).WithRevocationList(list)
+
+	claims := NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleCustomer)
+	claims.SessionID = "revoked"
+	if _, err := verifier.Verify(sign(t, ring, claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a revoked session: got %v, want ErrInvalidToken", err)
+	}
+
+	claims.SessionID = "active"
+	if _, err := verifier.Verify(sign(t, ring, claims)); err != nil {
+		t.Errorf("token of an active session: %v", err)
+	}
+
+	// Service tokens have no session
+	if _, err := verifier.Verify(sign(t, ring, NewServiceClaims(testIssuer, testAudience, time.Minute, "order-service"))); err != nil {
+		t.Errorf("service token: %v", err)
+	}
+
+	// A failed lookup is an error, but not an invalid token
+	failing := NewVerifier(ring, testIssuer, testAudience).WithRevocationList(revocations{err: errors.New("database is down")})
+	if _, err := failing.Verify(sign(t, ring, claims)); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("failed revocation lookup: got %v, want an error other than ErrInvalidToken", err)
+	}
+}
+
+func TestVerifierWithRemoteKeySetFollowsRotation(t *testing.T) {
+	ring := newTestRing(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ring.JWKS())
+	}))
+	defer server.Close()
+
+	keys := NewRemoteKeySet(server.URL)
+	verifier := NewVerifier(keys, testIssuer, testAudience)
+	claims := NewClaims(testIssuer, testAudience, time.Minute, "user-1", "cu@example.com", RoleCustomer)
+
+	before := sign(t, ring, claims)
+	if _, err := verifier.Verify(before); err != nil {
+		t.Fatalf("token signed before rotation: %v", err)
+	}
+
+	// The key set fetches the keys again when a token names a new key
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	keys.minInterval = 0
+	if _, err := verifier.Verify(sign(t, ring, claims)); err != nil {
+		t.Errorf("token signed after rotation: %v", err)
+	}
+	if _, err := verifier.Verify(before); err != nil {
+		t.Errorf("token signed before rotation, after rotation: %v", err)
+	}
+
+	// Unknown keys don't make it fetch the keys on every token
+	keys.minInterval = time.Hour
+	if _, err := keys.PublicKey("unknown"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("unknown key: got %v, want ErrUnknownKey", err)
+	}
+}
@@ -2,14 +2,22 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the service
 type Config struct {
 	// Server configuration
 	ServerPort string
+	// Addresses of the proxies, the gateway, trusted to say which client a
+	// request came from. Clients are identified by their own address otherwise.
+	TrustedProxies []string
 
 	// Database configuration
+	DBDriver   string
+	DBDSN      string
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -19,15 +27,74 @@ type Config struct {
 	// Kafka configuration
 	KafkaBootstrapServers string
 	KafkaTopic            string
+	KafkaUserTopic        string
+	KafkaPrivacyTopic     string
+	EventBus              string
+
+	// Redis configuration
+	RedisHost     string
+	RedisPort     string
+	RedisPassword string
+	RedisDB       int
+
+	// JWT configuration
+	JWTIssuer      string
+	JWTAudience    string
+	JWTExpiration  time.Duration
+	JWTKeysDir     string
+	JWTKeyRotation time.Duration
+
+	// Session configuration
+	RefreshTokenTTL time.Duration
+
+	// Service tokens. Other services get short-lived tokens for calling each
+	// other with the secret listed here under their name.
+	ServiceCredentials map[string]string
+	ServiceTokenTTL    time.Duration
+
+	// Password policy
+	PasswordMinLength int
+
+	// Account recovery configuration
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	PasswordResetTTL         time.Duration
+	AppBaseURL               string // Links in account emails point to the frontend
+
+	// Multi-factor authentication configuration
+	MFAIssuer       string // Name authenticator apps show next to the account
+	MFAChallengeTTL time.Duration
+
+	// Login throttling configuration
+	LoginMaxFailures     int // Failed logins of an account before it is locked
+	LoginIPMaxFailures   int // Failed logins from an IP address before it is blocked
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+
+	// Services holding personal data of customers, which data exports
+	// gather from and erasures wait for
+	OrderServiceURL        string
+	PaymentServiceURL      string
+	ShippingServiceURL     string
+	NotificationServiceURL string
+	CartServiceURL         string
+	InventoryServiceURL    string
+	DataExportTimeout      time.Duration
+
+	// Accounts registered with these emails get the admin role
+	AdminEmails []string
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	return &Config{
 		// Server configuration
-		ServerPort: getEnv("PORT", "8086"),
+		ServerPort:     getEnv("PORT", "8086"),
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 
 		// Database configuration
+		DBDriver:   getEnv("DB_DRIVER", "postgres"),
+		DBDSN:      getEnv("DB_DSN", ""),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5433"),
 		DBUser:     getEnv("DB_USER", "useruser"),
@@ -37,6 +104,60 @@ func LoadConfig() *Config {
 		// Kafka configuration
 		KafkaBootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:9092"),
 		KafkaTopic:            getEnv("KAFKA_TOPIC", "orders"),
+		KafkaUserTopic:        getEnv("KAFKA_USER_TOPIC", "users"),
+		KafkaPrivacyTopic:     getEnv("KAFKA_PRIVACY_TOPIC", "privacy"),
+		EventBus:              getEnv("EVENT_BUS", "kafka"),
+
+		// Redis configuration
+		RedisHost:     getEnv("REDIS_HOST", "localhost"),
+		RedisPort:     getEnv("REDIS_PORT", "6379"),
+		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		RedisDB:       getEnvAsInt("REDIS_DB", 2),
+
+		// JWT configuration
+		JWTIssuer:      getEnv("JWT_ISSUER", "user-service"),
+		JWTAudience:    getEnv("JWT_AUDIENCE", "online-order-system"),
+		JWTExpiration:  time.Duration(getEnvAsInt("JWT_EXPIRATION", 900)) * time.Second,
+		JWTKeysDir:     getEnv("JWT_KEYS_DIR", ""),
+		JWTKeyRotation: time.Duration(getEnvAsInt("JWT_KEY_ROTATION", 86400)) * time.Second,
+
+		// Session configuration
+		RefreshTokenTTL: time.Duration(getEnvAsInt("REFRESH_TOKEN_TTL", 2592000)) * time.Second,
+
+		// Service tokens
+		ServiceCredentials: getEnvAsMap("SERVICE_CREDENTIALS"),
+		ServiceTokenTTL:    time.Duration(getEnvAsInt("SERVICE_TOKEN_TTL", 300)) * time.Second,
+
+		// Password policy
+		PasswordMinLength: getEnvAsInt("PASSWORD_MIN_LENGTH", 10),
+
+		// Account recovery configuration
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationTTL:     time.Duration(getEnvAsInt("EMAIL_VERIFICATION_TTL", 86400)) * time.Second,
+		PasswordResetTTL:         time.Duration(getEnvAsInt("PASSWORD_RESET_TTL", 3600)) * time.Second,
+		AppBaseURL:               strings.TrimSuffix(getEnv("APP_BASE_URL", "http://localhost:3000"), "/"),
+
+		// Multi-factor authentication configuration
+		MFAIssuer:       getEnv("MFA_ISSUER", "Online Order System"),
+		MFAChallengeTTL: time.Duration(getEnvAsInt("MFA_CHALLENGE_TTL", 300)) * time.Second,
+
+		// Login throttling configuration
+		LoginMaxFailures:     getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:   getEnvAsInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginFailureWindow:   time.Duration(getEnvAsInt("LOGIN_FAILURE_WINDOW", 900)) * time.Second,
+		LoginLockoutDuration: time.Duration(getEnvAsInt("LOGIN_LOCKOUT_DURATION", 900)) * time.Second,
+
+		// Services holding personal data of customers
+		OrderServiceURL:        getEnv("ORDER_SERVICE_URL", "http://order-service:8081"),
+		PaymentServiceURL:      getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8083"),
+		ShippingServiceURL:     getEnv("SHIPPING_SERVICE_URL", "http://shipping-service:8084"),
+		NotificationServiceURL: getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8085"),
+		CartServiceURL:         getEnv("CART_SERVICE_URL", "http://cart-service:8087"),
+		InventoryServiceURL:    getEnv("INVENTORY_SERVICE_URL", "http://inventory-service:8082"),
+		DataExportTimeout:      time.Duration(getEnvAsInt("DATA_EXPORT_TIMEOUT", 30)) * time.Second,
+
+		// Accounts registered with these emails get the admin role
+		AdminEmails: getEnvAsList("ADMIN_EMAILS"),
 	}
 }
 
@@ -48,3 +169,42 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvAsInt gets an environment variable as an integer or returns a default value
+func getEnvAsInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsBool gets an environment variable as a boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma separated environment variable as a list
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsMap gets a comma separated list of name=value pairs as a map
+func getEnvAsMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range getEnvAsList(key) {
+		name, value, ok := strings.Cut(pair, "=")
+		if name = strings.TrimSpace(name); ok && name != "" {
+			values[name] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
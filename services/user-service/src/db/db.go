@@ -10,19 +10,29 @@ import (
 	"github.com/online-order-system/user-service/config"
 )
 
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 // Database represents a database connection
 type Database struct {
 	*sql.DB
+	Driver string
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg *config.Config) (*Database, error) {
 	// Create connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	connStr := cfg.DBDSN
+	if connStr == "" {
+		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open(cfg.DBDriver, connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -38,9 +48,9 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	log.Println("Connected to PostgreSQL database")
+	log.Printf("Connected to %s database", cfg.DBDriver)
 
-	return &Database{db}, nil
+	return &Database{DB: db, Driver: cfg.DBDriver}, nil
 }
 
 // CreateTables creates the necessary tables if they don't exist
@@ -54,6 +64,9 @@ func (db *Database) CreateTables() error {
 		last_name VARCHAR(50) NOT NULL,
 		phone VARCHAR(20),
 		address TEXT,
+		locale VARCHAR(10) NOT NULL DEFAULT 'en',
+		role VARCHAR(20) NOT NULL DEFAULT 'customer',
+		status VARCHAR(30) NOT NULL DEFAULT 'active',
 		created_at TIMESTAMP NOT NULL,
 		updated_at TIMESTAMP NOT NULL
 	)
@@ -62,6 +75,14 @@ func (db *Database) CreateTables() error {
 		return err
 	}
 
+	// Add the status column to users tables created before accounts had a
+	// status. Existing accounts stay active. SQLite databases are always
+	// created with it, so the error there is expected.
+	_, _ = db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'active'`)
+
+	// Add the locale column the same way. Existing accounts get the default.
+	_, _ = db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en'`)
+
 	// Create user_orders table
 	_, err = db.Exec(`
 	CREATE TABLE IF NOT EXISTS user_orders (
@@ -76,6 +97,174 @@ func (db *Database) CreateTables() error {
 		return err
 	}
 
+	// Create user_credentials table. Password hashes are kept apart from the
+	// profile so they are never selected along with it.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS user_credentials (
+		user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id),
+		password_hash TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create user_sessions table
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id),
+		user_agent TEXT NOT NULL,
+		ip_address VARCHAR(45) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		last_used_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create refresh_tokens table. Only hashes are stored; a token that was
+	// already used marks its session as compromised.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		session_id VARCHAR(36) NOT NULL REFERENCES user_sessions(id),
+		created_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create user_tokens table for email verification and password reset
+	// tokens. Like refresh tokens, only hashes are stored.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id),
+		purpose VARCHAR(30) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add the attempts column to user_tokens tables created before MFA
+	// challenges counted failed codes
+	_, _ = db.Exec(`ALTER TABLE user_tokens ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0`)
+
+	// Create user_mfa table. The TOTP secret is kept apart from the profile,
+	// like the password hash.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id),
+		secret VARCHAR(64) NOT NULL,
+		enabled_at TIMESTAMP,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create mfa_recovery_codes table. Only hashes are stored.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		code_hash VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create mfa_required_roles table listing the roles that have to use MFA
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS mfa_required_roles (
+		role VARCHAR(20) PRIMARY KEY,
+		updated_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create user_addresses table holding the address book of each user
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS user_addresses (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id),
+		recipient VARCHAR(100) NOT NULL,
+		phone VARCHAR(20) NOT NULL,
+		line1 VARCHAR(200) NOT NULL,
+		line2 VARCHAR(200) NOT NULL DEFAULT '',
+		ward VARCHAR(100) NOT NULL DEFAULT '',
+		district VARCHAR(100) NOT NULL DEFAULT '',
+		city VARCHAR(100) NOT NULL DEFAULT '',
+		province VARCHAR(100) NOT NULL DEFAULT '',
+		postal_code VARCHAR(20) NOT NULL DEFAULT '',
+		country VARCHAR(2) NOT NULL,
+		default_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+		default_billing BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_user_addresses_user_id ON user_addresses(user_id)`)
+	if err != nil {
+		return err
+	}
+
+	// Create erasure_requests table. Requests are kept after the account is
+	// erased, as the record that the erasure was done, so they don't
+	// reference users.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS erasure_requests (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		requested_by VARCHAR(36) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create erasure_request_services table holding the report of each
+	// service taking part in an erasure
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS erasure_request_services (
+		request_id VARCHAR(36) NOT NULL REFERENCES erasure_requests(id),
+		service VARCHAR(50) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		erased INTEGER NOT NULL DEFAULT 0,
+		retained INTEGER NOT NULL DEFAULT 0,
+		note TEXT NOT NULL DEFAULT '',
+		completed_at TIMESTAMP,
+		PRIMARY KEY (request_id, service)
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
 	log.Println("Database tables created or already exist")
 	return nil
 }
@@ -21,10 +21,62 @@ func NewUserRepository(db *Database) *UserRepository {
 // CreateUser creates a new user in the database
 func (r *UserRepository) CreateUser(user models.Customer) error {
 	_, err := r.db.Exec(
-		`INSERT INTO users (id, email, first_name, last_name, phone, address, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		user.ID, user.Email, user.FirstName, user.LastName, user.Phone, user.Address,
-		user.CreatedAt, user.UpdatedAt,
+		`INSERT INTO users (id, email, first_name, last_name, phone, address, locale, role, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		user.ID, user.Email, user.FirstName, user.LastName, user.Phone, user.Address, user.Locale,
+		user.Role, user.Status, user.CreatedAt, user.UpdatedAt,
+	)
+	return err
+}
+
+// CreateUserWithPassword creates a new user together with its password hash
+func (r *UserRepository) CreateUserWithPassword(user models.Customer, passwordHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO users (id, email, first_name, last_name, phone, address, locale, role, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		user.ID, user.Email, user.FirstName, user.LastName, user.Phone, user.Address, user.Locale,
+		user.Role, user.Status, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user_credentials (user_id, password_hash, updated_at) VALUES ($1, $2, $3)`,
+		user.ID, passwordHash, user.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPasswordHash retrieves the password hash of a user
+func (r *UserRepository) GetPasswordHash(userID string) (string, error) {
+	var passwordHash string
+	err := r.db.QueryRow(
+		`SELECT password_hash FROM user_credentials WHERE user_id = $1`,
+		userID,
+	).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return "", errors.New("credentials not found")
+	}
+	return passwordHash, err
+}
+
+// SetPasswordHash stores the password hash of a user, replacing any existing one
+func (r *UserRepository) SetPasswordHash(userID string, passwordHash string) error {
+	_, err := r.db.Exec(
+		`INSERT INTO user_credentials (user_id, password_hash, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET password_hash = EXCLUDED.password_hash, updated_at = EXCLUDED.updated_at`,
+		userID, passwordHash, time.Now(),
 	)
 	return err
 }
@@ -33,15 +85,15 @@ func (r *UserRepository) CreateUser(user models.Customer) error {
 func (r *UserRepository) GetUserByID(id string) (models.Customer, error) {
 	var user models.Customer
 	err := r.db.QueryRow(
-		`SELECT id, email, first_name, last_name, phone, address, created_at, updated_at
+		`SELECT id, email, first_name, last_name, phone, address, locale, role, status, created_at, updated_at
 		FROM users WHERE id = $1`,
 		id,
 	).Scan(
 		&user.ID, &user.Email, &user.FirstName, &user.LastName,
-		&user.Phone, &user.Address, &user.CreatedAt, &user.UpdatedAt,
+		&user.Phone, &user.Address, &user.Locale, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return models.Customer{}, errors.New("user not found")
+		return models.Customer{}, models.ErrUserNotFound
 	}
 	return user, err
 }
@@ -50,15 +102,15 @@ func (r *UserRepository) GetUserByID(id string) (models.Customer, error) {
 func (r *UserRepository) GetUserByEmail(email string) (models.Customer, error) {
 	var user models.Customer
 	err := r.db.QueryRow(
-		`SELECT id, email, first_name, last_name, phone, address, created_at, updated_at
+		`SELECT id, email, first_name, last_name, phone, address, locale, role, status, created_at, updated_at
 		FROM users WHERE email = $1`,
 		email,
 	).Scan(
 		&user.ID, &user.Email, &user.FirstName, &user.LastName,
-		&user.Phone, &user.Address, &user.CreatedAt, &user.UpdatedAt,
+		&user.Phone, &user.Address, &user.Locale, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return models.Customer{}, errors.New("user not found")
+		return models.Customer{}, models.ErrUserNotFound
 	}
 	return user, err
 }
@@ -66,7 +118,7 @@ func (r *UserRepository) GetUserByEmail(email string) (models.Customer, error) {
 // GetUsers retrieves all users
 func (r *UserRepository) GetUsers() ([]models.Customer, error) {
 	rows, err := r.db.Query(
-		`SELECT id, email, first_name, last_name, phone, address, created_at, updated_at
+		`SELECT id, email, first_name, last_name, phone, address, locale, role, status, created_at, updated_at
 		FROM users ORDER BY created_at DESC`,
 	)
 	if err != nil {
@@ -79,7 +131,7 @@ func (r *UserRepository) GetUsers() ([]models.Customer, error) {
 		var user models.Customer
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.FirstName, &user.LastName,
-			&user.Phone, &user.Address, &user.CreatedAt, &user.UpdatedAt,
+			&user.Phone, &user.Address, &user.Locale, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -92,10 +144,28 @@ func (r *UserRepository) GetUsers() ([]models.Customer, error) {
 // UpdateUser updates a user in the database
 func (r *UserRepository) UpdateUser(user models.Customer) error {
 	_, err := r.db.Exec(
-		`UPDATE users SET email = $1, first_name = $2, last_name = $3, phone = $4, address = $5, updated_at = $6
-		WHERE id = $7`,
-		user.Email, user.FirstName, user.LastName, user.Phone, user.Address,
-		user.UpdatedAt, user.ID,
+		`UPDATE users SET email = $1, first_name = $2, last_name = $3, phone = $4, address = $5, locale = $6, status = $7, updated_at = $8
+		WHERE id = $9`,
+		user.Email, user.FirstName, user.LastName, user.Phone, user.Address, user.Locale,
+		user.Status, user.UpdatedAt, user.ID,
+	)
+	return err
+}
+
+// UpdateUserRole changes the role of a user
+func (r *UserRepository) UpdateUserRole(id string, role string) error {
+	_, err := r.db.Exec(
+		`UPDATE users SET role = $1, updated_at = $2 WHERE id = $3`,
+		role, time.Now(), id,
+	)
+	return err
+}
+
+// UpdateUserStatus changes the status of an account
+func (r *UserRepository) UpdateUserStatus(id string, status string) error {
+	_, err := r.db.Exec(
+		`UPDATE users SET status = $1, updated_at = $2 WHERE id = $3`,
+		status, time.Now(), id,
 	)
 	return err
 }
@@ -108,6 +178,44 @@ func (r *UserRepository) DeleteUser(id string) error {
 		return err
 	}
 
+	// Then delete the sessions and their refresh tokens
+	_, err = r.db.Exec("DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM user_sessions WHERE user_id = $1)", id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("DELETE FROM user_sessions WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Then delete the verification and reset tokens
+	_, err = r.db.Exec("DELETE FROM user_tokens WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Then delete the MFA secret and recovery codes
+	_, err = r.db.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("DELETE FROM user_mfa WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Then delete the address book
+	_, err = r.db.Exec("DELETE FROM user_addresses WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Then delete the credentials
+	_, err = r.db.Exec("DELETE FROM user_credentials WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
 	// Then delete from users
 	_, err = r.db.Exec("DELETE FROM users WHERE id = $1", id)
 	return err
@@ -157,3 +265,832 @@ func (r *UserRepository) UpdateUserOrderStatus(userID string, orderID string, or
 	)
 	return err
 }
+
+// CreateSession creates a session together with its first refresh token
+func (r *UserRepository) CreateSession(session models.Session, tokenHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO user_sessions (id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress,
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO refresh_tokens (token_hash, session_id, created_at) VALUES ($1, $2, $3)`,
+		tokenHash, session.ID, session.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRefreshToken retrieves the session a refresh token belongs to and
+// whether the token was already used
+func (r *UserRepository) GetRefreshToken(tokenHash string) (string, bool, error) {
+	var sessionID string
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT session_id, used_at FROM refresh_tokens WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&sessionID, &usedAt)
+	if err == sql.ErrNoRows {
+		return "", false, errors.New("refresh token not found")
+	}
+	return sessionID, usedAt.Valid, err
+}
+
+// RotateRefreshToken marks a refresh token as used and stores its
+// replacement. It returns false without changing anything if the token was
+// used in the meantime.
+func (r *UserRepository) RotateRefreshToken(oldHash, newHash string, session models.Session) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL`,
+		session.LastUsedAt, oldHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO refresh_tokens (token_hash, session_id, created_at) VALUES ($1, $2, $3)`,
+		newHash, session.ID, session.LastUsedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE user_sessions SET user_agent = $1, ip_address = $2, last_used_at = $3 WHERE id = $4`,
+		session.UserAgent, session.IPAddress, session.LastUsedAt, session.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetSession retrieves a session by ID
+func (r *UserRepository) GetSession(id string) (models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at
+		FROM user_sessions WHERE id = $1`,
+		id,
+	).Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return models.Session{}, models.ErrSessionNotFound
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, err
+}
+
+// GetActiveSessions retrieves the sessions of a user that are neither revoked nor expired
+func (r *UserRepository) GetActiveSessions(userID string) ([]models.Session, error) {
+	rows, err := r.db.Query(
+		`SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at
+		FROM user_sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC`,
+		userID, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession marks a session as revoked
+func (r *UserRepository) RevokeSession(id string) error {
+	_, err := r.db.Exec(
+		`UPDATE user_sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+		time.Now(), id,
+	)
+	return err
+}
+
+// DeleteExpiredSessions deletes the sessions, and their refresh tokens, that
+// expired or were revoked before the given time
+func (r *UserRepository) DeleteExpiredSessions(before time.Time) (int64, error) {
+	_, err := r.db.Exec(
+		`DELETE FROM refresh_tokens WHERE session_id IN (
+			SELECT id FROM user_sessions WHERE expires_at < $1 OR revoked_at < $1
+		)`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.Exec(
+		`DELETE FROM user_sessions WHERE expires_at < $1 OR revoked_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateUserToken stores a single-use token of a user. Earlier unused tokens
+// of the same purpose are marked as used, so only the latest email works.
+func (r *UserRepository) CreateUserToken(userID string, purpose string, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL`,
+		now, userID, purpose,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user_tokens (token_hash, user_id, purpose, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		tokenHash, userID, purpose, now, expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUserToken retrieves the user an unused and unexpired token of the given
+// purpose belongs to
+func (r *UserRepository) GetUserToken(tokenHash string, purpose string) (string, error) {
+	var userID string
+	err := r.db.QueryRow(
+		`SELECT user_id FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3`,
+		tokenHash, purpose, time.Now(),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", models.ErrInvalidToken
+	}
+	return userID, err
+}
+
+// UseUserToken marks a token as used. It returns false if the token was used
+// in the meantime.
+func (r *UserRepository) UseUserToken(tokenHash string) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE user_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL`,
+		time.Now(), tokenHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// FailUserToken counts a failed attempt at a token and marks it as used once
+// it reaches maxAttempts failures. It returns false if the token is used up.
+func (r *UserRepository) FailUserToken(tokenHash string, maxAttempts int) (bool, error) {
+	_, err := r.db.Exec(
+		`UPDATE user_tokens SET attempts = attempts + 1 WHERE token_hash = $1 AND used_at IS NULL`,
+		tokenHash,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE user_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL AND attempts >= $3`,
+		time.Now(), tokenHash, maxAttempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 0, nil
+}
+
+// DeleteExpiredUserTokens deletes the tokens that expired before the given time
+func (r *UserRepository) DeleteExpiredUserTokens(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM user_tokens WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetMFA retrieves the MFA secret of a user
+func (r *UserRepository) GetMFA(userID string) (models.MFA, error) {
+	var mfa models.MFA
+	var enabledAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_mfa WHERE user_id = $1`,
+		userID,
+	).Scan(&mfa.UserID, &mfa.Secret, &enabledAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.MFA{}, models.ErrMFANotEnabled
+	}
+	if enabledAt.Valid {
+		mfa.EnabledAt = &enabledAt.Time
+	}
+	return mfa, err
+}
+
+// SaveMFASecret stores a new TOTP secret of a user that waits to be
+// confirmed, replacing one that was never confirmed
+func (r *UserRepository) SaveMFASecret(userID string, secret string) error {
+	_, err := r.db.Exec(
+		`INSERT INTO user_mfa (user_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled_at = NULL,
+			last_used_step = 0, created_at = EXCLUDED.created_at`,
+		userID, secret, time.Now(),
+	)
+	return err
+}
+
+// EnableMFA marks the TOTP secret of a user as confirmed and replaces the
+// recovery codes of the user
+func (r *UserRepository) EnableMFA(userID string, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`UPDATE user_mfa SET enabled_at = $1, last_used_step = $2 WHERE user_id = $3`,
+		now, step, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodes(tx, userID, codeHashes, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records that the code of a time step was used. It returns false
+// if a code of the same or a later step was already used.
+func (r *UserRepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`,
+		step, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// DeleteMFA deletes the TOTP secret and recovery codes of a user
+func (r *UserRepository) DeleteMFA(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user
+func (r *UserRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = replaceRecoveryCodes(tx, userID, codeHashes, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and stores new ones
+func replaceRecoveryCodes(tx *sql.Tx, userID string, codeHashes []string, now time.Time) error {
+	_, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err = tx.Exec(
+			`INSERT INTO mfa_recovery_codes (code_hash, user_id, created_at) VALUES ($1, $2, $3)`,
+			codeHash, userID, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It returns
+// false if the user has no such code.
+func (r *UserRepository) UseRecoveryCode(userID string, codeHash string) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE mfa_recovery_codes SET used_at = $1 WHERE code_hash = $2 AND user_id = $3 AND used_at IS NULL`,
+		time.Now(), codeHash, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *UserRepository) CountRecoveryCodes(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+// GetMFARequiredRoles retrieves the roles that have to use MFA
+func (r *UserRepository) GetMFARequiredRoles() ([]string, error) {
+	rows, err := r.db.Query(`SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// IsMFARequired reports whether a role has to use MFA
+func (r *UserRepository) IsMFARequired(role string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM mfa_required_roles WHERE role = $1`,
+		role,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// SetMFARequiredRoles replaces the roles that have to use MFA
+func (r *UserRepository) SetMFARequiredRoles(roles []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM mfa_required_roles")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, role := range roles {
+		_, err = tx.Exec(
+			`INSERT INTO mfa_required_roles (role, updated_at) VALUES ($1, $2)`,
+			role, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Columns of the user_addresses table that hold the flags of the default
+// addresses of a user
+const (
+	defaultShippingColumn = "default_shipping"
+	defaultBillingColumn  = "default_billing"
+)
+
+// addressColumns lists the columns an address is selected with
+const addressColumns = `id, user_id, recipient, phone, line1, line2, ward, district, city, province,
+	postal_code, country, default_shipping, default_billing, created_at, updated_at`
+
+// scanAddress scans a row selected with addressColumns
+func scanAddress(row interface{ Scan(...interface{}) error }) (models.Address, error) {
+	var address models.Address
+	err := row.Scan(
+		&address.ID, &address.CustomerID, &address.Recipient, &address.Phone, &address.Line1, &address.Line2,
+		&address.Ward, &address.District, &address.City, &address.Province, &address.PostalCode, &address.Country,
+		&address.DefaultShipping, &address.DefaultBilling, &address.CreatedAt, &address.UpdatedAt,
+	)
+	return address, err
+}
+
+// CreateAddress adds an address to the address book of a user. If the
+// address is a default, it stops being the default of any other address.
+func (r *UserRepository) CreateAddress(address models.Address) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = clearDefaultAddresses(tx, address)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user_addresses (id, user_id, recipient, phone, line1, line2, ward, district, city, province,
+			postal_code, country, default_shipping, default_billing, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		address.ID, address.CustomerID, address.Recipient, address.Phone, address.Line1, address.Line2,
+		address.Ward, address.District, address.City, address.Province, address.PostalCode, address.Country,
+		address.DefaultShipping, address.DefaultBilling, address.CreatedAt, address.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateAddress changes an address of a user. If the address becomes a
+// default, it stops being the default of any other address.
+func (r *UserRepository) UpdateAddress(address models.Address) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = clearDefaultAddresses(tx, address)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`UPDATE user_addresses SET recipient = $1, phone = $2, line1 = $3, line2 = $4, ward = $5, district = $6,
+			city = $7, province = $8, postal_code = $9, country = $10, default_shipping = $11, default_billing = $12,
+			updated_at = $13
+		WHERE id = $14 AND user_id = $15`,
+		address.Recipient, address.Phone, address.Line1, address.Line2, address.Ward, address.District,
+		address.City, address.Province, address.PostalCode, address.Country, address.DefaultShipping, address.DefaultBilling,
+		address.UpdatedAt, address.ID, address.CustomerID,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrAddressNotFound
+	}
+
+	return tx.Commit()
+}
+
+// clearDefaultAddresses takes the default flags the address has away from the
+// other addresses of its user
+func clearDefaultAddresses(tx *sql.Tx, address models.Address) error {
+	for column, isDefault := range map[string]bool{
+		defaultShippingColumn: address.DefaultShipping,
+		defaultBillingColumn:  address.DefaultBilling,
+	} {
+		if !isDefault {
+			continue
+		}
+		_, err := tx.Exec(
+			`UPDATE user_addresses SET `+column+` = FALSE WHERE user_id = $1 AND id <> $2`,
+			address.CustomerID, address.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAddresses retrieves the address book of a user, oldest address first
+func (r *UserRepository) GetAddresses(userID string) ([]models.Address, error) {
+	rows, err := r.db.Query(
+		`SELECT `+addressColumns+` FROM user_addresses WHERE user_id = $1 ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []models.Address{}
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// GetAddress retrieves an address of a user
+func (r *UserRepository) GetAddress(userID string, id string) (models.Address, error) {
+	address, err := scanAddress(r.db.QueryRow(
+		`SELECT `+addressColumns+` FROM user_addresses WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	))
+	if err == sql.ErrNoRows {
+		return models.Address{}, models.ErrAddressNotFound
+	}
+	return address, err
+}
+
+// GetDefaultShippingAddress retrieves the address a user ships to by default
+func (r *UserRepository) GetDefaultShippingAddress(userID string) (models.Address, error) {
+	address, err := scanAddress(r.db.QueryRow(
+		`SELECT `+addressColumns+` FROM user_addresses WHERE user_id = $1 AND default_shipping = TRUE`,
+		userID,
+	))
+	if err == sql.ErrNoRows {
+		return models.Address{}, models.ErrAddressNotFound
+	}
+	return address, err
+}
+
+// CountAddresses counts the addresses in the address book of a user
+func (r *UserRepository) CountAddresses(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM user_addresses WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// DeleteAddress removes an address from the address book of a user. A default
+// the address held moves to the oldest address left.
+func (r *UserRepository) DeleteAddress(userID string, id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	address, err := scanAddress(tx.QueryRow(
+		`SELECT `+addressColumns+` FROM user_addresses WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	))
+	if err == sql.ErrNoRows {
+		return models.ErrAddressNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_addresses WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	for column, wasDefault := range map[string]bool{
+		defaultShippingColumn: address.DefaultShipping,
+		defaultBillingColumn:  address.DefaultBilling,
+	} {
+		if !wasDefault {
+			continue
+		}
+		_, err = tx.Exec(
+			`UPDATE user_addresses SET `+column+` = TRUE
+			WHERE id = (SELECT id FROM user_addresses WHERE user_id = $1 ORDER BY created_at, id LIMIT 1)`,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateErasureRequest stores a new erasure request together with a pending
+// report for each service taking part in it
+func (r *UserRepository) CreateErasureRequest(request models.ErasureRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO erasure_requests (id, user_id, requested_by, status, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		request.ID, request.CustomerID, request.RequestedBy, request.Status, request.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range request.Services {
+		_, err = tx.Exec(
+			`INSERT INTO erasure_request_services (request_id, service, status) VALUES ($1, $2, $3)`,
+			request.ID, service.Service, service.Status,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetErasureRequest retrieves an erasure request with the reports of its services
+func (r *UserRepository) GetErasureRequest(id string) (models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	var completedAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT id, user_id, requested_by, status, created_at, completed_at FROM erasure_requests WHERE id = $1`,
+		id,
+	).Scan(&request.ID, &request.CustomerID, &request.RequestedBy, &request.Status, &request.CreatedAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return models.ErasureRequest{}, models.ErrErasureNotFound
+	}
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+	if completedAt.Valid {
+		request.CompletedAt = &completedAt.Time
+	}
+
+	request.Services, err = r.getErasureServices(id)
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+	return request, nil
+}
+
+// GetErasureRequests retrieves the erasure requests of a user, newest first
+func (r *UserRepository) GetErasureRequests(userID string) ([]models.ErasureRequest, error) {
+	rows, err := r.db.Query(
+		`SELECT id FROM erasure_requests WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	requests := []models.ErasureRequest{}
+	for _, id := range ids {
+		request, err := r.GetErasureRequest(id)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// getErasureServices retrieves the reports of the services taking part in an
+// erasure request
+func (r *UserRepository) getErasureServices(requestID string) ([]models.ErasureService, error) {
+	rows, err := r.db.Query(
+		`SELECT service, status, erased, retained, note, completed_at
+		FROM erasure_request_services WHERE request_id = $1 ORDER BY service`,
+		requestID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []models.ErasureService{}
+	for rows.Next() {
+		var service models.ErasureService
+		var completedAt sql.NullTime
+		err := rows.Scan(&service.Service, &service.Status, &service.Erased, &service.Retained, &service.Note, &completedAt)
+		if err != nil {
+			return nil, err
+		}
+		if completedAt.Valid {
+			service.CompletedAt = &completedAt.Time
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// UpdateErasureService stores the report of a service for an erasure request.
+// It reports whether the service takes part in the request.
+func (r *UserRepository) UpdateErasureService(requestID string, service models.ErasureService) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE erasure_request_services SET status = $1, erased = $2, retained = $3, note = $4, completed_at = $5
+		WHERE request_id = $6 AND service = $7`,
+		service.Status, service.Erased, service.Retained, service.Note, service.CompletedAt, requestID, service.Service,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UpdateErasureStatus changes the status of an erasure request
+func (r *UserRepository) UpdateErasureStatus(id string, status string, completedAt *time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE erasure_requests SET status = $1, completed_at = $2 WHERE id = $3`,
+		status, completedAt, id,
+	)
+	return err
+}
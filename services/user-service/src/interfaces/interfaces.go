@@ -1,6 +1,9 @@
 package interfaces
 
 import (
+	"time"
+
+	"github.com/online-order-system/user-service/auth"
 	"github.com/online-order-system/user-service/models"
 )
 
@@ -11,17 +14,60 @@ type UserService interface {
 	GetUserByEmail(email string) (models.Customer, error)
 	GetUsers() ([]models.Customer, error)
 	UpdateUser(id string, req models.UpdateCustomerRequest) (models.Customer, error)
+	UpdateUserRole(id string, role string) (models.Customer, error)
+	UpdateUserStatus(id string, status string) (models.Customer, error)
+	UnlockUser(id string) (models.Customer, error)
+	GetAddresses(userID string) ([]models.Address, error)
+	GetAddress(userID string, id string) (models.Address, error)
+	CreateAddress(userID string, req models.AddressRequest) (models.Address, error)
+	UpdateAddress(userID string, id string, req models.AddressRequest) (models.Address, error)
+	DeleteAddress(userID string, id string) error
 	DeleteUser(id string) error
-	VerifyUser(req models.VerifyCustomerRequest) (models.VerifyCustomerResponse, error)
+	ExportUserData(id string) ([]byte, error)
+	RequestErasure(id string, requestedBy string) (models.ErasureRequest, error)
+	GetErasureRequests(id string) ([]models.ErasureRequest, error)
+	RecordErasureResult(event models.ErasureEvent) error
+	VerifyUser(req models.VerifyCustomerRequest, client models.ClientInfo) (models.VerifyCustomerResponse, error)
 	GetUserOrders(userID string) ([]models.CustomerOrder, error)
 	AddUserOrder(userID string, orderID string, orderStatus string) error
 	UpdateUserOrderStatus(userID string, orderID string, orderStatus string) error
+	Register(req models.RegisterRequest, client models.ClientInfo) (models.LoginResponse, error)
+	Login(req models.LoginRequest, client models.ClientInfo) (models.LoginResponse, error)
+	ChangePassword(userID string, sessionID string, req models.ChangePasswordRequest) error
+	VerifyEmail(token string) (models.Customer, error)
+	ResendVerification(email string) error
+	ForgotPassword(email string) error
+	ResetPassword(req models.ResetPasswordRequest) error
+	VerifyMFA(req models.MFAVerifyRequest, client models.ClientInfo) (models.LoginResponse, error)
+	GetMFAStatus(userID string) (models.MFAStatus, error)
+	MFAEnrollmentUser(mfaToken string) (string, error)
+	EnrollMFA(userID string) (models.MFAEnrollment, error)
+	ConfirmMFA(userID string, code string) ([]string, error)
+	CompleteMFAEnrollment(mfaToken string, code string, client models.ClientInfo) (models.MFAConfirmResponse, error)
+	DisableMFA(userID string, req models.MFACodeRequest) error
+	RegenerateRecoveryCodes(userID string, code string) ([]string, error)
+	ResetMFA(userID string) error
+	GetMFAPolicy() (models.MFAPolicy, error)
+	UpdateMFAPolicy(policy models.MFAPolicy) (models.MFAPolicy, error)
+	Refresh(refreshToken string, client models.ClientInfo) (models.LoginResponse, error)
+	Logout(sessionID string) error
+	GetSessions(userID string) ([]models.Session, error)
+	RevokeSession(userID string, sessionID string) error
+	IsRevoked(sessionID string) (bool, error)
+	IssueToken(user models.Customer, sessionID string) (string, error)
+	ValidateToken(token string) (*auth.Claims, error)
+	IssueServiceToken(req auth.ServiceTokenRequest) (auth.ServiceTokenResponse, error)
+	JWKS() auth.JWKS
 }
 
 // UserProducer defines the interface for user producer
 type UserProducer interface {
 	PublishUserVerified(user models.Customer) error
 	PublishUserUpdated(user models.Customer) error
+	PublishEmailVerificationRequested(user models.Customer, link string, expiresAt time.Time) error
+	PublishPasswordResetRequested(user models.Customer, link string, expiresAt time.Time) error
+	PublishUserLocked(user models.Customer, reason string, ipAddress string, lockedUntil time.Time) error
+	PublishErasureRequested(request models.ErasureRequest, email string) error
 	Close() error
 }
 
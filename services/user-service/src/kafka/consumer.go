@@ -4,75 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"log"
-	"time"
 
 	"github.com/online-order-system/user-service/config"
+	"github.com/online-order-system/user-service/eventbus"
 	"github.com/online-order-system/user-service/interfaces"
 	"github.com/online-order-system/user-service/models"
-	"github.com/segmentio/kafka-go"
 )
 
 // Consumer handles Kafka message consumption
 type Consumer struct {
-	reader  *kafka.Reader
-	service interfaces.UserService
+	bus          eventbus.Bus
+	topic        string
+	privacyTopic string
+	service      interfaces.UserService
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.Config, service interfaces.UserService) *Consumer {
-	// Create Kafka reader
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{cfg.KafkaBootstrapServers},
-		GroupID:  "user-service",
-		Topic:    cfg.KafkaTopic,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
-	})
-
-	log.Println("Kafka consumer created and subscribed to topics")
-	return &Consumer{reader: r, service: service}
+func NewConsumer(cfg *config.Config, bus eventbus.Bus, service interfaces.UserService) *Consumer {
+	log.Println("Kafka consumer created")
+	return &Consumer{bus: bus, topic: cfg.KafkaTopic, privacyTopic: cfg.KafkaPrivacyTopic, service: service}
 }
 
 // StartConsuming starts consuming messages from Kafka
 func (c *Consumer) StartConsuming(ctx context.Context) {
-	if c.reader == nil {
+	if c.bus == nil {
 		log.Println("Kafka consumer not available, skipping message consumption")
 		return
 	}
 
-	// Start consuming in a goroutine
-	go func() {
-		log.Println("Starting to consume messages from Kafka")
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("Context cancelled, stopping Kafka consumer")
-				c.reader.Close()
-				return
-			default:
-				// Read message
-				msg, err := c.reader.ReadMessage(ctx)
-				if err != nil {
-					log.Printf("Error reading message: %v", err)
-					time.Sleep(100 * time.Millisecond)
-					continue
-				}
-
-				// Process message
-				c.processMessage(msg)
-			}
-		}
-	}()
+	log.Println("Starting to consume messages from Kafka")
+	if err := c.bus.Subscribe(ctx, c.topic, "user-service", c.processMessage); err != nil {
+		log.Printf("Failed to subscribe to topic %s: %v", c.topic, err)
+	}
+	if err := c.bus.Subscribe(ctx, c.privacyTopic, "user-service", c.processPrivacyMessage); err != nil {
+		log.Printf("Failed to subscribe to topic %s: %v", c.privacyTopic, err)
+	}
 }
 
 // processMessage processes a Kafka message
-func (c *Consumer) processMessage(msg kafka.Message) {
+func (c *Consumer) processMessage(ctx context.Context, key, value []byte) error {
 	// Parse message value
 	var event models.OrderEvent
-	err := json.Unmarshal(msg.Value, &event)
+	err := json.Unmarshal(value, &event)
 	if err != nil {
 		log.Printf("Failed to unmarshal message: %v", err)
-		return
+		return nil
 	}
 
 	// Process event based on type
@@ -90,4 +66,29 @@ func (c *Consumer) processMessage(msg kafka.Message) {
 	default:
 		log.Printf("Unknown event type: %s", event.EventType)
 	}
+	return nil
+}
+
+// processPrivacyMessage records the reports services send back about the
+// erasures they were asked for
+func (c *Consumer) processPrivacyMessage(ctx context.Context, key, value []byte) error {
+	var event models.ErasureEvent
+	err := json.Unmarshal(value, &event)
+	if err != nil {
+		log.Printf("Failed to unmarshal message: %v", err)
+		return nil
+	}
+
+	switch event.EventType {
+	case "user_erasure_completed":
+		err = c.service.RecordErasureResult(event)
+		if err != nil {
+			log.Printf("Failed to record erasure report of %s: %v", event.Service, err)
+		}
+	case "user_erasure_requested":
+		// Published by user-service itself, which erases its data right away
+	default:
+		log.Printf("Unknown event type: %s", event.EventType)
+	}
+	return nil
 }
@@ -7,36 +7,31 @@ import (
 	"time"
 
 	"github.com/online-order-system/user-service/config"
+	"github.com/online-order-system/user-service/eventbus"
 	"github.com/online-order-system/user-service/interfaces"
 	"github.com/online-order-system/user-service/models"
-	"github.com/segmentio/kafka-go"
 )
 
 // Producer handles Kafka message production
 type Producer struct {
-	writer *kafka.Writer
-	topic  string
+	bus          eventbus.Bus
+	topic        string
+	userTopic    string
+	privacyTopic string
 }
 
 // Ensure Producer implements UserProducer interface
 var _ interfaces.UserProducer = (*Producer)(nil)
 
 // NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.Config) *Producer {
-	// Create Kafka writer
-	w := &kafka.Writer{
-		Addr:     kafka.TCP(cfg.KafkaBootstrapServers),
-		Topic:    cfg.KafkaTopic,
-		Balancer: &kafka.LeastBytes{},
-	}
-
+func NewProducer(cfg *config.Config, bus eventbus.Bus) *Producer {
 	log.Println("Kafka producer created")
-	return &Producer{writer: w, topic: cfg.KafkaTopic}
+	return &Producer{bus: bus, topic: cfg.KafkaTopic, userTopic: cfg.KafkaUserTopic, privacyTopic: cfg.KafkaPrivacyTopic}
 }
 
 // PublishUserVerified publishes a user verified event
 func (p *Producer) PublishUserVerified(user models.Customer) error {
-	if p.writer == nil {
+	if p.bus == nil {
 		log.Println("Kafka producer not available, skipping event publishing")
 		return nil
 	}
@@ -50,12 +45,12 @@ func (p *Producer) PublishUserVerified(user models.Customer) error {
 		Timestamp:  time.Now().Unix(),
 	}
 
-	return p.publishEvent(event)
+	return p.publishEvent(p.topic, event)
 }
 
 // PublishUserUpdated publishes a user updated event
 func (p *Producer) PublishUserUpdated(user models.Customer) error {
-	if p.writer == nil {
+	if p.bus == nil {
 		log.Println("Kafka producer not available, skipping event publishing")
 		return nil
 	}
@@ -69,30 +64,94 @@ func (p *Producer) PublishUserUpdated(user models.Customer) error {
 		Timestamp:  time.Now().Unix(),
 	}
 
-	return p.publishEvent(event)
+	return p.publishEvent(p.topic, event)
+}
+
+// PublishEmailVerificationRequested asks for the email verification link to
+// be sent to a user
+func (p *Producer) PublishEmailVerificationRequested(user models.Customer, link string, expiresAt time.Time) error {
+	return p.publishAccountEmail(models.AccountEmailEvent{
+		EventType: "email_verification_requested",
+		Link:      link,
+		ExpiresAt: expiresAt.Unix(),
+	}, user)
+}
+
+// PublishPasswordResetRequested asks for the password reset link to be sent
+// to a user
+func (p *Producer) PublishPasswordResetRequested(user models.Customer, link string, expiresAt time.Time) error {
+	return p.publishAccountEmail(models.AccountEmailEvent{
+		EventType: "password_reset_requested",
+		Link:      link,
+		ExpiresAt: expiresAt.Unix(),
+	}, user)
+}
+
+// PublishUserLocked publishes a user locked event, so the owner of the account
+// is alerted. A zero lockedUntil means the account stays locked until an admin
+// unlocks it.
+func (p *Producer) PublishUserLocked(user models.Customer, reason string, ipAddress string, lockedUntil time.Time) error {
+	event := models.AccountEmailEvent{
+		EventType: "user_locked",
+		Reason:    reason,
+		IPAddress: ipAddress,
+	}
+	if !lockedUntil.IsZero() {
+		event.LockedUntil = lockedUntil.Unix()
+	}
+
+	return p.publishAccountEmail(event, user)
+}
+
+// PublishErasureRequested asks every service to erase the personal data of a
+// customer. The email is included for services that keep it apart from the
+// customer ID.
+func (p *Producer) PublishErasureRequested(request models.ErasureRequest, email string) error {
+	if p.bus == nil {
+		log.Println("Kafka producer not available, skipping event publishing")
+		return nil
+	}
+
+	event := models.ErasureEvent{
+		EventType:  "user_erasure_requested",
+		RequestID:  request.ID,
+		CustomerID: request.CustomerID,
+		Email:      email,
+		Timestamp:  time.Now().Unix(),
+	}
+
+	return p.publishEvent(p.privacyTopic, event)
+}
+
+// publishAccountEmail fills in the user of an account email event and
+// publishes it to the user topic
+func (p *Producer) publishAccountEmail(event models.AccountEmailEvent, user models.Customer) error {
+	if p.bus == nil {
+		log.Println("Kafka producer not available, skipping event publishing")
+		return nil
+	}
+
+	event.CustomerID = user.ID
+	event.Email = user.Email
+	event.FirstName = user.FirstName
+	event.Timestamp = time.Now().Unix()
+
+	return p.publishEvent(p.userTopic, event)
 }
 
 // publishEvent publishes an event to Kafka
-func (p *Producer) publishEvent(event interface{}) error {
+func (p *Producer) publishEvent(topic string, event interface{}) error {
 	// Convert event to JSON
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
-	// Create Kafka message
-	message := kafka.Message{
-		Value: eventJSON,
-	}
-
 	// Produce message
-	return p.writer.WriteMessages(context.Background(), message)
+	return p.bus.Publish(context.Background(), topic, nil, eventJSON)
 }
 
-// Close closes the Kafka producer
+// Close closes the Kafka producer. The event bus is owned by the caller.
 func (p *Producer) Close() error {
-	if p.writer != nil {
-		return p.writer.Close()
-	}
 	return nil
 }
@@ -8,11 +8,9 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/online-order-system/user-service/api"
+	"github.com/online-order-system/user-service/app"
 	"github.com/online-order-system/user-service/config"
-	"github.com/online-order-system/user-service/db"
-	"github.com/online-order-system/user-service/kafka"
-	"github.com/online-order-system/user-service/service"
+	"github.com/online-order-system/user-service/eventbus"
 )
 
 func main() {
@@ -20,39 +18,27 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
-	// Connect to database
-	database, err := db.NewDatabase(cfg)
+	// Create event bus
+	bus, err := eventbus.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to create event bus: %v", err)
 	}
-	defer database.Close()
+	defer bus.Close()
 
-	// Create tables
-	err = database.CreateTables()
+	// Create application
+	application, err := app.New(cfg, bus)
 	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+		log.Fatalf("Failed to create application: %v", err)
 	}
-
-	// Create repository
-	repository := db.NewUserRepository(database)
-
-	// Create Kafka producer
-	producer := kafka.NewProducer(cfg)
-	defer producer.Close()
-
-	// Create service
-	userService := service.NewUserService(cfg, repository, producer)
-
-	// Create Kafka consumer
-	consumer := kafka.NewConsumer(cfg, userService)
+	defer application.Close()
 
 	// Start Kafka consumer
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	consumer.StartConsuming(ctx)
+	application.Start(ctx)
 
 	// Set up router
-	router := api.SetupRouter(userService)
+	router := application.Router
 
 	// Start server
 	go func() {
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrAccountLocked is returned when a locked account tries to log in
+	ErrAccountLocked = errors.New("account is locked")
+	// ErrAccountTemporarilyLocked is returned when an account is locked after too many failed logins
+	ErrAccountTemporarilyLocked = errors.New("account is temporarily locked after too many failed attempts")
+	// ErrAddressNotFound is returned when an address is not in the address book of the customer
+	ErrAddressNotFound = errors.New("address not found")
+	// ErrDataExportFailed is returned when a service holding data of the customer can't be reached for a data export
+	ErrDataExportFailed = errors.New("data export failed")
+	// ErrEmailNotVerified is returned when an account logs in before verifying its email
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrEmailTaken is returned when registering an email, or changing to one,
+	// that already has an account
+	ErrEmailTaken = errors.New("user with this email already exists")
+	// ErrErasureNotFound is returned when a customer has no erasure request
+	ErrErasureNotFound = errors.New("erasure request not found")
+	// ErrInvalidAddress is returned when an address misses a field or a field has the wrong format
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrInvalidCredentials is returned when an email and password do not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidMFACode is returned when a TOTP or recovery code does not match
+	ErrInvalidMFACode = errors.New("invalid MFA code")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrInvalidRole is returned when a role is not one of the known roles
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrInvalidServiceCredentials is returned when a service asks for a service token with an unknown name or wrong secret
+	ErrInvalidServiceCredentials = errors.New("invalid service credentials")
+	// ErrInvalidStatus is returned when an account status is not one of the known statuses
+	ErrInvalidStatus = errors.New("invalid account status")
+	// ErrInvalidToken is returned when a verification or reset token is unknown, used or expired
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrMFAAlreadyEnabled is returned when enrolling an account that already uses MFA
+	ErrMFAAlreadyEnabled = errors.New("MFA is already enabled")
+	// ErrMFANotEnabled is returned when an account has not set up MFA
+	ErrMFANotEnabled = errors.New("MFA is not enabled")
+	// ErrMFARequired is returned when turning off MFA that the role of the account requires
+	ErrMFARequired = errors.New("MFA is required for this role")
+	// ErrSessionNotFound is returned when a session does not exist for the user
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTooManyAddresses is returned when adding an address to a full address book
+	ErrTooManyAddresses = errors.New("address book is full")
+	// ErrTooManyAttempts is returned when attempts come faster than failed attempts allow
+	ErrTooManyAttempts = errors.New("too many failed attempts, try again later")
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// ThrottledError is returned when an attempt is refused because of earlier
+// failed attempts. It tells when the next attempt may be made.
+type ThrottledError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
@@ -1,9 +1,34 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
+// Account statuses. New accounts wait for their email address to be
+// verified; locked accounts can't log in until an admin activates them.
+const (
+	StatusPendingVerification = "pending_verification"
+	StatusActive              = "active"
+	StatusLocked              = "locked"
+)
+
+// ValidStatus reports whether status is one of the known account statuses
+func ValidStatus(status string) bool {
+	return status == StatusPendingVerification || status == StatusActive || status == StatusLocked
+}
+
+// Purposes of the single-use tokens sent in account emails
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMFAChallenge      = "mfa_challenge"
+	TokenPurposeMFAEnrollment     = "mfa_enrollment"
+)
+
+// DefaultLocale is the locale of customers who haven't chosen one
+const DefaultLocale = "en"
+
 // Customer represents a customer in the system
 type Customer struct {
 	ID        string    `json:"id"`
@@ -12,10 +37,85 @@ type Customer struct {
 	LastName  string    `json:"last_name"`
 	Phone     string    `json:"phone"`
 	Address   string    `json:"address"`
+	Locale    string    `json:"locale"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Contact returns what other services need to contact the customer
+func (c Customer) Contact() CustomerContact {
+	return CustomerContact{
+		ID:     c.ID,
+		Email:  c.Email,
+		Name:   strings.TrimSpace(c.FirstName + " " + c.LastName),
+		Phone:  c.Phone,
+		Locale: c.Locale,
+	}
+}
+
+// CustomerContact is what other services need to contact a customer. They
+// look it up by customer ID and pass it on in their events, so the services
+// consuming those events don't have to look it up again.
+type CustomerContact struct {
+	ID     string `json:"id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Phone  string `json:"phone,omitempty"`
+	Locale string `json:"locale"`
+}
+
+// Address is an entry in the address book of a customer. Orders and shipments
+// keep a copy of the address they were placed with, so changing or deleting
+// an address doesn't change past orders.
+type Address struct {
+	ID              string    `json:"id"`
+	CustomerID      string    `json:"customer_id"`
+	Recipient       string    `json:"recipient"`
+	Phone           string    `json:"phone"`
+	Line1           string    `json:"line1"`
+	Line2           string    `json:"line2,omitempty"`
+	Ward            string    `json:"ward,omitempty"`
+	District        string    `json:"district,omitempty"`
+	City            string    `json:"city,omitempty"`
+	Province        string    `json:"province,omitempty"`
+	PostalCode      string    `json:"postal_code,omitempty"`
+	Country         string    `json:"country"` // ISO 3166-1 alpha-2 code
+	DefaultShipping bool      `json:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// String formats an address on one line
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Line1, a.Line2, a.Ward, a.District, a.City, a.Province, a.PostalCode, a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AddressRequest represents a request to add or change an address. Setting a
+// default flag moves that default away from the other addresses.
+type AddressRequest struct {
+	Recipient       string `json:"recipient" binding:"required"`
+	Phone           string `json:"phone" binding:"required"`
+	Line1           string `json:"line1" binding:"required"`
+	Line2           string `json:"line2"`
+	Ward            string `json:"ward"`
+	District        string `json:"district"`
+	City            string `json:"city"`
+	Province        string `json:"province"`
+	PostalCode      string `json:"postal_code"`
+	Country         string `json:"country" binding:"required"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
 // CustomerOrder represents an order associated with a customer
 type CustomerOrder struct {
 	CustomerID  string    `json:"customer_id"`
@@ -31,6 +131,7 @@ type CreateCustomerRequest struct {
 	LastName  string `json:"last_name" binding:"required"`
 	Phone     string `json:"phone"`
 	Address   string `json:"address"`
+	Locale    string `json:"locale" binding:"omitempty,max=10"`
 }
 
 // UpdateCustomerRequest represents a request to update a customer
@@ -40,40 +141,249 @@ type UpdateCustomerRequest struct {
 	LastName  string `json:"last_name"`
 	Phone     string `json:"phone"`
 	Address   string `json:"address"`
+	Locale    string `json:"locale" binding:"omitempty,max=10"`
+}
+
+// UpdateRoleRequest represents a request to change the role of a user
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UpdateStatusRequest represents a request to change the status of an account
+type UpdateStatusRequest struct {
+	Status string `json:"status" binding:"required"`
 }
 
-// VerifyCustomerRequest represents a request to verify a customer
+// VerifyCustomerRequest represents a request to verify a customer. AddressID
+// picks the address an order ships to; without it the default shipping
+// address is used.
 type VerifyCustomerRequest struct {
-	Email   string `json:"email" binding:"required,email"`
-	Address string `json:"address" binding:"omitempty"`
-	ID      string `json:"id" binding:"omitempty"`
+	Email     string `json:"email" binding:"required,email"`
+	Address   string `json:"address" binding:"omitempty"`
+	AddressID string `json:"address_id" binding:"omitempty"`
+	ID        string `json:"id" binding:"omitempty"`
 }
 
 // VerifyCustomerResponse represents a response from customer verification
 type VerifyCustomerResponse struct {
-	Verified bool   `json:"verified"`
-	Message  string `json:"message,omitempty"`
-	Token    string `json:"token,omitempty"`
+	Verified bool      `json:"verified"`
+	Message  string    `json:"message,omitempty"`
 	User     *Customer `json:"user,omitempty"`
+	Address  *Address  `json:"address,omitempty"` // The address an order ships to, if the customer has one
+}
+
+// RegisterRequest represents a request to register a new account
+type RegisterRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password" binding:"required"`
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+	Locale    string `json:"locale" binding:"omitempty,max=10"`
 }
 
 // LoginRequest represents a request to login
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"omitempty"`
+	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse represents a response from login
+// LoginResponse represents a response from login. Registrations that have
+// to verify their email first get no tokens, and neither do logins that have
+// to pass an MFA challenge; those get an MFA token to complete the login with.
 type LoginResponse struct {
-	Token string    `json:"token"`
-	User  *Customer `json:"user"`
+	Token                 string    `json:"token,omitempty"`
+	RefreshToken          string    `json:"refresh_token,omitempty"`
+	ExpiresIn             int64     `json:"expires_in,omitempty"` // Lifetime of the access token in seconds
+	User                  *Customer `json:"user,omitempty"`
+	VerificationRequired  bool      `json:"verification_required,omitempty"`
+	MFARequired           bool      `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool      `json:"mfa_enrollment_required,omitempty"` // The role requires MFA and the account has to set it up first
+	MFAToken              string    `json:"mfa_token,omitempty"`
 }
 
-// TokenClaims represents the claims in a JWT token
-type TokenClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+// RefreshRequest represents a request to exchange a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ClientInfo describes the device a session was opened from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Session represents a login session of a user. Each session holds one
+// refresh token at a time, which is replaced on every refresh.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // Whether the session is the one making the request
+}
+
+// ChangePasswordRequest represents a request to change the caller's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// VerifyEmailRequest represents a request to verify an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailRequest represents a request that only names an account by its email,
+// such as asking for a password reset
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// Reasons an account was locked
+const (
+	LockReasonFailedLogins = "too_many_failed_logins"
+	LockReasonAdmin        = "locked_by_admin"
+)
+
+// MFA holds the TOTP secret of an account. The secret only counts once the
+// account has confirmed it with a code.
+type MFA struct {
+	UserID       string
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64 // Time step of the last accepted code, so codes can't be replayed
+	CreatedAt    time.Time
+}
+
+// MFAStatus tells whether an account uses MFA and whether its role requires it
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollment represents a new TOTP secret waiting to be confirmed
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFAEnrollRequest represents a request to set up MFA. Logins that have to
+// set up MFA first pass their MFA token instead of an access token.
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+// MFAConfirmRequest represents a request to confirm a new TOTP secret
+type MFAConfirmRequest struct {
+	Code     string `json:"code" binding:"required"`
+	MFAToken string `json:"mfa_token"`
+}
+
+// MFAConfirmResponse represents a response from confirming a TOTP secret.
+// Logins that had to set up MFA first are completed in the same response.
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	*LoginResponse
+}
+
+// MFAVerifyRequest represents a request to complete a login with a TOTP code
+// or a recovery code
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFACodeRequest represents a request that has to be confirmed with a TOTP
+// code or a recovery code
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAPolicy lists the roles whose accounts have to use MFA
+type MFAPolicy struct {
+	RequiredRoles []string `json:"required_roles" binding:"required"`
+}
+
+// UserData is the personal data user-service holds about a customer, as it
+// appears in a data export
+type UserData struct {
+	Profile   Customer        `json:"profile"`
+	Addresses []Address       `json:"addresses"`
+	Orders    []CustomerOrder `json:"orders"`
+	Sessions  []Session       `json:"sessions"`
+	MFA       MFAStatus       `json:"mfa"`
+}
+
+// DataExportManifest describes the files of a data export archive
+type DataExportManifest struct {
+	CustomerID  string            `json:"customer_id"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Files       map[string]string `json:"files"` // File name of the data of each service
+}
+
+// Statuses of an erasure request and of each service taking part in it
+const (
+	ErasurePending   = "pending"
+	ErasureCompleted = "completed"
+	ErasureFailed    = "failed"
+)
+
+// ErasureRequest tracks the erasure of the personal data of a customer across
+// every service that holds some. It outlives the account, as the record that
+// the erasure was done.
+type ErasureRequest struct {
+	ID          string           `json:"id"`
+	CustomerID  string           `json:"customer_id"`
+	RequestedBy string           `json:"requested_by"`
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Services    []ErasureService `json:"services"`
+}
+
+// ErasureService reports how far one service got with an erasure request.
+// Retained records are kept because a legal hold requires them, with what
+// identifies the customer removed.
+type ErasureService struct {
+	Service     string     `json:"service"`
+	Status      string     `json:"status"`
+	Erased      int        `json:"erased"`
+	Retained    int        `json:"retained"`
+	Note        string     `json:"note,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ErasureEvent asks the services to erase the personal data of a customer
+// (user_erasure_requested), and carries the report of each service back
+// (user_erasure_completed)
+type ErasureEvent struct {
+	EventType  string `json:"event_type"`
+	RequestID  string `json:"request_id"`
+	CustomerID string `json:"customer_id"`
+	Email      string `json:"email,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+
+	// Set on user_erasure_completed events
+	Service  string `json:"service,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Erased   int    `json:"erased,omitempty"`
+	Retained int    `json:"retained,omitempty"`
+	Note     string `json:"note,omitempty"`
 }
 
 // CustomerEvent represents an event related to a customer
@@ -86,6 +396,24 @@ type CustomerEvent struct {
 	Timestamp  int64  `json:"timestamp"`
 }
 
+// AccountEmailEvent asks notification-service to email a user about their
+// account, such as the link to verify their email or to reset their
+// password, or an alert that the account was locked
+type AccountEmailEvent struct {
+	EventType  string `json:"event_type"`
+	CustomerID string `json:"customer_id"`
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	Link       string `json:"link,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+
+	// Set on user_locked events
+	Reason      string `json:"reason,omitempty"`
+	IPAddress   string `json:"ip_address,omitempty"`
+	LockedUntil int64  `json:"locked_until,omitempty"` // Zero when the account stays locked until an admin unlocks it
+}
+
 // OrderEvent represents an event related to an order
 type OrderEvent struct {
 	EventType      string  `json:"event_type"`
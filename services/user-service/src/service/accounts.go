@@ -0,0 +1,252 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/models"
+)
+
+// Frontend pages the links in account emails open
+const (
+	verifyEmailPath   = "/verify-email"
+	resetPasswordPath = "/reset-password"
+)
+
+// checkStatus reports whether an account may log in. Unverified accounts are
+// only turned away when email verification is required.
+func (s *UserService) checkStatus(user models.Customer) error {
+	switch user.Status {
+	case models.StatusLocked:
+		return models.ErrAccountLocked
+	case models.StatusPendingVerification:
+		if s.config.RequireEmailVerification {
+			return models.ErrEmailNotVerified
+		}
+	}
+	return nil
+}
+
+// UpdateUserStatus changes the status of an account. Locking an account
+// revokes its sessions, so it is signed out everywhere.
+func (s *UserService) UpdateUserStatus(id string, status string) (models.Customer, error) {
+	if !models.ValidStatus(status) {
+		return models.Customer{}, models.ErrInvalidStatus
+	}
+
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if user.Status == status {
+		return user, nil
+	}
+
+	err = s.repository.UpdateUserStatus(id, status)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	user.Status = status
+	user.UpdatedAt = time.Now()
+
+	if status == models.StatusLocked {
+		err = s.revokeAll(id)
+		if err != nil {
+			log.Printf("Failed to revoke sessions of locked user %s: %v", id, err)
+			// Continue anyway, the tokens expire soon
+		}
+
+		err = s.producer.PublishUserLocked(user, models.LockReasonAdmin, "", time.Time{})
+		if err != nil {
+			log.Printf("Failed to publish user locked event: %v", err)
+			// Continue anyway, the account is locked
+		}
+	}
+
+	return user, nil
+}
+
+// sendVerificationEmail issues an email verification token and asks
+// notification-service to email the link to the user
+func (s *UserService) sendVerificationEmail(user models.Customer) error {
+	token, expiresAt, err := s.issueUserToken(user.ID, models.TokenPurposeEmailVerification, s.config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.producer.PublishEmailVerificationRequested(user, s.link(verifyEmailPath, token), expiresAt)
+}
+
+// issueUserToken stores a new single-use token of a user
+func (s *UserService) issueUserToken(userID string, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, tokenHash, err := auth.NewToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	err = s.repository.CreateUserToken(userID, purpose, tokenHash, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// link returns the frontend link that carries a token
+func (s *UserService) link(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", s.config.AppBaseURL, path, url.QueryEscape(token))
+}
+
+// useUserToken checks a single-use token and marks it as used. It returns the
+// user the token belongs to.
+func (s *UserService) useUserToken(token string, purpose string) (models.Customer, error) {
+	tokenHash := auth.HashToken(token)
+	userID, err := s.repository.GetUserToken(tokenHash, purpose)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.Customer{}, models.ErrInvalidToken
+	}
+
+	used, err := s.repository.UseUserToken(tokenHash)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if !used {
+		// Another request used the token between the lookup and now
+		return models.Customer{}, models.ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// activate moves an account that was waiting for its email to be verified to
+// active. Other statuses are left alone, so verifying doesn't unlock an account.
+func (s *UserService) activate(user *models.Customer) error {
+	if user.Status != models.StatusPendingVerification {
+		return nil
+	}
+
+	err := s.repository.UpdateUserStatus(user.ID, models.StatusActive)
+	if err != nil {
+		return err
+	}
+	user.Status = models.StatusActive
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// VerifyEmail verifies the email address of the account a verification token
+// was sent to
+func (s *UserService) VerifyEmail(token string) (models.Customer, error) {
+	user, err := s.useUserToken(token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	err = s.activate(&user)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	return user, nil
+}
+
+// ResendVerification sends a new verification email to an account that is
+// still waiting for one. It doesn't tell whether the account exists.
+func (s *UserService) ResendVerification(email string) error {
+	user, err := s.GetUserByEmail(email)
+	if err != nil || user.Status != models.StatusPendingVerification {
+		return nil
+	}
+
+	return s.sendVerificationEmail(user)
+}
+
+// ForgotPassword emails a password reset link to an account. It doesn't tell
+// whether the account exists, and locked accounts get no link.
+func (s *UserService) ForgotPassword(email string) error {
+	user, err := s.GetUserByEmail(email)
+	if err != nil || user.Status == models.StatusLocked {
+		return nil
+	}
+
+	token, expiresAt, err := s.issueUserToken(user.ID, models.TokenPurposePasswordReset, s.config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.producer.PublishPasswordResetRequested(user, s.link(resetPasswordPath, token), expiresAt)
+}
+
+// ResetPassword sets a new password with a reset token and signs the account
+// out everywhere. Following the emailed link proves the email address, so it
+// also verifies accounts that were waiting for that.
+func (s *UserService) ResetPassword(req models.ResetPasswordRequest) error {
+	userID, err := s.repository.GetUserToken(auth.HashToken(req.Token), models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.ErrInvalidToken
+	}
+
+	// Check the password before using the token, so a rejected password
+	// doesn't cost the user their link
+	err = s.policy.Validate(req.NewPassword, user.Email)
+	if err != nil {
+		return err
+	}
+
+	user, err = s.useUserToken(req.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	if user.Status == models.StatusLocked {
+		return models.ErrAccountLocked
+	}
+
+	passwordHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.SetPasswordHash(user.ID, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	err = s.activate(&user)
+	if err != nil {
+		log.Printf("Failed to activate user %s after password reset: %v", user.ID, err)
+		// Continue anyway, the password was changed
+	}
+
+	err = s.revokeAll(user.ID)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s after password reset: %v", user.ID, err)
+		// Continue anyway, the tokens expire soon
+	}
+
+	return nil
+}
+
+// CleanupTokens deletes the verification and reset tokens that have expired
+func (s *UserService) CleanupTokens() {
+	count, err := s.repository.DeleteExpiredUserTokens(time.Now())
+	if err != nil {
+		log.Printf("Failed to delete expired user tokens: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Deleted %d expired user tokens", count)
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/online-order-system/user-service/models"
+)
+
+// maxAddresses is the number of addresses an address book holds
+const maxAddresses = 20
+
+// addressRule lists what an address in a country needs besides the recipient,
+// phone, first line and country every address needs
+type addressRule struct {
+	required   []string       // JSON names of the fields that must be set
+	postalCode *regexp.Regexp // Format of the postal code, if the country has one
+}
+
+// addressRules holds the rules of the countries orders usually ship to.
+// Addresses in other countries only need a city.
+var addressRules = map[string]addressRule{
+	"VN": {
+		required:   []string{"ward", "district", "province"},
+		postalCode: regexp.MustCompile(`^\d{6}$`),
+	},
+	"US": {
+		required:   []string{"city", "province", "postal_code"},
+		postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	},
+	"CA": {
+		required:   []string{"city", "province", "postal_code"},
+		postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	},
+	"GB": {
+		required:   []string{"city", "postal_code"},
+		postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	},
+	"JP": {
+		required:   []string{"province", "city", "postal_code"},
+		postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	},
+	"SG": {
+		required:   []string{"postal_code"},
+		postalCode: regexp.MustCompile(`^\d{6}$`),
+	},
+}
+
+var defaultAddressRule = addressRule{required: []string{"city"}}
+
+var (
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	phonePattern   = regexp.MustCompile(`^\+?[0-9][0-9 ().-]{6,19}$`)
+)
+
+// addressFields maps the JSON names of the text fields of an address to the
+// fields, so rules can refer to them by name
+func addressFields(address *models.Address) map[string]*string {
+	return map[string]*string{
+		"recipient":   &address.Recipient,
+		"phone":       &address.Phone,
+		"line1":       &address.Line1,
+		"line2":       &address.Line2,
+		"ward":        &address.Ward,
+		"district":    &address.District,
+		"city":        &address.City,
+		"province":    &address.Province,
+		"postal_code": &address.PostalCode,
+		"country":     &address.Country,
+	}
+}
+
+// validateAddress trims an address, normalizes its country and postal code,
+// and checks it against the rules of its country
+func validateAddress(address *models.Address) error {
+	fields := addressFields(address)
+	for _, field := range fields {
+		*field = strings.TrimSpace(*field)
+	}
+	address.Country = strings.ToUpper(address.Country)
+	address.PostalCode = strings.ToUpper(address.PostalCode)
+
+	if !countryPattern.MatchString(address.Country) {
+		return fmt.Errorf("%w: country must be a two-letter ISO 3166 code", models.ErrInvalidAddress)
+	}
+
+	rule, ok := addressRules[address.Country]
+	if !ok {
+		rule = defaultAddressRule
+	}
+
+	required := append([]string{"recipient", "phone", "line1"}, rule.required...)
+	for _, name := range required {
+		if *fields[name] == "" {
+			return fmt.Errorf("%w: %s is required in %s", models.ErrInvalidAddress, name, address.Country)
+		}
+	}
+
+	if !phonePattern.MatchString(address.Phone) {
+		return fmt.Errorf("%w: phone is not a phone number", models.ErrInvalidAddress)
+	}
+	if address.PostalCode != "" && rule.postalCode != nil && !rule.postalCode.MatchString(address.PostalCode) {
+		return fmt.Errorf("%w: postal_code has the wrong format for %s", models.ErrInvalidAddress, address.Country)
+	}
+
+	return nil
+}
+
+// applyAddressRequest copies the fields of a request onto an address
+func applyAddressRequest(address *models.Address, req models.AddressRequest) {
+	address.Recipient = req.Recipient
+	address.Phone = req.Phone
+	address.Line1 = req.Line1
+	address.Line2 = req.Line2
+	address.Ward = req.Ward
+	address.District = req.District
+	address.City = req.City
+	address.Province = req.Province
+	address.PostalCode = req.PostalCode
+	address.Country = req.Country
+}
+
+// GetAddresses retrieves the address book of a user
+func (s *UserService) GetAddresses(userID string) ([]models.Address, error) {
+	_, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetAddresses(userID)
+}
+
+// GetAddress retrieves an address of a user
+func (s *UserService) GetAddress(userID string, id string) (models.Address, error) {
+	return s.repository.GetAddress(userID, id)
+}
+
+// CreateAddress adds an address to the address book of a user. The first
+// address becomes the default shipping and billing address.
+func (s *UserService) CreateAddress(userID string, req models.AddressRequest) (models.Address, error) {
+	_, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	count, err := s.repository.CountAddresses(userID)
+	if err != nil {
+		return models.Address{}, err
+	}
+	if count >= maxAddresses {
+		return models.Address{}, models.ErrTooManyAddresses
+	}
+
+	now := time.Now()
+	address := models.Address{
+		ID:              uuid.New().String(),
+		CustomerID:      userID,
+		DefaultShipping: req.DefaultShipping || count == 0,
+		DefaultBilling:  req.DefaultBilling || count == 0,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	applyAddressRequest(&address, req)
+
+	err = validateAddress(&address)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	err = s.repository.CreateAddress(address)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	return address, nil
+}
+
+// UpdateAddress changes an address of a user. The default flags of a request
+// only make the address a default; a default moves away by making another
+// address the default.
+func (s *UserService) UpdateAddress(userID string, id string, req models.AddressRequest) (models.Address, error) {
+	address, err := s.repository.GetAddress(userID, id)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	applyAddressRequest(&address, req)
+	address.DefaultShipping = address.DefaultShipping || req.DefaultShipping
+	address.DefaultBilling = address.DefaultBilling || req.DefaultBilling
+	address.UpdatedAt = time.Now()
+
+	err = validateAddress(&address)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	err = s.repository.UpdateAddress(address)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	return address, nil
+}
+
+// DeleteAddress removes an address from the address book of a user. Orders
+// placed with the address keep their copy of it.
+func (s *UserService) DeleteAddress(userID string, id string) error {
+	return s.repository.DeleteAddress(userID, id)
+}
+
+// shippingAddress returns the address an order of a user ships to: the given
+// address, or the default shipping address if none is given. It returns nil
+// if the user has no address yet.
+func (s *UserService) shippingAddress(userID string, addressID string) (*models.Address, error) {
+	if addressID != "" {
+		address, err := s.repository.GetAddress(userID, addressID)
+		if err != nil {
+			return nil, err
+		}
+		return &address, nil
+	}
+
+	address, err := s.repository.GetDefaultShippingAddress(userID)
+	if err == models.ErrAddressNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to get default shipping address of user %s: %v", userID, err)
+		return nil, err
+	}
+	return &address, nil
+}
+
+// hasAddress reports whether a free-text address matches the profile address
+// or one of the saved addresses of a user. Case, spacing and punctuation are
+// ignored.
+func (s *UserService) hasAddress(user models.Customer, text string) (bool, error) {
+	want := normalizeAddress(text)
+	if want == normalizeAddress(user.Address) {
+		return true, nil
+	}
+
+	addresses, err := s.repository.GetAddresses(user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, address := range addresses {
+		if want == normalizeAddress(address.String()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizeAddress lowercases an address and reduces everything between its
+// words to single spaces
+func normalizeAddress(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/models"
+)
+
+// recoveryCodeCount is the number of recovery codes an account gets
+const recoveryCodeCount = 10
+
+// maxMFAAttempts is the number of wrong codes an MFA challenge takes before
+// the login has to start over
+const maxMFAAttempts = 5
+
+// beginLogin finishes a login whose password was checked. Accounts that use
+// MFA get a challenge instead of tokens, and accounts whose role requires MFA
+// have to set it up before they get tokens.
+func (s *UserService) beginLogin(user models.Customer, client models.ClientInfo) (models.LoginResponse, error) {
+	enabled, err := s.mfaEnabled(user.ID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if enabled {
+		token, _, err := s.issueUserToken(user.ID, models.TokenPurposeMFAChallenge, s.config.MFAChallengeTTL)
+		if err != nil {
+			return models.LoginResponse{}, err
+		}
+		return models.LoginResponse{MFARequired: true, MFAToken: token}, nil
+	}
+
+	required, err := s.repository.IsMFARequired(user.Role)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if required {
+		token, _, err := s.issueUserToken(user.ID, models.TokenPurposeMFAEnrollment, s.config.MFAChallengeTTL)
+		if err != nil {
+			return models.LoginResponse{}, err
+		}
+		return models.LoginResponse{MFAEnrollmentRequired: true, MFAToken: token}, nil
+	}
+
+	return s.startSession(user, client)
+}
+
+// mfaEnabled reports whether a user has confirmed a TOTP secret
+func (s *UserService) mfaEnabled(userID string) (bool, error) {
+	mfa, err := s.repository.GetMFA(userID)
+	if err == models.ErrMFANotEnabled {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.EnabledAt != nil, nil
+}
+
+// mfaSatisfied reports whether a user may hold a session: either the user
+// uses MFA or the role of the user doesn't require it
+func (s *UserService) mfaSatisfied(user models.Customer) (bool, error) {
+	enabled, err := s.mfaEnabled(user.ID)
+	if err != nil || enabled {
+		return enabled, err
+	}
+
+	required, err := s.repository.IsMFARequired(user.Role)
+	if err != nil {
+		return false, err
+	}
+	return !required, nil
+}
+
+// VerifyMFA completes a login with a TOTP code or a recovery code. A challenge
+// only takes a few wrong codes before the login has to start over.
+func (s *UserService) VerifyMFA(req models.MFAVerifyRequest, client models.ClientInfo) (models.LoginResponse, error) {
+	tokenHash := auth.HashToken(req.MFAToken)
+	userID, err := s.repository.GetUserToken(tokenHash, models.TokenPurposeMFAChallenge)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.LoginResponse{}, models.ErrInvalidToken
+	}
+
+	// Wrong codes count as failed logins, so guessing codes across
+	// challenges locks the account out too
+	err = s.checkThrottle(throttleLogin, user.Email, client.IPAddress)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	err = s.checkSecondFactor(userID, req.Code, req.RecoveryCode)
+	if err == models.ErrInvalidMFACode {
+		if _, failErr := s.repository.FailUserToken(tokenHash, maxMFAAttempts); failErr != nil {
+			log.Printf("Failed to count MFA attempt of user %s: %v", userID, failErr)
+		}
+		s.loginFailed(user.Email, client)
+		return models.LoginResponse{}, err
+	}
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	user, err = s.useUserToken(req.MFAToken, models.TokenPurposeMFAChallenge)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	s.clearFailures(throttleLogin, user.Email)
+
+	// The account may have been locked since the password was checked
+	err = s.checkStatus(user)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// checkSecondFactor checks a TOTP code, or a recovery code if one is given.
+// Each TOTP code and each recovery code works only once.
+func (s *UserService) checkSecondFactor(userID string, code string, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := s.repository.UseRecoveryCode(userID, auth.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return models.ErrInvalidMFACode
+		}
+		log.Printf("User %s used a recovery code", userID)
+		return nil
+	}
+
+	mfa, err := s.repository.GetMFA(userID)
+	if err == models.ErrMFANotEnabled || (err == nil && mfa.EnabledAt == nil) {
+		return models.ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.VerifyTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return models.ErrInvalidMFACode
+	}
+
+	used, err := s.repository.UseTOTPStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		// The code was already used, for example by someone looking over
+		// the user's shoulder
+		return models.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// GetMFAStatus tells whether a user uses MFA and whether the role of the user
+// requires it
+func (s *UserService) GetMFAStatus(userID string) (models.MFAStatus, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.MFAStatus{}, err
+	}
+
+	var status models.MFAStatus
+	status.Enabled, err = s.mfaEnabled(userID)
+	if err != nil {
+		return models.MFAStatus{}, err
+	}
+	status.Required, err = s.repository.IsMFARequired(user.Role)
+	if err != nil {
+		return models.MFAStatus{}, err
+	}
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = s.repository.CountRecoveryCodes(userID)
+		if err != nil {
+			return models.MFAStatus{}, err
+		}
+	}
+
+	return status, nil
+}
+
+// MFAEnrollmentUser returns the user a login that has to set up MFA belongs to
+func (s *UserService) MFAEnrollmentUser(mfaToken string) (string, error) {
+	return s.repository.GetUserToken(auth.HashToken(mfaToken), models.TokenPurposeMFAEnrollment)
+}
+
+// EnrollMFA creates a new TOTP secret for a user. The secret only takes effect
+// once it is confirmed with a code, so an abandoned enrolment changes nothing.
+func (s *UserService) EnrollMFA(userID string) (models.MFAEnrollment, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+
+	enabled, err := s.mfaEnabled(userID)
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+	if enabled {
+		return models.MFAEnrollment{}, models.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+
+	err = s.repository.SaveMFASecret(userID, secret)
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+
+	return models.MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(s.config.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA turns on MFA for a user once a code from the new secret matches.
+// It returns the recovery codes, which are shown only this once.
+func (s *UserService) ConfirmMFA(userID string, code string) ([]string, error) {
+	mfa, err := s.repository.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.EnabledAt != nil {
+		return nil, models.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := auth.VerifyTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, models.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.EnableMFA(userID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s enabled MFA", userID)
+	return codes, nil
+}
+
+// CompleteMFAEnrollment confirms the TOTP secret of a login that had to set up
+// MFA and completes the login
+func (s *UserService) CompleteMFAEnrollment(mfaToken string, code string, client models.ClientInfo) (models.MFAConfirmResponse, error) {
+	userID, err := s.MFAEnrollmentUser(mfaToken)
+	if err != nil {
+		return models.MFAConfirmResponse{}, err
+	}
+
+	codes, err := s.ConfirmMFA(userID, code)
+	if err != nil {
+		return models.MFAConfirmResponse{}, err
+	}
+
+	user, err := s.useUserToken(mfaToken, models.TokenPurposeMFAEnrollment)
+	if err != nil {
+		return models.MFAConfirmResponse{}, err
+	}
+
+	err = s.checkStatus(user)
+	if err != nil {
+		return models.MFAConfirmResponse{}, err
+	}
+
+	response, err := s.startSession(user, client)
+	if err != nil {
+		return models.MFAConfirmResponse{}, err
+	}
+
+	return models.MFAConfirmResponse{RecoveryCodes: codes, LoginResponse: &response}, nil
+}
+
+// DisableMFA turns off MFA for a user after checking a code. Users whose role
+// requires MFA can't turn it off.
+func (s *UserService) DisableMFA(userID string, req models.MFACodeRequest) error {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	required, err := s.repository.IsMFARequired(user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return models.ErrMFARequired
+	}
+
+	err = s.checkSecondFactor(userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("User %s disabled MFA", userID)
+	return s.repository.DeleteMFA(userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after checking
+// a TOTP code
+func (s *UserService) RegenerateRecoveryCodes(userID string, code string) ([]string, error) {
+	err := s.checkSecondFactor(userID, code, "")
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.ReplaceRecoveryCodes(userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ResetMFA removes the MFA of a user who lost their authenticator and their
+// recovery codes. The sessions of the user are revoked, and accounts whose
+// role requires MFA have to set it up again on their next login.
+func (s *UserService) ResetMFA(userID string) error {
+	_, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DeleteMFA(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.revokeAll(userID)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s after MFA reset: %v", userID, err)
+		// Continue anyway, the tokens expire soon
+	}
+
+	log.Printf("MFA of user %s was reset", userID)
+	return nil
+}
+
+// GetMFAPolicy retrieves the roles that have to use MFA
+func (s *UserService) GetMFAPolicy() (models.MFAPolicy, error) {
+	roles, err := s.repository.GetMFARequiredRoles()
+	if err != nil {
+		return models.MFAPolicy{}, err
+	}
+	return models.MFAPolicy{RequiredRoles: roles}, nil
+}
+
+// UpdateMFAPolicy replaces the roles that have to use MFA. Accounts of those
+// roles without MFA have to set it up on their next login, and can't refresh
+// their sessions until then.
+func (s *UserService) UpdateMFAPolicy(policy models.MFAPolicy) (models.MFAPolicy, error) {
+	roles := []string{}
+	for _, role := range policy.RequiredRoles {
+		role = strings.TrimSpace(role)
+		if !auth.ValidRole(role) {
+			return models.MFAPolicy{}, models.ErrInvalidRole
+		}
+		if !contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	err := s.repository.SetMFARequiredRoles(roles)
+	if err != nil {
+		return models.MFAPolicy{}, err
+	}
+
+	log.Printf("MFA is now required for roles: %v", roles)
+	return s.GetMFAPolicy()
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
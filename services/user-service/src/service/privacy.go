@@ -0,0 +1,266 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/models"
+)
+
+// userServiceName names user-service in data exports and erasure reports
+const userServiceName = "user-service"
+
+// dataService is another service holding personal data of customers
+type dataService struct {
+	name string
+	url  string
+}
+
+// dataServices returns the services a data export gathers from and an
+// erasure waits for, besides user-service itself
+func (s *UserService) dataServices() []dataService {
+	return []dataService{
+		{"order-service", s.config.OrderServiceURL},
+		{"payment-service", s.config.PaymentServiceURL},
+		{"shipping-service", s.config.ShippingServiceURL},
+		{"notification-service", s.config.NotificationServiceURL},
+		{"cart-service", s.config.CartServiceURL},
+		{"inventory-service", s.config.InventoryServiceURL},
+	}
+}
+
+// userData gathers the personal data user-service holds about a customer
+func (s *UserService) userData(user models.Customer) (models.UserData, error) {
+	data := models.UserData{Profile: user}
+
+	var err error
+	data.Addresses, err = s.repository.GetAddresses(user.ID)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	data.Orders, err = s.repository.GetUserOrders(user.ID)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	data.Sessions, err = s.repository.GetActiveSessions(user.ID)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	data.MFA, err = s.GetMFAStatus(user.ID)
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	return data, nil
+}
+
+// ExportUserData gathers the personal data every service holds about a
+// customer into a zip archive with one JSON file per service. The export
+// fails if a service can't be reached, so it never looks complete when it
+// isn't.
+func (s *UserService) ExportUserData(id string) ([]byte, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.userData(user)
+	if err != nil {
+		return nil, err
+	}
+	userJSON, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{userServiceName + ".json": userJSON}
+	manifest := models.DataExportManifest{
+		CustomerID:  id,
+		GeneratedAt: time.Now(),
+		Files:       map[string]string{userServiceName: userServiceName + ".json"},
+	}
+
+	client := auth.NewClient(s, s.config.DataExportTimeout)
+	for _, service := range s.dataServices() {
+		serviceJSON, err := fetchCustomerData(client, service, id)
+		if err != nil {
+			log.Printf("Failed to export data of user %s from %s: %v", id, service.name, err)
+			return nil, fmt.Errorf("%w: %s is unavailable", models.ErrDataExportFailed, service.name)
+		}
+		files[service.name+".json"] = serviceJSON
+		manifest.Files[service.name] = service.name + ".json"
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files["manifest.json"] = manifestJSON
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Exported data of user %s", id)
+	return archive.Bytes(), nil
+}
+
+// fetchCustomerData gets the personal data a service holds about a customer
+func fetchCustomerData(client *http.Client, service dataService, customerID string) ([]byte, error) {
+	resp, err := client.Get(fmt.Sprintf("%s/customers/%s/data", service.url, customerID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return indented.Bytes(), nil
+}
+
+// RequestErasure erases the personal data of a customer. user-service erases
+// its own data right away: the account, its credentials, sessions, MFA and
+// address book. The other services are asked to erase theirs through the
+// privacy topic and report back, and the request completes once all of them
+// have.
+func (s *UserService) RequestErasure(id string, requestedBy string) (models.ErasureRequest, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+
+	// Counted before anything is erased, for the report of user-service
+	data, err := s.userData(user)
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+
+	request := models.ErasureRequest{
+		ID:          uuid.New().String(),
+		CustomerID:  id,
+		RequestedBy: requestedBy,
+		Status:      models.ErasurePending,
+		CreatedAt:   time.Now(),
+		Services:    []models.ErasureService{{Service: userServiceName, Status: models.ErasurePending}},
+	}
+	for _, service := range s.dataServices() {
+		request.Services = append(request.Services, models.ErasureService{Service: service.name, Status: models.ErasurePending})
+	}
+
+	err = s.repository.CreateErasureRequest(request)
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+
+	// Ask the other services first, so the account is only erased once the
+	// rest of its data will be too
+	err = s.producer.PublishErasureRequested(request, user.Email)
+	if err != nil {
+		now := time.Now()
+		if updateErr := s.repository.UpdateErasureStatus(request.ID, models.ErasureFailed, &now); updateErr != nil {
+			log.Printf("Failed to fail erasure request %s: %v", request.ID, updateErr)
+		}
+		return models.ErasureRequest{}, err
+	}
+
+	err = s.revokeAll(id)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s before erasure: %v", id, err)
+		// Continue anyway, the sessions are deleted with the account
+	}
+
+	report := models.ErasureEvent{
+		RequestID: request.ID,
+		Service:   userServiceName,
+		Status:    models.ErasureCompleted,
+		Erased:    1 + len(data.Addresses) + len(data.Orders) + len(data.Sessions),
+	}
+	err = s.repository.DeleteUser(id)
+	if err != nil {
+		log.Printf("Failed to erase user %s: %v", id, err)
+		report.Status, report.Erased, report.Note = models.ErasureFailed, 0, err.Error()
+	}
+
+	err = s.RecordErasureResult(report)
+	if err != nil {
+		return models.ErasureRequest{}, err
+	}
+
+	log.Printf("Erasure %s of user %s requested by %s", request.ID, id, requestedBy)
+	return s.repository.GetErasureRequest(request.ID)
+}
+
+// GetErasureRequests retrieves the erasure requests of a customer with the
+// report of each service
+func (s *UserService) GetErasureRequests(id string) ([]models.ErasureRequest, error) {
+	return s.repository.GetErasureRequests(id)
+}
+
+// RecordErasureResult stores the report of a service for an erasure request.
+// Once every service has reported, the request is completed, or failed if a
+// service failed.
+func (s *UserService) RecordErasureResult(event models.ErasureEvent) error {
+	now := time.Now()
+	found, err := s.repository.UpdateErasureService(event.RequestID, models.ErasureService{
+		Service:     event.Service,
+		Status:      event.Status,
+		Erased:      event.Erased,
+		Retained:    event.Retained,
+		Note:        event.Note,
+		CompletedAt: &now,
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Printf("Ignoring erasure report of %s for unknown request %s", event.Service, event.RequestID)
+		return nil
+	}
+
+	request, err := s.repository.GetErasureRequest(event.RequestID)
+	if err != nil {
+		return err
+	}
+
+	status := models.ErasureCompleted
+	for _, service := range request.Services {
+		if service.Status == models.ErasurePending {
+			return nil
+		}
+		if service.Status == models.ErasureFailed {
+			status = models.ErasureFailed
+		}
+	}
+
+	log.Printf("Erasure %s of user %s %s", request.ID, request.CustomerID, status)
+	return s.repository.UpdateErasureStatus(request.ID, status, &now)
+}
@@ -1,12 +1,14 @@
 package service
 
 import (
-	"encoding/base64"
-	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/cache"
 	"github.com/online-order-system/user-service/config"
 	"github.com/online-order-system/user-service/db"
 	"github.com/online-order-system/user-service/interfaces"
@@ -18,18 +20,37 @@ type UserService struct {
 	config     *config.Config
 	repository *db.UserRepository
 	producer   interfaces.UserProducer
+	cache      *cache.RedisCache
+	keyRing    *auth.KeyRing
+	verifier   *auth.Verifier
+	policy     auth.PasswordPolicy
+	dummyHash  string
 }
 
 // Ensure UserService implements UserService interface
 var _ interfaces.UserService = (*UserService)(nil)
 
 // NewUserService creates a new user service
-func NewUserService(cfg *config.Config, repo *db.UserRepository, producer interfaces.UserProducer) *UserService {
-	return &UserService{
+func NewUserService(cfg *config.Config, repo *db.UserRepository, producer interfaces.UserProducer, keyRing *auth.KeyRing, redisCache *cache.RedisCache) *UserService {
+	// Logins for unknown emails are checked against this hash so they take as
+	// long as logins for known ones
+	dummyHash, err := auth.HashPassword(uuid.New().String())
+	if err != nil {
+		log.Printf("Failed to create dummy password hash: %v", err)
+	}
+
+	s := &UserService{
 		config:     cfg,
 		repository: repo,
 		producer:   producer,
+		cache:      redisCache,
+		keyRing:    keyRing,
+		policy:     auth.PasswordPolicy{MinLength: cfg.PasswordMinLength},
+		dummyHash:  dummyHash,
 	}
+	s.verifier = auth.NewVerifier(keyRing, cfg.JWTIssuer, cfg.JWTAudience).WithRevocationList(s)
+
+	return s
 }
 
 // CreateUser creates a new user
@@ -37,7 +58,7 @@ func (s *UserService) CreateUser(req models.CreateCustomerRequest) (models.Custo
 	// Check if user with email already exists
 	_, err := s.GetUserByEmail(req.Email)
 	if err == nil {
-		return models.Customer{}, errors.New("user with this email already exists")
+		return models.Customer{}, models.ErrEmailTaken
 	}
 
 	// Create user
@@ -49,6 +70,9 @@ func (s *UserService) CreateUser(req models.CreateCustomerRequest) (models.Custo
 		LastName:  req.LastName,
 		Phone:     req.Phone,
 		Address:   req.Address,
+		Locale:    localeOrDefault(req.Locale),
+		Role:      auth.RoleCustomer,
+		Status:    models.StatusPendingVerification,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -59,9 +83,207 @@ func (s *UserService) CreateUser(req models.CreateCustomerRequest) (models.Custo
 		return models.Customer{}, err
 	}
 
+	err = s.sendVerificationEmail(user)
+	if err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		// Continue anyway, the user can ask for another one
+	}
+
 	return user, nil
 }
 
+// Register creates a new account with a password and logs it in. When email
+// verification is required, the account is only logged in after verifying.
+func (s *UserService) Register(req models.RegisterRequest, client models.ClientInfo) (models.LoginResponse, error) {
+	// Check the password before the email so the response doesn't reveal
+	// whether an account exists for a weak password
+	err := s.policy.Validate(req.Password, req.Email)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	// Check if user with email already exists
+	_, err = s.GetUserByEmail(req.Email)
+	if err == nil {
+		return models.LoginResponse{}, models.ErrEmailTaken
+	}
+
+	passwordHash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	// Create user
+	now := time.Now()
+	user := models.Customer{
+		ID:        uuid.New().String(),
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Phone:     req.Phone,
+		Address:   req.Address,
+		Locale:    localeOrDefault(req.Locale),
+		Role:      s.initialRole(req.Email),
+		Status:    models.StatusPendingVerification,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Save user and credentials to database
+	err = s.repository.CreateUserWithPassword(user, passwordHash)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	err = s.sendVerificationEmail(user)
+	if err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		// Continue anyway, the user can ask for another one
+	}
+
+	if s.config.RequireEmailVerification {
+		return models.LoginResponse{User: &user, VerificationRequired: true}, nil
+	}
+
+	return s.beginLogin(user, client)
+}
+
+// initialRole returns the role of a new account. Accounts of the configured
+// admin emails start as admins, so a fresh installation has someone who can
+// hand out roles.
+func (s *UserService) initialRole(email string) string {
+	for _, adminEmail := range s.config.AdminEmails {
+		if strings.EqualFold(adminEmail, email) {
+			return auth.RoleAdmin
+		}
+	}
+	return auth.RoleCustomer
+}
+
+// localeOrDefault returns the locale a new account asked for, or the default
+func localeOrDefault(locale string) string {
+	if locale == "" {
+		return models.DefaultLocale
+	}
+	return locale
+}
+
+// Login checks an email and password and issues a token, or an MFA challenge
+// for accounts that use MFA. Unknown emails and accounts without a password
+// go through the same hashing work as wrong passwords, so the response time
+// doesn't reveal which accounts exist.
+func (s *UserService) Login(req models.LoginRequest, client models.ClientInfo) (models.LoginResponse, error) {
+	err := s.checkThrottle(throttleLogin, req.Email, client.IPAddress)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	user, err := s.GetUserByEmail(req.Email)
+	if err != nil {
+		s.checkDummyPassword(req.Password)
+		s.loginFailed(req.Email, client)
+		return models.LoginResponse{}, models.ErrInvalidCredentials
+	}
+
+	err = s.checkPassword(user.ID, req.Password)
+	if err != nil {
+		if err == models.ErrInvalidCredentials {
+			s.loginFailed(req.Email, client)
+		}
+		return models.LoginResponse{}, err
+	}
+	s.clearFailures(throttleLogin, req.Email)
+
+	// Only tell about the account status once the password is known to match
+	err = s.checkStatus(user)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return s.beginLogin(user, client)
+}
+
+// ChangePassword replaces the password of a user after checking the current
+// one. Every other session of the user is revoked, so a session opened with
+// the old password doesn't survive the change.
+func (s *UserService) ChangePassword(userID string, sessionID string, req models.ChangePasswordRequest) error {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPassword(user.ID, req.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.policy.Validate(req.NewPassword, user.Email)
+	if err != nil {
+		return err
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return fmt.Errorf("%w: must differ from the current password", auth.ErrWeakPassword)
+	}
+
+	passwordHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.SetPasswordHash(user.ID, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	err = s.revokeAll(user.ID, sessionID)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s after password change: %v", user.ID, err)
+		// Continue anyway, the tokens expire soon
+	}
+
+	return nil
+}
+
+// checkPassword verifies the password of a user
+func (s *UserService) checkPassword(userID, password string) error {
+	passwordHash, err := s.repository.GetPasswordHash(userID)
+	if err != nil {
+		s.checkDummyPassword(password)
+		return models.ErrInvalidCredentials
+	}
+
+	ok, err := auth.VerifyPassword(passwordHash, password)
+	if err != nil {
+		log.Printf("Failed to verify password of user %s: %v", userID, err)
+		return models.ErrInvalidCredentials
+	}
+	if !ok {
+		return models.ErrInvalidCredentials
+	}
+
+	// Hashes made with older parameters are upgraded while the password is
+	// at hand
+	if auth.NeedsRehash(passwordHash) {
+		newHash, err := auth.HashPassword(password)
+		if err == nil {
+			err = s.repository.SetPasswordHash(userID, newHash)
+		}
+		if err != nil {
+			// Log error but continue, the old hash still works
+			log.Printf("Failed to upgrade password hash of user %s: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// checkDummyPassword spends the same time as checking a real password
+func (s *UserService) checkDummyPassword(password string) {
+	if s.dummyHash != "" {
+		auth.VerifyPassword(s.dummyHash, password)
+	}
+}
+
 // GetUserByID retrieves a user by ID
 func (s *UserService) GetUserByID(id string) (models.Customer, error) {
 	return s.repository.GetUserByID(id)
@@ -77,7 +299,9 @@ func (s *UserService) GetUsers() ([]models.Customer, error) {
 	return s.repository.GetUsers()
 }
 
-// UpdateUser updates a user
+// UpdateUser updates a user. A new email address has to be verified like
+// the first one: the account waits for verification again and a verification
+// link is sent to the new address.
 func (s *UserService) UpdateUser(id string, req models.UpdateCustomerRequest) (models.Customer, error) {
 	// Get existing user
 	user, err := s.GetUserByID(id)
@@ -86,8 +310,15 @@ func (s *UserService) UpdateUser(id string, req models.UpdateCustomerRequest) (m
 	}
 
 	// Update fields if provided
-	if req.Email != "" {
+	emailChanged := req.Email != "" && req.Email != user.Email
+	if emailChanged {
+		if _, err := s.GetUserByEmail(req.Email); err == nil {
+			return models.Customer{}, models.ErrEmailTaken
+		}
 		user.Email = req.Email
+		if user.Status == models.StatusActive {
+			user.Status = models.StatusPendingVerification
+		}
 	}
 	if req.FirstName != "" {
 		user.FirstName = req.FirstName
@@ -101,14 +332,30 @@ func (s *UserService) UpdateUser(id string, req models.UpdateCustomerRequest) (m
 	if req.Address != "" {
 		user.Address = req.Address
 	}
+	if req.Locale != "" {
+		user.Locale = req.Locale
+	}
 	user.UpdatedAt = time.Now()
 
 	// Save updated user to database
 	err = s.repository.UpdateUser(user)
 	if err != nil {
+		// Another account may have taken the email since it was checked
+		if other, lookupErr := s.GetUserByEmail(user.Email); lookupErr == nil && other.ID != id {
+			return models.Customer{}, models.ErrEmailTaken
+		}
 		return models.Customer{}, err
 	}
 
+	if emailChanged && user.Status == models.StatusPendingVerification {
+		// Earlier links went to the old address and stop working
+		err = s.sendVerificationEmail(user)
+		if err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+			// Continue anyway, the user can ask for a new link
+		}
+	}
+
 	// Publish user updated event
 	err = s.producer.PublishUserUpdated(user)
 	if err != nil {
@@ -119,6 +366,37 @@ func (s *UserService) UpdateUser(id string, req models.UpdateCustomerRequest) (m
 	return user, nil
 }
 
+// UpdateUserRole changes the role of a user. The sessions of the user are
+// revoked, so the old role can't be refreshed into new tokens.
+func (s *UserService) UpdateUserRole(id string, role string) (models.Customer, error) {
+	if !auth.ValidRole(role) {
+		return models.Customer{}, models.ErrInvalidRole
+	}
+
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	err = s.repository.UpdateUserRole(id, role)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now()
+
+	err = s.revokeAll(id)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %s after role change: %v", id, err)
+		// Continue anyway, the tokens expire soon
+	}
+
+	return user, nil
+}
+
 // DeleteUser deletes a user
 func (s *UserService) DeleteUser(id string) error {
 	// Check if user exists
@@ -131,10 +409,18 @@ func (s *UserService) DeleteUser(id string) error {
 	return s.repository.DeleteUser(id)
 }
 
-// VerifyUser verifies a user's information
-func (s *UserService) VerifyUser(req models.VerifyCustomerRequest) (models.VerifyCustomerResponse, error) {
+// VerifyUser verifies a user's information. Failed verifications of an
+// email are throttled like failed logins, so addresses can't be guessed.
+func (s *UserService) VerifyUser(req models.VerifyCustomerRequest, client models.ClientInfo) (models.VerifyCustomerResponse, error) {
 	var user models.Customer
-	var err error
+
+	err := s.checkThrottle(throttleVerify, req.Email, client.IPAddress)
+	if err != nil {
+		return models.VerifyCustomerResponse{
+			Verified: false,
+			Message:  "Too many failed verifications",
+		}, err
+	}
 
 	// If ID is provided, get user by ID
 	if req.ID != "" {
@@ -149,6 +435,7 @@ func (s *UserService) VerifyUser(req models.VerifyCustomerRequest) (models.Verif
 		// Otherwise, get user by email
 		user, err = s.GetUserByEmail(req.Email)
 		if err != nil {
+			s.recordFailure(throttleVerify, req.Email, client.IPAddress)
 			return models.VerifyCustomerResponse{
 				Verified: false,
 				Message:  "User not found",
@@ -156,16 +443,39 @@ func (s *UserService) VerifyUser(req models.VerifyCustomerRequest) (models.Verif
 		}
 	}
 
-	// If address is provided, verify it
-	if req.Address != "" && user.Address != req.Address {
+	if user.Status == models.StatusLocked {
 		return models.VerifyCustomerResponse{
 			Verified: false,
-			Message:  "Address does not match",
+			Message:  "Account is locked",
 		}, nil
 	}
 
-	// Generate a simple token (in a real app, this would be a JWT)
-	token := generateToken(user.ID, user.Email)
+	// If address is provided, it has to be one of the addresses of the user
+	if req.Address != "" {
+		matches, err := s.hasAddress(user, req.Address)
+		if err != nil {
+			return models.VerifyCustomerResponse{}, err
+		}
+		if !matches {
+			s.recordFailure(throttleVerify, req.Email, client.IPAddress)
+			return models.VerifyCustomerResponse{
+				Verified: false,
+				Message:  "Address does not match",
+			}, nil
+		}
+	}
+
+	// Look up the address an order ships to, so the caller can keep a copy
+	address, err := s.shippingAddress(user.ID, req.AddressID)
+	if err == models.ErrAddressNotFound {
+		return models.VerifyCustomerResponse{
+			Verified: false,
+			Message:  "Address not found",
+		}, nil
+	}
+	if err != nil {
+		return models.VerifyCustomerResponse{}, err
+	}
 
 	// Publish user verified event
 	err = s.producer.PublishUserVerified(user)
@@ -177,17 +487,27 @@ func (s *UserService) VerifyUser(req models.VerifyCustomerRequest) (models.Verif
 	return models.VerifyCustomerResponse{
 		Verified: true,
 		Message:  "User verified successfully",
-		Token:    token,
 		User:     &user,
+		Address:  address,
 	}, nil
 }
 
-// generateToken creates a simple token for authentication
-func generateToken(userID, email string) string {
-	// In a real app, this would be a JWT with proper signing
-	// For demo purposes, we'll just create a base64 encoded string
-	tokenData := userID + ":" + email + ":" + time.Now().Format(time.RFC3339)
-	return base64.StdEncoding.EncodeToString([]byte(tokenData))
+// IssueToken issues a signed access token for a user. Tokens tied to a
+// session stop being accepted as soon as the session is revoked.
+func (s *UserService) IssueToken(user models.Customer, sessionID string) (string, error) {
+	claims := auth.NewClaims(s.config.JWTIssuer, s.config.JWTAudience, s.config.JWTExpiration, user.ID, user.Email, user.Role)
+	claims.SessionID = sessionID
+	return s.keyRing.Sign(claims)
+}
+
+// ValidateToken verifies an access token and returns its claims
+func (s *UserService) ValidateToken(token string) (*auth.Claims, error) {
+	return s.verifier.Verify(token)
+}
+
+// JWKS returns the public keys that verify access tokens
+func (s *UserService) JWKS() auth.JWKS {
+	return s.keyRing.JWKS()
 }
 
 // GetUserOrders retrieves all orders for a user
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"crypto/subtle"
+	"log"
+
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/models"
+)
+
+// Ensure UserService can call other services with its own service tokens
+var _ auth.TokenSource = (*UserService)(nil)
+
+// IssueServiceToken issues a short-lived token another service calls the
+// others with, in exchange for the secret configured for it
+func (s *UserService) IssueServiceToken(req auth.ServiceTokenRequest) (auth.ServiceTokenResponse, error) {
+	secret, ok := s.config.ServiceCredentials[req.Service]
+	if !ok || subtle.ConstantTimeCompare([]byte(secret), []byte(req.Secret)) != 1 {
+		log.Printf("Refused service token for %s: invalid credentials", req.Service)
+		return auth.ServiceTokenResponse{}, models.ErrInvalidServiceCredentials
+	}
+
+	token, err := s.signServiceToken(req.Service)
+	if err != nil {
+		return auth.ServiceTokenResponse{}, err
+	}
+
+	log.Printf("Issued service token to %s", req.Service)
+	return auth.ServiceTokenResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresIn: int(s.config.ServiceTokenTTL.Seconds()),
+	}, nil
+}
+
+// Token returns a service token for the calls user-service makes to other
+// services. user-service signs its own tokens, so it needs no credentials.
+func (s *UserService) Token() (string, error) {
+	return s.signServiceToken(userServiceName)
+}
+
+// signServiceToken signs a service token for the named service
+func (s *UserService) signServiceToken(service string) (string, error) {
+	claims := auth.NewServiceClaims(s.config.JWTIssuer, s.config.JWTAudience, s.config.ServiceTokenTTL, service)
+	return s.keyRing.Sign(claims)
+}
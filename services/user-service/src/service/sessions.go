@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-order-system/user-service/auth"
+	"github.com/online-order-system/user-service/cache"
+	"github.com/online-order-system/user-service/models"
+)
+
+// Values cached under the session state key
+const (
+	sessionActive  = "active"
+	sessionRevoked = "revoked"
+)
+
+// activeSessionTTL bounds how long a session is trusted without asking the
+// database. Revocations overwrite the cached state right away, so this only
+// matters when Redis was unreachable at the time.
+const activeSessionTTL = time.Minute
+
+// sessionStateKey returns the cache key holding the state of a session
+func sessionStateKey(sessionID string) string {
+	return "session_state:" + sessionID
+}
+
+// startSession opens a session for a user and issues its first tokens
+func (s *UserService) startSession(user models.Customer, client models.ClientInfo) (models.LoginResponse, error) {
+	refreshToken, tokenHash, err := auth.NewToken()
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.config.RefreshTokenTTL),
+	}
+
+	err = s.repository.CreateSession(session, tokenHash)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return s.loginResponse(user, session.ID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once; presenting one that was already used
+// means it was stolen, so the whole session is revoked.
+func (s *UserService) Refresh(refreshToken string, client models.ClientInfo) (models.LoginResponse, error) {
+	oldHash := auth.HashToken(refreshToken)
+	sessionID, used, err := s.repository.GetRefreshToken(oldHash)
+	if err != nil {
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	if used {
+		log.Printf("Refresh token reused for session %s, revoking the session", sessionID)
+		s.revoke(sessionID)
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	session, err := s.repository.GetSession(sessionID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil || s.checkStatus(user) != nil {
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	// Sessions of roles that started requiring MFA end until it is set up
+	satisfied, err := s.mfaSatisfied(user)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if !satisfied {
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := auth.NewToken()
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	session.UserAgent = client.UserAgent
+	session.IPAddress = client.IPAddress
+	session.LastUsedAt = time.Now()
+
+	rotated, err := s.repository.RotateRefreshToken(oldHash, newHash, session)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if !rotated {
+		// Another request used the token between the lookup and the rotation
+		log.Printf("Refresh token reused for session %s, revoking the session", sessionID)
+		s.revoke(sessionID)
+		return models.LoginResponse{}, models.ErrInvalidRefreshToken
+	}
+
+	return s.loginResponse(user, session.ID, newToken)
+}
+
+// loginResponse issues an access token for a session
+func (s *UserService) loginResponse(user models.Customer, sessionID string, refreshToken string) (models.LoginResponse, error) {
+	token, err := s.IssueToken(user, sessionID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.config.JWTExpiration / time.Second),
+		User:         &user,
+	}, nil
+}
+
+// Logout revokes the session of the caller
+func (s *UserService) Logout(sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return s.revoke(sessionID)
+}
+
+// GetSessions retrieves the active sessions of a user
+func (s *UserService) GetSessions(userID string) ([]models.Session, error) {
+	// Check if user exists
+	_, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetActiveSessions(userID)
+}
+
+// RevokeSession revokes a session of a user
+func (s *UserService) RevokeSession(userID string, sessionID string) error {
+	session, err := s.repository.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return models.ErrSessionNotFound
+	}
+
+	return s.revoke(sessionID)
+}
+
+// revokeAll revokes every active session of a user but the sessions to keep
+func (s *UserService) revokeAll(userID string, keep ...string) error {
+	sessions, err := s.repository.GetActiveSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if contains(keep, session.ID) {
+			continue
+		}
+		err = s.revoke(session.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revoke marks a session as revoked in the database and the cache, so access
+// tokens of the session are rejected without waiting for them to expire
+func (s *UserService) revoke(sessionID string) error {
+	err := s.repository.RevokeSession(sessionID)
+	if err != nil {
+		log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		return err
+	}
+
+	// Access tokens of the session can't outlive the access token lifetime
+	err = s.cache.Set(context.Background(), sessionStateKey(sessionID), sessionRevoked, s.config.JWTExpiration)
+	if err != nil {
+		log.Printf("Failed to cache revocation of session %s: %v", sessionID, err)
+		// Continue anyway, the database is the source of truth
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether a session was revoked or has ended. It implements
+// auth.RevocationList.
+func (s *UserService) IsRevoked(sessionID string) (bool, error) {
+	ctx := context.Background()
+	key := sessionStateKey(sessionID)
+
+	var state string
+	err := s.cache.Get(ctx, key, &state)
+	if err == nil {
+		return state == sessionRevoked, nil
+	}
+	if err != cache.ErrCacheMiss {
+		log.Printf("Failed to get session state from cache: %v", err)
+	}
+
+	session, err := s.repository.GetSession(sessionID)
+	if err == models.ErrSessionNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		if cacheErr := s.cache.Set(ctx, key, sessionRevoked, s.config.JWTExpiration); cacheErr != nil {
+			log.Printf("Failed to cache session state: %v", cacheErr)
+		}
+		return true, nil
+	}
+
+	if cacheErr := s.cache.Set(ctx, key, sessionActive, activeSessionTTL); cacheErr != nil {
+		log.Printf("Failed to cache session state: %v", cacheErr)
+	}
+	return false, nil
+}
+
+// CleanupSessions deletes the sessions that ended more than a refresh token
+// lifetime ago, together with their refresh tokens
+func (s *UserService) CleanupSessions() {
+	count, err := s.repository.DeleteExpiredSessions(time.Now().Add(-s.config.RefreshTokenTTL))
+	if err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Deleted %d expired sessions", count)
+	}
+}
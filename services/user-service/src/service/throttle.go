@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/online-order-system/user-service/models"
+)
+
+// Scopes failed attempts are counted in. Only failed logins lock accounts;
+// failed verifications just slow down further verifications of the account.
+const (
+	throttleLogin  = "login"
+	throttleVerify = "verify"
+)
+
+// Progressive delays between failed attempts at the same account. The first
+// failure is free, each one after doubles the wait.
+const (
+	throttleBaseDelay = time.Second
+	throttleMaxDelay  = 30 * time.Second
+)
+
+// throttleKey returns the cache key of a piece of throttle state
+func throttleKey(scope string, kind string, subject string) string {
+	return "throttle:" + scope + ":" + kind + ":" + subject
+}
+
+// throttleSubject normalizes an email, so attempts can't dodge the counters
+// by changing case
+func throttleSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttleDelay returns how long to wait after the given number of failures
+func throttleDelay(failures int64) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+
+	delay := throttleBaseDelay
+	for i := int64(2); i < failures && delay < throttleMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > throttleMaxDelay {
+		delay = throttleMaxDelay
+	}
+	return delay
+}
+
+// checkThrottle refuses an attempt at an account while the account is locked
+// out, while the delay after its last failure runs, or while the IP address
+// is blocked. Throttle state lives in Redis; without it nothing is throttled.
+func (s *UserService) checkThrottle(scope string, email string, ipAddress string) error {
+	ctx := context.Background()
+	subject := throttleSubject(email)
+
+	lock, err := s.cache.TTL(ctx, throttleKey(scope, "lock", subject))
+	if err != nil {
+		log.Printf("Failed to get lockout of %s: %v", subject, err)
+	}
+	if lock > 0 {
+		return &models.ThrottledError{Err: models.ErrAccountTemporarilyLocked, RetryAfter: lock}
+	}
+
+	var ipFailures int
+	err = s.cache.Get(ctx, throttleKey(scope, "ip", ipAddress), &ipFailures)
+	if err == nil && ipFailures >= s.config.LoginIPMaxFailures {
+		window, _ := s.cache.TTL(ctx, throttleKey(scope, "ip", ipAddress))
+		return &models.ThrottledError{Err: models.ErrTooManyAttempts, RetryAfter: window}
+	}
+
+	wait, err := s.cache.TTL(ctx, throttleKey(scope, "next", subject))
+	if err != nil {
+		log.Printf("Failed to get attempt delay of %s: %v", subject, err)
+	}
+	if wait > 0 {
+		return &models.ThrottledError{Err: models.ErrTooManyAttempts, RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed attempt at an account from an IP address. It
+// returns when the account is locked out until if this failure locked it.
+// Only failed logins lock accounts out: failed verifications come from other
+// services and must not lock customers out of their accounts.
+func (s *UserService) recordFailure(scope string, email string, ipAddress string) (time.Time, bool) {
+	ctx := context.Background()
+	subject := throttleSubject(email)
+	window := s.config.LoginFailureWindow
+
+	if _, err := s.cache.Incr(ctx, throttleKey(scope, "ip", ipAddress), window); err != nil {
+		log.Printf("Failed to count failed attempt from %s: %v", ipAddress, err)
+	}
+
+	failures, err := s.cache.Incr(ctx, throttleKey(scope, "account", subject), window)
+	if err != nil {
+		log.Printf("Failed to count failed attempt at %s: %v", subject, err)
+		return time.Time{}, false
+	}
+	if failures == 0 {
+		// Running without Redis
+		return time.Time{}, false
+	}
+
+	if scope == throttleLogin && failures >= int64(s.config.LoginMaxFailures) {
+		err = s.cache.Set(ctx, throttleKey(scope, "lock", subject), failures, s.config.LoginLockoutDuration)
+		if err != nil {
+			log.Printf("Failed to lock out %s: %v", subject, err)
+			return time.Time{}, false
+		}
+		s.clearFailures(scope, email)
+		return time.Now().Add(s.config.LoginLockoutDuration), true
+	}
+
+	if delay := throttleDelay(failures); delay > 0 {
+		err = s.cache.Set(ctx, throttleKey(scope, "next", subject), failures, delay)
+		if err != nil {
+			log.Printf("Failed to delay attempts at %s: %v", subject, err)
+		}
+	}
+	return time.Time{}, false
+}
+
+// clearFailures forgets the failed attempts at an account, but not a lockout
+// that is already running
+func (s *UserService) clearFailures(scope string, email string) {
+	ctx := context.Background()
+	subject := throttleSubject(email)
+
+	for _, kind := range []string{"account", "next"} {
+		if err := s.cache.Delete(ctx, throttleKey(scope, kind, subject)); err != nil {
+			log.Printf("Failed to clear failed attempts at %s: %v", subject, err)
+		}
+	}
+}
+
+// loginFailed counts a failed login, or a failed MFA code, and alerts the
+// owner of the account if it got locked out because of it
+func (s *UserService) loginFailed(email string, client models.ClientInfo) {
+	lockedUntil, locked := s.recordFailure(throttleLogin, email, client.IPAddress)
+	if !locked {
+		return
+	}
+
+	user, err := s.GetUserByEmail(email)
+	if err != nil {
+		// Unknown emails are locked out all the same, so lockouts don't reveal
+		// which accounts exist, but there is no one to alert
+		return
+	}
+
+	log.Printf("User %s locked out until %s after too many failed logins", user.ID, lockedUntil.Format(time.RFC3339))
+	err = s.producer.PublishUserLocked(user, models.LockReasonFailedLogins, client.IPAddress, lockedUntil)
+	if err != nil {
+		log.Printf("Failed to publish user locked event: %v", err)
+		// Continue anyway, the lockout is in place
+	}
+}
+
+// UnlockUser lifts every lock on an account: the lockout after failed
+// attempts and a locked status
+func (s *UserService) UnlockUser(id string) (models.Customer, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	s.clearFailures(throttleLogin, user.Email)
+	s.clearFailures(throttleVerify, user.Email)
+	err = s.cache.Delete(context.Background(), throttleKey(throttleLogin, "lock", throttleSubject(user.Email)))
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	if user.Status == models.StatusLocked {
+		return s.UpdateUserStatus(id, models.StatusActive)
+	}
+
+	return user, nil
+}